@@ -0,0 +1,305 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/log"
+)
+
+// Parsing of the annotations that are retained at run time. They're in the attributes of
+// classes, fields, and methods, which are kept as raw bytes in the Attributes of ClData,
+// Field, and Method, and parsed only when they're asked for by reflection. Their layout
+// is in the JVM spec at: https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.16
+
+// Annotation is an annotation in a RuntimeVisibleAnnotations or
+// RuntimeVisibleParameterAnnotations attribute. Elements are in the order in which
+// they're in the class file, and elements that take their default value are absent.
+type Annotation struct {
+	Type     string // the name of the annotation interface, such as java/lang/Deprecated
+	Elements []AnnotationElement
+}
+
+// AnnotationElement is an element-value pair of an annotation
+type AnnotationElement struct {
+	Name  string
+	Value ElementValue
+}
+
+// ElementValue is the value of an annotation element. Tag is the tag of the element_value
+// structure, which determines the type of Value:
+//
+//	B C I S Z J   int64
+//	F D           float64
+//	s             string
+//	c             string, the return descriptor of the class, such as Ljava/lang/String; or V
+//	e             EnumConstant
+//	@             Annotation
+//	[             []ElementValue
+type ElementValue struct {
+	Tag   byte
+	Value interface{}
+}
+
+// EnumConstant is the value of an element of an enum type
+type EnumConstant struct {
+	Type string // the name of the enum class
+	Name string // the name of the constant
+}
+
+// FindAttribute returns the content of the attribute with the given name, or nil if
+// there's none. The CP is that of the class whose attributes, or whose field's or
+// method's attributes, are searched.
+func FindAttribute(cp *CPool, attributes []Attr, name string) []byte {
+	for _, attrib := range attributes {
+		if int(attrib.AttrName) < len(cp.Utf8Refs) && cp.Utf8Refs[attrib.AttrName] == name {
+			return attrib.AttrContent
+		}
+	}
+	return nil
+}
+
+// ParseAnnotations returns the annotations in the RuntimeVisibleAnnotations attribute
+// among the given attributes, or nil if there's no such attribute:
+//
+//	RuntimeVisibleAnnotations_attribute {
+//	    u2         attribute_name_index;
+//	    u4         attribute_length;
+//	    u2         num_annotations;
+//	    annotation annotations[num_annotations];
+//	}
+func ParseAnnotations(cp *CPool, attributes []Attr) ([]Annotation, error) {
+	content := FindAttribute(cp, attributes, "RuntimeVisibleAnnotations")
+	if content == nil {
+		return nil, nil
+	}
+	annotations, _, err := parseAnnotationList(cp, content, 0)
+	return annotations, err
+}
+
+// ParseParameterAnnotations returns the annotations of each of the parameters of a method
+// in its RuntimeVisibleParameterAnnotations attribute, or nil if there's no such attribute.
+// The attribute can list fewer parameters than the method's descriptor does, when the
+// compiler added parameters that aren't in the source code.
+//
+//	RuntimeVisibleParameterAnnotations_attribute {
+//	    u2 attribute_name_index;
+//	    u4 attribute_length;
+//	    u1 num_parameters;
+//	    {   u2         num_annotations;
+//	        annotation annotations[num_annotations];
+//	    } parameter_annotations[num_parameters];
+//	}
+func ParseParameterAnnotations(cp *CPool, attributes []Attr) ([][]Annotation, error) {
+	content := FindAttribute(cp, attributes, "RuntimeVisibleParameterAnnotations")
+	if content == nil {
+		return nil, nil
+	}
+	if len(content) < 1 {
+		return nil, annotationFormatError("missing parameter count")
+	}
+
+	paramCount := int(content[0])
+	loc := 1
+	paramAnnotations := make([][]Annotation, paramCount)
+	for i := 0; i < paramCount; i++ {
+		var err error
+		paramAnnotations[i], loc, err = parseAnnotationList(cp, content, loc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paramAnnotations, nil
+}
+
+// ParseAnnotationDefault returns the default value of an element of an annotation
+// interface, which is in the AnnotationDefault attribute of the element's method.
+// Returns nil if the element has no default.
+//
+//	AnnotationDefault_attribute {
+//	    u2            attribute_name_index;
+//	    u4            attribute_length;
+//	    element_value default_value;
+//	}
+func ParseAnnotationDefault(cp *CPool, attributes []Attr) (*ElementValue, error) {
+	content := FindAttribute(cp, attributes, "AnnotationDefault")
+	if content == nil {
+		return nil, nil
+	}
+	value, _, err := parseElementValue(cp, content, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// parses a count of annotations at loc, followed by the annotations. Returns the
+// annotations and the location of the first byte after them.
+func parseAnnotationList(cp *CPool, content []byte, loc int) ([]Annotation, int, error) {
+	count, loc, err := annotationU2(content, loc)
+	if err != nil {
+		return nil, loc, err
+	}
+
+	annotations := make([]Annotation, 0, count)
+	for i := 0; i < count; i++ {
+		var annotation Annotation
+		annotation, loc, err = parseAnnotation(cp, content, loc)
+		if err != nil {
+			return nil, loc, err
+		}
+		annotations = append(annotations, annotation)
+	}
+	return annotations, loc, nil
+}
+
+// parses the annotation at loc. Returns it and the location of the first byte after it.
+//
+//	annotation {
+//	    u2 type_index;
+//	    u2 num_element_value_pairs;
+//	    {   u2            element_name_index;
+//	        element_value value;
+//	    } element_value_pairs[num_element_value_pairs];
+//	}
+func parseAnnotation(cp *CPool, content []byte, loc int) (Annotation, int, error) {
+	annotation := Annotation{}
+	typeDesc, loc, err := annotationUTF8(cp, content, loc)
+	if err != nil {
+		return annotation, loc, err
+	}
+	annotation.Type = descriptorClassName(typeDesc)
+
+	pairCount, loc, err := annotationU2(content, loc)
+	if err != nil {
+		return annotation, loc, err
+	}
+	for i := 0; i < pairCount; i++ {
+		element := AnnotationElement{}
+		element.Name, loc, err = annotationUTF8(cp, content, loc)
+		if err != nil {
+			return annotation, loc, err
+		}
+		element.Value, loc, err = parseElementValue(cp, content, loc)
+		if err != nil {
+			return annotation, loc, err
+		}
+		annotation.Elements = append(annotation.Elements, element)
+	}
+	return annotation, loc, nil
+}
+
+// parses the element value at loc. Returns it and the location of the first byte after it.
+//
+//	element_value {
+//	    u1 tag;
+//	    union {
+//	        u2 const_value_index;
+//	        {   u2 type_name_index;
+//	            u2 const_name_index;
+//	        } enum_const_value;
+//	        u2 class_info_index;
+//	        annotation annotation_value;
+//	        {   u2            num_values;
+//	            element_value values[num_values];
+//	        } array_value;
+//	    } value;
+//	}
+func parseElementValue(cp *CPool, content []byte, loc int) (ElementValue, int, error) {
+	if loc >= len(content) {
+		return ElementValue{}, loc, annotationFormatError("element value past the end of the attribute")
+	}
+	value := ElementValue{Tag: content[loc]}
+	loc += 1
+
+	var index int
+	var err error
+	switch value.Tag {
+	case 'B', 'C', 'I', 'S', 'Z':
+		index, loc, err = annotationConstant(cp, content, loc, IntConst)
+		if err == nil {
+			value.Value = int64(cp.IntConsts[cp.CpIndex[index].Slot])
+		}
+	case 'J':
+		index, loc, err = annotationConstant(cp, content, loc, LongConst)
+		if err == nil {
+			value.Value = cp.LongConsts[cp.CpIndex[index].Slot]
+		}
+	case 'F':
+		index, loc, err = annotationConstant(cp, content, loc, FloatConst)
+		if err == nil {
+			value.Value = float64(cp.Floats[cp.CpIndex[index].Slot])
+		}
+	case 'D':
+		index, loc, err = annotationConstant(cp, content, loc, DoubleConst)
+		if err == nil {
+			value.Value = cp.Doubles[cp.CpIndex[index].Slot]
+		}
+	case 's', 'c':
+		value.Value, loc, err = annotationUTF8(cp, content, loc)
+	case 'e':
+		enum := EnumConstant{}
+		var typeDesc string
+		typeDesc, loc, err = annotationUTF8(cp, content, loc)
+		if err == nil {
+			enum.Type = descriptorClassName(typeDesc)
+			enum.Name, loc, err = annotationUTF8(cp, content, loc)
+		}
+		value.Value = enum
+	case '@':
+		value.Value, loc, err = parseAnnotation(cp, content, loc)
+	case '[':
+		var count int
+		count, loc, err = annotationU2(content, loc)
+		values := make([]ElementValue, 0, count)
+		for i := 0; i < count && err == nil; i++ {
+			var element ElementValue
+			element, loc, err = parseElementValue(cp, content, loc)
+			values = append(values, element)
+		}
+		value.Value = values
+	default:
+		err = annotationFormatError(fmt.Sprintf("invalid element value tag: %c", value.Tag))
+	}
+	return value, loc, err
+}
+
+// reads the u2 at loc. Returns it and the location of the first byte after it.
+func annotationU2(content []byte, loc int) (int, int, error) {
+	if loc+2 > len(content) {
+		return 0, loc, annotationFormatError("unexpected end of the attribute")
+	}
+	return int(content[loc])<<8 | int(content[loc+1]), loc + 2, nil
+}
+
+// reads the CP index at loc, which must point to a UTF8 entry, and returns the string
+func annotationUTF8(cp *CPool, content []byte, loc int) (string, int, error) {
+	index, loc, err := annotationConstant(cp, content, loc, UTF8)
+	if err != nil {
+		return "", loc, err
+	}
+	return cp.Utf8Refs[cp.CpIndex[index].Slot], loc, nil
+}
+
+// reads the CP index at loc and checks that it points to an entry of the given type
+func annotationConstant(cp *CPool, content []byte, loc int, entryType int) (int, int, error) {
+	index, loc, err := annotationU2(content, loc)
+	if err != nil {
+		return 0, loc, err
+	}
+	if index < 1 || index >= len(cp.CpIndex) || int(cp.CpIndex[index].Type) != entryType {
+		return 0, loc, annotationFormatError(fmt.Sprintf("invalid constant pool index: %d", index))
+	}
+	return index, loc, nil
+}
+
+func annotationFormatError(msg string) error {
+	errMsg := "Invalid annotation: " + msg
+	_ = log.Log(errMsg, log.SEVERE)
+	return errors.New(errMsg)
+}
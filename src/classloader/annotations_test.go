@@ -0,0 +1,119 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"reflect"
+	"testing"
+)
+
+// a CP holding the entries the annotation tests refer to. The CP indexes of the
+// entries are given in the comments.
+func newAnnotationTestCP() *CPool {
+	cp := CPool{CpIndex: []CpEntry{{}}}
+	utf8 := func(s string) {
+		cp.CpIndex = append(cp.CpIndex, CpEntry{UTF8, uint16(len(cp.Utf8Refs))})
+		cp.Utf8Refs = append(cp.Utf8Refs, s)
+	}
+
+	utf8("RuntimeVisibleAnnotations")                                             // 1
+	utf8("Lcom/example/Config;")                                                  // 2
+	utf8("name")                                                                  // 3
+	utf8("svc")                                                                   // 4
+	utf8("size")                                                                  // 5
+	cp.CpIndex = append(cp.CpIndex, CpEntry{IntConst, uint16(len(cp.IntConsts))}) // 6
+	cp.IntConsts = append(cp.IntConsts, 8080)
+	utf8("level")                              // 7
+	utf8("Lcom/example/Level;")                // 8
+	utf8("HIGH")                               // 9
+	utf8("tags")                               // 10
+	utf8("Ljava/lang/Override;")               // 11
+	utf8("RuntimeVisibleParameterAnnotations") // 12
+	utf8("AnnotationDefault")                  // 13
+	utf8("Ljava/lang/String;")                 // 14
+	return &cp
+}
+
+func TestParseAnnotations(t *testing.T) {
+	defer silenceAttributeTest()()
+	cp := newAnnotationTestCP()
+
+	// @Config(name="svc", size=8080, level=Level.HIGH, tags={"svc"}), @Override
+	content := []byte{
+		0x00, 0x02, // two annotations
+		0x00, 0x02, 0x00, 0x04, // Config, with four elements
+		0x00, 0x03, 's', 0x00, 0x04,
+		0x00, 0x05, 'I', 0x00, 0x06,
+		0x00, 0x07, 'e', 0x00, 0x08, 0x00, 0x09,
+		0x00, 0x0A, '[', 0x00, 0x01, 's', 0x00, 0x04,
+		0x00, 0x0B, 0x00, 0x00, // Override, with no elements
+	}
+	attrs := []Attr{{AttrName: 0, AttrSize: len(content), AttrContent: content}}
+	annotations, err := ParseAnnotations(cp, attrs)
+	if err != nil {
+		t.Fatalf("ParseAnnotations: Unexpected error: %v", err)
+	}
+	expected := []Annotation{
+		{Type: "com/example/Config", Elements: []AnnotationElement{
+			{"name", ElementValue{'s', "svc"}},
+			{"size", ElementValue{'I', int64(8080)}},
+			{"level", ElementValue{'e', EnumConstant{"com/example/Level", "HIGH"}}},
+			{"tags", ElementValue{'[', []ElementValue{{'s', "svc"}}}},
+		}},
+		{Type: "java/lang/Override"},
+	}
+	if !reflect.DeepEqual(annotations, expected) {
+		t.Errorf("ParseAnnotations: Expected %v, got: %v", expected, annotations)
+	}
+
+	if annotations, err = ParseAnnotations(cp, nil); annotations != nil || err != nil {
+		t.Errorf("ParseAnnotations: Expected nothing without the attribute, got: %v, %v", annotations, err)
+	}
+}
+
+func TestParseParameterAnnotationsAndDefault(t *testing.T) {
+	defer silenceAttributeTest()()
+	cp := newAnnotationTestCP()
+
+	content := []byte{
+		0x02,       // two parameters
+		0x00, 0x00, // the first has no annotations
+		0x00, 0x01, 0x00, 0x0B, 0x00, 0x00, // @Override on the second
+	}
+	params, err := ParseParameterAnnotations(cp, []Attr{{AttrName: 10, AttrSize: len(content), AttrContent: content}})
+	if err != nil {
+		t.Fatalf("ParseParameterAnnotations: Unexpected error: %v", err)
+	}
+	expected := [][]Annotation{{}, {{Type: "java/lang/Override"}}}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("ParseParameterAnnotations: Expected %v, got: %v", expected, params)
+	}
+
+	content = []byte{'c', 0x00, 0x0E}
+	value, err := ParseAnnotationDefault(cp, []Attr{{AttrName: 11, AttrSize: len(content), AttrContent: content}})
+	if err != nil || value == nil || *value != (ElementValue{'c', "Ljava/lang/String;"}) {
+		t.Errorf("ParseAnnotationDefault: Expected the class String, got: %v, %v", value, err)
+	}
+}
+
+func TestParseInvalidAnnotations(t *testing.T) {
+	defer silenceAttributeTest()()
+	cp := newAnnotationTestCP()
+
+	invalid := [][]byte{
+		{0x00, 0x01, 0x00, 0x02}, // truncated
+		{0x00, 0x01, 0x00, 0x02, 0x00, 0x01, 0x00, 0x03, 'x', 0x00, 0x04}, // invalid tag
+		{0x00, 0x01, 0x00, 0x02, 0x00, 0x01, 0x00, 0x03, 'I', 0x00, 0x04}, // I pointing at a UTF8 entry
+		{0x00, 0x01, 0x00, 0x63, 0x00, 0x00},                              // type index out of range
+	}
+	for i, content := range invalid {
+		attrs := []Attr{{AttrName: 0, AttrSize: len(content), AttrContent: content}}
+		if _, err := ParseAnnotations(cp, attrs); err == nil {
+			t.Errorf("ParseAnnotations: Expected an error for invalid annotation %d", i)
+		}
+	}
+}
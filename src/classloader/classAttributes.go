@@ -0,0 +1,407 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/log"
+	"strconv"
+)
+
+// Parsing of the class attributes that describe how a class relates to other classes
+// and modules: InnerClasses, EnclosingMethod, NestHost, NestMembers, PermittedSubclasses,
+// Record, Signature, and Module. The layouts are in the JVM spec at:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7
+// The CP entries the attributes refer to are resolved to strings as they're parsed.
+// The attributes themselves are kept in the attributes of the class as well.
+
+// fetches the name of the class referred to by the CP index found at loc in the
+// attribute content. The index must point to a ClassRef entry.
+func fetchClassName(klass *ParsedClass, content []byte, loc int) (string, error) {
+	index, err := intFrom2Bytes(content, loc)
+	if err != nil {
+		return "", err
+	}
+	if index < 1 || index > klass.cpCount-1 || klass.cpIndex[index].entryType != ClassRef {
+		return "", cfe("CP entry #" + strconv.Itoa(index) + " is not a ClassRef entry")
+	}
+	return FetchUTF8string(klass, klass.classRefs[klass.cpIndex[index].slot])
+}
+
+// same as fetchClassName(), except that an index of 0, which means there's no class,
+// is allowed, in which case "" is returned
+func fetchOptionalClassName(klass *ParsedClass, content []byte, loc int) (string, error) {
+	index, err := intFrom2Bytes(content, loc)
+	if err != nil || index == 0 {
+		return "", err
+	}
+	return fetchClassName(klass, content, loc)
+}
+
+// fetches the UTF8 string whose CP index is found at loc in the attribute content.
+// An index of 0 is allowed, in which case "" is returned.
+func fetchOptionalUTF8string(klass *ParsedClass, content []byte, loc int) (string, error) {
+	index, err := intFrom2Bytes(content, loc)
+	if err != nil || index == 0 {
+		return "", err
+	}
+	return FetchUTF8string(klass, index)
+}
+
+// fetches a count, which is found at loc in the attribute content, followed by that many
+// CP indexes of ClassRef entries. Returns the names of the classes and the location of
+// the first byte after the indexes.
+func fetchClassNames(klass *ParsedClass, content []byte, loc int) ([]string, int, error) {
+	count, err := intFrom2Bytes(content, loc)
+	loc += 2
+	if err != nil {
+		return nil, loc, err
+	}
+
+	names := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		name, err := fetchClassName(klass, content, loc)
+		loc += 2
+		if err != nil {
+			return nil, loc, err
+		}
+		names = append(names, name)
+	}
+	return names, loc, nil
+}
+
+// fetches the name of the module or package (per entryType) referred to by the CP index
+// found at loc in the attribute content. An index of 0 is an error.
+func fetchModuleOrPackageName(klass *ParsedClass, content []byte, loc int, entryType int) (string, error) {
+	index, err := intFrom2Bytes(content, loc)
+	if err != nil {
+		return "", err
+	}
+	if index < 1 || index > klass.cpCount-1 || klass.cpIndex[index].entryType != entryType {
+		entryName := "Module"
+		if entryType == Package {
+			entryName = "Package"
+		}
+		return "", cfe("CP entry #" + strconv.Itoa(index) + " is not a " + entryName + " entry")
+	}
+	return FetchUTF8string(klass, klass.cpIndex[index].slot) // the slot is the CP index of the name
+}
+
+// fetches the signature in a Signature attribute, which can be an attribute of a class,
+// field, method, or record component:
+//
+//	Signature_attribute {
+//	    u2 attribute_name_index;
+//	    u4 attribute_length;
+//	    u2 signature_index;
+//	}
+func fetchSignature(klass *ParsedClass, att attr) (string, error) {
+	index, err := intFrom2Bytes(att.attrContent, 0)
+	if err != nil {
+		return "", err
+	}
+	return FetchUTF8string(klass, index)
+}
+
+// the InnerClasses attribute lists the classes that are members of the class, or that
+// the class refers to, and that are not members of a package:
+//
+//	InnerClasses_attribute {
+//	    u2 attribute_name_index;
+//	    u4 attribute_length;
+//	    u2 number_of_classes;
+//	    {   u2 inner_class_info_index;
+//	        u2 outer_class_info_index;
+//	        u2 inner_name_index;
+//	        u2 inner_class_access_flags;
+//	    } classes[number_of_classes];
+//	}
+func parseInnerClassesAttribute(att attr, klass *ParsedClass) error {
+	content := att.attrContent
+	classCount, err := intFrom2Bytes(content, 0)
+	if err != nil {
+		return cfe("Invalid InnerClasses attribute in class: " + klass.className)
+	}
+
+	klass.innerClasses = make([]InnerClass, 0, classCount)
+	for i := 0; i < classCount; i++ {
+		loc := 2 + i*8
+		innerClass := InnerClass{}
+		innerClass.InnerClass, err = fetchClassName(klass, content, loc)
+		if err == nil {
+			innerClass.OuterClass, err = fetchOptionalClassName(klass, content, loc+2)
+		}
+		if err == nil {
+			innerClass.Name, err = fetchOptionalUTF8string(klass, content, loc+4)
+		}
+		if err == nil {
+			innerClass.AccessFlags, err = intFrom2Bytes(content, loc+6)
+		}
+		if err != nil {
+			return cfe("Invalid entry #" + strconv.Itoa(i+1) + " in InnerClasses attribute in class: " +
+				klass.className)
+		}
+		klass.innerClasses = append(klass.innerClasses, innerClass)
+		_ = log.Log("    Inner class: "+innerClass.InnerClass, log.FINEST)
+	}
+	return nil
+}
+
+// the EnclosingMethod attribute of a local or anonymous class gives the class and, if
+// any, the method in which it's declared:
+//
+//	EnclosingMethod_attribute {
+//	    u2 attribute_name_index;
+//	    u4 attribute_length;
+//	    u2 class_index;
+//	    u2 method_index;   // 0 or a NameAndType entry
+//	}
+func parseEnclosingMethodAttribute(att attr, klass *ParsedClass) error {
+	enclosing := EnclosingMethod{}
+	var err error
+	enclosing.Class, err = fetchClassName(klass, att.attrContent, 0)
+	if err != nil {
+		return cfe("Invalid class in EnclosingMethod attribute in class: " + klass.className)
+	}
+
+	methodIndex, err := intFrom2Bytes(att.attrContent, 2)
+	if err != nil {
+		return cfe("Invalid method in EnclosingMethod attribute in class: " + klass.className)
+	}
+	if methodIndex != 0 {
+		if methodIndex > klass.cpCount-1 || klass.cpIndex[methodIndex].entryType != NameAndType {
+			return cfe("Method in EnclosingMethod attribute in class: " + klass.className +
+				" does not point to a NameAndType CP entry")
+		}
+		enclosing.MethodName, enclosing.MethodType, err = ResolveCPnameAndType(klass, methodIndex)
+		if err != nil {
+			return err
+		}
+	}
+
+	klass.enclosingMethod = &enclosing
+	_ = log.Log("    Enclosing method: "+enclosing.Class+"."+enclosing.MethodName+enclosing.MethodType,
+		log.FINEST)
+	return nil
+}
+
+// the Record attribute lists the components of a record class. Each component has its
+// own attributes, of which Signature is parsed here and all are kept as raw bytes:
+//
+//	Record_attribute {
+//	    u2 attribute_name_index;
+//	    u4 attribute_length;
+//	    u2 components_count;
+//	    {   u2 name_index;
+//	        u2 descriptor_index;
+//	        u2 attributes_count;
+//	        attribute_info attributes[attributes_count];
+//	    } components[components_count];
+//	}
+func parseRecordAttribute(att attr, klass *ParsedClass) error {
+	content := att.attrContent
+	componentCount, err := intFrom2Bytes(content, 0)
+	if err != nil {
+		return cfe("Invalid Record attribute in class: " + klass.className)
+	}
+
+	loc := 2
+	klass.recordComponents = make([]RecordComponent, 0, componentCount)
+	for i := 0; i < componentCount; i++ {
+		component := RecordComponent{}
+		nameIndex, err1 := intFrom2Bytes(content, loc)
+		descIndex, err2 := intFrom2Bytes(content, loc+2)
+		attrCount, err3 := intFrom2Bytes(content, loc+4)
+		loc += 6
+		if err1 != nil || err2 != nil || err3 != nil {
+			return cfe("Invalid component #" + strconv.Itoa(i+1) + " in Record attribute in class: " +
+				klass.className)
+		}
+		component.Name, err1 = FetchUTF8string(klass, nameIndex)
+		component.Desc, err2 = FetchUTF8string(klass, descIndex)
+		if err1 != nil || err2 != nil {
+			return cfe("Invalid name or descriptor of component #" + strconv.Itoa(i+1) +
+				" in Record attribute in class: " + klass.className)
+		}
+
+		for j := 0; j < attrCount; j++ {
+			// fetchAttribute() starts 1 byte after the location it's passed
+			componentAttr, location, err := fetchAttribute(klass, content, loc-1)
+			if err != nil {
+				return cfe("Invalid attribute of component " + component.Name +
+					" in Record attribute in class: " + klass.className)
+			}
+			loc = location + 1
+
+			if klass.utf8Refs[componentAttr.attrName].content == "Signature" {
+				component.Signature, err = fetchSignature(klass, componentAttr)
+				if err != nil {
+					return cfe("Invalid Signature attribute of component " + component.Name +
+						" in Record attribute in class: " + klass.className)
+				}
+			}
+			component.Attributes = append(component.Attributes, Attr{
+				AttrName:    uint16(componentAttr.attrName),
+				AttrSize:    componentAttr.attrSize,
+				AttrContent: componentAttr.attrContent,
+			})
+		}
+
+		klass.recordComponents = append(klass.recordComponents, component)
+		_ = log.Log("    Record component: "+component.Name+" "+component.Desc, log.FINEST)
+	}
+	return nil
+}
+
+// the Module attribute of a module-info class describes the module:
+//
+//	Module_attribute {
+//	    u2 attribute_name_index;
+//	    u4 attribute_length;
+//	    u2 module_name_index;
+//	    u2 module_flags;
+//	    u2 module_version_index;
+//	    u2 requires_count;
+//	    {   u2 requires_index;
+//	        u2 requires_flags;
+//	        u2 requires_version_index;
+//	    } requires[requires_count];
+//	    u2 exports_count;
+//	    {   u2 exports_index;
+//	        u2 exports_flags;
+//	        u2 exports_to_count;
+//	        u2 exports_to_index[exports_to_count];
+//	    } exports[exports_count];
+//	    u2 opens_count;
+//	    {   u2 opens_index;
+//	        u2 opens_flags;
+//	        u2 opens_to_count;
+//	        u2 opens_to_index[opens_to_count];
+//	    } opens[opens_count];
+//	    u2 uses_count;
+//	    u2 uses_index[uses_count];
+//	    u2 provides_count;
+//	    {   u2 provides_index;
+//	        u2 provides_with_count;
+//	        u2 provides_with_index[provides_with_count];
+//	    } provides[provides_count];
+//	}
+func parseModuleAttribute(att attr, klass *ParsedClass) error {
+	content := att.attrContent
+	module := ModuleInfo{}
+	var err error
+	errMsg := func(what string) error {
+		return cfe("Invalid " + what + " in Module attribute in class: " + klass.className)
+	}
+
+	module.Name, err = fetchModuleOrPackageName(klass, content, 0, Module)
+	if err != nil {
+		return errMsg("module name")
+	}
+	module.Flags, err = intFrom2Bytes(content, 2)
+	if err == nil {
+		module.Version, err = fetchOptionalUTF8string(klass, content, 4)
+	}
+	if err != nil {
+		return errMsg("module flags or version")
+	}
+
+	loc := 6
+	requiresCount, err := intFrom2Bytes(content, loc)
+	loc += 2
+	if err != nil {
+		return errMsg("requires count")
+	}
+	for i := 0; i < requiresCount; i++ {
+		requires := ModuleRequires{}
+		requires.Module, err = fetchModuleOrPackageName(klass, content, loc, Module)
+		if err == nil {
+			requires.Flags, err = intFrom2Bytes(content, loc+2)
+		}
+		if err == nil {
+			requires.Version, err = fetchOptionalUTF8string(klass, content, loc+4)
+		}
+		loc += 6
+		if err != nil {
+			return errMsg("requires entry #" + strconv.Itoa(i+1))
+		}
+		module.Requires = append(module.Requires, requires)
+	}
+
+	module.Exports, loc, err = parseModulePackages(klass, content, loc)
+	if err != nil {
+		return errMsg("exports")
+	}
+	module.Opens, loc, err = parseModulePackages(klass, content, loc)
+	if err != nil {
+		return errMsg("opens")
+	}
+	module.Uses, loc, err = fetchClassNames(klass, content, loc)
+	if err != nil {
+		return errMsg("uses")
+	}
+
+	providesCount, err := intFrom2Bytes(content, loc)
+	loc += 2
+	if err != nil {
+		return errMsg("provides count")
+	}
+	for i := 0; i < providesCount; i++ {
+		provides := ModuleProvides{}
+		provides.Service, err = fetchClassName(klass, content, loc)
+		if err == nil {
+			provides.With, loc, err = fetchClassNames(klass, content, loc+2)
+		}
+		if err != nil {
+			return errMsg("provides entry #" + strconv.Itoa(i+1))
+		}
+		module.Provides = append(module.Provides, provides)
+	}
+
+	klass.moduleInfo = &module
+	klass.moduleName = module.Name
+	_ = log.Log("    Module: "+module.Name, log.FINEST)
+	return nil
+}
+
+// parses the exports or the opens of a Module attribute, starting with their count at
+// loc. Returns them and the location of the first byte after them.
+func parseModulePackages(klass *ParsedClass, content []byte, loc int) ([]ModulePackage, int, error) {
+	count, err := intFrom2Bytes(content, loc)
+	loc += 2
+	if err != nil {
+		return nil, loc, err
+	}
+
+	var packages []ModulePackage
+	for i := 0; i < count; i++ {
+		pkg := ModulePackage{}
+		pkg.Package, err = fetchModuleOrPackageName(klass, content, loc, Package)
+		if err == nil {
+			pkg.Flags, err = intFrom2Bytes(content, loc+2)
+		}
+		if err != nil {
+			return nil, loc, err
+		}
+		loc += 4
+
+		toCount, err := intFrom2Bytes(content, loc)
+		loc += 2
+		if err != nil {
+			return nil, loc, err
+		}
+		for j := 0; j < toCount; j++ {
+			to, err := fetchModuleOrPackageName(klass, content, loc, Module)
+			loc += 2
+			if err != nil {
+				return nil, loc, err
+			}
+			pkg.To = append(pkg.To, to)
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, loc, nil
+}
@@ -0,0 +1,256 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// a parsed class whose CP holds the entries the attribute tests refer to. The CP
+// indexes of the entries are given in the comments.
+func newAttributeTestClass() *ParsedClass {
+	klass := ParsedClass{className: "com/example/Outer"}
+	utf8 := func(s string) {
+		klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, len(klass.utf8Refs)})
+		klass.utf8Refs = append(klass.utf8Refs, utf8Entry{s})
+	}
+	classRef := func(utf8Index int) {
+		klass.cpIndex = append(klass.cpIndex, cpEntry{ClassRef, len(klass.classRefs)})
+		klass.classRefs = append(klass.classRefs, utf8Index)
+	}
+
+	klass.cpIndex = append(klass.cpIndex, cpEntry{})
+	utf8("com/example/Outer")                                      // 1
+	classRef(1)                                                    // 2
+	utf8("com/example/Outer$Inner")                                // 3
+	classRef(3)                                                    // 4
+	utf8("Inner")                                                  // 5
+	utf8("run")                                                    // 6
+	utf8("()V")                                                    // 7
+	klass.cpIndex = append(klass.cpIndex, cpEntry{NameAndType, 0}) // 8
+	klass.nameAndTypes = append(klass.nameAndTypes, nameAndTypeEntry{6, 7})
+	utf8("<T:Ljava/lang/Object;>Ljava/lang/Object;")            // 9
+	utf8("x")                                                   // 10
+	utf8("I")                                                   // 11
+	utf8("com.example")                                         // 12
+	klass.cpIndex = append(klass.cpIndex, cpEntry{Module, 12})  // 13
+	utf8("com/example/api")                                     // 14
+	klass.cpIndex = append(klass.cpIndex, cpEntry{Package, 14}) // 15
+	utf8("java.base")                                           // 16
+	klass.cpIndex = append(klass.cpIndex, cpEntry{Module, 16})  // 17
+	utf8("17")                                                  // 18
+	utf8("Signature")                                           // 19
+	klass.cpCount = len(klass.cpIndex)
+	return &klass
+}
+
+// runs parseClassAttributes() on a single attribute with the given name and content
+func parseTestClassAttribute(klass *ParsedClass, name string, content []byte) error {
+	nameIndex := len(klass.cpIndex)
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, len(klass.utf8Refs)})
+	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{name})
+	klass.cpCount = len(klass.cpIndex)
+	klass.attribCount = 1
+
+	// there's a leading dummy byte b/c the fetch routine starts at 1 byte after the
+	// passed-in position
+	bytes := []byte{0x00, byte(nameIndex >> 8), byte(nameIndex),
+		0x00, 0x00, byte(len(content) >> 8), byte(len(content))}
+	bytes = append(bytes, content...)
+	_, err := parseClassAttributes(bytes, 0, klass)
+	return err
+}
+
+func silenceAttributeTest() func() {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	return func() {
+		_ = w.Close()
+		os.Stderr = normalStderr
+	}
+}
+
+func TestInnerClassesAndEnclosingMethodAttributes(t *testing.T) {
+	defer silenceAttributeTest()()
+	klass := newAttributeTestClass()
+
+	err := parseTestClassAttribute(klass, "InnerClasses", []byte{
+		0x00, 0x02, // two classes
+		0x00, 0x04, 0x00, 0x02, 0x00, 0x05, 0x00, 0x0A, // Outer$Inner, member of Outer, named Inner, private static
+		0x00, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // as if it were anonymous
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error parsing InnerClasses attribute: %v", err)
+	}
+	expected := []InnerClass{
+		{InnerClass: "com/example/Outer$Inner", OuterClass: "com/example/Outer", Name: "Inner", AccessFlags: 0x0A},
+		{InnerClass: "com/example/Outer$Inner"},
+	}
+	if !reflect.DeepEqual(klass.innerClasses, expected) {
+		t.Errorf("InnerClasses: Expected %v, got: %v", expected, klass.innerClasses)
+	}
+
+	err = parseTestClassAttribute(klass, "EnclosingMethod", []byte{0x00, 0x02, 0x00, 0x08})
+	if err != nil {
+		t.Fatalf("Unexpected error parsing EnclosingMethod attribute: %v", err)
+	}
+	if *klass.enclosingMethod != (EnclosingMethod{"com/example/Outer", "run", "()V"}) {
+		t.Errorf("EnclosingMethod: Expected com/example/Outer.run()V, got: %v", *klass.enclosingMethod)
+	}
+
+	// the class must be a ClassRef and the method a NameAndType entry
+	if parseTestClassAttribute(klass, "EnclosingMethod", []byte{0x00, 0x01, 0x00, 0x00}) == nil {
+		t.Errorf("EnclosingMethod: Expected an error for a class that's not a ClassRef")
+	}
+	if parseTestClassAttribute(klass, "EnclosingMethod", []byte{0x00, 0x02, 0x00, 0x06}) == nil {
+		t.Errorf("EnclosingMethod: Expected an error for a method that's not a NameAndType")
+	}
+}
+
+func TestNestAndPermittedSubclassesAttributes(t *testing.T) {
+	defer silenceAttributeTest()()
+	klass := newAttributeTestClass()
+
+	if err := parseTestClassAttribute(klass, "NestHost", []byte{0x00, 0x02}); err != nil {
+		t.Fatalf("Unexpected error parsing NestHost attribute: %v", err)
+	}
+	if klass.nestHost != "com/example/Outer" {
+		t.Errorf("NestHost: Expected com/example/Outer, got: %s", klass.nestHost)
+	}
+
+	members := []byte{0x00, 0x02, 0x00, 0x02, 0x00, 0x04}
+	if err := parseTestClassAttribute(klass, "NestMembers", members); err != nil {
+		t.Fatalf("Unexpected error parsing NestMembers attribute: %v", err)
+	}
+	if err := parseTestClassAttribute(klass, "PermittedSubclasses", members); err != nil {
+		t.Fatalf("Unexpected error parsing PermittedSubclasses attribute: %v", err)
+	}
+	expected := []string{"com/example/Outer", "com/example/Outer$Inner"}
+	if !reflect.DeepEqual(klass.nestMembers, expected) {
+		t.Errorf("NestMembers: Expected %v, got: %v", expected, klass.nestMembers)
+	}
+	if !reflect.DeepEqual(klass.permittedSubclasses, expected) {
+		t.Errorf("PermittedSubclasses: Expected %v, got: %v", expected, klass.permittedSubclasses)
+	}
+
+	// a count that runs past the end of the attribute
+	if parseTestClassAttribute(klass, "NestMembers", []byte{0x00, 0x03, 0x00, 0x02}) == nil {
+		t.Errorf("NestMembers: Expected an error for a truncated attribute")
+	}
+}
+
+func TestSignatureAndRecordAttributes(t *testing.T) {
+	defer silenceAttributeTest()()
+	klass := newAttributeTestClass()
+
+	if err := parseTestClassAttribute(klass, "Signature", []byte{0x00, 0x09}); err != nil {
+		t.Fatalf("Unexpected error parsing Signature attribute: %v", err)
+	}
+	if klass.signature != "<T:Ljava/lang/Object;>Ljava/lang/Object;" {
+		t.Errorf("Signature: Expected <T:Ljava/lang/Object;>Ljava/lang/Object;, got: %s", klass.signature)
+	}
+
+	err := parseTestClassAttribute(klass, "Record", []byte{
+		0x00, 0x02, // two components
+		0x00, 0x0A, 0x00, 0x0B, 0x00, 0x00, // int x
+		0x00, 0x0A, 0x00, 0x0B, 0x00, 0x01, // int x, with a Signature
+		0x00, 0x13, 0x00, 0x00, 0x00, 0x02, 0x00, 0x09,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error parsing Record attribute: %v", err)
+	}
+	if len(klass.recordComponents) != 2 {
+		t.Fatalf("Record: Expected 2 components, got: %d", len(klass.recordComponents))
+	}
+	first, second := klass.recordComponents[0], klass.recordComponents[1]
+	if first.Name != "x" || first.Desc != "I" || first.Signature != "" || len(first.Attributes) != 0 {
+		t.Errorf("Record: Expected the component int x without attributes, got: %v", first)
+	}
+	if second.Signature != "<T:Ljava/lang/Object;>Ljava/lang/Object;" || len(second.Attributes) != 1 {
+		t.Errorf("Record: Expected the component to have a Signature attribute, got: %v", second)
+	}
+
+	// a record without components is still a record
+	klass = newAttributeTestClass()
+	if err := parseTestClassAttribute(klass, "Record", []byte{0x00, 0x00}); err != nil {
+		t.Fatalf("Unexpected error parsing Record attribute: %v", err)
+	}
+	if klass.recordComponents == nil {
+		t.Errorf("Record: Expected an empty, non-nil slice of components")
+	}
+}
+
+func TestModuleAttribute(t *testing.T) {
+	defer silenceAttributeTest()()
+	klass := newAttributeTestClass()
+
+	err := parseTestClassAttribute(klass, "Module", []byte{
+		0x00, 0x0D, 0x00, 0x20, 0x00, 0x00, // module com.example, open, no version
+		0x00, 0x01, 0x00, 0x11, 0x80, 0x00, 0x00, 0x12, // requires java.base (mandated), version 17
+		0x00, 0x01, 0x00, 0x0F, 0x00, 0x00, 0x00, 0x00, // exports com/example/api
+		0x00, 0x01, 0x00, 0x0F, 0x00, 0x00, 0x00, 0x01, 0x00, 0x11, // opens com/example/api to java.base
+		0x00, 0x01, 0x00, 0x02, // uses Outer
+		0x00, 0x01, 0x00, 0x02, 0x00, 0x01, 0x00, 0x04, // provides Outer with Outer$Inner
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error parsing Module attribute: %v", err)
+	}
+
+	expected := ModuleInfo{
+		Name:     "com.example",
+		Flags:    0x20,
+		Requires: []ModuleRequires{{Module: "java.base", Flags: 0x8000, Version: "17"}},
+		Exports:  []ModulePackage{{Package: "com/example/api"}},
+		Opens:    []ModulePackage{{Package: "com/example/api", To: []string{"java.base"}}},
+		Uses:     []string{"com/example/Outer"},
+		Provides: []ModuleProvides{{Service: "com/example/Outer", With: []string{"com/example/Outer$Inner"}}},
+	}
+	if !reflect.DeepEqual(*klass.moduleInfo, expected) {
+		t.Errorf("Module: Expected %+v, got: %+v", expected, *klass.moduleInfo)
+	}
+	if klass.moduleName != "com.example" {
+		t.Errorf("Module: Expected the module name com.example, got: %s", klass.moduleName)
+	}
+
+	// the module name must be a Module entry
+	if parseTestClassAttribute(klass, "Module", []byte{0x00, 0x0F, 0x00, 0x00, 0x00, 0x00}) == nil {
+		t.Errorf("Module: Expected an error for a module name that's a Package entry")
+	}
+}
+
+// the parsed attributes are carried over to the class posted to the method area,
+// together with the signatures of fields and methods
+func TestClassAttributesArePosted(t *testing.T) {
+	klass := newAttributeTestClass()
+	klass.nestHost = "com/example/Outer"
+	klass.signature = "<T:Ljava/lang/Object;>Ljava/lang/Object;"
+	klass.recordComponents = []RecordComponent{{Name: "x", Desc: "I"}}
+	klass.fields = []field{{accessFlags: 0x12, name: 6, description: 7,
+		signature: "TT;", deprecated: true}}
+	klass.methods = []method{{name: 3, description: 4, signature: "()TT;"}}
+
+	kd := convertToPostableClass(klass)
+	if kd.NestHost != "com/example/Outer" || kd.Signature != klass.signature ||
+		!reflect.DeepEqual(kd.RecordComponents, klass.recordComponents) {
+		t.Errorf("Expected the class attributes to be posted, got: %s, %s, %v",
+			kd.NestHost, kd.Signature, kd.RecordComponents)
+	}
+	if f := kd.Fields[0]; f.AccessFlags != 0x12 || f.Signature != "TT;" || !f.Deprecated {
+		t.Errorf("Expected the field's access flags, signature and deprecation, got: %+v", f)
+	}
+	if m := kd.Methods[0]; m.Signature != "()TT;" {
+		t.Errorf("Expected the method signature ()TT;, got: %s", m.Signature)
+	}
+}
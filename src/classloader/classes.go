@@ -11,6 +11,8 @@ import (
 	"fmt"
 	"jacobin/log"
 	"jacobin/shutdown"
+	"strings"
+	"sync/atomic"
 )
 
 // the definition of the class as it's stored in the method area
@@ -34,7 +36,18 @@ type ClData struct {
 	Bootstraps  []BootstrapMethod
 	CP          CPool
 	Access      AccessFlags
-	ClInit      byte // 0 = no clinit, 1 = clinit not run, 2 clinit run
+	ClInit      byte            // initialization state: 0 = no clinit, 1 = clinit not run, 2 = in progress, 3 = initialized, 4 = erroneous
+	FieldLayout []InstanceField // the instance fields of its objects, nil until computed (see fieldLayout.go)
+
+	// ---- from the class attributes (see classAttributes.go) ----
+	InnerClasses        []InnerClass
+	EnclosingMethod     *EnclosingMethod // nil unless the class is a local or anonymous class
+	NestHost            string           // "" unless the class is a member of another class's nest
+	NestMembers         []string
+	PermittedSubclasses []string          // the permitted subclasses of a sealed class
+	RecordComponents    []RecordComponent // nil unless the class is a record
+	Signature           string            // the generic signature, if any
+	ModuleInfo          *ModuleInfo       // nil unless the class is a module-info class
 }
 
 type CPool struct {
@@ -79,6 +92,9 @@ type Field struct {
 	Desc        uint16 // index of the UTF-8 entry in the CP
 	IsStatic    bool   // is the field static?
 	Attributes  []Attr
+	ConstValue  interface{} // from the ConstantValue attribute: int, int64, float32, float64, string, or nil
+	Signature   string      // the generic signature, if any
+	Deprecated  bool        // is the field deprecated?
 }
 
 // the methods of the class, including the constructors
@@ -90,15 +106,17 @@ type Method struct {
 	Attributes  []Attr
 	Exceptions  []uint16 // indexes into Utf8Refs in the CP
 	Parameters  []ParamAttrib
-	Deprecated  bool // is the method deprecated?
+	Deprecated  bool   // is the method deprecated?
+	Signature   string // the generic signature, if any
 }
 
 type CodeAttrib struct {
-	MaxStack   int
-	MaxLocals  int
-	Code       []byte
-	Exceptions []CodeException // exception entries for this method
-	Attributes []Attr          // the code attributes has its own sub-attributes(!)
+	MaxStack    int
+	MaxLocals   int
+	Code        []byte
+	Exceptions  []CodeException // exception entries for this method
+	LineNumbers []LineNumber    // the method's LineNumberTable, in the order found in the class
+	Attributes  []Attr          // the code attributes has its own sub-attributes(!)
 }
 
 // ParamAttrib is the MethodParameters method attribute
@@ -107,6 +125,66 @@ type ParamAttrib struct {
 	AccessFlags int
 }
 
+// InnerClass is an entry in the InnerClasses class attribute. OuterClass is "" for a
+// local or anonymous class and Name is "" for an anonymous class.
+type InnerClass struct {
+	InnerClass  string
+	OuterClass  string
+	Name        string // the simple name of the class in the source code
+	AccessFlags int    // the access flags as declared in the source code
+}
+
+// EnclosingMethod is the EnclosingMethod attribute of a local or anonymous class. The
+// method name and type are "" if the class isn't declared in a method or constructor,
+// e.g., if it's declared in an initializer.
+type EnclosingMethod struct {
+	Class      string
+	MethodName string
+	MethodType string
+}
+
+// RecordComponent is a component of a record class, as listed in the Record attribute
+type RecordComponent struct {
+	Name       string
+	Desc       string
+	Signature  string // the generic signature, if any
+	Attributes []Attr
+}
+
+// ModuleInfo is the Module attribute of a module-info class. The names of modules are
+// given in their dotted form, those of packages and classes in their internal form.
+type ModuleInfo struct {
+	Name     string
+	Flags    int
+	Version  string // "" if no version was recorded
+	Requires []ModuleRequires
+	Exports  []ModulePackage
+	Opens    []ModulePackage
+	Uses     []string // the service interfaces the module uses
+	Provides []ModuleProvides
+}
+
+// ModuleRequires is a module that's required by a module
+type ModuleRequires struct {
+	Module  string
+	Flags   int
+	Version string // the version that was compiled against, or ""
+}
+
+// ModulePackage is a package that's exported or opened by a module, either to all
+// modules, if To is empty, or only to those that are listed
+type ModulePackage struct {
+	Package string
+	Flags   int
+	To      []string
+}
+
+// ModuleProvides is a service interface and the classes that a module provides it with
+type ModuleProvides struct {
+	Service string
+	With    []string
+}
+
 // the structure of many attributes (field, class, etc.) The content is just the raw bytes.
 type Attr struct {
 	AttrName    uint16 // index of the UTF8 entry in the CP
@@ -122,6 +200,26 @@ type CodeException struct {
 	CatchType uint16 // the type of exception, index to CP, which must point a ClassFref entry
 }
 
+// LineNumber is an entry in the LineNumberTable of a method: the bytecodes from
+// StartPc up to the StartPc of the next entry are from the given line of the source
+type LineNumber struct {
+	StartPc int
+	Line    int
+}
+
+// SourceLineOf returns the source line of the bytecode at pc, given the method's
+// LineNumberTable, or -1 if the table doesn't cover it. The entries can be in any
+// order and can overlap, so the one with the closest StartPc at or before pc is used.
+func SourceLineOf(lineNumbers []LineNumber, pc int) int {
+	line, bestStart := -1, -1
+	for _, ln := range lineNumbers {
+		if ln.StartPc <= pc && ln.StartPc > bestStart {
+			line, bestStart = ln.Line, ln.StartPc
+		}
+	}
+	return line
+}
+
 // the bootstrap methods, specified in the bootstrap class attribute
 type BootstrapMethod struct {
 	MethodRef uint16   // index pointing to a MethodHandle
@@ -135,8 +233,10 @@ type CpEntry struct {
 }
 
 type FieldRefEntry struct { // type: 09 (field reference)
-	ClassIndex  uint16
-	NameAndType uint16
+	ClassIndex    uint16
+	NameAndType   uint16
+	resolvedSlot  int32                  // the slot of the resolved instance field + 1, or 0 if not resolved
+	resolvedClass atomic.Pointer[string] // the class that declares the resolved static field, or nil
 }
 
 type MethodRefEntry struct { // type: 10 (method reference)
@@ -203,12 +303,13 @@ type InvokeDynamicEntry struct { // type 18 (invokedynamic data)
 // first class, will never be in one of the superclasses.
 func FetchMethodAndCP(className, methName, methType string) (MTentry, error) {
 	origClassName := className
-	// for {
-	// startSearch:
-
 	// has the className been loaded? If not, then load it now.
 	if MethAreaFetch(className) == nil {
 		err := LoadClassFromNameOnly(className)
+		var errBlk *GErrBlk
+		if errors.As(err, &errBlk) && methName != "main" { // e.g., a VerifyError, thrown by the caller
+			return MTentry{}, errBlk
+		}
 		if err != nil {
 			if methName == "main" {
 				// the starting className is always loaded, so if main() isn't found
@@ -223,7 +324,7 @@ func FetchMethodAndCP(className, methName, methType string) (MTentry, error) {
 	}
 
 	methFQN := className + "." + methName + methType // FQN = fully qualified name
-	methEntry := MTable[methFQN]
+	methEntry := MTableFetch(methFQN)
 
 	if methEntry.Meth != nil { // we found the entry in the MTable
 		if methEntry.MType == 'J' {
@@ -251,7 +352,6 @@ func FetchMethodAndCP(className, methName, methType string) (MTentry, error) {
 	}
 
 	if k.Loader == "" { // if className is not found, the zero value struct is returned
-		// TODO: check superclasses if method not found
 		errMsg := "FetchMethodAndCP: Null Loader in className: " + className
 		_ = log.Log(errMsg, log.SEVERE)
 		return MTentry{}, errors.New(errMsg) // dummy return needed for tests
@@ -274,16 +374,18 @@ func FetchMethodAndCP(className, methName, methType string) (MTentry, error) {
 			MaxStack:    m.CodeAttr.MaxStack,
 			MaxLocals:   m.CodeAttr.MaxLocals,
 			Code:        m.CodeAttr.Code,
-			exceptions:  m.CodeAttr.Exceptions,
+			Exceptions:  m.CodeAttr.Exceptions,
+			LineNumbers: m.CodeAttr.LineNumbers,
 			attribs:     m.CodeAttr.Attributes,
 			params:      m.Parameters,
 			deprecated:  m.Deprecated,
 			Cp:          &k.Data.CP,
+			ClName:      className,
 		}
-		MTable[methFQN] = MTentry{
+		MTableInsert(methFQN, MTentry{
 			Meth:  jme,
 			MType: 'J',
-		}
+		})
 		return MTentry{Meth: jme, MType: 'J'}, nil
 	}
 
@@ -291,17 +393,23 @@ func FetchMethodAndCP(className, methName, methType string) (MTentry, error) {
 	// except if we're searching for main(), in which case, we don't go up the list of superclasses
 	if methName == "main" { // to be consistent with the JDK, we print this peculiar error message when main() is missing
 		noMainError(origClassName)
-		// break
+	} else if superclass := k.Data.Superclass; className != "java/lang/Object" && superclass != "" &&
+		!strings.HasPrefix(strings.Trim(methName, "\""), "<") {
+		// constructors and initializers (<init> and <clinit>) are not inherited. An inherited
+		// method is cached under this class, so the search is done only once. Private methods
+		// aren't inherited, so they're not cached here, where INVOKEVIRTUAL would select them
+		// for objects of this class.
+		if _, err := fetchLoadedClass(superclass); err == nil {
+			superEntry, err := FetchMethodAndCP(superclass, methName, methType)
+			if err == nil && superEntry.Meth != nil {
+				if superEntry.MType != 'J' || superEntry.Meth.(JmEntry).AccessFlags&AccPrivate == 0 {
+					MTableInsert(methFQN, superEntry)
+				}
+				return superEntry, nil
+			}
+		}
 	}
 
-	// if className == "java/lang/Object" { // if we're already at the topmost superclass, then stop the loop
-	// 	break
-	// } else {
-	// 	className = k.Data.Superclass
-	// 	goto startSearch
-	// }
-	// }
-
 	// if we got this far, something went wrong with locating the method
 	msg := "FetchMethodAndCP: Found class " + className + ", but it did not contain method: " + methName
 	return MTentry{}, errors.New(msg)
@@ -67,6 +67,16 @@ type ParsedClass struct {
 
 	deprecated bool
 
+	// ---- the class attributes parsed in classAttributes.go ----
+	innerClasses        []InnerClass
+	enclosingMethod     *EnclosingMethod
+	nestHost            string
+	nestMembers         []string
+	permittedSubclasses []string
+	recordComponents    []RecordComponent
+	signature           string
+	moduleInfo          *ModuleInfo
+
 	// ---- constant pool data items ----
 	cpCount        int       // count of constant pool entries
 	cpIndex        []cpEntry // the constant pool index to entries
@@ -109,6 +119,8 @@ type field struct {
 	description int         // index of the UTF-8 entry in the CP
 	constValue  interface{} // the constant value if any was defined
 	attributes  []attr
+	signature   string // the generic signature, if any
+	deprecated  bool   // is the field deprecated?
 }
 
 // the methods of the class, including the constructors
@@ -120,15 +132,17 @@ type method struct {
 	attributes  []attr
 	exceptions  []int // indexes into Utf8Refs in the CP
 	parameters  []paramAttrib
-	deprecated  bool // is the method deprecated?
+	deprecated  bool   // is the method deprecated?
+	signature   string // the generic signature, if any
 }
 
 type codeAttrib struct {
-	maxStack   int
-	maxLocals  int
-	code       []byte
-	exceptions []exception // exception entries for this method
-	attributes []attr      // the code attributes has its own sub-attributes(!)
+	maxStack    int
+	maxLocals   int
+	code        []byte
+	exceptions  []exception  // exception entries for this method
+	lineNumbers []lineNumber // the entries of the LineNumberTable attribute(s), if any
+	attributes  []attr       // the code attributes has its own sub-attributes(!)
 }
 
 // the MethodParameters method attribute
@@ -152,6 +166,12 @@ type exception struct {
 	catchType int // the type of exception, index to CP, which must point a ClassFref entry
 }
 
+// an entry in the LineNumberTable attribute of a method's Code attribute
+type lineNumber struct {
+	startPc int // the first bytecode of the source line
+	line    int // the line number in the source file
+}
+
 // the bootstrap methods, specified in the bootstrap class attribute
 type bootstrapMethod struct {
 	methodRef int   // index pointing to a MethodHandle
@@ -429,8 +449,20 @@ func ParseAndPostClass(cl *Classloader, filename string, rawBytes []byte) (strin
 	_ = log.Log("Class "+fullyParsedClass.className+" has been format-checked.", log.FINEST)
 
 	classToPost := convertToPostableClass(&fullyParsedClass)
+	status := byte('F') // F = format-checked
+
+	// verify the class's bytecode, if it must be verified. A class that fails is not
+	// posted, so the VerifyError is thrown when the class is first used.
+	if verificationRequired(cl, fullyParsedClass.className) {
+		if err = verifyClass(&classToPost, fullyParsedClass.javaVersion); err != nil {
+			_ = log.Log("ParseAndPostClass: error verifying "+filename, log.SEVERE)
+			return "", err
+		}
+		status = 'V' // V = verified
+	}
+
 	eKF := Klass{
-		Status: 'F', // F = format-checked
+		Status: status,
 		Loader: cl.Name,
 		Data:   &classToPost,
 	}
@@ -463,7 +495,11 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 			kdf := Field{}
 			kdf.Name = uint16(fullyParsedClass.fields[i].name)
 			kdf.Desc = uint16(fullyParsedClass.fields[i].description)
+			kdf.AccessFlags = fullyParsedClass.fields[i].accessFlags
 			kdf.IsStatic = fullyParsedClass.fields[i].isStatic
+			kdf.ConstValue = fullyParsedClass.fields[i].constValue
+			kdf.Signature = fullyParsedClass.fields[i].signature
+			kdf.Deprecated = fullyParsedClass.fields[i].deprecated
 			if len(fullyParsedClass.fields[i].attributes) > 0 {
 				for j := 0; j < len(fullyParsedClass.fields[i].attributes); j++ {
 					kdfa := Attr{}
@@ -499,6 +535,10 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 					kdm.CodeAttr.Exceptions = append(kdm.CodeAttr.Exceptions, kdmce)
 				}
 			}
+			for _, ln := range fullyParsedClass.methods[i].codeAttr.lineNumbers {
+				kdm.CodeAttr.LineNumbers = append(kdm.CodeAttr.LineNumbers,
+					LineNumber{StartPc: ln.startPc, Line: ln.line})
+			}
 			if len(fullyParsedClass.methods[i].codeAttr.attributes) > 0 {
 				for m := 0; m < len(fullyParsedClass.methods[i].codeAttr.attributes); m++ {
 					kdmca := Attr{}
@@ -533,6 +573,7 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 				}
 			}
 			kdm.Deprecated = fullyParsedClass.methods[i].deprecated
+			kdm.Signature = fullyParsedClass.methods[i].signature
 			kd.Methods = append(kd.Methods, kdm)
 
 			methodTableKey := methName + methDesc
@@ -558,6 +599,14 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 		}
 	}
 	kd.SourceFile = fullyParsedClass.sourceFile
+	kd.InnerClasses = fullyParsedClass.innerClasses
+	kd.EnclosingMethod = fullyParsedClass.enclosingMethod
+	kd.NestHost = fullyParsedClass.nestHost
+	kd.NestMembers = fullyParsedClass.nestMembers
+	kd.PermittedSubclasses = fullyParsedClass.permittedSubclasses
+	kd.RecordComponents = fullyParsedClass.recordComponents
+	kd.Signature = fullyParsedClass.signature
+	kd.ModuleInfo = fullyParsedClass.moduleInfo
 	if len(fullyParsedClass.bootstraps) > 0 {
 		for j := 0; j < len(fullyParsedClass.bootstraps); j++ {
 			kdbs := BootstrapMethod{
@@ -628,11 +677,10 @@ func convertToPostableClass(fullyParsedClass *ParsedClass) ClData {
 
 	if len(fullyParsedClass.fieldRefs) > 0 {
 		for i := 0; i < len(fullyParsedClass.fieldRefs); i++ {
-			fr := FieldRefEntry{
+			kd.CP.FieldRefs = append(kd.CP.FieldRefs, FieldRefEntry{
 				ClassIndex:  uint16(fullyParsedClass.fieldRefs[i].classIndex),
 				NameAndType: uint16(fullyParsedClass.fieldRefs[i].nameAndTypeIndex),
-			}
-			kd.CP.FieldRefs = append(kd.CP.FieldRefs, fr)
+			})
 		}
 	}
 
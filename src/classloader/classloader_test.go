@@ -304,7 +304,7 @@ func TestInsertionIntoMethodArea(t *testing.T) {
 		t.Error("Got unexpected logging message for insertion of Klass into method area: " + msg)
 	}
 
-	if MethAreaSize() != 6 { // the 1 from here + 5 preloaded synthetic array classes
+	if MethAreaSize() != 11 { // the 1 from here + 10 preloaded synthetic array classes
 		t.Errorf("Expecting method area to have a size of 1, got: %d",
 			MethAreaSize())
 	}
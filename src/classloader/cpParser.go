@@ -220,11 +220,11 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 			if err != nil {
 				break // error message will already have been shown
 			}
-			if klass.moduleName != "" {
-				return pos + 2, cfe("Class " + klass.className + " has two module names: " + klass.moduleName +
-					" and " + moduleName)
+			// a module-info class also refers to the modules it requires, etc. Its own
+			// name is the one in its Module attribute (see classAttributes.go).
+			if klass.moduleName == "" {
+				klass.moduleName = moduleName
 			}
-			klass.moduleName = moduleName
 			klass.cpIndex[i] = cpEntry{Module, nameIndex}
 			pos += 2
 			i += 1
@@ -237,11 +237,10 @@ func parseConstantPool(rawBytes []byte, klass *ParsedClass) (int, error) {
 			if err != nil {
 				break // error message will already have been shown
 			}
-			if klass.packageName != "" {
-				return pos + 2, cfe("Class " + klass.className + " has two package names: " + klass.packageName +
-					" and " + packageName)
+			// a module-info class refers to all the packages it exports or opens
+			if klass.packageName == "" {
+				klass.packageName = packageName
 			}
-			klass.packageName = packageName
 			klass.cpIndex[i] = cpEntry{Package, nameIndex}
 			pos += 2
 			i += 1
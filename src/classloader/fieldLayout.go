@@ -0,0 +1,232 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/object"
+	"jacobin/types"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// The layout of the instance fields of objects. An object's fields are kept in its Fields
+// slice, in the order given by the field layout of its class: the fields of its topmost
+// superclass come first, followed by those of each subclass down to the class itself, and
+// within a class, the fields are in the order they are declared in the class file. As a
+// result, a field has the same slot in the objects of a class and of all its subclasses,
+// and a field that shadows a superclass's field of the same name has a slot of its own.
+//
+// The layout of a class is computed when the class is linked, which in Jacobin is the first
+// time its objects are created or its fields are accessed. GETFIELD and PUTFIELD resolve
+// their field reference to a slot once and keep the slot in the CP entry.
+//
+// Objects whose Fields are laid out this way are created by LayOutFields(), which marks
+// them as LaidOut. Other objects, such as Jacobin's strings and the exceptions it creates
+// when the JDK is not available, are built by hand: their fields are looked up by name
+// in their FieldTable.
+
+// InstanceField is an entry in the field layout of a class
+type InstanceField struct {
+	Class string // the class that declares the field
+	Name  string
+	Desc  string // the field's type
+}
+
+// fieldLayoutMutex guards the FieldLayout of every class
+var fieldLayoutMutex sync.RWMutex
+
+// FieldLayout returns the layout of the instance fields of the named class's objects,
+// computing it the first time it's requested. The class and its superclasses are loaded
+// as needed.
+func FieldLayout(className string) ([]InstanceField, error) {
+	k, err := fetchLoadedClass(className)
+	if err != nil {
+		return nil, err
+	}
+
+	fieldLayoutMutex.RLock()
+	layout := k.Data.FieldLayout
+	fieldLayoutMutex.RUnlock()
+	if layout != nil {
+		return layout, nil
+	}
+
+	layout = make([]InstanceField, 0)
+	// java/lang/Object has no instance fields, so it need not be loaded
+	if k.Data.Superclass != "" && k.Data.Superclass != "java/lang/Object" && !k.Data.Access.ClassIsInterface {
+		superLayout, err := FieldLayout(k.Data.Superclass)
+		if err != nil {
+			return nil, err
+		}
+		layout = append(layout, superLayout...)
+	}
+	for _, fld := range k.Data.Fields {
+		if !fld.IsStatic {
+			layout = append(layout, InstanceField{Class: className,
+				Name: k.Data.CP.Utf8Refs[fld.Name], Desc: k.Data.CP.Utf8Refs[fld.Desc]})
+		}
+	}
+
+	// if another thread computed the layout in the meantime, it's the same as this one
+	fieldLayoutMutex.Lock()
+	if k.Data.FieldLayout == nil {
+		k.Data.FieldLayout = layout
+	}
+	layout = k.Data.FieldLayout
+	fieldLayoutMutex.Unlock()
+	return layout, nil
+}
+
+// ResolveInstanceField resolves a reference to the instance field fieldName of the named
+// class, as set out in JVMS 5.4.3.2: the field is looked up in the class and then in its
+// superclasses. (Fields declared in superinterfaces are static, so they are not looked
+// up.) It returns the field's slot in the layout of the class. If the field can't be
+// resolved, the error is a *GErrBlk naming the exception to throw.
+func ResolveInstanceField(className, fieldName string) (int, error) {
+	declaringClass := ""
+	for name := className; name != "" && name != "java/lang/Object" && declaringClass == ""; {
+		k, err := fetchLoadedClass(name)
+		if err != nil {
+			return -1, ClassLoadingError(name, err)
+		}
+		for _, fld := range k.Data.Fields {
+			if k.Data.CP.Utf8Refs[fld.Name] != fieldName {
+				continue
+			}
+			if fld.IsStatic {
+				return -1, &GErrBlk{ExceptionType: "java/lang/IncompatibleClassChangeError",
+					ErrMsg: "Expected non-static field " + javaName(className) + "." + fieldName}
+			}
+			declaringClass = name
+			break
+		}
+		name = k.Data.Superclass
+	}
+	if declaringClass == "" {
+		return -1, &GErrBlk{ExceptionType: "java/lang/NoSuchFieldError", ErrMsg: fieldName}
+	}
+
+	layout, err := FieldLayout(className)
+	if err != nil {
+		return -1, ClassLoadingError(className, err)
+	}
+	for slot := len(layout) - 1; slot >= 0; slot-- {
+		if layout[slot].Class == declaringClass && layout[slot].Name == fieldName {
+			return slot, nil
+		}
+	}
+	return -1, &GErrBlk{ExceptionType: "java/lang/NoSuchFieldError", ErrMsg: fieldName}
+}
+
+// FieldRefSlot returns the slot of the instance field that's referred to by the given
+// FieldRef entry of the CP, along with the field's name. The reference is resolved the
+// first time, and the slot is then kept in the entry.
+func FieldRefSlot(cp *CPool, fieldRefIndex uint16) (int, string, error) {
+	fieldRef := &cp.FieldRefs[fieldRefIndex]
+	nameAndType := cp.NameAndTypes[cp.CpIndex[fieldRef.NameAndType].Slot]
+	fieldName := FetchUTF8stringFromCPEntryNumber(cp, nameAndType.NameIndex)
+
+	if slot := atomic.LoadInt32(&fieldRef.resolvedSlot); slot > 0 {
+		return int(slot) - 1, fieldName, nil
+	}
+
+	classNameIndex := cp.ClassRefs[cp.CpIndex[fieldRef.ClassIndex].Slot]
+	className := FetchUTF8stringFromCPEntryNumber(cp, classNameIndex)
+	slot, err := ResolveInstanceField(className, fieldName)
+	if err != nil {
+		return -1, fieldName, err
+	}
+	atomic.StoreInt32(&fieldRef.resolvedSlot, int32(slot)+1)
+	return slot, fieldName, nil
+}
+
+// LayOutFields gives the object the fields of a new object of the named class, as
+// returned by NewObjectFields(), and marks the object as laid out by its class, so that
+// its fields are accessed by their slot
+func LayOutFields(obj *object.Object, className string) error {
+	fields, err := NewObjectFields(className)
+	if err != nil {
+		return err
+	}
+	obj.Fields = fields
+	obj.LaidOut = true
+	return nil
+}
+
+// NewObjectFields returns the fields of a new object of the named class, in the order of
+// its field layout. Each field has its type's default value.
+func NewObjectFields(className string) ([]object.Field, error) {
+	layout, err := FieldLayout(className)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]object.Field, len(layout))
+	for i, fld := range layout {
+		fields[i] = object.Field{Ftype: fld.Desc, Fvalue: types.DefaultValue(fld.Desc)}
+	}
+	return fields, nil
+}
+
+// GetObjectField returns the instance field of the object that has the given name. If
+// a superclass declares a field of the same name, the field of the subclass is returned.
+// Objects that Jacobin creates without their class, such as the exceptions thrown when
+// the JDK is not available, keep their fields in the object's FieldTable instead.
+func GetObjectField(obj *object.Object, name string) (object.Field, bool) {
+	if slot := objectFieldSlot(obj, name); slot >= 0 {
+		return obj.Fields[slot], true
+	}
+	fld, ok := obj.FieldTable[name]
+	return fld, ok
+}
+
+// SetObjectField sets the value of the instance field of the object that has the given
+// name. If the object's class has no such field, the field is kept in its FieldTable.
+func SetObjectField(obj *object.Object, name string, fld object.Field) {
+	if slot := objectFieldSlot(obj, name); slot >= 0 {
+		obj.Fields[slot].Fvalue = fld.Fvalue
+		return
+	}
+	if obj.FieldTable == nil {
+		obj.FieldTable = make(map[string]object.Field)
+	}
+	obj.FieldTable[name] = fld
+}
+
+// objectLayout returns the field layout of the object's class, if the object's Fields
+// are laid out by it
+func objectLayout(obj *object.Object) ([]InstanceField, bool) {
+	if !obj.LaidOut {
+		return nil, false
+	}
+	layout, err := FieldLayout(*obj.Klass)
+	if err != nil {
+		return nil, false
+	}
+	return layout, true
+}
+
+// objectFieldSlot returns the slot of the named field in the object's Fields, or -1 if the
+// object's fields are not laid out by its class or the class has no field of that name
+func objectFieldSlot(obj *object.Object, name string) int {
+	layout, ok := objectLayout(obj)
+	if !ok {
+		return -1
+	}
+	for slot := len(layout) - 1; slot >= 0; slot-- {
+		if layout[slot].Name == name {
+			return slot
+		}
+	}
+	return -1
+}
+
+// javaName returns the class name with dots, as it's shown in Java
+func javaName(className string) string {
+	return strings.ReplaceAll(className, "/", ".")
+}
@@ -0,0 +1,155 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/object"
+	"strings"
+	"testing"
+)
+
+// inserts a class with the given fields into the method area. Each field is given as
+// its name and type. A name that starts with "static " declares a static field.
+func insertLayoutTestClass(name, superclass string, fields ...string) {
+	data := ClData{Name: name, Superclass: superclass}
+	for i := 0; i < len(fields); i += 2 {
+		fld := Field{Name: uint16(len(data.CP.Utf8Refs)), Desc: uint16(len(data.CP.Utf8Refs) + 1)}
+		fieldName, isStatic := strings.CutPrefix(fields[i], "static ")
+		fld.IsStatic = isStatic
+		data.CP.Utf8Refs = append(data.CP.Utf8Refs, fieldName, fields[i+1])
+		data.Fields = append(data.Fields, fld)
+	}
+	MethAreaInsert(name, &Klass{Status: 'X', Loader: "app", Data: &data})
+}
+
+// sets up the following classes, in which Sub's field x shadows Base's
+//
+//	class Base { int x; static int count; String name; }
+//	class Sub extends Base { long x; double d; }
+func setupLayoutTestClasses() {
+	globals.InitGlobals("test")
+	log.Init()
+	InitMethodArea()
+	insertLayoutTestClass("Base", "java/lang/Object", "x", "I", "static count", "I",
+		"name", "Ljava/lang/String;")
+	insertLayoutTestClass("Sub", "Base", "x", "J", "d", "D")
+}
+
+// the superclass's fields come first, in the order they're declared, and static fields
+// are left out
+func TestFieldLayoutWithSuperclass(t *testing.T) {
+	setupLayoutTestClasses()
+
+	layout, err := FieldLayout("Sub")
+	if err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+	expected := []InstanceField{
+		{"Base", "x", "I"}, {"Base", "name", "Ljava/lang/String;"}, {"Sub", "x", "J"}, {"Sub", "d", "D"},
+	}
+	if len(layout) != len(expected) {
+		t.Fatalf("Expected a layout of %d fields, got: %v", len(expected), layout)
+	}
+	for i := range expected {
+		if layout[i] != expected[i] {
+			t.Errorf("Expected field %d of the layout to be %v, got: %v", i, expected[i], layout[i])
+		}
+	}
+}
+
+// a field reference resolves to the field declared in the class or its nearest superclass
+func TestResolveInstanceFieldWithShadowing(t *testing.T) {
+	setupLayoutTestClasses()
+
+	tests := []struct {
+		className, fieldName string
+		slot                 int
+	}{
+		{"Base", "x", 0}, {"Base", "name", 1}, {"Sub", "name", 1}, {"Sub", "x", 2}, {"Sub", "d", 3},
+	}
+	for _, test := range tests {
+		slot, err := ResolveInstanceField(test.className, test.fieldName)
+		if err != nil {
+			t.Errorf("Got unexpected error resolving %s.%s: %s", test.className, test.fieldName, err.Error())
+		} else if slot != test.slot {
+			t.Errorf("Expected %s.%s to resolve to slot %d, got: %d", test.className, test.fieldName,
+				test.slot, slot)
+		}
+	}
+}
+
+// fields that don't exist or are static can't be resolved as instance fields
+func TestResolveInstanceFieldErrors(t *testing.T) {
+	setupLayoutTestClasses()
+
+	tests := []struct{ className, fieldName, exception string }{
+		{"Sub", "missing", "java/lang/NoSuchFieldError"},
+		{"Base", "d", "java/lang/NoSuchFieldError"},
+		{"Sub", "count", "java/lang/IncompatibleClassChangeError"},
+	}
+	for _, test := range tests {
+		_, err := ResolveInstanceField(test.className, test.fieldName)
+		var errBlk *GErrBlk
+		if !errors.As(err, &errBlk) || errBlk.ExceptionType != test.exception {
+			t.Errorf("Expected a %s resolving %s.%s, got: %v", test.exception, test.className,
+				test.fieldName, err)
+		}
+	}
+}
+
+// the slot of a resolved field reference is kept in the CP entry
+func TestFieldRefSlotIsKept(t *testing.T) {
+	setupLayoutTestClasses()
+
+	cp := CPool{Utf8Refs: []string{"Sub", "x", "J"}, ClassRefs: []uint16{1}}
+	cp.CpIndex = []CpEntry{{}, {Type: UTF8, Slot: 0}, {Type: ClassRef, Slot: 0}, {Type: UTF8, Slot: 1},
+		{Type: UTF8, Slot: 2}, {Type: NameAndType, Slot: 0}, {Type: FieldRef, Slot: 0}}
+	cp.NameAndTypes = []NameAndTypeEntry{{NameIndex: 3, DescIndex: 4}}
+	cp.FieldRefs = []FieldRefEntry{{ClassIndex: 2, NameAndType: 5}}
+
+	slot, name, err := FieldRefSlot(&cp, 0)
+	if err != nil || slot != 2 || name != "x" {
+		t.Fatalf("Expected Sub.x to resolve to slot 2, got slot %d, name %q, error: %v", slot, name, err)
+	}
+
+	// once resolved, the reference is not resolved again, even if the class is gone
+	InitMethodArea()
+	slot, _, err = FieldRefSlot(&cp, 0)
+	if err != nil || slot != 2 {
+		t.Errorf("Expected the kept slot 2, got slot %d, error: %v", slot, err)
+	}
+}
+
+// the fields of objects can be accessed by name, and objects that are not laid out by
+// their class keep their fields in their FieldTable
+func TestGetAndSetObjectField(t *testing.T) {
+	setupLayoutTestClasses()
+
+	className := "Sub"
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	if err := LayOutFields(obj, className); err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+
+	SetObjectField(obj, "x", object.Field{Ftype: "J", Fvalue: int64(5)})
+	if obj.Fields[2].Fvalue != int64(5) || obj.Fields[0].Fvalue != int64(0) {
+		t.Errorf("Expected Sub's field x to be set, not Base's, got fields: %v", obj.Fields)
+	}
+	if fld, ok := GetObjectField(obj, "x"); !ok || fld.Fvalue != int64(5) {
+		t.Errorf("Expected to get Sub's field x with the value 5, got: %v", fld.Fvalue)
+	}
+
+	other := object.MakeEmptyObject()
+	SetObjectField(other, "detailMessage", object.Field{Ftype: "Ljava/lang/String;", Fvalue: nil})
+	if _, ok := other.FieldTable["detailMessage"]; !ok {
+		t.Errorf("Expected the field to be kept in the FieldTable of an object with no class")
+	}
+}
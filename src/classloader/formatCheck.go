@@ -504,8 +504,8 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// placed into klass.moduleName. So, here we verify this module name rather
 			// than the CP entry that got it. We also check access permissions, as required
 			// in: https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.4.11
-			// Note: the test for minimum Java 9 version and the limit of at most one
-			// Module entry is enforced in the original CP parsing (see cpParser.go)
+			// Note: the test for minimum Java 9 version is enforced in the original CP
+			// parsing (see cpParser.go), which keeps the name of the first Module entry
 			if !klass.classIsModule {
 				return cfe("Module CP entry must appear only in class with ACC_MODULE set.")
 			}
@@ -517,8 +517,8 @@ func formatCheckConstantPool(klass *ParsedClass) error {
 			// placed into klass.packageName. So, here we verify this package name rather
 			// than the CP entry that got it. We also check access permissions, as required
 			// in: https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.4.12
-			// Note: the test for minimum Java 9 version and the limit of at most one
-			// Package entry is enforced in the original CP parsing (see cpParser.go)
+			// Note: the test for minimum Java 9 version is enforced in the original CP
+			// parsing (see cpParser.go), which keeps the name of the first Package entry
 			if !klass.classIsModule {
 				return cfe("Package CP entry must appear only in class with ACC_MODULE set.")
 			}
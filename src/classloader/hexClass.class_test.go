@@ -3084,7 +3084,7 @@ func TestParseAndPostFunctionWithClass_Class(t *testing.T) {
 	_ = log.SetLogLevel(log.WARNING)
 	_ = Init()
 	MethArea = &sync.Map{}
-	if MethAreaSize() != 5 { // for the 5 synthetic array classes that are preloaded
+	if MethAreaSize() != 10 { // for the 10 synthetic array classes that are preloaded
 		t.Errorf("Unexpected error in initializing MethArea (which is the method area)")
 	}
 
@@ -3101,7 +3101,7 @@ func TestParseAndPostFunctionWithClass_Class(t *testing.T) {
 		t.Errorf("Got unexpected error in ParseAndPost() of Class.class")
 	}
 
-	if MethAreaSize() != 6 { // the 1 from here + 5 preloaded synthetic array classes
+	if MethAreaSize() != 11 { // the 1 from here + 10 preloaded synthetic array classes
 		t.Errorf("Expected MethArea to have 1 entry, but it has %d",
 			MethAreaSize())
 	}
@@ -3121,7 +3121,7 @@ func TestLoadClassByNameOnly(t *testing.T) {
 	_ = log.SetLogLevel(log.WARNING)
 	_ = Init()
 
-	if MethAreaSize() != 5 { // 5 synthetic array entries are preloaded to the methArea
+	if MethAreaSize() != 10 { // 10 synthetic array entries are preloaded to the methArea
 		t.Errorf("Unexpected error in initializing MethArea (which is the method area)")
 	}
 
@@ -3138,7 +3138,7 @@ func TestLoadClassByNameOnly(t *testing.T) {
 		t.Errorf("Got unexpected error in ParseAndPost() of Class.class")
 	}
 
-	if MethAreaSize() != 6 {
+	if MethAreaSize() != 11 {
 		// 1 for this class + 5 for the preloaded array classes
 		t.Errorf("Expected MethArea to have 1 entry, but it has %d",
 			MethAreaSize())
@@ -32,8 +32,9 @@ import (
 var MethodSignatures = make(map[string]GMeth)
 
 type GMeth struct {
-	ParamSlots int
-	GFunction  function
+	ParamSlots  int
+	GFunction   function
+	NeedsThread bool // pass the ID of the calling thread to GFunction after the arguments
 }
 
 type function func([]interface{}) interface{}
@@ -0,0 +1,659 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"fmt"
+	"jacobin/object"
+	"jacobin/types"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+ The annotations of classes, methods, constructors, and fields, as returned by
+ getAnnotation(), getAnnotations(), and the like. Only the annotations whose retention
+ policy is RUNTIME are available, as they're the ones in the RuntimeVisibleAnnotations
+ and RuntimeVisibleParameterAnnotations attributes (see annotations.go).
+
+ As in the JDK, an annotation is an instance of a proxy class that implements the
+ annotation interface. Jacobin creates one proxy class per annotation interface, whose
+ methods are Go functions: each element method returns the value of the element, which
+ is kept in a field of the same name, and annotationType(), toString(), hashCode(), and
+ equals() behave as specified by java.lang.annotation.Annotation. An annotation whose
+ interface can't be loaded is left out, as the JDK does.
+*/
+
+func Load_Lang_Annotation() map[string]GMeth {
+
+	// the methods of java.lang.reflect.AnnotatedElement. A class's annotations include
+	// the @Inherited annotations of its superclasses; those of members are only their own.
+	for _, element := range []string{ClassClassName, reflectMethodClass, reflectFieldClass, reflectConstructorClass} {
+		MethodSignatures[element+".getAnnotation(Ljava/lang/Class;)Ljava/lang/annotation/Annotation;"] =
+			GMeth{
+				ParamSlots:  2, // the annotated element, the annotation interface
+				GFunction:   getAnnotation,
+				NeedsThread: true,
+			}
+
+		MethodSignatures[element+".getDeclaredAnnotation(Ljava/lang/Class;)Ljava/lang/annotation/Annotation;"] =
+			GMeth{
+				ParamSlots:  2,
+				GFunction:   getDeclaredAnnotation,
+				NeedsThread: true,
+			}
+
+		MethodSignatures[element+".getAnnotations()[Ljava/lang/annotation/Annotation;"] =
+			GMeth{
+				ParamSlots:  1, // the annotated element
+				GFunction:   getAnnotations,
+				NeedsThread: true,
+			}
+
+		MethodSignatures[element+".getDeclaredAnnotations()[Ljava/lang/annotation/Annotation;"] =
+			GMeth{
+				ParamSlots:  1,
+				GFunction:   getDeclaredAnnotations,
+				NeedsThread: true,
+			}
+
+		MethodSignatures[element+".isAnnotationPresent(Ljava/lang/Class;)Z"] =
+			GMeth{
+				ParamSlots: 2,
+				GFunction:  isAnnotationPresent,
+			}
+	}
+
+	for _, executable := range []string{reflectMethodClass, reflectConstructorClass} {
+		MethodSignatures[executable+".getParameterAnnotations()[[Ljava/lang/annotation/Annotation;"] =
+			GMeth{
+				ParamSlots:  1,
+				GFunction:   executableGetParameterAnnotations,
+				NeedsThread: true,
+			}
+	}
+
+	MethodSignatures["java/lang/reflect/Method.getDefaultValue()Ljava/lang/Object;"] =
+		GMeth{
+			ParamSlots:  1,
+			GFunction:   methodGetDefaultValue,
+			NeedsThread: true,
+		}
+
+	return MethodSignatures
+}
+
+const (
+	annotationArrayType = "[Ljava/lang/annotation/Annotation;"
+	inheritedAnnotation = "java/lang/annotation/Inherited"
+
+	// the field of an annotation proxy that holds the annotation, with the default values
+	// of the elements that it doesn't specify, for toString(), hashCode(), and equals()
+	annotationProxyField = "$annotation"
+)
+
+// the proxy classes, by the names of the annotation interfaces they implement
+var annotationProxyClasses = make(map[string]string)
+var annotationProxyMutex sync.Mutex
+
+// getAnnotation(Ljava/lang/Class;)Ljava/lang/annotation/Annotation; returns the annotation
+// of the element whose interface is the given class, or null if there's none
+func getAnnotation(params []interface{}) interface{} {
+	return findAnnotation(params, true)
+}
+
+// getDeclaredAnnotation(Ljava/lang/Class;)Ljava/lang/annotation/Annotation; is the same
+// as getAnnotation(), except that a class's inherited annotations are ignored
+func getDeclaredAnnotation(params []interface{}) interface{} {
+	return findAnnotation(params, false)
+}
+
+// getAnnotations()[Ljava/lang/annotation/Annotation; returns the annotations of the element
+func getAnnotations(params []interface{}) interface{} {
+	return annotationArray(params[0].(*object.Object), true, params[1].(int))
+}
+
+// getDeclaredAnnotations()[Ljava/lang/annotation/Annotation; returns the annotations of
+// the element, except for a class's inherited annotations
+func getDeclaredAnnotations(params []interface{}) interface{} {
+	return annotationArray(params[0].(*object.Object), false, params[1].(int))
+}
+
+// isAnnotationPresent(Ljava/lang/Class;)Z returns whether the element has an annotation
+// whose interface is the given class
+func isAnnotationPresent(params []interface{}) interface{} {
+	annotationClass, ok := params[1].(*object.Object)
+	if !ok || annotationClass == nil {
+		return &GErrBlk{ExceptionType: "java/lang/NullPointerException"}
+	}
+	annotations, err := elementAnnotations(params[0].(*object.Object), true)
+	if err != nil {
+		return annotationFormatErrBlk(err)
+	}
+	annotationType := MirrorClassName(annotationClass)
+	for _, annotation := range annotations {
+		if annotation.Type == annotationType {
+			return types.JavaBoolTrue
+		}
+	}
+	return types.JavaBoolFalse
+}
+
+// the element, the annotation interface, and the thread ID are in params
+func findAnnotation(params []interface{}, inherited bool) interface{} {
+	annotationClass, ok := params[1].(*object.Object)
+	if !ok || annotationClass == nil {
+		return &GErrBlk{ExceptionType: "java/lang/NullPointerException"}
+	}
+	annotations, err := elementAnnotations(params[0].(*object.Object), inherited)
+	if err != nil {
+		return annotationFormatErrBlk(err)
+	}
+
+	annotationType := MirrorClassName(annotationClass)
+	for _, annotation := range annotations {
+		if annotation.Type == annotationType {
+			proxy, errBlk := annotationProxy(annotation, params[2].(int))
+			if errBlk != nil {
+				return errBlk
+			}
+			return proxy
+		}
+	}
+	return object.Null
+}
+
+// annotationArray returns an Annotation[] that holds the proxies of the element's annotations
+func annotationArray(element *object.Object, inherited bool, threadID int) interface{} {
+	annotations, err := elementAnnotations(element, inherited)
+	if err != nil {
+		return annotationFormatErrBlk(err)
+	}
+	proxies, errBlk := annotationProxies(annotations, threadID)
+	if errBlk != nil {
+		return errBlk
+	}
+	return makeObjectArray(annotationArrayType, proxies)
+}
+
+// annotationProxies returns the proxies of the annotations, leaving out those whose
+// interfaces can't be loaded
+func annotationProxies(annotations []Annotation, threadID int) ([]*object.Object, *GErrBlk) {
+	var proxies []*object.Object
+	for _, annotation := range annotations {
+		proxy, errBlk := annotationProxy(annotation, threadID)
+		if errBlk != nil {
+			return nil, errBlk
+		}
+		if proxy != object.Null {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies, nil
+}
+
+// elementAnnotations returns the annotations of the class, method, constructor, or field
+// that the mirror or reflection object represents. If inherited is true, a class's
+// annotations include those of its superclasses whose interfaces are annotated with
+// @Inherited, unless the class has an annotation of the same interface itself.
+func elementAnnotations(element *object.Object, inherited bool) ([]Annotation, error) {
+	switch *element.Klass {
+	case ClassClassName:
+		return classAnnotations(MirrorClassName(element), inherited)
+	case reflectFieldClass:
+		_, k, slot := memberOf(element)
+		return ParseAnnotations(&k.CP, k.Fields[slot].Attributes)
+	default: // a method or a constructor
+		_, k, slot := memberOf(element)
+		return ParseAnnotations(&k.CP, k.Methods[slot].Attributes)
+	}
+}
+
+// classAnnotations returns the annotations of the named class and, if inherited is true,
+// the inherited annotations of its superclasses
+func classAnnotations(className string, inherited bool) ([]Annotation, error) {
+	k := declaringClassData(className)
+	if k == nil { // array classes and primitive types have no annotations
+		return nil, nil
+	}
+	annotations, err := ParseAnnotations(&k.CP, k.Attributes)
+	if err != nil || !inherited {
+		return annotations, err
+	}
+
+	for superclass := k.Superclass; superclass != ""; {
+		sk := declaringClassData(superclass)
+		if sk == nil {
+			break
+		}
+		superAnnotations, err := ParseAnnotations(&sk.CP, sk.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		for _, annotation := range superAnnotations {
+			if !hasAnnotation(annotations, annotation.Type) && isInheritedAnnotation(annotation.Type) {
+				annotations = append(annotations, annotation)
+			}
+		}
+		superclass = sk.Superclass
+	}
+	return annotations, nil
+}
+
+// hasAnnotation reports whether there's an annotation of the given interface in annotations
+func hasAnnotation(annotations []Annotation, annotationType string) bool {
+	for _, annotation := range annotations {
+		if annotation.Type == annotationType {
+			return true
+		}
+	}
+	return false
+}
+
+// isInheritedAnnotation reports whether the annotation interface is annotated with
+// @Inherited, so that its annotations of a class are inherited by the subclasses
+func isInheritedAnnotation(annotationType string) bool {
+	k := declaringClassData(annotationType)
+	if k == nil {
+		return false
+	}
+	metaAnnotations, _ := ParseAnnotations(&k.CP, k.Attributes)
+	return hasAnnotation(metaAnnotations, inheritedAnnotation)
+}
+
+// getParameterAnnotations()[[Ljava/lang/annotation/Annotation; returns an array that holds
+// the annotations of each of the parameters of a method or constructor. If the attribute
+// lists fewer parameters than the descriptor, the parameters that the compiler added at
+// the beginning, such as the outer instance of an inner class, have none.
+func executableGetParameterAnnotations(params []interface{}) interface{} {
+	_, k, slot := memberOf(params[0].(*object.Object))
+	meth := &k.Methods[slot]
+	paramTypes, _, _ := splitMethodDescriptor(k.CP.Utf8Refs[meth.Desc])
+	paramAnnotations, err := ParseParameterAnnotations(&k.CP, meth.Attributes)
+	if err != nil {
+		return annotationFormatErrBlk(err)
+	}
+
+	arrays := make([]*object.Object, len(paramTypes))
+	offset := len(paramTypes) - len(paramAnnotations)
+	for i := range arrays {
+		var proxies []*object.Object
+		if i >= offset && i-offset < len(paramAnnotations) {
+			var errBlk *GErrBlk
+			proxies, errBlk = annotationProxies(paramAnnotations[i-offset], params[1].(int))
+			if errBlk != nil {
+				return errBlk
+			}
+		}
+		arrays[i] = makeObjectArray(annotationArrayType, proxies)
+	}
+	return makeObjectArray("["+annotationArrayType, arrays)
+}
+
+// java/lang/reflect/Method.getDefaultValue()Ljava/lang/Object; returns the default value
+// of the element of an annotation interface that the method represents, boxed if it's a
+// primitive value, or null if it has none
+func methodGetDefaultValue(params []interface{}) interface{} {
+	_, k, slot := memberOf(params[0].(*object.Object))
+	meth := &k.Methods[slot]
+	defaultValue, err := ParseAnnotationDefault(&k.CP, meth.Attributes)
+	if err != nil {
+		return annotationFormatErrBlk(err)
+	}
+	if defaultValue == nil {
+		return object.Null
+	}
+
+	_, returnType, _ := splitMethodDescriptor(k.CP.Utf8Refs[meth.Desc])
+	value, errBlk := elementJavaValue(*defaultValue, returnType, params[1].(int))
+	if errBlk != nil {
+		return errBlk
+	}
+	return boxValue(value, returnType)
+}
+
+// annotationFormatErrBlk throws the AnnotationFormatError for an annotation that couldn't
+// be parsed
+func annotationFormatErrBlk(err error) *GErrBlk {
+	return &GErrBlk{ExceptionType: "java/lang/annotation/AnnotationFormatError", ErrMsg: err.Error()}
+}
+
+// annotationElementMethod is an element of an annotation interface, which is declared as
+// a method without parameters
+type annotationElementMethod struct {
+	name       string
+	returnType string
+	defaultVal *ElementValue // nil if the element has no default value
+}
+
+// annotationElementMethods returns the elements of the annotation interface, in the order
+// in which they're declared
+func annotationElementMethods(k *ClData) []annotationElementMethod {
+	var elements []annotationElementMethod
+	for _, meth := range k.Methods {
+		desc := k.CP.Utf8Refs[meth.Desc]
+		if meth.AccessFlags&AccStatic != 0 || !strings.HasPrefix(desc, "()") {
+			continue
+		}
+		defaultVal, _ := ParseAnnotationDefault(&k.CP, meth.Attributes) // an error's been logged
+		elements = append(elements, annotationElementMethod{
+			name:       k.CP.Utf8Refs[meth.Name],
+			returnType: desc[2:],
+			defaultVal: defaultVal,
+		})
+	}
+	return elements
+}
+
+// completeAnnotation returns the annotation with the values of all its elements, in the
+// order in which they're declared by the interface, which is given: those it specifies
+// and the default values of the others. An annotation nested in it is completed as well.
+// An element that has neither a value nor a default value is left out.
+func completeAnnotation(annotation Annotation, elements []annotationElementMethod) Annotation {
+	complete := Annotation{Type: annotation.Type}
+	for _, element := range elements {
+		var value *ElementValue
+		for i := range annotation.Elements {
+			if annotation.Elements[i].Name == element.name {
+				value = &annotation.Elements[i].Value
+			}
+		}
+		if value == nil {
+			value = element.defaultVal
+		}
+		if value != nil {
+			complete.Elements = append(complete.Elements,
+				AnnotationElement{Name: element.name, Value: completeElementValue(*value)})
+		}
+	}
+	return complete
+}
+
+// completeElementValue completes the annotations in an element value, if there are any
+func completeElementValue(value ElementValue) ElementValue {
+	switch value.Tag {
+	case '@':
+		nested := value.Value.(Annotation)
+		if k := declaringClassData(nested.Type); k != nil {
+			value.Value = completeAnnotation(nested, annotationElementMethods(k))
+		}
+	case '[':
+		values := value.Value.([]ElementValue)
+		completed := make([]ElementValue, len(values))
+		for i, v := range values {
+			completed[i] = completeElementValue(v)
+		}
+		value.Value = completed
+	}
+	return value
+}
+
+// annotationProxy returns a proxy object for the annotation, or null if its interface
+// can't be loaded. The values of its elements are converted to Java values here, which
+// initializes the classes of the enum constants among them.
+func annotationProxy(annotation Annotation, threadID int) (*object.Object, *GErrBlk) {
+	k := declaringClassData(annotation.Type)
+	if k == nil {
+		return object.Null, nil
+	}
+	elements := annotationElementMethods(k)
+	complete := completeAnnotation(annotation, elements)
+	className := annotationProxyClass(annotation.Type, elements)
+
+	// the proxy class declares no fields, so the element values and the annotation are
+	// kept in the proxy's FieldTable
+	proxy := object.MakeEmptyObject()
+	proxy.Klass = &className
+	_ = LayOutFields(proxy, className)
+	for _, element := range elements {
+		for _, pair := range complete.Elements {
+			if pair.Name != element.name {
+				continue
+			}
+			value, errBlk := elementJavaValue(pair.Value, element.returnType, threadID)
+			if errBlk != nil {
+				return nil, errBlk
+			}
+			SetObjectField(proxy, element.name, object.Field{Ftype: element.returnType, Fvalue: value})
+		}
+	}
+	SetObjectField(proxy, annotationProxyField, object.Field{Fvalue: complete})
+	return proxy, nil
+}
+
+// annotationProxyClass returns the name of the proxy class of the annotation interface,
+// creating the class the first time, or again if the method area or the MTable has been
+// reset since. The proxy classes are named as in the JDK: jdk/proxy1/$Proxy1, etc.
+func annotationProxyClass(annotationType string, elements []annotationElementMethod) string {
+	annotationProxyMutex.Lock()
+	defer annotationProxyMutex.Unlock()
+	className, ok := annotationProxyClasses[annotationType]
+	if ok && MethAreaFetch(className) != nil &&
+		MTableFetch(className+".annotationType()Ljava/lang/Class;").Meth != nil {
+		return className
+	}
+	if !ok {
+		className = fmt.Sprintf("jdk/proxy1/$Proxy%d", len(annotationProxyClasses)+1)
+	}
+	data := ClData{
+		Name:        className,
+		Superclass:  "java/lang/Object",
+		Interfaces:  []uint16{0},
+		MethodTable: make(map[string]*Method),
+		CP:          CPool{Utf8Refs: []string{annotationType}},
+		ClInit:      types.NoClinit,
+	}
+	data.Access.ClassIsPublic = true
+	data.Access.ClassIsFinal = true
+	data.Access.ClassIsSynthetic = true
+	MethAreaInsert(className, &Klass{Status: 'N', Loader: "bootstrap", Data: &data})
+
+	proxyMethods := map[string]GMeth{
+		className + ".annotationType()Ljava/lang/Class;": {ParamSlots: 1, GFunction: annotationProxyType},
+		className + ".toString()Ljava/lang/String;":      {ParamSlots: 1, GFunction: annotationProxyToString},
+		className + ".hashCode()I":                       {ParamSlots: 1, GFunction: annotationProxyHashCode},
+		className + ".equals(Ljava/lang/Object;)Z":       {ParamSlots: 2, GFunction: annotationProxyEquals},
+	}
+	for _, element := range elements {
+		proxyMethods[className+"."+element.name+"()"+element.returnType] =
+			GMeth{ParamSlots: 1, GFunction: annotationElementGetter(annotationType, element.name)}
+	}
+	loadlib(&MTable, proxyMethods)
+
+	annotationProxyClasses[annotationType] = className
+	return className
+}
+
+// annotationElementGetter returns the Go function of the element method of a proxy class,
+// which returns the value of the element. An array is copied, so that the annotation's
+// value can't be changed. If the annotation has no value for the element, which can
+// happen if the element was added to the interface after the annotation was compiled, an
+// IncompleteAnnotationException is thrown.
+func annotationElementGetter(annotationType, name string) func([]interface{}) interface{} {
+	return func(params []interface{}) interface{} {
+		value, ok := GetObjectField(params[0].(*object.Object), name)
+		if !ok {
+			return &GErrBlk{ExceptionType: "java/lang/annotation/IncompleteAnnotationException",
+				ErrMsg: javaName(annotationType) + " missing element " + name}
+		}
+		if strings.HasPrefix(value.Ftype, types.Array) {
+			return objectClone([]interface{}{value.Fvalue})
+		}
+		return value.Fvalue
+	}
+}
+
+// the annotation held by an annotation proxy
+func proxiedAnnotation(proxy *object.Object) (Annotation, bool) {
+	fld, ok := GetObjectField(proxy, annotationProxyField)
+	if !ok {
+		return Annotation{}, false
+	}
+	annotation, ok := fld.Fvalue.(Annotation)
+	return annotation, ok
+}
+
+// annotationType()Ljava/lang/Class; returns the mirror of the annotation interface
+func annotationProxyType(params []interface{}) interface{} {
+	annotation, _ := proxiedAnnotation(params[0].(*object.Object))
+	return GetClassMirror(annotation.Type)
+}
+
+// toString()Ljava/lang/String; returns the annotation as it'd be written in the source
+// code, with the values of all its elements, such as @com.example.Config(name="x", size=3)
+func annotationProxyToString(params []interface{}) interface{} {
+	annotation, _ := proxiedAnnotation(params[0].(*object.Object))
+	str := annotationToString(annotation)
+	return object.CreateCompactStringFromGoString(&str)
+}
+
+// hashCode()I returns the hash code of the string returned by toString(), so that equal
+// annotations have equal hash codes
+func annotationProxyHashCode(params []interface{}) interface{} {
+	annotation, _ := proxiedAnnotation(params[0].(*object.Object))
+	hash := int32(0)
+	for _, c := range annotationToString(annotation) {
+		hash = 31*hash + int32(c)
+	}
+	return int64(hash)
+}
+
+// equals(Ljava/lang/Object;)Z returns whether the object is an annotation of the same
+// interface whose elements have the same values
+func annotationProxyEquals(params []interface{}) interface{} {
+	annotation, _ := proxiedAnnotation(params[0].(*object.Object))
+	other, ok := params[1].(*object.Object)
+	if !ok || other == nil {
+		return types.JavaBoolFalse
+	}
+	otherAnnotation, ok := proxiedAnnotation(other)
+	return types.ConvertGoBoolToJavaBool(ok && reflect.DeepEqual(annotation, otherAnnotation))
+}
+
+// elementJavaValue converts the value of an annotation element to the Java value that its
+// element method returns, given the method's return type
+func elementJavaValue(value ElementValue, returnType string, threadID int) (interface{}, *GErrBlk) {
+	switch value.Tag {
+	case 's':
+		str := value.Value.(string)
+		return object.CreateCompactStringFromGoString(&str), nil
+	case 'c':
+		return GetClassMirror(descriptorClassName(value.Value.(string))), nil
+	case 'e':
+		enum := value.Value.(EnumConstant)
+		if errBlk := initializeDeclaringClass(enum.Type, threadID); errBlk != nil {
+			return nil, errBlk
+		}
+		if static, ok := FetchStatic(enum.Type + "." + enum.Name); ok {
+			if constant, ok := static.Value.(*object.Object); ok {
+				return constant, nil
+			}
+		}
+		return object.Null, nil
+	case '@':
+		return annotationProxy(value.Value.(Annotation), threadID)
+	case '[':
+		values := value.Value.([]ElementValue)
+		array := object.MakeArray(returnType, int64(len(values)))
+		for i, v := range values {
+			elementValue, errBlk := elementJavaValue(v, returnType[1:], threadID)
+			if errBlk != nil {
+				return nil, errBlk
+			}
+			// a value that doesn't match the element's type (as when the interface was changed
+			// after the annotation was compiled) is left as the default
+			switch elements := array.Fields[0].Fvalue.(type) {
+			case *[]byte:
+				if v, ok := elementValue.(int64); ok {
+					(*elements)[i] = byte(v)
+				}
+			case *[]int64:
+				if v, ok := elementValue.(int64); ok {
+					(*elements)[i] = v
+				}
+			case *[]float64:
+				if v, ok := elementValue.(float64); ok {
+					(*elements)[i] = v
+				}
+			case *[]*object.Object:
+				if v, ok := elementValue.(*object.Object); ok {
+					(*elements)[i] = v
+				}
+			}
+		}
+		return array, nil
+	default: // a primitive value, which is an int64 or a float64, as on the operand stack
+		return value.Value, nil
+	}
+}
+
+// annotationToString formats an annotation as it's written in the source code
+func annotationToString(annotation Annotation) string {
+	elements := make([]string, len(annotation.Elements))
+	for i, element := range annotation.Elements {
+		elements[i] = element.Name + "=" + elementValueToString(element.Value)
+	}
+	return "@" + javaName(annotation.Type) + "(" + strings.Join(elements, ", ") + ")"
+}
+
+// elementValueToString formats an element value as it's written in the source code, as
+// the JDK's toString() of an annotation does
+func elementValueToString(value ElementValue) string {
+	switch value.Tag {
+	case 'B':
+		return fmt.Sprintf("(byte)0x%02x", byte(value.Value.(int64)))
+	case 'C':
+		return "'" + string(rune(value.Value.(int64))) + "'"
+	case 'J':
+		return strconv.FormatInt(value.Value.(int64), 10) + "L"
+	case 'Z':
+		return strconv.FormatBool(value.Value.(int64) != 0)
+	case 'F':
+		return floatToString(value.Value.(float64), 32) + "f"
+	case 'D':
+		return floatToString(value.Value.(float64), 64)
+	case 's':
+		return strconv.Quote(value.Value.(string))
+	case 'c':
+		desc := value.Value.(string)
+		dims := len(desc) - len(strings.TrimLeft(desc, types.Array))
+		return javaName(descriptorClassName(desc[dims:])) + strings.Repeat("[]", dims) + ".class"
+	case 'e':
+		return value.Value.(EnumConstant).Name
+	case '@':
+		return annotationToString(value.Value.(Annotation))
+	case '[':
+		values := value.Value.([]ElementValue)
+		strs := make([]string, len(values))
+		for i, v := range values {
+			strs[i] = elementValueToString(v)
+		}
+		return "{" + strings.Join(strs, ", ") + "}"
+	default: // the other integral types
+		return strconv.FormatInt(value.Value.(int64), 10)
+	}
+}
+
+// floatToString formats a float or double value in the source code. An integral value has
+// a fractional part of .0, and values that aren't finite are written as divisions by zero.
+func floatToString(value float64, bitSize int) string {
+	switch {
+	case value != value:
+		return "0.0/0.0"
+	case value > 0 && value*2 == value:
+		return "1.0/0.0"
+	case value < 0 && value*2 == value:
+		return "-1.0/0.0"
+	}
+	str := strconv.FormatFloat(value, 'g', -1, bitSize)
+	if !strings.ContainsAny(str, ".e") {
+		str += ".0"
+	}
+	return str
+}
@@ -0,0 +1,247 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"fmt"
+	"jacobin/object"
+	"jacobin/thread"
+	"jacobin/types"
+	"strings"
+	"time"
+)
+
+/*
+ The native methods of java.lang.Object. wait(), notify() and notifyAll() work on the
+ object's monitor (see object/monitor.go), which the calling thread must own. Each of
+ them is passed the ID of the calling thread after its arguments.
+
+ equals() and toString() are not native in the JDK, but as they depend only on the
+ object's identity, they are implemented here as well. Note that toString() uses the
+ identity hash code, rather than calling a hashCode() method that the class overrides.
+*/
+
+func Load_Lang_Object() map[string]GMeth {
+
+	MethodSignatures["java/lang/Object.hashCode()I"] =
+		GMeth{
+			ParamSlots: 1, // the object
+			GFunction:  objectHashCode,
+		}
+
+	MethodSignatures["java/lang/Object.equals(Ljava/lang/Object;)Z"] =
+		GMeth{
+			ParamSlots: 2, // the object, the object to compare it with
+			GFunction:  objectEquals,
+		}
+
+	MethodSignatures["java/lang/Object.getClass()Ljava/lang/Class;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  objectGetClass,
+		}
+
+	MethodSignatures["java/lang/Object.clone()Ljava/lang/Object;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  objectClone,
+		}
+
+	MethodSignatures["java/lang/Object.toString()Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  objectToString,
+		}
+
+	MethodSignatures["java/lang/Object.wait()V"] =
+		GMeth{
+			ParamSlots:  1, // the object
+			GFunction:   objectWait,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/Object.wait(J)V"] =
+		GMeth{
+			ParamSlots:  3, // the object + 2 slots for the long
+			GFunction:   objectWaitWithTimeout,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/Object.wait(JI)V"] =
+		GMeth{
+			ParamSlots:  4, // the object + 2 slots for the long + the nanos
+			GFunction:   objectWaitWithTimeoutAndNanos,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/Object.notify()V"] =
+		GMeth{
+			ParamSlots:  1,
+			GFunction:   objectNotify,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/Object.notifyAll()V"] =
+		GMeth{
+			ParamSlots:  1,
+			GFunction:   objectNotifyAll,
+			NeedsThread: true,
+		}
+
+	return MethodSignatures
+}
+
+// java/lang/Object.hashCode()I returns the object's identity hash code, which is kept
+// in its mark word
+func objectHashCode(params []interface{}) interface{} {
+	return int64(int32(params[0].(*object.Object).Mark.Hash))
+}
+
+// java/lang/Object.equals(Ljava/lang/Object;)Z reports whether the two references are
+// to the same object
+func objectEquals(params []interface{}) interface{} {
+	other, _ := params[1].(*object.Object)
+	return types.ConvertGoBoolToJavaBool(params[0].(*object.Object) == other)
+}
+
+// java/lang/Object.getClass()Ljava/lang/Class; returns the mirror of the object's class
+func objectGetClass(params []interface{}) interface{} {
+	return GetClassMirror(*params[0].(*object.Object).Klass)
+}
+
+// java/lang/Object.toString()Ljava/lang/String; returns the class name followed by @ and
+// the identity hash code in hex, such as java.lang.Object@1b6d3586
+func objectToString(params []interface{}) interface{} {
+	obj := params[0].(*object.Object)
+	str := fmt.Sprintf("%s@%x", javaName(*obj.Klass), obj.Mark.Hash)
+	return object.CreateCompactStringFromGoString(&str)
+}
+
+// java/lang/Object.clone()Ljava/lang/Object; returns a shallow copy of an array or of an
+// object whose class implements Cloneable: the copy's fields (or elements) have the same
+// values as the original's, so references in them point to the same objects. The copy
+// has its own identity hash code and monitor.
+func objectClone(params []interface{}) interface{} {
+	obj := params[0].(*object.Object)
+	className := *obj.Klass
+	isArray := strings.HasPrefix(className, types.Array)
+	if !isArray && !ImplementsInterface(className, "java/lang/Cloneable") {
+		return &GErrBlk{ExceptionType: "java/lang/CloneNotSupportedException",
+			ErrMsg: javaName(className)}
+	}
+
+	clone := object.MakeEmptyObject()
+	clone.Klass = obj.Klass
+	clone.LaidOut = obj.LaidOut
+	clone.Fields = make([]object.Field, len(obj.Fields))
+	copy(clone.Fields, obj.Fields)
+	if obj.FieldTable != nil {
+		clone.FieldTable = make(map[string]object.Field, len(obj.FieldTable))
+		for name, fld := range obj.FieldTable {
+			clone.FieldTable[name] = fld
+		}
+	}
+
+	if isArray { // the elements are copied, and the Klass field points to the copy's type
+		switch elements := obj.Fields[0].Fvalue.(type) {
+		case *[]byte:
+			clone.Fields[0].Fvalue = copyElements(elements)
+		case *[]int64:
+			clone.Fields[0].Fvalue = copyElements(elements)
+		case *[]float64:
+			clone.Fields[0].Fvalue = copyElements(elements)
+		case *[]*object.Object:
+			clone.Fields[0].Fvalue = copyElements(elements)
+		}
+		clone.Klass = &clone.Fields[0].Ftype
+	}
+	return clone
+}
+
+// copyElements returns a pointer to a copy of the elements of an array
+func copyElements[T any](elements *[]T) *[]T {
+	elementsCopy := make([]T, len(*elements))
+	copy(elementsCopy, *elements)
+	return &elementsCopy
+}
+
+// java/lang/Object.wait()V waits until the thread is notified or interrupted
+func objectWait(params []interface{}) interface{} {
+	return waitOnObject(params[0].(*object.Object), 0, params[1].(int))
+}
+
+// java/lang/Object.wait(J)V waits at most the given number of milliseconds for the
+// thread to be notified. A timeout of 0 means to wait until notified or interrupted.
+func objectWaitWithTimeout(params []interface{}) interface{} {
+	millis := params[1].(int64)
+	if millis < 0 {
+		return &GErrBlk{ExceptionType: "java/lang/IllegalArgumentException",
+			ErrMsg: "timeout value is negative"}
+	}
+	return waitOnObject(params[0].(*object.Object), time.Duration(millis)*time.Millisecond, params[3].(int))
+}
+
+// java/lang/Object.wait(JI)V is wait(J)V with an additional timeout in nanoseconds
+func objectWaitWithTimeoutAndNanos(params []interface{}) interface{} {
+	millis := params[1].(int64)
+	nanos := params[3].(int64)
+	if millis < 0 {
+		return &GErrBlk{ExceptionType: "java/lang/IllegalArgumentException",
+			ErrMsg: "timeout value is negative"}
+	}
+	if nanos < 0 || nanos > 999999 {
+		return &GErrBlk{ExceptionType: "java/lang/IllegalArgumentException",
+			ErrMsg: "nanosecond timeout value out of range"}
+	}
+	timeout := time.Duration(millis)*time.Millisecond + time.Duration(nanos)
+	return waitOnObject(params[0].(*object.Object), timeout, params[4].(int))
+}
+
+// waitOnObject makes the thread threadID wait on the monitor of obj. The thread must
+// own the monitor, and an InterruptedException is thrown if it's interrupted before
+// or while it waits. Java code waits in a loop that tests its condition, so it's not
+// affected by a thread waking before it's notified, as when the wait times out.
+func waitOnObject(obj *object.Object, timeout time.Duration, threadID int) interface{} {
+	monitor := object.GetMonitor(obj)
+
+	t := thread.FindThread(threadID)
+	if t == nil { // a thread that isn't in the thread table can't be interrupted
+		if owned, _ := monitor.Wait(threadID, timeout, nil); !owned {
+			return &GErrBlk{ExceptionType: "java/lang/IllegalMonitorStateException",
+				ErrMsg: "current thread is not owner"}
+		}
+		return nil
+	}
+
+	owned, interrupted := t.WaitOnMonitor(monitor, timeout)
+	if !owned {
+		return &GErrBlk{ExceptionType: "java/lang/IllegalMonitorStateException",
+			ErrMsg: "current thread is not owner"}
+	}
+	if interrupted {
+		return &GErrBlk{ExceptionType: "java/lang/InterruptedException"}
+	}
+	return nil
+}
+
+// java/lang/Object.notify()V wakes one of the threads waiting on the object
+func objectNotify(params []interface{}) interface{} {
+	if !object.GetMonitor(params[0].(*object.Object)).Notify(params[1].(int)) {
+		return &GErrBlk{ExceptionType: "java/lang/IllegalMonitorStateException",
+			ErrMsg: "current thread is not owner"}
+	}
+	return nil
+}
+
+// java/lang/Object.notifyAll()V wakes all the threads waiting on the object
+func objectNotifyAll(params []interface{}) interface{} {
+	if !object.GetMonitor(params[0].(*object.Object)).NotifyAll(params[1].(int)) {
+		return &GErrBlk{ExceptionType: "java/lang/IllegalMonitorStateException",
+			ErrMsg: "current thread is not owner"}
+	}
+	return nil
+}
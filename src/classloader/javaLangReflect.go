@@ -0,0 +1,795 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"fmt"
+	"jacobin/object"
+	"jacobin/types"
+	"strings"
+)
+
+/*
+ Core reflection: the declared methods, fields, and constructors of a class, as returned
+ by Class.getDeclaredMethods() and the like, and the means to invoke them and to get and
+ set the fields.
+
+ The reflection objects are instances of java/lang/reflect/Method, Field, and Constructor,
+ whose fields are set as the JDK sets them. In particular, clazz is the mirror of the
+ declaring class and slot is the index of the member in the class's ClData.Methods or
+ ClData.Fields, which is how the member is found again when it's invoked or accessed.
+
+ Primitive values are boxed when they're returned as objects and unboxed when they're
+ passed as arguments, with the widening conversions that Method.invoke() allows. Jacobin
+ does not check access to members, so setAccessible() has nothing to do.
+*/
+
+// InvokeMethod invokes a method on the thread with the given ID. It needs the
+// interpreter, so it's set by the jvm package. The receiver is nil for a static method
+// and for a constructor, in which case the new object is created and returned. The
+// arguments are unboxed, one per parameter. It returns the method's result, which is
+// nil for a void method, or the exception to throw: an InvocationTargetException that
+// wraps the exception the method threw, or the exception thrown by the initialization
+// of the class. An error is returned only if the JVM itself fails.
+var InvokeMethod func(className, methName, methType string, receiver *object.Object,
+	args []interface{}, threadID int) (interface{}, *object.Object, error)
+
+func Load_Lang_Reflect() map[string]GMeth {
+
+	MethodSignatures["java/lang/Class.getDeclaredMethods()[Ljava/lang/reflect/Method;"] =
+		GMeth{
+			ParamSlots: 1, // the mirror
+			GFunction:  classGetDeclaredMethods,
+		}
+
+	MethodSignatures["java/lang/Class.getDeclaredFields()[Ljava/lang/reflect/Field;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  classGetDeclaredFields,
+		}
+
+	MethodSignatures["java/lang/Class.getDeclaredConstructors()[Ljava/lang/reflect/Constructor;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  classGetDeclaredConstructors,
+		}
+
+	MethodSignatures["java/lang/Class.getDeclaredMethod(Ljava/lang/String;[Ljava/lang/Class;)Ljava/lang/reflect/Method;"] =
+		GMeth{
+			ParamSlots: 3, // the mirror, the method name, the parameter types
+			GFunction:  classGetDeclaredMethod,
+		}
+
+	MethodSignatures["java/lang/Class.getDeclaredField(Ljava/lang/String;)Ljava/lang/reflect/Field;"] =
+		GMeth{
+			ParamSlots: 2, // the mirror, the field name
+			GFunction:  classGetDeclaredField,
+		}
+
+	MethodSignatures["java/lang/Class.getDeclaredConstructor([Ljava/lang/Class;)Ljava/lang/reflect/Constructor;"] =
+		GMeth{
+			ParamSlots: 2, // the mirror, the parameter types
+			GFunction:  classGetDeclaredConstructor,
+		}
+
+	// the methods common to all three kinds of members
+	for _, member := range []string{reflectMethodClass, reflectFieldClass, reflectConstructorClass} {
+		MethodSignatures[member+".getDeclaringClass()Ljava/lang/Class;"] =
+			GMeth{
+				ParamSlots: 1, // the member
+				GFunction:  memberGetDeclaringClass,
+			}
+
+		MethodSignatures[member+".getModifiers()I"] =
+			GMeth{
+				ParamSlots: 1,
+				GFunction:  memberGetModifiers,
+			}
+
+		MethodSignatures[member+".getName()Ljava/lang/String;"] =
+			GMeth{
+				ParamSlots: 1,
+				GFunction:  memberGetName,
+			}
+
+		MethodSignatures[member+".setAccessible(Z)V"] =
+			GMeth{
+				ParamSlots: 2, // the member, whether it's accessible
+				GFunction:  justReturn,
+			}
+	}
+
+	for _, executable := range []string{reflectMethodClass, reflectConstructorClass} {
+		MethodSignatures[executable+".getParameterTypes()[Ljava/lang/Class;"] =
+			GMeth{
+				ParamSlots: 1,
+				GFunction:  executableGetParameterTypes,
+			}
+
+		MethodSignatures[executable+".getParameterCount()I"] =
+			GMeth{
+				ParamSlots: 1,
+				GFunction:  executableGetParameterCount,
+			}
+	}
+
+	MethodSignatures["java/lang/reflect/Method.getReturnType()Ljava/lang/Class;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  methodGetReturnType,
+		}
+
+	MethodSignatures["java/lang/reflect/Method.invoke(Ljava/lang/Object;[Ljava/lang/Object;)Ljava/lang/Object;"] =
+		GMeth{
+			ParamSlots:  3, // the method, the receiver, the arguments
+			GFunction:   methodInvoke,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/reflect/Constructor.newInstance([Ljava/lang/Object;)Ljava/lang/Object;"] =
+		GMeth{
+			ParamSlots:  2, // the constructor, the arguments
+			GFunction:   constructorNewInstance,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/reflect/Field.getType()Ljava/lang/Class;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  fieldGetType,
+		}
+
+	MethodSignatures["java/lang/reflect/Field.get(Ljava/lang/Object;)Ljava/lang/Object;"] =
+		GMeth{
+			ParamSlots:  2, // the field, the object
+			GFunction:   fieldGet,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/reflect/Field.set(Ljava/lang/Object;Ljava/lang/Object;)V"] =
+		GMeth{
+			ParamSlots:  3, // the field, the object, the value
+			GFunction:   fieldSet,
+			NeedsThread: true,
+		}
+	return MethodSignatures
+}
+
+// the classes of the reflection objects
+const (
+	reflectMethodClass      = "java/lang/reflect/Method"
+	reflectFieldClass       = "java/lang/reflect/Field"
+	reflectConstructorClass = "java/lang/reflect/Constructor"
+)
+
+// java/lang/Class.getDeclaredMethods()[Ljava/lang/reflect/Method; returns the methods
+// that the class declares, other than its constructors and its initializer, in the order
+// in which they're in the class file. Array classes and primitive types declare none.
+func classGetDeclaredMethods(params []interface{}) interface{} {
+	className := MirrorClassName(params[0].(*object.Object))
+	var methods []*object.Object
+	if k := declaringClassData(className); k != nil {
+		for slot, meth := range k.Methods {
+			if name := k.CP.Utf8Refs[meth.Name]; !strings.HasPrefix(name, "<") {
+				methods = append(methods, newMethodObject(className, k, slot))
+			}
+		}
+	}
+	return makeObjectArray("[L"+reflectMethodClass+";", methods)
+}
+
+// java/lang/Class.getDeclaredFields()[Ljava/lang/reflect/Field; returns the static and
+// instance fields that the class declares, in the order in which they're in the class file
+func classGetDeclaredFields(params []interface{}) interface{} {
+	className := MirrorClassName(params[0].(*object.Object))
+	var fields []*object.Object
+	if k := declaringClassData(className); k != nil {
+		for slot := range k.Fields {
+			fields = append(fields, newFieldObject(className, k, slot))
+		}
+	}
+	return makeObjectArray("[L"+reflectFieldClass+";", fields)
+}
+
+// java/lang/Class.getDeclaredConstructors()[Ljava/lang/reflect/Constructor; returns the
+// constructors that the class declares. Interfaces, array classes, and primitive types
+// have none.
+func classGetDeclaredConstructors(params []interface{}) interface{} {
+	className := MirrorClassName(params[0].(*object.Object))
+	var constructors []*object.Object
+	if k := declaringClassData(className); k != nil {
+		for slot, meth := range k.Methods {
+			if k.CP.Utf8Refs[meth.Name] == "<init>" {
+				constructors = append(constructors, newConstructorObject(className, k, slot))
+			}
+		}
+	}
+	return makeObjectArray("[L"+reflectConstructorClass+";", constructors)
+}
+
+// java/lang/Class.getDeclaredMethod(Ljava/lang/String;[Ljava/lang/Class;)Ljava/lang/reflect/Method;
+// returns the method that the class declares with the given name and parameter types. If
+// there's none, a NoSuchMethodException is thrown.
+func classGetDeclaredMethod(params []interface{}) interface{} {
+	className := MirrorClassName(params[0].(*object.Object))
+	nameObj, ok := params[1].(*object.Object)
+	if !ok || nameObj == nil {
+		return &GErrBlk{ExceptionType: "java/lang/NullPointerException"}
+	}
+	name := object.GetGoStringFromJavaStringPtr(nameObj)
+
+	paramTypes := mirrorsToClassNames(params[2])
+	if k := declaringClassData(className); k != nil && !strings.HasPrefix(name, "<") {
+		if slot := findDeclaredMethod(k, name, paramTypes); slot >= 0 {
+			return newMethodObject(className, k, slot)
+		}
+	}
+	return &GErrBlk{ExceptionType: "java/lang/NoSuchMethodException",
+		ErrMsg: methodToString(className, name, paramTypes)}
+}
+
+// java/lang/Class.getDeclaredField(Ljava/lang/String;)Ljava/lang/reflect/Field; returns
+// the field that the class declares with the given name. If there's none, a
+// NoSuchFieldException is thrown.
+func classGetDeclaredField(params []interface{}) interface{} {
+	className := MirrorClassName(params[0].(*object.Object))
+	nameObj, ok := params[1].(*object.Object)
+	if !ok || nameObj == nil {
+		return &GErrBlk{ExceptionType: "java/lang/NullPointerException"}
+	}
+	name := object.GetGoStringFromJavaStringPtr(nameObj)
+
+	if k := declaringClassData(className); k != nil {
+		for slot, fld := range k.Fields {
+			if k.CP.Utf8Refs[fld.Name] == name {
+				return newFieldObject(className, k, slot)
+			}
+		}
+	}
+	return &GErrBlk{ExceptionType: "java/lang/NoSuchFieldException", ErrMsg: name}
+}
+
+// java/lang/Class.getDeclaredConstructor([Ljava/lang/Class;)Ljava/lang/reflect/Constructor;
+// returns the constructor that the class declares with the given parameter types. If
+// there's none, a NoSuchMethodException is thrown.
+func classGetDeclaredConstructor(params []interface{}) interface{} {
+	className := MirrorClassName(params[0].(*object.Object))
+	paramTypes := mirrorsToClassNames(params[1])
+	if k := declaringClassData(className); k != nil {
+		if slot := findDeclaredMethod(k, "<init>", paramTypes); slot >= 0 {
+			return newConstructorObject(className, k, slot)
+		}
+	}
+	return &GErrBlk{ExceptionType: "java/lang/NoSuchMethodException",
+		ErrMsg: methodToString(className, "<init>", paramTypes)}
+}
+
+// declaringClassData returns the class data of the named class, loading the class if need
+// be, or nil if it's an array class or a primitive type, which declare no members
+func declaringClassData(className string) *ClData {
+	if strings.HasPrefix(className, types.Array) || isPrimitiveClass(className) {
+		return nil
+	}
+	k, err := fetchLoadedClass(className)
+	if err != nil {
+		return nil
+	}
+	return k.Data
+}
+
+// findDeclaredMethod returns the slot of the method in the class that has the given name
+// and the parameters of the given classes, or -1 if there is none
+func findDeclaredMethod(k *ClData, name string, paramTypes []string) int {
+	var paramDescs strings.Builder
+	for _, paramType := range paramTypes {
+		paramDescs.WriteString(classDescriptor(paramType))
+	}
+	prefix := "(" + paramDescs.String() + ")"
+
+	for slot, meth := range k.Methods {
+		if k.CP.Utf8Refs[meth.Name] == name && strings.HasPrefix(k.CP.Utf8Refs[meth.Desc], prefix) {
+			return slot
+		}
+	}
+	return -1
+}
+
+// methodToString formats a method as the detail message of a NoSuchMethodException,
+// such as Foo.bar(int, java.lang.String)
+func methodToString(className, name string, paramTypes []string) string {
+	names := make([]string, len(paramTypes))
+	for i, paramType := range paramTypes {
+		names[i] = javaName(paramType)
+	}
+	return fmt.Sprintf("%s.%s(%s)", javaName(className), name, strings.Join(names, ", "))
+}
+
+// newReflectionObject creates an object of one of the reflection classes. If the class
+// is loaded, the object's fields are laid out by it, otherwise they're kept in its FieldTable.
+func newReflectionObject(className string) *object.Object {
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	if MethAreaFetch(className) != nil {
+		_ = LayOutFields(obj, className)
+	}
+	return obj
+}
+
+// newMethodObject creates the java/lang/reflect/Method object for the method in the given
+// slot of the class
+func newMethodObject(className string, k *ClData, slot int) *object.Object {
+	meth := k.Methods[slot]
+	paramTypes, returnType, _ := splitMethodDescriptor(k.CP.Utf8Refs[meth.Desc])
+
+	obj := newReflectionObject(reflectMethodClass)
+	setMemberFields(obj, className, slot, meth.AccessFlags)
+	name := k.CP.Utf8Refs[meth.Name]
+	SetObjectField(obj, "name", object.Field{Ftype: "Ljava/lang/String;",
+		Fvalue: object.CreateCompactStringFromGoString(&name)})
+	SetObjectField(obj, "returnType", object.Field{Ftype: "Ljava/lang/Class;",
+		Fvalue: GetClassMirror(descriptorClassName(returnType))})
+	setExecutableFields(obj, k, &meth, paramTypes)
+	return obj
+}
+
+// newConstructorObject creates the java/lang/reflect/Constructor object for the
+// constructor in the given slot of the class
+func newConstructorObject(className string, k *ClData, slot int) *object.Object {
+	meth := k.Methods[slot]
+	paramTypes, _, _ := splitMethodDescriptor(k.CP.Utf8Refs[meth.Desc])
+
+	obj := newReflectionObject(reflectConstructorClass)
+	setMemberFields(obj, className, slot, meth.AccessFlags)
+	setExecutableFields(obj, k, &meth, paramTypes)
+	return obj
+}
+
+// newFieldObject creates the java/lang/reflect/Field object for the field in the given
+// slot of the class
+func newFieldObject(className string, k *ClData, slot int) *object.Object {
+	fld := k.Fields[slot]
+
+	obj := newReflectionObject(reflectFieldClass)
+	setMemberFields(obj, className, slot, fld.AccessFlags)
+	name := k.CP.Utf8Refs[fld.Name]
+	SetObjectField(obj, "name", object.Field{Ftype: "Ljava/lang/String;",
+		Fvalue: object.CreateCompactStringFromGoString(&name)})
+	SetObjectField(obj, "type", object.Field{Ftype: "Ljava/lang/Class;",
+		Fvalue: GetClassMirror(descriptorClassName(k.CP.Utf8Refs[fld.Desc]))})
+	return obj
+}
+
+// setMemberFields sets the fields that all the reflection objects have
+func setMemberFields(obj *object.Object, className string, slot, accessFlags int) {
+	SetObjectField(obj, "clazz", object.Field{Ftype: "Ljava/lang/Class;", Fvalue: GetClassMirror(className)})
+	SetObjectField(obj, "slot", object.Field{Ftype: types.Int, Fvalue: int64(slot)})
+	SetObjectField(obj, "modifiers", object.Field{Ftype: types.Int, Fvalue: int64(accessFlags)})
+}
+
+// setExecutableFields sets the parameter and exception types of a method or constructor
+func setExecutableFields(obj *object.Object, k *ClData, meth *Method, paramTypes []string) {
+	paramClasses := make([]string, len(paramTypes))
+	for i, paramType := range paramTypes {
+		paramClasses[i] = descriptorClassName(paramType)
+	}
+	SetObjectField(obj, "parameterTypes", object.Field{Ftype: "[Ljava/lang/Class;",
+		Fvalue: classNamesToMirrors(paramClasses)})
+
+	exceptionClasses := make([]string, len(meth.Exceptions))
+	for i, utf8Index := range meth.Exceptions {
+		exceptionClasses[i] = k.CP.Utf8Refs[utf8Index]
+	}
+	SetObjectField(obj, "exceptionTypes", object.Field{Ftype: "[Ljava/lang/Class;",
+		Fvalue: classNamesToMirrors(exceptionClasses)})
+}
+
+// makeObjectArray returns an array of the given type that holds the objects
+func makeObjectArray(arrayType string, objs []*object.Object) *object.Object {
+	array := object.MakeArray(arrayType, int64(len(objs)))
+	copy(*array.Fields[0].Fvalue.(*[]*object.Object), objs)
+	return array
+}
+
+// classNamesToMirrors returns a Class[] that holds the mirrors of the named classes
+func classNamesToMirrors(classNames []string) *object.Object {
+	mirrors := make([]*object.Object, len(classNames))
+	for i, className := range classNames {
+		mirrors[i] = GetClassMirror(className)
+	}
+	return makeObjectArray("[Ljava/lang/Class;", mirrors)
+}
+
+// mirrorsToClassNames returns the names of the classes whose mirrors are in a Class[].
+// A null array is taken as an empty one, as getDeclaredMethod() does.
+func mirrorsToClassNames(array interface{}) []string {
+	arrayObj, ok := array.(*object.Object)
+	if !ok || arrayObj == nil || len(arrayObj.Fields) == 0 {
+		return nil
+	}
+	mirrors := *arrayObj.Fields[0].Fvalue.(*[]*object.Object)
+	classNames := make([]string, len(mirrors))
+	for i, mirror := range mirrors {
+		if mirror != nil {
+			classNames[i] = MirrorClassName(mirror)
+		}
+	}
+	return classNames
+}
+
+// classDescriptor returns the field descriptor of the named class. It's the reverse of
+// descriptorClassName().
+func classDescriptor(className string) string {
+	for desc, primitive := range primitiveClassNames {
+		if className == primitive {
+			return desc
+		}
+	}
+	if strings.HasPrefix(className, types.Array) {
+		return className
+	}
+	return "L" + className + ";"
+}
+
+// memberOf returns the name of the class that declares the member represented by the
+// reflection object, the data of that class, and the slot of the member in it
+func memberOf(member *object.Object) (string, *ClData, int) {
+	clazz, _ := GetObjectField(member, "clazz")
+	className := MirrorClassName(clazz.Fvalue.(*object.Object))
+	slot, _ := GetObjectField(member, "slot")
+	return className, declaringClassData(className), int(slot.Fvalue.(int64))
+}
+
+// methodOf returns the method or constructor that the reflection object represents, along
+// with the name of its declaring class and its name and type
+func methodOf(member *object.Object) (className string, methName string, methType string, meth *Method) {
+	className, k, slot := memberOf(member)
+	meth = &k.Methods[slot]
+	return className, k.CP.Utf8Refs[meth.Name], k.CP.Utf8Refs[meth.Desc], meth
+}
+
+// fieldOf returns the field that the reflection object represents, along with the name
+// of its declaring class and its name and type
+func fieldOf(member *object.Object) (className string, fieldName string, fieldType string, fld *Field) {
+	className, k, slot := memberOf(member)
+	fld = &k.Fields[slot]
+	return className, k.CP.Utf8Refs[fld.Name], k.CP.Utf8Refs[fld.Desc], fld
+}
+
+// getDeclaringClass()Ljava/lang/Class; returns the mirror of the class that declares
+// the method, field, or constructor
+func memberGetDeclaringClass(params []interface{}) interface{} {
+	clazz, _ := GetObjectField(params[0].(*object.Object), "clazz")
+	return clazz.Fvalue
+}
+
+// getModifiers()I returns the access flags of the method, field, or constructor, which
+// are the modifiers with which it's declared
+func memberGetModifiers(params []interface{}) interface{} {
+	modifiers, _ := GetObjectField(params[0].(*object.Object), "modifiers")
+	return modifiers.Fvalue
+}
+
+// getName()Ljava/lang/String; returns the name of the method or field. The name of a
+// constructor is that of its class, in Java format.
+func memberGetName(params []interface{}) interface{} {
+	member := params[0].(*object.Object)
+	if *member.Klass == reflectConstructorClass {
+		clazz, _ := GetObjectField(member, "clazz")
+		return classGetName([]interface{}{clazz.Fvalue})
+	}
+	name, _ := GetObjectField(member, "name")
+	return name.Fvalue
+}
+
+// getParameterTypes()[Ljava/lang/Class; returns the mirrors of the classes of the
+// parameters of the method or constructor. The array is a copy, as in the JDK.
+func executableGetParameterTypes(params []interface{}) interface{} {
+	paramTypes, _ := GetObjectField(params[0].(*object.Object), "parameterTypes")
+	return makeObjectArray("[Ljava/lang/Class;", *paramTypes.Fvalue.(*object.Object).Fields[0].Fvalue.(*[]*object.Object))
+}
+
+// getParameterCount()I returns the number of parameters of the method or constructor
+func executableGetParameterCount(params []interface{}) interface{} {
+	paramTypes, _ := GetObjectField(params[0].(*object.Object), "parameterTypes")
+	return int64(len(*paramTypes.Fvalue.(*object.Object).Fields[0].Fvalue.(*[]*object.Object)))
+}
+
+// java/lang/reflect/Method.getReturnType()Ljava/lang/Class; returns the mirror of the
+// class of the method's return value, which is void for a void method
+func methodGetReturnType(params []interface{}) interface{} {
+	returnType, _ := GetObjectField(params[0].(*object.Object), "returnType")
+	return returnType.Fvalue
+}
+
+// java/lang/reflect/Field.getType()Ljava/lang/Class; returns the mirror of the field's class
+func fieldGetType(params []interface{}) interface{} {
+	fieldType, _ := GetObjectField(params[0].(*object.Object), "type")
+	return fieldType.Fvalue
+}
+
+// java/lang/reflect/Method.invoke(Ljava/lang/Object;[Ljava/lang/Object;)Ljava/lang/Object;
+// invokes the method on the receiver, which is ignored for a static method, with the
+// arguments in the array, which are unboxed for primitive parameters. A primitive result
+// is boxed, and a void method returns null. An exception thrown by the method is wrapped
+// in an InvocationTargetException.
+func methodInvoke(params []interface{}) interface{} {
+	className, methName, methType, meth := methodOf(params[0].(*object.Object))
+
+	var receiver *object.Object
+	if meth.AccessFlags&AccStatic == 0 {
+		obj, ok := params[1].(*object.Object)
+		if !ok || obj == nil {
+			return &GErrBlk{ExceptionType: "java/lang/NullPointerException"}
+		}
+		if obj.Klass == nil || !IsAssignableTo(*obj.Klass, className) {
+			return &GErrBlk{ExceptionType: "java/lang/IllegalArgumentException",
+				ErrMsg: "object is not an instance of declaring class"}
+		}
+		receiver = obj
+	}
+
+	paramTypes, returnType, _ := splitMethodDescriptor(methType)
+	args, errBlk := unboxArguments(params[2], paramTypes)
+	if errBlk != nil {
+		return errBlk
+	}
+
+	result, exc, err := InvokeMethod(className, methName, methType, receiver, args, params[3].(int))
+	if errBlk := invocationError(exc, err); errBlk != nil {
+		return errBlk
+	}
+	return boxValue(result, returnType)
+}
+
+// java/lang/reflect/Constructor.newInstance([Ljava/lang/Object;)Ljava/lang/Object; creates
+// an object of the constructor's class, initializing the class if need be, and runs the
+// constructor on it with the arguments in the array. An exception thrown by the
+// constructor is wrapped in an InvocationTargetException.
+func constructorNewInstance(params []interface{}) interface{} {
+	className, methName, methType, _ := methodOf(params[0].(*object.Object))
+	if k := MethAreaFetch(className); k != nil && k.Data != nil &&
+		(k.Data.Access.ClassIsAbstract || k.Data.Access.ClassIsInterface) {
+		return &GErrBlk{ExceptionType: "java/lang/InstantiationException"}
+	}
+
+	paramTypes, _, _ := splitMethodDescriptor(methType)
+	args, errBlk := unboxArguments(params[1], paramTypes)
+	if errBlk != nil {
+		return errBlk
+	}
+
+	obj, exc, err := InvokeMethod(className, methName, methType, nil, args, params[2].(int))
+	if errBlk := invocationError(exc, err); errBlk != nil {
+		return errBlk
+	}
+	return obj
+}
+
+// invocationError returns the *GErrBlk that throws the exception returned by InvokeMethod,
+// or nil if there's none. A failure of the JVM is reported as an InternalError.
+func invocationError(exc *object.Object, err error) *GErrBlk {
+	if err != nil { // the error will have been logged
+		return &GErrBlk{ExceptionType: "java/lang/InternalError", ErrMsg: err.Error()}
+	}
+	if exc != nil {
+		return &GErrBlk{ExceptionType: *exc.Klass, Exception: exc}
+	}
+	return nil
+}
+
+// java/lang/reflect/Field.get(Ljava/lang/Object;)Ljava/lang/Object; returns the value of
+// the field in the object, or of the static field, whose class is initialized first. A
+// primitive value is boxed.
+func fieldGet(params []interface{}) interface{} {
+	className, fieldName, fieldType, fld := fieldOf(params[0].(*object.Object))
+
+	if fld.IsStatic {
+		if errBlk := initializeDeclaringClass(className, params[2].(int)); errBlk != nil {
+			return errBlk
+		}
+		static, _ := FetchStatic(className + "." + fieldName)
+		value := static.Value
+		switch v := value.(type) { // statics can be kept in other forms than those on the stack
+		case bool:
+			value = types.ConvertGoBoolToJavaBool(v)
+		case byte:
+			value = int64(v)
+		case int:
+			value = int64(v)
+		}
+		return boxValue(value, fieldType)
+	}
+
+	obj, errBlk := fieldReceiver(params[1], className, fieldName, fieldType)
+	if errBlk != nil {
+		return errBlk
+	}
+	if slot := instanceFieldSlot(obj, className, fieldName); slot >= 0 {
+		return boxValue(obj.Fields[slot].Fvalue, fieldType)
+	}
+	value, _ := GetObjectField(obj, fieldName)
+	return boxValue(value.Fvalue, fieldType)
+}
+
+// java/lang/reflect/Field.set(Ljava/lang/Object;Ljava/lang/Object;)V sets the value of the
+// field in the object, or of the static field, whose class is initialized first. The value
+// is unboxed for a primitive field.
+func fieldSet(params []interface{}) interface{} {
+	className, fieldName, fieldType, fld := fieldOf(params[0].(*object.Object))
+
+	value, ok := unboxValue(params[2], fieldType)
+	if !ok {
+		return &GErrBlk{ExceptionType: "java/lang/IllegalArgumentException",
+			ErrMsg: fmt.Sprintf("Can not set %s field %s.%s to %s", javaName(descriptorClassName(fieldType)),
+				javaName(className), fieldName, valueClassName(params[2]))}
+	}
+
+	if fld.IsStatic {
+		if errBlk := initializeDeclaringClass(className, params[3].(int)); errBlk != nil {
+			return errBlk
+		}
+		_ = AddStatic(className+"."+fieldName, Static{Type: fieldType, Value: value})
+		return nil
+	}
+
+	obj, errBlk := fieldReceiver(params[1], className, fieldName, fieldType)
+	if errBlk != nil {
+		return errBlk
+	}
+	if slot := instanceFieldSlot(obj, className, fieldName); slot >= 0 {
+		obj.Fields[slot].Fvalue = value
+		return nil
+	}
+	SetObjectField(obj, fieldName, object.Field{Ftype: fieldType, Fvalue: value})
+	return nil
+}
+
+// fieldReceiver checks that the object whose instance field is to be accessed is an
+// instance of the class that declares the field, and returns it
+func fieldReceiver(param interface{}, className, fieldName, fieldType string) (*object.Object, *GErrBlk) {
+	obj, ok := param.(*object.Object)
+	if !ok || obj == nil {
+		return nil, &GErrBlk{ExceptionType: "java/lang/NullPointerException"}
+	}
+	if obj.Klass == nil || !IsAssignableTo(*obj.Klass, className) {
+		return nil, &GErrBlk{ExceptionType: "java/lang/IllegalArgumentException",
+			ErrMsg: fmt.Sprintf("Can not get %s field %s.%s on %s", javaName(descriptorClassName(fieldType)),
+				javaName(className), fieldName, javaName(*obj.Klass))}
+	}
+	return obj, nil
+}
+
+// instanceFieldSlot returns the slot in the object's Fields of the field that the named
+// class declares, which may be shadowed by a field of the same name in a subclass. It's -1
+// if the object's fields are not laid out by its class, in which case they're in its FieldTable.
+func instanceFieldSlot(obj *object.Object, className, fieldName string) int {
+	if objectFieldSlot(obj, fieldName) < 0 {
+		return -1
+	}
+	slot, err := ResolveInstanceField(className, fieldName)
+	if err != nil {
+		return -1
+	}
+	return slot
+}
+
+// initializeDeclaringClass initializes the class that declares a static field before the
+// field is accessed, as GETSTATIC and PUTSTATIC do
+func initializeDeclaringClass(className string, threadID int) *GErrBlk {
+	if InitializeClass == nil {
+		return nil
+	}
+	return invocationError(InitializeClass(className, threadID))
+}
+
+// the wrapper classes of the primitive types, by their descriptors
+var wrapperClassNames = map[string]string{
+	types.Bool: "java/lang/Boolean", types.Byte: "java/lang/Byte", types.Char: "java/lang/Character",
+	types.Short: "java/lang/Short", types.Int: "java/lang/Integer", types.Long: "java/lang/Long",
+	types.Float: "java/lang/Float", types.Double: "java/lang/Double",
+}
+
+// the primitive types to which each primitive type can be converted by a widening
+// conversion (JLS 5.1.2), including itself, as is allowed for the unboxed arguments
+// of Method.invoke()
+var wideningConversions = map[string]string{
+	types.Bool: "Z", types.Byte: "BSIJFD", types.Char: "CIJFD", types.Short: "SIJFD",
+	types.Int: "IJFD", types.Long: "JFD", types.Float: "FD", types.Double: "D",
+}
+
+// boxValue returns the value of the given type as an object: a primitive value is boxed
+// in an object of its wrapper class, and a null reference or the absent result of a void
+// method is null
+func boxValue(value interface{}, desc string) interface{} {
+	className, isPrimitive := wrapperClassNames[desc]
+	if !isPrimitive {
+		if obj, ok := value.(*object.Object); ok && obj != nil {
+			return obj
+		}
+		return object.Null
+	}
+
+	wrapper := newReflectionObject(className)
+	SetObjectField(wrapper, "value", object.Field{Ftype: desc, Fvalue: value})
+	return wrapper
+}
+
+// unboxValue converts an object to a value of the given type. For a primitive type, the
+// object must be of a wrapper class whose primitive type widens to it. For a reference
+// type, it must be null or assignable to the type. Returns false if it can't be converted.
+func unboxValue(value interface{}, desc string) (interface{}, bool) {
+	obj, _ := value.(*object.Object)
+	if _, isPrimitive := wrapperClassNames[desc]; !isPrimitive {
+		if obj == nil {
+			return object.Null, true
+		}
+		return obj, obj.Klass != nil && IsAssignableTo(*obj.Klass, descriptorClassName(desc))
+	}
+
+	if obj == nil || obj.Klass == nil {
+		return nil, false
+	}
+	var from string
+	for primitive, className := range wrapperClassNames {
+		if *obj.Klass == className {
+			from = primitive
+		}
+	}
+	if from == "" || !strings.Contains(wideningConversions[from], desc) {
+		return nil, false
+	}
+
+	fld, _ := GetObjectField(obj, "value")
+	switch v := fld.Fvalue.(type) {
+	case int64:
+		if desc == types.Float {
+			return float64(float32(v)), true
+		} else if desc == types.Double {
+			return float64(v), true
+		}
+		return v, true
+	case float64:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// unboxArguments converts the arguments in an Object[] to the types of the parameters of a
+// method. A null array is taken as an empty one. A wrong number of arguments, or an
+// argument that can't be converted, throws an IllegalArgumentException.
+func unboxArguments(array interface{}, paramTypes []string) ([]interface{}, *GErrBlk) {
+	var elements []*object.Object
+	if arrayObj, ok := array.(*object.Object); ok && arrayObj != nil && len(arrayObj.Fields) > 0 {
+		elements = *arrayObj.Fields[0].Fvalue.(*[]*object.Object)
+	}
+	if len(elements) != len(paramTypes) {
+		return nil, &GErrBlk{ExceptionType: "java/lang/IllegalArgumentException",
+			ErrMsg: fmt.Sprintf("wrong number of arguments: %d expected: %d", len(elements), len(paramTypes))}
+	}
+
+	args := make([]interface{}, len(paramTypes))
+	for i, paramType := range paramTypes {
+		arg, ok := unboxValue(elements[i], paramType)
+		if !ok {
+			return nil, &GErrBlk{ExceptionType: "java/lang/IllegalArgumentException",
+				ErrMsg: "argument type mismatch"}
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+// valueClassName returns the Java name of the class of a value passed as an object, or
+// null for a null reference
+func valueClassName(value interface{}) string {
+	obj, ok := value.(*object.Object)
+	if !ok || obj == nil || obj.Klass == nil {
+		return "null value"
+	}
+	return javaName(*obj.Klass)
+}
@@ -6,6 +6,16 @@
 
 package classloader
 
+import (
+	"fmt"
+	"jacobin/object"
+	"jacobin/thread"
+	"jacobin/types"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
 /*
  Each object or library that has Go methods contains a reference to MethodSignatures,
  which contain data needed to insert the go method into the MTable of the currently
@@ -20,8 +30,24 @@ package classloader
 
  The passed-in slice contains one entry for every parameter passed to the method (which
  could mean an empty slice).
+
+ Java threads are mapped to goroutines, each of which runs its own interpreter loop
+ with its own frame stack. The state of a Thread object is kept in the fields the JDK
+ uses for it: name, target (the Runnable to run), daemon, and threadStatus, which is
+ non-zero once the thread has been started. A running thread is found by looking up
+ its Thread object in the thread table.
 */
 
+// StartThread runs the run() method of a Thread object on a new thread. Running Java
+// code requires the interpreter, which is in the jvm package, so jvm sets this function.
+var StartThread func(threadObj *object.Object) error
+
+// the number used in the name of the next thread that's not given a name, as in Thread-0
+var threadInitNumber int64
+
+// threadStatusStarted is the value of threadStatus once a thread has been started
+const threadStatusStarted int64 = 1
+
 func Load_Lang_Thread() map[string]GMeth {
 
 	MethodSignatures["java/lang/Thread.registerNatives()V"] =
@@ -30,5 +56,350 @@ func Load_Lang_Thread() map[string]GMeth {
 			GFunction:  justReturn,
 		}
 
+	MethodSignatures["java/lang/Thread.<init>()V"] =
+		GMeth{
+			ParamSlots: 1, // the Thread object
+			GFunction:  threadInit,
+		}
+
+	MethodSignatures["java/lang/Thread.<init>(Ljava/lang/Runnable;)V"] =
+		GMeth{
+			ParamSlots: 2, // the Thread object, the target
+			GFunction:  threadInitWithTarget,
+		}
+
+	MethodSignatures["java/lang/Thread.<init>(Ljava/lang/String;)V"] =
+		GMeth{
+			ParamSlots: 2, // the Thread object, the name
+			GFunction:  threadInitWithName,
+		}
+
+	MethodSignatures["java/lang/Thread.<init>(Ljava/lang/Runnable;Ljava/lang/String;)V"] =
+		GMeth{
+			ParamSlots: 3, // the Thread object, the target, the name
+			GFunction:  threadInitWithTargetAndName,
+		}
+
+	MethodSignatures["java/lang/Thread.start()V"] =
+		GMeth{
+			ParamSlots:  1,
+			GFunction:   threadStart,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/Thread.run()V"] = // runs only if neither a subclass nor a target provides run()
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  justReturn,
+		}
+
+	MethodSignatures["java/lang/Thread.join()V"] =
+		GMeth{
+			ParamSlots:  1,
+			GFunction:   threadJoin,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/Thread.join(J)V"] =
+		GMeth{
+			ParamSlots:  3, // the Thread object + 2 slots for the long
+			GFunction:   threadJoinWithTimeout,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/Thread.sleep(J)V"] =
+		GMeth{
+			ParamSlots:  2, // 2 slots for the long
+			GFunction:   threadSleep,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/Thread.yield()V"] =
+		GMeth{
+			ParamSlots: 0,
+			GFunction:  threadYield,
+		}
+
+	MethodSignatures["java/lang/Thread.currentThread()Ljava/lang/Thread;"] =
+		GMeth{
+			ParamSlots:  0,
+			GFunction:   currentThread,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/Thread.isAlive()Z"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  threadIsAlive,
+		}
+
+	MethodSignatures["java/lang/Thread.setDaemon(Z)V"] =
+		GMeth{
+			ParamSlots: 2, // the Thread object, the boolean
+			GFunction:  threadSetDaemon,
+		}
+
+	MethodSignatures["java/lang/Thread.isDaemon()Z"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  threadIsDaemon,
+		}
+
+	MethodSignatures["java/lang/Thread.getName()Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  threadGetName,
+		}
+
+	MethodSignatures["java/lang/Thread.interrupt()V"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  threadInterrupt,
+		}
+
+	MethodSignatures["java/lang/Thread.isInterrupted()Z"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  threadIsInterrupted,
+		}
+
+	MethodSignatures["java/lang/Thread.interrupted()Z"] = // static: tests and clears the current thread's status
+		GMeth{
+			ParamSlots:  0,
+			GFunction:   threadInterrupted,
+			NeedsThread: true,
+		}
+
 	return MethodSignatures
 }
+
+// NewThreadObject creates a Thread object with the given name and target, which can be
+// nil. This is done by the Thread constructors and for threads, such as the main thread,
+// that Java code did not create. If the Thread class is loaded, the object's fields are
+// laid out by it, otherwise they're kept in its FieldTable.
+func NewThreadObject(name string, target *object.Object) *object.Object {
+	className := "java/lang/Thread"
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	if MethAreaFetch(className) != nil {
+		_ = LayOutFields(obj, className)
+	}
+	initThreadObject(obj, target, name)
+	return obj
+}
+
+// initThreadObject sets the fields of a new Thread object. An empty name is replaced
+// by the next name in the series Thread-0, Thread-1, and so on.
+func initThreadObject(obj, target *object.Object, name string) {
+	if name == "" {
+		name = fmt.Sprintf("Thread-%d", atomic.AddInt64(&threadInitNumber, 1)-1)
+	}
+	SetObjectField(obj, "name", object.Field{Ftype: "Ljava/lang/String;",
+		Fvalue: object.CreateCompactStringFromGoString(&name)})
+	SetObjectField(obj, "target", object.Field{Ftype: "Ljava/lang/Runnable;", Fvalue: target})
+	SetObjectField(obj, "daemon", object.Field{Ftype: types.Bool, Fvalue: types.JavaBoolFalse})
+	SetObjectField(obj, "threadStatus", object.Field{Ftype: types.Int, Fvalue: int64(0)})
+}
+
+// threadField returns the value of the named field of a Thread object
+func threadField(obj *object.Object, name string) interface{} {
+	fld, _ := GetObjectField(obj, name)
+	return fld.Fvalue
+}
+
+// GetThreadName returns the name of a Thread object
+func GetThreadName(obj *object.Object) string {
+	name, ok := threadField(obj, "name").(*object.Object)
+	if !ok || name == nil {
+		return ""
+	}
+	return object.GetGoStringFromJavaStringPtr(name)
+}
+
+// GetThreadTarget returns the Runnable that a Thread object runs, or nil if it has none
+func GetThreadTarget(obj *object.Object) *object.Object {
+	target, _ := threadField(obj, "target").(*object.Object)
+	return target
+}
+
+// IsDaemonThread reports whether a Thread object has been marked as a daemon
+func IsDaemonThread(obj *object.Object) bool {
+	daemon, _ := threadField(obj, "daemon").(int64)
+	return daemon == types.JavaBoolTrue
+}
+
+// java/lang/Thread.<init>()V
+func threadInit(params []interface{}) interface{} {
+	initThreadObject(params[0].(*object.Object), nil, "")
+	return nil
+}
+
+// java/lang/Thread.<init>(Ljava/lang/Runnable;)V
+func threadInitWithTarget(params []interface{}) interface{} {
+	target, _ := params[1].(*object.Object)
+	initThreadObject(params[0].(*object.Object), target, "")
+	return nil
+}
+
+// java/lang/Thread.<init>(Ljava/lang/String;)V
+func threadInitWithName(params []interface{}) interface{} {
+	name, _ := params[1].(*object.Object)
+	if name == nil {
+		return &GErrBlk{ExceptionType: "java/lang/NullPointerException", ErrMsg: "name cannot be null"}
+	}
+	initThreadObject(params[0].(*object.Object), nil, object.GetGoStringFromJavaStringPtr(name))
+	return nil
+}
+
+// java/lang/Thread.<init>(Ljava/lang/Runnable;Ljava/lang/String;)V
+func threadInitWithTargetAndName(params []interface{}) interface{} {
+	target, _ := params[1].(*object.Object)
+	name, _ := params[2].(*object.Object)
+	if name == nil {
+		return &GErrBlk{ExceptionType: "java/lang/NullPointerException", ErrMsg: "name cannot be null"}
+	}
+	initThreadObject(params[0].(*object.Object), target, object.GetGoStringFromJavaStringPtr(name))
+	return nil
+}
+
+// java/lang/Thread.start()V starts the thread, which runs the run() method of the
+// Thread object or, if its class does not override run(), that of its target. A thread
+// can be started only once. As in the JDK, start() is synchronized on the Thread object,
+// so if threads call it at the same time, only one of them starts the thread.
+func threadStart(params []interface{}) interface{} {
+	obj := params[0].(*object.Object)
+	threadID := params[1].(int)
+	monitor := object.GetMonitor(obj)
+	monitor.Enter(threadID)
+	defer monitor.Exit(threadID)
+
+	if status, _ := threadField(obj, "threadStatus").(int64); status != 0 {
+		return &GErrBlk{ExceptionType: "java/lang/IllegalThreadStateException"}
+	}
+	SetObjectField(obj, "threadStatus", object.Field{Ftype: types.Int, Fvalue: threadStatusStarted})
+
+	if err := StartThread(obj); err != nil {
+		return &GErrBlk{ExceptionType: "java/lang/InternalError", ErrMsg: err.Error()}
+	}
+	return nil
+}
+
+// java/lang/Thread.join()V waits for the thread to end
+func threadJoin(params []interface{}) interface{} {
+	return joinThread(params[0].(*object.Object), 0, params[1].(int))
+}
+
+// java/lang/Thread.join(J)V waits at most the given number of milliseconds for the
+// thread to end. A timeout of 0 means to wait forever.
+func threadJoinWithTimeout(params []interface{}) interface{} {
+	millis := params[1].(int64)
+	if millis < 0 {
+		return &GErrBlk{ExceptionType: "java/lang/IllegalArgumentException",
+			ErrMsg: "timeout value is negative"}
+	}
+	return joinThread(params[0].(*object.Object), time.Duration(millis)*time.Millisecond, params[3].(int))
+}
+
+// joinThread blocks the calling thread until the thread whose Thread object is obj ends
+// or the timeout elapses. An InterruptedException is thrown if the caller is interrupted.
+func joinThread(obj *object.Object, timeout time.Duration, callerID int) interface{} {
+	other := thread.FindThreadByObject(obj)
+	if other == nil { // the thread was never started or has already ended
+		return nil
+	}
+
+	caller := thread.FindThread(callerID)
+	if caller == nil {
+		return nil
+	}
+	if !caller.Join(other, timeout) {
+		return &GErrBlk{ExceptionType: "java/lang/InterruptedException"}
+	}
+	return nil
+}
+
+// java/lang/Thread.sleep(J)V suspends the current thread for the given number of
+// milliseconds. An InterruptedException is thrown if the thread is interrupted.
+func threadSleep(params []interface{}) interface{} {
+	millis := params[0].(int64)
+	if millis < 0 {
+		return &GErrBlk{ExceptionType: "java/lang/IllegalArgumentException",
+			ErrMsg: "timeout value is negative"}
+	}
+
+	t := thread.FindThread(params[2].(int))
+	if t == nil {
+		time.Sleep(time.Duration(millis) * time.Millisecond)
+		return nil
+	}
+	if !t.Sleep(time.Duration(millis) * time.Millisecond) {
+		return &GErrBlk{ExceptionType: "java/lang/InterruptedException", ErrMsg: "sleep interrupted"}
+	}
+	return nil
+}
+
+// java/lang/Thread.yield()V lets other threads run
+func threadYield([]interface{}) interface{} {
+	runtime.Gosched()
+	return nil
+}
+
+// java/lang/Thread.currentThread()Ljava/lang/Thread; returns the Thread object of the
+// calling thread. The main thread's object is created the first time it's requested.
+func currentThread(params []interface{}) interface{} {
+	t := thread.FindThread(params[0].(int))
+	if t == nil {
+		return object.Null
+	}
+	if t.Object == nil {
+		t.Object = NewThreadObject("main", nil)
+		SetObjectField(t.Object, "threadStatus", object.Field{Ftype: types.Int, Fvalue: threadStatusStarted})
+	}
+	return t.Object
+}
+
+// java/lang/Thread.isAlive()Z: a thread is alive from when it's started until it ends
+func threadIsAlive(params []interface{}) interface{} {
+	return types.ConvertGoBoolToJavaBool(thread.FindThreadByObject(params[0].(*object.Object)) != nil)
+}
+
+// java/lang/Thread.setDaemon(Z)V can be called only before the thread is started
+func threadSetDaemon(params []interface{}) interface{} {
+	obj := params[0].(*object.Object)
+	if status, _ := threadField(obj, "threadStatus").(int64); status != 0 {
+		return &GErrBlk{ExceptionType: "java/lang/IllegalThreadStateException"}
+	}
+	SetObjectField(obj, "daemon", object.Field{Ftype: types.Bool, Fvalue: params[1].(int64) & 0x01})
+	return nil
+}
+
+// java/lang/Thread.isDaemon()Z
+func threadIsDaemon(params []interface{}) interface{} {
+	return types.ConvertGoBoolToJavaBool(IsDaemonThread(params[0].(*object.Object)))
+}
+
+// java/lang/Thread.getName()Ljava/lang/String;
+func threadGetName(params []interface{}) interface{} {
+	return threadField(params[0].(*object.Object), "name")
+}
+
+// java/lang/Thread.interrupt()V sets the thread's interrupt status and wakes it if it's
+// sleeping, waiting or joining. Interrupting a thread that is not alive has no effect.
+func threadInterrupt(params []interface{}) interface{} {
+	if t := thread.FindThreadByObject(params[0].(*object.Object)); t != nil {
+		t.Interrupt()
+	}
+	return nil
+}
+
+// java/lang/Thread.isInterrupted()Z tests the thread's interrupt status without clearing it
+func threadIsInterrupted(params []interface{}) interface{} {
+	t := thread.FindThreadByObject(params[0].(*object.Object))
+	return types.ConvertGoBoolToJavaBool(t != nil && t.IsInterrupted())
+}
+
+// java/lang/Thread.interrupted()Z tests and clears the current thread's interrupt status
+func threadInterrupted(params []interface{}) interface{} {
+	t := thread.FindThread(params[0].(int))
+	return types.ConvertGoBoolToJavaBool(t != nil && t.ClearInterrupt())
+}
@@ -0,0 +1,84 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"fmt"
+	"jacobin/object"
+	"os"
+)
+
+/*
+ The native methods of java.lang.Throwable that record and print the stack trace.
+ The JDK records the trace when the Throwable is created, by calling fillInStackTrace()
+ from its constructor. The trace is kept in the Throwable's backtrace field, as the
+ lines that printStackTrace() prints.
+*/
+
+// FillInStackTrace records the frames of the thread with the given ID in the throwable.
+// StackTraceOf returns the lines that printStackTrace() prints for the throwable. Both
+// need the frame stack and the interpreter, which are in the jvm package, so jvm sets them.
+var FillInStackTrace func(throwable *object.Object, threadID int)
+var StackTraceOf func(throwable *object.Object) []string
+
+func Load_Lang_Throwable() map[string]GMeth {
+
+	MethodSignatures["java/lang/Throwable.fillInStackTrace()Ljava/lang/Throwable;"] =
+		GMeth{
+			ParamSlots:  1, // the Throwable
+			GFunction:   throwableFillInStackTrace,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/Throwable.fillInStackTrace(I)Ljava/lang/Throwable;"] =
+		GMeth{
+			ParamSlots:  2, // the Throwable, a dummy int
+			GFunction:   throwableFillInStackTraceNative,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/Throwable.printStackTrace()V"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  throwablePrintStackTrace,
+		}
+
+	return MethodSignatures
+}
+
+// java/lang/Throwable.fillInStackTrace()Ljava/lang/Throwable; records the present
+// stack trace in the Throwable and returns it
+func throwableFillInStackTrace(params []interface{}) interface{} {
+	throwable := params[0].(*object.Object)
+	if FillInStackTrace != nil {
+		FillInStackTrace(throwable, params[1].(int))
+	}
+	return throwable
+}
+
+// java/lang/Throwable.fillInStackTrace(I)Ljava/lang/Throwable; is the private native
+// method that the JDK's fillInStackTrace() calls. The int is unused.
+func throwableFillInStackTraceNative(params []interface{}) interface{} {
+	throwable := params[0].(*object.Object)
+	if FillInStackTrace != nil {
+		FillInStackTrace(throwable, params[2].(int))
+	}
+	return throwable
+}
+
+// java/lang/Throwable.printStackTrace()V prints the Throwable, its stack trace, and
+// those of its causes to stderr
+func throwablePrintStackTrace(params []interface{}) interface{} {
+	throwable := params[0].(*object.Object)
+	if StackTraceOf == nil {
+		return nil
+	}
+	for _, line := range StackTraceOf(throwable) {
+		fmt.Fprintln(os.Stderr, line)
+	}
+	return nil
+}
@@ -12,10 +12,24 @@ import (
 	"jacobin/globals"
 	"jacobin/log"
 	"jacobin/object"
-	"jacobin/shutdown"
+	"jacobin/types"
+	"strings"
+	"sync"
 )
 
 // Implementation of some of the functions in in Java/lang/Class.
+//
+// Every class, interface, array class, and primitive type is represented in Java by a
+// java/lang/Class object, which is called its mirror. A mirror is created the first time
+// it's needed, and there is only one per class. Mirrors are identified by the class
+// name: java/lang/String, [I, or, for the primitive types, their Java names, such as int.
+
+// IsAssignableTo reports whether a reference to an object of class s can be assigned to
+// a reference of type t. InitializeClass initializes the named class on the thread with
+// the given ID, returning the exception to throw if initialization fails. Both need code
+// in the jvm package, so jvm sets them.
+var IsAssignableTo func(s, t string) bool
+var InitializeClass func(className string, threadID int) (*object.Object, error)
 
 func Load_Lang_Class() map[string]GMeth {
 
@@ -42,75 +56,353 @@ func Load_Lang_Class() map[string]GMeth {
 			ParamSlots: 0,
 			GFunction:  justReturn,
 		}
+
+	MethodSignatures["java/lang/Class.forName(Ljava/lang/String;)Ljava/lang/Class;"] =
+		GMeth{
+			ParamSlots:  1, // the class name
+			GFunction:   classForName,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/Class.forName(Ljava/lang/String;ZLjava/lang/ClassLoader;)Ljava/lang/Class;"] =
+		GMeth{
+			ParamSlots:  3, // the class name, whether to initialize the class, the class loader
+			GFunction:   classForNameWithLoader,
+			NeedsThread: true,
+		}
+
+	MethodSignatures["java/lang/Class.getName()Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 1, // the mirror
+			GFunction:  classGetName,
+		}
+
+	MethodSignatures["java/lang/Class.getSimpleName()Ljava/lang/String;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  classGetSimpleName,
+		}
+
+	MethodSignatures["java/lang/Class.getSuperclass()Ljava/lang/Class;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  classGetSuperclass,
+		}
+
+	MethodSignatures["java/lang/Class.getInterfaces()[Ljava/lang/Class;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  classGetInterfaces,
+		}
+
+	MethodSignatures["java/lang/Class.isInstance(Ljava/lang/Object;)Z"] =
+		GMeth{
+			ParamSlots: 2, // the mirror, the object
+			GFunction:  classIsInstance,
+		}
+
+	MethodSignatures["java/lang/Class.isArray()Z"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  classIsArray,
+		}
+
+	MethodSignatures["java/lang/Class.getComponentType()Ljava/lang/Class;"] =
+		GMeth{
+			ParamSlots: 1,
+			GFunction:  classGetComponentType,
+		}
+
+	MethodSignatures["java/lang/Class.isAssignableFrom(Ljava/lang/Class;)Z"] =
+		GMeth{
+			ParamSlots: 2, // the mirror, the mirror of the other class
+			GFunction:  classIsAssignableFrom,
+		}
 	return MethodSignatures
 }
 
+// the Java names of the primitive types, by their descriptors
+var primitiveClassNames = map[string]string{
+	types.Bool: "boolean", types.Byte: "byte", types.Char: "char", types.Short: "short",
+	types.Int: "int", types.Long: "long", types.Float: "float", types.Double: "double",
+	"V": "void",
+}
+
+// isPrimitiveClass reports whether the class name is the name of a primitive type, such as int
+func isPrimitiveClass(className string) bool {
+	for _, name := range primitiveClassNames {
+		if className == name {
+			return true
+		}
+	}
+	return false
+}
+
 // getPrimitiveClass() takes a one-word descriptor of a primitive and
-// returns  apointer to the native primitive class that corresponds to it.
+// returns the mirror of the primitive class that corresponds to it.
 // This duplicates the behavior of OpenJDK JVMs.
 func getPrimitiveClass(params []interface{}) interface{} {
 	primitive := params[0].(*object.Object)
 	str := object.GetGoStringFromJavaStringPtr(primitive)
 
-	var k *Klass
-	var err error
-	switch str {
-	case "boolean":
-		k, err = simpleClassLoadByName("java/lang/Boolean")
-	case "byte":
-		k, err = simpleClassLoadByName("java/lang/Byte")
-	case "char":
-		k, err = simpleClassLoadByName("java/lang/Character")
-	case "double":
-		k, err = simpleClassLoadByName("java/lang/Double")
-	case "float":
-		k, err = simpleClassLoadByName("java/lang/Float")
-	case "int":
-		k, err = simpleClassLoadByName("java/lang/Integer")
-	case "long":
-		k, err = simpleClassLoadByName("java/lang/Long")
-	case "short":
-		k, err = simpleClassLoadByName("java/lang/Short")
-	case "void":
-		k, err = simpleClassLoadByName("java/lang/Void")
-	default:
-		k = nil
-		err = errors.New("urecognized primitive")
-	}
-
-	if err == nil {
-		return k
-	} else {
+	if !isPrimitiveClass(str) {
 		errMsg := fmt.Sprintf("getPrimitiveClass() does not handle: %s", str)
 		_ = log.Log(errMsg, log.SEVERE)
 		return errors.New(errMsg)
 	}
+	return GetClassMirror(str)
 }
 
-// simpleClassLoadByName() just checks the MethodArea cache for the loaded
-// class, and if it's not there, it loads it and returns a pointer to it.
-// Logic basically duplicates similar functionality in instantiate.go
-func simpleClassLoadByName(className string) (*Klass, error) {
-	alreadyLoaded := MethAreaFetch(className)
-	if alreadyLoaded != nil { // if the class is already loaded, skip the rest of this
-		return alreadyLoaded, nil
+// the java/lang/Class objects (the mirrors) of the classes, by class name
+var classMirrors = make(map[string]*object.Object)
+var classMirrorsMutex sync.Mutex
+
+// ClassClassName is the class of the class mirrors
+var ClassClassName = "java/lang/Class"
+
+// GetClassMirror returns the java/lang/Class object that represents the named class,
+// interface, or array class, such as [I. There is only one mirror per class, so that
+// mirrors can be compared with ==. The mirror's name field holds the class name in
+// Java format (java.lang.String), as Class.getName() returns it.
+func GetClassMirror(className string) *object.Object {
+	classMirrorsMutex.Lock()
+	defer classMirrorsMutex.Unlock()
+
+	if mirror, ok := classMirrors[className]; ok {
+		return mirror
+	}
+
+	mirror := object.MakeEmptyObject()
+	mirror.Klass = &ClassClassName
+	if MethAreaFetch(ClassClassName) != nil {
+		_ = LayOutFields(mirror, ClassClassName)
+	}
+	name := javaName(className)
+	SetObjectField(mirror, "name", object.Field{Ftype: "Ljava/lang/String;",
+		Fvalue: object.CreateCompactStringFromGoString(&name)})
+
+	classMirrors[className] = mirror
+	return mirror
+}
+
+// MirrorClassName returns the name of the class that the mirror represents
+func MirrorClassName(mirror *object.Object) string {
+	fld, _ := GetObjectField(mirror, "name")
+	name, ok := fld.Fvalue.(*object.Object)
+	if !ok || name == nil {
+		return ""
 	}
+	return strings.ReplaceAll(object.GetGoStringFromJavaStringPtr(name), ".", "/")
+}
 
-	// If not, try to load class by name
-	err := LoadClassFromNameOnly(className)
+// LoadClassMirror returns the mirror of the named class, interface, or array class,
+// loading the class first if need be, as LDC does for a CONSTANT_Class entry. Loading
+// an array class loads the class of its elements. If the class can't be loaded, the
+// error is a *GErrBlk, usually for a NoClassDefFoundError.
+func LoadClassMirror(className string) (*object.Object, error) {
+	elementClass := strings.TrimLeft(className, types.Array)
+	if elementClass != className { // an array class
+		if _, ok := primitiveClassNames[elementClass]; ok && elementClass != "V" {
+			return GetClassMirror(className), nil
+		}
+		if !strings.HasPrefix(elementClass, types.Ref) || !strings.HasSuffix(elementClass, ";") {
+			return nil, &GErrBlk{ExceptionType: "java/lang/NoClassDefFoundError", ErrMsg: className}
+		}
+		elementClass = elementClass[1 : len(elementClass)-1]
+	}
+
+	if MethAreaFetch(elementClass) == nil {
+		if elementClass == "" || strings.ContainsAny(elementClass, ".;[") {
+			return nil, &GErrBlk{ExceptionType: "java/lang/NoClassDefFoundError", ErrMsg: elementClass}
+		}
+		if err := LoadClassFromNameOnly(elementClass); err != nil {
+			return nil, ClassLoadingError(elementClass, err)
+		}
+		if WaitForClassStatus(elementClass) != nil {
+			return nil, &GErrBlk{ExceptionType: "java/lang/NoClassDefFoundError", ErrMsg: elementClass}
+		}
+	}
+	return GetClassMirror(className), nil
+}
+
+// java/lang/Class.forName(Ljava/lang/String;)Ljava/lang/Class; loads and initializes
+// the named class and returns its mirror
+func classForName(params []interface{}) interface{} {
+	return forName(params[0], true, params[1].(int))
+}
+
+// java/lang/Class.forName(Ljava/lang/String;ZLjava/lang/ClassLoader;)Ljava/lang/Class;
+// is forName() that initializes the class only if asked to. Jacobin loads all classes
+// itself, so the class loader is ignored.
+func classForNameWithLoader(params []interface{}) interface{} {
+	return forName(params[0], params[1].(int64) == types.JavaBoolTrue, params[3].(int))
+}
+
+// forName loads the class whose name, in Java format, is in the string object
+// javaName and returns its mirror. The class is initialized on the thread threadID
+// if initialize is true. A class that can't be found throws a ClassNotFoundException.
+func forName(javaName interface{}, initialize bool, threadID int) interface{} {
+	nameObj, ok := javaName.(*object.Object)
+	if !ok || nameObj == nil {
+		return &GErrBlk{ExceptionType: "java/lang/NullPointerException"}
+	}
+	name := object.GetGoStringFromJavaStringPtr(nameObj)
+
+	className := strings.ReplaceAll(name, ".", "/")
+	if strings.Contains(name, "/") || isPrimitiveClass(className) {
+		return &GErrBlk{ExceptionType: "java/lang/ClassNotFoundException", ErrMsg: name}
+	}
+	mirror, err := LoadClassMirror(className)
 	if err != nil {
-		var errClassName = className
-		if className == "" {
-			errClassName = "<empty string>"
+		return &GErrBlk{ExceptionType: "java/lang/ClassNotFoundException", ErrMsg: name}
+	}
+
+	if initialize && !strings.HasPrefix(className, types.Array) && InitializeClass != nil {
+		exc, err := InitializeClass(className, threadID)
+		if err != nil { // the JVM failed, and the error will have been logged
+			return &GErrBlk{ExceptionType: "java/lang/InternalError", ErrMsg: err.Error()}
 		}
-		errMsg := "instantiateClass()-getPrimitivelass(): Failed to load class " + errClassName
-		_ = log.Log(errMsg, log.SEVERE)
-		_ = log.Log(err.Error(), log.SEVERE)
-		shutdown.Exit(shutdown.APP_EXCEPTION)
-		return nil, errors.New(errMsg) // needed for testing, which does not shutdown on failure
-	} else {
-		return MethAreaFetch(className), nil
+		if exc != nil {
+			return &GErrBlk{ExceptionType: *exc.Klass, Exception: exc}
+		}
+	}
+	return mirror
+}
+
+// java/lang/Class.getName()Ljava/lang/String; returns the name of the class in Java
+// format, such as java.lang.String, [I, or [Ljava.lang.String;
+func classGetName(params []interface{}) interface{} {
+	fld, _ := GetObjectField(params[0].(*object.Object), "name")
+	return fld.Fvalue
+}
+
+// java/lang/Class.getSimpleName()Ljava/lang/String; returns the name of the class as
+// it's written in the source code, such as String or int[]
+func classGetSimpleName(params []interface{}) interface{} {
+	name := simpleName(MirrorClassName(params[0].(*object.Object)))
+	return object.CreateCompactStringFromGoString(&name)
+}
+
+// simpleName returns the simple name of the named class. The name of a nested class
+// follows the last $ of its class name, after the digits of a local class, such as
+// Outer$1Local. Anonymous classes, such as Outer$1, have no simple name.
+func simpleName(className string) string {
+	if strings.HasPrefix(className, types.Array) {
+		return simpleName(componentClassName(className)) + "[]"
+	}
+
+	name := className[strings.LastIndex(className, "/")+1:]
+	if i := strings.LastIndex(name, "$"); i >= 0 {
+		name = strings.TrimLeft(name[i+1:], "0123456789")
+	}
+	return name
+}
+
+// componentClassName returns the name of the class of the components of the named
+// array class: the component class of [[I is [I, and that of [I is int.
+func componentClassName(arrayClassName string) string {
+	return descriptorClassName(arrayClassName[1:])
+}
+
+// descriptorClassName returns the name of the class that a field descriptor denotes:
+// int for I, java/lang/String for Ljava/lang/String;, and [I for [I
+func descriptorClassName(desc string) string {
+	if primitive, ok := primitiveClassNames[desc]; ok {
+		return primitive
+	}
+	if strings.HasPrefix(desc, types.Ref) {
+		return strings.TrimSuffix(desc[1:], ";")
+	}
+	return desc
+}
+
+// java/lang/Class.getSuperclass()Ljava/lang/Class; returns the mirror of the superclass.
+// The superclass of an array class is Object. Object, the interfaces, and the primitive
+// types have none, so null is returned for them.
+func classGetSuperclass(params []interface{}) interface{} {
+	className := MirrorClassName(params[0].(*object.Object))
+	if strings.HasPrefix(className, types.Array) {
+		return GetClassMirror("java/lang/Object")
+	}
+	if className == "java/lang/Object" || isPrimitiveClass(className) {
+		return object.Null
+	}
+
+	k, err := fetchLoadedClass(className)
+	if err != nil || k.Data.Access.ClassIsInterface || k.Data.Superclass == "" {
+		return object.Null
+	}
+	return GetClassMirror(k.Data.Superclass)
+}
+
+// java/lang/Class.getInterfaces()[Ljava/lang/Class; returns the mirrors of the interfaces
+// that the class directly implements, or that the interface directly extends, in the
+// order in which they're declared. Every array class implements Cloneable and Serializable.
+func classGetInterfaces(params []interface{}) interface{} {
+	className := MirrorClassName(params[0].(*object.Object))
+
+	var interfaces []string
+	if strings.HasPrefix(className, types.Array) {
+		interfaces = []string{"java/lang/Cloneable", "java/io/Serializable"}
+	} else if !isPrimitiveClass(className) {
+		if k, err := fetchLoadedClass(className); err == nil {
+			interfaces = GetSuperinterfaces(k)
+		}
+	}
+
+	array := object.MakeArray("[Ljava/lang/Class;", int64(len(interfaces)))
+	mirrors := *array.Fields[0].Fvalue.(*[]*object.Object)
+	for i, iface := range interfaces {
+		mirrors[i] = GetClassMirror(iface)
+	}
+	return array
+}
+
+// java/lang/Class.isInstance(Ljava/lang/Object;)Z reports whether the object is an
+// instance of the class, as the instanceof operator does. It's false for null.
+func classIsInstance(params []interface{}) interface{} {
+	className := MirrorClassName(params[0].(*object.Object))
+	obj, ok := params[1].(*object.Object)
+	if !ok || obj == nil || obj.Klass == nil || isPrimitiveClass(className) {
+		return types.JavaBoolFalse
+	}
+	return types.ConvertGoBoolToJavaBool(IsAssignableTo(*obj.Klass, className))
+}
+
+// java/lang/Class.isArray()Z reports whether the class is an array class
+func classIsArray(params []interface{}) interface{} {
+	className := MirrorClassName(params[0].(*object.Object))
+	return types.ConvertGoBoolToJavaBool(strings.HasPrefix(className, types.Array))
+}
+
+// java/lang/Class.getComponentType()Ljava/lang/Class; returns the mirror of the class
+// of an array's components, or null if the class is not an array class
+func classGetComponentType(params []interface{}) interface{} {
+	className := MirrorClassName(params[0].(*object.Object))
+	if !strings.HasPrefix(className, types.Array) {
+		return object.Null
+	}
+	return GetClassMirror(componentClassName(className))
+}
+
+// java/lang/Class.isAssignableFrom(Ljava/lang/Class;)Z reports whether a reference to
+// an object of the other class can be assigned to a reference of this class. A primitive
+// type is assignable only from itself.
+func classIsAssignableFrom(params []interface{}) interface{} {
+	mirror := params[0].(*object.Object)
+	other, ok := params[1].(*object.Object)
+	if !ok || other == nil {
+		return &GErrBlk{ExceptionType: "java/lang/NullPointerException"}
+	}
+	if mirror == other {
+		return types.JavaBoolTrue
+	}
+
+	className, otherName := MirrorClassName(mirror), MirrorClassName(other)
+	if isPrimitiveClass(className) || isPrimitiveClass(otherName) {
+		return types.JavaBoolFalse
 	}
+	return types.ConvertGoBoolToJavaBool(IsAssignableTo(otherName, className))
 }
 
 // returns boolean indicating whether assertions are enabled or not.
@@ -64,6 +64,14 @@ func JmodMapFetch(className string) string {
 	return jmodFile
 }
 
+// isJdkClass reports whether the named class is one of the JDK's, which are the classes
+// in its jmod files
+func isJdkClass(className string) bool {
+	jmodMapMutex.Lock()
+	defer jmodMapMutex.Unlock()
+	return JMODMAP[className+".class"] != ""
+}
+
 // This function returns the number of entries in JMODMAP.
 func JmodMapSize() int {
 	return jmodMapSize
@@ -7,6 +7,8 @@
 package classloader
 
 import (
+	"jacobin/object"
+	"strings"
 	"sync"
 )
 
@@ -43,8 +45,25 @@ type MData interface{}
 // Fu is a go function. All go functions accept a possibly empty slice of interface{} and
 // return a possibly nil interface{}
 type GmEntry struct {
-	ParamSlots int
-	Fu         func([]interface{}) interface{}
+	ParamSlots  int
+	Fu          func([]interface{}) interface{}
+	NeedsThread bool   // if true, the ID of the calling thread is passed to Fu after the arguments
+	ClName      string // the class that declares the method
+}
+
+// GErrBlk is returned by a Go function to throw a Java exception in the method that
+// called it. ExceptionType is the name of the exception's class, such as
+// java/lang/InterruptedException, and ErrMsg is its detail message, if any. A Go
+// function that runs Java code can instead rethrow the exception object that the Java
+// code threw, which is then in Exception.
+type GErrBlk struct {
+	ExceptionType string
+	ErrMsg        string
+	Exception     *object.Object
+}
+
+func (e *GErrBlk) Error() string {
+	return e.ExceptionType + ": " + e.ErrMsg
 }
 
 // JmEntry is the entry in the Mtable for Java methods.
@@ -53,11 +72,13 @@ type JmEntry struct {
 	MaxStack    int
 	MaxLocals   int
 	Code        []byte
-	exceptions  []CodeException
+	Exceptions  []CodeException
+	LineNumbers []LineNumber
 	attribs     []Attr
 	params      []ParamAttrib
 	deprecated  bool
 	Cp          *CPool
+	ClName      string // the class that declares the method
 }
 
 // Function is the generic-style function used for Go entries: a function that accepts a
@@ -65,23 +86,40 @@ type JmEntry struct {
 // stack rather than actually returned to a caller).
 type Function func([]interface{}) interface{}
 
-// MTmutex is used for updates to the MTable because multiple threads could be
+// MTmutex is used for access to the MTable because multiple threads could be
 // updating it simultaneously.
-var MTmutex sync.Mutex
+var MTmutex sync.RWMutex
+
+// MTableFetch returns the MTable entry for a method, given its fully qualified name
+// and type. If there is no entry, the returned entry's Meth is nil.
+func MTableFetch(key string) MTentry {
+	MTmutex.RLock()
+	defer MTmutex.RUnlock()
+	return MTable[key]
+}
+
+// MTableInsert adds an entry to the MTable, or replaces an existing one
+func MTableInsert(key string, entry MTentry) {
+	addEntry(&MTable, key, entry)
+}
 
 // MTableLoadNatives loads the Go methods from files that contain them. It does this
 // by calling the Load_* function in each of those files to load whatever Go functions
 // they make available.
 func MTableLoadNatives() {
-	loadlib(&MTable, Load_Io_PrintStream()) // load the java.io.prinstream golang functions
-	loadlib(&MTable, Load_Lang_Class())     // load the java.lang.Class golang functions
-	loadlib(&MTable, Load_Lang_Math())      // load the java.lang.Math golang functions
-	loadlib(&MTable, Load_Misc_Unsafe())    // load the jdk.internal/misc/Unsafe functions
-	loadlib(&MTable, Load_Lang_String())    // load the java.lang.String golang functions
-	loadlib(&MTable, Load_Lang_System())    // load the java.lang.System golang functions
-	loadlib(&MTable, Load_Lang_Thread())    // load the java.lang.Thread golang functions
-	loadlib(&MTable, Load_Lang_UTF16())     // load the java.lang.UTF16 golang functions
-	loadlib(&MTable, Load_Util_HashMap())   // load the java.util.HashMap golang functions
+	loadlib(&MTable, Load_Io_PrintStream())  // load the java.io.prinstream golang functions
+	loadlib(&MTable, Load_Lang_Annotation()) // load the annotation functions of java.lang.reflect
+	loadlib(&MTable, Load_Lang_Class())      // load the java.lang.Class golang functions
+	loadlib(&MTable, Load_Lang_Math())       // load the java.lang.Math golang functions
+	loadlib(&MTable, Load_Misc_Unsafe())     // load the jdk.internal/misc/Unsafe functions
+	loadlib(&MTable, Load_Lang_Object())     // load the java.lang.Object golang functions
+	loadlib(&MTable, Load_Lang_Reflect())    // load the java.lang.reflect golang functions
+	loadlib(&MTable, Load_Lang_String())     // load the java.lang.String golang functions
+	loadlib(&MTable, Load_Lang_System())     // load the java.lang.System golang functions
+	loadlib(&MTable, Load_Lang_Thread())     // load the java.lang.Thread golang functions
+	loadlib(&MTable, Load_Lang_Throwable())  // load the java.lang.Throwable golang functions
+	loadlib(&MTable, Load_Lang_UTF16())      // load the java.lang.UTF16 golang functions
+	loadlib(&MTable, Load_Util_HashMap())    // load the java.util.HashMap golang functions
 }
 
 func loadlib(tbl *MT, libMeths map[string]GMeth) {
@@ -89,6 +127,12 @@ func loadlib(tbl *MT, libMeths map[string]GMeth) {
 		gme := GmEntry{}
 		gme.ParamSlots = val.ParamSlots
 		gme.Fu = val.GFunction
+		gme.NeedsThread = val.NeedsThread
+		if paren := strings.Index(key, "("); paren > 0 { // the key is class.name(params)return
+			if dot := strings.LastIndex(key[:paren], "."); dot > 0 {
+				gme.ClName = key[:dot]
+			}
+		}
 
 		tableEntry := MTentry{
 			MType: 'G',
@@ -101,19 +101,18 @@ func InitMethodArea() {
 // MethAreaPreload preloads the synthetic entries for array types into
 // the method area.
 func MethAreaPreload() {
-	emptyKlass := Klass{
-		Status: 'N', // N = instantiated
-		Loader: "bootstrap",
-		Data:   &ClData{Superclass: "java/lang/Object"}, // empty class info
-	}
 	classesToPreload := []string{
-		types.ByteArray, types.FloatArray, types.IntArray,
+		types.BoolArray, types.ByteArray, types.CharArray, types.DoubleArray,
+		types.FloatArray, types.IntArray, types.LongArray, types.ShortArray,
 		types.RefArray, types.RuneArray,
 	}
 
 	for _, x := range classesToPreload {
-		k := emptyKlass
-		k.Data.Name = x
+		emptyKlass := Klass{
+			Status: 'N', // N = instantiated
+			Loader: "bootstrap",
+			Data:   &ClData{Name: x, Superclass: "java/lang/Object"}, // empty class info
+		}
 		MethAreaInsert(x, &emptyKlass)
 	}
 }
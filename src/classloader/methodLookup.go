@@ -0,0 +1,245 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+)
+
+// Method access flags used in method lookups and invocations (see JVMS Table 4.6-A)
+const (
+	AccPublic       = 0x0001
+	AccPrivate      = 0x0002
+	AccStatic       = 0x0008
+	AccFinal        = 0x0010
+	AccSynchronized = 0x0020
+	AccNative       = 0x0100
+	AccAbstract     = 0x0400
+)
+
+// The functions in this file search the class hierarchy for methods. They're used
+// principally in the resolution and selection of methods invoked via INVOKEVIRTUAL and
+// INVOKEINTERFACE, which must look at superclasses and superinterfaces of the receiver's class.
+
+// fetchLoadedClass returns the named class, loading it first if necessary.
+func fetchLoadedClass(className string) (*Klass, error) {
+	k := MethAreaFetch(className)
+	if k == nil {
+		if err := LoadClassFromNameOnly(className); err != nil {
+			return nil, err
+		}
+		if err := WaitForClassStatus(className); err != nil {
+			return nil, err
+		}
+		k = MethAreaFetch(className)
+	}
+
+	if k == nil || k.Data == nil {
+		return nil, errors.New("fetchLoadedClass: could not load class " + className)
+	}
+	return k, nil
+}
+
+// ClassLoadingError returns the error to throw when the named class could not be loaded
+// for the reason err: the LinkageError that loading the class raised, such as a VerifyError,
+// or else a NoClassDefFoundError. Either is a *GErrBlk.
+func ClassLoadingError(className string, err error) error {
+	var errBlk *GErrBlk
+	if errors.As(err, &errBlk) {
+		return errBlk
+	}
+	return &GErrBlk{ExceptionType: "java/lang/NoClassDefFoundError", ErrMsg: className}
+}
+
+// IsInterface reports whether the named class is an interface. Returns false if
+// the class cannot be loaded.
+func IsInterface(className string) bool {
+	k, err := fetchLoadedClass(className)
+	if err != nil {
+		return false
+	}
+	return k.Data.Access.ClassIsInterface
+}
+
+// GetSuperinterfaces returns the names of the interfaces directly implemented
+// by a class or directly extended by an interface.
+func GetSuperinterfaces(k *Klass) []string {
+	var names []string
+	for _, utf8Index := range k.Data.Interfaces {
+		if int(utf8Index) < len(k.Data.CP.Utf8Refs) {
+			names = append(names, k.Data.CP.Utf8Refs[utf8Index])
+		}
+	}
+	return names
+}
+
+// ImplementsInterface reports whether the named class or interface is, extends or
+// implements the named interface, either directly or through its superclasses and
+// superinterfaces.
+func ImplementsInterface(className, interfaceName string) bool {
+	visited := make(map[string]bool)
+	var search func(name string) bool
+	search = func(name string) bool {
+		if name == "" || visited[name] {
+			return false
+		}
+		visited[name] = true
+
+		if name == interfaceName {
+			return true
+		}
+
+		k, err := fetchLoadedClass(name)
+		if err != nil {
+			return false
+		}
+
+		for _, iface := range GetSuperinterfaces(k) {
+			if search(iface) {
+				return true
+			}
+		}
+		return search(k.Data.Superclass)
+	}
+	return search(className)
+}
+
+// FindInstanceMethodInSuperclasses looks for an instance method with the given name
+// and type in the named class and then up the chain of its superclasses. Static
+// and private methods are skipped, as they can't override an inherited method.
+// Returns the name of the class that declares the method and the method, or ""
+// and nil if no such method is found.
+func FindInstanceMethodInSuperclasses(className, methName, methType string) (string, *Method) {
+	for className != "" {
+		k, err := fetchLoadedClass(className)
+		if err != nil {
+			return "", nil
+		}
+
+		m, ok := k.Data.MethodTable[methName+methType]
+		if ok && m.AccessFlags&(AccStatic|AccPrivate) == 0 {
+			return className, m
+		}
+		className = k.Data.Superclass
+	}
+	return "", nil
+}
+
+// FindNonOverridableMethod returns the private or final method with the given name and
+// type that the named class declares, which INVOKEVIRTUAL invokes as resolved rather than
+// selecting a method by the class of the receiver. Returns nil if the class declares no
+// such method, as well as for methods implemented in golang, which are selected from the
+// receiver's class. If the class can't be loaded, the error is a *GErrBlk.
+func FindNonOverridableMethod(className, methName, methType string) (*Method, error) {
+	if entry := MTableFetch(className + "." + methName + methType); entry.Meth != nil && entry.MType == 'G' {
+		return nil, nil
+	}
+
+	k, err := fetchLoadedClass(className)
+	if err != nil {
+		return nil, ClassLoadingError(className, err)
+	}
+	m, ok := k.Data.MethodTable[methName+methType]
+	if ok && m.AccessFlags&(AccPrivate|AccFinal) != 0 {
+		return m, nil
+	}
+	return nil, nil
+}
+
+// FindMethodForReceiver selects the instance method with the given name and type that
+// is executed for an object of the named class: it's the first such method found in
+// the class or up the chain of its superclasses. Unlike FindInstanceMethodInSuperclasses,
+// it also finds methods implemented in golang, which are in the MTable rather than in the
+// method table of their class. Returns the name of the class that declares the method
+// and the method's MTable entry, or "" and an empty entry if no such method is found.
+func FindMethodForReceiver(className, methName, methType string) (string, MTentry) {
+	for className != "" {
+		if entry := MTableFetch(className + "." + methName + methType); entry.Meth != nil && entry.MType == 'G' {
+			return className, entry
+		}
+
+		k, err := fetchLoadedClass(className)
+		if err != nil {
+			return "", MTentry{}
+		}
+
+		m, ok := k.Data.MethodTable[methName+methType]
+		if ok && m.AccessFlags&(AccStatic|AccPrivate) == 0 {
+			entry, err := FetchMethodAndCP(className, methName, methType)
+			if err != nil || entry.Meth == nil {
+				return "", MTentry{}
+			}
+			return className, entry
+		}
+
+		if className == "java/lang/Object" { // the topmost superclass
+			break
+		}
+		className = k.Data.Superclass
+	}
+	return "", MTentry{}
+}
+
+// FindMaxSpecificInterfaceMethods returns the names of the interfaces that declare
+// the maximally-specific superinterface methods (JVMS 5.4.3.3) of the named class
+// that match the given method name and type. A method is maximally specific if no
+// other matching method is declared in one of the subinterfaces of its interface.
+// Static and private interface methods are not candidates. The returned slice
+// can contain abstract methods. The caller decides what to do with them.
+func FindMaxSpecificInterfaceMethods(className, methName, methType string) []string {
+	// gather all the superinterfaces of the class, including those of its superclasses
+	var interfaces []string
+	visited := make(map[string]bool)
+	var gather func(name string, isInterface bool)
+	gather = func(name string, isInterface bool) {
+		if name == "" || visited[name] {
+			return
+		}
+		visited[name] = true
+
+		k, err := fetchLoadedClass(name)
+		if err != nil {
+			return
+		}
+		if isInterface {
+			interfaces = append(interfaces, name)
+		}
+		for _, iface := range GetSuperinterfaces(k) {
+			gather(iface, true)
+		}
+		if !isInterface {
+			gather(k.Data.Superclass, false)
+		}
+	}
+	gather(className, IsInterface(className))
+
+	// find the interfaces that declare a matching method
+	var candidates []string
+	for _, iface := range interfaces {
+		k := MethAreaFetch(iface)
+		m, ok := k.Data.MethodTable[methName+methType]
+		if ok && m.AccessFlags&(AccStatic|AccPrivate) == 0 {
+			candidates = append(candidates, iface)
+		}
+	}
+
+	// keep only the candidates whose interface is not extended by another candidate
+	var maxSpecific []string
+	for _, c := range candidates {
+		isMax := true
+		for _, other := range candidates {
+			if other != c && ImplementsInterface(other, c) {
+				isMax = false
+				break
+			}
+		}
+		if isMax {
+			maxSpecific = append(maxSpecific, c)
+		}
+	}
+	return maxSpecific
+}
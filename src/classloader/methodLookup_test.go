@@ -0,0 +1,165 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"jacobin/globals"
+	"jacobin/log"
+	"testing"
+)
+
+// inserts a class into the method area. The class extends superclass and implements
+// (or if it's an interface, extends) the interfaces in ifaces.
+func insertLookupTestClass(name, superclass string, isInterface bool,
+	meths map[string]*Method, ifaces ...string) {
+	data := ClData{Name: name, Superclass: superclass, MethodTable: meths}
+	data.Access.ClassIsInterface = isInterface
+	for i, iface := range ifaces {
+		data.CP.Utf8Refs = append(data.CP.Utf8Refs, iface)
+		data.Interfaces = append(data.Interfaces, uint16(i))
+	}
+	MethAreaInsert(name, &Klass{Status: 'X', Loader: "bootstrap", Data: &data})
+}
+
+// sets up the following hierarchy, in which A declares a default method m(), which
+// B overrides with a default method and C redeclares as abstract.
+//
+//	interface A { default void m() }
+//	interface B extends A { default void m() }
+//	interface C extends A { abstract void m() }
+//	class Base implements B
+//	class Sub extends Base implements C
+func setupLookupTestHierarchy() {
+	globals.InitGlobals("test")
+	log.Init()
+	InitMethodArea()
+
+	dflt := &Method{AccessFlags: AccPublic}
+	abstract := &Method{AccessFlags: AccPublic | AccAbstract}
+	static := &Method{AccessFlags: AccPublic | AccStatic}
+
+	insertLookupTestClass("java/lang/Object", "", false, map[string]*Method{})
+	insertLookupTestClass("A", "java/lang/Object", true, map[string]*Method{"m()V": dflt})
+	insertLookupTestClass("B", "java/lang/Object", true, map[string]*Method{"m()V": dflt}, "A")
+	insertLookupTestClass("C", "java/lang/Object", true, map[string]*Method{"m()V": abstract}, "A")
+	insertLookupTestClass("Base", "java/lang/Object", false, map[string]*Method{"s()V": static}, "B")
+	insertLookupTestClass("Sub", "Base", false, map[string]*Method{}, "C")
+}
+
+func TestImplementsInterface(t *testing.T) {
+	setupLookupTestHierarchy()
+
+	if !ImplementsInterface("Sub", "A") {
+		t.Errorf("Expected Sub to implement A through its superinterfaces")
+	}
+	if !ImplementsInterface("Sub", "B") {
+		t.Errorf("Expected Sub to implement B through its superclass")
+	}
+	if ImplementsInterface("Base", "C") {
+		t.Errorf("Did not expect Base to implement C")
+	}
+	if !ImplementsInterface("B", "A") {
+		t.Errorf("Expected interface B to extend A")
+	}
+}
+
+func TestFindMaxSpecificInterfaceMethods(t *testing.T) {
+	setupLookupTestHierarchy()
+
+	// A.m() is overridden by both B.m() and C.m(), so those are the maximally-specific methods
+	ifaces := FindMaxSpecificInterfaceMethods("Sub", "m", "()V")
+	if len(ifaces) != 2 {
+		t.Fatalf("Expected 2 maximally-specific methods, got: %v", ifaces)
+	}
+	for _, iface := range ifaces {
+		if iface != "B" && iface != "C" {
+			t.Errorf("Expected maximally-specific methods in B and C, got one in: %s", iface)
+		}
+	}
+
+	ifaces = FindMaxSpecificInterfaceMethods("Base", "m", "()V")
+	if len(ifaces) != 1 || ifaces[0] != "B" {
+		t.Errorf("Expected a single maximally-specific method in B, got: %v", ifaces)
+	}
+}
+
+func TestFindInstanceMethodInSuperclasses(t *testing.T) {
+	setupLookupTestHierarchy()
+
+	// static methods are not instance methods, so they're not found
+	className, m := FindInstanceMethodInSuperclasses("Sub", "s", "()V")
+	if m != nil {
+		t.Errorf("Did not expect to find static method s() as an instance method, found it in %s", className)
+	}
+
+	MethAreaFetch("Base").Data.MethodTable["i()V"] = &Method{AccessFlags: AccPublic}
+	className, m = FindInstanceMethodInSuperclasses("Sub", "i", "()V")
+	if m == nil || className != "Base" {
+		t.Errorf("Expected to find i() in superclass Base, got: %s", className)
+	}
+}
+
+func TestFindMethodForReceiver(t *testing.T) {
+	setupLookupTestHierarchy()
+	for _, key := range []string{"Base.i()V", "Base.g()V", "Sub.i()V"} {
+		delete(MTable, key) // remove methods cached by previous tests
+	}
+
+	MethAreaFetch("Base").Data.MethodTable["i()V"] = &Method{AccessFlags: AccPublic}
+	className, entry := FindMethodForReceiver("Sub", "i", "()V")
+	if className != "Base" || entry.MType != 'J' {
+		t.Errorf("Expected to find Java method i() in superclass Base, got: %s", className)
+	}
+
+	// methods implemented in golang are found in the MTable
+	MTable["Base.g()V"] = MTentry{Meth: GMeth{ParamSlots: 0}, MType: 'G'}
+	className, entry = FindMethodForReceiver("Sub", "g", "()V")
+	if className != "Base" || entry.MType != 'G' {
+		t.Errorf("Expected to find golang method g() in superclass Base, got: %s", className)
+	}
+
+	// private methods are not inherited
+	MethAreaFetch("Base").Data.MethodTable["p()V"] = &Method{AccessFlags: AccPrivate}
+	className, _ = FindMethodForReceiver("Sub", "p", "()V")
+	if className != "" {
+		t.Errorf("Did not expect to find private method p(), found it in %s", className)
+	}
+}
+
+func TestFetchMethodAndCPInheritedMethod(t *testing.T) {
+	setupLookupTestHierarchy()
+	delete(MTable, "Sub.i()V") // remove any method cached by previous tests
+
+	MethAreaFetch("Base").Data.MethodTable["i()V"] = &Method{AccessFlags: AccPublic}
+	entry, err := FetchMethodAndCP("Sub", "i", "()V")
+	if err != nil {
+		t.Fatalf("Expected to find i() in superclass Base, got error: %s", err.Error())
+	}
+	if entry.Meth.(JmEntry).ClName != "Base" {
+		t.Errorf("Expected i() to be declared in Base, got: %s", entry.Meth.(JmEntry).ClName)
+	}
+	if MTable["Sub.i()V"].Meth == nil {
+		t.Errorf("Expected the inherited method to be cached in the MTable as Sub.i()V")
+	}
+
+	// private methods are found, but not cached under the subclass, where INVOKEVIRTUAL
+	// would select them
+	delete(MTable, "Sub.p()V")
+	MethAreaFetch("Base").Data.MethodTable["p()V"] = &Method{AccessFlags: AccPrivate}
+	if entry, err = FetchMethodAndCP("Sub", "p", "()V"); err != nil || entry.Meth == nil {
+		t.Errorf("Expected to find p() in superclass Base, got error: %v", err)
+	}
+	if MTable["Sub.p()V"].Meth != nil {
+		t.Errorf("Did not expect the private method to be cached in the MTable as Sub.p()V")
+	}
+
+	// initializers are not inherited
+	MethAreaFetch("Base").Data.MethodTable["<clinit>()V"] = &Method{AccessFlags: AccStatic}
+	if _, err = FetchMethodAndCP("Sub", "<clinit>", "()V"); err == nil {
+		t.Errorf("Did not expect Sub to inherit the <clinit>() of Base")
+	}
+}
@@ -108,6 +108,13 @@ func parseMethods(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 					if parseMethodParametersAttribute(attrib, &meth, klass) != nil {
 						return pos, cfe("") // error msg will already have been shown to user
 					}
+				case "Signature":
+					log.Log("    Attribute: Signature", log.FINEST)
+					meth.signature, err5 = fetchSignature(klass, attrib)
+					if err5 != nil {
+						return pos, cfe("Invalid Signature attribute in method: " +
+							klass.utf8Refs[nameSlot].content)
+					}
 				default:
 					log.Log("    Attribute: "+klass.utf8Refs[attrib.attrName].content, log.FINEST)
 				}
@@ -211,6 +218,13 @@ func parseCodeAttribute(att attr, meth *method, klass *ParsedClass) error {
 			}
 			pos = loc
 			log.Log("        "+klass.utf8Refs[cat.attrName].content, log.FINEST)
+			if klass.utf8Refs[cat.attrName].content == "LineNumberTable" {
+				lines, err3 := parseLineNumberTable(cat, codeLength, methodName, klass)
+				if err3 != nil {
+					return err3
+				}
+				ca.lineNumbers = append(ca.lineNumbers, lines...)
+			}
 			ca.attributes = append(ca.attributes, cat)
 		}
 	}
@@ -223,6 +237,51 @@ func parseCodeAttribute(att attr, meth *method, klass *ParsedClass) error {
 	return nil
 }
 
+// The LineNumberTable attribute of a Code attribute maps bytecodes to the lines of the
+// source file. A method can have more than one, in which case their entries are combined.
+// See: https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.12
+//
+//	LineNumberTable_attribute {
+//	    u2 attribute_name_index;
+//	    u4 attribute_length;
+//	    u2 line_number_table_length;
+//	    {   u2 start_pc;
+//	        u2 line_number;
+//	    } line_number_table[line_number_table_length];
+//	}
+func parseLineNumberTable(att attr, codeLength int, methodName string, klass *ParsedClass) ([]lineNumber, error) {
+	var lines []lineNumber
+	pos := -1
+	tableLength, err := intFrom2Bytes(att.attrContent, pos+1)
+	pos += 2
+	if err != nil {
+		return nil, cfe("Error getting length of LineNumberTable in method " + methodName +
+			"() of " + klass.className)
+	}
+
+	for i := 0; i < tableLength; i++ {
+		startPc, err := intFrom2Bytes(att.attrContent, pos+1)
+		pos += 2
+		if err != nil {
+			return nil, cfe("Error getting start_pc of LineNumberTable entry #" + strconv.Itoa(i+1) +
+				" in method " + methodName + "() of " + klass.className)
+		}
+		if startPc >= codeLength {
+			return nil, cfe("Invalid start_pc " + strconv.Itoa(startPc) + " in LineNumberTable of method " +
+				methodName + "() of " + klass.className)
+		}
+
+		line, err := intFrom2Bytes(att.attrContent, pos+1)
+		pos += 2
+		if err != nil {
+			return nil, cfe("Error getting line number of LineNumberTable entry #" + strconv.Itoa(i+1) +
+				" in method " + methodName + "() of " + klass.className)
+		}
+		lines = append(lines, lineNumber{startPc: startPc, line: line})
+	}
+	return lines, nil
+}
+
 // The Exceptions attribute of a method indicates which checked exceptions a method
 // can throw. See: https://docs.oracle.com/javase/specs/jvms/se11/html/jvms-4.html#jvms-4.7.5
 //
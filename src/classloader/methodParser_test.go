@@ -315,3 +315,95 @@ func TestValidMethodParameterAttribute(t *testing.T) {
 		t.Error("MethodParameter name: " + mp.name + " is not a valid unqualified name")
 	}
 }
+
+// test a Code attribute with a LineNumberTable sub-attribute
+func TestCodeAttributeWithLineNumberTable(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass := ParsedClass{}
+	klass.className = "Test"
+	klass.cpIndex = append(klass.cpIndex, cpEntry{})
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 0})
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 1})
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 2})
+	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"Code"})
+	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"testMethod"})
+	klass.utf8Refs = append(klass.utf8Refs, utf8Entry{"LineNumberTable"})
+	klass.cpCount = 4
+
+	meth := method{}
+	meth.name = 1
+
+	attrib := attr{}
+	attrib.attrName = 0
+	attrib.attrContent = []byte{
+		0, 1, // maxstack = 1
+		0, 1, // maxlocals = 1
+		0, 0, 0, 4, // code length = 4
+		0x03, 0x3B, 0x04, 0xB1, // iconst_0, istore_0, iconst_1, return
+		0, 0, // number of exceptions = 0
+		0, 1, // attribute count of Code attribute = 1
+		0, 3, // name: CP entry #3 = LineNumberTable
+		0, 0, 0, 10, // attribute length
+		0, 2, // line_number_table_length = 2
+		0, 0, 0, 7, // pc 0 is line 7
+		0, 2, 0, 9, // pc 2 is line 9
+	}
+
+	err := parseCodeAttribute(attrib, &meth, &klass)
+	if err != nil {
+		t.Fatalf("Unexpected error in processing Code attribute with a LineNumberTable: %s", err.Error())
+	}
+
+	expected := []lineNumber{{startPc: 0, line: 7}, {startPc: 2, line: 9}}
+	if len(meth.codeAttr.lineNumbers) != len(expected) {
+		t.Fatalf("Expected %d line numbers, got: %v", len(expected), meth.codeAttr.lineNumbers)
+	}
+	for i, ln := range expected {
+		if meth.codeAttr.lineNumbers[i] != ln {
+			t.Errorf("Expected line number entry %v, got: %v", ln, meth.codeAttr.lineNumbers[i])
+		}
+	}
+}
+
+// a LineNumberTable entry whose start_pc is past the end of the code is invalid
+func TestLineNumberTableInvalidStartPc(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	klass := ParsedClass{}
+	klass.className = "Test"
+	att := attr{attrContent: []byte{
+		0, 1, // line_number_table_length = 1
+		0, 4, 0, 7, // pc 4 is line 7
+	}}
+	_, err := parseLineNumberTable(att, 4, "testMethod", &klass)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if err == nil {
+		t.Errorf("Expected an error for a start_pc past the end of the code, but got none")
+	}
+}
+
+// the line of a bytecode is that of the entry with the closest start_pc at or before it
+func TestSourceLineOf(t *testing.T) {
+	table := []LineNumber{{StartPc: 5, Line: 12}, {StartPc: 0, Line: 10}, {StartPc: 9, Line: 11}}
+
+	tests := []struct{ pc, line int }{{0, 10}, {4, 10}, {5, 12}, {8, 12}, {9, 11}, {100, 11}}
+	for _, test := range tests {
+		if line := SourceLineOf(table, test.pc); line != test.line {
+			t.Errorf("SourceLineOf: Expected line %d for pc %d, got: %d", test.line, test.pc, line)
+		}
+	}
+
+	if line := SourceLineOf([]LineNumber{{StartPc: 3, Line: 1}}, 2); line != -1 {
+		t.Errorf("SourceLineOf: Expected -1 for a pc not covered by the table, got: %d", line)
+	}
+}
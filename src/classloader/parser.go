@@ -475,8 +475,25 @@ func parseFields(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 			if attrName == "ConstantValue" {
 				desc := klass.utf8Refs[f.description].content
 				switch desc {
-				case types.Ref, types.Bool: // TODO: Find out how to process these
-					f.constValue = nil
+				case types.Bool: // boolean--same logic as for "I", only error message is different
+					indexIntoCP := int(attribute.attrContent[0])*256 +
+						int(attribute.attrContent[1])
+					entryInCp := klass.cpIndex[indexIntoCP]
+					if entryInCp.entryType != IntConst {
+						return pos, cfe("error: wrong type of constant value for boolean " +
+							klass.utf8Refs[f.name].content)
+					}
+					f.constValue = klass.intConsts[entryInCp.slot]
+				case "Ljava/lang/String;": // String--the value is a string constant
+					indexIntoCP := int(attribute.attrContent[0])*256 +
+						int(attribute.attrContent[1])
+					entryInCp := klass.cpIndex[indexIntoCP]
+					if entryInCp.entryType != StringConst {
+						return pos, cfe("error: wrong type of constant value for String " +
+							klass.utf8Refs[f.name].content)
+					}
+					utf8Index := klass.stringRefs[entryInCp.slot].index
+					f.constValue = klass.utf8Refs[klass.cpIndex[utf8Index].slot].content
 				case types.Byte: // byte--same logic as for "I", only error message is different
 					indexIntoCP := int(attribute.attrContent[0])*256 +
 						int(attribute.attrContent[1])
@@ -543,6 +560,16 @@ func parseFields(bytes []byte, loc int, klass *ParsedClass) (int, error) {
 				}
 			} else { // append the attribute only if it's not ConstantValue
 				f.attributes = append(f.attributes, attribute)
+				switch attrName {
+				case "Deprecated":
+					f.deprecated = true
+				case "Signature":
+					f.signature, err = fetchSignature(klass, attribute)
+					if err != nil {
+						return pos, cfe("Invalid Signature attribute for field: " +
+							klass.utf8Refs[f.name].content)
+					}
+				}
 			}
 			pos = k
 		}
@@ -654,6 +681,54 @@ func parseClassAttributes(bytes []byte, loc int, klass *ParsedClass) (int, error
 			sourceFile := klass.utf8Refs[utf8slot].content // points to the name of the source file
 			klass.sourceFile = sourceFile
 			_ = log.Log("Source file: "+sourceFile, log.FINEST)
+
+		case "InnerClasses":
+			if parseInnerClassesAttribute(attrib, klass) != nil {
+				return pos, cfe("") // error msg will already have been shown to user
+			}
+
+		case "EnclosingMethod":
+			if parseEnclosingMethodAttribute(attrib, klass) != nil {
+				return pos, cfe("") // error msg will already have been shown to user
+			}
+
+		case "NestHost":
+			nestHost, err := fetchClassName(klass, attrib.attrContent, 0)
+			if err != nil {
+				return pos, cfe("Invalid NestHost attribute in class: " + klass.className)
+			}
+			klass.nestHost = nestHost
+
+		case "NestMembers":
+			nestMembers, _, err := fetchClassNames(klass, attrib.attrContent, 0)
+			if err != nil {
+				return pos, cfe("Invalid NestMembers attribute in class: " + klass.className)
+			}
+			klass.nestMembers = nestMembers
+
+		case "PermittedSubclasses":
+			subclasses, _, err := fetchClassNames(klass, attrib.attrContent, 0)
+			if err != nil {
+				return pos, cfe("Invalid PermittedSubclasses attribute in class: " + klass.className)
+			}
+			klass.permittedSubclasses = subclasses
+
+		case "Record":
+			if parseRecordAttribute(attrib, klass) != nil {
+				return pos, cfe("") // error msg will already have been shown to user
+			}
+
+		case "Signature":
+			signature, err := fetchSignature(klass, attrib)
+			if err != nil {
+				return pos, cfe("Invalid Signature attribute in class: " + klass.className)
+			}
+			klass.signature = signature
+
+		case "Module":
+			if parseModuleAttribute(attrib, klass) != nil {
+				return pos, cfe("") // error msg will already have been shown to user
+			}
 		}
 	}
 	return pos, nil
@@ -1129,6 +1129,63 @@ func TestFieldWithNoAttributes(t *testing.T) {
 	}
 }
 
+// String and boolean static fields get their values from their ConstantValue attributes
+func TestFieldsWithStringAndBooleanConstantValues(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	klass := ParsedClass{}
+	klass.cpIndex = append(klass.cpIndex, cpEntry{})
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 0})        // 1: "greeting"
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 1})        // 2: "Ljava/lang/String;"
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 2})        // 3: "ConstantValue"
+	klass.cpIndex = append(klass.cpIndex, cpEntry{StringConst, 0}) // 4: the string at 5
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 3})        // 5: "hello"
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 4})        // 6: "enabled"
+	klass.cpIndex = append(klass.cpIndex, cpEntry{UTF8, 5})        // 7: "Z"
+	klass.cpIndex = append(klass.cpIndex, cpEntry{IntConst, 0})    // 8: 1
+	for _, s := range []string{"greeting", "Ljava/lang/String;", "ConstantValue", "hello", "enabled", "Z"} {
+		klass.utf8Refs = append(klass.utf8Refs, utf8Entry{s})
+	}
+	klass.stringRefs = append(klass.stringRefs, stringConstantEntry{index: 5})
+	klass.intConsts = append(klass.intConsts, 1)
+	klass.cpCount = 9
+	klass.fieldCount = 2
+
+	testBytes := []byte{
+		0x00,       // first byte is skipped
+		0x00, 0x18, // access flags: static final
+		0x00, 0x01, // name: "greeting"
+		0x00, 0x02, // description: "Ljava/lang/String;"
+		0x00, 0x01, // attribute count
+		0x00, 0x03, // attribute name: "ConstantValue"
+		0x00, 0x00, 0x00, 0x02, // attribute length
+		0x00, 0x04, // the string constant
+		0x00, 0x18, // access flags: static final
+		0x00, 0x06, // name: "enabled"
+		0x00, 0x07, // description: "Z"
+		0x00, 0x01, // attribute count
+		0x00, 0x03, // attribute name: "ConstantValue"
+		0x00, 0x00, 0x00, 0x02, // attribute length
+		0x00, 0x08, // the int constant
+	}
+
+	_, err := parseFields(testBytes, 0, &klass)
+	if err != nil {
+		t.Fatalf("Expected no error in parsing fields, got: %s", err.Error())
+	}
+
+	if len(klass.fields) != 2 {
+		t.Fatalf("Expected 2 fields in parsed class, got: %d", len(klass.fields))
+	}
+	if klass.fields[0].constValue != "hello" {
+		t.Errorf("Expected the String field's value to be \"hello\", got: %v", klass.fields[0].constValue)
+	}
+	if klass.fields[1].constValue != 1 {
+		t.Errorf("Expected the boolean field's value to be 1, got: %v", klass.fields[1].constValue)
+	}
+}
+
 func TestMethodCountValid(t *testing.T) {
 
 	globals.InitGlobals("test")
@@ -0,0 +1,256 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"strconv"
+)
+
+// The StackMapTable attribute is a sub-attribute of the Code attribute. It gives the
+// types of the local variables and of the operand stack at the start of every
+// instruction that's the target of a branch or an exception handler, or that follows
+// an unconditional branch. The verifier (see verifier.go) checks that the types at
+// every such instruction match those in the table. Its structure is described at:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.7.4
+//
+//	StackMapTable_attribute {
+//	    u2              attribute_name_index;
+//	    u4              attribute_length;
+//	    u2              number_of_entries;
+//	    stack_map_frame entries[number_of_entries];
+//	}
+//
+// Each frame is given as a change from the previous frame, which for the first frame
+// is the one implied by the method's descriptor. The frame's offset is given as a
+// delta from the previous frame's offset.
+
+// the tags of the verification_type_info items in the frames
+const (
+	itemTop               = 0
+	itemInteger           = 1
+	itemFloat             = 2
+	itemDouble            = 3
+	itemLong              = 4
+	itemNull              = 5
+	itemUninitializedThis = 6
+	itemObject            = 7
+	itemUninitialized     = 8
+)
+
+// the ranges of the frame_type byte that begins each frame. The frame types from
+// 128 to 246 are reserved, and 255 is a full_frame.
+const (
+	sameFrameMax            = 63
+	sameLocals1StackItemMin = 64
+	sameLocals1StackItemMax = 127
+	sameLocals1StackItemExt = 247
+	chopFrameMax            = 250
+	sameFrameExtended       = 251
+	appendFrameMax          = 254
+)
+
+// stackMapReader reads the frames of a StackMapTable attribute
+type stackMapReader struct {
+	v       *methodVerifier
+	content []byte
+	pos     int
+}
+
+// parseStackMapTable returns the type states given by the method's StackMapTable
+// attribute, keyed by the offsets of the instructions they apply to. initialLocals
+// are the types of the method's parameters (including this), as they appear in the
+// implicit first frame. A method without the attribute has no frames.
+func (v *methodVerifier) parseStackMapTable(initialLocals []verifType) (map[int]*typeState, error) {
+	frames := make(map[int]*typeState)
+
+	var content []byte
+	found := false
+	for _, attr := range v.meth.CodeAttr.Attributes {
+		if int(attr.AttrName) < len(v.class.CP.Utf8Refs) && v.class.CP.Utf8Refs[attr.AttrName] == "StackMapTable" {
+			if found {
+				return nil, v.fail(0, "Multiple StackMapTable attributes")
+			}
+			content = attr.AttrContent
+			found = true
+		}
+	}
+	if !found {
+		return frames, nil
+	}
+
+	r := stackMapReader{v: v, content: content}
+	entryCount, err := r.u2()
+	if err != nil {
+		return nil, v.fail(0, "StackMapTable format error: "+err.Error())
+	}
+
+	locals := initialLocals // the locals of the previous frame, one entry per long or double
+	offset := -1
+	for i := 0; i < entryCount; i++ {
+		frameType, err := r.u1()
+		if err != nil {
+			return nil, v.fail(0, "StackMapTable format error: "+err.Error())
+		}
+
+		var delta int
+		var stack []verifType
+		switch {
+		case frameType <= sameFrameMax:
+			delta = frameType
+		case frameType <= sameLocals1StackItemMax:
+			delta = frameType - sameLocals1StackItemMin
+			stack, err = r.verifTypes(1)
+		case frameType < sameLocals1StackItemExt:
+			return nil, v.fail(0, "StackMapTable format error: reserved frame type "+strconv.Itoa(frameType))
+		case frameType == sameLocals1StackItemExt:
+			if delta, err = r.u2(); err == nil {
+				stack, err = r.verifTypes(1)
+			}
+		case frameType <= chopFrameMax:
+			delta, err = r.u2()
+			chop := sameFrameExtended - frameType
+			if chop > len(locals) {
+				return nil, v.fail(0, "StackMapTable format error: chop frame removes too many locals")
+			}
+			locals = locals[:len(locals)-chop]
+		case frameType == sameFrameExtended:
+			delta, err = r.u2()
+		case frameType <= appendFrameMax:
+			var appended []verifType
+			if delta, err = r.u2(); err == nil {
+				appended, err = r.verifTypes(frameType - sameFrameExtended)
+			}
+			locals = append(append([]verifType{}, locals...), appended...)
+		default: // full_frame
+			var count int
+			if delta, err = r.u2(); err == nil {
+				if count, err = r.u2(); err == nil {
+					if locals, err = r.verifTypes(count); err == nil {
+						if count, err = r.u2(); err == nil {
+							stack, err = r.verifTypes(count)
+						}
+					}
+				}
+			}
+		}
+		if err != nil {
+			return nil, v.fail(0, "StackMapTable format error: "+err.Error())
+		}
+
+		offset += delta + 1
+		if offset >= len(v.code) {
+			return nil, v.fail(offset, "StackMapTable error: bad offset")
+		}
+
+		state, err := v.stateFromFrame(offset, locals, stack)
+		if err != nil {
+			return nil, err
+		}
+		frames[offset] = state
+	}
+
+	if r.pos != len(content) {
+		return nil, v.fail(0, "StackMapTable format error: wrong attribute size")
+	}
+	return frames, nil
+}
+
+// stateFromFrame converts the locals and stack of a frame, in which a long or double
+// takes up one entry, into a type state, in which it's followed by top in the locals.
+// Locals not listed in the frame are top.
+func (v *methodVerifier) stateFromFrame(offset int, locals, stack []verifType) (*typeState, error) {
+	state := &typeState{locals: make([]verifType, 0, v.maxLocals)}
+	for _, t := range locals {
+		state.locals = append(state.locals, t)
+		if t.isCat2() {
+			state.locals = append(state.locals, typeTop)
+		}
+		if t.tag == vtUninitThis {
+			state.thisUninit = true
+		}
+	}
+	if len(state.locals) > v.maxLocals {
+		return nil, v.fail(offset, "StackMapTable error: local variables exceed max locals")
+	}
+	for len(state.locals) < v.maxLocals {
+		state.locals = append(state.locals, typeTop)
+	}
+
+	state.stack = stack
+	if state.stackSlots() > v.maxStack {
+		return nil, v.fail(offset, "StackMapTable error: operand stack exceeds max stack")
+	}
+	return state, nil
+}
+
+// reads count verification_type_info items
+func (r *stackMapReader) verifTypes(count int) ([]verifType, error) {
+	types := make([]verifType, 0, count)
+	for i := 0; i < count; i++ {
+		tag, err := r.u1()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tag {
+		case itemTop:
+			types = append(types, typeTop)
+		case itemInteger:
+			types = append(types, typeInt)
+		case itemFloat:
+			types = append(types, typeFloat)
+		case itemDouble:
+			types = append(types, typeDouble)
+		case itemLong:
+			types = append(types, typeLong)
+		case itemNull:
+			types = append(types, typeNull)
+		case itemUninitializedThis:
+			types = append(types, typeUninitThis)
+		case itemObject:
+			cpIndex, err := r.u2()
+			if err != nil {
+				return nil, err
+			}
+			className, ok := r.v.classNameAt(cpIndex)
+			if !ok {
+				return nil, errors.New("invalid class reference at CP entry " + strconv.Itoa(cpIndex))
+			}
+			types = append(types, refType(className))
+		case itemUninitialized:
+			offset, err := r.u2()
+			if err != nil {
+				return nil, err
+			}
+			if offset >= len(r.v.code) || r.v.code[offset] != opNew {
+				return nil, errors.New("uninitialized type refers to offset " + strconv.Itoa(offset) +
+					", which is not a new instruction")
+			}
+			types = append(types, verifType{tag: vtUninit, offset: offset})
+		default:
+			return nil, errors.New("invalid verification type tag " + strconv.Itoa(tag))
+		}
+	}
+	return types, nil
+}
+
+func (r *stackMapReader) u1() (int, error) {
+	if r.pos >= len(r.content) {
+		return 0, errors.New("attribute is truncated")
+	}
+	r.pos += 1
+	return int(r.content[r.pos-1]), nil
+}
+
+func (r *stackMapReader) u2() (int, error) {
+	if r.pos+1 >= len(r.content) {
+		return 0, errors.New("attribute is truncated")
+	}
+	r.pos += 2
+	return int(r.content[r.pos-2])<<8 | int(r.content[r.pos-1]), nil
+}
@@ -56,12 +56,99 @@ func AddStatic(name string, s Static) error {
 	if name == "" {
 		return errors.New("AddStatic: Attempting to add invalid static entry")
 	}
-	staticsMutex.RLock()
+	staticsMutex.Lock()
 	Statics[name] = s
-	staticsMutex.RUnlock()
+	staticsMutex.Unlock()
 	return nil
 }
 
+// FetchStatic returns the static field with the given fully qualified name and
+// whether it's in the Statics table, using a mutex
+func FetchStatic(name string) (Static, bool) {
+	staticsMutex.RLock()
+	s, ok := Statics[name]
+	staticsMutex.RUnlock()
+	return s, ok
+}
+
+// ResolveStaticField resolves a reference to the static field fieldName of the named class,
+// as set out in JVMS 5.4.3.2: the field is looked up in the class, then in its
+// superinterfaces, and then in its superclass, whose superinterfaces and superclass are
+// searched in turn. It returns the name of the class or interface that declares the field,
+// which is the class whose entry in the Statics table holds the field. If the field can't
+// be resolved, the error is a *GErrBlk naming the exception to throw.
+func ResolveStaticField(className, fieldName string) (string, error) {
+	declaringClass, isStatic, err := findDeclaringClass(className, fieldName)
+	if err != nil {
+		return "", err
+	}
+	if declaringClass == "" {
+		return "", &GErrBlk{ExceptionType: "java/lang/NoSuchFieldError", ErrMsg: fieldName}
+	}
+	if !isStatic {
+		return "", &GErrBlk{ExceptionType: "java/lang/IncompatibleClassChangeError",
+			ErrMsg: "Expected static field " + javaName(className) + "." + fieldName}
+	}
+	return declaringClass, nil
+}
+
+// StaticFieldRefClass returns the class that declares the static field that's referred to
+// by the given FieldRef entry of the CP, along with the field's name. The reference is
+// resolved the first time, and the declaring class is then kept in the entry, as
+// FieldRefSlot() keeps the slot of an instance field. Static fields that Jacobin itself
+// preloads belong to classes that need not be loaded, so they are not resolved until
+// their class is loaded.
+func StaticFieldRefClass(cp *CPool, fieldRefIndex uint16) (string, string, error) {
+	fieldRef := &cp.FieldRefs[fieldRefIndex]
+	nameAndType := cp.NameAndTypes[cp.CpIndex[fieldRef.NameAndType].Slot]
+	fieldName := FetchUTF8stringFromCPEntryNumber(cp, nameAndType.NameIndex)
+
+	if declaringClass := fieldRef.resolvedClass.Load(); declaringClass != nil {
+		return *declaringClass, fieldName, nil
+	}
+
+	classNameIndex := cp.ClassRefs[cp.CpIndex[fieldRef.ClassIndex].Slot]
+	className := FetchUTF8stringFromCPEntryNumber(cp, classNameIndex)
+	if _, ok := FetchStatic(className + "." + fieldName); ok && MethAreaFetch(className) == nil {
+		return className, fieldName, nil
+	}
+
+	declaringClass, err := ResolveStaticField(className, fieldName)
+	if err != nil {
+		return "", fieldName, err
+	}
+	fieldRef.resolvedClass.Store(&declaringClass)
+	return declaringClass, fieldName, nil
+}
+
+// findDeclaringClass returns the class or interface that declares the field that a reference
+// to fieldName in the named class resolves to, and whether the field is static. It returns an
+// empty name if the field is not found.
+func findDeclaringClass(className, fieldName string) (string, bool, error) {
+	k, err := fetchLoadedClass(className)
+	if err != nil {
+		return "", false, ClassLoadingError(className, err)
+	}
+	for _, fld := range k.Data.Fields {
+		if k.Data.CP.Utf8Refs[fld.Name] == fieldName {
+			return className, fld.IsStatic, nil
+		}
+	}
+
+	for _, utf8Index := range k.Data.Interfaces {
+		declaringClass, isStatic, err := findDeclaringClass(k.Data.CP.Utf8Refs[utf8Index], fieldName)
+		if err != nil || declaringClass != "" {
+			return declaringClass, isStatic, err
+		}
+	}
+
+	// java/lang/Object declares no fields, so it need not be loaded
+	if k.Data.Superclass == "" || k.Data.Superclass == "java/lang/Object" {
+		return "", false, nil
+	}
+	return findDeclaringClass(k.Data.Superclass, fieldName)
+}
+
 // StaticsPreload preloads static fields from java.lang.String and other
 // immediately necessary statics. It's called in jvmStart.go
 func StaticsPreload() {
@@ -0,0 +1,107 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"errors"
+	"testing"
+)
+
+// adds the given superinterfaces to a class that's in the method area
+func addTestInterfaces(className string, interfaces ...string) {
+	data := MethAreaFetch(className).Data
+	for _, iface := range interfaces {
+		data.Interfaces = append(data.Interfaces, uint16(len(data.CP.Utf8Refs)))
+		data.CP.Utf8Refs = append(data.CP.Utf8Refs, iface)
+	}
+}
+
+// sets up the following classes and interfaces:
+//
+//	interface Limits { static int MAX; }
+//	interface Sized extends Limits { static int SIZE; }
+//	class Base { static int MAX; static int count; int x; }
+//	class Sub extends Base implements Sized { }
+func setupStaticsTestClasses() {
+	setupLayoutTestClasses()
+	insertLayoutTestClass("Limits", "java/lang/Object", "static MAX", "I")
+	insertLayoutTestClass("Sized", "java/lang/Object", "static SIZE", "I")
+	addTestInterfaces("Sized", "Limits")
+	insertLayoutTestClass("Base", "java/lang/Object", "static MAX", "I", "static count", "I", "x", "I")
+	insertLayoutTestClass("Sub", "Base")
+	addTestInterfaces("Sub", "Sized")
+}
+
+// a static field is looked up in the class, then its superinterfaces, then its superclass
+func TestResolveStaticFieldOrder(t *testing.T) {
+	setupStaticsTestClasses()
+
+	tests := []struct {
+		className, fieldName, declaringClass string
+	}{
+		{"Sub", "count", "Base"}, {"Sub", "SIZE", "Sized"}, {"Sub", "MAX", "Limits"},
+		{"Base", "MAX", "Base"}, {"Sized", "MAX", "Limits"},
+	}
+	for _, test := range tests {
+		declaringClass, err := ResolveStaticField(test.className, test.fieldName)
+		if err != nil {
+			t.Errorf("Got unexpected error resolving %s.%s: %s", test.className, test.fieldName, err.Error())
+			continue
+		}
+		if declaringClass != test.declaringClass {
+			t.Errorf("Expected %s.%s to be declared in %s, got: %s",
+				test.className, test.fieldName, test.declaringClass, declaringClass)
+		}
+	}
+}
+
+// the class that declares the field of a resolved static field reference is kept in the
+// CP entry
+func TestStaticFieldRefClassIsKept(t *testing.T) {
+	setupStaticsTestClasses()
+
+	cp := CPool{Utf8Refs: []string{"Sub", "SIZE", "I"}, ClassRefs: []uint16{1}}
+	cp.CpIndex = []CpEntry{{}, {Type: UTF8, Slot: 0}, {Type: ClassRef, Slot: 0}, {Type: UTF8, Slot: 1},
+		{Type: UTF8, Slot: 2}, {Type: NameAndType, Slot: 0}, {Type: FieldRef, Slot: 0}}
+	cp.NameAndTypes = []NameAndTypeEntry{{NameIndex: 3, DescIndex: 4}}
+	cp.FieldRefs = []FieldRefEntry{{ClassIndex: 2, NameAndType: 5}}
+
+	declaringClass, name, err := StaticFieldRefClass(&cp, 0)
+	if err != nil || declaringClass != "Sized" || name != "SIZE" {
+		t.Fatalf("Expected Sub.SIZE to resolve to Sized, got %q, name %q, error: %v", declaringClass, name, err)
+	}
+
+	// once resolved, the reference is not resolved again, even if the class is gone
+	InitMethodArea()
+	declaringClass, _, err = StaticFieldRefClass(&cp, 0)
+	if err != nil || declaringClass != "Sized" {
+		t.Errorf("Expected the kept class Sized, got %q, error: %v", declaringClass, err)
+	}
+}
+
+func TestResolveStaticFieldErrors(t *testing.T) {
+	setupStaticsTestClasses()
+
+	tests := []struct {
+		className, fieldName, exception, message string
+	}{
+		{"Sub", "x", "java/lang/IncompatibleClassChangeError", "Expected static field Sub.x"},
+		{"Sub", "missing", "java/lang/NoSuchFieldError", "missing"},
+	}
+	for _, test := range tests {
+		_, err := ResolveStaticField(test.className, test.fieldName)
+		var errBlk *GErrBlk
+		if !errors.As(err, &errBlk) {
+			t.Errorf("Expected a *GErrBlk resolving %s.%s, got: %v", test.className, test.fieldName, err)
+			continue
+		}
+		if errBlk.ExceptionType != test.exception || errBlk.ErrMsg != test.message {
+			t.Errorf("Expected %s(%q) resolving %s.%s, got: %s(%q)", test.exception, test.message,
+				test.className, test.fieldName, errBlk.ExceptionType, errBlk.ErrMsg)
+		}
+	}
+}
@@ -0,0 +1,838 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"fmt"
+	"jacobin/globals"
+	"jacobin/log"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The verifier checks the bytecode of a class's methods before the class is posted to
+// the method area, so that a malformed class is rejected with a VerifyError, which is
+// thrown where the class is first used, rather than failing when its code is run. It
+// implements verification by type checking, as described in JVMS 4.10.1, which is
+// required for class files of version 50 (Java 6) and later: the types of the local
+// variables and of the operand stack are tracked instruction by instruction and checked
+// against the types each instruction expects and against the frames of the method's
+// StackMapTable (see stackMapTable.go).
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.10.1
+//
+// Which classes are verified depends on globals.VerifyLevel, which is set by -Xverify.
+// As in the JDK, by default only the classes that don't come from the JDK are verified.
+//
+// Checking whether one class type is assignable to another can require loading the
+// classes. A class that can't be loaded is treated as assignable, leaving the failure
+// to be reported when the class is resolved at run time. As in the JDK, interface
+// types are treated as java/lang/Object, so that any class is assignable to them.
+// Protected access (JVMS 4.10.1.8) is not checked.
+
+// the earliest class file version that's verified by type checking
+const typeCheckingVersion = 50
+
+// the tags of the verification types (JVMS 4.10.1.2)
+const (
+	vtTop = iota
+	vtInt
+	vtFloat
+	vtLong
+	vtDouble
+	vtNull
+	vtUninitThis
+	vtUninit
+	vtRef
+)
+
+// verifType is a verification type. int is used for boolean, byte, char, and short.
+type verifType struct {
+	tag    int
+	name   string // for a vtRef, the class name or, for an array, its descriptor
+	offset int    // for a vtUninit, the offset of the new instruction that created the object
+}
+
+var (
+	typeTop        = verifType{tag: vtTop}
+	typeInt        = verifType{tag: vtInt}
+	typeFloat      = verifType{tag: vtFloat}
+	typeLong       = verifType{tag: vtLong}
+	typeDouble     = verifType{tag: vtDouble}
+	typeNull       = verifType{tag: vtNull}
+	typeUninitThis = verifType{tag: vtUninitThis}
+	typeObject     = refType("java/lang/Object")
+)
+
+func refType(name string) verifType { return verifType{tag: vtRef, name: name} }
+
+// isCat2 reports whether the type is a long or a double, which take up two slots
+func (t verifType) isCat2() bool { return t.tag == vtLong || t.tag == vtDouble }
+
+func (t verifType) size() int {
+	if t.isCat2() {
+		return 2
+	}
+	return 1
+}
+
+// isReference reports whether the type is a reference, including to an object that
+// has not been initialized
+func (t verifType) isReference() bool {
+	return t.tag == vtNull || t.tag == vtRef || t.tag == vtUninit || t.tag == vtUninitThis
+}
+
+func (t verifType) isArray() bool { return t.tag == vtRef && strings.HasPrefix(t.name, "[") }
+
+func (t verifType) String() string {
+	switch t.tag {
+	case vtTop:
+		return "top"
+	case vtInt:
+		return "integer"
+	case vtFloat:
+		return "float"
+	case vtLong:
+		return "long"
+	case vtDouble:
+		return "double"
+	case vtNull:
+		return "null"
+	case vtUninitThis:
+		return "uninitializedThis"
+	case vtUninit:
+		return "uninitialized(" + strconv.Itoa(t.offset) + ")"
+	}
+	return "'" + t.name + "'"
+}
+
+// typeFromDescriptor returns the verification type of a field descriptor, such as I,
+// Ljava/lang/String;, or [J
+func typeFromDescriptor(desc string) verifType {
+	switch desc[0] {
+	case 'B', 'C', 'I', 'S', 'Z':
+		return typeInt
+	case 'F':
+		return typeFloat
+	case 'J':
+		return typeLong
+	case 'D':
+		return typeDouble
+	case 'L':
+		return refType(strings.TrimSuffix(desc[1:], ";"))
+	}
+	return refType(desc) // an array
+}
+
+// componentType returns the type of the elements of an array type, such as [I or
+// [Ljava/lang/String;
+func componentType(arrayType verifType) verifType {
+	return typeFromDescriptor(arrayType.name[1:])
+}
+
+// arrayOf returns the type of an array of the named class, which can itself be an array
+func arrayOf(className string) verifType {
+	if strings.HasPrefix(className, "[") {
+		return refType("[" + className)
+	}
+	return refType("[L" + className + ";")
+}
+
+// splitMethodDescriptor returns the field descriptors of a method descriptor's
+// parameters and of its return type, which is V for a void method
+func splitMethodDescriptor(desc string) ([]string, string, bool) {
+	if !strings.HasPrefix(desc, "(") {
+		return nil, "", false
+	}
+
+	var params []string
+	i := 1
+	for i < len(desc) && desc[i] != ')' {
+		start := i
+		for i < len(desc) && desc[i] == '[' {
+			i++
+		}
+		if i < len(desc) && desc[i] == 'L' {
+			end := strings.IndexByte(desc[i:], ';')
+			if end < 0 {
+				return nil, "", false
+			}
+			i += end
+		}
+		if i >= len(desc) {
+			return nil, "", false
+		}
+		i++
+		params = append(params, desc[start:i])
+	}
+	if i+1 >= len(desc) {
+		return nil, "", false
+	}
+	return params, desc[i+1:], true
+}
+
+// typeState is the state of the local variables and the operand stack before or
+// after an instruction
+type typeState struct {
+	locals     []verifType // one entry per local variable; a long or double is followed by top
+	stack      []verifType // one entry per value, so a long or double takes up one entry
+	thisUninit bool        // is this an uninitialized object in a constructor? (flagThisUninit)
+}
+
+func (s *typeState) copy() *typeState {
+	return &typeState{
+		locals:     append([]verifType{}, s.locals...),
+		stack:      append([]verifType{}, s.stack...),
+		thisUninit: s.thisUninit,
+	}
+}
+
+// stackSlots returns the number of slots of the operand stack that are in use
+func (s *typeState) stackSlots() int {
+	slots := 0
+	for _, t := range s.stack {
+		slots += t.size()
+	}
+	return slots
+}
+
+// classesInVerification holds the ClData of the classes that are being verified,
+// keyed by their names. Verifying a class can load other classes, whose verification
+// can in turn require the class hierarchy of a class that is not yet in the method area.
+var classesInVerification sync.Map
+
+// verificationRequired reports whether the named class, loaded by the classloader, must
+// be verified. At the default level, the JDK's classes are trusted: those loaded by the
+// bootstrap classloader and those that the application classloader loads from the jmods.
+func verificationRequired(cl *Classloader, className string) bool {
+	switch globals.GetGlobalRef().VerifyLevel {
+	case globals.VerifyNone:
+		return false
+	case globals.VerifyAll:
+		return true
+	default: // the JDK's classes are trusted, whichever classloader loads them
+		return cl.Name != "bootstrap" && !isJdkClass(className)
+	}
+}
+
+// verifyClass verifies the code of all the methods of a class, whose class file has
+// the given version. Class files older than version 50 don't have the StackMapTable
+// attributes needed for verification by type checking, so they're not verified.
+// If a method fails, returns the VerifyError to throw, as a *GErrBlk, which will have
+// been shown to the user.
+func verifyClass(class *ClData, version int) error {
+	if version < typeCheckingVersion {
+		_ = log.Log("Class "+class.Name+" has class file version "+strconv.Itoa(version)+
+			", which is too old to be verified by type checking", log.FINE)
+		return nil
+	}
+
+	classesInVerification.Store(class.Name, class)
+	defer classesInVerification.Delete(class.Name)
+
+	for i := range class.Methods {
+		v, err := newMethodVerifier(class, &class.Methods[i], version)
+		if err != nil {
+			return err
+		}
+		if v == nil { // a method without code
+			continue
+		}
+		if err = v.verify(); err != nil {
+			return err
+		}
+	}
+	_ = log.Log("Class "+class.Name+" has been verified", log.FINEST)
+	return nil
+}
+
+// methodVerifier verifies the code of one method
+type methodVerifier struct {
+	class       *ClData
+	meth        *Method
+	methName    string
+	methDesc    string
+	version     int
+	code        []byte
+	maxStack    int
+	maxLocals   int
+	returnType  string             // the field descriptor of the return type, or V
+	instrStarts []bool             // the offsets at which instructions start
+	stackMap    map[int]*typeState // the type states from the StackMapTable, by offset
+	state       *typeState         // the type state of the current instruction
+}
+
+// newMethodVerifier sets up the verification of a method. It returns nil for an
+// abstract or native method, which has no code.
+func newMethodVerifier(class *ClData, meth *Method, version int) (*methodVerifier, error) {
+	v := &methodVerifier{class: class, meth: meth, version: version,
+		code: meth.CodeAttr.Code, maxStack: meth.CodeAttr.MaxStack, maxLocals: meth.CodeAttr.MaxLocals}
+	if int(meth.Name) < len(class.CP.Utf8Refs) {
+		v.methName = class.CP.Utf8Refs[meth.Name]
+	}
+	if int(meth.Desc) < len(class.CP.Utf8Refs) {
+		v.methDesc = class.CP.Utf8Refs[meth.Desc]
+	}
+
+	if meth.AccessFlags&(AccAbstract|AccNative) != 0 {
+		if len(v.code) > 0 {
+			return nil, v.fail(0, "Abstract or native method has code")
+		}
+		return nil, nil
+	}
+	if len(v.code) == 0 {
+		return nil, v.fail(0, "Method has no code")
+	}
+
+	params, returnType, ok := splitMethodDescriptor(v.methDesc)
+	if !ok {
+		return nil, v.fail(0, "Invalid method descriptor")
+	}
+	v.returnType = returnType
+
+	// the types of the local variables on entry to the method, as they appear in the
+	// implicit first frame of the StackMapTable
+	var initialLocals []verifType
+	if meth.AccessFlags&AccStatic == 0 {
+		if v.methName == "<init>" && class.Name != "java/lang/Object" {
+			initialLocals = append(initialLocals, typeUninitThis)
+		} else {
+			initialLocals = append(initialLocals, refType(class.Name))
+		}
+	}
+	for _, param := range params {
+		initialLocals = append(initialLocals, typeFromDescriptor(param))
+	}
+
+	argSlots := 0
+	for _, t := range initialLocals {
+		argSlots += t.size()
+	}
+	if argSlots > v.maxLocals {
+		return nil, v.fail(0, "Arguments can't fit into locals")
+	}
+
+	var err error
+	if v.state, err = v.stateFromFrame(0, initialLocals, nil); err != nil {
+		return nil, err
+	}
+	if err = v.findInstructions(); err != nil {
+		return nil, err
+	}
+	if v.stackMap, err = v.parseStackMapTable(initialLocals); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// fail returns a VerifyError for the instruction at offset pc, after logging it
+func (v *methodVerifier) fail(pc int, msg string) error {
+	errMsg := fmt.Sprintf("(class: %s, method: %s signature: %s) at offset %d: %s",
+		v.class.Name, v.methName, v.methDesc, pc, msg)
+	_ = log.Log("java.lang.VerifyError: "+errMsg, log.SEVERE)
+	return &GErrBlk{ExceptionType: "java/lang/VerifyError", ErrMsg: errMsg}
+}
+
+// the opcodes that the verifier treats specially
+const (
+	opIinc         = 0x84
+	opTableswitch  = 0xAA
+	opLookupswitch = 0xAB
+	opNew          = 0xBB
+	opWide         = 0xC4
+)
+
+// the lengths of the instructions, including their operands, by opcode. The lengths
+// of tableswitch, lookupswitch, and wide vary, and 0 marks an invalid opcode.
+var instructionLengths = [256]int{
+	0x10: 2, 0x11: 3, 0x12: 2, 0x13: 3, 0x14: 3, 0x15: 2, 0x16: 2, 0x17: 2, 0x18: 2, 0x19: 2,
+	0x36: 2, 0x37: 2, 0x38: 2, 0x39: 2, 0x3A: 2, 0x84: 3,
+	0x99: 3, 0x9A: 3, 0x9B: 3, 0x9C: 3, 0x9D: 3, 0x9E: 3, 0x9F: 3, 0xA0: 3, 0xA1: 3, 0xA2: 3,
+	0xA3: 3, 0xA4: 3, 0xA5: 3, 0xA6: 3, 0xA7: 3, 0xA8: 3, 0xA9: 2,
+	0xB2: 3, 0xB3: 3, 0xB4: 3, 0xB5: 3, 0xB6: 3, 0xB7: 3, 0xB8: 3, 0xB9: 5, 0xBA: 5,
+	0xBB: 3, 0xBC: 2, 0xBD: 3, 0xC0: 3, 0xC1: 3, 0xC5: 4, 0xC6: 3, 0xC7: 3, 0xC8: 5, 0xC9: 5,
+}
+
+func init() {
+	// the instructions without operands
+	for op := 0x00; op <= 0xC3; op++ {
+		if instructionLengths[op] == 0 && (op < 0xAA || op > 0xAB) {
+			instructionLengths[op] = 1
+		}
+	}
+}
+
+// InstructionLength returns the length of the instruction at offset pc of the
+// bytecode, or 0 if the instruction is invalid or truncated
+func InstructionLength(code []byte, pc int) int {
+	s4 := func(pos int) int {
+		return int(int32(uint32(code[pos])<<24 | uint32(code[pos+1])<<16 |
+			uint32(code[pos+2])<<8 | uint32(code[pos+3])))
+	}
+
+	op := code[pc]
+	length := instructionLengths[op]
+	switch op {
+	case opTableswitch, opLookupswitch:
+		base := pc + 1 + (3 - pc%4) // the operands are 4-byte aligned
+		if base+8 > len(code) {
+			return 0
+		}
+		if op == opTableswitch {
+			if base+12 > len(code) {
+				return 0
+			}
+			low, high := s4(base+4), s4(base+8)
+			if high < low || high-low >= len(code) {
+				return 0
+			}
+			length = base + 12 + 4*(high-low+1) - pc
+		} else {
+			pairs := s4(base + 4)
+			if pairs < 0 || pairs >= len(code) {
+				return 0
+			}
+			length = base + 8 + 8*pairs - pc
+		}
+	case opWide:
+		if pc+1 < len(code) && code[pc+1] == opIinc {
+			length = 6
+		} else {
+			length = 4
+		}
+	}
+	if pc+length > len(code) {
+		return 0
+	}
+	return length
+}
+
+func (v *methodVerifier) instructionLength(pc int) int { return InstructionLength(v.code, pc) }
+
+// findInstructions records the offsets at which the instructions start
+func (v *methodVerifier) findInstructions() error {
+	v.instrStarts = make([]bool, len(v.code))
+	for pc := 0; pc < len(v.code); {
+		length := v.instructionLength(pc)
+		if length == 0 {
+			return v.fail(pc, "Bad instruction: "+fmt.Sprintf("0x%02X", v.code[pc]))
+		}
+		v.instrStarts[pc] = true
+		pc += length
+	}
+	return nil
+}
+
+func (v *methodVerifier) u1(pos int) int { return int(v.code[pos]) }
+func (v *methodVerifier) u2(pos int) int { return int(v.code[pos])<<8 | int(v.code[pos+1]) }
+func (v *methodVerifier) s2(pos int) int { return int(int16(v.u2(pos))) }
+func (v *methodVerifier) s4(pos int) int {
+	return int(int32(uint32(v.code[pos])<<24 | uint32(v.code[pos+1])<<16 |
+		uint32(v.code[pos+2])<<8 | uint32(v.code[pos+3])))
+}
+
+// verify checks the method's code one instruction at a time
+func (v *methodVerifier) verify() error {
+	for offset := range v.stackMap {
+		if !v.instrStarts[offset] {
+			return v.fail(offset, "StackMapTable error: bad offset")
+		}
+	}
+	if err := v.checkExceptionTable(); err != nil {
+		return err
+	}
+
+	// a method whose code contains jsr or ret, which are allowed only before version 51,
+	// can't be verified by type checking. The JDK verifies it by type inference.
+	if v.version == typeCheckingVersion && v.hasSubroutines() {
+		_ = log.Log("Method "+v.class.Name+"."+v.methName+v.methDesc+
+			" uses jsr/ret, so it can't be verified by type checking", log.FINE)
+		return nil
+	}
+
+	reachable := true // can the current instruction be reached from the previous one?
+	for pc := 0; pc < len(v.code); pc += v.instructionLength(pc) {
+		if frame, ok := v.stackMap[pc]; ok {
+			if reachable && !v.isFrameAssignable(v.state, frame) {
+				return v.fail(pc, "Type mismatch with the stack map frame")
+			}
+			v.state = frame.copy()
+		} else if !reachable {
+			return v.fail(pc, "Expecting a stack map frame")
+		}
+
+		if err := v.checkHandlers(pc); err != nil {
+			return err
+		}
+
+		var err error
+		if reachable, err = v.verifyInstruction(pc); err != nil {
+			return err
+		}
+	}
+
+	if reachable {
+		return v.fail(len(v.code), "Falling off the end of the code")
+	}
+	return nil
+}
+
+// hasSubroutines reports whether the code contains a jsr, jsr_w, or ret instruction
+func (v *methodVerifier) hasSubroutines() bool {
+	for pc := 0; pc < len(v.code); pc += v.instructionLength(pc) {
+		switch op := v.code[pc]; {
+		case op == 0xA8 || op == 0xA9 || op == 0xC9: // jsr, ret, jsr_w
+			return true
+		case op == opWide && v.code[pc+1] == 0xA9: // wide ret
+			return true
+		}
+	}
+	return false
+}
+
+// checkExceptionTable checks the ranges and handlers of the exception table entries
+func (v *methodVerifier) checkExceptionTable() error {
+	for _, ex := range v.meth.CodeAttr.Exceptions {
+		if ex.StartPc >= ex.EndPc || ex.EndPc > len(v.code) || !v.instrStarts[ex.StartPc] ||
+			(ex.EndPc < len(v.code) && !v.instrStarts[ex.EndPc]) {
+			return v.fail(ex.StartPc, "Illegal exception table range")
+		}
+		if ex.HandlerPc >= len(v.code) || !v.instrStarts[ex.HandlerPc] {
+			return v.fail(ex.HandlerPc, "Illegal exception table handler")
+		}
+		if _, ok := v.stackMap[ex.HandlerPc]; !ok {
+			return v.fail(ex.HandlerPc, "Expecting a stack map frame at the exception handler")
+		}
+		if ex.CatchType != 0 {
+			catchType, ok := v.classNameAt(int(ex.CatchType))
+			if !ok || !v.isAssignable(refType(catchType), refType("java/lang/Throwable")) {
+				return v.fail(ex.HandlerPc, "Catch type is not a subclass of Throwable")
+			}
+		}
+	}
+	return nil
+}
+
+// checkHandlers checks that the local variables before the instruction at offset pc
+// are compatible with those expected by each exception handler that covers it. The
+// operand stack of a handler holds only the exception.
+func (v *methodVerifier) checkHandlers(pc int) error {
+	for _, ex := range v.meth.CodeAttr.Exceptions {
+		if pc < ex.StartPc || pc >= ex.EndPc {
+			continue
+		}
+
+		exception := refType("java/lang/Throwable")
+		if ex.CatchType != 0 {
+			exception.name, _ = v.classNameAt(int(ex.CatchType))
+		}
+		excState := &typeState{locals: v.state.locals, stack: []verifType{exception},
+			thisUninit: v.state.thisUninit}
+		if !v.isFrameAssignable(excState, v.stackMap[ex.HandlerPc]) {
+			return v.fail(pc, "Type mismatch with the stack map frame of the exception handler at offset "+
+				strconv.Itoa(ex.HandlerPc))
+		}
+	}
+	return nil
+}
+
+// isFrameAssignable reports whether the type state from can flow into the type state to
+func (v *methodVerifier) isFrameAssignable(from, to *typeState) bool {
+	if len(from.locals) != len(to.locals) || len(from.stack) != len(to.stack) {
+		return false
+	}
+	if from.thisUninit && !to.thisUninit {
+		return false
+	}
+	for i := range from.locals {
+		if !v.isAssignable(from.locals[i], to.locals[i]) {
+			return false
+		}
+	}
+	for i := range from.stack {
+		if !v.isAssignable(from.stack[i], to.stack[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isAssignable reports whether a value of type from can be used where type to is
+// expected (JVMS 4.10.1.2)
+func (v *methodVerifier) isAssignable(from, to verifType) bool {
+	switch {
+	case to.tag == vtTop:
+		return true
+	case from.tag != to.tag:
+		return from.tag == vtNull && to.tag == vtRef
+	case from.tag == vtUninit:
+		return from.offset == to.offset
+	case from.tag == vtRef:
+		return v.isClassAssignable(from.name, to.name)
+	}
+	return true
+}
+
+// isClassAssignable reports whether a reference to an object of class from, which can
+// be an array descriptor, is assignable to a reference of class to
+func (v *methodVerifier) isClassAssignable(from, to string) bool {
+	if from == to || to == "java/lang/Object" {
+		return true
+	}
+
+	fromType, toType := refType(from), refType(to)
+	if toType.isArray() {
+		if !fromType.isArray() {
+			return false
+		}
+		fromComponent, toComponent := componentType(fromType), componentType(toType)
+		if fromComponent.tag != vtRef || toComponent.tag != vtRef {
+			return from == to // arrays of primitives are assignable only to the same type
+		}
+		return v.isClassAssignable(fromComponent.name, toComponent.name)
+	}
+
+	if fromType.isArray() {
+		return to == "java/lang/Cloneable" || to == "java/io/Serializable"
+	}
+
+	toClass := lookupClassForVerification(to)
+	if toClass == nil || toClass.Access.ClassIsInterface {
+		return true
+	}
+
+	visited := make(map[string]bool)
+	for name := from; name != "" && !visited[name]; {
+		if name == to {
+			return true
+		}
+		visited[name] = true
+
+		class := lookupClassForVerification(name)
+		if class == nil {
+			return true
+		}
+		name = class.Superclass
+	}
+	return false
+}
+
+// lookupClassForVerification returns the ClData of the named class, loading the class
+// if necessary, or nil if it can't be loaded
+func lookupClassForVerification(className string) *ClData {
+	if class, ok := classesInVerification.Load(className); ok {
+		return class.(*ClData)
+	}
+	k, err := fetchLoadedClass(className)
+	if err != nil {
+		_ = log.Log("Verifier could not load class "+className, log.FINE)
+		return nil
+	}
+	return k.Data
+}
+
+// ---- CP lookups ----
+
+// classNameAt returns the name of the class in the ClassRef CP entry at cpIndex
+func (v *methodVerifier) classNameAt(cpIndex int) (string, bool) {
+	cp := &v.class.CP
+	if cpIndex < 1 || cpIndex >= len(cp.CpIndex) || cp.CpIndex[cpIndex].Type != ClassRef ||
+		int(cp.CpIndex[cpIndex].Slot) >= len(cp.ClassRefs) {
+		return "", false
+	}
+	name := FetchUTF8stringFromCPEntryNumber(cp, cp.ClassRefs[cp.CpIndex[cpIndex].Slot])
+	return name, name != ""
+}
+
+// nameAndTypeAt returns the name and descriptor in the NameAndType CP entry at cpIndex
+func (v *methodVerifier) nameAndTypeAt(cpIndex int) (string, string, bool) {
+	cp := &v.class.CP
+	if cpIndex < 1 || cpIndex >= len(cp.CpIndex) || cp.CpIndex[cpIndex].Type != NameAndType ||
+		int(cp.CpIndex[cpIndex].Slot) >= len(cp.NameAndTypes) {
+		return "", "", false
+	}
+	nAndT := cp.NameAndTypes[cp.CpIndex[cpIndex].Slot]
+	name := FetchUTF8stringFromCPEntryNumber(cp, nAndT.NameIndex)
+	desc := FetchUTF8stringFromCPEntryNumber(cp, nAndT.DescIndex)
+	return name, desc, name != "" && desc != ""
+}
+
+// memberRefAt returns the class, name, and descriptor of the field, method, or
+// interface method reference at cpIndex, which must be of one of the given CP types
+func (v *methodVerifier) memberRefAt(cpIndex int, cpTypes ...int) (string, string, string, bool) {
+	cp := &v.class.CP
+	if cpIndex < 1 || cpIndex >= len(cp.CpIndex) {
+		return "", "", "", false
+	}
+
+	entry := cp.CpIndex[cpIndex]
+	var classIndex, nAndTIndex uint16
+	matched := false
+	for _, cpType := range cpTypes {
+		if int(entry.Type) != cpType {
+			continue
+		}
+		switch cpType {
+		case FieldRef:
+			if int(entry.Slot) < len(cp.FieldRefs) {
+				classIndex, nAndTIndex = cp.FieldRefs[entry.Slot].ClassIndex, cp.FieldRefs[entry.Slot].NameAndType
+				matched = true
+			}
+		case MethodRef:
+			if int(entry.Slot) < len(cp.MethodRefs) {
+				classIndex, nAndTIndex = cp.MethodRefs[entry.Slot].ClassIndex, cp.MethodRefs[entry.Slot].NameAndType
+				matched = true
+			}
+		case Interface:
+			if int(entry.Slot) < len(cp.InterfaceRefs) {
+				classIndex, nAndTIndex = cp.InterfaceRefs[entry.Slot].ClassIndex, cp.InterfaceRefs[entry.Slot].NameAndType
+				matched = true
+			}
+		}
+	}
+	if !matched {
+		return "", "", "", false
+	}
+
+	className, ok := v.classNameAt(int(classIndex))
+	if !ok {
+		return "", "", "", false
+	}
+	name, desc, ok := v.nameAndTypeAt(int(nAndTIndex))
+	return className, name, desc, ok
+}
+
+// ---- the operand stack and local variables ----
+
+func (v *methodVerifier) push(pc int, t verifType) error {
+	if v.state.stackSlots()+t.size() > v.maxStack {
+		return v.fail(pc, "Operand stack overflow")
+	}
+	v.state.stack = append(v.state.stack, t)
+	return nil
+}
+
+// pop pops a value from the operand stack, which must be assignable to expected
+func (v *methodVerifier) pop(pc int, expected verifType) (verifType, error) {
+	if len(v.state.stack) == 0 {
+		return typeTop, v.fail(pc, "Operand stack underflow")
+	}
+	t := v.state.stack[len(v.state.stack)-1]
+	if !v.isAssignable(t, expected) || t.size() != expected.size() {
+		return typeTop, v.fail(pc, "Bad type on operand stack: expected "+expected.String()+", found "+t.String())
+	}
+	v.state.stack = v.state.stack[:len(v.state.stack)-1]
+	return t, nil
+}
+
+// popReference pops a reference, which can be to an uninitialized object
+func (v *methodVerifier) popReference(pc int) (verifType, error) {
+	if len(v.state.stack) == 0 {
+		return typeTop, v.fail(pc, "Operand stack underflow")
+	}
+	t := v.state.stack[len(v.state.stack)-1]
+	if !t.isReference() {
+		return typeTop, v.fail(pc, "Bad type on operand stack: expected a reference, found "+t.String())
+	}
+	v.state.stack = v.state.stack[:len(v.state.stack)-1]
+	return t, nil
+}
+
+// popSlots pops the values that take up exactly slots slots of the operand stack,
+// as the dup and pop instructions do. They're returned in the order they were pushed.
+func (v *methodVerifier) popSlots(pc int, slots int) ([]verifType, error) {
+	var popped []verifType
+	for slots > 0 {
+		if len(v.state.stack) == 0 {
+			return nil, v.fail(pc, "Operand stack underflow")
+		}
+		t := v.state.stack[len(v.state.stack)-1]
+		if t.size() > slots {
+			return nil, v.fail(pc, "Bad type on operand stack: "+t.String()+" would be split")
+		}
+		v.state.stack = v.state.stack[:len(v.state.stack)-1]
+		popped = append([]verifType{t}, popped...)
+		slots -= t.size()
+	}
+	return popped, nil
+}
+
+// load pushes the local variable at index, which must be assignable to expected
+func (v *methodVerifier) load(pc, index int, expected verifType) error {
+	if index+expected.size() > v.maxLocals {
+		return v.fail(pc, "Illegal local variable number")
+	}
+	t := v.state.locals[index]
+	if expected.tag == vtRef { // aload, which loads any reference
+		if !t.isReference() {
+			return v.fail(pc, "Bad local variable type: expected a reference, found "+t.String())
+		}
+		return v.push(pc, t)
+	}
+	if t.tag != expected.tag {
+		return v.fail(pc, "Bad local variable type: expected "+expected.String()+", found "+t.String())
+	}
+	return v.push(pc, t)
+}
+
+// store pops a value, which must be assignable to expected, into the local variable
+// at index. Storing into either half of a long or double invalidates it.
+func (v *methodVerifier) store(pc, index int, expected verifType) error {
+	if index+expected.size() > v.maxLocals {
+		return v.fail(pc, "Illegal local variable number")
+	}
+
+	var t verifType
+	var err error
+	if expected.tag == vtRef { // astore, which stores any reference
+		t, err = v.popReference(pc)
+	} else {
+		t, err = v.pop(pc, expected)
+	}
+	if err != nil {
+		return err
+	}
+
+	if index > 0 && v.state.locals[index-1].isCat2() {
+		v.state.locals[index-1] = typeTop
+	}
+	v.state.locals[index] = t
+	if t.isCat2() {
+		v.state.locals[index+1] = typeTop
+	}
+	return nil
+}
+
+// replaceType replaces every occurrence of a type in the locals and the operand stack
+func (v *methodVerifier) replaceType(old, new verifType) {
+	for i, t := range v.state.locals {
+		if t == old {
+			v.state.locals[i] = new
+		}
+	}
+	for i, t := range v.state.stack {
+		if t == old {
+			v.state.stack[i] = new
+		}
+	}
+}
+
+// checkBranch checks that the current type state can flow into the stack map
+// frame at the target of a branch
+func (v *methodVerifier) checkBranch(pc, target int) error {
+	if target < 0 || target >= len(v.code) || !v.instrStarts[target] {
+		return v.fail(pc, "Illegal target of jump or branch: "+strconv.Itoa(target))
+	}
+	frame, ok := v.stackMap[target]
+	if !ok {
+		return v.fail(pc, "Expecting a stack map frame at branch target "+strconv.Itoa(target))
+	}
+	if !v.isFrameAssignable(v.state, frame) {
+		return v.fail(pc, "Type mismatch with the stack map frame at branch target "+strconv.Itoa(target))
+	}
+	return nil
+}
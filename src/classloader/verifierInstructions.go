@@ -0,0 +1,655 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"strconv"
+	"strings"
+)
+
+// This file contains the type checking of the individual instructions by the verifier
+// (see verifier.go), which follows the rules in JVMS 4.10.1.9:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-4.html#jvms-4.10.1.9
+
+// stackEffect gives the types an instruction pops from the operand stack, with the
+// value on top of the stack first, and the type it pushes, if any
+type stackEffect struct {
+	pops []verifType
+	push *verifType
+}
+
+// the instructions whose only effect is to pop and push values of fixed types
+var simpleInstructions = map[byte]stackEffect{}
+
+func init() {
+	I, J, F, D := typeInt, typeLong, typeFloat, typeDouble
+	effect := func(push *verifType, pops ...verifType) stackEffect {
+		return stackEffect{pops: pops, push: push}
+	}
+
+	simpleInstructions[0x00] = effect(nil) // nop
+	simpleInstructions[0x01] = effect(&typeNull)
+	for op := byte(0x02); op <= 0x08; op++ { // iconst_m1 to iconst_5
+		simpleInstructions[op] = effect(&I)
+	}
+	simpleInstructions[0x09] = effect(&J) // lconst_0
+	simpleInstructions[0x0A] = effect(&J)
+	simpleInstructions[0x0B] = effect(&F) // fconst_0
+	simpleInstructions[0x0C] = effect(&F)
+	simpleInstructions[0x0D] = effect(&F)
+	simpleInstructions[0x0E] = effect(&D) // dconst_0
+	simpleInstructions[0x0F] = effect(&D)
+	simpleInstructions[0x10] = effect(&I) // bipush
+	simpleInstructions[0x11] = effect(&I) // sipush
+
+	// the loads and stores of arrays of primitives (baload and bastore are handled
+	// separately, as they work on arrays of both bytes and booleans)
+	arrays := map[byte]verifType{0x2E: I, 0x2F: J, 0x30: F, 0x31: D, 0x34: I, 0x35: I}
+	arrayTypes := map[byte]string{0x2E: "[I", 0x2F: "[J", 0x30: "[F", 0x31: "[D", 0x34: "[C", 0x35: "[S"}
+	for op, elem := range arrays {
+		elem := elem
+		simpleInstructions[op] = effect(&elem, I, refType(arrayTypes[op]))          // xaload
+		simpleInstructions[op+0x21] = effect(nil, elem, I, refType(arrayTypes[op])) // xastore
+	}
+
+	// the arithmetic instructions, which come in groups of int, long, float, double
+	for op := byte(0x60); op <= 0x73; op += 4 { // add, sub, mul, div, rem
+		simpleInstructions[op] = effect(&I, I, I)
+		simpleInstructions[op+1] = effect(&J, J, J)
+		simpleInstructions[op+2] = effect(&F, F, F)
+		simpleInstructions[op+3] = effect(&D, D, D)
+	}
+	simpleInstructions[0x74] = effect(&I, I) // ineg
+	simpleInstructions[0x75] = effect(&J, J)
+	simpleInstructions[0x76] = effect(&F, F)
+	simpleInstructions[0x77] = effect(&D, D)
+	for op := byte(0x78); op <= 0x7D; op += 2 { // shl, shr, ushr: the shift distance is an int
+		simpleInstructions[op] = effect(&I, I, I)
+		simpleInstructions[op+1] = effect(&J, I, J)
+	}
+	for op := byte(0x7E); op <= 0x83; op += 2 { // and, or, xor
+		simpleInstructions[op] = effect(&I, I, I)
+		simpleInstructions[op+1] = effect(&J, J, J)
+	}
+
+	// conversions
+	conversions := []struct {
+		from, to verifType
+	}{{I, J}, {I, F}, {I, D}, {J, I}, {J, F}, {J, D}, {F, I}, {F, J}, {F, D},
+		{D, I}, {D, J}, {D, F}, {I, I}, {I, I}, {I, I}} // i2l to d2f, then i2b, i2c, i2s
+	for i, conv := range conversions {
+		to := conv.to
+		simpleInstructions[byte(0x85+i)] = effect(&to, conv.from)
+	}
+
+	// comparisons
+	simpleInstructions[0x94] = effect(&I, J, J) // lcmp
+	simpleInstructions[0x95] = effect(&I, F, F) // fcmpl
+	simpleInstructions[0x96] = effect(&I, F, F) // fcmpg
+	simpleInstructions[0x97] = effect(&I, D, D) // dcmpl
+	simpleInstructions[0x98] = effect(&I, D, D) // dcmpg
+}
+
+// the types of the load and store instructions, by the offset of their opcodes from
+// those of iload and istore
+var localVariableTypes = []verifType{typeInt, typeLong, typeFloat, typeDouble, typeObject}
+
+// verifyInstruction checks the instruction at offset pc against the current type
+// state and then updates the type state to reflect the instruction's effect. Returns
+// whether the next instruction can be reached from this one.
+func (v *methodVerifier) verifyInstruction(pc int) (bool, error) {
+	op := v.code[pc]
+	if effect, ok := simpleInstructions[op]; ok {
+		for _, t := range effect.pops {
+			if _, err := v.pop(pc, t); err != nil {
+				return false, err
+			}
+		}
+		if effect.push != nil {
+			return true, v.push(pc, *effect.push)
+		}
+		return true, nil
+	}
+
+	switch {
+	case op >= 0x15 && op <= 0x19: // iload, lload, fload, dload, aload
+		return true, v.load(pc, v.u1(pc+1), localVariableTypes[op-0x15])
+	case op >= 0x1A && op <= 0x2D: // iload_0 to aload_3
+		return true, v.load(pc, int(op-0x1A)%4, localVariableTypes[(op-0x1A)/4])
+	case op >= 0x36 && op <= 0x3A: // istore, lstore, fstore, dstore, astore
+		return true, v.store(pc, v.u1(pc+1), localVariableTypes[op-0x36])
+	case op >= 0x3B && op <= 0x4E: // istore_0 to astore_3
+		return true, v.store(pc, int(op-0x3B)%4, localVariableTypes[(op-0x3B)/4])
+	case op >= 0x57 && op <= 0x5F: // the stack instructions
+		return true, v.verifyStackInstruction(pc, op)
+	case op >= 0x99 && op <= 0xA6, op == 0xC6, op == 0xC7: // the conditional branches
+		return true, v.verifyConditionalBranch(pc, op)
+	case op >= 0xAC && op <= 0xB1: // the returns
+		return false, v.verifyReturn(pc, op)
+	case op >= 0xB2 && op <= 0xB5: // getstatic, putstatic, getfield, putfield
+		return true, v.verifyFieldAccess(pc, op)
+	case op >= 0xB6 && op <= 0xBA: // the invokes
+		return true, v.verifyInvoke(pc, op)
+	}
+
+	var err error
+	switch op {
+	case 0x12, 0x13, 0x14: // ldc, ldc_w, ldc2_w
+		err = v.verifyLdc(pc, op)
+
+	case 0x32: // aaload
+		if _, err = v.pop(pc, typeInt); err == nil {
+			var array verifType
+			if array, err = v.popArray(pc); err == nil {
+				switch {
+				case array.tag == vtNull:
+					err = v.push(pc, typeNull)
+				case componentType(array).tag != vtRef:
+					err = v.fail(pc, "Bad type on operand stack: expected an array of references, found "+
+						array.String())
+				default:
+					err = v.push(pc, componentType(array))
+				}
+			}
+		}
+
+	case 0x33: // baload
+		if _, err = v.pop(pc, typeInt); err == nil {
+			if err = v.popByteOrBooleanArray(pc); err == nil {
+				err = v.push(pc, typeInt)
+			}
+		}
+
+	case 0x53: // aastore. Whether the value's type fits in the array is checked at run time.
+		if _, err = v.pop(pc, typeObject); err == nil {
+			if _, err = v.pop(pc, typeInt); err == nil {
+				var array verifType
+				if array, err = v.popArray(pc); err == nil && array.tag != vtNull && componentType(array).tag != vtRef {
+					err = v.fail(pc, "Bad type on operand stack: expected an array of references, found "+
+						array.String())
+				}
+			}
+		}
+
+	case 0x54: // bastore
+		if _, err = v.pop(pc, typeInt); err == nil {
+			if _, err = v.pop(pc, typeInt); err == nil {
+				err = v.popByteOrBooleanArray(pc)
+			}
+		}
+
+	case opIinc:
+		err = v.verifyIinc(pc, v.u1(pc+1))
+
+	case 0xA7: // goto
+		return false, v.checkBranch(pc, pc+v.s2(pc+1))
+
+	case 0xC8: // goto_w
+		return false, v.checkBranch(pc, pc+v.s4(pc+1))
+
+	case 0xA8, 0xA9, 0xC9: // jsr, ret, jsr_w
+		return false, v.fail(pc, "Illegal instruction found: jsr and ret are not allowed in class file version "+
+			strconv.Itoa(v.version))
+
+	case opTableswitch, opLookupswitch:
+		return false, v.verifySwitch(pc, op)
+
+	case opNew:
+		err = v.verifyNew(pc)
+
+	case 0xBC: // newarray
+		err = v.verifyNewarray(pc)
+
+	case 0xBD: // anewarray
+		className, ok := v.classNameAt(v.u2(pc + 1))
+		if !ok {
+			return false, v.fail(pc, "Illegal constant pool index")
+		}
+		if strings.Count(className, "[") >= 255 {
+			return false, v.fail(pc, "Array with too many dimensions")
+		}
+		if _, err = v.pop(pc, typeInt); err == nil {
+			err = v.push(pc, arrayOf(className))
+		}
+
+	case 0xBE: // arraylength
+		if _, err = v.popArray(pc); err == nil {
+			err = v.push(pc, typeInt)
+		}
+
+	case 0xBF: // athrow
+		_, err = v.pop(pc, refType("java/lang/Throwable"))
+		return false, err
+
+	case 0xC0, 0xC1: // checkcast, instanceof
+		className, ok := v.classNameAt(v.u2(pc + 1))
+		if !ok {
+			return false, v.fail(pc, "Illegal constant pool index")
+		}
+		if _, err = v.pop(pc, typeObject); err == nil {
+			if op == 0xC0 {
+				err = v.push(pc, refType(className))
+			} else {
+				err = v.push(pc, typeInt)
+			}
+		}
+
+	case 0xC2, 0xC3: // monitorenter, monitorexit
+		_, err = v.pop(pc, typeObject)
+
+	case opWide:
+		err = v.verifyWide(pc)
+
+	case 0xC5: // multianewarray
+		err = v.verifyMultianewarray(pc)
+
+	default:
+		err = v.fail(pc, "Bad instruction: 0x"+strconv.FormatInt(int64(op), 16))
+	}
+	return true, err
+}
+
+// popArray pops a reference to an array, or null
+func (v *methodVerifier) popArray(pc int) (verifType, error) {
+	t, err := v.popReference(pc)
+	if err == nil && t.tag != vtNull && !t.isArray() {
+		err = v.fail(pc, "Bad type on operand stack: expected an array, found "+t.String())
+	}
+	return t, err
+}
+
+// popByteOrBooleanArray pops a reference to a byte or boolean array, or null
+func (v *methodVerifier) popByteOrBooleanArray(pc int) error {
+	t, err := v.popArray(pc)
+	if err == nil && t.tag != vtNull && t.name != "[B" && t.name != "[Z" {
+		err = v.fail(pc, "Bad type on operand stack: expected a byte or boolean array, found "+t.String())
+	}
+	return err
+}
+
+// verifyStackInstruction checks pop, pop2, dup, dup_x1, dup_x2, dup2, dup2_x1,
+// dup2_x2, and swap. Their forms are defined by the number of slots they move, so that,
+// for example, dup2 duplicates either two ints or one long.
+func (v *methodVerifier) verifyStackInstruction(pc int, op byte) error {
+	switch op {
+	case 0x57, 0x58: // pop, pop2
+		_, err := v.popSlots(pc, int(op-0x56))
+		return err
+	case 0x5F: // swap
+		values, err := v.popSlots(pc, 1)
+		if err != nil {
+			return err
+		}
+		under, err := v.popSlots(pc, 1)
+		if err != nil {
+			return err
+		}
+		v.state.stack = append(append(v.state.stack, values...), under...)
+		return nil
+	}
+
+	// dup, dup_x1, dup_x2, dup2, dup2_x1, dup2_x2 copy the top 1 or 2 slots of the
+	// stack and insert the copy 0, 1, or 2 slots further down
+	dupSlots := 1 + int(op-0x59)/3
+	underSlots := int(op-0x59) % 3
+	values, err := v.popSlots(pc, dupSlots)
+	if err != nil {
+		return err
+	}
+	under, err := v.popSlots(pc, underSlots)
+	if err != nil {
+		return err
+	}
+	if v.state.stackSlots()+2*dupSlots+underSlots > v.maxStack {
+		return v.fail(pc, "Operand stack overflow")
+	}
+	v.state.stack = append(v.state.stack, values...)
+	v.state.stack = append(v.state.stack, under...)
+	v.state.stack = append(v.state.stack, values...)
+	return nil
+}
+
+// verifyConditionalBranch checks the if instructions, which pop one or two values
+func (v *methodVerifier) verifyConditionalBranch(pc int, op byte) error {
+	var err error
+	switch {
+	case op <= 0x9E: // ifeq, ifne, iflt, ifge, ifgt, ifle
+		_, err = v.pop(pc, typeInt)
+	case op <= 0xA4: // if_icmpeq to if_icmple
+		if _, err = v.pop(pc, typeInt); err == nil {
+			_, err = v.pop(pc, typeInt)
+		}
+	case op <= 0xA6: // if_acmpeq, if_acmpne
+		if _, err = v.popReference(pc); err == nil {
+			_, err = v.popReference(pc)
+		}
+	default: // ifnull, ifnonnull
+		_, err = v.popReference(pc)
+	}
+	if err != nil {
+		return err
+	}
+	return v.checkBranch(pc, pc+v.s2(pc+1))
+}
+
+// verifySwitch checks tableswitch and lookupswitch, which branch to one of several
+// targets based on an int
+func (v *methodVerifier) verifySwitch(pc int, op byte) error {
+	if _, err := v.pop(pc, typeInt); err != nil {
+		return err
+	}
+
+	base := pc + 1 + (3 - pc%4)
+	targets := []int{pc + v.s4(base)} // the default
+	if op == opTableswitch {
+		low, high := v.s4(base+4), v.s4(base+8)
+		for i := 0; i <= high-low; i++ {
+			targets = append(targets, pc+v.s4(base+12+4*i))
+		}
+	} else {
+		pairs := v.s4(base + 4)
+		for i := 0; i < pairs; i++ {
+			if i > 0 && v.s4(base+8+8*i) <= v.s4(base+8+8*(i-1)) {
+				return v.fail(pc, "Bad lookupswitch instruction: keys are not sorted")
+			}
+			targets = append(targets, pc+v.s4(base+12+8*i))
+		}
+	}
+
+	for _, target := range targets {
+		if err := v.checkBranch(pc, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyReturn checks that the value returned, if any, matches the method's return
+// type, and that a constructor has initialized this before it returns
+func (v *methodVerifier) verifyReturn(pc int, op byte) error {
+	if op == 0xB1 { // return
+		if v.returnType != "V" {
+			return v.fail(pc, "Method expects a return value")
+		}
+		if v.methName == "<init>" && v.state.thisUninit {
+			return v.fail(pc, "Constructor must call super() or this() before return")
+		}
+		return nil
+	}
+
+	if v.returnType == "V" {
+		return v.fail(pc, "Method does not expect a return value")
+	}
+	expected := typeFromDescriptor(v.returnType)
+	if expected.tag != localVariableTypes[op-0xAC].tag {
+		return v.fail(pc, "Wrong return type in function")
+	}
+	_, err := v.pop(pc, expected)
+	return err
+}
+
+// verifyFieldAccess checks getstatic, putstatic, getfield, and putfield
+func (v *methodVerifier) verifyFieldAccess(pc int, op byte) error {
+	className, _, desc, ok := v.memberRefAt(v.u2(pc+1), FieldRef)
+	if !ok {
+		return v.fail(pc, "Illegal constant pool index")
+	}
+	fieldType := typeFromDescriptor(desc)
+
+	switch op {
+	case 0xB2: // getstatic
+		return v.push(pc, fieldType)
+	case 0xB3: // putstatic
+		_, err := v.pop(pc, fieldType)
+		return err
+	case 0xB4: // getfield
+		if _, err := v.pop(pc, refType(className)); err != nil {
+			return err
+		}
+		return v.push(pc, fieldType)
+	}
+
+	// putfield. A constructor can set the fields declared in its class before it
+	// calls super() or this().
+	if _, err := v.pop(pc, fieldType); err != nil {
+		return err
+	}
+	if len(v.state.stack) > 0 && v.state.stack[len(v.state.stack)-1].tag == vtUninitThis &&
+		className == v.class.Name {
+		_, err := v.popReference(pc)
+		return err
+	}
+	_, err := v.pop(pc, refType(className))
+	return err
+}
+
+// verifyInvoke checks invokevirtual, invokespecial, invokestatic, invokeinterface, and
+// invokedynamic. Calling a constructor via invokespecial initializes the object on which
+// it's called.
+func (v *methodVerifier) verifyInvoke(pc int, op byte) error {
+	var className, methName, desc string
+	var ok bool
+	switch op {
+	case 0xB6: // invokevirtual
+		className, methName, desc, ok = v.memberRefAt(v.u2(pc+1), MethodRef)
+	case 0xB9: // invokeinterface
+		className, methName, desc, ok = v.memberRefAt(v.u2(pc+1), Interface)
+	case 0xBA: // invokedynamic
+		cp := &v.class.CP
+		cpIndex := v.u2(pc + 1)
+		if cpIndex >= 1 && cpIndex < len(cp.CpIndex) && cp.CpIndex[cpIndex].Type == InvokeDynamic &&
+			int(cp.CpIndex[cpIndex].Slot) < len(cp.InvokeDynamics) {
+			methName, desc, ok = v.nameAndTypeAt(int(cp.InvokeDynamics[cp.CpIndex[cpIndex].Slot].NameAndType))
+		}
+		if ok && (v.code[pc+3] != 0 || v.code[pc+4] != 0) {
+			return v.fail(pc, "Bad invokedynamic instruction")
+		}
+	default: // invokespecial, invokestatic, which can refer to interface methods
+		cpTypes := []int{MethodRef}
+		if v.version >= 52 {
+			cpTypes = append(cpTypes, Interface)
+		}
+		className, methName, desc, ok = v.memberRefAt(v.u2(pc+1), cpTypes...)
+	}
+	if !ok {
+		return v.fail(pc, "Illegal constant pool index")
+	}
+
+	params, returnType, ok := splitMethodDescriptor(desc)
+	if !ok {
+		return v.fail(pc, "Invalid method descriptor: "+desc)
+	}
+	if methName == "<clinit>" || (methName == "<init>" && (op != 0xB7 || returnType != "V")) {
+		return v.fail(pc, "Illegal call to internal method "+methName)
+	}
+
+	paramSlots := 0
+	for i := len(params) - 1; i >= 0; i-- {
+		t, err := v.pop(pc, typeFromDescriptor(params[i]))
+		if err != nil {
+			return err
+		}
+		paramSlots += t.size()
+	}
+
+	switch op {
+	case 0xB6: // invokevirtual
+		if _, err := v.pop(pc, refType(className)); err != nil {
+			return err
+		}
+	case 0xB9: // invokeinterface
+		if v.u1(pc+3) != paramSlots+1 || v.code[pc+4] != 0 {
+			return v.fail(pc, "Inconsistent args count operand in invokeinterface")
+		}
+		if _, err := v.pop(pc, typeObject); err != nil {
+			return err
+		}
+	case 0xB7: // invokespecial
+		if methName == "<init>" {
+			if err := v.initializeObject(pc, className); err != nil {
+				return err
+			}
+		} else if _, err := v.pop(pc, refType(v.class.Name)); err != nil {
+			return err
+		}
+	}
+
+	if returnType != "V" {
+		return v.push(pc, typeFromDescriptor(returnType))
+	}
+	return nil
+}
+
+// initializeObject pops the uninitialized object on which a constructor of className
+// is called and marks it as initialized wherever it appears in the type state
+func (v *methodVerifier) initializeObject(pc int, className string) error {
+	t, err := v.popReference(pc)
+	if err != nil {
+		return err
+	}
+
+	var initialized verifType
+	switch t.tag {
+	case vtUninitThis: // a constructor calling super() or this()
+		if className != v.class.Name && className != v.class.Superclass {
+			return v.fail(pc, "Bad <init> method call: "+className+" is not this class or its superclass")
+		}
+		initialized = refType(v.class.Name)
+		v.state.thisUninit = false
+	case vtUninit: // an object created by new
+		newClass, _ := v.classNameAt(v.u2(t.offset + 1))
+		if className != newClass {
+			return v.fail(pc, "Bad <init> method call: expected a constructor of "+newClass)
+		}
+		initialized = refType(newClass)
+	default:
+		return v.fail(pc, "Bad operand type when invoking <init>: "+t.String())
+	}
+
+	v.replaceType(t, initialized)
+	return nil
+}
+
+// verifyLdc checks ldc, ldc_w, and ldc2_w, which push a constant from the CP
+func (v *methodVerifier) verifyLdc(pc int, op byte) error {
+	cpIndex := v.u1(pc + 1)
+	if op != 0x12 {
+		cpIndex = v.u2(pc + 1)
+	}
+	cp := &v.class.CP
+	if cpIndex < 1 || cpIndex >= len(cp.CpIndex) {
+		return v.fail(pc, "Illegal constant pool index")
+	}
+
+	var t verifType
+	switch cp.CpIndex[cpIndex].Type {
+	case IntConst:
+		t = typeInt
+	case FloatConst:
+		t = typeFloat
+	case LongConst:
+		t = typeLong
+	case DoubleConst:
+		t = typeDouble
+	case UTF8: // string constants are converted to UTF8 entries when the class is posted
+		t = refType("java/lang/String")
+	case ClassRef:
+		t = refType("java/lang/Class")
+	case MethodType:
+		t = refType("java/lang/invoke/MethodType")
+	case MethodHandle:
+		t = refType("java/lang/invoke/MethodHandle")
+	case Dynamic:
+		if int(cp.CpIndex[cpIndex].Slot) < len(cp.Dynamics) {
+			if _, desc, ok := v.nameAndTypeAt(int(cp.Dynamics[cp.CpIndex[cpIndex].Slot].NameAndType)); ok {
+				t = typeFromDescriptor(desc)
+			}
+		}
+	}
+
+	if t.tag == vtTop || t.isCat2() != (op == 0x14) {
+		return v.fail(pc, "Illegal type at constant pool entry "+strconv.Itoa(cpIndex))
+	}
+	return v.push(pc, t)
+}
+
+// verifyIinc checks that the local variable incremented by iinc is an int
+func (v *methodVerifier) verifyIinc(pc, index int) error {
+	if index >= v.maxLocals {
+		return v.fail(pc, "Illegal local variable number")
+	}
+	if v.state.locals[index].tag != vtInt {
+		return v.fail(pc, "Bad local variable type: expected integer, found "+v.state.locals[index].String())
+	}
+	return nil
+}
+
+// verifyWide checks the instruction that wide modifies to take a 2-byte local index
+func (v *methodVerifier) verifyWide(pc int) error {
+	op := v.code[pc+1]
+	index := v.u2(pc + 2)
+	switch {
+	case op >= 0x15 && op <= 0x19:
+		return v.load(pc, index, localVariableTypes[op-0x15])
+	case op >= 0x36 && op <= 0x3A:
+		return v.store(pc, index, localVariableTypes[op-0x36])
+	case op == opIinc:
+		return v.verifyIinc(pc, index)
+	case op == 0xA9: // ret
+		return v.fail(pc, "Illegal instruction found: jsr and ret are not allowed in class file version "+
+			strconv.Itoa(v.version))
+	}
+	return v.fail(pc, "Bad wide instruction")
+}
+
+// verifyNew checks new, which pushes an uninitialized object. The same new instruction
+// must not have created any other uninitialized object in the type state.
+func (v *methodVerifier) verifyNew(pc int) error {
+	className, ok := v.classNameAt(v.u2(pc + 1))
+	if !ok || strings.HasPrefix(className, "[") {
+		return v.fail(pc, "Illegal new instruction")
+	}
+
+	uninit := verifType{tag: vtUninit, offset: pc}
+	for _, t := range v.state.stack {
+		if t == uninit {
+			return v.fail(pc, "Uninitialized object exists on the operand stack at new")
+		}
+	}
+	v.replaceType(uninit, typeTop)
+	return v.push(pc, uninit)
+}
+
+// the array types created by newarray, by its atype operand
+var newarrayTypes = map[int]string{4: "[Z", 5: "[C", 6: "[F", 7: "[D", 8: "[B", 9: "[S", 10: "[I", 11: "[J"}
+
+func (v *methodVerifier) verifyNewarray(pc int) error {
+	arrayType, ok := newarrayTypes[v.u1(pc+1)]
+	if !ok {
+		return v.fail(pc, "Illegal newarray instruction: invalid type "+strconv.Itoa(v.u1(pc+1)))
+	}
+	if _, err := v.pop(pc, typeInt); err != nil {
+		return err
+	}
+	return v.push(pc, refType(arrayType))
+}
+
+// verifyMultianewarray checks multianewarray, which pops the size of each of its
+// dimensions. The array type must have at least that many dimensions.
+func (v *methodVerifier) verifyMultianewarray(pc int) error {
+	className, ok := v.classNameAt(v.u2(pc + 1))
+	dimensions := v.u1(pc + 3)
+	if !ok || dimensions < 1 || len(className)-len(strings.TrimLeft(className, "[")) < dimensions {
+		return v.fail(pc, "Illegal multianewarray instruction")
+	}
+	for i := 0; i < dimensions; i++ {
+		if _, err := v.pop(pc, typeInt); err != nil {
+			return err
+		}
+	}
+	return v.push(pc, refType(className))
+}
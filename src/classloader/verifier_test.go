@@ -0,0 +1,323 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package classloader
+
+import (
+	"bytes"
+	"errors"
+	"jacobin/globals"
+	"jacobin/log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// the CP entries of the classes built by verifierTestClass
+const (
+	vtcThisClass   = 2 // ClassRef: Test
+	vtcObjectInit  = 7 // MethodRef: java/lang/Object.<init>()V
+	vtcStringClass = 9 // ClassRef: java/lang/String
+)
+
+// builds a class named Test, a subclass of java/lang/Object, with a single method
+// that has the given name, descriptor, access flags, and code. stackMap is the content
+// of the method's StackMapTable attribute, which is omitted if stackMap is nil.
+func verifierTestClass(name, desc string, flags, maxStack, maxLocals int, code, stackMap []byte) *ClData {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.WARNING)
+	InitMethodArea()
+
+	cp := CPool{Utf8Refs: []string{"Test", name, desc, "StackMapTable", "java/lang/Object",
+		"<init>", "()V", "java/lang/String"}}
+	cp.CpIndex = []CpEntry{
+		{Type: Dummy},
+		{Type: UTF8, Slot: 0},        // 1: Test
+		{Type: ClassRef, Slot: 0},    // 2: class Test
+		{Type: UTF8, Slot: 4},        // 3: java/lang/Object
+		{Type: ClassRef, Slot: 1},    // 4: class java/lang/Object
+		{Type: UTF8, Slot: 5},        // 5: <init>
+		{Type: UTF8, Slot: 6},        // 6: ()V
+		{Type: MethodRef, Slot: 0},   // 7: java/lang/Object.<init>()V
+		{Type: NameAndType, Slot: 0}, // 8: <init>()V
+		{Type: ClassRef, Slot: 2},    // 9: class java/lang/String
+		{Type: UTF8, Slot: 7},        // 10: java/lang/String
+	}
+	cp.ClassRefs = []uint16{1, 3, 10}
+	cp.MethodRefs = []MethodRefEntry{{ClassIndex: 4, NameAndType: 8}}
+	cp.NameAndTypes = []NameAndTypeEntry{{NameIndex: 5, DescIndex: 6}}
+
+	meth := Method{AccessFlags: flags, Name: 1, Desc: 2,
+		CodeAttr: CodeAttrib{MaxStack: maxStack, MaxLocals: maxLocals, Code: code}}
+	if stackMap != nil {
+		meth.CodeAttr.Attributes = []Attr{{AttrName: 3, AttrSize: len(stackMap), AttrContent: stackMap}}
+	}
+	return &ClData{Name: "Test", Superclass: "java/lang/Object", CP: cp, Methods: []Method{meth}}
+}
+
+// runs verifyClass, hiding the VerifyError it shows the user
+func verifyTestClass(class *ClData) error {
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	err := verifyClass(class, 61)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+	return err
+}
+
+func expectVerifyError(t *testing.T, err error, msg string) {
+	if err == nil {
+		t.Errorf("Expected a VerifyError with %q, but the method was verified", msg)
+		return
+	}
+	var errBlk *GErrBlk
+	if !errors.As(err, &errBlk) || errBlk.ExceptionType != "java/lang/VerifyError" || !strings.Contains(errBlk.ErrMsg, msg) {
+		t.Errorf("Expected a VerifyError with %q, got: %s", msg, err.Error())
+	}
+}
+
+// static int test(int i) { return i + 1; }
+func TestVerifyValidMethod(t *testing.T) {
+	code := []byte{0x1A, 0x04, 0x60, 0xAC} // iload_0, iconst_1, iadd, ireturn
+	class := verifierTestClass("test", "(I)I", AccStatic, 2, 1, code, nil)
+	if err := verifyTestClass(class); err != nil {
+		t.Errorf("Got unexpected error: %s", err.Error())
+	}
+}
+
+func TestVerifyBadTypeOnStack(t *testing.T) {
+	code := []byte{0x03, 0x0B, 0x60, 0xAC} // iconst_0, fconst_0, iadd, ireturn
+	class := verifierTestClass("test", "()I", AccStatic, 2, 0, code, nil)
+	expectVerifyError(t, verifyTestClass(class), "at offset 2: Bad type on operand stack: expected integer, found float")
+}
+
+func TestVerifyStackUnderflowAndOverflow(t *testing.T) {
+	code := []byte{0x04, 0x60, 0xAC} // iconst_1, iadd, ireturn
+	class := verifierTestClass("test", "()I", AccStatic, 2, 0, code, nil)
+	expectVerifyError(t, verifyTestClass(class), "Operand stack underflow")
+
+	code = []byte{0x04, 0x04, 0x60, 0xAC} // iconst_1, iconst_1, iadd, ireturn with a max stack of 1
+	class = verifierTestClass("test", "()I", AccStatic, 1, 0, code, nil)
+	expectVerifyError(t, verifyTestClass(class), "Operand stack overflow")
+}
+
+func TestVerifyBadLocalVariable(t *testing.T) {
+	code := []byte{0x1B, 0xAC} // iload_1, ireturn, with 1 local
+	class := verifierTestClass("test", "(I)I", AccStatic, 1, 1, code, nil)
+	expectVerifyError(t, verifyTestClass(class), "Illegal local variable number")
+
+	code = []byte{0x1A, 0xAC} // iload_0 of a float, ireturn
+	class = verifierTestClass("test", "(F)I", AccStatic, 1, 1, code, nil)
+	expectVerifyError(t, verifyTestClass(class), "Bad local variable type: expected integer, found float")
+}
+
+func TestVerifyFallingOffTheEnd(t *testing.T) {
+	code := []byte{0x04, 0x57} // iconst_1, pop
+	class := verifierTestClass("test", "()V", AccStatic, 1, 0, code, nil)
+	expectVerifyError(t, verifyTestClass(class), "Falling off the end of the code")
+}
+
+func TestVerifyWrongReturnType(t *testing.T) {
+	code := []byte{0x04, 0xAC} // iconst_1, ireturn in a void method
+	class := verifierTestClass("test", "()V", AccStatic, 1, 0, code, nil)
+	expectVerifyError(t, verifyTestClass(class), "Method does not expect a return value")
+}
+
+// static int test(int i) { return i == 0 ? 1 : 0; }, which requires a stack map frame
+// at the target of the branch
+func TestVerifyBranchWithStackMap(t *testing.T) {
+	// 0: iload_0, 1: ifeq 6, 4: iconst_0, 5: ireturn, 6: iconst_1, 7: ireturn
+	code := []byte{0x1A, 0x99, 0x00, 0x05, 0x03, 0xAC, 0x04, 0xAC}
+
+	class := verifierTestClass("test", "(I)I", AccStatic, 1, 1, code, nil)
+	expectVerifyError(t, verifyTestClass(class), "Expecting a stack map frame at branch target 6")
+
+	stackMap := []byte{0x00, 0x01, 6} // 1 entry: same_frame at offset 6
+	class = verifierTestClass("test", "(I)I", AccStatic, 1, 1, code, stackMap)
+	if err := verifyTestClass(class); err != nil {
+		t.Errorf("Got unexpected error: %s", err.Error())
+	}
+
+	// a frame that has an int on the stack at offset 6, where the stack is empty
+	stackMap = []byte{0x00, 0x01, 64 + 6, itemInteger}
+	class = verifierTestClass("test", "(I)I", AccStatic, 2, 1, code, stackMap)
+	expectVerifyError(t, verifyTestClass(class), "Type mismatch with the stack map frame at branch target 6")
+}
+
+func TestVerifyStackMapFormatErrors(t *testing.T) {
+	code := []byte{0x1A, 0x99, 0x00, 0x05, 0x03, 0xAC, 0x04, 0xAC}
+
+	stackMap := []byte{0x00, 0x01, 200} // a reserved frame type
+	class := verifierTestClass("test", "(I)I", AccStatic, 1, 1, code, stackMap)
+	expectVerifyError(t, verifyTestClass(class), "reserved frame type 200")
+
+	stackMap = []byte{0x00, 0x01, 2} // a frame in the middle of the ifeq instruction
+	class = verifierTestClass("test", "(I)I", AccStatic, 1, 1, code, stackMap)
+	expectVerifyError(t, verifyTestClass(class), "StackMapTable error: bad offset")
+
+	stackMap = []byte{0x00, 0x02, 6} // too few entries
+	class = verifierTestClass("test", "(I)I", AccStatic, 1, 1, code, stackMap)
+	expectVerifyError(t, verifyTestClass(class), "StackMapTable format error")
+}
+
+// a constructor must call super() or this() before it returns
+func TestVerifyConstructor(t *testing.T) {
+	code := []byte{0xB1} // return
+	class := verifierTestClass("<init>", "()V", AccPublic, 1, 1, code, nil)
+	expectVerifyError(t, verifyTestClass(class), "Constructor must call super() or this() before return")
+
+	code = []byte{0x2A, 0xB7, 0x00, vtcObjectInit, 0xB1} // aload_0, invokespecial Object.<init>, return
+	class = verifierTestClass("<init>", "()V", AccPublic, 1, 1, code, nil)
+	if err := verifyTestClass(class); err != nil {
+		t.Errorf("Got unexpected error: %s", err.Error())
+	}
+}
+
+// an object created by new can't be used until its constructor has been called
+func TestVerifyUninitializedObject(t *testing.T) {
+	code := []byte{0xBB, 0x00, vtcThisClass, 0xB0} // new Test, areturn
+	class := verifierTestClass("test", "()Ljava/lang/Object;", AccStatic, 1, 0, code, nil)
+	expectVerifyError(t, verifyTestClass(class), "expected 'java/lang/Object', found uninitialized(0)")
+}
+
+// a reference is assignable to the types of its superclasses only
+func TestVerifyReferenceAssignability(t *testing.T) {
+	code := []byte{0x2A, 0xB0} // aload_0, areturn
+	class := verifierTestClass("test", "(LDog;)LAnimal;", AccStatic, 1, 1, code, nil)
+	insertLookupTestClass("java/lang/Object", "", false, map[string]*Method{})
+	insertLookupTestClass("Animal", "java/lang/Object", false, map[string]*Method{})
+	insertLookupTestClass("Dog", "Animal", false, map[string]*Method{})
+	if err := verifyTestClass(class); err != nil {
+		t.Errorf("Got unexpected error: %s", err.Error())
+	}
+
+	class.CP.Utf8Refs[2] = "(LAnimal;)LDog;"
+	expectVerifyError(t, verifyTestClass(class), "expected 'Dog', found 'Animal'")
+
+	class.CP.Utf8Refs[2] = "([LDog;)[LAnimal;" // arrays are covariant
+	if err := verifyTestClass(class); err != nil {
+		t.Errorf("Got unexpected error: %s", err.Error())
+	}
+
+	class.CP.Utf8Refs[2] = "([I)[J"
+	expectVerifyError(t, verifyTestClass(class), "expected '[J', found '[I'")
+}
+
+// jsr and ret are not allowed in class files of version 51 and later
+func TestVerifyJsrNotAllowed(t *testing.T) {
+	code := []byte{0xA8, 0x00, 0x03, 0xB1} // jsr 3, return
+	class := verifierTestClass("test", "()V", AccStatic, 1, 0, code, nil)
+	expectVerifyError(t, verifyTestClass(class), "Illegal instruction found: jsr and ret")
+}
+
+// the classes of the bootstrap classloader and the JDK's classes that the application
+// classloader loads are verified only at the all level
+func TestVerificationRequired(t *testing.T) {
+	globals.InitGlobals("test")
+	_ = Init()
+	prevMap := JMODMAP
+	JMODMAP = map[string]string{"java/lang/Thing.class": "java.base.jmod"}
+	defer func() { JMODMAP = prevMap }()
+
+	expected := map[int][3]bool{ // level: bootstrap, app's JDK class, app's own class
+		globals.VerifyNone:   {false, false, false},
+		globals.VerifyRemote: {false, false, true},
+		globals.VerifyAll:    {true, true, true},
+	}
+	for level, required := range expected {
+		globals.GetGlobalRef().VerifyLevel = level
+		if verificationRequired(&BootstrapCL, "java/lang/Thing") != required[0] ||
+			verificationRequired(&AppCL, "java/lang/Thing") != required[1] ||
+			verificationRequired(&AppCL, "Test") != required[2] {
+			t.Errorf("At verification level %d, expected verification of bootstrap, app JDK, app classes: %v",
+				level, required)
+		}
+	}
+}
+
+// a verified class is posted to the method area with the status V
+func TestParseAndPostVerifiedClass(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.SEVERE)
+	_ = Init()
+	globals.GetGlobalRef().VerifyLevel = globals.VerifyAll
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	normalStdout := os.Stdout
+	_, wout, _ := os.Pipe()
+	os.Stdout = wout
+
+	className, err := ParseAndPostClass(&AppCL, "Class.class", ClassBytes)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+	_ = wout.Close()
+	os.Stdout = normalStdout
+
+	if err != nil {
+		t.Fatalf("Got unexpected error verifying java/lang/Class: %s", err.Error())
+	}
+	if k := MethAreaFetch(className); k == nil || k.Status != 'V' {
+		t.Errorf("Expected %s to have been posted as verified", className)
+	}
+}
+
+// a class that fails verification is not posted to the method area, and the VerifyError
+// is the error that's thrown when the class is first used, here by an LDC of the class
+func TestClassFailingVerificationThrowsVerifyError(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	_ = log.SetLogLevel(log.SEVERE)
+	_ = Init()
+	InitMethodArea()
+	globals.GetGlobalRef().VerifyLevel = globals.VerifyAll
+
+	// the class is not one of the JDK's, so it's loaded from a file
+	prevMap, prevSize := JMODMAP, jmodMapSize
+	JMODMAP, jmodMapSize = map[string]string{"java/lang/Object.class": "java.base.jmod"}, 1
+	defer func() { JMODMAP, jmodMapSize = prevMap, prevSize }()
+
+	// the constructor's aload_0 (this) becomes fload_0, which expects a float in local 0
+	classBytes := append([]byte{}, ClassBytes...)
+	pos := bytes.Index(classBytes, []byte{0x2A, 0xB7}) // aload_0, invokespecial
+	if pos < 0 {
+		t.Fatalf("Expected to find the code of the constructor")
+	}
+	classBytes[pos] = 0x22 // fload_0
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "java", "lang"), 0755); err != nil {
+		t.Fatalf("Got unexpected error creating the class's directory: %s", err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(dir, "java", "lang", "Class.class"), classBytes, 0644); err != nil {
+		t.Fatalf("Got unexpected error writing the class file: %s", err.Error())
+	}
+	prevDir, _ := os.Getwd()
+	_ = os.Chdir(dir)
+	defer func() { _ = os.Chdir(prevDir) }()
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_, err := LoadClassMirror("java/lang/Class")
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	expectVerifyError(t, err, "Bad local variable type")
+	if MethAreaFetch("java/lang/Class") != nil {
+		t.Errorf("Expected the class that failed verification not to be in the method area")
+	}
+}
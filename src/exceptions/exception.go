@@ -181,11 +181,14 @@ const (
 	XMLStreamException
 
 	// Java exceptions
+	AbstractMethodError
 	AnnotationFormatError
 	AssertionError
 	AWTError
 	CoderMalfunctionError
 	FactoryConfigurationError
+	IllegalAccessError
+	IncompatibleClassChangeError
 	IOError
 	LinkageError
 	SchemaFactoryConfigurationError
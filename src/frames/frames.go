@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"jacobin/classloader"
 	"jacobin/log"
+	"jacobin/object"
 	"unsafe"
 )
 
@@ -30,15 +31,19 @@ type Number interface {
 // second stack entry for these data items.
 type Frame struct {
 	Thread   int
-	MethName string             // method name
-	ClName   string             // class name
-	Meth     []byte             // bytecode of method
-	CP       *classloader.CPool // constant pool of class
-	Locals   []interface{}      // local variables
-	OpStack  []interface{}      // operand stack
-	TOS      int                // top of the operand stack
-	PC       int                // program counter (index into the bytecode of the method)
-	Ftype    byte               // type of method in frame: 'J' = java, 'G' = Golang, 'N' = native
+	MethName string                      // method name
+	MethType string                      // method descriptor, e.g., (I)V
+	ClName   string                      // class name
+	Meth     []byte                      // bytecode of method
+	CP       *classloader.CPool          // constant pool of class
+	Locals   []interface{}               // local variables
+	OpStack  []interface{}               // operand stack
+	TOS      int                         // top of the operand stack
+	PC       int                         // program counter (index into the bytecode of the method)
+	Ftype    byte                        // type of method in frame: 'J' = java, 'G' = Golang, 'N' = native
+	ExcTable []classloader.CodeException // the method's exception-handler table (can be empty)
+	LineNums []classloader.LineNumber    // the method's LineNumberTable (can be empty)
+	SyncObj  *object.Object              // object whose monitor a synchronized method holds, else nil
 }
 
 // CreateFrameStack creates a stack of frames. Implemented as a list in which
@@ -42,9 +42,9 @@ type Globals struct {
 	Options       map[string]Option
 
 	// ---- classloading items ----
-	MaxJavaVersion    int // the Java version as commonly known, i.e. Java 11
-	MaxJavaVersionRaw int // the Java version as it appears in bytecode i.e., 55 (= Java 11)
-	VerifyLevel       int
+	MaxJavaVersion    int   // the Java version as commonly known, i.e. Java 11
+	MaxJavaVersionRaw int   // the Java version as it appears in bytecode i.e., 55 (= Java 11)
+	VerifyLevel       int   // which classes are verified: VerifyNone, VerifyRemote, or VerifyAll
 	AssertionsEnabled int64 // are assertions enabled? It's boolean, represented as an int64 (0,1)
 
 	// ---- Java Home and Version ----
@@ -55,7 +55,8 @@ type Globals struct {
 	JacobinHome string
 
 	// ---- thread management ----
-	Threads ThreadList // list of all app execution threads
+	Threads       ThreadList // list of all app execution threads
+	MaxFrameDepth int        // the maximum number of frames on a thread's frame stack
 
 	// ---- execution context ----
 	JacobinBuildData map[string]string
@@ -79,6 +80,23 @@ type Globals struct {
 	FileEncoding string // what file encoding are we using?
 }
 
+// The maximum depth of a thread's frame stack is either specified directly with
+// -Xmaxframes or derived from the thread stack size specified with -Xss, of which
+// each frame is estimated to take up StackBytesPerFrame. The default depth is the
+// one derived from the JDK's default thread stack size of 1MB.
+const (
+	StackBytesPerFrame   = 64
+	DefaultMaxFrameDepth = 1024 * 1024 / StackBytesPerFrame
+)
+
+// The levels of bytecode verification, set by -Xverify. At the default level, VerifyRemote,
+// only the classes that don't come from the JDK are verified.
+const (
+	VerifyNone = iota
+	VerifyRemote
+	VerifyAll
+)
+
 // LoaderWg is a wait group for various channels used for parallel loading of classes.
 var LoaderWg sync.WaitGroup
 
@@ -99,7 +117,9 @@ func InitGlobals(progName string) Globals {
 		StartingJar:        "",
 		MaxJavaVersion:     17, // this value and MaxJavaVersionRaw must *always* be in sync
 		MaxJavaVersionRaw:  61, // this value and MaxJavaVersion must *always* be in sync
-		Threads:            ThreadList{list.New(), sync.Mutex{}},
+		VerifyLevel:        VerifyRemote,
+		Threads:            ThreadList{list.New(), sync.Mutex{}, 0},
+		MaxFrameDepth:      DefaultMaxFrameDepth,
 		JacobinBuildData:   nil,
 		StrictJDK:          false,
 		AssertionsEnabled:  types.JavaBoolFalse,
@@ -130,9 +150,11 @@ func InitGlobals(progName string) Globals {
 }
 
 // ThreadList contains a list of all app execution threads and a mutex for adding new threads to the list.
+// Thread IDs are assigned from NextID, so they are never reused, even after a thread ends.
 type ThreadList struct {
 	ThreadsList  *list.List
 	ThreadsMutex sync.Mutex
+	NextID       int // the ID of the next thread added to the list
 }
 
 // GetGlobalRef returns a pointer to the singleton instance of Globals
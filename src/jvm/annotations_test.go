@@ -0,0 +1,346 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/object"
+	"jacobin/types"
+	"testing"
+)
+
+// annotationWriter writes the contents of annotation attributes, adding the CP entries
+// they refer to to the CP of the class whose attributes they are
+type annotationWriter struct {
+	c *messageTestCP
+}
+
+func u2(v uint16) []byte {
+	return []byte{byte(v >> 8), byte(v)}
+}
+
+// joins the values, preceded by their count, as in an array or a list of annotations
+func counted(values ...[]byte) []byte {
+	content := u2(uint16(len(values)))
+	for _, value := range values {
+		content = append(content, value...)
+	}
+	return content
+}
+
+// the element-value pairs are alternately names and values
+func (w annotationWriter) annotation(typeDesc string, pairs ...interface{}) []byte {
+	content := append(u2(w.c.utf8(typeDesc)), u2(uint16(len(pairs)/2))...)
+	for i := 0; i < len(pairs); i += 2 {
+		content = append(content, u2(w.c.utf8(pairs[i].(string)))...)
+		content = append(content, pairs[i+1].([]byte)...)
+	}
+	return content
+}
+
+func (w annotationWriter) str(s string) []byte {
+	return append([]byte{'s'}, u2(w.c.utf8(s))...)
+}
+
+func (w annotationWriter) int(v int32) []byte {
+	w.c.cp.IntConsts = append(w.c.cp.IntConsts, v)
+	return append([]byte{'I'}, u2(w.c.add(classloader.IntConst, len(w.c.cp.IntConsts)-1))...)
+}
+
+func (w annotationWriter) enum(typeDesc, name string) []byte {
+	return append(append([]byte{'e'}, u2(w.c.utf8(typeDesc))...), u2(w.c.utf8(name))...)
+}
+
+func (w annotationWriter) array(values ...[]byte) []byte {
+	return append([]byte{'['}, counted(values...)...)
+}
+
+func (w annotationWriter) attr(name string, content []byte) classloader.Attr {
+	w.c.utf8(name)
+	return classloader.Attr{AttrName: uint16(len(w.c.cp.Utf8Refs) - 1), AttrSize: len(content), AttrContent: content}
+}
+
+// sets up the following classes and the Go implementations of the reflection methods:
+//
+//	enum Level { LOW, HIGH }
+//
+//	@Inherited @Retention(RUNTIME) @interface Marker {}
+//
+//	@Retention(RUNTIME) @interface Config {
+//	    String name();
+//	    int size() default 3;
+//	    String[] tags() default {};
+//	    Level level() default Level.LOW;
+//	}
+//
+//	@Marker class Base {}
+//
+//	@Config(name = "svc", tags = {"a", "b"}, level = Level.HIGH)
+//	class Service extends Base {
+//	    @Config(name = "port", size = 8080) int port;
+//	    @Config(name = "run") void run(int n, @Marker String s) {}
+//	    @Config void broken() {} // compiled when Config.name() had a default
+//	}
+func setupAnnotationTest() map[string]*object.Object {
+	setupReflectionTest()
+	levels := map[string]*object.Object{}
+	for _, name := range []string{"LOW", "HIGH"} {
+		className := "Level"
+		levels[name] = object.MakeEmptyObject()
+		levels[name].Klass = &className
+		_ = classloader.AddStatic("Level."+name, classloader.Static{Type: "LLevel;", Value: levels[name]})
+	}
+
+	insert := func(c *messageTestCP, data classloader.ClData) {
+		if data.MethodTable == nil {
+			data.MethodTable = map[string]*classloader.Method{}
+		}
+		data.CP = c.cp
+		classloader.MethAreaInsert(data.Name, &classloader.Klass{Status: 'X', Loader: "app", Data: &data})
+	}
+	method := func(c *messageTestCP, accessFlags int, name, desc string, attrs ...classloader.Attr) classloader.Method {
+		c.utf8(name)
+		nameIndex := uint16(len(c.cp.Utf8Refs) - 1)
+		c.utf8(desc)
+		return classloader.Method{AccessFlags: accessFlags, Name: nameIndex, Desc: uint16(len(c.cp.Utf8Refs) - 1),
+			Attributes: attrs, CodeAttr: classloader.CodeAttrib{MaxStack: 1, MaxLocals: 3, Code: []byte{RETURN}}}
+	}
+
+	c := newMessageTestCP()
+	insert(c, classloader.ClData{Name: "Level", Superclass: "java/lang/Enum", ClInit: types.ClInitRun})
+
+	c = newMessageTestCP()
+	annotation := classloader.ClData{Name: "java/lang/annotation/Annotation", Superclass: "java/lang/Object",
+		ClInit: types.NoClinit}
+	annotation.Access.ClassIsInterface = true
+	insert(c, annotation)
+
+	c = newMessageTestCP()
+	w := annotationWriter{c}
+	marker := classloader.ClData{Name: "Marker", Superclass: "java/lang/Object", ClInit: types.NoClinit}
+	marker.Access.ClassIsInterface = true
+	marker.Attributes = []classloader.Attr{w.attr("RuntimeVisibleAnnotations",
+		counted(w.annotation("Ljava/lang/annotation/Inherited;")))}
+	insert(c, marker)
+
+	c = newMessageTestCP()
+	w = annotationWriter{c}
+	config := classloader.ClData{Name: "Config", Superclass: "java/lang/Object", ClInit: types.NoClinit}
+	config.Access.ClassIsInterface = true
+	abstract := classloader.AccPublic | classloader.AccAbstract
+	config.Methods = []classloader.Method{
+		method(c, abstract, "name", "()Ljava/lang/String;"),
+		method(c, abstract, "size", "()I", w.attr("AnnotationDefault", w.int(3))),
+		method(c, abstract, "tags", "()[Ljava/lang/String;", w.attr("AnnotationDefault", w.array())),
+		method(c, abstract, "level", "()LLevel;", w.attr("AnnotationDefault", w.enum("LLevel;", "LOW"))),
+	}
+	insert(c, config)
+
+	c = newMessageTestCP()
+	w = annotationWriter{c}
+	insert(c, classloader.ClData{Name: "Base", Superclass: "java/lang/Object", ClInit: types.NoClinit,
+		Attributes: []classloader.Attr{w.attr("RuntimeVisibleAnnotations", counted(w.annotation("LMarker;")))}})
+
+	c = newMessageTestCP()
+	w = annotationWriter{c}
+	service := classloader.ClData{Name: "Service", Superclass: "Base", ClInit: types.NoClinit}
+	service.Attributes = []classloader.Attr{w.attr("RuntimeVisibleAnnotations", counted(w.annotation("LConfig;",
+		"name", w.str("svc"), "tags", w.array(w.str("a"), w.str("b")), "level", w.enum("LLevel;", "HIGH"))))}
+	c.utf8("port")
+	service.Fields = []classloader.Field{{Name: uint16(len(c.cp.Utf8Refs) - 1), Desc: uint16(len(c.cp.Utf8Refs)),
+		Attributes: []classloader.Attr{w.attr("RuntimeVisibleAnnotations",
+			counted(w.annotation("LConfig;", "name", w.str("port"), "size", w.int(8080))))}}}
+	c.utf8("I")
+	paramAnnotations := append([]byte{2}, append(counted(), counted(w.annotation("LMarker;"))...)...)
+	service.Methods = []classloader.Method{
+		method(c, classloader.AccPublic, "run", "(ILjava/lang/String;)V",
+			w.attr("RuntimeVisibleAnnotations", counted(w.annotation("LConfig;", "name", w.str("run")))),
+			w.attr("RuntimeVisibleParameterAnnotations", paramAnnotations)),
+		method(c, classloader.AccPublic, "broken", "()V",
+			w.attr("RuntimeVisibleAnnotations", counted(w.annotation("LConfig;")))),
+	}
+	insert(c, service)
+	return levels
+}
+
+// returns the member of the class that's found by calling the Class method with the given
+// name and type with the given argument
+func declaredMember(t *testing.T, className, name, desc string, args ...interface{}) *object.Object {
+	args = append([]interface{}{classloader.GetClassMirror(className)}, args...)
+	member, exc := callReflectionMethod(t, "java/lang/Class", name, desc, args...)
+	if exc != nil {
+		t.Fatalf("Class.%s(): Got unexpected exception: %s", name, *exc.Klass)
+	}
+	return member.(*object.Object)
+}
+
+// the annotations of a class are proxies that implement the annotation interface and
+// whose element methods return the values of the elements, or their defaults
+func TestAnnotationProxies(t *testing.T) {
+	levels := setupAnnotationTest()
+	getAnnotation := "(Ljava/lang/Class;)Ljava/lang/annotation/Annotation;"
+
+	config, exc := callReflectionMethod(t, "java/lang/Class", "getAnnotation", getAnnotation,
+		classloader.GetClassMirror("Service"), classloader.GetClassMirror("Config"))
+	if exc != nil {
+		t.Fatalf("Class.getAnnotation(): Got unexpected exception: %s", *exc.Klass)
+	}
+	proxy := config.(*object.Object)
+	if !classloader.IsAssignableTo(*proxy.Klass, "Config") ||
+		!classloader.IsAssignableTo(*proxy.Klass, "java/lang/Object") {
+		t.Fatalf("Class.getAnnotation(): Expected an instance of Config, got: %s", *proxy.Klass)
+	}
+
+	if name, _ := callReflectionMethod(t, "Config", "name", "()Ljava/lang/String;", proxy); goString(name) != "svc" {
+		t.Errorf("Config.name(): Expected svc, got: %v", name)
+	}
+	if size, _ := callReflectionMethod(t, "Config", "size", "()I", proxy); size != int64(3) {
+		t.Errorf("Config.size(): Expected the default 3, got: %v", size)
+	}
+	tags, _ := callReflectionMethod(t, "Config", "tags", "()[Ljava/lang/String;", proxy)
+	if array := objectArray(tags); len(array) != 2 || goString(array[0]) != "a" || goString(array[1]) != "b" {
+		t.Errorf("Config.tags(): Expected {a, b}, got: %v", array)
+	}
+	if level, _ := callReflectionMethod(t, "Config", "level", "()LLevel;", proxy); level != levels["HIGH"] {
+		t.Errorf("Config.level(): Expected Level.HIGH, got: %v", level)
+	}
+	if annotationType, _ := callReflectionMethod(t, "Config", "annotationType", "()Ljava/lang/Class;",
+		proxy); annotationType != classloader.GetClassMirror("Config") {
+		t.Errorf("Config.annotationType(): Expected the mirror of Config, got: %v", annotationType)
+	}
+
+	expected := `@Config(name="svc", size=3, tags={"a", "b"}, level=HIGH)`
+	if str, _ := callReflectionMethod(t, "Config", "toString", "()Ljava/lang/String;", proxy); goString(str) != expected {
+		t.Errorf("Config.toString(): Expected %s, got: %s", expected, goString(str))
+	}
+
+	// another proxy of the same annotation is equal to it, and has the same hash code
+	other, _ := callReflectionMethod(t, "java/lang/Class", "getAnnotation", getAnnotation,
+		classloader.GetClassMirror("Service"), classloader.GetClassMirror("Config"))
+	if equal, _ := callReflectionMethod(t, "java/lang/Object", "equals", "(Ljava/lang/Object;)Z",
+		proxy, other); other == config || equal != types.JavaBoolTrue {
+		t.Errorf("Config.equals(): Expected two distinct, equal proxies")
+	}
+	hash1, _ := callReflectionMethod(t, "java/lang/Object", "hashCode", "()I", proxy)
+	hash2, _ := callReflectionMethod(t, "java/lang/Object", "hashCode", "()I", other)
+	if hash1 != hash2 {
+		t.Errorf("Config.hashCode(): Expected equal hash codes, got: %v and %v", hash1, hash2)
+	}
+
+	// an element that has neither a value nor a default
+	broken := declaredMember(t, "Service", "getDeclaredMethod",
+		"(Ljava/lang/String;[Ljava/lang/Class;)Ljava/lang/reflect/Method;", goStr("broken"), classArray())
+	brokenConfig, _ := callReflectionMethod(t, "java/lang/reflect/Method", "getAnnotation", getAnnotation,
+		broken, classloader.GetClassMirror("Config"))
+	_, exc = callReflectionMethod(t, "Config", "name", "()Ljava/lang/String;", brokenConfig)
+	if exc == nil || *exc.Klass != "java/lang/annotation/IncompleteAnnotationException" ||
+		getThrowableMessage(exc) != "Config missing element name" {
+		t.Errorf("Config.name(): Expected IncompleteAnnotationException: Config missing element name, got: %v", exc)
+	}
+}
+
+// getAnnotations() of a class includes the @Inherited annotations of its superclasses,
+// while getDeclaredAnnotations() doesn't
+func TestClassAnnotations(t *testing.T) {
+	setupAnnotationTest()
+	service := classloader.GetClassMirror("Service")
+	annotationTypes := func(array interface{}) []string {
+		var names []string
+		for _, annotation := range objectArray(array) {
+			mirror, _ := callReflectionMethod(t, "java/lang/annotation/Annotation", "annotationType",
+				"()Ljava/lang/Class;", annotation)
+			names = append(names, classloader.MirrorClassName(mirror.(*object.Object)))
+		}
+		return names
+	}
+
+	all, _ := callReflectionMethod(t, "java/lang/Class", "getAnnotations",
+		"()[Ljava/lang/annotation/Annotation;", service)
+	if names := annotationTypes(all); len(names) != 2 || names[0] != "Config" || names[1] != "Marker" {
+		t.Errorf("Class.getAnnotations(): Expected Config and Marker, got: %v", names)
+	}
+	declared, _ := callReflectionMethod(t, "java/lang/Class", "getDeclaredAnnotations",
+		"()[Ljava/lang/annotation/Annotation;", service)
+	if names := annotationTypes(declared); len(names) != 1 || names[0] != "Config" {
+		t.Errorf("Class.getDeclaredAnnotations(): Expected only Config, got: %v", names)
+	}
+
+	present := []struct {
+		className, annotationType string
+		expected                  int64
+	}{
+		{"Service", "Marker", types.JavaBoolTrue},
+		{"Base", "Config", types.JavaBoolFalse},
+		{"int", "Config", types.JavaBoolFalse},
+	}
+	for _, test := range present {
+		value, _ := callReflectionMethod(t, "java/lang/Class", "isAnnotationPresent", "(Ljava/lang/Class;)Z",
+			classloader.GetClassMirror(test.className), classloader.GetClassMirror(test.annotationType))
+		if value != test.expected {
+			t.Errorf("Class.isAnnotationPresent(): Expected %d for @%s on %s, got: %v",
+				test.expected, test.annotationType, test.className, value)
+		}
+	}
+}
+
+// the annotations of fields, methods, and parameters, and the default values of elements
+func TestMemberAnnotations(t *testing.T) {
+	setupAnnotationTest()
+	getAnnotation := "(Ljava/lang/Class;)Ljava/lang/annotation/Annotation;"
+	configMirror := classloader.GetClassMirror("Config")
+
+	port := declaredMember(t, "Service", "getDeclaredField", "(Ljava/lang/String;)Ljava/lang/reflect/Field;",
+		goStr("port"))
+	portConfig, _ := callReflectionMethod(t, "java/lang/reflect/Field", "getAnnotation", getAnnotation,
+		port, configMirror)
+	if size, _ := callReflectionMethod(t, "Config", "size", "()I", portConfig); size != int64(8080) {
+		t.Errorf("Field.getAnnotation(): Expected a size of 8080, got: %v", size)
+	}
+
+	run := declaredMember(t, "Service", "getDeclaredMethod",
+		"(Ljava/lang/String;[Ljava/lang/Class;)Ljava/lang/reflect/Method;",
+		goStr("run"), classArray("int", "java/lang/String"))
+	runConfig, _ := callReflectionMethod(t, "java/lang/reflect/Method", "getAnnotation", getAnnotation,
+		run, configMirror)
+	if name, _ := callReflectionMethod(t, "Config", "name", "()Ljava/lang/String;", runConfig); goString(name) != "run" {
+		t.Errorf("Method.getAnnotation(): Expected the name run, got: %v", name)
+	}
+	missing, _ := callReflectionMethod(t, "java/lang/reflect/Method", "getAnnotation", getAnnotation,
+		run, classloader.GetClassMirror("Marker"))
+	if missing != object.Null {
+		t.Errorf("Method.getAnnotation(): Expected null for an absent annotation, got: %v", missing)
+	}
+
+	paramAnnotations, _ := callReflectionMethod(t, "java/lang/reflect/Method", "getParameterAnnotations",
+		"()[[Ljava/lang/annotation/Annotation;", run)
+	params := objectArray(paramAnnotations)
+	if len(params) != 2 || len(objectArray(params[0])) != 0 || len(objectArray(params[1])) != 1 {
+		t.Fatalf("Method.getParameterAnnotations(): Expected no annotations and one, got: %v", params)
+	}
+
+	size := declaredMember(t, "Config", "getDeclaredMethod",
+		"(Ljava/lang/String;[Ljava/lang/Class;)Ljava/lang/reflect/Method;", goStr("size"), classArray())
+	defaultValue, _ := callReflectionMethod(t, "java/lang/reflect/Method", "getDefaultValue",
+		"()Ljava/lang/Object;", size)
+	if value := unbox(t, defaultValue, "java/lang/Integer"); value != int64(3) {
+		t.Errorf("Method.getDefaultValue(): Expected 3, got: %v", value)
+	}
+	name := declaredMember(t, "Config", "getDeclaredMethod",
+		"(Ljava/lang/String;[Ljava/lang/Class;)Ljava/lang/reflect/Method;", goStr("name"), classArray())
+	if defaultValue, _ = callReflectionMethod(t, "java/lang/reflect/Method", "getDefaultValue",
+		"()Ljava/lang/Object;", name); defaultValue != object.Null {
+		t.Errorf("Method.getDefaultValue(): Expected null for an element without a default, got: %v", defaultValue)
+	}
+}
+
+func goString(value interface{}) string {
+	obj, ok := value.(*object.Object)
+	if !ok || obj == nil {
+		return ""
+	}
+	return object.GetGoStringFromJavaStringPtr(obj)
+}
@@ -0,0 +1,260 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/frames"
+	"jacobin/globals"
+	"math"
+	"testing"
+)
+
+// The conformance tests of the arithmetic, logical, shift, conversion, and comparison
+// bytecodes. Each entry gives a bytecode, its operands, and the result the JDK computes.
+// Ints wrap around at 32 bits, floats are rounded to 32 bits, and the float-to-integer
+// conversions saturate, with NaN converted to zero.
+
+// operand is a value on the operand stack. Ints and longs are int64s, while floats and
+// doubles are float64s. Longs and doubles take two slots.
+type operand struct {
+	value interface{}
+	wide  bool
+}
+
+func intOp(v int32) operand      { return operand{value: int64(v)} }
+func longOp(v int64) operand     { return operand{value: v, wide: true} }
+func floatOp(v float32) operand  { return operand{value: float64(v)} }
+func doubleOp(v float64) operand { return operand{value: v, wide: true} }
+
+type conformanceTest struct {
+	op       byte
+	operands []operand // in the order they're pushed
+	expected operand
+}
+
+var negZero = math.Copysign(0, -1)
+var nan = math.NaN()
+var inf = math.Inf(1)
+
+var conformanceTests = []conformanceTest{
+	// int arithmetic wraps around at 32 bits
+	{IADD, []operand{intOp(math.MaxInt32), intOp(1)}, intOp(math.MinInt32)},
+	{IADD, []operand{intOp(math.MinInt32), intOp(-1)}, intOp(math.MaxInt32)},
+	{IADD, []operand{intOp(-1), intOp(1)}, intOp(0)},
+	{ISUB, []operand{intOp(math.MinInt32), intOp(1)}, intOp(math.MaxInt32)},
+	{ISUB, []operand{intOp(5), intOp(7)}, intOp(-2)},
+	{IMUL, []operand{intOp(0x10000), intOp(0x10000)}, intOp(0)},
+	{IMUL, []operand{intOp(math.MaxInt32), intOp(2)}, intOp(-2)},
+	{IMUL, []operand{intOp(46341), intOp(46341)}, intOp(-2147479015)},
+	{IDIV, []operand{intOp(7), intOp(2)}, intOp(3)},
+	{IDIV, []operand{intOp(-7), intOp(2)}, intOp(-3)},
+	{IDIV, []operand{intOp(math.MinInt32), intOp(-1)}, intOp(math.MinInt32)},
+	{IREM, []operand{intOp(7), intOp(3)}, intOp(1)},
+	{IREM, []operand{intOp(-7), intOp(3)}, intOp(-1)},
+	{IREM, []operand{intOp(7), intOp(-3)}, intOp(1)},
+	{IREM, []operand{intOp(math.MinInt32), intOp(-1)}, intOp(0)},
+	{INEG, []operand{intOp(5)}, intOp(-5)},
+	{INEG, []operand{intOp(math.MinInt32)}, intOp(math.MinInt32)},
+
+	// int shifts use the low 5 bits of the shift count
+	{ISHL, []operand{intOp(1), intOp(31)}, intOp(math.MinInt32)},
+	{ISHL, []operand{intOp(1), intOp(32)}, intOp(1)},
+	{ISHL, []operand{intOp(0x40000000), intOp(1)}, intOp(math.MinInt32)},
+	{ISHL, []operand{intOp(-1), intOp(33)}, intOp(-2)},
+	{ISHR, []operand{intOp(math.MinInt32), intOp(31)}, intOp(-1)},
+	{ISHR, []operand{intOp(-16), intOp(2)}, intOp(-4)},
+	{ISHR, []operand{intOp(16), intOp(34)}, intOp(4)},
+	{IUSHR, []operand{intOp(-1), intOp(28)}, intOp(15)},
+	{IUSHR, []operand{intOp(-1), intOp(32)}, intOp(-1)},
+	{IUSHR, []operand{intOp(-200), intOp(3)}, intOp(536870887)},
+	{IAND, []operand{intOp(-1), intOp(0x0F0F)}, intOp(0x0F0F)},
+	{IOR, []operand{intOp(0xF0), intOp(0x0F)}, intOp(0xFF)},
+	{IOR, []operand{intOp(math.MinInt32), intOp(1)}, intOp(math.MinInt32 + 1)},
+	{IXOR, []operand{intOp(-1), intOp(0x0F)}, intOp(-16)},
+
+	// long arithmetic wraps around at 64 bits
+	{LADD, []operand{longOp(math.MaxInt64), longOp(1)}, longOp(math.MinInt64)},
+	{LSUB, []operand{longOp(math.MinInt64), longOp(1)}, longOp(math.MaxInt64)},
+	{LMUL, []operand{longOp(math.MaxInt64), longOp(2)}, longOp(-2)},
+	{LDIV, []operand{longOp(-7), longOp(2)}, longOp(-3)},
+	{LDIV, []operand{longOp(math.MinInt64), longOp(-1)}, longOp(math.MinInt64)},
+	{LREM, []operand{longOp(-7), longOp(3)}, longOp(-1)},
+	{LREM, []operand{longOp(math.MinInt64), longOp(-1)}, longOp(0)},
+	{LNEG, []operand{longOp(math.MinInt64)}, longOp(math.MinInt64)},
+
+	// long shifts use the low 6 bits of the shift count, which is an int
+	{LSHL, []operand{longOp(1), intOp(63)}, longOp(math.MinInt64)},
+	{LSHL, []operand{longOp(1), intOp(64)}, longOp(1)},
+	{LSHR, []operand{longOp(math.MinInt64), intOp(63)}, longOp(-1)},
+	{LSHR, []operand{longOp(-16), intOp(66)}, longOp(-4)},
+	{LUSHR, []operand{longOp(-1), intOp(60)}, longOp(15)},
+	{LUSHR, []operand{longOp(-1), intOp(64)}, longOp(-1)},
+	{LAND, []operand{longOp(-1), longOp(0x0F0F)}, longOp(0x0F0F)},
+	{LOR, []operand{longOp(math.MinInt64), longOp(1)}, longOp(math.MinInt64 + 1)},
+	{LXOR, []operand{longOp(-1), longOp(0x0F)}, longOp(-16)},
+	{LCMP, []operand{longOp(1), longOp(2)}, intOp(-1)},
+	{LCMP, []operand{longOp(math.MaxInt64), longOp(math.MinInt64)}, intOp(1)},
+	{LCMP, []operand{longOp(-3), longOp(-3)}, intOp(0)},
+
+	// float arithmetic is rounded to 32 bits, and follows IEEE 754
+	{FADD, []operand{floatOp(16777216), floatOp(1)}, floatOp(16777216)},
+	{FADD, []operand{floatOp(math.MaxFloat32), floatOp(math.MaxFloat32)}, floatOp(float32(inf))},
+	{FADD, []operand{floatOp(float32(nan)), floatOp(1)}, floatOp(float32(nan))},
+	{FSUB, []operand{floatOp(1e8), floatOp(1)}, floatOp(1e8)},
+	{FSUB, []operand{floatOp(1), floatOp(3)}, floatOp(-2)},
+	{FMUL, []operand{floatOp(1e20), floatOp(1e20)}, floatOp(float32(inf))},
+	{FMUL, []operand{floatOp(float32(negZero)), floatOp(5)}, floatOp(float32(negZero))},
+	{FDIV, []operand{floatOp(1), floatOp(3)}, floatOp(math.Float32frombits(0x3EAAAAAB))},
+	{FDIV, []operand{floatOp(1), floatOp(0)}, floatOp(float32(inf))},
+	{FDIV, []operand{floatOp(1), floatOp(float32(negZero))}, floatOp(float32(-inf))},
+	{FDIV, []operand{floatOp(-1), floatOp(0)}, floatOp(float32(-inf))},
+	{FDIV, []operand{floatOp(0), floatOp(0)}, floatOp(float32(nan))},
+	{FREM, []operand{floatOp(5.5), floatOp(2)}, floatOp(1.5)},
+	{FREM, []operand{floatOp(-5.5), floatOp(2)}, floatOp(-1.5)},
+	{FREM, []operand{floatOp(5), floatOp(0)}, floatOp(float32(nan))},
+	{FNEG, []operand{floatOp(0)}, floatOp(float32(negZero))},
+	{FNEG, []operand{floatOp(-2.5)}, floatOp(2.5)},
+	{FCMPL, []operand{floatOp(1), floatOp(2)}, intOp(-1)},
+	{FCMPL, []operand{floatOp(2), floatOp(1)}, intOp(1)},
+	{FCMPL, []operand{floatOp(float32(negZero)), floatOp(0)}, intOp(0)},
+	{FCMPL, []operand{floatOp(float32(nan)), floatOp(0)}, intOp(-1)},
+	{FCMPG, []operand{floatOp(float32(nan)), floatOp(0)}, intOp(1)},
+	{FCMPG, []operand{floatOp(1), floatOp(2)}, intOp(-1)},
+
+	// double arithmetic follows IEEE 754
+	{DADD, []operand{doubleOp(0.1), doubleOp(0.2)}, doubleOp(math.Float64frombits(0x3FD3333333333334))},
+	{DADD, []operand{doubleOp(inf), doubleOp(-inf)}, doubleOp(nan)},
+	{DSUB, []operand{doubleOp(1), doubleOp(3)}, doubleOp(-2)},
+	{DMUL, []operand{doubleOp(math.MaxFloat64), doubleOp(2)}, doubleOp(inf)},
+	{DMUL, []operand{doubleOp(negZero), doubleOp(5)}, doubleOp(negZero)},
+	{DDIV, []operand{doubleOp(1), doubleOp(negZero)}, doubleOp(-inf)},
+	{DDIV, []operand{doubleOp(0), doubleOp(0)}, doubleOp(nan)},
+	{DDIV, []operand{doubleOp(7), doubleOp(2)}, doubleOp(3.5)},
+	{DREM, []operand{doubleOp(5.5), doubleOp(2)}, doubleOp(1.5)},
+	{DREM, []operand{doubleOp(-5.5), doubleOp(2)}, doubleOp(-1.5)},
+	{DREM, []operand{doubleOp(inf), doubleOp(2)}, doubleOp(nan)},
+	{DNEG, []operand{doubleOp(0)}, doubleOp(negZero)},
+	{DCMPL, []operand{doubleOp(1), doubleOp(2)}, intOp(-1)},
+	{DCMPL, []operand{doubleOp(nan), doubleOp(0)}, intOp(-1)},
+	{DCMPG, []operand{doubleOp(nan), doubleOp(0)}, intOp(1)},
+	{DCMPG, []operand{doubleOp(negZero), doubleOp(0)}, intOp(0)},
+
+	// conversions between the integral types
+	{I2L, []operand{intOp(-1)}, longOp(-1)},
+	{L2I, []operand{longOp(0x100000001)}, intOp(1)},
+	{L2I, []operand{longOp(math.MaxInt64)}, intOp(-1)},
+	{L2I, []operand{longOp(0x80000000)}, intOp(math.MinInt32)},
+	{I2B, []operand{intOp(200)}, intOp(-56)},
+	{I2B, []operand{intOp(-2100)}, intOp(-52)},
+	{I2B, []operand{intOp(127)}, intOp(127)},
+	{I2C, []operand{intOp(-1)}, intOp(65535)},
+	{I2C, []operand{intOp(0x12345)}, intOp(0x2345)},
+	{I2S, []operand{intOp(32768)}, intOp(-32768)},
+	{I2S, []operand{intOp(0x12345)}, intOp(0x2345)},
+	{I2S, []operand{intOp(-1)}, intOp(-1)},
+
+	// conversions from integral types to floating point round to the nearest value
+	{I2F, []operand{intOp(16777217)}, floatOp(16777216)},
+	{I2D, []operand{intOp(math.MinInt32)}, doubleOp(math.MinInt32)},
+	{L2F, []operand{longOp(math.MaxInt64)}, floatOp(9.223372e18)},
+	{L2D, []operand{longOp(1<<53 + 1)}, doubleOp(1 << 53)},
+
+	// conversions between the floating-point types
+	{F2D, []operand{floatOp(0.1)}, doubleOp(float64(float32(0.1)))},
+	{D2F, []operand{doubleOp(0.1)}, floatOp(0.1)},
+	{D2F, []operand{doubleOp(1e40)}, floatOp(float32(inf))},
+	{D2F, []operand{doubleOp(1e-50)}, floatOp(0)},
+
+	// conversions from floating point to integral types truncate toward zero and saturate,
+	// and NaN becomes zero
+	{F2I, []operand{floatOp(-1.9)}, intOp(-1)},
+	{F2I, []operand{floatOp(float32(nan))}, intOp(0)},
+	{F2I, []operand{floatOp(1e10)}, intOp(math.MaxInt32)},
+	{F2I, []operand{floatOp(-1e10)}, intOp(math.MinInt32)},
+	{F2I, []operand{floatOp(float32(inf))}, intOp(math.MaxInt32)},
+	{F2I, []operand{floatOp(float32(-inf))}, intOp(math.MinInt32)},
+	{F2L, []operand{floatOp(2.9)}, longOp(2)},
+	{F2L, []operand{floatOp(float32(nan))}, longOp(0)},
+	{F2L, []operand{floatOp(1e19)}, longOp(math.MaxInt64)},
+	{F2L, []operand{floatOp(-1e19)}, longOp(math.MinInt64)},
+	{D2I, []operand{doubleOp(-2.5)}, intOp(-2)},
+	{D2I, []operand{doubleOp(nan)}, intOp(0)},
+	{D2I, []operand{doubleOp(3e9)}, intOp(math.MaxInt32)},
+	{D2I, []operand{doubleOp(-3e9)}, intOp(math.MinInt32)},
+	{D2L, []operand{doubleOp(-2.5)}, longOp(-2)},
+	{D2L, []operand{doubleOp(nan)}, longOp(0)},
+	{D2L, []operand{doubleOp(1 << 63)}, longOp(math.MaxInt64)},
+	{D2L, []operand{doubleOp(-1e19)}, longOp(math.MinInt64)},
+	{D2L, []operand{doubleOp(-inf)}, longOp(math.MinInt64)},
+}
+
+// sameValue reports whether the result of a bytecode is the expected value. Floating-point
+// values must have the same bits, so that 0.0 and -0.0 differ, except that all NaNs are the same.
+func sameValue(result, expected interface{}) bool {
+	resultFloat, isFloat := result.(float64)
+	expectedFloat, expectFloat := expected.(float64)
+	if isFloat != expectFloat {
+		return false
+	}
+	if !isFloat {
+		return result == expected
+	}
+	if math.IsNaN(expectedFloat) {
+		return math.IsNaN(resultFloat)
+	}
+	return math.Float64bits(resultFloat) == math.Float64bits(expectedFloat)
+}
+
+func TestArithmeticConformance(t *testing.T) {
+	globals.InitGlobals("test")
+
+	for _, test := range conformanceTests {
+		name := BytecodeNames[test.op]
+		f := newFrame(test.op)
+		for _, arg := range test.operands {
+			push(&f, arg.value)
+			if arg.wide {
+				push(&f, arg.value)
+			}
+		}
+
+		fs := frames.CreateFrameStack()
+		fs.PushFront(&f) // push the new frame
+		if err := runFrame(fs); err != nil {
+			t.Errorf("%s %v: Got unexpected error: %s", name, test.operands, err.Error())
+			continue
+		}
+
+		result := pop(&f)
+		if test.expected.wide && !sameValue(pop(&f), result) {
+			t.Errorf("%s %v: Expected the two slots of the result to hold the same value", name, test.operands)
+		}
+		if !sameValue(result, test.expected.value) {
+			t.Errorf("%s %v: Expected %v (%T), got: %v (%T)", name, test.operands,
+				test.expected.value, test.expected.value, result, result)
+		}
+		if f.TOS != -1 {
+			t.Errorf("%s %v: Expected an empty stack, got a TOS of: %d", name, test.operands, f.TOS)
+		}
+	}
+}
+
+// IINC: the incremented int wraps around at 32 bits
+func TestIincWrapsAround(t *testing.T) {
+	globals.InitGlobals("test")
+	f := newFrame(IINC)
+	f.Meth = append(f.Meth, 0, 1) // increment local 0 by 1
+	f.Locals = []interface{}{int64(math.MaxInt32)}
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	_ = runFrame(fs)
+
+	if f.Locals[0] != int64(math.MinInt32) {
+		t.Errorf("IINC: Expected MAX_VALUE + 1 to be %d, got: %v", math.MinInt32, f.Locals[0])
+	}
+}
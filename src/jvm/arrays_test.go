@@ -22,18 +22,18 @@ import (
 func TestJdkArrayTypeToJacobinType(t *testing.T) {
 
 	a := object.JdkArrayTypeToJacobinType(object.T_BOOLEAN)
-	if a != object.BYTE {
-		t.Errorf("Expected Jacobin type of %d, got: %d", object.BYTE, a)
+	if a != object.BOOL {
+		t.Errorf("Expected Jacobin type of %d, got: %d", object.BOOL, a)
 	}
 
 	b := object.JdkArrayTypeToJacobinType(object.T_CHAR)
-	if b != object.INT {
-		t.Errorf("Expected Jacobin type of %d, got: %d", object.INT, b)
+	if b != object.CHAR {
+		t.Errorf("Expected Jacobin type of %d, got: %d", object.CHAR, b)
 	}
 
 	c := object.JdkArrayTypeToJacobinType(object.T_DOUBLE)
-	if c != object.FLOAT {
-		t.Errorf("Expected Jacobin type of %d, got: %d", object.FLOAT, c)
+	if c != object.DOUBLE {
+		t.Errorf("Expected Jacobin type of %d, got: %d", object.DOUBLE, c)
 	}
 
 	d := object.JdkArrayTypeToJacobinType(999)
@@ -42,10 +42,20 @@ func TestJdkArrayTypeToJacobinType(t *testing.T) {
 	}
 }
 
+// newAnewarrayFrame returns a frame with an ANEWARRAY bytecode that creates an array
+// of the named class
+func newAnewarrayFrame(className string) frames.Frame {
+	c := newMessageTestCP()
+	f := newFrame(ANEWARRAY)
+	f.Meth = append(f.Meth, 0, byte(c.classRef(className)))
+	f.CP = &c.cp
+	return f
+}
+
 // AALOAD: Test fetching and pushing the value of an element in a reference array
 // The logic here is effectively identical to IALOAD. This code also tests AASTORE.
 func TestAaload(t *testing.T) {
-	f := newFrame(ANEWARRAY)
+	f := newAnewarrayFrame("java/lang/Object")
 	push(&f, int64(30)) // make the array 30 elements big
 
 	globals.InitGlobals("test")
@@ -109,7 +119,7 @@ func TestAaloadWithNil(t *testing.T) {
 	}
 
 	errMsg := err.Error()
-	if !strings.Contains(errMsg, "Invalid (null) reference") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException") {
 		t.Errorf("AALOAD: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -192,7 +202,7 @@ func TestAastoreInvalid1(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot store to object array") {
 		t.Errorf("AASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -208,6 +218,7 @@ func TestAastoreInvalid2(t *testing.T) {
 
 	log.Init()
 	globals.InitGlobals("test")
+	classloader.InitMethodArea()
 	normalStderr := os.Stderr
 	r, w, _ := os.Pipe()
 	os.Stderr = w
@@ -230,7 +241,7 @@ func TestAastoreInvalid2(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Attempt to access array of incorrect type") {
+	if !strings.Contains(errMsg, "java.lang.ArrayStoreException: [I") {
 		t.Errorf("AASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -267,14 +278,14 @@ func TestAastoreInvalid3(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "AASTORE: Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index 30 out of bounds for length 10") {
 		t.Errorf("AASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
 
 // ANEWARRAY: creation of array for references
 func TestAnewrray(t *testing.T) {
-	f := newFrame(ANEWARRAY)
+	f := newAnewarrayFrame("java/lang/Object")
 	push(&f, int64(13)) // make the array 13 elements big
 
 	globals.InitGlobals("test")
@@ -304,7 +315,7 @@ func TestAnewrray(t *testing.T) {
 
 // ANEWARRAY: creation of array for references; test contents of Klass field
 func TestAnewrrayKlassField(t *testing.T) {
-	f := newFrame(ANEWARRAY)
+	f := newAnewarrayFrame("java/lang/Object")
 	push(&f, int64(13)) // make the array 13 elements big
 
 	globals.InitGlobals("test")
@@ -334,7 +345,7 @@ func TestAnewrrayKlassField(t *testing.T) {
 
 // ANEWARRAY: creation of array for references; test invalid array size
 func TestAnewrrayInvalidSize(t *testing.T) {
-	f := newFrame(ANEWARRAY)
+	f := newAnewarrayFrame("java/lang/Object")
 	push(&f, int64(-1)) // make the array an invalid size
 
 	globals.InitGlobals("test")
@@ -347,7 +358,7 @@ func TestAnewrrayInvalidSize(t *testing.T) {
 	}
 
 	msg := err.Error()
-	if !(msg == "ANEWARRAY: Invalid size for array") {
+	if !strings.Contains(msg, "java.lang.NegativeArraySizeException") {
 		t.Errorf("ANEWARRAY: Expecting different error msg, got %s", msg)
 	}
 }
@@ -590,7 +601,7 @@ func TestNilArrayLength(t *testing.T) {
 	}
 
 	errMsg := err.Error()
-	if errMsg != "ARRAYLENGTH: Invalid (null) reference to an array" {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException") {
 		t.Errorf("ARRAYLENGTH: Expecting different error msg, got: %s", errMsg)
 	}
 }
@@ -669,7 +680,7 @@ func TestBaloadNilArray(t *testing.T) {
 
 	errMsg := string(out[:])
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot load from byte/boolean array") {
 		t.Errorf("BALOAD: Did not get expected err msg for nil array, got: %s",
 			errMsg)
 	}
@@ -711,7 +722,7 @@ func TestBaloadInvalidSubscript(t *testing.T) {
 
 	errMsg := string(out[:])
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index 200 out of bounds for length 30") {
 		t.Errorf("BALOAD: Did not get expected err msg for invalid subscript, got: %s",
 			errMsg)
 	}
@@ -835,7 +846,7 @@ func TestBastoreInvalid1(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot store to byte/boolean array") {
 		t.Errorf("BASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -851,6 +862,7 @@ func TestBastoreInvalid2(t *testing.T) {
 
 	log.Init()
 	globals.InitGlobals("test")
+	classloader.InitMethodArea()
 	normalStderr := os.Stderr
 	r, w, _ := os.Pipe()
 	os.Stderr = w
@@ -873,7 +885,7 @@ func TestBastoreInvalid2(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Attempt to access array of incorrect type") {
+	if !strings.Contains(errMsg, "java.lang.ArrayStoreException: byte") {
 		t.Errorf("BASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -911,7 +923,7 @@ func TestBastoreInvalid3(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "BASTORE: Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index 30 out of bounds for length 10") {
 		t.Errorf("BASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1040,7 +1052,7 @@ func TestDaloadNilArray(t *testing.T) {
 
 	errMsg := string(out[:])
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot load from double array") {
 		t.Errorf("DALOAD: Did not get expected err msg for nil array, got: %s",
 			errMsg)
 	}
@@ -1082,7 +1094,7 @@ func TestLaDoadInvalidSubscript(t *testing.T) {
 
 	errMsg := string(out[:])
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index 200 out of bounds for length 30") {
 		t.Errorf("DALOAD: Did not get expected err msg for invalid subscript, got: %s",
 			errMsg)
 	}
@@ -1168,7 +1180,7 @@ func TestDastoreInvalid1(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot store to double array") {
 		t.Errorf("DASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1184,6 +1196,7 @@ func TestDastoreInvalid2(t *testing.T) {
 
 	log.Init()
 	globals.InitGlobals("test")
+	classloader.InitMethodArea()
 	normalStderr := os.Stderr
 	r, w, _ := os.Pipe()
 	os.Stderr = w
@@ -1206,7 +1219,7 @@ func TestDastoreInvalid2(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Attempt to access array of incorrect type") {
+	if !strings.Contains(errMsg, "java.lang.ArrayStoreException: double") {
 		t.Errorf("DASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1214,7 +1227,7 @@ func TestDastoreInvalid2(t *testing.T) {
 // DASTORE: Test error conditions: index out of range
 func TestDastoreInvalid3(t *testing.T) {
 
-	o := object.Make1DimArray(object.FLOAT, 10)
+	o := object.Make1DimArray(object.DOUBLE, 10)
 	f := newFrame(DASTORE)
 	push(&f, o)             // an array of 10 ints, not floats
 	push(&f, int64(30))     // the index into the array: it's too big, causing error
@@ -1245,7 +1258,7 @@ func TestDastoreInvalid3(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index 30 out of bounds for length 10") {
 		t.Errorf("DASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1322,7 +1335,7 @@ func TestFaloadNilArray(t *testing.T) {
 
 	errMsg := string(out[:])
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot load from float array") {
 		t.Errorf("FALOAD: Did not get expected err msg for nil array, got: %s",
 			errMsg)
 	}
@@ -1364,7 +1377,7 @@ func TestFaloadInvalidSubscript(t *testing.T) {
 
 	errMsg := string(out[:])
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index 200 out of bounds for length 30") {
 		t.Errorf("DALOAD: Did not get expected err msg for invalid subscript, got: %s",
 			errMsg)
 	}
@@ -1445,7 +1458,7 @@ func TestFastoreInvalid1(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot store to float array") {
 		t.Errorf("FASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1460,6 +1473,7 @@ func TestFastoreInvalid2(t *testing.T) {
 
 	log.Init()
 	globals.InitGlobals("test")
+	classloader.InitMethodArea()
 	normalStderr := os.Stderr
 	r, w, _ := os.Pipe()
 	os.Stderr = w
@@ -1482,7 +1496,7 @@ func TestFastoreInvalid2(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Attempt to access array of incorrect type") {
+	if !strings.Contains(errMsg, "java.lang.ArrayStoreException: float") {
 		t.Errorf("FASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1520,7 +1534,7 @@ func TestFastoreInvalid3(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index 30 out of bounds for length 10") {
 		t.Errorf("FASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1597,7 +1611,7 @@ func TestIaloadNilArray(t *testing.T) {
 
 	errMsg := string(out[:])
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot load from int array") {
 		t.Errorf("IALOAD: Did not get expected err msg for nil array, got: %s",
 			errMsg)
 	}
@@ -1654,7 +1668,7 @@ func TestIaloadInvalidSubscript(t *testing.T) {
 
 	errMsg := string(out[:])
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index 200 out of bounds for length 30") {
 		t.Errorf("IALOAD: Did not get expected err msg for invalid subscript, got: %s",
 			errMsg)
 	}
@@ -1735,7 +1749,7 @@ func TestIastoreInvalid1(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot store to int array") {
 		t.Errorf("IASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1751,6 +1765,7 @@ func TestIastoreInvalid2(t *testing.T) {
 
 	log.Init()
 	globals.InitGlobals("test")
+	classloader.InitMethodArea()
 	normalStderr := os.Stderr
 	r, w, _ := os.Pipe()
 	os.Stderr = w
@@ -1773,7 +1788,7 @@ func TestIastoreInvalid2(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "IA/CA/SASTORE: field type expected=[I, observed=[F") {
+	if !strings.Contains(errMsg, "java.lang.ArrayStoreException: int") {
 		t.Errorf("IASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1811,7 +1826,7 @@ func TestIastoreInvalid3(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "IA/CA/SASTORE: array size= 10 but array index= 30 (too large)") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index 30 out of bounds for length 10") {
 		t.Errorf("IASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -1890,7 +1905,7 @@ func TestLaloadNilArray(t *testing.T) {
 
 	errMsg := string(out[:])
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot load from long array") {
 		t.Errorf("LALOAD: Did not get expected err msg for nil array, got: %s",
 			errMsg)
 	}
@@ -1932,7 +1947,7 @@ func TestLaloadInvalidSubscript(t *testing.T) {
 
 	errMsg := string(out[:])
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index 200 out of bounds for length 30") {
 		t.Errorf("LALOAD: Did not get expected err msg for invalid subscript, got: %s",
 			errMsg)
 	}
@@ -2017,7 +2032,7 @@ func TestLastoreInvalid1(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid (null) reference to an array") {
+	if !strings.Contains(errMsg, "java.lang.NullPointerException: Cannot store to long array") {
 		t.Errorf("LASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -2034,6 +2049,7 @@ func TestLastoreInvalid2(t *testing.T) {
 
 	log.Init()
 	globals.InitGlobals("test")
+	classloader.InitMethodArea()
 	normalStderr := os.Stderr
 	r, w, _ := os.Pipe()
 	os.Stderr = w
@@ -2056,7 +2072,7 @@ func TestLastoreInvalid2(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Attempt to access array of incorrect type") {
+	if !strings.Contains(errMsg, "java.lang.ArrayStoreException: long") {
 		t.Errorf("LASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -2064,7 +2080,7 @@ func TestLastoreInvalid2(t *testing.T) {
 // LASTORE: Test error conditions: index out of range
 func TestLastoreInvalid3(t *testing.T) {
 
-	o := object.Make1DimArray(object.INT, 10)
+	o := object.Make1DimArray(object.LONG, 10)
 	f := newFrame(LASTORE)
 	push(&f, o)         // an array of 10 ints, not floats
 	push(&f, int64(30)) // the index into the array: it's too big, causing error
@@ -2095,7 +2111,7 @@ func TestLastoreInvalid3(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Invalid array subscript") {
+	if !strings.Contains(errMsg, "java.lang.ArrayIndexOutOfBoundsException: Index 30 out of bounds for length 10") {
 		t.Errorf("LASTORE: Did not get expected error msg, got: %s", errMsg)
 	}
 }
@@ -2134,8 +2150,8 @@ func Test2DimArrayKlassField(t *testing.T) {
 	}
 
 	arrKlass := arr.Klass
-	if *arrKlass != "[B" {
-		t.Errorf("Expecting array with Klass of '[B', got: %s", *arrKlass)
+	if *arrKlass != "[[B" {
+		t.Errorf("Expecting array with Klass of '[[B', got: %s", *arrKlass)
 	}
 }
 
@@ -2151,7 +2167,7 @@ func Test3DimArray1(t *testing.T) {
 	CP.CpIndex[0] = classloader.CpEntry{Type: 0, Slot: 0}
 	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.UTF8, Slot: 0}
 	CP.CpIndex[2] = classloader.CpEntry{Type: classloader.ClassRef, Slot: 0}
-	CP.ClassRefs = append(CP.ClassRefs, 0)
+	CP.ClassRefs = append(CP.ClassRefs, 1)
 	CP.Utf8Refs = append(CP.Utf8Refs, "[[[I")
 
 	// create the frame
@@ -2176,9 +2192,9 @@ func Test3DimArray1(t *testing.T) {
 		t.Error("MULTIANEWARRAY: Expected a pointer to an array, got nil")
 	}
 
-	topLevelArray := *(arrayPtr.(*object.Object))
-	if topLevelArray.Fields[0].Ftype != "[L" {
-		t.Errorf("MULTIANEWARRAY: Expected 1st dim to be type '[L', got %s",
+	topLevelArray := arrayPtr.(*object.Object)
+	if topLevelArray.Fields[0].Ftype != "[[[I" {
+		t.Errorf("MULTIANEWARRAY: Expected 1st dim to be type '[[[I', got %s",
 			topLevelArray.Fields[0].Ftype)
 	}
 
@@ -2218,9 +2234,9 @@ func Test3DimArray1(t *testing.T) {
 	}
 }
 
-// MULTINEWARRAY: Test an array 4x3x3 array of int64's. The zero
-// size of the second dimension should result in an single-dimension
-// array of int64s
+// MULTINEWARRAY: Test a 3x0x4 array of int64's. The zero
+// size of the second dimension should result in an array of 3
+// empty arrays, with no third dimension
 func Test3DimArray2(t *testing.T) {
 	g := globals.InitGlobals("test")
 	g.JacobinName = "test" // prevents a shutdown when the exception hits.
@@ -2232,7 +2248,7 @@ func Test3DimArray2(t *testing.T) {
 	CP.CpIndex[0] = classloader.CpEntry{Type: 0, Slot: 0}
 	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.UTF8, Slot: 0}
 	CP.CpIndex[2] = classloader.CpEntry{Type: classloader.ClassRef, Slot: 0}
-	CP.ClassRefs = append(CP.ClassRefs, 0)
+	CP.ClassRefs = append(CP.ClassRefs, 1)
 	CP.Utf8Refs = append(CP.Utf8Refs, "[[[I")
 
 	// create the frame
@@ -2257,18 +2273,24 @@ func Test3DimArray2(t *testing.T) {
 		t.Error("MULTIANEWARRAY: Expected a pointer to an array, got nil")
 	}
 
-	topLevelArray := *(arrayPtr.(*object.Object))
-	if topLevelArray.Fields[0].Ftype != "[I" {
-		t.Errorf("MULTIANEWARRAY: Expected 1st dim to be type '[I', got %s",
+	topLevelArray := arrayPtr.(*object.Object)
+	if topLevelArray.Fields[0].Ftype != "[[[I" {
+		t.Errorf("MULTIANEWARRAY: Expected 1st dim to be type '[[[I', got %s",
 			topLevelArray.Fields[0].Ftype)
 	}
 
-	dim1 := *(topLevelArray.Fields[0].Fvalue.(*[]int64))
-	if len(dim1) != 4 {
-		t.Errorf("MULTINEWARRAY: Expected 1st dim to have 4 elements, got: %d",
+	dim1 := *(topLevelArray.Fields[0].Fvalue.(*[]*object.Object))
+	if len(dim1) != 3 {
+		t.Errorf("MULTINEWARRAY: Expected 1st dim to have 3 elements, got: %d",
 			len(dim1))
 	}
 
+	dim2 := *(dim1[2].Fields[0].Fvalue.(*[]*object.Object))
+	if dim1[2].Fields[0].Ftype != "[[I" || len(dim2) != 0 {
+		t.Errorf("MULTINEWARRAY: Expected 2nd dim to be an empty [[I, got %s with %d elements",
+			dim1[2].Fields[0].Ftype, len(dim2))
+	}
+
 }
 
 // NEWARRAY: creation of array for primitive values
@@ -2342,7 +2364,7 @@ func TestNewrrayInvalidSize(t *testing.T) {
 	}
 
 	errMsg := err.Error()
-	if !strings.Contains(errMsg, "Invalid size for array") {
+	if !strings.Contains(errMsg, "java.lang.NegativeArraySizeException") {
 		t.Errorf("NEWARRAY: Got unexpected error message: %s", errMsg)
 	}
 }
@@ -2372,8 +2394,8 @@ func TestNewrrayInvalidType(t *testing.T) {
 // SALOAD: Test fetching and pushing the value of an element in a short array
 func TestSaload(t *testing.T) {
 	f := newFrame(NEWARRAY)
-	push(&f, int64(30))                   // make the array 30 elements big
-	f.Meth = append(f.Meth, object.T_INT) // make it an array of ints
+	push(&f, int64(30))                     // make the array 30 elements big
+	f.Meth = append(f.Meth, object.T_SHORT) // make it an array of shorts
 
 	globals.InitGlobals("test")
 	fs := frames.CreateFrameStack()
@@ -2422,8 +2444,8 @@ func TestSaload(t *testing.T) {
 // See comments for IASTORE for the logic of this test
 func TestSastore(t *testing.T) {
 	f := newFrame(NEWARRAY)
-	push(&f, int64(30))                   // make the array 30 elements big
-	f.Meth = append(f.Meth, object.T_INT) // make it an array of ints
+	push(&f, int64(30))                     // make the array 30 elements big
+	f.Meth = append(f.Meth, object.T_SHORT) // make it an array of shorts
 
 	globals.InitGlobals("test")
 	fs := frames.CreateFrameStack()
@@ -2460,3 +2482,212 @@ func TestSastore(t *testing.T) {
 		t.Errorf("SASTORE: Expected sum of array entries to be 100, got: %d", sum)
 	}
 }
+
+// NEWARRAY: each primitive array has the exact type of its elements
+func TestNewarrayExactTypes(t *testing.T) {
+	globals.InitGlobals("test")
+	tests := map[byte]string{
+		object.T_BOOLEAN: "[Z", object.T_BYTE: "[B", object.T_CHAR: "[C", object.T_SHORT: "[S",
+		object.T_INT: "[I", object.T_LONG: "[J", object.T_FLOAT: "[F", object.T_DOUBLE: "[D",
+	}
+	for jdkType, arrayType := range tests {
+		f := newFrame(NEWARRAY)
+		push(&f, int64(3))
+		f.Meth = append(f.Meth, jdkType)
+		fs := frames.CreateFrameStack()
+		fs.PushFront(&f) // push the new frame
+		_ = runFrame(fs)
+
+		arr := pop(&f).(*object.Object)
+		if arr.Fields[0].Ftype != arrayType || *arr.Klass != arrayType {
+			t.Errorf("NEWARRAY: Expected type %d to make an array of type %s, got: %s",
+				jdkType, arrayType, arr.Fields[0].Ftype)
+		}
+	}
+}
+
+// CASTORE, SASTORE, IASTORE, BASTORE, FASTORE: the stored value is narrowed to the type of
+// the array's elements, and BALOAD sign-extends bytes
+func TestArrayStoresNarrowValues(t *testing.T) {
+	globals.InitGlobals("test")
+	tests := []struct {
+		arrayType byte
+		store     byte
+		load      byte
+		value     interface{}
+		expected  interface{}
+	}{
+		{object.CHAR, CASTORE, CALOAD, int64(0x12345), int64(0x2345)},
+		{object.CHAR, CASTORE, CALOAD, int64(-1), int64(0xFFFF)},
+		{object.SHORT, SASTORE, SALOAD, int64(0x18000), int64(-32768)},
+		{object.INT, IASTORE, IALOAD, int64(0x180000000), int64(-2147483648)},
+		{object.BYTE, BASTORE, BALOAD, int64(0xFF), int64(-1)},
+		{object.BOOL, BASTORE, BALOAD, int64(3), int64(1)},
+		{object.FLOAT, FASTORE, FALOAD, 0.1, float64(float32(0.1))},
+	}
+	for _, test := range tests {
+		arr := object.Make1DimArray(test.arrayType, 2)
+
+		f := newFrame(test.store)
+		push(&f, arr)
+		push(&f, int64(1))
+		push(&f, test.value)
+		fs := frames.CreateFrameStack()
+		fs.PushFront(&f) // push the new frame
+		if err := runFrame(fs); err != nil {
+			t.Errorf("Got unexpected error storing into %s: %s", arr.Fields[0].Ftype, err.Error())
+			continue
+		}
+
+		f = newFrame(test.load)
+		push(&f, arr)
+		push(&f, int64(1))
+		fs = frames.CreateFrameStack()
+		fs.PushFront(&f) // push the new frame
+		_ = runFrame(fs)
+		if value := pop(&f); value != test.expected {
+			t.Errorf("Expected %v stored in %s to be loaded as %v, got: %v",
+				test.value, arr.Fields[0].Ftype, test.expected, value)
+		}
+	}
+}
+
+// INSTANCEOF and CHECKCAST tell a char[] from an int[]
+func TestPrimitiveArrayTypeChecks(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+	chars := object.Make1DimArray(object.CHAR, 2)
+
+	for className, expected := range map[string]int64{"[C": 1, "[I": 0} {
+		c := newMessageTestCP()
+		f := frames.CreateFrame(4)
+		f.Ftype = 'J'
+		f.CP = &c.cp
+		f.Meth = []byte{INSTANCEOF, 0, byte(c.classRef(className))}
+		push(f, chars)
+		fs := frames.CreateFrameStack()
+		fs.PushFront(f)
+		if err := runFrame(fs); err != nil {
+			t.Fatalf("INSTANCEOF: Got unexpected error: %s", err.Error())
+		}
+		if value := pop(f); value != expected {
+			t.Errorf("INSTANCEOF: Expected a char array instanceof %s to be %d, got: %v", className, expected, value)
+		}
+	}
+
+	// (int[]) chars
+	c := newMessageTestCP()
+	f := frames.CreateFrame(4)
+	f.Ftype = 'J'
+	f.CP = &c.cp
+	f.Meth = []byte{CHECKCAST, 0, byte(c.classRef("[I")), RETURN, RETURN}
+	f.ExcTable = append(f.ExcTable, classloader.CodeException{StartPc: 0, EndPc: 3, HandlerPc: 4,
+		CatchType: c.classRef("java/lang/ClassCastException")})
+	push(f, chars)
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("CHECKCAST: Expected the ClassCastException to be caught, got error: %s", err.Error())
+	}
+	if f.PC != 4 {
+		t.Errorf("CHECKCAST: Expected the cast of a char array to int[] to be caught by the handler")
+	}
+}
+
+// MULTIANEWARRAY: an array of more than three dimensions, with its last dimension left to be
+// created later
+func TestMultianewarrayManyDimensions(t *testing.T) {
+	globals.InitGlobals("test")
+	c := newMessageTestCP()
+	f := newFrame(MULTIANEWARRAY)
+	f.Meth = append(f.Meth, 0, byte(c.classRef("[[[[[[J")), 5) // 5 of the 6 dimensions
+	f.CP = &c.cp
+	for _, size := range []int64{2, 1, 1, 1, 3} {
+		push(&f, size)
+	}
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("MULTIANEWARRAY: Got unexpected error: %s", err.Error())
+	}
+
+	arr := pop(&f).(*object.Object)
+	for _, arrayType := range []string{"[[[[[[J", "[[[[[J", "[[[[J", "[[[J"} {
+		if arr.Fields[0].Ftype != arrayType {
+			t.Fatalf("MULTIANEWARRAY: Expected a dimension of type %s, got: %s", arrayType, arr.Fields[0].Ftype)
+		}
+		arr = (*arr.Fields[0].Fvalue.(*[]*object.Object))[0]
+	}
+	leaves := *arr.Fields[0].Fvalue.(*[]*object.Object)
+	if arr.Fields[0].Ftype != "[[J" || len(leaves) != 3 || leaves[0] != nil {
+		t.Errorf("MULTIANEWARRAY: Expected the 5th dimension to be 3 null [J arrays, got %s of %d: %v",
+			arr.Fields[0].Ftype, len(leaves), leaves)
+	}
+}
+
+// AASTORE: an object can be stored in an array of its superclass, but storing it in an
+// array of another class throws an ArrayStoreException
+func TestAastoreArrayStoreException(t *testing.T) {
+	setupAnimalClasses()
+	dogName, catName := "Dog", "Cat"
+	dog, cat := object.MakeEmptyObject(), object.MakeEmptyObject()
+	dog.Klass, cat.Klass = &dogName, &catName
+
+	// Animal[] animals = new Dog[2]; animals[0] = dog; animals[1] = cat;
+	dogs := object.MakeArray("[LDog;", 2)
+	c := newMessageTestCP()
+	f := frames.CreateFrame(6)
+	f.Ftype = 'J'
+	f.CP = &c.cp
+	f.Meth = []byte{ALOAD_0, ICONST_0, ALOAD_1, AASTORE, ALOAD_0, ICONST_1, ALOAD_2, AASTORE, RETURN,
+		ASTORE_0, RETURN}
+	f.Locals = []interface{}{dogs, dog, cat}
+	f.ExcTable = append(f.ExcTable, classloader.CodeException{StartPc: 0, EndPc: 8, HandlerPc: 9,
+		CatchType: c.classRef("java/lang/ArrayStoreException")})
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("AASTORE: Expected the ArrayStoreException to be caught, got error: %s", err.Error())
+	}
+
+	if f.PC != 10 {
+		t.Errorf("AASTORE: Expected storing a Cat in a Dog[] to be caught by the handler")
+	}
+	array := *dogs.Fields[0].Fvalue.(*[]*object.Object)
+	if array[0] != dog || array[1] != nil {
+		t.Errorf("AASTORE: Expected the array to hold only the dog, got: %v", array)
+	}
+	exc := f.Locals[0].(*object.Object)
+	if msg := getThrowableMessage(exc); *exc.Klass != "java/lang/ArrayStoreException" || msg != "Cat" {
+		t.Errorf("AASTORE: Expected java.lang.ArrayStoreException: Cat, got: %s: %s", *exc.Klass, msg)
+	}
+}
+
+// INSTANCEOF and CHECKCAST: an array of a class is an instance of an array of its superclass
+func TestArrayCovarianceTypeChecks(t *testing.T) {
+	setupAnimalClasses()
+	dogs := object.MakeArray("[LDog;", 1)
+
+	for className, expected := range map[string]int64{"[LAnimal;": 1, "[Ljava/lang/Object;": 1, "[LCat;": 0} {
+		c := newMessageTestCP()
+		f := frames.CreateFrame(4)
+		f.Ftype = 'J'
+		f.CP = &c.cp
+		f.Meth = []byte{CHECKCAST, 0, byte(c.classRef("[LAnimal;")), INSTANCEOF, 0, byte(c.classRef(className))}
+		push(f, dogs)
+		push(f, dogs)
+		fs := frames.CreateFrameStack()
+		fs.PushFront(f)
+		if err := runFrame(fs); err != nil {
+			t.Fatalf("CHECKCAST/INSTANCEOF: Got unexpected error: %s", err.Error())
+		}
+		if value := pop(f); value != expected {
+			t.Errorf("INSTANCEOF: Expected a Dog[] instanceof %s to be %d, got: %v", className, expected, value)
+		}
+		if f.TOS != 0 || peek(f) != dogs {
+			t.Errorf("CHECKCAST: Expected the cast of a Dog[] to Animal[] to succeed")
+		}
+	}
+}
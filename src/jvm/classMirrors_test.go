@@ -0,0 +1,197 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/object"
+	"jacobin/types"
+	"testing"
+)
+
+// LDC and LDC_W of a CONSTANT_Class entry push the mirror of the class, loading it first.
+// A class that can't be loaded throws a NoClassDefFoundError.
+func TestLdcPushesClassMirror(t *testing.T) {
+	setupObjectNativesTest()
+	c := newMessageTestCP()
+	dog := c.classRef("Dog")
+	dogs := c.classRef("[LDog;")
+	missing := c.classRef("Missing")
+
+	code := []byte{LDC, byte(dog), LDC_W, byte(dogs >> 8), byte(dogs), ASTORE_1, ASTORE_0,
+		LDC, byte(missing), RETURN, ASTORE_2, RETURN}
+	excTable := []classloader.CodeException{{StartPc: 7, EndPc: 9, HandlerPc: 10,
+		CatchType: c.classRef("java/lang/NoClassDefFoundError")}}
+	f := runObjectNativesCode(t, c, code, []interface{}{nil, nil, nil}, excTable)
+
+	if f.Locals[0] != classloader.GetClassMirror("Dog") {
+		t.Errorf("LDC: Expected the mirror of Dog, got: %v", f.Locals[0])
+	}
+	if f.Locals[1] != classloader.GetClassMirror("[LDog;") {
+		t.Errorf("LDC_W: Expected the mirror of Dog[], got: %v", f.Locals[1])
+	}
+	if f.PC != 11 {
+		t.Fatalf("LDC: Expected the NoClassDefFoundError for Missing to be caught by the handler")
+	}
+	exc := f.Locals[2].(*object.Object)
+	if msg := getThrowableMessage(exc); msg != "Missing" {
+		t.Errorf("LDC: Expected java.lang.NoClassDefFoundError: Missing, got: %s: %s", *exc.Klass, msg)
+	}
+}
+
+// invokes the Class method with the given name and type on the mirror, passing arg if
+// it's not nil, and returns the result
+func invokeClassMethod(t *testing.T, mirror *object.Object, name, desc string, arg interface{}) interface{} {
+	c := newMessageTestCP()
+	meth := c.methodRef("java/lang/Class", name, desc)
+	code := []byte{ALOAD_0, INVOKEVIRTUAL, byte(meth >> 8), byte(meth)}
+	if arg != nil {
+		code = []byte{ALOAD_0, ALOAD_1, INVOKEVIRTUAL, byte(meth >> 8), byte(meth)}
+	}
+	f := runObjectNativesCode(t, c, code, []interface{}{mirror, arg}, nil)
+	if f.TOS != 0 {
+		t.Fatalf("Class.%s(): Expected a result on the stack, got a TOS of: %d", name, f.TOS)
+	}
+	return pop(f)
+}
+
+// the names, superclasses, interfaces, and component types of classes, array classes,
+// and primitive types
+func TestClassMirrorDescriptions(t *testing.T) {
+	setupObjectNativesTest()
+	mirror := classloader.GetClassMirror
+	goString := func(value interface{}) string {
+		return object.GetGoStringFromJavaStringPtr(value.(*object.Object))
+	}
+
+	names := map[string][2]string{ // the names and simple names returned for each class
+		"Sheep":                    {"Sheep", "Sheep"},
+		"java/lang/Object":         {"java.lang.Object", "Object"},
+		"[[I":                      {"[[I", "int[][]"},
+		"[Ljava/lang/String;":      {"[Ljava.lang.String;", "String[]"},
+		"int":                      {"int", "int"},
+		"com/example/Outer$Inner":  {"com.example.Outer$Inner", "Inner"},
+		"com/example/Outer$1Local": {"com.example.Outer$1Local", "Local"},
+		"com/example/Outer$1":      {"com.example.Outer$1", ""},
+	}
+	for className, expected := range names {
+		if name := goString(invokeClassMethod(t, mirror(className), "getName", "()Ljava/lang/String;", nil)); name != expected[0] {
+			t.Errorf("Class.getName(): Expected %s for %s, got: %s", expected[0], className, name)
+		}
+		if name := goString(invokeClassMethod(t, mirror(className), "getSimpleName", "()Ljava/lang/String;", nil)); name != expected[1] {
+			t.Errorf("Class.getSimpleName(): Expected %q for %s, got: %q", expected[1], className, name)
+		}
+	}
+
+	superclasses := map[string]*object.Object{"Sheep": mirror("Animal"), "[LDog;": mirror("java/lang/Object"),
+		"java/lang/Object": object.Null, "int": object.Null}
+	for className, expected := range superclasses {
+		if super := invokeClassMethod(t, mirror(className), "getSuperclass", "()Ljava/lang/Class;", nil); super != expected {
+			t.Errorf("Class.getSuperclass(): Expected %v for %s, got: %v", expected, className, super)
+		}
+	}
+
+	interfaces := invokeClassMethod(t, mirror("Sheep"), "getInterfaces", "()[Ljava/lang/Class;", nil).(*object.Object)
+	if array := *interfaces.Fields[0].Fvalue.(*[]*object.Object); *interfaces.Klass != "[Ljava/lang/Class;" ||
+		len(array) != 1 || array[0] != mirror("java/lang/Cloneable") {
+		t.Errorf("Class.getInterfaces(): Expected Sheep to implement only Cloneable, got: %v", array)
+	}
+
+	components := map[string]*object.Object{"[I": mirror("int"), "[[I": mirror("[I"),
+		"[LDog;": mirror("Dog"), "Dog": object.Null}
+	for className, expected := range components {
+		if component := invokeClassMethod(t, mirror(className), "getComponentType", "()Ljava/lang/Class;", nil); component != expected {
+			t.Errorf("Class.getComponentType(): Expected %v for %s, got: %v", expected, className, component)
+		}
+		isArray := types.ConvertGoBoolToJavaBool(expected != object.Null)
+		if value := invokeClassMethod(t, mirror(className), "isArray", "()Z", nil); value != isArray {
+			t.Errorf("Class.isArray(): Expected %d for %s, got: %v", isArray, className, value)
+		}
+	}
+}
+
+// isInstance() and isAssignableFrom() follow the rules of the instanceof operator
+func TestClassMirrorTypeChecks(t *testing.T) {
+	setupObjectNativesTest()
+	mirror := classloader.GetClassMirror
+	dogName := "Dog"
+	dog := object.MakeEmptyObject()
+	dog.Klass = &dogName
+	dogs := object.MakeArray("[LDog;", 1)
+
+	instances := []struct {
+		className string
+		obj       *object.Object
+		expected  int64
+	}{
+		{"Animal", dog, types.JavaBoolTrue},
+		{"Cat", dog, types.JavaBoolFalse},
+		{"java/lang/Object", dogs, types.JavaBoolTrue},
+		{"[LAnimal;", dogs, types.JavaBoolTrue},
+		{"[LCat;", dogs, types.JavaBoolFalse},
+		{"Animal", object.Null, types.JavaBoolFalse},
+	}
+	for _, test := range instances {
+		value := invokeClassMethod(t, mirror(test.className), "isInstance", "(Ljava/lang/Object;)Z", test.obj)
+		if value != test.expected {
+			t.Errorf("Class.isInstance(): Expected %d for %v of %s, got: %v", test.expected, test.obj, test.className, value)
+		}
+	}
+
+	assignables := []struct {
+		to, from string
+		expected int64
+	}{
+		{"Animal", "Dog", types.JavaBoolTrue},
+		{"Dog", "Animal", types.JavaBoolFalse},
+		{"java/lang/Cloneable", "Sheep", types.JavaBoolTrue},
+		{"[LAnimal;", "[LDog;", types.JavaBoolTrue},
+		{"int", "int", types.JavaBoolTrue},
+		{"java/lang/Object", "int", types.JavaBoolFalse},
+	}
+	for _, test := range assignables {
+		value := invokeClassMethod(t, mirror(test.to), "isAssignableFrom", "(Ljava/lang/Class;)Z", mirror(test.from))
+		if value != test.expected {
+			t.Errorf("Class.isAssignableFrom(): Expected %d for %s from %s, got: %v", test.expected, test.to, test.from, value)
+		}
+	}
+}
+
+// Class.forName() returns the mirror of a class, initializing it, and throws a
+// ClassNotFoundException for a class that can't be found
+func TestClassForName(t *testing.T) {
+	setupInitTest(initCounter, initBase)
+	classloader.MTable = make(map[string]classloader.MTentry)
+	classloader.MTableLoadNatives()
+	classloader.MethAreaInsert("java/lang/Class", &classloader.Klass{Status: 'X', Loader: "bootstrap",
+		Data: &classloader.ClData{Name: "java/lang/Class", Superclass: "java/lang/Object", ClInit: types.ClInitRun}})
+
+	c := newMessageTestCP()
+	forName := c.methodRef("java/lang/Class", "forName", "(Ljava/lang/String;)Ljava/lang/Class;")
+	code := []byte{ALOAD_0, INVOKESTATIC, byte(forName >> 8), byte(forName), ASTORE_0,
+		ALOAD_1, INVOKESTATIC, byte(forName >> 8), byte(forName), RETURN, ASTORE_1, RETURN}
+	excTable := []classloader.CodeException{{StartPc: 0, EndPc: 9, HandlerPc: 10,
+		CatchType: c.classRef("java/lang/ClassNotFoundException")}}
+	found, missing := "InitBase", "com.example.Missing"
+	f := runObjectNativesCode(t, c, code, []interface{}{object.CreateCompactStringFromGoString(&found),
+		object.CreateCompactStringFromGoString(&missing)}, excTable)
+
+	if f.Locals[0] != classloader.GetClassMirror("InitBase") {
+		t.Errorf("Class.forName(): Expected the mirror of InitBase, got: %v", f.Locals[0])
+	}
+	if state := classInitState("InitBase"); state != types.ClInitRun {
+		t.Errorf("Class.forName(): Expected InitBase to be initialized, got the state: %d", state)
+	}
+	if f.PC != 11 {
+		t.Fatalf("Class.forName(): Expected the ClassNotFoundException to be caught by the handler")
+	}
+	exc := f.Locals[1].(*object.Object)
+	if msg := getThrowableMessage(exc); *exc.Klass != "java/lang/ClassNotFoundException" || msg != "com.example.Missing" {
+		t.Errorf("Class.forName(): Expected java.lang.ClassNotFoundException: com.example.Missing, got: %s: %s",
+			*exc.Klass, msg)
+	}
+}
@@ -91,6 +91,11 @@ func getOptionRootAndArgs(option string) (string, string, error) {
 		return "", "", errors.New("empty option error")
 	}
 
+	// -Xss is followed immediately by its value, as in -Xss512k
+	if strings.HasPrefix(option, "-Xss") {
+		return "-Xss", option[len("-Xss"):], nil
+	}
+
 	// if the option has an embedded arg value, it'll come after a : or an =
 	argMarker := strings.Index(option, ":")
 	if argMarker == -1 {
@@ -162,10 +167,17 @@ where options include:
 	-showversion  print product version to the error stream and continue
 	--show-version
 				  print product version to the output stream and continue
+	-Xss<size>    set java thread stack size, which determines the maximum
+				  depth of a thread's frame stack
+	-Xverify:[none|remote|all]
+				  select the classes whose bytecode is verified when they
+				  are loaded. The default, remote, verifies all but the JDK's
 
 Jacobin-specific options:
 	-strictJDK    make user messages conform closely to the JDK's format
-	-trace:inst   display instruction-level tracing data to the console`
+	-trace:inst   display instruction-level tracing data to the console
+	-Xmaxframes:<n>
+				  set the maximum depth of a thread's frame stack`
 
 	_, _ = fmt.Fprintln(outStream, userMessage)
 }
@@ -541,3 +541,113 @@ func TestEmptyOptionForEmbeddedArg(t *testing.T) {
 		t.Error("Empty option should fail test for embedded args, but did not.")
 	}
 }
+
+func TestThreadStackSizeSetsMaxFrameDepth(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	args := []string{"jacobin", "-Xss512k"}
+	_ = HandleCli(args, &global)
+
+	expected := 512 * 1024 / globals.StackBytesPerFrame
+	if global.MaxFrameDepth != expected {
+		t.Errorf("-Xss512k should set the maximum frame depth to %d, got: %d",
+			expected, global.MaxFrameDepth)
+	}
+	if !global.Options["-Xss"].Set {
+		t.Error("-Xss should have been marked as set")
+	}
+}
+
+func TestThreadStackSizeFormats(t *testing.T) {
+	sizes := map[string]int64{
+		"4096": 4096,
+		"8k":   8 * 1024,
+		"2M":   2 * 1024 * 1024,
+		"1g":   1024 * 1024 * 1024,
+	}
+	for arg, expected := range sizes {
+		size, err := parseMemorySize(arg)
+		if err != nil || size != expected {
+			t.Errorf("Expected size of %s to be %d, got: %d (err: %v)", arg, expected, size, err)
+		}
+	}
+
+	for _, arg := range []string{"", "k", "12q", "-1m", "99999999999999999g"} {
+		if _, err := parseMemorySize(arg); err == nil {
+			t.Errorf("Expected an error for invalid size: %q", arg)
+		}
+	}
+}
+
+func TestInvalidThreadStackSize(t *testing.T) {
+	global := globals.InitGlobals("test")
+	log.Init()
+	LoadOptionsTable(global)
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_, err := setThreadStackSize(0, "12x", &global)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if err == nil || !strings.Contains(err.Error(), "Invalid thread stack size: -Xss12x") {
+		t.Errorf("Expected an error for -Xss12x, got: %v", err)
+	}
+	if global.MaxFrameDepth != globals.DefaultMaxFrameDepth {
+		t.Errorf("An invalid -Xss should not change the maximum frame depth, got: %d", global.MaxFrameDepth)
+	}
+}
+
+func TestMaxFramesOption(t *testing.T) {
+	global := globals.InitGlobals("test")
+	LoadOptionsTable(global)
+
+	args := []string{"jacobin", "-Xmaxframes:2500"}
+	_ = HandleCli(args, &global)
+
+	if global.MaxFrameDepth != 2500 {
+		t.Errorf("-Xmaxframes:2500 should set the maximum frame depth to 2500, got: %d", global.MaxFrameDepth)
+	}
+}
+
+func TestVerifyOption(t *testing.T) {
+	levels := map[string]int{"none": globals.VerifyNone, "remote": globals.VerifyRemote, "all": globals.VerifyAll}
+	for arg, expected := range levels {
+		global := globals.InitGlobals("test")
+		LoadOptionsTable(global)
+
+		args := []string{"jacobin", "-Xverify:" + arg}
+		_ = HandleCli(args, &global)
+
+		if global.VerifyLevel != expected {
+			t.Errorf("-Xverify:%s should set the verification level to %d, got: %d", arg, expected, global.VerifyLevel)
+		}
+	}
+
+	global := globals.InitGlobals("test")
+	if global.VerifyLevel != globals.VerifyRemote {
+		t.Errorf("The default verification level should be remote, got: %d", global.VerifyLevel)
+	}
+	log.Init()
+	LoadOptionsTable(global)
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	_, err := setVerifyLevel(0, "some", &global)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if err == nil || !strings.Contains(err.Error(), "Invalid verification level: -Xverify:some") {
+		t.Errorf("Expected an error for -Xverify:some, got: %v", err)
+	}
+	if global.VerifyLevel != globals.VerifyRemote {
+		t.Errorf("An invalid -Xverify should not change the verification level, got: %d", global.VerifyLevel)
+	}
+}
@@ -65,7 +65,7 @@ func formatStackUnderflowError(f *frames.Frame) {
 	f.PC = 0 // reset the current PC to point to the zeroth byte of our error data
 }
 
-// Prints out the frame stack
+// Prints out the frame stack in the format of a JDK stack trace
 func showFrameStack(t *thread.ExecThread) {
 	if globals.GetGlobalRef().JvmFrameStackShown == false {
 		frameStack := t.Stack.Front()
@@ -75,11 +75,8 @@ func showFrameStack(t *thread.ExecThread) {
 		}
 
 		// step through the list-based stack of called methods and print contents
-		for e := frameStack; e != nil; e = e.Next() {
-			val := e.Value.(*frames.Frame)
-			methName := fmt.Sprintf("%s.%s", val.ClName, val.MethName)
-			data := fmt.Sprintf("Method: %-40s PC: %03d", methName, val.PC)
-			_ = log.Log(data, log.SEVERE)
+		for _, line := range getStackTraceLines(frameStack, 0) {
+			_ = log.Log(line, log.SEVERE)
 		}
 		globals.GetGlobalRef().JvmFrameStackShown = true
 	}
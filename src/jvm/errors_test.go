@@ -11,9 +11,11 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
+	"jacobin/classloader"
 	"jacobin/frames"
 	"jacobin/globals"
 	"jacobin/log"
+	"jacobin/object"
 	"jacobin/thread"
 	"os"
 	"runtime/debug"
@@ -206,7 +208,7 @@ func TestShowFrameStackWithOneEntry(t *testing.T) {
 	g.StrictJDK = false
 
 	log.Init()
-	_ = log.SetLogLevel(log.INFO)
+	classloader.InitMethodArea()
 
 	// redirect stderr & stdout to capture results from stderr
 	normalStderr := os.Stderr
@@ -238,9 +240,8 @@ func TestShowFrameStackWithOneEntry(t *testing.T) {
 	os.Stdout = normalStdout
 
 	errMsg := string(msg)
-	if errMsg != "Method: testClass.main                           PC: 042\n" {
-		t.Errorf("Got this when expecting 'Method: testClass.main                           PC: 042': %s",
-			errMsg)
+	if errMsg != "\tat testClass.main(Unknown Source)\n" {
+		t.Errorf("Got this when expecting '\tat testClass.main(Unknown Source)': %s", errMsg)
 	}
 }
 
@@ -473,3 +474,133 @@ func TestShowPanicCauseNil(t *testing.T) {
 		t.Errorf("Got unexpected message for nil panic cause: %s", errMsg)
 	}
 }
+
+// the stack trace gives the source file and line of each frame's method, as the JDK does
+func TestStackTraceLinesWithLineNumbers(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+	classloader.MethAreaInsert("com/foo/Bar", &classloader.Klass{Status: 'X', Loader: "app",
+		Data: &classloader.ClData{Name: "com/foo/Bar", Superclass: "java/lang/Object", SourceFile: "Bar.java"}})
+	classloader.MethAreaInsert("Other", &classloader.Klass{Status: 'X', Loader: "app",
+		Data: &classloader.ClData{Name: "Other", Superclass: "java/lang/Object"}})
+
+	gf := frames.CreateFrame(1)
+	gf.ClName = "java/io/PrintStream"
+	gf.MethName = "println(I)V"
+	gf.Ftype = 'G'
+
+	baz := frames.CreateFrame(1) // on the invocation of println(), which began at 3
+	baz.ClName = "com/foo/Bar"
+	baz.MethName = "baz"
+	baz.PC = 5
+	baz.LineNums = []classloader.LineNumber{{StartPc: 0, Line: 40}, {StartPc: 3, Line: 42}, {StartPc: 6, Line: 43}}
+
+	noLines := frames.CreateFrame(1)
+	noLines.ClName = "com/foo/Bar"
+	noLines.MethName = "run"
+	noLines.PC = 3
+
+	other := frames.CreateFrame(1)
+	other.ClName = "Other"
+	other.MethName = "main"
+	other.PC = 3
+	other.LineNums = []classloader.LineNumber{{StartPc: 0, Line: 5}}
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(other)
+	fs.PushFront(noLines)
+	fs.PushFront(baz)
+	fs.PushFront(gf)
+
+	expected := []string{
+		"\tat java.io.PrintStream.println(Native Method)",
+		"\tat com.foo.Bar.baz(Bar.java:42)",
+		"\tat com.foo.Bar.run(Bar.java)",
+		"\tat Other.main(Unknown Source)",
+	}
+	lines := getStackTraceLines(fs.Front(), 0)
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected stack trace:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(lines, "\n"))
+	}
+}
+
+// fillInStackTrace() records the frames below those of the throwable's constructors
+func TestFillInStackTraceSkipsConstructors(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+	classes := map[string]string{"java/lang/Object": "", "java/lang/Throwable": "java/lang/Object",
+		"java/lang/Exception": "java/lang/Throwable", "MyException": "java/lang/Exception", "Test": "java/lang/Object"}
+	for name, superclass := range classes {
+		classloader.MethAreaInsert(name, &classloader.Klass{Status: 'X', Loader: "app",
+			Data: &classloader.ClData{Name: name, Superclass: superclass, SourceFile: "Test.java"}})
+	}
+
+	th := thread.CreateThread()
+	th.Stack = frames.CreateFrameStack()
+	thread.AddThreadToTable(&th, &globals.GetGlobalRef().Threads)
+	defer thread.RemoveThreadFromTable(&th, &globals.GetGlobalRef().Threads)
+
+	calls := []struct {
+		className, methName string
+		ftype               byte
+	}{
+		{"Test", "main", 'J'},
+		{"Test", "helper", 'J'},
+		{"MyException", "<init>", 'J'},
+		{"java/lang/Exception", "<init>", 'J'},
+		{"java/lang/Throwable", "<init>", 'J'},
+		{"java/lang/Throwable", "fillInStackTrace", 'J'},
+		{"java/lang/Throwable", "fillInStackTrace(I)Ljava/lang/Throwable;", 'G'},
+	}
+	for i, call := range calls {
+		f := frames.CreateFrame(1)
+		f.ClName = call.className
+		f.MethName = call.methName
+		f.Ftype = call.ftype
+		f.PC = 4
+		f.LineNums = []classloader.LineNumber{{StartPc: 0, Line: 10 * (i + 1)}}
+		th.Stack.PushFront(f)
+	}
+
+	excName := "MyException"
+	exc := object.MakeEmptyObject()
+	exc.Klass = &excName
+	classloader.FillInStackTrace(exc, th.ID)
+
+	expected := []string{"\tat Test.helper(Test.java:20)", "\tat Test.main(Test.java:10)"}
+	trace := getBacktrace(exc)
+	if strings.Join(trace, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected stack trace:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(trace, "\n"))
+	}
+}
+
+// printStackTrace() shows the causes of the throwable, omitting the frames they have in
+// common with the trace of the throwable they caused
+func TestStackTraceReportWithCause(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	outer := createThrowable("java/lang/RuntimeException", "outer")
+	inner := createThrowable("java/io/IOException", "")
+	setBacktrace(outer, []string{"\tat Test.handle(Test.java:12)", "\tat Test.main(Test.java:5)"})
+	setBacktrace(inner, []string{"\tat Test.read(Test.java:30)", "\tat Test.handle(Test.java:10)",
+		"\tat Test.main(Test.java:5)"})
+	classloader.SetObjectField(outer, "cause", object.Field{Ftype: "Ljava/lang/Throwable;", Fvalue: inner})
+	classloader.SetObjectField(inner, "cause", object.Field{Ftype: "Ljava/lang/Throwable;", Fvalue: inner})
+
+	expected := []string{
+		"java.lang.RuntimeException: outer",
+		"\tat Test.handle(Test.java:12)",
+		"\tat Test.main(Test.java:5)",
+		"Caused by: java.io.IOException",
+		"\tat Test.read(Test.java:30)",
+		"\tat Test.handle(Test.java:10)",
+		"\t... 1 more",
+	}
+	report := getStackTraceReport(outer)
+	if strings.Join(report, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected report:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(report, "\n"))
+	}
+}
@@ -0,0 +1,497 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"strconv"
+	"strings"
+)
+
+// The detail messages of the exceptions the interpreter throws for runtime faults, such
+// as an array index that's out of bounds. They're worded exactly as the JDK words them,
+// so that programs that show or test the messages behave as they do on the JDK.
+
+// arrayIndexMessage returns the message of an ArrayIndexOutOfBoundsException
+func arrayIndexMessage(index int64, length int) string {
+	return fmt.Sprintf("Index %d out of bounds for length %d", index, length)
+}
+
+// classCastMessage returns the message of a ClassCastException thrown when an object
+// of class objClass is cast to class castClass. As in the JDK, the message identifies
+// the module and the classloader of both classes, for example:
+//
+//	class Dog cannot be cast to class Cat (Dog and Cat are in unnamed module of loader 'app')
+func classCastMessage(objClass, castClass string) string {
+	objName, castName := javaClassName(objClass), javaClassName(castClass)
+	objModule, castModule := moduleOfClass(objClass), moduleOfClass(castClass)
+
+	var where string
+	if objModule == castModule {
+		where = fmt.Sprintf("%s and %s are in %s", objName, castName, objModule)
+	} else {
+		where = fmt.Sprintf("%s is in %s; %s is in %s", objName, objModule, castName, castModule)
+	}
+	return fmt.Sprintf("class %s cannot be cast to class %s (%s)", objName, castName, where)
+}
+
+// moduleOfClass describes the module and classloader of a class as the JDK does in the
+// messages of exceptions. Classes loaded by the bootstrap classloader come from the
+// JDK's modules; all other classes are in the unnamed module of their classloader. An
+// array is in the module of its element type.
+func moduleOfClass(className string) string {
+	elementType := strings.TrimLeft(className, "[")
+	if elementType != className { // it's an array
+		if !strings.HasPrefix(elementType, "L") {
+			return "module java.base of loader 'bootstrap'" // primitive arrays are in java.base
+		}
+		elementType = strings.TrimSuffix(elementType[1:], ";")
+	}
+
+	loader := "app"
+	k := classloader.MethAreaFetch(elementType)
+	if k != nil && k.Loader != "" {
+		loader = k.Loader
+	}
+	if loader != "bootstrap" {
+		return fmt.Sprintf("unnamed module of loader '%s'", loader)
+	}
+
+	module := "java.base"
+	if classloader.JmodMapSize() > 0 {
+		if jmod := classloader.JmodMapFetch(elementType); jmod != "" {
+			module = strings.TrimSuffix(jmod, ".jmod")
+		}
+	}
+	return fmt.Sprintf("module %s of loader 'bootstrap'", module)
+}
+
+// The "helpful" messages of NullPointerExceptions (see JEP 358) describe the action that
+// failed and, if it can be determined, where the null came from, for example:
+//
+//	Cannot load from int array because "<local1>" is null
+//
+// Where the null came from is found by walking backward through the method's bytecode
+// from the failing instruction, tracking the position of the null on the operand stack,
+// until reaching the instruction that pushed it. Unlike the JDK, which analyzes every
+// path through the method, the walk stops at the start of a basic block, in which case
+// only the failed action is described.
+
+// the maximum depth of the expression that's shown as the source of a null, as in the JDK
+const maxNullSourceDetail = 5
+
+// the number of operand stack slots popped and pushed by the instructions whose effect
+// on the stack does not depend on their operands. Those that do are handled in
+// stackEffect(), and any other instruction ends the search for the source of a null.
+var fixedStackEffects = map[byte][2]int{
+	NOP: {0, 0}, ACONST_NULL: {0, 1}, ICONST_M1: {0, 1}, ICONST_0: {0, 1}, ICONST_1: {0, 1},
+	ICONST_2: {0, 1}, ICONST_3: {0, 1}, ICONST_4: {0, 1}, ICONST_5: {0, 1}, LCONST_0: {0, 2},
+	LCONST_1: {0, 2}, FCONST_0: {0, 1}, FCONST_1: {0, 1}, FCONST_2: {0, 1}, DCONST_0: {0, 2},
+	DCONST_1: {0, 2}, BIPUSH: {0, 1}, SIPUSH: {0, 1}, LDC: {0, 1}, LDC_W: {0, 1}, LDC2_W: {0, 2},
+
+	ILOAD: {0, 1}, LLOAD: {0, 2}, FLOAD: {0, 1}, DLOAD: {0, 2}, ALOAD: {0, 1},
+	ILOAD_0: {0, 1}, ILOAD_1: {0, 1}, ILOAD_2: {0, 1}, ILOAD_3: {0, 1},
+	LLOAD_0: {0, 2}, LLOAD_1: {0, 2}, LLOAD_2: {0, 2}, LLOAD_3: {0, 2},
+	FLOAD_0: {0, 1}, FLOAD_1: {0, 1}, FLOAD_2: {0, 1}, FLOAD_3: {0, 1},
+	DLOAD_0: {0, 2}, DLOAD_1: {0, 2}, DLOAD_2: {0, 2}, DLOAD_3: {0, 2},
+	ALOAD_0: {0, 1}, ALOAD_1: {0, 1}, ALOAD_2: {0, 1}, ALOAD_3: {0, 1},
+	IALOAD: {2, 1}, LALOAD: {2, 2}, FALOAD: {2, 1}, DALOAD: {2, 2}, AALOAD: {2, 1},
+	BALOAD: {2, 1}, CALOAD: {2, 1}, SALOAD: {2, 1},
+
+	ISTORE: {1, 0}, LSTORE: {2, 0}, FSTORE: {1, 0}, DSTORE: {2, 0}, ASTORE: {1, 0},
+	ISTORE_0: {1, 0}, ISTORE_1: {1, 0}, ISTORE_2: {1, 0}, ISTORE_3: {1, 0},
+	LSTORE_0: {2, 0}, LSTORE_1: {2, 0}, LSTORE_2: {2, 0}, LSTORE_3: {2, 0},
+	FSTORE_0: {1, 0}, FSTORE_1: {1, 0}, FSTORE_2: {1, 0}, FSTORE_3: {1, 0},
+	DSTORE_0: {2, 0}, DSTORE_1: {2, 0}, DSTORE_2: {2, 0}, DSTORE_3: {2, 0},
+	ASTORE_0: {1, 0}, ASTORE_1: {1, 0}, ASTORE_2: {1, 0}, ASTORE_3: {1, 0},
+	IASTORE: {3, 0}, LASTORE: {4, 0}, FASTORE: {3, 0}, DASTORE: {4, 0}, AASTORE: {3, 0},
+	BASTORE: {3, 0}, CASTORE: {3, 0}, SASTORE: {3, 0}, POP: {1, 0}, POP2: {2, 0},
+
+	IADD: {2, 1}, LADD: {4, 2}, FADD: {2, 1}, DADD: {4, 2}, ISUB: {2, 1}, LSUB: {4, 2},
+	FSUB: {2, 1}, DSUB: {4, 2}, IMUL: {2, 1}, LMUL: {4, 2}, FMUL: {2, 1}, DMUL: {4, 2},
+	IDIV: {2, 1}, LDIV: {4, 2}, FDIV: {2, 1}, DDIV: {4, 2}, IREM: {2, 1}, LREM: {4, 2},
+	FREM: {2, 1}, DREM: {4, 2}, INEG: {1, 1}, LNEG: {2, 2}, FNEG: {1, 1}, DNEG: {2, 2},
+	ISHL: {2, 1}, LSHL: {3, 2}, ISHR: {2, 1}, LSHR: {3, 2}, IUSHR: {2, 1}, LUSHR: {3, 2},
+	IAND: {2, 1}, LAND: {4, 2}, IOR: {2, 1}, LOR: {4, 2}, IXOR: {2, 1}, LXOR: {4, 2},
+	IINC: {0, 0}, I2L: {1, 2}, I2F: {1, 1}, I2D: {1, 2}, L2I: {2, 1}, L2F: {2, 1},
+	L2D: {2, 2}, F2I: {1, 1}, F2L: {1, 2}, F2D: {1, 2}, D2I: {2, 1}, D2L: {2, 2},
+	D2F: {2, 1}, I2B: {1, 1}, I2C: {1, 1}, I2S: {1, 1},
+	LCMP: {4, 1}, FCMPL: {2, 1}, FCMPG: {2, 1}, DCMPL: {4, 1}, DCMPG: {4, 1},
+
+	IFEQ: {1, 0}, IFNE: {1, 0}, IFLT: {1, 0}, IFGE: {1, 0}, IFGT: {1, 0}, IFLE: {1, 0},
+	IF_ICMPEQ: {2, 0}, IF_ICMPNE: {2, 0}, IF_ICMPLT: {2, 0}, IF_ICMPGE: {2, 0},
+	IF_ICMPGT: {2, 0}, IF_ICMPLE: {2, 0}, IF_ACMPEQ: {2, 0}, IF_ACMPNE: {2, 0},
+	IFNULL: {1, 0}, IFNONNULL: {1, 0},
+
+	NEW: {0, 1}, NEWARRAY: {1, 1}, ANEWARRAY: {1, 1}, ARRAYLENGTH: {1, 1},
+	CHECKCAST: {1, 1}, INSTANCEOF: {1, 1}, MONITORENTER: {1, 0}, MONITOREXIT: {1, 0},
+}
+
+// npeAnalyzer finds the description of a NullPointerException in the bytecode of a frame
+type npeAnalyzer struct {
+	f       *frames.Frame
+	code    []byte
+	starts  []int        // the offsets of the instructions, in order
+	targets map[int]bool // the offsets that branches and exception handlers jump to
+}
+
+// nullPointerMessage returns the message of a NullPointerException thrown by the
+// instruction in frame f at f.PC, which can point to any byte of the instruction. It
+// returns "" if the instruction is not one that throws NullPointerExceptions.
+func nullPointerMessage(f *frames.Frame) string {
+	a := newNpeAnalyzer(f)
+	if a == nil {
+		return ""
+	}
+
+	i := len(a.starts) - 1
+	for i > 0 && a.starts[i] > f.PC {
+		i--
+	}
+
+	action, depth := a.failedAction(a.starts[i])
+	if action == "" {
+		return ""
+	}
+
+	source := a.source(i, depth)
+	if source < 0 {
+		return action
+	}
+	switch a.code[a.starts[source]] {
+	case INVOKEVIRTUAL, INVOKESPECIAL, INVOKESTATIC, INVOKEINTERFACE:
+		return action + " because the return value of \"" + a.describe(source, maxNullSourceDetail) + "\" is null"
+	}
+	if cause := a.describe(source, maxNullSourceDetail); cause != "" {
+		return action + " because \"" + cause + "\" is null"
+	}
+	return action
+}
+
+// newNpeAnalyzer finds the instructions of the frame's method and the targets of its
+// branches. Returns nil if the bytecode can't be decoded.
+func newNpeAnalyzer(f *frames.Frame) *npeAnalyzer {
+	a := npeAnalyzer{f: f, code: f.Meth, targets: make(map[int]bool)}
+	for pc := 0; pc < len(a.code); {
+		length := classloader.InstructionLength(a.code, pc)
+		if length == 0 {
+			return nil
+		}
+		a.starts = append(a.starts, pc)
+
+		switch op := a.code[pc]; {
+		case (op >= IFEQ && op <= JSR) || op == IFNULL || op == IFNONNULL:
+			a.targets[pc+int(int16(a.u2(pc+1)))] = true
+		case op == GOTO_W || op == JSR_W:
+			a.targets[pc+a.s4(pc+1)] = true
+		case op == TABLESWITCH || op == LOOKUPSWITCH:
+			base := pc + 1 + (3 - pc%4)
+			a.targets[pc+a.s4(base)] = true // the default
+			if op == TABLESWITCH {
+				for entry := base + 12; entry < pc+length; entry += 4 {
+					a.targets[pc+a.s4(entry)] = true
+				}
+			} else {
+				for pair := base + 8; pair < pc+length; pair += 8 {
+					a.targets[pc+a.s4(pair+4)] = true
+				}
+			}
+		}
+		pc += length
+	}
+
+	if len(a.starts) == 0 {
+		return nil
+	}
+	for _, handler := range f.ExcTable {
+		a.targets[int(handler.HandlerPc)] = true
+	}
+	return &a
+}
+
+// failedAction describes the action of the instruction at pc that failed because of a
+// null reference, and returns the position of that reference on the operand stack, as
+// the number of slots above it. Returns "" if the instruction can't throw an NPE.
+func (a *npeAnalyzer) failedAction(pc int) (string, int) {
+	switch op := a.code[pc]; op {
+	case IALOAD, LALOAD, FALOAD, DALOAD, AALOAD, BALOAD, CALOAD, SALOAD:
+		return "Cannot load from " + arrayElementName(op-IALOAD) + " array", 1
+	case IASTORE, FASTORE, AASTORE, BASTORE, CASTORE, SASTORE:
+		return "Cannot store to " + arrayElementName(op-IASTORE) + " array", 2
+	case LASTORE, DASTORE:
+		return "Cannot store to " + arrayElementName(op-IASTORE) + " array", 3
+	case ARRAYLENGTH:
+		return "Cannot read the array length", 0
+	case ATHROW:
+		return "Cannot throw exception", 0
+	case MONITORENTER:
+		return "Cannot enter synchronized block", 0
+	case MONITOREXIT:
+		return "Cannot exit synchronized block", 0
+	case GETFIELD:
+		_, fieldName, _ := getFieldInfoFromCPfieldref(a.f.CP, a.u2(pc+1))
+		return "Cannot read field \"" + fieldName + "\"", 0
+	case PUTFIELD:
+		_, fieldName, fieldType := getFieldInfoFromCPfieldref(a.f.CP, a.u2(pc+1))
+		return "Cannot assign field \"" + fieldName + "\"", slotsFor(fieldType)
+	case INVOKEVIRTUAL, INVOKESPECIAL, INVOKEINTERFACE:
+		_, _, methodType := getMethInfoFromCPmethref(a.f.CP, a.u2(pc+1))
+		return "Cannot invoke \"" + a.methodName(pc) + "\"", countArgSlots(methodType)
+	}
+	return "", 0
+}
+
+// the names of array element types in the order of the array loads and stores
+func arrayElementName(n byte) string {
+	return []string{"int", "long", "float", "double", "object", "byte/boolean", "char", "short"}[n]
+}
+
+// source returns the index of the instruction that pushed the value that's depth slots
+// below the top of the operand stack when the i-th instruction starts, or -1 if it
+// can't be found without following a branch.
+func (a *npeAnalyzer) source(i, depth int) int {
+	for i > 0 {
+		if a.targets[a.starts[i]] { // the value could have come from more than one place
+			return -1
+		}
+		i--
+
+		pops, pushes, ok := a.stackEffect(a.starts[i])
+		if !ok {
+			return -1
+		}
+		if a.code[a.starts[i]] == DUP && depth < 2 { // both copies are the duplicated value
+			depth = 0
+			continue
+		}
+		if depth < pushes {
+			if pushes == 1 { // a long or double is never the source of a null or an index
+				return i
+			}
+			return -1
+		}
+		depth += pops - pushes
+	}
+	return -1
+}
+
+// stackEffect returns the number of operand stack slots popped and pushed by the
+// instruction at pc. Returns false if the instruction doesn't fall through to the next
+// one or if its effect isn't tracked.
+func (a *npeAnalyzer) stackEffect(pc int) (int, int, bool) {
+	op := a.code[pc]
+	if effect, ok := fixedStackEffects[op]; ok {
+		return effect[0], effect[1], true
+	}
+
+	switch op {
+	case DUP:
+		return 1, 2, true
+	case GETSTATIC, PUTSTATIC, GETFIELD, PUTFIELD:
+		_, _, fieldType := getFieldInfoFromCPfieldref(a.f.CP, a.u2(pc+1))
+		if fieldType == "" {
+			return 0, 0, false
+		}
+		size := slotsFor(fieldType)
+		switch op {
+		case GETSTATIC:
+			return 0, size, true
+		case PUTSTATIC:
+			return size, 0, true
+		case GETFIELD:
+			return 1, size, true
+		default:
+			return 1 + size, 0, true
+		}
+	case INVOKEVIRTUAL, INVOKESPECIAL, INVOKESTATIC, INVOKEINTERFACE:
+		_, _, methodType := getMethInfoFromCPmethref(a.f.CP, a.u2(pc+1))
+		if methodType == "" {
+			return 0, 0, false
+		}
+		pops := countArgSlots(methodType)
+		if op != INVOKESTATIC {
+			pops += 1 // the object reference
+		}
+		return pops, slotsFor(methodType[strings.IndexByte(methodType, ')')+1:]), true
+	case MULTIANEWARRAY:
+		return int(a.code[pc+3]), 1, true
+	case WIDE:
+		if effect, ok := fixedStackEffects[a.code[pc+1]]; ok { // loads, stores, and iinc
+			return effect[0], effect[1], true
+		}
+	}
+	return 0, 0, false
+}
+
+// describe returns the Java expression for the value pushed by the i-th instruction,
+// nesting up to maxDetail subexpressions, or "" if it can't be described
+func (a *npeAnalyzer) describe(i, maxDetail int) string {
+	if i < 0 || maxDetail <= 0 {
+		return ""
+	}
+
+	pc := a.starts[i]
+	switch op := a.code[pc]; op {
+	case ALOAD_0, ALOAD_1, ALOAD_2, ALOAD_3:
+		return a.localName(int(op - ALOAD_0))
+	case ILOAD_0, ILOAD_1, ILOAD_2, ILOAD_3:
+		return a.localName(int(op - ILOAD_0))
+	case ALOAD, ILOAD:
+		return a.localName(int(a.code[pc+1]))
+	case WIDE:
+		if a.code[pc+1] == ALOAD || a.code[pc+1] == ILOAD {
+			return a.localName(a.u2(pc + 2))
+		}
+	case ACONST_NULL:
+		return "null"
+	case ICONST_M1, ICONST_0, ICONST_1, ICONST_2, ICONST_3, ICONST_4, ICONST_5:
+		return strconv.Itoa(int(op) - ICONST_0)
+	case BIPUSH:
+		return strconv.Itoa(int(int8(a.code[pc+1])))
+	case SIPUSH:
+		return strconv.Itoa(int(int16(a.u2(pc + 1))))
+	case GETSTATIC:
+		className, fieldName, _ := getFieldInfoFromCPfieldref(a.f.CP, a.u2(pc+1))
+		return shortJavaClassName(className) + "." + fieldName
+	case GETFIELD:
+		_, fieldName, _ := getFieldInfoFromCPfieldref(a.f.CP, a.u2(pc+1))
+		if obj := a.describe(a.source(i, 0), maxDetail-1); obj != "" {
+			return obj + "." + fieldName
+		}
+		return fieldName
+	case IALOAD, LALOAD, FALOAD, DALOAD, AALOAD, BALOAD, CALOAD, SALOAD:
+		array := a.describe(a.source(i, 1), maxDetail-1)
+		if array == "" {
+			array = "<array>"
+		}
+		index := a.describe(a.source(i, 0), maxDetail-1)
+		if index == "" {
+			index = "..."
+		}
+		return array + "[" + index + "]"
+	case INVOKEVIRTUAL, INVOKESPECIAL, INVOKESTATIC, INVOKEINTERFACE:
+		return a.methodName(pc)
+	}
+	return ""
+}
+
+// localName returns the name of a local variable. Local variable tables are not kept,
+// so the JDK's names for unnamed variables are used: this, <parameterN> for the
+// parameters that the method never changes, and <localN> for all others.
+func (a *npeAnalyzer) localName(slot int) string {
+	isStatic := true
+	if k := classloader.MethAreaFetch(a.f.ClName); k != nil && k.Data != nil {
+		if m, ok := k.Data.MethodTable[a.f.MethName+a.f.MethType]; ok {
+			isStatic = m.AccessFlags&classloader.AccStatic != 0
+		}
+	}
+
+	isParameter := !a.isWritten(slot)
+	if !isStatic && slot == 0 && isParameter {
+		return "this"
+	}
+
+	paramSlot := 0
+	if !isStatic {
+		paramSlot = 1
+	}
+	for n, param := range parseParamDescriptors(a.f.MethType) {
+		size := slotsFor(param)
+		if slot >= paramSlot && slot < paramSlot+size && isParameter {
+			return fmt.Sprintf("<parameter%d>", n+1)
+		}
+		paramSlot += size
+	}
+	return fmt.Sprintf("<local%d>", slot)
+}
+
+// isWritten reports whether any instruction of the method stores into a local variable
+func (a *npeAnalyzer) isWritten(slot int) bool {
+	for _, pc := range a.starts {
+		var written, size int
+		switch op := a.code[pc]; {
+		case op == ISTORE || op == FSTORE || op == ASTORE || op == IINC:
+			written, size = int(a.code[pc+1]), 1
+		case op == LSTORE || op == DSTORE:
+			written, size = int(a.code[pc+1]), 2
+		case op >= ISTORE_0 && op <= ASTORE_3:
+			written, size = int(op-ISTORE_0)%4, 1
+			if op >= LSTORE_0 && op <= LSTORE_3 || op >= DSTORE_0 && op <= DSTORE_3 {
+				size = 2
+			}
+		case op == WIDE:
+			switch a.code[pc+1] {
+			case ISTORE, FSTORE, ASTORE, IINC:
+				written, size = a.u2(pc+2), 1
+			case LSTORE, DSTORE:
+				written, size = a.u2(pc+2), 2
+			}
+		}
+		if size > 0 && slot >= written && slot < written+size {
+			return true
+		}
+	}
+	return false
+}
+
+// methodName returns the class, name, and parameter types of the method invoked by the
+// instruction at pc, in the format of the JDK's messages, such as String.charAt(int)
+func (a *npeAnalyzer) methodName(pc int) string {
+	className, methodName, methodType := getMethInfoFromCPmethref(a.f.CP, a.u2(pc+1))
+	var params []string
+	for _, param := range parseParamDescriptors(methodType) {
+		params = append(params, javaTypeName(param))
+	}
+	return shortJavaClassName(className) + "." + methodName + "(" + strings.Join(params, ", ") + ")"
+}
+
+// javaTypeName converts a field descriptor into the name of the type as it's written
+// in Java, such as int[] for [I, shortening Object and String as the JDK does
+func javaTypeName(desc string) string {
+	dimensions := strings.Count(desc, "[")
+	var name string
+	switch elementType := desc[dimensions:]; elementType {
+	case "B":
+		name = "byte"
+	case "C":
+		name = "char"
+	case "D":
+		name = "double"
+	case "F":
+		name = "float"
+	case "I":
+		name = "int"
+	case "J":
+		name = "long"
+	case "S":
+		name = "short"
+	case "Z":
+		name = "boolean"
+	default:
+		name = shortJavaClassName(strings.TrimSuffix(strings.TrimPrefix(elementType, "L"), ";"))
+	}
+	return name + strings.Repeat("[]", dimensions)
+}
+
+// shortJavaClassName converts an internal class name to its Java name, which the JDK's
+// messages shorten to Object and String for those two classes
+func shortJavaClassName(className string) string {
+	switch className {
+	case "java/lang/Object":
+		return "Object"
+	case "java/lang/String":
+		return "String"
+	}
+	return javaClassName(className)
+}
+
+func (a *npeAnalyzer) u2(pos int) int { return int(a.code[pos])<<8 | int(a.code[pos+1]) }
+func (a *npeAnalyzer) s4(pos int) int {
+	return int(int32(uint32(a.u2(pos))<<16 | uint32(a.u2(pos+2))))
+}
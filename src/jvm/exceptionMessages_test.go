@@ -0,0 +1,335 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/object"
+	"testing"
+)
+
+// messageTestCP builds a constant pool for the tests of exception messages
+type messageTestCP struct {
+	cp classloader.CPool
+}
+
+func newMessageTestCP() *messageTestCP {
+	c := messageTestCP{}
+	c.cp.CpIndex = []classloader.CpEntry{{Type: classloader.Dummy}}
+	return &c
+}
+
+func (c *messageTestCP) add(entryType uint16, slot int) uint16 {
+	c.cp.CpIndex = append(c.cp.CpIndex, classloader.CpEntry{Type: entryType, Slot: uint16(slot)})
+	return uint16(len(c.cp.CpIndex) - 1)
+}
+
+func (c *messageTestCP) utf8(s string) uint16 {
+	c.cp.Utf8Refs = append(c.cp.Utf8Refs, s)
+	return c.add(classloader.UTF8, len(c.cp.Utf8Refs)-1)
+}
+
+func (c *messageTestCP) classRef(name string) uint16 {
+	c.cp.ClassRefs = append(c.cp.ClassRefs, c.utf8(name))
+	return c.add(classloader.ClassRef, len(c.cp.ClassRefs)-1)
+}
+
+func (c *messageTestCP) nameAndType(name, desc string) uint16 {
+	c.cp.NameAndTypes = append(c.cp.NameAndTypes,
+		classloader.NameAndTypeEntry{NameIndex: c.utf8(name), DescIndex: c.utf8(desc)})
+	return c.add(classloader.NameAndType, len(c.cp.NameAndTypes)-1)
+}
+
+func (c *messageTestCP) fieldRef(className, name, desc string) uint16 {
+	c.cp.FieldRefs = append(c.cp.FieldRefs,
+		classloader.FieldRefEntry{ClassIndex: c.classRef(className), NameAndType: c.nameAndType(name, desc)})
+	return c.add(classloader.FieldRef, len(c.cp.FieldRefs)-1)
+}
+
+func (c *messageTestCP) methodRef(className, name, desc string) uint16 {
+	c.cp.MethodRefs = append(c.cp.MethodRefs,
+		classloader.MethodRefEntry{ClassIndex: c.classRef(className), NameAndType: c.nameAndType(name, desc)})
+	return c.add(classloader.MethodRef, len(c.cp.MethodRefs)-1)
+}
+
+// the messages of NullPointerExceptions describe the failed action and where the null came from
+func TestNullPointerMessages(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+
+	c := newMessageTestCP()
+	name := byte(c.fieldRef("Test", "name", "Ljava/lang/String;"))
+	count := byte(c.fieldRef("Test", "count", "J"))
+	list := byte(c.fieldRef("Test", "list", "[I"))
+	length := byte(c.methodRef("java/lang/String", "length", "()I"))
+	get := byte(c.methodRef("Test", "get", "()LTest;"))
+	put := byte(c.methodRef("Test", "put", "(ILjava/lang/String;[[J)V"))
+
+	tests := []struct {
+		code     []byte
+		pc       int
+		methType string
+		expected string
+	}{
+		{[]byte{ALOAD_1, ICONST_0, IALOAD}, 2, "",
+			`Cannot load from int array because "<local1>" is null`},
+		{[]byte{ALOAD_0, ICONST_0, IALOAD}, 2, "([I)V",
+			`Cannot load from int array because "<parameter1>" is null`},
+		{[]byte{ALOAD_0, ICONST_0, IALOAD, ACONST_NULL, ASTORE_0}, 2, "([I)V",
+			`Cannot load from int array because "<local0>" is null`},
+		{[]byte{ALOAD_1, ICONST_2, AALOAD, ICONST_0, ILOAD_2, CASTORE}, 5, "",
+			`Cannot store to char array because "<local1>[2]" is null`},
+		{[]byte{ALOAD_1, ILOAD_2, ICONST_1, IADD, AALOAD, ARRAYLENGTH}, 5, "",
+			`Cannot read the array length because "<local1>[...]" is null`},
+		{[]byte{ACONST_NULL, ATHROW}, 1, "",
+			`Cannot throw exception because "null" is null`},
+		{[]byte{ALOAD_1, GETFIELD, 0, name, INVOKEVIRTUAL, 0, length}, 6, "",
+			`Cannot invoke "String.length()" because "<local1>.name" is null`},
+		{[]byte{ALOAD_1, INVOKEVIRTUAL, 0, get, GETFIELD, 0, name}, 4, "",
+			`Cannot read field "name" because the return value of "Test.get()" is null`},
+		{[]byte{GETSTATIC, 0, list, ICONST_1, ILOAD_2, IASTORE}, 5, "",
+			`Cannot store to int array because "Test.list" is null`},
+		{[]byte{ALOAD_1, ICONST_0, ALOAD_2, ACONST_NULL, INVOKEVIRTUAL, 0, put}, 5, "",
+			`Cannot invoke "Test.put(int, String, long[][])" because "<local1>" is null`},
+		{[]byte{ALOAD_1, DUP, ASTORE_2, MONITORENTER}, 3, "",
+			`Cannot enter synchronized block because "<local1>" is null`},
+		{[]byte{ALOAD_1, LCONST_0, PUTFIELD, 0, count}, 2, "",
+			`Cannot assign field "count" because "<local1>" is null`},
+		{[]byte{ALOAD_1, ICONST_0, IALOAD, GOTO, 0xFF, 0xFF}, 2, "", // the branch to 2 stops the search
+			`Cannot load from int array`},
+	}
+
+	for _, test := range tests {
+		f := frames.CreateFrame(4)
+		f.Meth = test.code
+		f.PC = test.pc
+		f.CP = &c.cp
+		f.ClName = "Test"
+		f.MethType = test.methType
+		if msg := nullPointerMessage(f); msg != test.expected {
+			t.Errorf("Expected NPE message %q for %v, got: %q", test.expected, test.code, msg)
+		}
+	}
+}
+
+// the messages of ClassCastExceptions give the module and classloader of the classes
+func TestClassCastMessages(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+	for _, name := range []string{"Dog", "Cat"} {
+		classloader.MethAreaInsert(name, &classloader.Klass{Status: 'X', Loader: "app",
+			Data: &classloader.ClData{Name: name, Superclass: "java/lang/Object"}})
+	}
+	for _, name := range []string{"java/lang/String", "java/lang/Integer"} {
+		classloader.MethAreaInsert(name, &classloader.Klass{Status: 'X', Loader: "bootstrap",
+			Data: &classloader.ClData{Name: name, Superclass: "java/lang/Object"}})
+	}
+
+	tests := []struct{ objClass, castClass, expected string }{
+		{"Dog", "Cat", "class Dog cannot be cast to class Cat (Dog and Cat are in unnamed module of loader 'app')"},
+		{"java/lang/String", "java/lang/Integer", "class java.lang.String cannot be cast to class " +
+			"java.lang.Integer (java.lang.String and java.lang.Integer are in module java.base of loader 'bootstrap')"},
+		{"Dog", "java/lang/String", "class Dog cannot be cast to class java.lang.String (Dog is in unnamed " +
+			"module of loader 'app'; java.lang.String is in module java.base of loader 'bootstrap')"},
+		{"[I", "[LCat;", "class [I cannot be cast to class [LCat; ([I is in module java.base of loader " +
+			"'bootstrap'; [LCat; is in unnamed module of loader 'app')"},
+	}
+	for _, test := range tests {
+		if msg := classCastMessage(test.objClass, test.castClass); msg != test.expected {
+			t.Errorf("Expected CCE message %q, got: %q", test.expected, msg)
+		}
+	}
+}
+
+// runs the code, in which the instruction at offset 2 throws an exception of the named
+// class, which is caught by the handler at offset 4. Returns the caught exception.
+func runCaughtException(t *testing.T, code []byte, excClass string, stack ...interface{}) *object.Object {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+
+	c := newMessageTestCP()
+	f := frames.CreateFrame(4)
+	f.Ftype = 'J'
+	f.CP = &c.cp
+	f.ClName = "Test"
+	f.MethName = "test"
+	f.Meth = code
+	f.Locals = []interface{}{nil}
+	f.ExcTable = append(f.ExcTable, classloader.CodeException{StartPc: 0, EndPc: 4, HandlerPc: 4,
+		CatchType: c.classRef(excClass)})
+	for _, item := range stack {
+		push(f, item)
+	}
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("Expected the %s to be caught, got error: %s", excClass, err.Error())
+	}
+
+	exc, ok := f.Locals[0].(*object.Object)
+	if !ok || exc == nil {
+		t.Fatalf("Expected the handler to store the %s", excClass)
+	}
+	if *exc.Klass != excClass {
+		t.Errorf("Expected a %s, got: %s", excClass, *exc.Klass)
+	}
+	return exc
+}
+
+// LASTORE: a store to an array of another type throws an ArrayStoreException that
+// names the type of the value
+func TestLastoreArrayStoreExceptionCaught(t *testing.T) {
+	code := []byte{NOP, NOP, LASTORE, RETURN, ASTORE_0, RETURN}
+	exc := runCaughtException(t, code, "java/lang/ArrayStoreException",
+		object.Make1DimArray(object.FLOAT, 2), int64(0), int64(7), int64(7))
+	if msg := getThrowableMessage(exc); msg != "long" {
+		t.Errorf("LASTORE: Expected the message \"long\", got: %q", msg)
+	}
+}
+
+// CHECKCAST and INSTANCEOF: a class that can't be loaded throws a NoClassDefFoundError
+// that the method can catch
+func TestTypeCheckNoClassDefFoundErrorCaught(t *testing.T) {
+	for _, opcode := range []byte{CHECKCAST, INSTANCEOF} {
+		setupAnimalClasses()
+
+		dogName := "Dog"
+		dog := object.MakeEmptyObject()
+		dog.Klass = &dogName
+
+		c := newMessageTestCP()
+		missingRef := c.classRef("com/example/Missing")
+		f := frames.CreateFrame(4)
+		f.Ftype = 'J'
+		f.CP = &c.cp
+		f.Meth = []byte{NOP, NOP, opcode, 0, byte(missingRef), RETURN, ASTORE_0, RETURN}
+		f.Locals = []interface{}{nil}
+		f.ExcTable = append(f.ExcTable, classloader.CodeException{StartPc: 0, EndPc: 5, HandlerPc: 6,
+			CatchType: c.classRef("java/lang/NoClassDefFoundError")})
+		push(f, dog)
+		fs := frames.CreateFrameStack()
+		fs.PushFront(f)
+		if err := runFrame(fs); err != nil {
+			t.Fatalf("%s: Expected the NoClassDefFoundError to be caught, got error: %s",
+				BytecodeNames[opcode], err.Error())
+		}
+
+		exc, ok := f.Locals[0].(*object.Object)
+		if !ok || exc == nil || *exc.Klass != "java/lang/NoClassDefFoundError" ||
+			getThrowableMessage(exc) != "com/example/Missing" {
+			t.Errorf("%s: Expected a NoClassDefFoundError for com/example/Missing", BytecodeNames[opcode])
+		}
+	}
+}
+
+// IDIV: a division by zero throws an ArithmeticException that the method can catch
+func TestIdivArithmeticExceptionCaught(t *testing.T) {
+	code := []byte{ICONST_1, ICONST_0, IDIV, RETURN, ASTORE_0, RETURN}
+	exc := runCaughtException(t, code, "java/lang/ArithmeticException")
+	if msg := getThrowableMessage(exc); msg != "/ by zero" {
+		t.Errorf("IDIV: Expected the message \"/ by zero\", got: %q", msg)
+	}
+}
+
+// IALOAD: a negative index throws an ArrayIndexOutOfBoundsException
+func TestIaloadNegativeIndexCaught(t *testing.T) {
+	code := []byte{NOP, ICONST_M1, IALOAD, RETURN, ASTORE_0, RETURN}
+	exc := runCaughtException(t, code, "java/lang/ArrayIndexOutOfBoundsException",
+		object.Make1DimArray(object.INT, 3))
+	if msg := getThrowableMessage(exc); msg != "Index -1 out of bounds for length 3" {
+		t.Errorf("IALOAD: Expected the message \"Index -1 out of bounds for length 3\", got: %q", msg)
+	}
+}
+
+// ARRAYLENGTH: a null array reference throws a NullPointerException
+func TestArraylengthNullPointerExceptionCaught(t *testing.T) {
+	code := []byte{NOP, ACONST_NULL, ARRAYLENGTH, RETURN, ASTORE_0, RETURN}
+	exc := runCaughtException(t, code, "java/lang/NullPointerException")
+	if msg := getThrowableMessage(exc); msg != `Cannot read the array length because "null" is null` {
+		t.Errorf("ARRAYLENGTH: Got unexpected message: %q", msg)
+	}
+}
+
+// NEWARRAY: a negative size throws a NegativeArraySizeException whose message is the size
+func TestNewarrayNegativeSizeCaught(t *testing.T) {
+	code := []byte{ICONST_M1, NEWARRAY, object.T_INT, ASTORE_0, RETURN}
+	globals.InitGlobals("test")
+	log.Init()
+
+	c := newMessageTestCP()
+	f := frames.CreateFrame(4)
+	f.Ftype = 'J'
+	f.CP = &c.cp
+	f.Meth = code
+	f.Locals = []interface{}{nil}
+	f.ExcTable = append(f.ExcTable, classloader.CodeException{StartPc: 0, EndPc: 3, HandlerPc: 3,
+		CatchType: c.classRef("java/lang/NegativeArraySizeException")})
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("NEWARRAY: Expected the exception to be caught, got error: %s", err.Error())
+	}
+	exc, ok := f.Locals[0].(*object.Object)
+	if !ok || exc == nil || getThrowableMessage(exc) != "-1" {
+		t.Errorf("NEWARRAY: Expected a NegativeArraySizeException with the message -1")
+	}
+}
+
+// CHECKCAST: a cast to a superclass succeeds, while a cast to an unrelated class throws
+// a ClassCastException
+func TestCheckcastClassCastExceptionCaught(t *testing.T) {
+	setupAnimalClasses()
+
+	dogName := "Dog"
+	dog := object.MakeEmptyObject()
+	dog.Klass = &dogName
+
+	// (Animal) dog
+	c := newMessageTestCP()
+	f := frames.CreateFrame(4)
+	f.Ftype = 'J'
+	f.CP = &c.cp
+	f.Meth = []byte{CHECKCAST, 0, byte(c.classRef("Animal"))}
+	push(f, dog)
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	if err := runFrame(fs); err != nil || f.TOS != 0 || peek(f) != dog {
+		t.Errorf("CHECKCAST: Expected the cast of a Dog to Animal to succeed")
+	}
+
+	// (Cat) dog
+	c = newMessageTestCP()
+	catRef := c.classRef("Cat")
+	code := []byte{NOP, NOP, CHECKCAST, 0, byte(catRef), ASTORE_0, RETURN}
+	f = frames.CreateFrame(4)
+	f.Ftype = 'J'
+	f.CP = &c.cp
+	f.Meth = code
+	f.Locals = []interface{}{nil}
+	f.ExcTable = append(f.ExcTable, classloader.CodeException{StartPc: 0, EndPc: 5, HandlerPc: 5,
+		CatchType: c.classRef("java/lang/ClassCastException")})
+	push(f, dog)
+	fs = frames.CreateFrameStack()
+	fs.PushFront(f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("CHECKCAST: Expected the ClassCastException to be caught, got error: %s", err.Error())
+	}
+
+	exc, ok := f.Locals[0].(*object.Object)
+	expected := "class Dog cannot be cast to class Cat (Dog and Cat are in unnamed module of loader 'app')"
+	if !ok || exc == nil || getThrowableMessage(exc) != expected {
+		t.Errorf("CHECKCAST: Expected a ClassCastException with the message %q", expected)
+	}
+}
@@ -21,10 +21,11 @@ import (
 // as an array of interface{}, which can be nil if there are no arguments.
 // Any return value from the method is returned to run() as an interface{}
 // (which is nil in the case of a void function), where it is placed
-// by run() on the operand stack of the calling function.
+// by run() on the operand stack of the calling function. If the go function
+// throws a Java exception, it's returned as the error, a *classloader.GErrBlk.
 func runGframe(fr *frames.Frame) (interface{}, int, error) {
 	// get the go method from the MTable
-	me := classloader.MTable[fr.ClName+"."+fr.MethName]
+	me := classloader.MTableFetch(fr.ClName + "." + fr.MethName)
 	if me.Meth == nil {
 		return nil, 0, errors.New("runGframe: go method not found: " +
 			fr.ClName + "." + fr.MethName)
@@ -36,8 +37,17 @@ func runGframe(fr *frames.Frame) (interface{}, int, error) {
 		*params = append(*params, v)
 	}
 
+	// functions such as Thread.sleep() need to know which thread called them
+	gme := me.Meth.(classloader.GmEntry)
+	if gme.NeedsThread {
+		*params = append(*params, fr.Thread)
+	}
+
 	// call the function passing a pointer to the slice of arguments
-	ret := me.Meth.(classloader.GmEntry).Fu(*params)
+	ret := gme.Fu(*params)
+	if errBlk, ok := ret.(*classloader.GErrBlk); ok {
+		return nil, 0, errBlk
+	}
 
 	// how many slots does the return value consume on the op stack?
 	// the last char in the method name indicates the data type of the return
@@ -61,7 +71,11 @@ func runGframe(fr *frames.Frame) (interface{}, int, error) {
 // its stack, pushes the frame onto the head of the frame stack and then calls run() to
 // execute it. This eventually calls runGFrame(), which handles any return value. After
 // the function is run, this method pops the frame off the frame stack and returns.
-func runGmethod(mt classloader.MTentry, fs *list.List, className, methodName, methodType string) (*frames.Frame, error) {
+//
+// If the go function throws a Java exception, the exception is thrown in the calling
+// method, and the frame of the handler that catches it is returned along with true.
+// In that case, execution continues at the handler's PC.
+func runGmethod(mt classloader.MTentry, fs *list.List, className, methodName, methodType string) (*frames.Frame, bool, error) {
 	f := fs.Front().Value.(*frames.Frame)
 
 	// create a frame (gf for 'go frame') for this function
@@ -96,14 +110,24 @@ func runGmethod(mt classloader.MTentry, fs *list.List, className, methodName, me
 
 	// then run the frame, which will call run(), which will eventually call runGFrame()
 	err := runFrame(fs)
+	var errBlk *classloader.GErrBlk
+	if errors.As(err, &errBlk) {
+		fs.Remove(fs.Front()) // the exception is thrown by the caller of the go function
+		if errBlk.Exception != nil {
+			handlerFrame, err := throwException(fs, errBlk.Exception)
+			return handlerFrame, true, err
+		}
+		handlerFrame, err := throwNewException(fs, errBlk.ExceptionType, errBlk.ErrMsg)
+		return handlerFrame, true, err
+	}
 	if err != nil {
 		_ = log.Log("Error: "+err.Error(), log.SEVERE)
-		return nil, err
+		return nil, false, err
 	}
 
 	// now that the go function is done, pop the frame off the stack and
 	// point the previous frame as the current frame
 	fs.Remove(fs.Front())                // pop the frame off
 	f = fs.Front().Value.(*frames.Frame) // point f the head again
-	return f, nil
+	return f, false, nil
 }
@@ -13,95 +13,222 @@ import (
 	"jacobin/classloader"
 	"jacobin/frames"
 	"jacobin/log"
+	"jacobin/object"
+	"jacobin/thread"
 	"jacobin/types"
+	"sync"
 )
 
 // Initialization blocks are code blocks that for all intents are methods. They're gathered up by the
-// Java compiler into a method called <clinit>, which must be run at class instantiation--that is,
-// before any constructor. Because that code might well call other methods, it will need to be run
-// just like a regular method with stack frames and depending on the interpreter in run.go
-// In addition, we have to make sure that the initialization blocks of superclasses have been
-// previously executed.
-func runInitializationBlock(k *classloader.Klass, superClasses []string, fs *list.List) error {
-	if superClasses == nil || len(superClasses) == 0 {
-		// show we're running <clinit>. This prevents circularity errors.
-		k.Data.ClInit = types.ClInitInProgress
-
-		// if no superclasses were previously looked up
-		// get list of the superclasses up to but not including java.lang.Object
-		var superclasses []string
-
-		// put the present class at the bottom of the list of superclasses,
-		// because we'll need to run its clinit() code, if any
-		superclasses = append(superclasses, k.Data.Name)
-
-		superclass := k.Data.Superclass
-		for {
-			if superclass == "java/lang/Object" {
-				break
-			}
+// Java compiler into a method called <clinit>, which must be run when the class is initialized.
+// Because that code might well call other methods, it will need to be run just like a regular
+// method with stack frames and depending on the interpreter in run.go
+//
+// A class is initialized on its first active use: when an instance of it is created (NEW),
+// when one of its static fields is read or written (GETSTATIC, PUTSTATIC), or when one of its
+// static methods is invoked (INVOKESTATIC). Initialization follows the procedure in JVMS 5.5:
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-5.html#jvms-5.5
+// Its superclass is initialized first. The state of initialization is kept in the class's
+// ClInit byte. A class that's being initialized by one thread makes other threads that want
+// to use it wait, while the initializing thread itself may use it freely. If the initializer
+// throws an exception, the class is marked as erroneous and can't be used thereafter.
+
+// classInitLock is the initialization lock of JVMS 5.5. It guards the ClInit state of every
+// class and classInitThreads, the IDs of the threads initializing the classes in progress.
+// classInitDone is signaled whenever the initialization of a class ends.
+var classInitLock sync.Mutex
+var classInitDone = sync.NewCond(&classInitLock)
+var classInitThreads = make(map[string]int)
+
+// Class.forName() is implemented in the classloader package, but it initializes the class,
+// which requires the interpreter, so it calls initializeClassOnThread() here.
+func init() {
+	classloader.InitializeClass = initializeClassOnThread
+}
+
+// initializerException is the error returned by throwException() when an exception is
+// not caught by a <clinit> method. The exception is then rethrown by initializeClass()
+// to the code that triggered the initialization, rather than being propagated further.
+type initializerException struct {
+	exception *object.Object
+}
 
-			err := loadThisClass(superclass) // load the superclass
-			if err != nil {                  // error message will have been displayed
-				return err
+func (e *initializerException) Error() string {
+	return "exception in initializer: " + javaClassName(*e.exception.Klass)
+}
+
+// initializeClass initializes the named class, if it has not been initialized, loading it
+// first if need be. It's called on the thread whose frame stack is fs. If initialization
+// fails, it returns the exception to throw: an ExceptionInInitializerError, the Error that
+// the initializer threw, or a NoClassDefFoundError if the class failed to initialize earlier.
+// An error is returned only if the JVM itself fails.
+func initializeClass(className string, fs *list.List) (*object.Object, error) {
+	if err := loadThisClass(className); err != nil { // error message will have been displayed
+		return nil, err
+	}
+	k := classloader.MethAreaFetch(className)
+	if k == nil {
+		errMsg := "initializeClass: class is nil after loading, class: " + className
+		_ = log.Log(errMsg, log.SEVERE)
+		return nil, errors.New(errMsg)
+	}
+	if k.Data == nil { // a placeholder for a class, which has nothing to initialize
+		return nil, nil
+	}
+	threadID := currentThreadID(fs)
+
+	classInitLock.Lock()
+	for k.Data.ClInit == types.ClInitInProgress && classInitThreads[className] != threadID {
+		classInitDone.Wait() // another thread is initializing the class
+	}
+	switch k.Data.ClInit {
+	case types.ClInitInProgress, types.ClInitRun: // a recursive request, or it's done
+		classInitLock.Unlock()
+		return nil, nil
+	case types.ClInitError:
+		classInitLock.Unlock()
+		return createThrowable("java/lang/NoClassDefFoundError",
+			"Could not initialize class "+javaClassName(className)), nil
+	}
+	k.Data.ClInit = types.ClInitInProgress
+	classInitThreads[className] = threadID
+	classInitLock.Unlock()
+
+	// the static fields get their default values, or those of their ConstantValue attributes
+	for _, fld := range k.Data.Fields {
+		if fld.IsStatic {
+			if _, err := createField(fld, k, className); err != nil {
+				endClassInitialization(k, types.ClInitError)
+				return nil, err
 			}
+		}
+	}
 
-			// load only superclasses that have a clInit block that has not been run
-			loadedSuperclass := classloader.MethAreaFetch(superclass)
-			if loadedSuperclass.Data.ClInit == types.ClInitNotRun {
-				superclasses = append(superclasses, superclass)
+	// a class's superclass and the superinterfaces that declare default methods are
+	// initialized before it. An exception thrown in doing so is thrown for this class too.
+	if !k.Data.Access.ClassIsInterface {
+		for _, super := range classesToInitializeFirst(k) {
+			exc, err := initializeClass(super, fs)
+			if err != nil || exc != nil {
+				endClassInitialization(k, types.ClInitError)
+				return exc, err
 			}
+		}
+	}
 
-			// now loop to see whether this superclass has a superclass
-			superclass = loadedSuperclass.Data.Superclass
+	var exc *object.Object
+	var err error
+	if me, fetchErr := classloader.FetchMethodAndCP(className, "<clinit>", "()V"); fetchErr == nil {
+		switch me.MType {
+		case 'J': // it's a Java initializer (the most common case)
+			exc, err = runJavaInitializer(me.Meth, k, fs)
+		case 'G': // it's a golang implementation of the initializer
+			exc = runNativeInitializer(me)
 		}
-		superClasses = superclasses
-	}
-
-	// now execute any encountered <clinit> code in this class
-	for i := len(superClasses) - 1; i >= 0; i-- {
-		className := superClasses[i]
-		me, err := classloader.FetchMethodAndCP(className, "<clinit>", "()V")
-		if err == nil {
-			switch me.MType {
-			case 'J': // it's a Java initializer (the most common case)
-				err = runJavaInitializer(me.Meth, k, fs)
-			case 'G': // it's a golang implementation of the initializer
-				err = runNativeInitializer(me, k, fs)
-			}
-			if err != nil {
-				return err
+	} // if there's no <clinit> method, there's nothing more to do
+
+	if err != nil || exc != nil {
+		endClassInitialization(k, types.ClInitError)
+		if exc != nil && !isClassOrSubclassOf(*exc.Klass, "java/lang/Error") {
+			exc = createExceptionInInitializerError(exc)
+		}
+		return exc, err
+	}
+	endClassInitialization(k, types.ClInitRun)
+	return nil, nil
+}
+
+// endClassInitialization sets the final initialization state of the class, which is
+// either initialized or erroneous, and wakes the threads waiting for it
+func endClassInitialization(k *classloader.Klass, state byte) {
+	classInitLock.Lock()
+	k.Data.ClInit = state
+	delete(classInitThreads, k.Data.Name)
+	classInitDone.Broadcast()
+	classInitLock.Unlock()
+}
+
+// classesToInitializeFirst returns the superclass of the class k, if it's not java/lang/Object,
+// followed by the superinterfaces that declare methods that are neither abstract nor static,
+// which JVMS 5.5 requires to be initialized before the class.
+func classesToInitializeFirst(k *classloader.Klass) []string {
+	var classes []string
+	if k.Data.Superclass != "" && k.Data.Superclass != "java/lang/Object" {
+		classes = append(classes, k.Data.Superclass)
+	}
+
+	for _, utf8Index := range k.Data.Interfaces {
+		iface := k.Data.CP.Utf8Refs[utf8Index]
+		if loadThisClass(iface) != nil {
+			continue
+		}
+		ik := classloader.MethAreaFetch(iface)
+		if ik == nil || ik.Data == nil {
+			continue
+		}
+		for _, m := range ik.Data.MethodTable {
+			if m.AccessFlags&(classloader.AccAbstract|classloader.AccStatic) == 0 {
+				classes = append(classes, iface)
+				break
 			}
-		} // if no <clinit> method, then skip that superclass
+		}
 	}
-	return nil
+	return classes
+}
+
+// createExceptionInInitializerError creates the ExceptionInInitializerError that's thrown
+// when an initializer throws an exception that is not an Error. That exception is its cause.
+func createExceptionInInitializerError(cause *object.Object) *object.Object {
+	excObj := createThrowable("java/lang/ExceptionInInitializerError", "")
+	classloader.SetObjectField(excObj, "cause", object.Field{Ftype: "Ljava/lang/Throwable;", Fvalue: cause})
+	return excObj
+}
+
+// initializeClassOnThread initializes the named class on the thread with the given ID, as
+// Class.forName() does. The initializer runs on the frame stack of that thread. It returns
+// the exception to throw if initialization fails, as initializeClass() does.
+func initializeClassOnThread(className string, threadID int) (*object.Object, error) {
+	fs := frames.CreateFrameStack()
+	if t := thread.FindThread(threadID); t != nil && t.Stack != nil {
+		fs = t.Stack
+	}
+	return initializeClass(className, fs)
+}
+
+// currentThreadID returns the ID of the thread whose frame stack is fs. Before the first
+// frame is pushed, it can only be the main thread.
+func currentThreadID(fs *list.List) int {
+	if fs == nil || fs.Len() == 0 {
+		return MainThread.ID
+	}
+	return fs.Front().Value.(*frames.Frame).Thread
 }
 
 // Run the <clinit>() initializer code as a Java method. This effectively duplicates
-// the code in run.go that creates a new frame and runs the method. Note that this
-// code creates its own frame stack, which is distinct from the applications frame
-// stack. The reason is that this is computing that's in most ways apart from the
-// bytecode of the app. (This design might be revised at a later point and the two
-// frame stacks combined into one.)
-func runJavaInitializer(m classloader.MData, k *classloader.Klass, fs *list.List) error {
+// the code in run.go that creates a new frame and runs the method. The frame is pushed
+// onto the frame stack of the thread that triggered the initialization. If <clinit>()
+// throws an exception that it doesn't catch, that exception is returned.
+func runJavaInitializer(m classloader.MData, k *classloader.Klass, fs *list.List) (*object.Object, error) {
 	meth := m.(classloader.JmEntry)
 	f := frames.CreateFrame(meth.MaxStack + 2) // create a new frame (adding 2 b/c of unexplained bytecode needs)
 	f.MethName = "<clinit>"
+	f.MethType = "()V"
 	f.ClName = k.Data.Name
 	f.CP = meth.Cp                        // add its pointer to the class CP
 	f.Meth = append(f.Meth, meth.Code...) // copy the bytecodes over
+	f.ExcTable = meth.Exceptions          // the exception handlers, if any
+	f.LineNums = meth.LineNumbers         // the source line numbers, if any
+	f.Thread = currentThreadID(fs)        // <clinit>() runs on the thread that triggered it
 
 	// allocate the local variables
 	for j := 0; j < meth.MaxLocals; j++ {
 		f.Locals = append(f.Locals, 0)
 	}
 
-	k.Data.ClInit = types.ClInitInProgress
-
 	if frames.PushFrame(fs, f) != nil {
 		errMsg := "memory exception allocating frame in runJavaInitializer()"
 		_ = log.Log(errMsg, log.SEVERE)
-		return errors.New(errMsg)
+		return nil, errors.New(errMsg)
 	}
 
 	if MainThread.Trace {
@@ -111,17 +238,25 @@ func runJavaInitializer(m classloader.MData, k *classloader.Klass, fs *list.List
 	}
 
 	err := runFrame(fs)
-	k.Data.ClInit = types.ClInitRun // flag showing we've run this class's <clinit>
+	var initErr *initializerException
+	if errors.As(err, &initErr) {
+		frames.PopFrame(fs) // throwException() leaves the <clinit> frame on top
+		return initErr.exception, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	frames.PopFrame(fs)
-	return nil
+	return nil, nil
 }
 
-func runNativeInitializer(mt classloader.MTentry, k *classloader.Klass, fs *list.List) error {
-	runGmethod(mt, fs, k.Data.Name, "<clinit>", "()V")
-	k.Data.ClInit = types.ClInitRun // flag showing we've run this class's <clinit>
+// Run a golang implementation of <clinit>(). If it throws an exception, that exception
+// is returned.
+func runNativeInitializer(mt classloader.MTentry) *object.Object {
+	ret := mt.Meth.(classloader.GmEntry).Fu(nil)
+	if errBlk, ok := ret.(*classloader.GErrBlk); ok {
+		return createThrowable(errBlk.ExceptionType, errBlk.ErrMsg)
+	}
 	return nil
 }
@@ -0,0 +1,382 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/object"
+	"jacobin/types"
+	"os"
+	"strings"
+	"testing"
+)
+
+// initTestClass describes a class used in the tests of class initialization
+type initTestClass struct {
+	name       string
+	superclass string
+	statics    []classloader.Field // their Name and Desc are set from names and descs
+	names      []string
+	descs      []string
+	clinit     func(c *messageTestCP) []byte // the code of <clinit>, if any
+}
+
+// setupInitTest puts the classes in a new method area, along with the JDK classes that
+// the tests' exceptions need, and removes the statics left by previous tests
+func setupInitTest(classes ...initTestClass) {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+
+	jdkClasses := []struct{ name, superclass string }{
+		{"java/lang/Object", ""},
+		{"java/lang/Throwable", "java/lang/Object"},
+		{"java/lang/Exception", "java/lang/Throwable"},
+		{"java/lang/RuntimeException", "java/lang/Exception"},
+		{"java/lang/ArithmeticException", "java/lang/RuntimeException"},
+		{"java/lang/Error", "java/lang/Throwable"},
+		{"java/lang/OutOfMemoryError", "java/lang/Error"},
+	}
+	for _, jdk := range jdkClasses {
+		classloader.MethAreaInsert(jdk.name, &classloader.Klass{Status: 'X', Loader: "bootstrap",
+			Data: &classloader.ClData{Name: jdk.name, Superclass: jdk.superclass,
+				MethodTable: map[string]*classloader.Method{}}})
+	}
+
+	for _, class := range classes {
+		c := newMessageTestCP()
+		data := classloader.ClData{Name: class.name, Superclass: class.superclass,
+			MethodTable: map[string]*classloader.Method{}, ClInit: types.ClInitNotRun}
+		for i, fld := range class.statics {
+			c.utf8(class.names[i])
+			fld.Name = uint16(len(c.cp.Utf8Refs) - 1)
+			c.utf8(class.descs[i])
+			fld.Desc = uint16(len(c.cp.Utf8Refs) - 1)
+			fld.IsStatic = true
+			data.Fields = append(data.Fields, fld)
+			delete(classloader.Statics, class.name+"."+class.names[i])
+		}
+		if class.clinit != nil {
+			code := class.clinit(c)
+			data.MethodTable["<clinit>()V"] = &classloader.Method{AccessFlags: classloader.AccStatic,
+				CodeAttr: classloader.CodeAttrib{MaxStack: 4, MaxLocals: 1, Code: code}}
+		}
+		data.CP = c.cp
+		classloader.MethAreaInsert(class.name, &classloader.Klass{Status: 'X', Loader: "app", Data: &data})
+	}
+}
+
+// counterClinit returns a <clinit> that appends the digit to InitCounter.n, so that
+// InitCounter.n records the order in which the initializers ran
+func counterClinit(digit byte) func(c *messageTestCP) []byte {
+	return func(c *messageTestCP) []byte {
+		n := c.fieldRef("InitCounter", "n", "I")
+		return []byte{GETSTATIC, 0, byte(n), BIPUSH, 10, IMUL, BIPUSH, digit, IADD,
+			PUTSTATIC, 0, byte(n), RETURN}
+	}
+}
+
+var initCounter = initTestClass{name: "InitCounter", superclass: "java/lang/Object",
+	statics: []classloader.Field{{}}, names: []string{"n"}, descs: []string{"I"}}
+
+var initBase = initTestClass{name: "InitBase", superclass: "java/lang/Object", clinit: counterClinit(1)}
+
+var initDerived = initTestClass{name: "InitDerived", superclass: "InitBase",
+	statics: []classloader.Field{{ConstValue: 42}, {ConstValue: "hello"}, {}},
+	names:   []string{"answer", "greeting", "count"},
+	descs:   []string{"I", "Ljava/lang/String;", "J"},
+	clinit:  counterClinit(2)}
+
+func classInitState(className string) byte {
+	return classloader.MethAreaFetch(className).Data.ClInit
+}
+
+// the superclass is initialized before the class, and each is initialized only once
+func TestInitializeClassSuperclassFirst(t *testing.T) {
+	setupInitTest(initCounter, initBase, initDerived)
+	fs := frames.CreateFrameStack()
+
+	for i := 0; i < 2; i++ {
+		exc, err := initializeClass("InitDerived", fs)
+		if err != nil || exc != nil {
+			t.Fatalf("Expected InitDerived to be initialized, got error: %v, exception: %v", err, exc)
+		}
+	}
+
+	n, _ := classloader.FetchStatic("InitCounter.n")
+	if n.Value != int64(12) {
+		t.Errorf("Expected InitBase.<clinit> to run once before InitDerived.<clinit>, got InitCounter.n = %v",
+			n.Value)
+	}
+	for _, className := range []string{"InitBase", "InitDerived"} {
+		if classInitState(className) != types.ClInitRun {
+			t.Errorf("Expected %s to be marked as initialized, got state %d", className, classInitState(className))
+		}
+	}
+	if fs.Len() != 0 {
+		t.Errorf("Expected the <clinit> frames to be popped, got %d frames", fs.Len())
+	}
+}
+
+// static fields get the values of their ConstantValue attributes, or else the default values
+func TestInitializeClassConstantValues(t *testing.T) {
+	setupInitTest(initCounter, initBase, initDerived)
+	if exc, err := initializeClass("InitDerived", frames.CreateFrameStack()); err != nil || exc != nil {
+		t.Fatalf("Expected InitDerived to be initialized, got error: %v, exception: %v", err, exc)
+	}
+
+	answer, _ := classloader.FetchStatic("InitDerived.answer")
+	if answer.Value != int64(42) {
+		t.Errorf("Expected InitDerived.answer to be 42, got: %v", answer.Value)
+	}
+	greeting, _ := classloader.FetchStatic("InitDerived.greeting")
+	str, ok := greeting.Value.(*object.Object)
+	if !ok || object.GetGoStringFromJavaStringPtr(str) != "hello" {
+		t.Errorf("Expected InitDerived.greeting to be \"hello\", got: %v", greeting.Value)
+	}
+	count, _ := classloader.FetchStatic("InitDerived.count")
+	if count.Value != int64(0) {
+		t.Errorf("Expected InitDerived.count to be 0, got: %v", count.Value)
+	}
+}
+
+// a class whose initializer uses the class itself is not initialized again
+func TestInitializeClassRecursiveRequest(t *testing.T) {
+	initSelf := initTestClass{name: "InitSelf", superclass: "java/lang/Object",
+		statics: []classloader.Field{{}}, names: []string{"x"}, descs: []string{"I"},
+		clinit: func(c *messageTestCP) []byte {
+			x := c.fieldRef("InitSelf", "x", "I")
+			return []byte{GETSTATIC, 0, byte(x), ICONST_3, IADD, PUTSTATIC, 0, byte(x), RETURN}
+		}}
+	setupInitTest(initSelf)
+
+	if exc, err := initializeClass("InitSelf", frames.CreateFrameStack()); err != nil || exc != nil {
+		t.Fatalf("Expected InitSelf to be initialized, got error: %v, exception: %v", err, exc)
+	}
+	x, _ := classloader.FetchStatic("InitSelf.x")
+	if x.Value != int64(3) {
+		t.Errorf("Expected InitSelf.x to be 3, got: %v", x.Value)
+	}
+	if classInitState("InitSelf") != types.ClInitRun {
+		t.Errorf("Expected InitSelf to be marked as initialized, got state %d", classInitState("InitSelf"))
+	}
+}
+
+// an exception thrown by an initializer is wrapped in an ExceptionInInitializerError,
+// and later uses of the class throw a NoClassDefFoundError
+func TestInitializeClassThrowsException(t *testing.T) {
+	initBad := initTestClass{name: "InitBad", superclass: "java/lang/Object",
+		clinit: func(c *messageTestCP) []byte { return []byte{ICONST_1, ICONST_0, IDIV, RETURN} }}
+	setupInitTest(initBad)
+	fs := frames.CreateFrameStack()
+
+	exc, err := initializeClass("InitBad", fs)
+	if err != nil {
+		t.Fatalf("Expected an exception, got error: %s", err.Error())
+	}
+	if exc == nil || *exc.Klass != "java/lang/ExceptionInInitializerError" {
+		t.Fatalf("Expected an ExceptionInInitializerError, got: %v", exc)
+	}
+	cause := getThrowableCause(exc)
+	if cause == nil || *cause.Klass != "java/lang/ArithmeticException" {
+		t.Errorf("Expected the cause to be the ArithmeticException, got: %v", cause)
+	}
+	if classInitState("InitBad") != types.ClInitError {
+		t.Errorf("Expected InitBad to be marked as erroneous, got state %d", classInitState("InitBad"))
+	}
+	if fs.Len() != 0 {
+		t.Errorf("Expected the <clinit> frame to be popped, got %d frames", fs.Len())
+	}
+
+	exc, err = initializeClass("InitBad", fs)
+	if err != nil || exc == nil || *exc.Klass != "java/lang/NoClassDefFoundError" {
+		t.Fatalf("Expected a NoClassDefFoundError, got error: %v, exception: %v", err, exc)
+	}
+	if msg := getThrowableMessage(exc); msg != "Could not initialize class InitBad" {
+		t.Errorf("Expected the message \"Could not initialize class InitBad\", got: %q", msg)
+	}
+}
+
+// an Error thrown by an initializer is thrown as is
+func TestInitializeClassThrowsError(t *testing.T) {
+	setupInitTest(initTestClass{name: "InitNative", superclass: "java/lang/Object"})
+	classloader.MTableInsert("InitNative.<clinit>()V", classloader.MTentry{MType: 'G',
+		Meth: classloader.GmEntry{Fu: func([]interface{}) interface{} {
+			return &classloader.GErrBlk{ExceptionType: "java/lang/OutOfMemoryError", ErrMsg: "no room"}
+		}}})
+	defer delete(classloader.MTable, "InitNative.<clinit>()V")
+
+	exc, err := initializeClass("InitNative", frames.CreateFrameStack())
+	if err != nil || exc == nil || *exc.Klass != "java/lang/OutOfMemoryError" {
+		t.Fatalf("Expected an OutOfMemoryError, got error: %v, exception: %v", err, exc)
+	}
+	if classInitState("InitNative") != types.ClInitError {
+		t.Errorf("Expected InitNative to be marked as erroneous, got state %d", classInitState("InitNative"))
+	}
+}
+
+// GETSTATIC initializes the class of the field before reading it
+func TestGetstaticInitializesClass(t *testing.T) {
+	setupInitTest(initCounter, initBase, initDerived)
+	c := newMessageTestCP()
+	answer := c.fieldRef("InitDerived", "answer", "I")
+
+	f := frames.CreateFrame(4)
+	f.CP = &c.cp
+	f.Ftype = 'J'
+	f.ClName = "Test"
+	f.Meth = []byte{GETSTATIC, 0, byte(answer)}
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("GETSTATIC: Got unexpected error: %s", err.Error())
+	}
+
+	if value := pop(f); value != int64(42) {
+		t.Errorf("GETSTATIC: Expected 42, got: %v", value)
+	}
+	if classInitState("InitDerived") != types.ClInitRun {
+		t.Errorf("GETSTATIC: Expected InitDerived to be initialized, got state %d", classInitState("InitDerived"))
+	}
+}
+
+// a static field referred to through a subclass is the field of the class that declares
+// it, and only the declaring class is initialized
+func TestPutstaticThroughSubclass(t *testing.T) {
+	initLeaf := initTestClass{name: "InitLeaf", superclass: "InitDerived", clinit: counterClinit(3)}
+	setupInitTest(initCounter, initBase, initDerived, initLeaf)
+	c := newMessageTestCP()
+	leafCount := c.fieldRef("InitLeaf", "count", "J")
+	derivedCount := c.fieldRef("InitDerived", "count", "J")
+
+	f := frames.CreateFrame(4)
+	f.CP = &c.cp
+	f.Ftype = 'J'
+	f.ClName = "Test"
+	f.Meth = []byte{LCONST_1, PUTSTATIC, 0, byte(leafCount), GETSTATIC, 0, byte(derivedCount)}
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("PUTSTATIC: Got unexpected error: %s", err.Error())
+	}
+
+	if value := pop(f); value != int64(1) {
+		t.Errorf("GETSTATIC: Expected InitDerived.count to be 1, got: %v", value)
+	}
+	if _, ok := classloader.FetchStatic("InitLeaf.count"); ok {
+		t.Errorf("PUTSTATIC: Expected no static InitLeaf.count, as InitDerived declares count")
+	}
+	if classInitState("InitLeaf") != types.ClInitNotRun {
+		t.Errorf("PUTSTATIC: Expected InitLeaf not to be initialized, got state %d", classInitState("InitLeaf"))
+	}
+}
+
+// a static field reference is resolved to its declaring class the first time GETSTATIC
+// executes. Later executions use that class without resolving the reference again.
+func TestGetstaticResolvesOnce(t *testing.T) {
+	initLeaf := initTestClass{name: "InitLeaf", superclass: "InitDerived", clinit: counterClinit(3)}
+	setupInitTest(initCounter, initBase, initDerived, initLeaf)
+	c := newMessageTestCP()
+	leafAnswer := c.fieldRef("InitLeaf", "answer", "I")
+
+	f := frames.CreateFrame(4)
+	f.CP = &c.cp
+	f.Ftype = 'J'
+	f.ClName = "Test"
+	f.Meth = []byte{GETSTATIC, 0, byte(leafAnswer)}
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("GETSTATIC: Got unexpected error: %s", err.Error())
+	}
+	if value := pop(f); value != int64(42) {
+		t.Fatalf("GETSTATIC: Expected 42, got: %v", value)
+	}
+
+	// if the reference were resolved again, it would fail with a NoSuchFieldError
+	classloader.MethAreaFetch("InitDerived").Data.Fields = nil
+	f.PC = 0
+	fs.PushFront(f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("GETSTATIC: Got unexpected error: %s", err.Error())
+	}
+	if value := pop(f); value != int64(42) {
+		t.Errorf("GETSTATIC: Expected 42 from the resolved field, got: %v", value)
+	}
+}
+
+// NEW throws the exception of a failed initialization, which the method can catch
+func TestNewInitializerExceptionCaught(t *testing.T) {
+	initBad := initTestClass{name: "InitBad", superclass: "java/lang/Object",
+		clinit: func(c *messageTestCP) []byte { return []byte{ICONST_1, ICONST_0, IDIV, RETURN} }}
+	setupInitTest(initBad)
+	classloader.MethAreaInsert("java/lang/ExceptionInInitializerError", &classloader.Klass{Status: 'X',
+		Loader: "bootstrap", Data: &classloader.ClData{Name: "java/lang/ExceptionInInitializerError",
+			Superclass: "java/lang/Error"}})
+
+	c := newMessageTestCP()
+	bad := c.classRef("InitBad")
+	code := []byte{NEW, 0, byte(bad), RETURN, ASTORE_0, RETURN}
+
+	f := frames.CreateFrame(4)
+	f.Ftype = 'J'
+	f.CP = &c.cp
+	f.ClName = "Test"
+	f.MethName = "test"
+	f.Meth = code
+	f.Locals = []interface{}{nil}
+	f.ExcTable = append(f.ExcTable, classloader.CodeException{StartPc: 0, EndPc: 4, HandlerPc: 4,
+		CatchType: c.classRef("java/lang/Error")})
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("NEW: Expected the exception to be caught, got error: %s", err.Error())
+	}
+
+	exc, ok := f.Locals[0].(*object.Object)
+	if !ok || exc == nil || *exc.Klass != "java/lang/ExceptionInInitializerError" {
+		t.Errorf("NEW: Expected an ExceptionInInitializerError to be caught, got: %v", f.Locals[0])
+	}
+}
+
+// NEW stops with an error, rather than creating the object, if the class's initializer
+// fails for a reason other than a Java exception
+func TestNewInitializerError(t *testing.T) {
+	initBroken := initTestClass{name: "InitBroken", superclass: "java/lang/Object",
+		clinit: func(c *messageTestCP) []byte { return []byte{0xCB, RETURN} }} // 0xCB is not an opcode
+	setupInitTest(initBroken)
+
+	c := newMessageTestCP()
+	code := []byte{NEW, 0, byte(c.classRef("InitBroken")), RETURN}
+	f := frames.CreateFrame(4)
+	f.Ftype = 'J'
+	f.CP = &c.cp
+	f.ClName = "Test"
+	f.MethName = "test"
+	f.Meth = code
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	err := runFrame(fs)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if err == nil || !strings.Contains(err.Error(), "NEW: could not load class InitBroken") {
+		t.Errorf("NEW: Expected an error for the failed initializer, got: %v", err)
+	}
+	if f.TOS != -1 {
+		t.Errorf("NEW: Expected no object to be created, got TOS: %d", f.TOS)
+	}
+}
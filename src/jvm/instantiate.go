@@ -22,8 +22,10 @@ import (
 // instantiating an object is a two-part process (except for arrays, which are handled
 // by special bytecodes):
 //  1. the class needs to be loaded, so that its details and its methods are knowable
-//  2. the class fields (if static) and instance fields (if non-static) are allocated.
-//     Details for this second step appear in the loop that drives createField().
+//  2. the instance fields are allocated, as they're laid out by the class (see
+//     classloader/fieldLayout.go). Static fields are created when the class is initialized.
+//
+// The class is not initialized here. That's done by initializeClass(), which NEW calls first.
 func instantiateClass(classname string, frameStack *list.List) (*object.Object, error) {
 
 	if !strings.HasPrefix(classname, "[") { // do this only for classes, not arrays
@@ -56,117 +58,31 @@ func instantiateClass(classname string, frameStack *list.List) (*object.Object,
 		return nil, errors.New(errMsg)
 	}
 
-	// go up the chain of superclasses until we hit java/lang/Object
-	superclasses := []string{}
-	superclass := k.Data.Superclass
-	for {
-		// if the present class is Object, it has no superclass. If the present
-		// class's superclass is Object, we've reached the top of the superclass
-		// hierarchy. Otherwise, keep looping up the superclasses.
-		if classname == "java/lang/Object" || superclass == "java/lang/Object" {
-			break
-		}
-
-		err := loadThisClass(superclass) // load the superclass
-		if err != nil {                  // error message will have been displayed
-			return nil, err
-		} else {
-			superclasses = append(superclasses, superclass)
-		}
-
-		loadedSuperclass := classloader.MethAreaFetch(superclass)
-		// now loop to see whether this superclass has a superclass
-		superclass = loadedSuperclass.Data.Superclass
-	}
-
 	// the object's mark field contains the lower 32-bits of the object's
 	// address, which serves as the hash code for the object
 	uintp := uintptr(unsafe.Pointer(&obj))
 	obj.Mark.Hash = uint32(uintp)
 
-	// handle the fields. If the object has no superclass other than Object,
-	// the fields are in an array in the order they're declared in the CP.
-	// If the object has a non-Object superclass, then the superclasses' fields
-	// and the present object's field are stored in a map--indexed by the
-	// field name. Eventually, we might coalesce on a single approach for
-	// both kinds of objects.
-	if len(superclasses) == 0 && len(k.Data.Fields) == 0 {
-		goto runInitializer // check to see if any static initializers
+	// the object's fields are those of its class's field layout: the instance fields of
+	// its superclasses, starting with the topmost, and then its own, each with its default
+	// value. GETFIELD and PUTFIELD access them by their slot in the layout. Static fields
+	// are not in the object. They're in the Statics table.
+	if err := classloader.LayOutFields(&obj, classname); err != nil {
+		errMsg := "Error in class instantiation, cannot lay out the fields of class: " + classname
+		_ = log.Log(errMsg, log.SEVERE)
+		return nil, errors.New(errMsg)
 	}
 
-	if len(superclasses) == 0 {
-		for i := 0; i < len(k.Data.Fields); i++ {
-			f := k.Data.Fields[i]
-			desc := k.Data.CP.Utf8Refs[f.Desc]
-			name := k.Data.CP.Utf8Refs[f.Name]
-			if log.Level == log.FINE {
-				reciteField := fmt.Sprintf("Class: %s field[%d] name: %s, type: %s", k.Data.Name, i,
-					name, desc)
-				_ = log.Log(reciteField, log.FINE)
-			}
-
-			fieldToAdd, err := createField(f, k, classname)
-			if err != nil {
-				return nil, err
-			}
-			obj.Fields = append(obj.Fields, *fieldToAdd)
-		} // loop through the fields if any
-		obj.FieldTable = nil
-		goto runInitializer
-		// return &obj, nil
-	} // end of handling fields for objects w/ no superclasses
-
-	obj.FieldTable = make(map[string]object.Field)
-	// in the case of superclasses, we start at the topmost superclass
-	// and work our way down to the present class, adding fields to FieldTable.
-	// so we add the present class into position[0] and then loop through
-	// the slice of class names
-	superclasses = append([]string{classname}, superclasses...)
-	for j := len(superclasses) - 1; j >= 0; j-- {
-		superclassName := superclasses[j]
-		c := classloader.MethAreaFetch(superclassName)
-		if c == nil {
-			errMsg := fmt.Sprintf("Error in class instantiation, cannot find superclass: %s",
-				superclassName)
-			_ = log.Log(errMsg, log.SEVERE)
-			return nil, errors.New(errMsg)
-		}
-		for i := 0; i < len(c.Data.Fields); i++ {
-			f := c.Data.Fields[i]
-			desc := c.Data.CP.Utf8Refs[f.Desc]
-			name := c.Data.CP.Utf8Refs[f.Name]
-			if log.Level == log.FINE {
-				reciteField := fmt.Sprintf("Class: %s field[%d] name: %s, type: %s", k.Data.Name, i,
-					name, desc)
-				_ = log.Log(reciteField, log.FINE)
-			}
-
-			fieldToAdd, err := createField(f, c, classname)
-			if err != nil {
-				return nil, err
-			}
-
-			// add the field to the field table for this object
-			obj.FieldTable[name] = *fieldToAdd
-		} // end of handling fields for one  class or superclass
-	} // end of handling fields for classes with superclasses other than Object
-
-runInitializer:
-	// run intialization blocks
-	_, ok := k.Data.MethodTable["<clinit>()V"]
-	if ok && k.Data.ClInit == types.ClInitNotRun {
-		err := runInitializationBlock(k, superclasses, frameStack)
-		if err != nil {
-			errMsg := fmt.Sprintf("error encountered running %s.<clinit>()", classname)
-			_ = log.Log(errMsg, log.SEVERE)
-			return nil, err
+	if log.Level == log.FINE {
+		for i, fld := range obj.Fields {
+			reciteField := fmt.Sprintf("Class: %s field[%d] type: %s", k.Data.Name, i, fld.Ftype)
+			_ = log.Log(reciteField, log.FINE)
 		}
 	}
-
 	return &obj, nil
 }
 
-// creates a field for insertion into the object representation
+// creates a static field of a class when the class is initialized and adds it to the Statics table
 func createField(f classloader.Field, k *classloader.Klass, classname string) (*object.Field, error) {
 	desc := k.Data.CP.Utf8Refs[f.Desc]
 	name := k.Data.CP.Utf8Refs[f.Name]
@@ -199,37 +115,26 @@ func createField(f classloader.Field, k *classloader.Klass, classname string) (*
 		fieldToAdd.Ftype = "X" + presentType
 	}
 
-	// static fields can have ConstantValue attributes,
-	// which specify their initial value.
-	if len(f.Attributes) > 0 {
-		for j := 0; j < len(f.Attributes); j++ {
-			attr := k.Data.CP.Utf8Refs[int(f.Attributes[j].AttrName)]
-			if attr == "ConstantValue" && f.IsStatic { // only statics can have ConstantValue attribute
-				valueIndex := int(f.Attributes[j].AttrContent[0])*256 +
-					int(f.Attributes[j].AttrContent[1])
-				valueType := k.Data.CP.CpIndex[valueIndex].Type
-				valueSlot := k.Data.CP.CpIndex[valueIndex].Slot
-				switch valueType {
-				case classloader.IntConst:
-					fieldToAdd.Fvalue = int64(k.Data.CP.IntConsts[valueSlot])
-				case classloader.LongConst:
-					fieldToAdd.Fvalue = k.Data.CP.LongConsts[valueSlot]
-				case classloader.FloatConst:
-					fieldToAdd.Fvalue = float64(k.Data.CP.Floats[valueSlot])
-				case classloader.DoubleConst:
-					fieldToAdd.Fvalue = k.Data.CP.Doubles[valueSlot]
-				case classloader.StringConst:
-					str := k.Data.CP.Utf8Refs[valueSlot]
-					fieldToAdd.Fvalue = object.NewStringFromGoString(str)
-				default:
-					errMsg := fmt.Sprintf(
-						"Unexpected ConstantValue type in instantiate: %d", valueType)
-					_ = log.Log(errMsg, log.SEVERE)
-					return nil, errors.New(errMsg)
-				} // end of ConstantValue type switch
-			} // end of ConstantValue attribute processing
-		} // end of processing attributes
-	} // end of search through attributes
+	// static fields can have ConstantValue attributes, which specify their initial value.
+	// The parser keeps the value in the field.
+	if f.IsStatic && f.ConstValue != nil {
+		switch value := f.ConstValue.(type) {
+		case int: // ints, shorts, chars, bytes, and booleans
+			fieldToAdd.Fvalue = int64(value)
+		case int64:
+			fieldToAdd.Fvalue = value
+		case float32:
+			fieldToAdd.Fvalue = float64(value)
+		case float64:
+			fieldToAdd.Fvalue = value
+		case string: // stored as LDC stores string constants
+			fieldToAdd.Fvalue = object.CreateCompactStringFromGoString(&value)
+		default:
+			errMsg := fmt.Sprintf("Unexpected ConstantValue type in instantiate: %T", value)
+			_ = log.Log(errMsg, log.SEVERE)
+			return nil, errors.New(errMsg)
+		}
+	}
 
 	if f.IsStatic {
 		s := classloader.Static{
@@ -240,7 +145,7 @@ func createField(f classloader.Field, k *classloader.Klass, classname string) (*
 		fieldName := k.Data.CP.Utf8Refs[f.Name]
 		fullFieldName := classname + "." + fieldName
 
-		_, alreadyPresent := classloader.Statics[fullFieldName]
+		_, alreadyPresent := classloader.FetchStatic(fullFieldName)
 		if !alreadyPresent { // add only if field has not been pre-loaded
 			_ = classloader.AddStatic(fullFieldName, s)
 		}
@@ -254,8 +159,12 @@ func loadThisClass(className string) error {
 	if alreadyLoaded != nil { // if the class is already loaded, skip the rest of this
 		return nil
 	}
-	// Try to load class by name
+	// Try to load class by name. A class that fails verification is thrown a VerifyError.
 	err := classloader.LoadClassFromNameOnly(className)
+	var errBlk *classloader.GErrBlk
+	if errors.As(err, &errBlk) {
+		return errBlk
+	}
 	if err != nil {
 		var errClassName = className
 		if className == "" {
@@ -0,0 +1,80 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"fmt"
+	"jacobin/classloader"
+	"strings"
+)
+
+// locateInterfaceMethod finds the method to execute for an INVOKEINTERFACE of the method
+// methName with type methType, declared in interface ifaceName, on an object of class
+// receiverClass. It follows the resolution and selection steps in the JVMS (5.4.3.4 and
+// 5.4.6): first the receiver's class and its superclasses are searched, then the
+// maximally-specific methods in its superinterfaces, which is where default methods are
+// found. The selected method is cached in the MTable under the receiver's class (see
+// selectedMethodKey), so subsequent invocations on objects of the same class are found
+// immediately. The selected method must be public, which is checked on every invocation,
+// since INVOKEVIRTUAL can select and cache a method that isn't.
+//
+// Returns the MTable entry and the name of the class that declares the method. The
+// errors that the JVMS requires are returned as a *GErrBlk, which the caller throws.
+func locateInterfaceMethod(receiverClass, ifaceName, methName, methType string) (classloader.MTentry, string, error) {
+	// resolution: the class referenced in the CP must be an interface
+	if !classloader.IsInterface(ifaceName) {
+		errMsg := fmt.Sprintf("Found class %s, but interface was expected",
+			javaClassName(ifaceName))
+		return classloader.MTentry{}, "", &classloader.GErrBlk{
+			ExceptionType: "java/lang/IncompatibleClassChangeError", ErrMsg: errMsg}
+	}
+
+	// the receiver must implement the interface. This is checked before the MTable,
+	// which can hold a method of the same name selected by an INVOKEVIRTUAL.
+	if !classloader.ImplementsInterface(receiverClass, ifaceName) {
+		errMsg := fmt.Sprintf("Class %s does not implement the requested interface %s",
+			javaClassName(receiverClass), javaClassName(ifaceName))
+		return classloader.MTentry{}, "", &classloader.GErrBlk{
+			ExceptionType: "java/lang/IncompatibleClassChangeError", ErrMsg: errMsg}
+	}
+
+	// has this method already been selected for this receiver class?
+	methKey := selectedMethodKey(receiverClass, methName, methType)
+	mtEntry := classloader.MTableFetch(methKey)
+	declaringClass := declaringClassOf(mtEntry, receiverClass)
+	if mtEntry.Meth == nil {
+		// selection: look in the receiver's class, its superclasses, and its superinterfaces
+		var err error
+		declaringClass, mtEntry, err = selectMethod(receiverClass, methName, methType)
+		if err != nil {
+			return classloader.MTentry{}, "", err
+		}
+
+		if declaringClass == "" || isAbstractMethod(mtEntry) {
+			errMsg := fmt.Sprintf("Receiver class %s does not define or inherit an implementation of the "+
+				"resolved method 'abstract %s' of interface %s.",
+				javaClassName(receiverClass), methName+methType, javaClassName(ifaceName))
+			return classloader.MTentry{}, "", &classloader.GErrBlk{
+				ExceptionType: "java/lang/AbstractMethodError", ErrMsg: errMsg}
+		}
+		classloader.MTableInsert(methKey, mtEntry)
+	}
+
+	if mtEntry.MType == 'J' && mtEntry.Meth.(classloader.JmEntry).AccessFlags&classloader.AccPublic == 0 {
+		errMsg := fmt.Sprintf("Receiver class %s does not have a public implementation of %s.%s",
+			javaClassName(receiverClass), javaClassName(ifaceName), methName+methType)
+		return classloader.MTentry{}, "", &classloader.GErrBlk{
+			ExceptionType: "java/lang/IllegalAccessError", ErrMsg: errMsg}
+	}
+	return mtEntry, declaringClass, nil
+}
+
+// javaClassName converts a class name in the internal JVM format (java/lang/String)
+// into the format used in Java (java.lang.String)
+func javaClassName(className string) string {
+	return strings.ReplaceAll(className, "/", ".")
+}
@@ -0,0 +1,91 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/log"
+)
+
+// INVOKEDYNAMIC call sites are linked by a bootstrap method, which in the JDK builds a
+// MethodHandle that is then invoked. Jacobin does not run the bootstrap methods. Rather,
+// it recognizes the bootstraps that javac emits and performs their work natively.
+
+// the names of the supported bootstrap methods, in the form class.methodName
+const (
+	stringConcatBootstrap      = "java/lang/invoke/StringConcatFactory.makeConcatWithConstants"
+	lambdaMetafactoryBootstrap = "java/lang/invoke/LambdaMetafactory.metafactory"
+)
+
+// invokeDynamic executes the INVOKEDYNAMIC instruction whose InvokeDynamic entry is
+// at cpIndex in the CP of frame f. The arguments to the call site are on f's operand
+// stack. They're replaced by the call site's return value, if any.
+func invokeDynamic(fs *list.List, f *frames.Frame, cpIndex int) error {
+	if cpIndex < 1 || cpIndex >= len(f.CP.CpIndex) ||
+		f.CP.CpIndex[cpIndex].Type != classloader.InvokeDynamic {
+		errMsg := fmt.Sprintf("INVOKEDYNAMIC: Expected an invokedynamic CP entry at %d in method %s of class %s",
+			cpIndex, f.MethName, f.ClName)
+		_ = log.Log(errMsg, log.SEVERE)
+		return errors.New(errMsg)
+	}
+	callSite := f.CP.InvokeDynamics[f.CP.CpIndex[cpIndex].Slot]
+
+	// the name and type of the call site. The type describes the arguments on the stack.
+	nAndT := f.CP.NameAndTypes[f.CP.CpIndex[callSite.NameAndType].Slot]
+	methodName := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, nAndT.NameIndex)
+	methodType := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, nAndT.DescIndex)
+
+	bootstrap, err := getBootstrapMethod(f.ClName, callSite.BootstrapIndex)
+	if err != nil {
+		return err
+	}
+
+	// the bootstrap method is identified by a method handle, which points to its method ref
+	if int(bootstrap.MethodRef) >= len(f.CP.CpIndex) ||
+		f.CP.CpIndex[bootstrap.MethodRef].Type != classloader.MethodHandle {
+		errMsg := fmt.Sprintf("INVOKEDYNAMIC: Invalid bootstrap method handle for %s%s in class %s",
+			methodName, methodType, f.ClName)
+		_ = log.Log(errMsg, log.SEVERE)
+		return errors.New(errMsg)
+	}
+	handle := f.CP.MethodHandles[f.CP.CpIndex[bootstrap.MethodRef].Slot]
+	bsmClass, bsmName, _ := getMethInfoFromCPmethref(f.CP, int(handle.RefIndex))
+
+	switch bsmClass + "." + bsmName {
+	case stringConcatBootstrap:
+		return concatWithConstants(fs, f, methodType, bootstrap.Args)
+	case lambdaMetafactoryBootstrap:
+		return invokeLambdaMetafactory(f, cpIndex, methodName, methodType, bootstrap.Args)
+	default:
+		errMsg := fmt.Sprintf("INVOKEDYNAMIC: Unsupported bootstrap method %s.%s for %s%s in class %s",
+			bsmClass, bsmName, methodName, methodType, f.ClName)
+		_ = log.Log(errMsg, log.SEVERE)
+		return errors.New(errMsg)
+	}
+}
+
+// getBootstrapMethod returns the entry at index in the BootstrapMethods attribute
+// of the named class
+func getBootstrapMethod(className string, index uint16) (classloader.BootstrapMethod, error) {
+	k := classloader.MethAreaFetch(className)
+	if k == nil || k.Data == nil {
+		errMsg := "INVOKEDYNAMIC: Could not find class " + className + " in the method area"
+		_ = log.Log(errMsg, log.SEVERE)
+		return classloader.BootstrapMethod{}, errors.New(errMsg)
+	}
+
+	if int(index) >= len(k.Data.Bootstraps) {
+		errMsg := fmt.Sprintf("INVOKEDYNAMIC: Bootstrap method %d not found in class %s", index, className)
+		_ = log.Log(errMsg, log.SEVERE)
+		return classloader.BootstrapMethod{}, errors.New(errMsg)
+	}
+	return k.Data.Bootstraps[index], nil
+}
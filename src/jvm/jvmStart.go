@@ -43,6 +43,9 @@ func JVMrun() int {
 	if err != nil {
 		return shutdown.Exit(shutdown.JVM_EXCEPTION)
 	}
+	// the classloader reads the verification level from the global singleton
+	globals.GetGlobalRef().VerifyLevel = Global.VerifyLevel
+
 	// some CLI options, like -version, show data and immediately exit. This tests for that.
 	if Global.ExitNow == true {
 		return shutdown.Exit(shutdown.OK)
@@ -59,7 +62,7 @@ func JVMrun() int {
 	var mainClass string
 
 	if Global.StartingJar != "" {
-		manifestClass, err := classloader.GetMainClassFromJar(classloader.BootstrapCL, Global.StartingJar)
+		manifestClass, err := classloader.GetMainClassFromJar(classloader.AppCL, Global.StartingJar)
 
 		if err != nil {
 			_ = log.Log(err.Error(), log.INFO)
@@ -70,12 +73,12 @@ func JVMrun() int {
 			_ = log.Log(fmt.Sprintf("no main manifest attribute, in %s", Global.StartingJar), log.INFO)
 			return shutdown.Exit(shutdown.APP_EXCEPTION)
 		}
-		mainClass, err = classloader.LoadClassFromJar(classloader.BootstrapCL, manifestClass, Global.StartingJar)
+		mainClass, err = classloader.LoadClassFromJar(classloader.AppCL, manifestClass, Global.StartingJar)
 		if err != nil { // the exceptions message will already have been shown to user
 			return shutdown.Exit(shutdown.JVM_EXCEPTION)
 		}
 	} else if Global.StartingClass != "" {
-		mainClass, err = classloader.LoadClassFromFile(classloader.BootstrapCL, Global.StartingClass)
+		mainClass, err = classloader.LoadClassFromFile(classloader.AppCL, Global.StartingClass)
 		if err != nil { // the exceptions message will already have been shown to user
 			return shutdown.Exit(shutdown.JVM_EXCEPTION)
 		}
@@ -0,0 +1,471 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/log"
+	"jacobin/object"
+	"jacobin/types"
+	"strings"
+	"sync"
+)
+
+// Lambdas and method references are compiled by javac into an INVOKEDYNAMIC whose
+// bootstrap method is LambdaMetafactory.metafactory(). Its three static arguments are:
+// the type of the functional interface's method (the SAM type), a method handle that
+// points to the method that implements it, and the SAM type as instantiated by the
+// generics in use. The call site's name is the name of the interface method, and its
+// type takes the captured values and returns the functional interface.
+//
+// As the JDK does, Jacobin links the call site by creating a synthetic class that
+// implements the interface. The captured values are fields of this class, and its
+// single method loads them, followed by its own arguments, and invokes the method
+// pointed to by the method handle. The class is created the first time the call
+// site is executed. Every later execution simply creates an instance of it.
+
+// method handle kinds (JVMS 5.4.3.5) that can implement a lambda
+const (
+	refInvokeVirtual    = 5
+	refInvokeStatic     = 6
+	refInvokeSpecial    = 7
+	refNewInvokeSpecial = 8
+	refInvokeInterface  = 9
+)
+
+// lambdaCallSite is an INVOKEDYNAMIC call site that has been linked to a synthetic class
+type lambdaCallSite struct {
+	className string         // the name of the synthetic class
+	captures  []string       // the field descriptors of the captured values
+	instance  *object.Object // the single instance of the class, if nothing is captured
+}
+
+// the linked call sites, indexed by the name of the class that contains the call site
+// and the CP index of the call site's InvokeDynamic entry
+var lambdaCallSites = make(map[string]*lambdaCallSite)
+var lambdaCallSitesMutex sync.Mutex
+var lambdaClassCount = 0
+
+// invokeLambdaMetafactory executes an INVOKEDYNAMIC of a lambda or method reference, whose
+// InvokeDynamic entry is at cpIndex in f's CP. The captured values are popped off the
+// operand stack and replaced by an object that implements the functional interface.
+func invokeLambdaMetafactory(f *frames.Frame, cpIndex int, methodName, methodType string,
+	bsmArgs []uint16) error {
+	callSiteKey := fmt.Sprintf("%s.%d", f.ClName, cpIndex)
+
+	lambdaCallSitesMutex.Lock()
+	site := lambdaCallSites[callSiteKey]
+	if site == nil {
+		var err error
+		site, err = linkLambdaCallSite(f, methodName, methodType, bsmArgs)
+		if err != nil {
+			lambdaCallSitesMutex.Unlock()
+			return err
+		}
+		lambdaCallSites[callSiteKey] = site
+	}
+	lambdaCallSitesMutex.Unlock()
+
+	if site.instance != nil { // non-capturing lambdas are all the same object
+		push(f, site.instance)
+		return nil
+	}
+
+	obj := makeLambdaObject(site.className)
+	for i := len(site.captures) - 1; i >= 0; i-- {
+		if types.UsesTwoSlots(site.captures[i]) {
+			pop(f)
+		}
+		obj.Fields[i] = object.Field{Ftype: site.captures[i], Fvalue: pop(f)}
+	}
+	push(f, obj)
+	return nil
+}
+
+// makeLambdaObject creates an instance of a synthetic lambda class, whose fields,
+// if any, are filled in by the caller
+func makeLambdaObject(className string) *object.Object {
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	_ = classloader.LayOutFields(obj, className) // the class is in the method area, so this can't fail
+	return obj
+}
+
+// linkLambdaCallSite creates the synthetic class for a call site of the lambda
+// metafactory and posts it to the method area
+func linkLambdaCallSite(f *frames.Frame, methodName, methodType string, bsmArgs []uint16) (*lambdaCallSite, error) {
+	if len(bsmArgs) < 3 {
+		errMsg := fmt.Sprintf("INVOKEDYNAMIC: Expected 3 bootstrap arguments for lambda %s%s in class %s, got %d",
+			methodName, methodType, f.ClName, len(bsmArgs))
+		_ = log.Log(errMsg, log.SEVERE)
+		return nil, errors.New(errMsg)
+	}
+
+	samType := getMethodTypeFromCP(f.CP, bsmArgs[0])
+	implKind, implClass, implName, implType := getMethodHandleFromCP(f.CP, bsmArgs[1])
+	if samType == "" || implClass == "" {
+		errMsg := fmt.Sprintf("INVOKEDYNAMIC: Invalid bootstrap arguments for lambda %s%s in class %s",
+			methodName, methodType, f.ClName)
+		_ = log.Log(errMsg, log.SEVERE)
+		return nil, errors.New(errMsg)
+	}
+
+	// the call site returns the functional interface: (captures)Lpackage/Interface;
+	iface := methodType[strings.Index(methodType, ")")+1:]
+	iface = strings.TrimSuffix(strings.TrimPrefix(iface, "L"), ";")
+
+	lambdaClassCount += 1
+	className := fmt.Sprintf("%s$$Lambda$%d", f.ClName, lambdaClassCount)
+	captures := parseParamDescriptors(methodType)
+
+	cp := newSyntheticCP()
+	code, maxStack, err := generateLambdaMethod(cp, className, captures, samType,
+		implKind, implClass, implName, implType)
+	if err != nil {
+		return nil, err
+	}
+
+	data := classloader.ClData{
+		Name:        className,
+		Superclass:  "java/lang/Object",
+		MethodTable: make(map[string]*classloader.Method),
+		SourceFile:  "Unknown Source",
+	}
+	data.Access.ClassIsFinal = true
+	data.Access.ClassIsSynthetic = true
+
+	// the fields hold the captured values. Their names follow the JDK's: arg$1, arg$2, etc.
+	for i, desc := range captures {
+		data.Fields = append(data.Fields, classloader.Field{
+			AccessFlags: classloader.AccPrivate | classloader.AccFinal,
+			Name:        cp.utf8Slot(fmt.Sprintf("arg$%d", i+1)),
+			Desc:        cp.utf8Slot(desc),
+		})
+	}
+	data.Interfaces = append(data.Interfaces, cp.utf8Slot(iface))
+
+	samParamSlots := 0
+	for _, param := range parseParamDescriptors(samType) {
+		samParamSlots += slotsFor(param)
+	}
+	data.MethodTable[methodName+samType] = &classloader.Method{
+		AccessFlags: classloader.AccPublic,
+		Name:        cp.utf8Slot(methodName),
+		Desc:        cp.utf8Slot(samType),
+		CodeAttr: classloader.CodeAttrib{
+			MaxStack:  maxStack,
+			MaxLocals: samParamSlots + 1, // the parameters plus the 'this' reference
+			Code:      code,
+		},
+	}
+	data.CP = cp.CPool
+
+	loader := "bootstrap"
+	if k := classloader.MethAreaFetch(f.ClName); k != nil {
+		loader = k.Loader
+	}
+	classloader.MethAreaInsert(className, &classloader.Klass{Status: 'N', Loader: loader, Data: &data})
+
+	site := lambdaCallSite{className: className, captures: captures}
+	if len(captures) == 0 {
+		site.instance = makeLambdaObject(className)
+	}
+
+	if MainThread.Trace {
+		traceInfo := fmt.Sprintf("\tlinked lambda %s%s in %s to %s, which calls %s.%s%s",
+			methodName, samType, f.ClName, className, implClass, implName, implType)
+		_ = log.Log(traceInfo, log.TRACE_INST)
+	}
+	return &site, nil
+}
+
+// generateLambdaMethod creates the bytecodes of the method of a synthetic lambda class.
+// The method loads the captured values from its fields and then its own parameters,
+// converting them (by boxing, unboxing, or widening) to the types expected by the
+// implementation method, which it then invokes. The return value is converted in
+// the same way. The CP entries that the bytecodes refer to are added to cp.
+// Returns the bytecodes and the maximum depth of the operand stack.
+func generateLambdaMethod(cp *syntheticCP, className string, captures []string, samType string,
+	implKind int, implClass, implName, implType string) ([]byte, int, error) {
+
+	// the field refs to the captured values. The fields of the lambda object are in the
+	// order of the class's field layout, which is the order of the captures.
+	var fieldRefs []uint16
+	for i, desc := range captures {
+		fieldRefs = append(fieldRefs, cp.fieldRef(className, fmt.Sprintf("arg$%d", i+1), desc))
+	}
+
+	// instance methods and constructors take the object reference as their first argument
+	implParams := parseParamDescriptors(implType)
+	implReturn := implType[strings.Index(implType, ")")+1:]
+	if implKind == refInvokeVirtual || implKind == refInvokeSpecial || implKind == refInvokeInterface {
+		implParams = append([]string{"L" + implClass + ";"}, implParams...)
+	}
+
+	samParams := parseParamDescriptors(samType)
+	samReturn := samType[strings.Index(samType, ")")+1:]
+	if len(captures)+len(samParams) != len(implParams) {
+		errMsg := fmt.Sprintf("INVOKEDYNAMIC: Lambda type %s with %d captured values does not match %s.%s%s",
+			samType, len(captures), implClass, implName, implType)
+		_ = log.Log(errMsg, log.SEVERE)
+		return nil, 0, errors.New(errMsg)
+	}
+
+	var code []byte
+	if implKind == refNewInvokeSpecial {
+		classRef := cp.classRef(implClass)
+		code = append(code, NEW, byte(classRef>>8), byte(classRef), DUP)
+	}
+
+	// the captured values, then the parameters of this method
+	argSlots := 0
+	for i, desc := range captures {
+		code = append(code, ALOAD_0, GETFIELD, byte(fieldRefs[i]>>8), byte(fieldRefs[i]))
+		code = append(code, convertLambdaValue(cp, desc, implParams[i])...)
+		argSlots += slotsFor(implParams[i])
+	}
+
+	local := 1 // local 0 holds 'this'
+	for i, desc := range samParams {
+		code = append(code, loadOpcodeFor(desc), byte(local))
+		local += slotsFor(desc)
+		implParam := implParams[len(captures)+i]
+		code = append(code, convertLambdaValue(cp, desc, implParam)...)
+		argSlots += slotsFor(implParam)
+	}
+
+	// invoke the implementation method
+	switch implKind {
+	case refInvokeStatic:
+		methRef := cp.methodRef(implClass, implName, implType)
+		code = append(code, INVOKESTATIC, byte(methRef>>8), byte(methRef))
+	case refInvokeVirtual:
+		methRef := cp.methodRef(implClass, implName, implType)
+		code = append(code, INVOKEVIRTUAL, byte(methRef>>8), byte(methRef))
+	case refInvokeSpecial, refNewInvokeSpecial:
+		methRef := cp.methodRef(implClass, implName, implType)
+		code = append(code, INVOKESPECIAL, byte(methRef>>8), byte(methRef))
+	case refInvokeInterface:
+		methRef := cp.interfaceRef(implClass, implName, implType)
+		code = append(code, INVOKEINTERFACE, byte(methRef>>8), byte(methRef), byte(argSlots), 0)
+	default:
+		errMsg := fmt.Sprintf("INVOKEDYNAMIC: Unsupported method handle kind %d for lambda implementation %s.%s",
+			implKind, implClass, implName)
+		_ = log.Log(errMsg, log.SEVERE)
+		return nil, 0, errors.New(errMsg)
+	}
+
+	// a constructor leaves the new object on the stack
+	if implKind == refNewInvokeSpecial {
+		implReturn = "L" + implClass + ";"
+	}
+
+	// the arguments, plus room for the NEW and DUP of a constructor and for a
+	// long or double being converted while it's on top of the arguments
+	maxStack := argSlots + 4
+	if slotsFor(implReturn) > maxStack {
+		maxStack = slotsFor(implReturn)
+	}
+
+	switch {
+	case samReturn == "V":
+		if implReturn != "V" {
+			if slotsFor(implReturn) == 2 {
+				code = append(code, POP2)
+			} else {
+				code = append(code, POP)
+			}
+		}
+		code = append(code, RETURN)
+	case implReturn == "V":
+		errMsg := fmt.Sprintf("INVOKEDYNAMIC: Lambda type %s requires a value, but %s.%s%s returns void",
+			samType, implClass, implName, implType)
+		_ = log.Log(errMsg, log.SEVERE)
+		return nil, 0, errors.New(errMsg)
+	default:
+		code = append(code, convertLambdaValue(cp, implReturn, samReturn)...)
+		code = append(code, returnOpcodeFor(samReturn))
+	}
+	return code, maxStack, nil
+}
+
+// the wrapper classes of the primitive types, and the methods that unbox them
+var boxedTypes = map[byte]string{
+	'Z': "java/lang/Boolean", 'B': "java/lang/Byte", 'C': "java/lang/Character", 'S': "java/lang/Short",
+	'I': "java/lang/Integer", 'J': "java/lang/Long", 'F': "java/lang/Float", 'D': "java/lang/Double",
+}
+var unboxingMethods = map[byte]string{
+	'Z': "booleanValue", 'B': "byteValue", 'C': "charValue", 'S': "shortValue",
+	'I': "intValue", 'J': "longValue", 'F': "floatValue", 'D': "doubleValue",
+}
+
+// convertLambdaValue returns the bytecodes that convert a value on the stack of type
+// from to type to, as the JDK's lambda implementation does: primitives are boxed
+// when a reference is expected, references are unboxed when a primitive is expected,
+// and primitives are widened when a wider primitive is expected.
+func convertLambdaValue(cp *syntheticCP, from, to string) []byte {
+	fromIsRef := types.IsAddress(from)
+	toIsRef := types.IsAddress(to)
+
+	switch {
+	case fromIsRef && toIsRef:
+		return nil
+	case !fromIsRef && toIsRef: // box the primitive
+		wrapper := boxedTypes[from[0]]
+		methRef := cp.methodRef(wrapper, "valueOf", "("+from+")L"+wrapper+";")
+		return []byte{INVOKESTATIC, byte(methRef >> 8), byte(methRef)}
+	case fromIsRef && !toIsRef: // unbox the reference
+		wrapper := boxedTypes[to[0]]
+		methRef := cp.methodRef(wrapper, unboxingMethods[to[0]], "()"+to)
+		return []byte{INVOKEVIRTUAL, byte(methRef >> 8), byte(methRef)}
+	}
+
+	// widening primitive conversions. Jacobin holds all integral types as int64s
+	// and floats as float64s, so only conversions across those are needed.
+	isLong := func(t string) bool { return t == "J" }
+	isFloating := types.IsFloatingPoint
+	switch {
+	case !isLong(from) && !isFloating(from) && isLong(to):
+		return []byte{I2L}
+	case !isLong(from) && !isFloating(from) && to == "F":
+		return []byte{I2F}
+	case !isLong(from) && !isFloating(from) && to == "D":
+		return []byte{I2D}
+	case isLong(from) && to == "F":
+		return []byte{L2F}
+	case isLong(from) && to == "D":
+		return []byte{L2D}
+	case from == "F" && to == "D":
+		return []byte{F2D}
+	}
+	return nil
+}
+
+// slotsFor returns the number of stack slots taken by a value of the given type
+func slotsFor(desc string) int {
+	switch {
+	case desc == "V":
+		return 0
+	case types.UsesTwoSlots(desc):
+		return 2
+	default:
+		return 1
+	}
+}
+
+// loadOpcodeFor returns the bytecode that loads a local of the given type
+func loadOpcodeFor(desc string) byte {
+	switch desc {
+	case "J":
+		return LLOAD
+	case "F":
+		return FLOAD
+	case "D":
+		return DLOAD
+	case "Z", "B", "C", "S", "I":
+		return ILOAD
+	default:
+		return ALOAD
+	}
+}
+
+// returnOpcodeFor returns the bytecode that returns a value of the given type
+func returnOpcodeFor(desc string) byte {
+	switch desc {
+	case "J":
+		return LRETURN
+	case "F":
+		return FRETURN
+	case "D":
+		return DRETURN
+	case "Z", "B", "C", "S", "I":
+		return IRETURN
+	default:
+		return ARETURN
+	}
+}
+
+// getMethodTypeFromCP returns the method descriptor of the MethodType entry at cpIndex,
+// or "" if the entry is not a MethodType
+func getMethodTypeFromCP(CP *classloader.CPool, cpIndex uint16) string {
+	if int(cpIndex) >= len(CP.CpIndex) || CP.CpIndex[cpIndex].Type != classloader.MethodType {
+		return ""
+	}
+	return classloader.FetchUTF8stringFromCPEntryNumber(CP, CP.MethodTypes[CP.CpIndex[cpIndex].Slot])
+}
+
+// getMethodHandleFromCP returns the kind of the MethodHandle entry at cpIndex along with
+// the class name, method name, and method type of the method it refers to. The class
+// name is "" if the entry is not a MethodHandle.
+func getMethodHandleFromCP(CP *classloader.CPool, cpIndex uint16) (int, string, string, string) {
+	if int(cpIndex) >= len(CP.CpIndex) || CP.CpIndex[cpIndex].Type != classloader.MethodHandle {
+		return 0, "", "", ""
+	}
+	handle := CP.MethodHandles[CP.CpIndex[cpIndex].Slot]
+	className, methName, methType := getMethInfoFromCPmethref(CP, int(handle.RefIndex))
+	return int(handle.RefKind), className, methName, methType
+}
+
+// syntheticCP builds the constant pool of a class that's created at run time
+type syntheticCP struct {
+	classloader.CPool
+}
+
+func newSyntheticCP() *syntheticCP {
+	cp := syntheticCP{}
+	cp.CpIndex = append(cp.CpIndex, classloader.CpEntry{}) // entry 0 is unused
+	return &cp
+}
+
+// adds an entry and returns its CP index
+func (cp *syntheticCP) add(entryType uint16, slot int) uint16 {
+	cp.CpIndex = append(cp.CpIndex, classloader.CpEntry{Type: entryType, Slot: uint16(slot)})
+	return uint16(len(cp.CpIndex) - 1)
+}
+
+func (cp *syntheticCP) utf8(s string) uint16 {
+	cp.Utf8Refs = append(cp.Utf8Refs, s)
+	return cp.add(classloader.UTF8, len(cp.Utf8Refs)-1)
+}
+
+// utf8Slot adds a UTF8 entry and returns its index in Utf8Refs, which is how
+// field and method names and types, and interfaces, refer to their strings
+func (cp *syntheticCP) utf8Slot(s string) uint16 {
+	return cp.CpIndex[cp.utf8(s)].Slot
+}
+
+func (cp *syntheticCP) classRef(className string) uint16 {
+	cp.ClassRefs = append(cp.ClassRefs, cp.utf8(className))
+	return cp.add(classloader.ClassRef, len(cp.ClassRefs)-1)
+}
+
+func (cp *syntheticCP) nameAndType(name, desc string) uint16 {
+	entry := classloader.NameAndTypeEntry{NameIndex: cp.utf8(name), DescIndex: cp.utf8(desc)}
+	cp.NameAndTypes = append(cp.NameAndTypes, entry)
+	return cp.add(classloader.NameAndType, len(cp.NameAndTypes)-1)
+}
+
+func (cp *syntheticCP) fieldRef(className, name, desc string) uint16 {
+	cp.FieldRefs = append(cp.FieldRefs,
+		classloader.FieldRefEntry{ClassIndex: cp.classRef(className), NameAndType: cp.nameAndType(name, desc)})
+	return cp.add(classloader.FieldRef, len(cp.FieldRefs)-1)
+}
+
+func (cp *syntheticCP) methodRef(className, name, desc string) uint16 {
+	entry := classloader.MethodRefEntry{ClassIndex: cp.classRef(className), NameAndType: cp.nameAndType(name, desc)}
+	cp.MethodRefs = append(cp.MethodRefs, entry)
+	return cp.add(classloader.MethodRef, len(cp.MethodRefs)-1)
+}
+
+func (cp *syntheticCP) interfaceRef(className, name, desc string) uint16 {
+	entry := classloader.InterfaceRefEntry{ClassIndex: cp.classRef(className), NameAndType: cp.nameAndType(name, desc)}
+	cp.InterfaceRefs = append(cp.InterfaceRefs, entry)
+	return cp.add(classloader.Interface, len(cp.InterfaceRefs)-1)
+}
@@ -0,0 +1,95 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// returns the class, name, and type of the method ref at the CP index in code[at+1:at+3]
+func methodAt(cp *syntheticCP, code []byte, at int) string {
+	className, methName, methType := getMethInfoFromCPmethref(&cp.CPool, int(code[at+1])<<8|int(code[at+2]))
+	return className + "." + methName + methType
+}
+
+// Function<Integer, Long> f = X::f, where f is static long f(int): the argument must be
+// unboxed and the return value boxed
+func TestGenerateLambdaMethodBoxing(t *testing.T) {
+	cp := newSyntheticCP()
+	code, maxStack, err := generateLambdaMethod(cp, "X$$Lambda$1", nil,
+		"(Ljava/lang/Object;)Ljava/lang/Object;", refInvokeStatic, "X", "f", "(I)J")
+	if err != nil {
+		t.Fatalf("generateLambdaMethod: Got unexpected error: %s", err.Error())
+	}
+
+	expected := []byte{ALOAD, 1, INVOKEVIRTUAL, 0, 0, INVOKESTATIC, 0, 0, INVOKESTATIC, 0, 0, ARETURN}
+	if len(code) != len(expected) {
+		t.Fatalf("generateLambdaMethod: Expected %d bytes of code, got: % x", len(expected), code)
+	}
+	for _, at := range []int{0, 2, 5, 8, 11} {
+		if code[at] != expected[at] {
+			t.Errorf("generateLambdaMethod: Expected opcode %02x at %d, got: %02x", expected[at], at, code[at])
+		}
+	}
+	if methodAt(cp, code, 2) != "java/lang/Integer.intValue()I" {
+		t.Errorf("generateLambdaMethod: Expected the argument to be unboxed, got: %s", methodAt(cp, code, 2))
+	}
+	if methodAt(cp, code, 5) != "X.f(I)J" {
+		t.Errorf("generateLambdaMethod: Expected a call to X.f(I)J, got: %s", methodAt(cp, code, 5))
+	}
+	if methodAt(cp, code, 8) != "java/lang/Long.valueOf(J)Ljava/lang/Long;" {
+		t.Errorf("generateLambdaMethod: Expected the return value to be boxed, got: %s", methodAt(cp, code, 8))
+	}
+	if maxStack < 2 {
+		t.Errorf("generateLambdaMethod: Expected a max stack of at least 2, got: %d", maxStack)
+	}
+}
+
+// a bound reference to an interface method, list::add, which captures the receiver
+// and whose int argument is widened to a long
+func TestGenerateLambdaMethodInterfaceReceiver(t *testing.T) {
+	cp := newSyntheticCP()
+	code, _, err := generateLambdaMethod(cp, "X$$Lambda$2", []string{"LList;"},
+		"(I)V", refInvokeInterface, "List", "add", "(J)Z")
+	if err != nil {
+		t.Fatalf("generateLambdaMethod: Got unexpected error: %s", err.Error())
+	}
+
+	// the captured receiver, the widened argument, then the call, whose result is discarded
+	if !bytes.HasPrefix(code, []byte{ALOAD_0, GETFIELD}) || !bytes.HasPrefix(code[4:], []byte{ILOAD, 1, I2L, INVOKEINTERFACE}) {
+		t.Fatalf("generateLambdaMethod: Unexpected code: % x", code)
+	}
+	if cp.CpIndex[int(code[2])<<8|int(code[3])].Slot != 0 { // GETFIELD uses the slot as the field's position
+		t.Errorf("generateLambdaMethod: Expected the field ref of the first field to be in slot 0")
+	}
+	if methodAt(cp, code, 7) != "List.add(J)Z" {
+		t.Errorf("generateLambdaMethod: Expected a call to List.add(J)Z, got: %s", methodAt(cp, code, 7))
+	}
+	if code[10] != 3 { // the receiver and the long
+		t.Errorf("generateLambdaMethod: Expected INVOKEINTERFACE count of 3, got: %d", code[10])
+	}
+	if !bytes.Equal(code[12:], []byte{POP, RETURN}) {
+		t.Errorf("generateLambdaMethod: Expected the result to be popped, got: % x", code[12:])
+	}
+}
+
+func TestGenerateLambdaMethodMismatch(t *testing.T) {
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	cp := newSyntheticCP()
+	_, _, err := generateLambdaMethod(cp, "X$$Lambda$3", nil, "(II)I", refInvokeStatic, "X", "f", "(I)I")
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+	if err == nil {
+		t.Errorf("generateLambdaMethod: Expected an error when the SAM type doesn't match the method")
+	}
+}
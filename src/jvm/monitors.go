@@ -0,0 +1,49 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/object"
+)
+
+// A synchronized method holds the monitor of its object or, if it's a static method,
+// of its class while it runs. The monitor is entered when the method's frame is created
+// and released when the method returns, whether normally or by an exception.
+
+// enterSynchronizedMethod enters the monitor that the synchronized method in frame fram
+// holds while it runs, blocking if another thread owns it. For an instance method, the
+// monitor is that of the object in local 0 (this); for a static method, it's that of
+// the class's mirror, which synchronized (Foo.class) also locks.
+func enterSynchronizedMethod(fram *frames.Frame, isStatic bool) {
+	var lockObj *object.Object
+	if isStatic {
+		lockObj = classloader.GetClassMirror(fram.ClName)
+	} else {
+		lockObj, _ = fram.Locals[0].(*object.Object)
+		if lockObj == nil {
+			return
+		}
+	}
+
+	object.GetMonitor(lockObj).Enter(fram.Thread)
+	fram.SyncObj = lockObj
+}
+
+// exitSynchronizedMethod releases the monitor held by the method in frame f, if it's
+// a synchronized method. Returns false if the frame's thread does not own the monitor,
+// which happens only if the method exited the monitor with an unbalanced MONITOREXIT.
+func exitSynchronizedMethod(f *frames.Frame) bool {
+	if f.SyncObj == nil {
+		return true
+	}
+
+	lockObj := f.SyncObj
+	f.SyncObj = nil
+	return object.GetMonitor(lockObj).Exit(f.Thread)
+}
@@ -0,0 +1,146 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/object"
+	"jacobin/types"
+	"testing"
+)
+
+// sets up the Go implementations of the Object methods, the animal classes, and the
+// class Sheep, which extends Animal and implements Cloneable
+func setupObjectNativesTest() {
+	setupAnimalClasses()
+	classloader.MTable = make(map[string]classloader.MTentry)
+	classloader.MTableLoadNatives()
+
+	sheep := classloader.ClData{Name: "Sheep", Superclass: "Animal", Interfaces: []uint16{0}}
+	sheep.CP.Utf8Refs = []string{"java/lang/Cloneable"}
+	classloader.MethAreaInsert("Sheep", &classloader.Klass{Status: 'X', Loader: "app", Data: &sheep})
+}
+
+// runs code with the given locals and exception table, and returns its frame
+func runObjectNativesCode(t *testing.T, c *messageTestCP, code []byte, locals []interface{},
+	excTable []classloader.CodeException) *frames.Frame {
+	f := frames.CreateFrame(6)
+	f.Ftype = 'J'
+	f.CP = &c.cp
+	f.Meth = code
+	f.Locals = locals
+	f.ExcTable = excTable
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("Object: Got unexpected error: %s", err.Error())
+	}
+	return f
+}
+
+// hashCode() returns the identity hash code, equals() compares identities, and getClass()
+// returns the same mirror for all the objects of a class
+func TestObjectIdentityMethods(t *testing.T) {
+	setupObjectNativesTest()
+	c := newMessageTestCP()
+	hashCode := c.methodRef("java/lang/Object", "hashCode", "()I")
+	equals := c.methodRef("java/lang/Object", "equals", "(Ljava/lang/Object;)Z")
+	getClass := c.methodRef("java/lang/Object", "getClass", "()Ljava/lang/Class;")
+
+	dogName := "Dog"
+	dog, otherDog := object.MakeEmptyObject(), object.MakeEmptyObject()
+	dog.Klass, otherDog.Klass = &dogName, &dogName
+	dog.Mark.Hash = 0x89ABCDEF
+
+	// dog.hashCode(); dog.equals(dog); dog.equals(otherDog); dog.getClass(); otherDog.getClass()
+	code := []byte{ALOAD_0, INVOKEVIRTUAL, byte(hashCode >> 8), byte(hashCode),
+		ALOAD_0, ALOAD_0, INVOKEVIRTUAL, byte(equals >> 8), byte(equals),
+		ALOAD_0, ALOAD_1, INVOKEVIRTUAL, byte(equals >> 8), byte(equals),
+		ALOAD_0, INVOKEVIRTUAL, byte(getClass >> 8), byte(getClass),
+		ALOAD_1, INVOKEVIRTUAL, byte(getClass >> 8), byte(getClass)}
+	f := runObjectNativesCode(t, c, code, []interface{}{dog, otherDog}, nil)
+
+	otherClass, class := pop(f).(*object.Object), pop(f).(*object.Object)
+	if class != otherClass || *class.Klass != "java/lang/Class" {
+		t.Errorf("Object.getClass(): Expected both dogs to return the same mirror of Dog, got: %v and %v",
+			class, otherClass)
+	}
+	if name, _ := classloader.GetObjectField(class, "name"); object.GetGoStringFromJavaStringPtr(
+		name.Fvalue.(*object.Object)) != "Dog" {
+		t.Errorf("Object.getClass(): Expected the mirror's name to be Dog")
+	}
+	if value := pop(f); value != types.JavaBoolFalse {
+		t.Errorf("Object.equals(): Expected two dogs not to be equal, got: %v", value)
+	}
+	if value := pop(f); value != types.JavaBoolTrue {
+		t.Errorf("Object.equals(): Expected a dog to equal itself, got: %v", value)
+	}
+	if value := pop(f); value != int64(int32(-0x76543211)) {
+		t.Errorf("Object.hashCode(): Expected the identity hash code as an int, got: %v", value)
+	}
+}
+
+// clone() of an array copies its elements into a new array of the same type
+func TestObjectCloneArray(t *testing.T) {
+	setupObjectNativesTest()
+	c := newMessageTestCP()
+	clone := c.methodRef("[I", "clone", "()Ljava/lang/Object;")
+
+	original := object.MakeArray(types.IntArray, 3)
+	*original.Fields[0].Fvalue.(*[]int64) = []int64{1, 2, 3}
+	code := []byte{ALOAD_0, INVOKEVIRTUAL, byte(clone >> 8), byte(clone)}
+	f := runObjectNativesCode(t, c, code, []interface{}{original}, nil)
+
+	copied := pop(f).(*object.Object)
+	if copied == original || *copied.Klass != types.IntArray {
+		t.Fatalf("Object.clone(): Expected a new int[], got: %v", copied)
+	}
+	elements := copied.Fields[0].Fvalue.(*[]int64)
+	(*original.Fields[0].Fvalue.(*[]int64))[0] = 42
+	if len(*elements) != 3 || (*elements)[0] != 1 || (*elements)[2] != 3 {
+		t.Errorf("Object.clone(): Expected the copy to have its own elements 1, 2, 3, got: %v", *elements)
+	}
+}
+
+// clone() of an object whose class implements Cloneable is a shallow copy, while
+// clone() of any other object throws a CloneNotSupportedException
+func TestObjectCloneObjects(t *testing.T) {
+	setupObjectNativesTest()
+	c := newMessageTestCP()
+	clone := c.methodRef("java/lang/Object", "clone", "()Ljava/lang/Object;")
+
+	sheepName, dogName := "Sheep", "Dog"
+	wool := object.MakeEmptyObject()
+	sheep, dog := object.MakeEmptyObject(), object.MakeEmptyObject()
+	sheep.Klass, dog.Klass = &sheepName, &dogName
+	sheep.Fields = []object.Field{{Ftype: types.Int, Fvalue: int64(7)}, {Ftype: "LWool;", Fvalue: wool}}
+
+	// try { dolly = sheep.clone(); dog.clone(); } catch (CloneNotSupportedException e) { }
+	code := []byte{ALOAD_0, INVOKEVIRTUAL, byte(clone >> 8), byte(clone), ASTORE_2,
+		ALOAD_1, INVOKEVIRTUAL, byte(clone >> 8), byte(clone), RETURN, ASTORE_1, RETURN}
+	excTable := []classloader.CodeException{{StartPc: 0, EndPc: 9, HandlerPc: 10,
+		CatchType: c.classRef("java/lang/CloneNotSupportedException")}}
+	f := runObjectNativesCode(t, c, code, []interface{}{sheep, dog, nil}, excTable)
+
+	if f.PC != 11 {
+		t.Errorf("Object.clone(): Expected cloning a Dog to be caught by the handler")
+	}
+	dolly := f.Locals[2].(*object.Object)
+	if dolly == sheep || *dolly.Klass != "Sheep" || dolly.Mark.Hash == sheep.Mark.Hash {
+		t.Errorf("Object.clone(): Expected a new Sheep with its own hash code, got: %v", dolly)
+	}
+	if dolly.Fields[0].Fvalue != int64(7) || dolly.Fields[1].Fvalue != wool {
+		t.Errorf("Object.clone(): Expected the copy to have the same field values, got: %v", dolly.Fields)
+	}
+	exc := f.Locals[1].(*object.Object)
+	if msg := getThrowableMessage(exc); *exc.Klass != "java/lang/CloneNotSupportedException" || msg != "Dog" {
+		t.Errorf("Object.clone(): Expected java.lang.CloneNotSupportedException: Dog, got: %s: %s",
+			*exc.Klass, msg)
+	}
+}
@@ -12,7 +12,9 @@ import (
 	"jacobin/execdata"
 	"jacobin/globals"
 	"jacobin/log"
+	"math"
 	"os"
+	"strconv"
 )
 
 // This set of routines loads the Global.Options table with the various
@@ -29,6 +31,7 @@ import (
 //                              // 0 = no argument      1 = value follows a :
 //                              // 2 = value follows =  4 = value follows a space
 //                              // 8 = option has multiple values separated by a ; (such as -cp)
+//                              // 16 = value immediately follows the option (such as -Xss1m)
 //	        action  func(position int, name string, gl pointer to globasl) error
 //                              // which is the action to perform when this option found.
 //      }
@@ -100,6 +103,15 @@ func LoadOptionsTable(Global globals.Globals) {
 	verboseClass := globals.Option{true, false, 1, verbosityLevel}
 	Global.Options["-verbose"] = verboseClass
 
+	maxFrames := globals.Option{true, false, 1, setMaxFrameDepth}
+	Global.Options["-Xmaxframes"] = maxFrames
+
+	threadStackSize := globals.Option{true, false, 16, setThreadStackSize}
+	Global.Options["-Xss"] = threadStackSize
+
+	verify := globals.Option{true, false, 1, setVerifyLevel}
+	Global.Options["-Xverify"] = verify
+
 	version := globals.Option{true, false, 1, versionStderrThenExit}
 	Global.Options["-version"] = version
 
@@ -140,6 +152,84 @@ func notSupported(pos int, arg string, gl *globals.Globals) (int, error) {
 	return pos, nil
 }
 
+// for -Xmaxframes:<n>, which sets the maximum number of frames on a thread's frame
+// stack. Invoking a method that would exceed this depth throws a StackOverflowError.
+func setMaxFrameDepth(pos int, argValue string, gl *globals.Globals) (int, error) {
+	depth, err := strconv.Atoi(argValue)
+	if err != nil || depth < 1 {
+		errMsg := "Invalid maximum frame depth: -Xmaxframes:" + argValue
+		_ = log.Log(errMsg, log.SEVERE)
+		return pos, errors.New(errMsg)
+	}
+
+	gl.MaxFrameDepth = depth
+	setOptionToSeen("-Xmaxframes", gl)
+	return pos, nil
+}
+
+// for -Xss<size>, which sets the thread stack size in bytes, optionally followed by
+// k, m, or g (in either case) for kilobytes, megabytes, or gigabytes, as in the JDK.
+// Jacobin's frames are not stored on a native stack, so the size is converted into
+// a maximum frame depth.
+func setThreadStackSize(pos int, argValue string, gl *globals.Globals) (int, error) {
+	size, err := parseMemorySize(argValue)
+	if err != nil || size < globals.StackBytesPerFrame {
+		errMsg := "Invalid thread stack size: -Xss" + argValue
+		_ = log.Log(errMsg, log.SEVERE)
+		return pos, errors.New(errMsg)
+	}
+
+	gl.MaxFrameDepth = int(size / globals.StackBytesPerFrame)
+	setOptionToSeen("-Xss", gl)
+	return pos, nil
+}
+
+// parseMemorySize converts a size in the format of the JDK's memory options, such as
+// 4096, 512k, or 2M, into a number of bytes
+func parseMemorySize(size string) (int64, error) {
+	multiplier := int64(1)
+	if len(size) > 0 {
+		switch size[len(size)-1] {
+		case 'k', 'K':
+			multiplier = 1024
+		case 'm', 'M':
+			multiplier = 1024 * 1024
+		case 'g', 'G':
+			multiplier = 1024 * 1024 * 1024
+		}
+	}
+	if multiplier > 1 {
+		size = size[:len(size)-1]
+	}
+
+	value, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || value < 0 || value > math.MaxInt64/multiplier {
+		return 0, errors.New("invalid memory size: " + size)
+	}
+	return value * multiplier, nil
+}
+
+// for -Xverify:<level>, which selects the classes whose bytecode is verified when they're
+// loaded: none, remote (the default, which verifies all classes except those of the JDK),
+// or all.
+func setVerifyLevel(pos int, argValue string, gl *globals.Globals) (int, error) {
+	switch argValue {
+	case "none":
+		gl.VerifyLevel = globals.VerifyNone
+	case "remote":
+		gl.VerifyLevel = globals.VerifyRemote
+	case "all":
+		gl.VerifyLevel = globals.VerifyAll
+	default:
+		errMsg := "Invalid verification level: -Xverify:" + argValue
+		_ = log.Log(errMsg, log.SEVERE)
+		return pos, errors.New(errMsg)
+	}
+
+	setOptionToSeen("-Xverify", gl)
+	return pos, nil
+}
+
 func showHelpStderrAndExit(pos int, name string, gl *globals.Globals) (int, error) {
 	ShowUsage(os.Stderr)
 	gl.ExitNow = true
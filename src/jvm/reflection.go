@@ -0,0 +1,145 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"errors"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/object"
+	"jacobin/thread"
+	"jacobin/types"
+)
+
+// Method.invoke() and Constructor.newInstance() are implemented in the classloader
+// package (see classloader/javaLangReflect.go), but they run Java methods, which requires
+// the interpreter, so they call invokeMethodOnThread() here.
+func init() {
+	classloader.InvokeMethod = invokeMethodOnThread
+}
+
+// the frames that stand in for the JDK's accessors, which call the reflected method
+const (
+	methodAccessorClass      = "jdk/internal/reflect/DirectMethodHandleAccessor"
+	constructorAccessorClass = "jdk/internal/reflect/DirectConstructorHandleAccessor"
+)
+
+// invokeMethodOnThread invokes the method of the named class on the thread with the given
+// ID, as described for classloader.InvokeMethod. An instance method is selected by the
+// class of the receiver, as INVOKEVIRTUAL does. The class of a static method or of a
+// constructor is initialized first, and for a constructor, a new object is created.
+func invokeMethodOnThread(className, methName, methType string, receiver *object.Object,
+	args []interface{}, threadID int) (interface{}, *object.Object, error) {
+	fs := frames.CreateFrameStack()
+	if t := thread.FindThread(threadID); t != nil && t.Stack != nil {
+		fs = t.Stack
+	}
+
+	if receiver == nil {
+		exc, err := initializeClass(className, fs)
+		if err != nil || exc != nil {
+			return nil, exc, err
+		}
+	}
+
+	if methName == "<init>" {
+		obj, err := instantiateClass(className, fs)
+		if err != nil {
+			return nil, nil, err
+		}
+		mtEntry, err := classloader.FetchMethodAndCP(className, methName, methType)
+		if err != nil {
+			return nil, nil, err
+		}
+		_, exc, err := runReflectedMethod(fs, mtEntry, className, methName, methType, obj, args,
+			constructorAccessorClass, "newInstance", threadID)
+		if err != nil || exc != nil {
+			return nil, exc, err
+		}
+		return obj, nil, nil
+	}
+
+	var mtEntry classloader.MTentry
+	var err error
+	declaringClass := className
+	if receiver != nil {
+		mtEntry, declaringClass, err = locateVirtualMethod(*receiver.Klass, className, methName, methType)
+	} else {
+		mtEntry, err = classloader.FetchMethodAndCP(className, methName, methType)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return runReflectedMethod(fs, mtEntry, declaringClass, methName, methType, receiver, args,
+		methodAccessorClass, "invoke", threadID)
+}
+
+// runReflectedMethod runs the method on the frame stack fs. The method is called from a
+// golang frame of the given accessor class and method, onto whose operand stack the
+// receiver, if any, and the arguments are pushed, so that the method's frame is set up
+// as createAndInitNewFrame() sets it up for an invocation from Java code. The method's
+// return value, if any, is popped from the same frame. If the method throws an exception
+// that it doesn't catch, the exception is returned wrapped in an InvocationTargetException.
+func runReflectedMethod(fs *list.List, mtEntry classloader.MTentry, className, methName, methType string,
+	receiver *object.Object, args []interface{}, accessorClass, accessorMethod string,
+	threadID int) (interface{}, *object.Object, error) {
+	paramTypes := parseParamDescriptors(methType)
+	cf := frames.CreateFrame(2*len(paramTypes) + 2) // room for the receiver and the return value
+	cf.Ftype = 'G'
+	cf.ClName = accessorClass
+	cf.MethName = accessorMethod
+	cf.Thread = threadID
+	if receiver != nil {
+		push(cf, receiver)
+	}
+	for i, paramType := range paramTypes {
+		push(cf, args[i])
+		if types.UsesTwoSlots(paramType) {
+			push(cf, args[i])
+		}
+	}
+	callerElement := fs.PushFront(cf)
+	defer fs.Remove(callerElement)
+
+	var err error
+	if mtEntry.MType == 'G' {
+		_, _, err = runGmethod(mtEntry, fs, className, methName, methType)
+	} else {
+		jm := mtEntry.Meth.(classloader.JmEntry)
+		var fram *frames.Frame
+		fram, err = createAndInitNewFrame(className, methName, methType, &jm, receiver != nil, cf)
+		if err != nil {
+			return nil, nil, err
+		}
+		fs.PushFront(fram)
+		err = runFrameToCompletion(fs)
+	}
+
+	var callerErr *goCallerException
+	if errors.As(err, &callerErr) {
+		return nil, createInvocationTargetException(callerErr.exception), nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if cf.TOS < 0 { // a void method
+		return nil, nil, nil
+	}
+	return pop(cf), nil, nil
+}
+
+// createInvocationTargetException creates the InvocationTargetException that's thrown
+// when a method invoked by reflection throws an exception. That exception is its target
+// and its cause.
+func createInvocationTargetException(target *object.Object) *object.Object {
+	excObj := createThrowable("java/lang/reflect/InvocationTargetException", "")
+	classloader.SetObjectField(excObj, "target", object.Field{Ftype: "Ljava/lang/Throwable;", Fvalue: target})
+	classloader.SetObjectField(excObj, "cause", object.Field{Ftype: "Ljava/lang/Throwable;", Fvalue: target})
+	return excObj
+}
@@ -0,0 +1,316 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/object"
+	"jacobin/types"
+	"testing"
+)
+
+// sets up the Go implementations of the reflection methods and the class Counter:
+//
+//	class Counter {
+//	    int count;
+//	    static long total;
+//	    Counter(int count) { this.count = count; }
+//	    int add(int n) { return count + n; }
+//	    static double scale(long n, double factor) { return n * factor; }
+//	    void fail() { throw null; }
+//	}
+func setupReflectionTest() {
+	setupInitTest()
+	classloader.MTable = make(map[string]classloader.MTentry)
+	classloader.MTableLoadNatives()
+	delete(classloader.Statics, "Counter.total")
+
+	c := newMessageTestCP()
+	utf8 := func(s string) uint16 {
+		c.utf8(s)
+		return uint16(len(c.cp.Utf8Refs) - 1)
+	}
+	count := c.fieldRef("Counter", "count", "I")
+
+	data := classloader.ClData{Name: "Counter", Superclass: "java/lang/Object",
+		MethodTable: map[string]*classloader.Method{}, ClInit: types.ClInitNotRun}
+	data.Fields = []classloader.Field{
+		{Name: utf8("count"), Desc: utf8("I")},
+		{AccessFlags: classloader.AccStatic, Name: utf8("total"), Desc: utf8("J"), IsStatic: true},
+	}
+	methods := []struct {
+		name, desc  string
+		accessFlags int
+		maxLocals   int
+		code        []byte
+	}{
+		{"<init>", "(I)V", classloader.AccPublic, 2,
+			[]byte{ALOAD_0, ILOAD_1, PUTFIELD, byte(count >> 8), byte(count), RETURN}},
+		{"add", "(I)I", classloader.AccPublic, 2,
+			[]byte{ALOAD_0, GETFIELD, byte(count >> 8), byte(count), ILOAD_1, IADD, IRETURN}},
+		{"scale", "(JD)D", classloader.AccPublic | classloader.AccStatic, 4,
+			[]byte{LLOAD_0, L2D, DLOAD_2, DMUL, DRETURN}},
+		{"fail", "()V", classloader.AccPublic, 1, []byte{ACONST_NULL, ATHROW}},
+	}
+	for _, m := range methods {
+		data.Methods = append(data.Methods, classloader.Method{AccessFlags: m.accessFlags,
+			Name: utf8(m.name), Desc: utf8(m.desc),
+			CodeAttr: classloader.CodeAttrib{MaxStack: 4, MaxLocals: m.maxLocals, Code: m.code}})
+	}
+	for i, m := range methods {
+		data.MethodTable[m.name+m.desc] = &data.Methods[i]
+	}
+	data.CP = c.cp
+	classloader.MethAreaInsert("Counter", &classloader.Klass{Status: 'X', Loader: "app", Data: &data})
+}
+
+// calls the method with the given arguments, which are in the locals, and returns the
+// result, or the exception that it throws. The method is invoked by INVOKEVIRTUAL, or by
+// INVOKESTATIC if there's no receiver.
+func callReflectionMethod(t *testing.T, className, name, desc string, args ...interface{}) (interface{}, *object.Object) {
+	c := newMessageTestCP()
+	meth := c.methodRef(className, name, desc)
+	var code []byte
+	for i := range args {
+		code = append(code, ALOAD, byte(i))
+	}
+	code = append(code, INVOKEVIRTUAL, byte(meth>>8), byte(meth))
+	if desc[len(desc)-1] != 'V' {
+		code = append(code, ASTORE, byte(len(args)))
+	}
+	end := len(code)
+	code = append(code, RETURN, ASTORE, byte(len(args)+1), RETURN)
+	excTable := []classloader.CodeException{{StartPc: 0, EndPc: end, HandlerPc: end + 1, CatchType: 0}}
+
+	locals := append(args, nil, nil)
+	f := runObjectNativesCode(t, c, code, locals, excTable)
+	exc, _ := f.Locals[len(args)+1].(*object.Object)
+	return f.Locals[len(args)], exc
+}
+
+// box returns an object of the wrapper class that holds the value
+func box(className string, value interface{}) *object.Object {
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	classloader.SetObjectField(obj, "value", object.Field{Fvalue: value})
+	return obj
+}
+
+// unbox returns the value that the object of a wrapper class holds
+func unbox(t *testing.T, value interface{}, className string) interface{} {
+	obj, ok := value.(*object.Object)
+	if !ok || obj == nil || *obj.Klass != className {
+		t.Fatalf("Expected an object of %s, got: %v", className, value)
+	}
+	fld, _ := classloader.GetObjectField(obj, "value")
+	return fld.Fvalue
+}
+
+// returns the elements of an array of objects
+func objectArray(value interface{}) []*object.Object {
+	return *value.(*object.Object).Fields[0].Fvalue.(*[]*object.Object)
+}
+
+// returns the class mirrors for the classes, in a Class[]
+func classArray(classNames ...string) *object.Object {
+	array := object.MakeArray("[Ljava/lang/Class;", int64(len(classNames)))
+	for i, className := range classNames {
+		objectArray(array)[i] = classloader.GetClassMirror(className)
+	}
+	return array
+}
+
+// returns an Object[] that holds the objects
+func argArray(objs ...*object.Object) *object.Object {
+	array := object.MakeArray("[Ljava/lang/Object;", int64(len(objs)))
+	copy(objectArray(array), objs)
+	return array
+}
+
+func goStr(s string) *object.Object {
+	return object.CreateCompactStringFromGoString(&s)
+}
+
+// getDeclaredMethods(), getDeclaredFields() and getDeclaredConstructors() return the
+// members that the class declares, and getDeclaredMethod() finds a method by its name
+// and parameter types
+func TestReflectionDeclaredMembers(t *testing.T) {
+	setupReflectionTest()
+	counter := classloader.GetClassMirror("Counter")
+	nameOf := func(member *object.Object, memberClass string) string {
+		name, _ := callReflectionMethod(t, memberClass, "getName", "()Ljava/lang/String;", member)
+		return object.GetGoStringFromJavaStringPtr(name.(*object.Object))
+	}
+
+	methods, _ := callReflectionMethod(t, "java/lang/Class", "getDeclaredMethods",
+		"()[Ljava/lang/reflect/Method;", counter)
+	var names []string
+	for _, meth := range objectArray(methods) {
+		names = append(names, nameOf(meth, "java/lang/reflect/Method"))
+	}
+	if len(names) != 3 || names[0] != "add" || names[1] != "scale" || names[2] != "fail" {
+		t.Errorf("Class.getDeclaredMethods(): Expected add, scale, and fail, got: %v", names)
+	}
+
+	fields, _ := callReflectionMethod(t, "java/lang/Class", "getDeclaredFields",
+		"()[Ljava/lang/reflect/Field;", counter)
+	if fieldArray := objectArray(fields); len(fieldArray) != 2 ||
+		nameOf(fieldArray[0], "java/lang/reflect/Field") != "count" ||
+		nameOf(fieldArray[1], "java/lang/reflect/Field") != "total" {
+		t.Errorf("Class.getDeclaredFields(): Expected count and total")
+	}
+
+	constructors, _ := callReflectionMethod(t, "java/lang/Class", "getDeclaredConstructors",
+		"()[Ljava/lang/reflect/Constructor;", counter)
+	if constructorArray := objectArray(constructors); len(constructorArray) != 1 ||
+		nameOf(constructorArray[0], "java/lang/reflect/Constructor") != "Counter" {
+		t.Errorf("Class.getDeclaredConstructors(): Expected the constructor Counter(int)")
+	}
+
+	getDeclaredMethod := "(Ljava/lang/String;[Ljava/lang/Class;)Ljava/lang/reflect/Method;"
+	scale, exc := callReflectionMethod(t, "java/lang/Class", "getDeclaredMethod", getDeclaredMethod,
+		counter, goStr("scale"), classArray("long", "double"))
+	if exc != nil {
+		t.Fatalf("Class.getDeclaredMethod(): Got unexpected exception: %s", *exc.Klass)
+	}
+	returnType, _ := callReflectionMethod(t, "java/lang/reflect/Method", "getReturnType",
+		"()Ljava/lang/Class;", scale)
+	if returnType != classloader.GetClassMirror("double") {
+		t.Errorf("Method.getReturnType(): Expected double, got: %v", returnType)
+	}
+	paramTypes, _ := callReflectionMethod(t, "java/lang/reflect/Method", "getParameterTypes",
+		"()[Ljava/lang/Class;", scale)
+	if params := objectArray(paramTypes); len(params) != 2 || params[0] != classloader.GetClassMirror("long") ||
+		params[1] != classloader.GetClassMirror("double") {
+		t.Errorf("Method.getParameterTypes(): Expected long and double, got: %v", params)
+	}
+
+	_, exc = callReflectionMethod(t, "java/lang/Class", "getDeclaredMethod", getDeclaredMethod,
+		counter, goStr("add"), classArray("long"))
+	if exc == nil || *exc.Klass != "java/lang/NoSuchMethodException" || getThrowableMessage(exc) != "Counter.add(long)" {
+		t.Errorf("Class.getDeclaredMethod(): Expected java.lang.NoSuchMethodException: Counter.add(long), got: %v", exc)
+	}
+	_, exc = callReflectionMethod(t, "java/lang/Class", "getDeclaredField",
+		"(Ljava/lang/String;)Ljava/lang/reflect/Field;", counter, goStr("missing"))
+	if exc == nil || *exc.Klass != "java/lang/NoSuchFieldException" || getThrowableMessage(exc) != "missing" {
+		t.Errorf("Class.getDeclaredField(): Expected java.lang.NoSuchFieldException: missing, got: %v", exc)
+	}
+}
+
+// Constructor.newInstance() and Method.invoke() run the Java code with unboxed arguments,
+// box the result, and wrap an exception thrown by the code in an InvocationTargetException
+func TestReflectionInvoke(t *testing.T) {
+	setupReflectionTest()
+	counterClass := classloader.GetClassMirror("Counter")
+	getDeclaredMethod := func(name string, paramTypes ...string) *object.Object {
+		meth, exc := callReflectionMethod(t, "java/lang/Class", "getDeclaredMethod",
+			"(Ljava/lang/String;[Ljava/lang/Class;)Ljava/lang/reflect/Method;",
+			counterClass, goStr(name), classArray(paramTypes...))
+		if exc != nil {
+			t.Fatalf("Class.getDeclaredMethod(): Got unexpected exception: %s", *exc.Klass)
+		}
+		return meth.(*object.Object)
+	}
+	invoke := "(Ljava/lang/Object;[Ljava/lang/Object;)Ljava/lang/Object;"
+
+	constructor, _ := callReflectionMethod(t, "java/lang/Class", "getDeclaredConstructor",
+		"([Ljava/lang/Class;)Ljava/lang/reflect/Constructor;", counterClass, classArray("int"))
+	value, exc := callReflectionMethod(t, "java/lang/reflect/Constructor", "newInstance",
+		"([Ljava/lang/Object;)Ljava/lang/Object;", constructor, argArray(box("java/lang/Integer", int64(5))))
+	if exc != nil {
+		t.Fatalf("Constructor.newInstance(): Got unexpected exception: %s", *exc.Klass)
+	}
+	counter := value.(*object.Object)
+	if *counter.Klass != "Counter" || counter.Fields[0].Fvalue != int64(5) {
+		t.Fatalf("Constructor.newInstance(): Expected a Counter with a count of 5, got: %v", counter)
+	}
+
+	// a byte argument is widened to an int, and the int result is boxed
+	add := getDeclaredMethod("add", "int")
+	value, _ = callReflectionMethod(t, "java/lang/reflect/Method", "invoke", invoke,
+		add, counter, argArray(box("java/lang/Byte", int64(3))))
+	if sum := unbox(t, value, "java/lang/Integer"); sum != int64(8) {
+		t.Errorf("Method.invoke(): Expected add(3) to return 8, got: %v", sum)
+	}
+
+	// a static method is invoked without a receiver, and its long and double arguments
+	// take two slots each
+	scale := getDeclaredMethod("scale", "long", "double")
+	value, _ = callReflectionMethod(t, "java/lang/reflect/Method", "invoke", invoke,
+		scale, object.Null, argArray(box("java/lang/Integer", int64(3)), box("java/lang/Double", 1.5)))
+	if product := unbox(t, value, "java/lang/Double"); product != 4.5 {
+		t.Errorf("Method.invoke(): Expected scale(3, 1.5) to return 4.5, got: %v", product)
+	}
+
+	_, exc = callReflectionMethod(t, "java/lang/reflect/Method", "invoke", invoke,
+		getDeclaredMethod("fail"), counter, argArray())
+	if exc == nil || *exc.Klass != "java/lang/reflect/InvocationTargetException" {
+		t.Fatalf("Method.invoke(): Expected an InvocationTargetException, got: %v", exc)
+	}
+	if target, _ := classloader.GetObjectField(exc, "target"); *target.Fvalue.(*object.Object).Klass !=
+		"java/lang/NullPointerException" {
+		t.Errorf("Method.invoke(): Expected the target to be a NullPointerException, got: %v", target.Fvalue)
+	}
+
+	illegalArgs := map[string][]interface{}{
+		"argument type mismatch":                   {add, counter, argArray(box("java/lang/Long", int64(3)))},
+		"wrong number of arguments: 0 expected: 1": {add, counter, argArray()},
+		"object is not an instance of declaring class": {add, box("java/lang/Integer", int64(1)),
+			argArray(box("java/lang/Integer", int64(3)))},
+	}
+	for msg, args := range illegalArgs {
+		_, exc = callReflectionMethod(t, "java/lang/reflect/Method", "invoke", invoke, args...)
+		if exc == nil || *exc.Klass != "java/lang/IllegalArgumentException" || getThrowableMessage(exc) != msg {
+			t.Errorf("Method.invoke(): Expected java.lang.IllegalArgumentException: %s, got: %v", msg, exc)
+		}
+	}
+}
+
+// Field.get() and Field.set() access instance fields in the object and static fields in
+// the statics table, boxing and unboxing the values
+func TestReflectionFieldAccess(t *testing.T) {
+	setupReflectionTest()
+	getDeclaredField := func(name string) *object.Object {
+		fld, _ := callReflectionMethod(t, "java/lang/Class", "getDeclaredField",
+			"(Ljava/lang/String;)Ljava/lang/reflect/Field;", classloader.GetClassMirror("Counter"), goStr(name))
+		return fld.(*object.Object)
+	}
+	get, set := "(Ljava/lang/Object;)Ljava/lang/Object;", "(Ljava/lang/Object;Ljava/lang/Object;)V"
+
+	counterName := "Counter"
+	counter := object.MakeEmptyObject()
+	counter.Klass = &counterName
+	counter.Fields = []object.Field{{Ftype: types.Int, Fvalue: int64(5)}}
+	counter.LaidOut = true
+
+	count := getDeclaredField("count")
+	value, _ := callReflectionMethod(t, "java/lang/reflect/Field", "get", get, count, counter)
+	if n := unbox(t, value, "java/lang/Integer"); n != int64(5) {
+		t.Errorf("Field.get(): Expected count to be 5, got: %v", n)
+	}
+	if _, exc := callReflectionMethod(t, "java/lang/reflect/Field", "set", set, count, counter,
+		box("java/lang/Short", int64(9))); exc != nil || counter.Fields[0].Fvalue != int64(9) {
+		t.Errorf("Field.set(): Expected count to be set to 9, got: %v", counter.Fields[0].Fvalue)
+	}
+	_, exc := callReflectionMethod(t, "java/lang/reflect/Field", "set", set, count, counter, goStr("nine"))
+	if msg := "Can not set int field Counter.count to java.lang.String"; exc == nil ||
+		*exc.Klass != "java/lang/IllegalArgumentException" || getThrowableMessage(exc) != msg {
+		t.Errorf("Field.set(): Expected java.lang.IllegalArgumentException: %s, got: %v", msg, exc)
+	}
+
+	// the class is initialized before its static field is accessed
+	total := getDeclaredField("total")
+	value, _ = callReflectionMethod(t, "java/lang/reflect/Field", "get", get, total, object.Null)
+	if n := unbox(t, value, "java/lang/Long"); n != int64(0) {
+		t.Errorf("Field.get(): Expected total to be 0, got: %v", n)
+	}
+	_, _ = callReflectionMethod(t, "java/lang/reflect/Field", "set", set, total, object.Null,
+		box("java/lang/Long", int64(7)))
+	if static, _ := classloader.FetchStatic("Counter.total"); static.Value != int64(7) {
+		t.Errorf("Field.set(): Expected total to be set to 7, got: %v", static.Value)
+	}
+}
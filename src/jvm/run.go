@@ -12,7 +12,6 @@ import (
 	"errors"
 	"fmt"
 	"jacobin/classloader"
-	"jacobin/exceptions"
 	"jacobin/frames"
 	"jacobin/globals"
 	"jacobin/log"
@@ -35,11 +34,11 @@ var MainThread thread.ExecThread
 // in the method area (it's guaranteed to already be loaded), grabs the executable
 // bytes, creates a thread of execution, pushes the main() frame onto the JVM stack
 // and begins execution.
-func StartExec(className string, mainThread *thread.ExecThread, globals *globals.Globals) error {
+func StartExec(className string, mainThread *thread.ExecThread, gl *globals.Globals) error {
 
 	// set tracing, if any
 	tracing := false
-	trace, exists := globals.Options["-trace"]
+	trace, exists := gl.Options["-trace"]
 	if exists {
 		tracing = trace.Set
 	}
@@ -53,9 +52,12 @@ func StartExec(className string, mainThread *thread.ExecThread, globals *globals
 	m := me.Meth.(classloader.JmEntry)
 	f := frames.CreateFrame(m.MaxStack) // create a new frame
 	f.MethName = "main"
+	f.MethType = "([Ljava/lang/String;)V"
 	f.ClName = className
 	f.CP = m.Cp                        // add its pointer to the class CP
 	f.Meth = append(f.Meth, m.Code...) // copy the bytecodes over
+	f.ExcTable = m.Exceptions          // the exception handlers, if any
+	f.LineNums = m.LineNumbers         // the source line numbers, if any
 
 	// allocate the local variables
 	for k := 0; k < m.MaxLocals; k++ {
@@ -65,14 +67,20 @@ func StartExec(className string, mainThread *thread.ExecThread, globals *globals
 	// create the first thread and place its first frame on it
 	MainThread = *mainThread
 	MainThread.Stack = frames.CreateFrameStack()
-	MainThread.ID = thread.AddThreadToTable(&MainThread, &globals.Threads)
+	MainThread.MaxFrameDepth = gl.MaxFrameDepth
+	MainThread.ID = thread.AddThreadToTable(&MainThread, &globals.GetGlobalRef().Threads)
 	MainThread.Trace = tracing
+	f.Thread = MainThread.ID
 
-	// must first instantiate the class, so that any static initializers are run
-	_, instantiateError := instantiateClass(className, MainThread.Stack)
-	if instantiateError != nil {
+	// the class must be initialized before main() runs
+	exc, initError := initializeClass(className, MainThread.Stack)
+	if initError != nil {
 		return errors.New("Error instantiating: " + className + ".main()")
 	}
+	if exc != nil {
+		reportUncaughtException(getThreadName(MainThread.ID), exc)
+		return errors.New("uncaught exception: " + javaClassName(*exc.Klass))
+	}
 
 	if frames.PushFrame(MainThread.Stack, f) != nil {
 		_ = log.Log("Memory exceptions allocating frame on thread: "+strconv.Itoa(MainThread.ID),
@@ -86,7 +94,13 @@ func StartExec(className string, mainThread *thread.ExecThread, globals *globals
 		_ = log.Log(traceInfo, log.TRACE_INST)
 	}
 
+	// main is a thread like the others, so threads that join it wait until it ends
+	MainThread.Started()
 	err = runThread(&MainThread)
+	MainThread.Ended()
+
+	// as in the JDK, the program ends only when all the threads that are not daemons have ended
+	thread.WaitForNonDaemonThreads()
 	if err != nil {
 		return err
 	}
@@ -158,8 +172,22 @@ func runFrame(fs *list.List) error {
 	}
 
 	// the frame's method is not a golang method, so it's Java bytecode, which
-	// is interpreted in the rest of this function.
-	for f.PC < len(f.Meth) {
+	// is interpreted in the rest of this function. Methods invoked from this
+	// frame are not run by a recursive call to runFrame(). Rather, their frames
+	// are pushed and executed in this same loop, and when they return, execution
+	// resumes in the frame of the caller. baseDepth is the depth of the frame
+	// stack for the frame that runFrame() was called to run. When that frame
+	// returns, so does runFrame().
+	baseDepth := fs.Len()
+	for {
+		if f.PC >= len(f.Meth) { // running off the end of the bytecode is a void return
+			var err error
+			if f, err = exitFrame(fs, baseDepth); f == nil {
+				return err
+			}
+			continue
+		}
+
 		if MainThread.Trace && f.Meth[f.PC] != IMPDEP2 {
 			traceInfo := emitTraceData(f)
 			_ = log.Log(traceInfo, log.TRACE_INST)
@@ -231,6 +259,19 @@ func runFrame(fs *list.List) error {
 			f.PC += 1
 
 			CPe := FetchCPentry(f.CP, int(idx))
+			if CPe.entryType == classloader.ClassRef { // push the mirror of the class
+				mirror, err := classloader.LoadClassMirror(*CPe.stringVal)
+				if err != nil {
+					handlerFrame, err := throwResolutionError(fs, err)
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue
+				}
+				push(f, mirror)
+				break
+			}
 			if CPe.entryType != 0 && // 0 = error
 				// Note: an invalid CP entry causes a java.lang.Verify error and
 				//       is caught before execution of the program begins.
@@ -255,15 +296,32 @@ func runFrame(fs *list.List) error {
 					push(f, stringAddr)
 				}
 			} else { // TODO: Determine what exception to throw
-				exceptions.Throw(exceptions.InaccessibleObjectException,
+				handlerFrame, err := throwNewException(fs, "java/lang/reflect/InaccessibleObjectException",
 					"Invalid type for LDC instruction")
-				return errors.New("LDC: invalid type")
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 		case LDC_W: // 	0x13	(push constant from CP indexed by next two bytes)
 			idx := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2])
 			f.PC += 2
 
 			CPe := FetchCPentry(f.CP, idx)
+			if CPe.entryType == classloader.ClassRef { // push the mirror of the class
+				mirror, err := classloader.LoadClassMirror(*CPe.stringVal)
+				if err != nil {
+					handlerFrame, err := throwResolutionError(fs, err)
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue
+				}
+				push(f, mirror)
+				break
+			}
 			if CPe.entryType != 0 && // this instruction does not load longs or doubles
 				CPe.entryType != classloader.DoubleConst &&
 				CPe.entryType != classloader.LongConst { // if no error
@@ -288,9 +346,13 @@ func runFrame(fs *list.List) error {
 					push(f, stringAddr)
 				}
 			} else { // TODO: Determine what exception to throw
-				errMsg := "LDC_W: Invalid type for instruction"
-				exceptions.Throw(exceptions.InaccessibleObjectException, errMsg)
-				return errors.New(errMsg)
+				handlerFrame, err := throwNewException(fs, "java/lang/reflect/InaccessibleObjectException",
+					"Invalid type for instruction")
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 		case LDC2_W: // 0x14 	(push long or double from CP indexed by next two bytes)
 			idx := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2])
@@ -304,9 +366,13 @@ func runFrame(fs *list.List) error {
 				push(f, CPe.floatVal)
 				push(f, CPe.floatVal)
 			} else { // TODO: Determine what exception to throw
-				errMsg := "LDC2_W: Invalid type for LDC2_W instruction"
-				exceptions.Throw(exceptions.InaccessibleObjectException, errMsg)
-				return errors.New(errMsg)
+				handlerFrame, err := throwNewException(fs, "java/lang/reflect/InaccessibleObjectException",
+					"Invalid type for LDC2_W instruction")
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 		case ILOAD, // 0x15	(push int from local var, using next byte as index)
 			FLOAD, //  0x17 (push float from local var, using next byte as index)
@@ -381,17 +447,24 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			iAref := pop(f).(*object.Object) // ptr to array object
 			if iAref == object.Null {
-				errMsg := "I/C/SALOAD: Invalid (null) reference to an array"
-				exceptions.Throw(exceptions.NullPointerException, errMsg)
-				return errors.New(errMsg)
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
 			array := *(iAref.Fields[0].Fvalue).(*[]int64)
 
-			if index >= int64(len(array)) {
-				errMsg := "IALOAD: Invalid array subscript"
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException, errMsg)
-				return errors.New(errMsg)
+			if index < 0 || index >= int64(len(array)) {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayIndexOutOfBoundsException",
+					arrayIndexMessage(index, len(array)))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 			var value = array[index]
 			push(f, value)
@@ -399,16 +472,23 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			iAref := pop(f).(*object.Object) // ptr to array object
 			if iAref == nil {
-				errMsg := "LALOAD: Invalid (null) reference to an array"
-				exceptions.Throw(exceptions.NullPointerException, errMsg)
-				return errors.New(errMsg)
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
 			array := *(iAref.Fields[0].Fvalue).(*[]int64)
-			if index >= int64(len(array)) {
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"LALOAD: Invalid array subscript")
-				return errors.New("LALOAD error")
+			if index < 0 || index >= int64(len(array)) {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayIndexOutOfBoundsException",
+					arrayIndexMessage(index, len(array)))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 			var value = array[index]
 			push(f, value)
@@ -419,17 +499,24 @@ func runFrame(fs *list.List) error {
 			ref := pop(f) // ptr to array object
 			// fAref := (*object.JacobinFloatArray)(ref)
 			if ref == nil || ref == object.Null {
-				errMsg := "FALOAD: Invalid (null) reference to an array"
-				exceptions.Throw(exceptions.NullPointerException, errMsg)
-				return errors.New(errMsg)
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
 			fAref := ref.(*object.Object)
 			array := *(fAref.Fields[0].Fvalue).(*[]float64)
-			if index >= int64(len(array)) {
-				errMsg := "FALOAD: Invalid array subscript"
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException, errMsg)
-				return errors.New(errMsg)
+			if index < 0 || index >= int64(len(array)) {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayIndexOutOfBoundsException",
+					arrayIndexMessage(index, len(array)))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 			var value = array[index]
 			push(f, value)
@@ -438,16 +525,23 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			fAref := pop(f).(*object.Object) // ptr to array object
 			if fAref == nil {
-				errMsg := "DALOAD: Invalid (null) reference to an array"
-				exceptions.Throw(exceptions.NullPointerException, errMsg)
-				return errors.New(errMsg)
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 			array := *(fAref.Fields[0].Fvalue).(*[]float64)
 
-			if index >= int64(len(array)) {
-				errMsg := "DALOAD: Invalid array subscript"
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException, errMsg)
-				return errors.New(errMsg)
+			if index < 0 || index >= int64(len(array)) {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayIndexOutOfBoundsException",
+					arrayIndexMessage(index, len(array)))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 			var value = array[index]
 			push(f, value)
@@ -455,18 +549,25 @@ func runFrame(fs *list.List) error {
 		case AALOAD: // 0x32    (push contents of a reference array element)
 			index := pop(f).(int64)
 			rAref := pop(f) // the array object. Can't be cast to *Object b/c might be nil
-			if rAref == nil {
-				errMsg := "AALOAD: Invalid (null) reference to an array"
-				exceptions.Throw(exceptions.NullPointerException, errMsg)
-				return errors.New(errMsg)
+			if rAref == nil || rAref == object.Null {
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
 			arrayPtr := (rAref.(*object.Object)).Fields[0].Fvalue.(*[]*object.Object)
 			size := int64(len(*arrayPtr))
-			if index >= size {
-				errMsg := "AALOAD: Invalid array subscript"
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException, errMsg)
-				return errors.New(errMsg)
+			if index < 0 || index >= size {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayIndexOutOfBoundsException",
+					arrayIndexMessage(index, len(*arrayPtr)))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 			array := *(arrayPtr)
 			var value = array[index]
@@ -476,23 +577,34 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			ref := pop(f) // the array object
 			if ref == nil || ref == object.Null {
-				errMsg := "BALOAD: Invalid (null) reference to an array"
-				exceptions.Throw(exceptions.NullPointerException, errMsg)
-				return errors.New(errMsg)
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
 			bAref := ref.(*object.Object)
 			arrayPtr := bAref.Fields[0].Fvalue.(*[]byte)
 			size := int64(len(*arrayPtr))
 
-			if index >= size {
-				errMsg := "BALOAD: Invalid array subscript"
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException, errMsg)
-				return errors.New(errMsg)
+			if index < 0 || index >= size {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayIndexOutOfBoundsException",
+					arrayIndexMessage(index, len(*arrayPtr)))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 			array := *(arrayPtr)
 			var value = array[index]
-			push(f, int64(value))
+			if bAref.Fields[0].Ftype == types.BoolArray {
+				push(f, int64(value))
+			} else { // bytes are signed
+				push(f, int64(int8(value)))
+			}
 
 		case ISTORE, //  0x36 	(store popped top of stack int into local[index])
 			LSTORE: //  0x37 (store popped top of stack long into local[index])
@@ -581,25 +693,47 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			arrObj := pop(f).(*object.Object) // the array object
 			if arrObj == nil {
-				exceptions.Throw(exceptions.NullPointerException,
-					"IA/CA/SASTORE: Invalid (null) reference to an array")
-				return errors.New("IA/CA/SASTORE: Invalid array address")
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
-			if arrObj.Fields[0].Ftype != "[I" {
-				errMsg := fmt.Sprintf("IA/CA/SASTORE: field type expected=[I, observed=%s", arrObj.Fields[0].Ftype)
-				_ = log.Log(errMsg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayStoreException, errMsg)
-				return errors.New(errMsg)
+			// the value is narrowed to the type of the array's elements
+			var arrType string
+			switch f.Meth[f.PC] {
+			case IASTORE:
+				arrType = types.IntArray
+				value = int64(int32(value))
+			case CASTORE:
+				arrType = types.CharArray
+				value = int64(uint16(value))
+			case SASTORE:
+				arrType = types.ShortArray
+				value = int64(int16(value))
+			}
+			if arrObj.Fields[0].Ftype != arrType {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayStoreException",
+					javaTypeName(arrType[1:]))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
 			array := *(arrObj.Fields[0].Fvalue).(*[]int64)
 			size := int64(len(array))
-			if index >= size {
-				errMsg := fmt.Sprintf("IA/CA/SASTORE: array size= %d but array index= %d (too large)", size, index)
-				_ = log.Log(errMsg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException, errMsg)
-				return errors.New(errMsg)
+			if index < 0 || index >= size {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayIndexOutOfBoundsException",
+					arrayIndexMessage(index, int(size)))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 			array[index] = value
 
@@ -609,58 +743,70 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			lAref := pop(f).(*object.Object) // ptr to array object
 			if lAref == nil {
-				errMsg := fmt.Sprintf("LASTORE: Invalid (null) reference to an array")
-				exceptions.Throw(exceptions.NullPointerException, errMsg)
-				return errors.New(errMsg)
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
 			arrType := lAref.Fields[0].Ftype
 
-			if arrType != "[I" {
-				msg := fmt.Sprintf("LASTORE: field type expected=[I, observed=%s", arrType)
-				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayStoreException,
-					"LASTORE: Attempt to access array of incorrect type")
-				return errors.New("LASTORE: Invalid array type")
+			if arrType != types.LongArray {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayStoreException", "long")
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
 			array := *(lAref.Fields[0].Fvalue).(*[]int64)
 			size := int64(len(array))
-			if index >= size {
-				msg := fmt.Sprintf("LASTORE: array size=%d but index=%d (too large)", size, index)
-				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"LASTORE: Invalid array subscript")
-				return errors.New("LASTORE: Invalid array index")
+			if index < 0 || index >= size {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayIndexOutOfBoundsException",
+					arrayIndexMessage(index, int(size)))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 			array[index] = value
 
 		case FASTORE: // 0x51	(store a float in a float array)
-			value := pop(f).(float64)
+			value := float64(float32(pop(f).(float64))) // rounded to a float
 			index := pop(f).(int64)
 			fAref := pop(f).(*object.Object) // ptr to array object
 			if fAref == nil {
-				exceptions.Throw(exceptions.NullPointerException,
-					"FASTORE: Invalid (null) reference to an array")
-				return errors.New("FASTORE: Invalid array address")
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
-			if fAref.Fields[0].Ftype != "[F" {
-				msg := fmt.Sprintf("FASTORE: field type expected=[F, observed=%s", fAref.Fields[0].Ftype)
-				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayStoreException,
-					"FASTORE: Attempt to access array of incorrect type")
-				return errors.New("FASTORE: Invalid array type")
+			if fAref.Fields[0].Ftype != types.FloatArray {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayStoreException", "float")
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
 			array := *(fAref.Fields[0].Fvalue).(*[]float64)
 			size := int64(len(array))
-			if index >= size {
-				msg := fmt.Sprintf("FASTORE: array size=%d but index=%d (too large)", size, index)
-				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"FASTORE: Invalid array subscript")
-				return errors.New("FASTORE: Invalid array index")
+			if index < 0 || index >= size {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayIndexOutOfBoundsException",
+					arrayIndexMessage(index, int(size)))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 			array[index] = value
 
@@ -670,27 +816,33 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			dAref := pop(f).(*object.Object)
 			if dAref == nil {
-				exceptions.Throw(exceptions.NullPointerException,
-					"DASTORE: Invalid (null) reference to an array")
-				return errors.New("DASTORE: Invalid array reference")
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
-			if dAref.Fields[0].Ftype != "[F" {
-				msg := fmt.Sprintf("DASTORE: field type expected=[F, observed=%s", dAref.Fields[0].Ftype)
-				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayStoreException,
-					"DASTORE: Attempt to access array of incorrect type")
-				return errors.New("DASTORE: Invalid array type")
+			if dAref.Fields[0].Ftype != types.DoubleArray {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayStoreException", "double")
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
 			array := *(dAref.Fields[0].Fvalue).(*[]float64)
 			size := int64(len(array))
-			if index >= size {
-				msg := fmt.Sprintf("DASTORE: array size=%d but index=%d (too large)", size, index)
-				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"DASTORE: Invalid array subscript")
-				return errors.New("DASTORE: Invalid array index")
+			if index < 0 || index >= size {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayIndexOutOfBoundsException",
+					arrayIndexMessage(index, int(size)))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
 			array[index] = value
@@ -701,28 +853,54 @@ func runFrame(fs *list.List) error {
 			ptrObj := pop(f).(*object.Object) // ptr to the array object
 
 			if ptrObj == nil {
-				exceptions.Throw(exceptions.NullPointerException,
-					"AASTORE: Invalid (null) reference to an array")
-				return errors.New("AASTORE: Invalid array address")
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
-			if ptrObj.Fields[0].Ftype != "[L" {
-				msg := fmt.Sprintf("AASTORE: field type expected=[L, observed=%s", ptrObj.Fields[0].Ftype)
-				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayStoreException,
-					"AASTORE: Attempt to access array of incorrect type")
-				return errors.New("AASTORE: Invalid array type")
+			// get pointer to the actual array
+			arrayPtr, ok := ptrObj.Fields[0].Fvalue.(*[]*object.Object)
+			if !ok { // not an array of references
+				valueClass := "java/lang/Object"
+				if value != nil && value != object.Null && value.Klass != nil && *value.Klass != "" {
+					valueClass = *value.Klass
+				}
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayStoreException",
+					javaClassName(valueClass))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
-			// get pointer to the actual array
-			arrayPtr := ptrObj.Fields[0].Fvalue.(*[]*object.Object)
 			size := int64(len(*arrayPtr))
-			if index >= size {
-				msg := fmt.Sprintf("AASTORE: array size=%d but index=%d (too large)", size, index)
-				_ = log.Log(msg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException,
-					"AASTORE: Invalid array subscript")
-				return errors.New("AASTORE: Invalid array index")
+			if index < 0 || index >= size {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayIndexOutOfBoundsException",
+					arrayIndexMessage(index, int(size)))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
+			}
+
+			// the value must be assignable to the array's component type. (Objects that
+			// Jacobin creates without a class can't be checked.)
+			if value != nil && value != object.Null && value.Klass != nil && *value.Klass != "" {
+				componentClass := componentClassName(ptrObj.Fields[0].Ftype[1:])
+				if !isAssignableTo(*value.Klass, componentClass) {
+					handlerFrame, err := throwNewException(fs, "java/lang/ArrayStoreException",
+						javaClassName(*value.Klass))
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue
+				}
 			}
 
 			array := *arrayPtr
@@ -735,27 +913,38 @@ func runFrame(fs *list.List) error {
 			index := pop(f).(int64)
 			ptrObj := pop(f).(*object.Object) // ptr to array object
 			if ptrObj == nil {
-				errMsg := "BASTORE: Invalid (null) reference to an array"
-				exceptions.Throw(exceptions.NullPointerException, errMsg)
-				return errors.New(errMsg)
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
-			if ptrObj.Fields[0].Ftype != "[B" {
-				errMsg := fmt.Sprintf("BASTORE: Attempt to access array of incorrect type, expected=[B, observed=%s",
-					ptrObj.Fields[0].Ftype)
-				_ = log.Log(errMsg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayStoreException, errMsg)
-				return errors.New(errMsg)
+			switch ptrObj.Fields[0].Ftype {
+			case types.ByteArray:
+			case types.BoolArray: // a boolean is stored as its lowest bit (JVMS, BASTORE)
+				value &= 0x01
+			default:
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayStoreException", "byte")
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
 			// array := *(ptrObj.Fields[0].Fvalue.(*[]types.JavaByte)) // changed w/ JACOBIN-282
 			array := *(ptrObj.Fields[0].Fvalue.(*[]byte))
 			size := int64(len(array))
-			if index >= size {
-				errMsg := fmt.Sprintf("BASTORE: Invalid array subscript: %d (size=%d) ", index, size)
-				_ = log.Log(errMsg, log.SEVERE)
-				exceptions.Throw(exceptions.ArrayIndexOutOfBoundsException, errMsg)
-				return errors.New(errMsg)
+			if index < 0 || index >= size {
+				handlerFrame, err := throwNewException(fs, "java/lang/ArrayIndexOutOfBoundsException",
+					arrayIndexMessage(index, int(size)))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 			array[index] = value
 
@@ -831,8 +1020,8 @@ func runFrame(fs *list.List) error {
 		case IADD: //  0x60		(add top 2 integers on operand stack, push result)
 			i2 := pop(f).(int64)
 			i1 := pop(f).(int64)
-			sum := add(i1, i2)
-			push(f, sum)
+			sum := int32(i1) + int32(i2) // ints wrap around at 32 bits
+			push(f, int64(sum))
 		case LADD: //  0x61     (add top 2 longs on operand stack, push result)
 			l2 := pop(f).(int64) //    longs occupy two slots, hence double pushes and pops
 			pop(f)
@@ -856,8 +1045,8 @@ func runFrame(fs *list.List) error {
 		case ISUB: //  0x64	(subtract top 2 integers on operand stack, push result)
 			i2 := pop(f).(int64)
 			i1 := pop(f).(int64)
-			diff := subtract(i1, i2)
-			push(f, diff)
+			diff := int32(i1) - int32(i2)
+			push(f, int64(diff))
 		case LSUB: //  0x65 (subtract top 2 longs on operand stack, push result)
 			i2 := pop(f).(int64) //    longs occupy two slots, hence double pushes and pops
 			pop(f)
@@ -882,9 +1071,9 @@ func runFrame(fs *list.List) error {
 		case IMUL: //  0x68  	(multiply 2 integers on operand stack, push result)
 			i2 := pop(f).(int64)
 			i1 := pop(f).(int64)
-			product := multiply(i1, i2)
+			product := int32(i1) * int32(i2)
 
-			push(f, product)
+			push(f, int64(product))
 		case LMUL: //  0x69     (multiply 2 longs on operand stack, push result)
 			l2 := pop(f).(int64) //    longs occupy two slots, hence double pushes and pops
 			pop(f)
@@ -909,20 +1098,26 @@ func runFrame(fs *list.List) error {
 		case IDIV: //  0x6C (integer divide tos-1 by tos)
 			val1 := pop(f).(int64)
 			if val1 == 0 {
-				exceptions.Throw(exceptions.ArithmeticException, ""+
-					"IDIV: Arithmetic Exception: divide by zero")
-				return errors.New("IDIV: Arithmetic Exception: divide by zero")
+				handlerFrame, err := throwNewException(fs, "java/lang/ArithmeticException", "/ by zero")
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			} else {
 				val2 := pop(f).(int64)
-				push(f, val2/val1)
+				push(f, int64(int32(val2)/int32(val1))) // MIN_VALUE / -1 overflows to MIN_VALUE
 			}
 		case LDIV: //  0x6D   (long divide tos-2 by tos)
 			val2 := pop(f).(int64)
 			pop(f) //    longs occupy two slots, hence double pushes and pops
 			if val2 == 0 {
-				exceptions.Throw(exceptions.ArithmeticException, ""+
-					"LDIV: Arithmetic Exception: divide by zero")
-				return errors.New("LDIV: Divide by zero")
+				handlerFrame, err := throwNewException(fs, "java/lang/ArithmeticException", "/ by zero")
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			} else {
 				val1 := pop(f).(int64)
 				pop(f)
@@ -932,56 +1127,44 @@ func runFrame(fs *list.List) error {
 			}
 
 		case FDIV: // 0x6E
+			// division by zero follows IEEE 754, as golang does: the result
+			// is NaN, or an infinity whose sign is that of the quotient
 			val1 := pop(f).(float64)
 			val2 := pop(f).(float64)
-			if val1 == 0.0 {
-				if val2 == 0.0 {
-					push(f, math.NaN())
-				} else if math.Signbit(val1) { // this test for negative zero
-					push(f, math.Inf(-1)) // but alas there is no -0 in golang (as of 1.20)
-				} else {
-					push(f, math.Inf(1))
-				}
-			} else {
-				push(f, float64(float32(val2)/float32(val1)))
-			}
+			push(f, float64(float32(val2)/float32(val1)))
 
 		case DDIV: // 0x6F
 			val1 := pop(f).(float64)
 			pop(f)
 			val2 := pop(f).(float64)
 			pop(f)
-			if val1 == 0.0 {
-				if val2 == 0.0 {
-					push(f, math.NaN())
-				} else if math.Signbit(val1) { // this tests for negative zero
-					push(f, math.Inf(-1)) // but golang has no -0 as of v. 1.20
-				} else {
-					push(f, math.Inf(1))
-				}
-			} else {
-				res := val2 / val1
-				push(f, res)
-				push(f, res)
-			}
+			res := val2 / val1 // as in FDIV, division by zero follows IEEE 754
+			push(f, res)
+			push(f, res)
 		case IREM: // 	0x70	(remainder after int division, modulo)
 			val2 := pop(f).(int64)
 			if val2 == 0 {
-				errMsg := "IREM: Arithmetic Exception: divide by zero"
-				exceptions.Throw(exceptions.ArithmeticException, errMsg)
-				return errors.New(errMsg)
+				handlerFrame, err := throwNewException(fs, "java/lang/ArithmeticException", "/ by zero")
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			} else {
 				val1 := pop(f).(int64)
-				res := val1 % val2
-				push(f, res)
+				res := int32(val1) % int32(val2)
+				push(f, int64(res))
 			}
 		case LREM: // 	0x71	(remainder after long division)
 			val2 := pop(f).(int64)
 			pop(f) //    longs occupy two slots, hence double pushes and pops
 			if val2 == 0 {
-				errMsg := "LREM: Arithmetic Exception: divide by zero"
-				exceptions.Throw(exceptions.ArithmeticException, errMsg)
-				return errors.New(errMsg)
+				handlerFrame, err := throwNewException(fs, "java/lang/ArithmeticException", "/ by zero")
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			} else {
 				val1 := pop(f).(int64)
 				pop(f)
@@ -990,20 +1173,22 @@ func runFrame(fs *list.List) error {
 				push(f, res)
 			}
 		case FREM: // 0x72
+			// Java's remainder truncates the quotient, as C's fmod() does,
+			// rather than rounding it, as IEEE 754's remainder does
 			val2 := pop(f).(float64)
 			val1 := pop(f).(float64)
-			push(f, float64(float32(math.Remainder(val1, val2))))
+			push(f, float64(float32(math.Mod(val1, val2))))
 		case DREM: // 0x73
 			val2 := pop(f).(float64)
 			pop(f)
 			val1 := pop(f).(float64)
 			pop(f)
-			drem := math.Remainder(val1, val2)
+			drem := math.Mod(val1, val2)
 			push(f, drem)
 			push(f, drem)
 		case INEG: //	0x74 	(negate an int)
 			val := pop(f).(int64)
-			push(f, -val)
+			push(f, int64(-int32(val))) // -MIN_VALUE overflows to MIN_VALUE
 		case LNEG: //   0x75	(negate a long)
 			val := pop(f).(int64)
 			pop(f) // pop a second time because it's a long, which occupies 2 slots
@@ -1022,13 +1207,7 @@ func runFrame(fs *list.List) error {
 		case ISHL: //	0x78 	(shift int left)
 			shiftBy := pop(f).(int64)
 			val1 := pop(f).(int64)
-			var val2 int64
-			if val1 < 0 { // if neg, shift as pos, then make neg
-				val2 = (-val1) << (shiftBy & 0x1F) // only the bottom five bits are used
-				push(f, -val2)
-			} else {
-				push(f, val1<<(shiftBy&0x1F))
-			}
+			push(f, int64(int32(val1)<<(shiftBy&0x1F))) // only the bottom five bits are used
 
 		case LSHL: // 	0x79	(shift value1 (long) left by value2 (int) bits)
 			shiftBy := pop(f).(int64)
@@ -1038,18 +1217,11 @@ func runFrame(fs *list.List) error {
 			val3 := val1 << ushiftBy
 			push(f, val3)
 			push(f, val3)
-		case ISHR: //  0x7A	(shift int value right)
+		case ISHR: //  0x7A	(shift int value right, extending the sign)
 			shiftBy := pop(f).(int64)
 			val1 := pop(f).(int64)
-			var val2 int64
-			if val1 < 0 { // if neg, shift as pos, then make neg
-				val2 = (-val1) >> (shiftBy & 0x1F) // only the bottom five bits are used
-				push(f, -val2)
-			} else {
-				push(f, val1>>(shiftBy&0x1F))
-			}
-		case LSHR, // 	0x7B	(shift value1 (long) right by value2 (int) bits)
-			LUSHR: // 	0x70
+			push(f, int64(int32(val1)>>(shiftBy&0x1F))) // only the bottom five bits are used
+		case LSHR: // 	0x7B	(shift value1 (long) right by value2 (int) bits, extending the sign)
 			shiftBy := pop(f).(int64)
 			ushiftBy := uint64(shiftBy) & 0x3f // must be unsigned in golang; 0-63 bits per JVM
 			val1 := pop(f).(int64)
@@ -1057,13 +1229,18 @@ func runFrame(fs *list.List) error {
 			val3 := val1 >> ushiftBy
 			push(f, val3)
 			push(f, val3)
-		case IUSHR: // 0x7C (unsigned shift right of int)
-			shiftBy := pop(f).(int64) // TODO: verify the result against JDK
+		case IUSHR: // 0x7C (unsigned shift right of int, shifting in zeros)
+			shiftBy := pop(f).(int64)
 			val1 := pop(f).(int64)
-			if val1 < 0 {
-				val1 = -val1
-			}
-			push(f, val1>>(shiftBy&0x1F)) // only the bottom five bits are used
+			push(f, int64(int32(uint32(val1)>>(shiftBy&0x1F)))) // only the bottom five bits are used
+		case LUSHR: // 	0x7D	(unsigned shift right of long, shifting in zeros)
+			shiftBy := pop(f).(int64)
+			ushiftBy := uint64(shiftBy) & 0x3f
+			val1 := pop(f).(int64)
+			pop(f)
+			val3 := int64(uint64(val1) >> ushiftBy)
+			push(f, val3)
+			push(f, val3)
 		case IAND: //	0x7E	(logical and of two ints, push result)
 			val1 := pop(f).(int64)
 			val2 := pop(f).(int64)
@@ -1105,11 +1282,11 @@ func runFrame(fs *list.List) error {
 			wbyte := f.Meth[f.PC+2]
 			increment := byteToInt64(wbyte)
 			orig := f.Locals[localVarIndex].(int64)
-			f.Locals[localVarIndex] = orig + increment
+			f.Locals[localVarIndex] = int64(int32(orig + increment))
 			f.PC += 2
 		case I2F: //	0x86 	( convert int to float)
 			intVal := pop(f).(int64)
-			push(f, float64(intVal))
+			push(f, float64(float32(intVal))) // rounded to the nearest float
 		case I2L: // 	0x85     (convert int to long)
 			// 	ints are already 64-bits, so this just pushes a second instance
 			val := peek(f).(int64) // look without popping
@@ -1142,7 +1319,7 @@ func runFrame(fs *list.List) error {
 			fallthrough
 		case F2I: // 0x8B
 			floatVal := pop(f).(float64)
-			push(f, int64(math.Trunc(floatVal)))
+			push(f, floatToInt(floatVal, math.MinInt32, math.MaxInt32))
 		case F2D: // 0x8D
 			floatVal := pop(f).(float64)
 			push(f, floatVal)
@@ -1152,7 +1329,7 @@ func runFrame(fs *list.List) error {
 			fallthrough
 		case F2L: // 	0x8C convert float to long
 			floatVal := pop(f).(float64)
-			truncated := int64(math.Trunc(floatVal))
+			truncated := floatToInt(floatVal, math.MinInt64, math.MaxInt64)
 			push(f, truncated)
 			push(f, truncated)
 
@@ -1162,12 +1339,8 @@ func runFrame(fs *list.List) error {
 			push(f, float64(floatVal))
 		case I2B: //	0x91 convert into to byte preserving sign
 			intVal := pop(f).(int64)
-			byteVal := intVal & 0xFF
-			if !(intVal > 0 && byteVal > 0) &&
-				!(intVal < 0 && byteVal < 0) {
-				byteVal = -byteVal
-			}
-			push(f, byteVal)
+			byteVal := int8(intVal) // the low 8 bits, sign-extended
+			push(f, int64(byteVal))
 		case I2C: //	0x92 convert to 16-bit char
 			// determine what happens in Java if the int is negative
 			intVal := pop(f).(int64)
@@ -1175,7 +1348,7 @@ func runFrame(fs *list.List) error {
 			push(f, int64(charVal))
 		case I2S: //	0x93 convert int to short
 			intVal := pop(f).(int64)
-			shortVal := int32(intVal)
+			shortVal := int16(intVal)
 			push(f, int64(shortVal))
 		case LCMP: // 	0x94 (compare two longs, push int -1, 0, or 1, depending on result)
 			value2 := pop(f).(int64)
@@ -1386,38 +1559,98 @@ func runFrame(fs *list.List) error {
 		case GOTO: // 0xA7     (goto an instruction)
 			jumpTo := (int16(f.Meth[f.PC+1]) * 256) + int16(f.Meth[f.PC+2])
 			f.PC = f.PC + int(jumpTo) - 1 // -1 because this loop will increment f.PC by 1
+		case TABLESWITCH: // 0xAA jump to the offset in a table indexed by the popped int
+			// the opcode is followed by 0-3 bytes of padding, so that the default offset
+			// begins at an address that's a multiple of 4 from the start of the method.
+			// Then come the default offset, the low and high index values, and the table
+			// of jump offsets. All of these are signed 32-bit values. The offsets are
+			// relative to the address of the opcode.
+			basePC := f.PC
+			loc := basePC + 1 + (3 - (basePC % 4)) // skip over the padding
+			defaultJump := int32(binary.BigEndian.Uint32(f.Meth[loc : loc+4]))
+			low := int64(int32(binary.BigEndian.Uint32(f.Meth[loc+4 : loc+8])))
+			high := int64(int32(binary.BigEndian.Uint32(f.Meth[loc+8 : loc+12])))
+
+			jumpTo := defaultJump
+			index := pop(f).(int64)
+			if index >= low && index <= high {
+				entry := loc + 12 + (int(index-low) * 4)
+				jumpTo = int32(binary.BigEndian.Uint32(f.Meth[entry : entry+4]))
+			}
+			f.PC = basePC + int(jumpTo) - 1 // -1 because this loop will increment f.PC by 1
+		case LOOKUPSWITCH: // 0xAB jump to the offset paired with the popped int (the key)
+			// the layout is the same as for TABLESWITCH up through the default offset,
+			// which is followed by the number of pairs and then the pairs themselves.
+			// Each pair consists of a key and a jump offset. The pairs are sorted by key.
+			basePC := f.PC
+			loc := basePC + 1 + (3 - (basePC % 4)) // skip over the padding
+			defaultJump := int32(binary.BigEndian.Uint32(f.Meth[loc : loc+4]))
+			npairs := int(int32(binary.BigEndian.Uint32(f.Meth[loc+4 : loc+8])))
+
+			jumpTo := defaultJump
+			key := pop(f).(int64)
+			for i := 0; i < npairs; i++ {
+				pair := loc + 8 + (i * 8)
+				match := int64(int32(binary.BigEndian.Uint32(f.Meth[pair : pair+4])))
+				if match == key {
+					jumpTo = int32(binary.BigEndian.Uint32(f.Meth[pair+4 : pair+8]))
+					break
+				} else if match > key { // the keys are sorted, so there's no need to look further
+					break
+				}
+			}
+			f.PC = basePC + int(jumpTo) - 1 // -1 because this loop will increment f.PC by 1
 		case IRETURN: // 0xAC (return an int and exit current frame)
 			valToReturn := pop(f)
-			f = fs.Front().Next().Value.(*frames.Frame)
-			push(f, valToReturn) // TODO: check what happens when main() ends on IRETURN
-			return nil
+			push(fs.Front().Next().Value.(*frames.Frame), valToReturn)
+			var err error
+			if f, err = exitFrame(fs, baseDepth); f == nil {
+				return err
+			}
+			continue // the caller's PC already points to the bytecode after the invocation
 		case LRETURN: // 0xAD (return a long and exit current frame)
 			valToReturn := pop(f).(int64)
-			f = fs.Front().Next().Value.(*frames.Frame)
-			push(f, valToReturn) // pushed twice b/c a long uses two slots
-			push(f, valToReturn)
-			return nil
+			caller := fs.Front().Next().Value.(*frames.Frame)
+			push(caller, valToReturn) // pushed twice b/c a long uses two slots
+			push(caller, valToReturn)
+			var err error
+			if f, err = exitFrame(fs, baseDepth); f == nil {
+				return err
+			}
+			continue
 		case FRETURN: // 0xAE
 			valToReturn := pop(f).(float64)
-			f = fs.Front().Next().Value.(*frames.Frame)
-			push(f, valToReturn)
-			return nil
+			push(fs.Front().Next().Value.(*frames.Frame), valToReturn)
+			var err error
+			if f, err = exitFrame(fs, baseDepth); f == nil {
+				return err
+			}
+			continue
 		case DRETURN: // 0xAF (return a double and exit current frame)
 			valToReturn := pop(f).(float64)
-			f = fs.Front().Next().Value.(*frames.Frame)
-			push(f, valToReturn) // pushed twice b/c a float uses two slots
-			push(f, valToReturn)
-			return nil
+			caller := fs.Front().Next().Value.(*frames.Frame)
+			push(caller, valToReturn) // pushed twice b/c a double uses two slots
+			push(caller, valToReturn)
+			var err error
+			if f, err = exitFrame(fs, baseDepth); f == nil {
+				return err
+			}
+			continue
 		case ARETURN: // 0xB0	(return a reference)
 			valToReturn := pop(f)
-			// prevFrame := f
-			f = fs.Front().Next().Value.(*frames.Frame)
-			push(f, valToReturn)
-			// fs.PushFront(prevFrame) //
-			return nil
+			push(fs.Front().Next().Value.(*frames.Frame), valToReturn)
+			var err error
+			if f, err = exitFrame(fs, baseDepth); f == nil {
+				return err
+			}
+			continue
 		case RETURN: // 0xB1    (return from void function)
 			f.TOS = -1 // empty the stack
-			return nil
+			var err error
+			if f, err = exitFrame(fs, baseDepth); f == nil {
+				return err
+			}
+			continue
 		case GETSTATIC: // 0xB2		(get static field)
 			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2]) // next 2 bytes point to CP entry
 			f.PC += 2
@@ -1428,44 +1661,54 @@ func runFrame(fs *list.List) error {
 					CPentry.Type, f.PC, f.MethName, f.ClName)
 			}
 
-			// get the field entry
-			field := f.CP.FieldRefs[CPentry.Slot]
-
-			// get the class entry from the field entry for this field. It's the class name.
-			classRef := field.ClassIndex
-			classNameIndex := f.CP.ClassRefs[f.CP.CpIndex[classRef].Slot]
-			classNameEntry := f.CP.CpIndex[classNameIndex]
-			className := f.CP.Utf8Refs[classNameEntry.Slot]
+			// a static field is kept in the Statics table under the class that declares it,
+			// which may be a superclass or a superinterface of the referenced class. The field
+			// reference is resolved to that class the first time, and the class is kept in the
+			// CP entry. The class must be initialized before the field is accessed, but once
+			// it has been, there's nothing more to do.
+			declaringClass, name, err := classloader.StaticFieldRefClass(f.CP, CPentry.Slot)
+			if err != nil {
+				handlerFrame, err := throwResolutionError(fs, err)
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
+			}
+			fieldName := declaringClass + "." + name
 
-			// process the name and type entry for this field
-			nAndTindex := field.NameAndType
-			nAndTentry := f.CP.CpIndex[nAndTindex]
-			nAndTslot := nAndTentry.Slot
-			nAndT := f.CP.NameAndTypes[nAndTslot]
-			fieldNameIndex := nAndT.NameIndex
-			fieldName := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, fieldNameIndex)
-			fieldName = className + "." + fieldName
-
-			// was this static field previously loaded? Is so, get its location and move on.
-			prevLoaded, ok := classloader.Statics[fieldName]
-			if !ok { // if field is not already loaded, then
-				// the class has not been instantiated, so
-				// instantiate the class
-				_, err := instantiateClass(className, fs)
-				if err == nil {
-					prevLoaded, ok = classloader.Statics[fieldName]
-				} else {
-					errMsg := fmt.Sprintf("GETSTATIC: could not load class %s", className)
+			if k := classloader.MethAreaFetch(declaringClass); k != nil && k.Data != nil &&
+				k.Data.ClInit != types.ClInitRun {
+				exc, err := initializeClass(declaringClass, fs)
+				var errBlk *classloader.GErrBlk
+				if errors.As(err, &errBlk) { // the class could not be loaded, e.g., a VerifyError
+					handlerFrame, err := throwResolutionError(fs, errBlk)
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue
+				}
+				if err != nil {
+					errMsg := fmt.Sprintf("GETSTATIC: could not load class %s", declaringClass)
 					_ = log.Log(errMsg, log.SEVERE)
 					return errors.New(errMsg)
 				}
+				if exc != nil {
+					handlerFrame, err := throwException(fs, exc)
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue
+				}
 			}
+			prevLoaded, ok := classloader.FetchStatic(fieldName)
 
 			// if the field can't be found even after instantiating the
 			// containing class, something is wrong so get out of here.
 			if !ok {
-				errMsg := fmt.Sprintf("GETSTATIC: could not find static field %s in class %s"+
-					"\n", fieldName, className)
+				errMsg := fmt.Sprintf("GETSTATIC: could not find static field %s\n", fieldName)
 				_ = log.Log(errMsg, log.SEVERE)
 				return errors.New(errMsg)
 			}
@@ -1508,44 +1751,54 @@ func runFrame(fs *list.List) error {
 				return fmt.Errorf(errMsg)
 			}
 
-			// get the field entry
-			field := f.CP.FieldRefs[CPentry.Slot]
-
-			// get the class entry from the field entry for this field. It's the class name.
-			classRef := field.ClassIndex
-			classNameIndex := f.CP.ClassRefs[f.CP.CpIndex[classRef].Slot]
-			classNameEntry := f.CP.CpIndex[classNameIndex]
-			className := f.CP.Utf8Refs[classNameEntry.Slot]
+			// a static field is kept in the Statics table under the class that declares it,
+			// which may be a superclass or a superinterface of the referenced class. The field
+			// reference is resolved to that class the first time, and the class is kept in the
+			// CP entry. The class must be initialized before the field is accessed, but once
+			// it has been, there's nothing more to do.
+			declaringClass, name, err := classloader.StaticFieldRefClass(f.CP, CPentry.Slot)
+			if err != nil {
+				handlerFrame, err := throwResolutionError(fs, err)
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
+			}
+			fieldName := declaringClass + "." + name
 
-			// process the name and type entry for this field
-			nAndTindex := field.NameAndType
-			nAndTentry := f.CP.CpIndex[nAndTindex]
-			nAndTslot := nAndTentry.Slot
-			nAndT := f.CP.NameAndTypes[nAndTslot]
-			fieldNameIndex := nAndT.NameIndex
-			fieldName := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, fieldNameIndex)
-			fieldName = className + "." + fieldName
-
-			// was this static field previously loaded? Is so, get its location and move on.
-			prevLoaded, ok := classloader.Statics[fieldName]
-			if !ok { // if field is not already loaded, then
-				// the class has not been instantiated, so
-				// instantiate the class
-				_, err := instantiateClass(className, fs)
-				if err == nil {
-					prevLoaded, ok = classloader.Statics[fieldName]
-				} else {
-					errMsg := fmt.Sprintf("PUTSTATIC: could not load class %s", className)
+			if k := classloader.MethAreaFetch(declaringClass); k != nil && k.Data != nil &&
+				k.Data.ClInit != types.ClInitRun {
+				exc, err := initializeClass(declaringClass, fs)
+				var errBlk *classloader.GErrBlk
+				if errors.As(err, &errBlk) { // the class could not be loaded, e.g., a VerifyError
+					handlerFrame, err := throwResolutionError(fs, errBlk)
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue
+				}
+				if err != nil {
+					errMsg := fmt.Sprintf("PUTSTATIC: could not load class %s", declaringClass)
 					_ = log.Log(errMsg, log.SEVERE)
 					return errors.New(errMsg)
 				}
+				if exc != nil {
+					handlerFrame, err := throwException(fs, exc)
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue
+				}
 			}
+			prevLoaded, ok := classloader.FetchStatic(fieldName)
 
 			// if the field can't be found even after instantiating the
 			// containing class, something is wrong so get out of here.
 			if !ok {
-				errMsg := fmt.Sprintf("PUTSTATIC: could not find static field %s in class %s"+
-					"\n", fieldName, className)
+				errMsg := fmt.Sprintf("PUTSTATIC: could not find static field %s\n", fieldName)
 				_ = log.Log(errMsg, log.SEVERE)
 				return errors.New(errMsg)
 			}
@@ -1557,16 +1810,16 @@ func runFrame(fs *list.List) error {
 				// be stored as a boolean, a byte (in an array), or int64
 				// We want all forms normalized to int64
 				value = pop(f).(int64) & 0x01
-				classloader.Statics[fieldName] = classloader.Static{
+				_ = classloader.AddStatic(fieldName, classloader.Static{
 					Type:  prevLoaded.Type,
 					Value: value,
-				}
+				})
 			case types.Char, types.Short, types.Int, types.Long:
 				value = pop(f).(int64)
-				classloader.Statics[fieldName] = classloader.Static{
+				_ = classloader.AddStatic(fieldName, classloader.Static{
 					Type:  prevLoaded.Type,
 					Value: value,
-				}
+				})
 			case types.Byte:
 				var val byte
 				v := pop(f)
@@ -1577,16 +1830,16 @@ func runFrame(fs *list.List) error {
 				case byte:
 					val = v.(byte)
 				}
-				classloader.Statics[fieldName] = classloader.Static{
+				_ = classloader.AddStatic(fieldName, classloader.Static{
 					Type:  prevLoaded.Type,
 					Value: val,
-				}
+				})
 			case types.Float, types.Double:
 				value = pop(f).(float64)
-				classloader.Statics[fieldName] = classloader.Static{
+				_ = classloader.AddStatic(fieldName, classloader.Static{
 					Type:  prevLoaded.Type,
 					Value: value,
-				}
+				})
 
 			default:
 				// if it's not a primitive or a pointer to a class,
@@ -1595,10 +1848,10 @@ func runFrame(fs *list.List) error {
 				value = pop(f)
 				switch value.(type) {
 				case *object.Object:
-					classloader.Statics[fieldName] = classloader.Static{
+					_ = classloader.AddStatic(fieldName, classloader.Static{
 						Type:  prevLoaded.Type,
 						Value: value,
-					}
+					})
 				case *classloader.Klass:
 					// convert to an *object.Object
 					kPtr := value.(*classloader.Klass)
@@ -1611,10 +1864,10 @@ func runFrame(fs *list.List) error {
 					obj.Fields = append(obj.Fields, objField)
 					obj.FieldTable = nil
 
-					classloader.Statics[fieldName] = classloader.Static{
+					_ = classloader.AddStatic(fieldName, classloader.Static{
 						Type:  objField.Ftype,
 						Value: value,
-					}
+					})
 				default:
 					errMsg := fmt.Sprintf("PUTSTATIC: type unrecognized: %v", value)
 					_ = log.Log(errMsg, log.SEVERE)
@@ -1638,26 +1891,36 @@ func runFrame(fs *list.List) error {
 					fieldEntry.Type, f.PC, f.MethName, f.ClName)
 			}
 
+			// the field is resolved to its slot in the object's fields the first time
+			slot, fieldName, err := classloader.FieldRefSlot(f.CP, fieldEntry.Slot)
+			if err != nil {
+				handlerFrame, err := throwResolutionError(fs, err)
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
+			}
+
 			ref := pop(f).(*object.Object)
-			obj := *ref
+			if ref == nil {
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
+			}
+			obj := ref
 
-			// var fieldName string
 			var fieldType string
 			var fieldValue interface{}
-
-			if obj.Fields != nil {
-				fieldType = obj.Fields[fieldEntry.Slot].Ftype
-				fieldValue = obj.Fields[fieldEntry.Slot].Fvalue
-			} else { // retrieve by name
-				fullFieldEntry := f.CP.FieldRefs[fieldEntry.Slot]
-				nameAndTypeCPIndex := fullFieldEntry.NameAndType
-				nameAndTypeIndex := f.CP.CpIndex[nameAndTypeCPIndex]
-				nameAndType := f.CP.NameAndTypes[nameAndTypeIndex.Slot]
-				nameCPIndex := nameAndType.NameIndex
-				nameCPentry := f.CP.CpIndex[nameCPIndex]
-				fieldName := f.CP.Utf8Refs[nameCPentry.Slot]
-
+			if obj.LaidOut {
+				fieldType = obj.Fields[slot].Ftype
+				fieldValue = obj.Fields[slot].Fvalue
+			} else { // an object that Jacobin created without its class keeps its fields by name
 				objField := obj.FieldTable[fieldName]
+				fieldType = objField.Ftype
 				fieldValue = objField.Fvalue
 			}
 			push(f, fieldValue)
@@ -1678,6 +1941,17 @@ func runFrame(fs *list.List) error {
 					fieldEntry.Type, f.PC, f.MethName, f.ClName)
 			}
 
+			// the field is resolved to its slot in the object's fields the first time
+			slot, fieldName, err := classloader.FieldRefSlot(f.CP, fieldEntry.Slot)
+			if err != nil {
+				handlerFrame, err := throwResolutionError(fs, err)
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
+			}
+
 			var ref interface{} // pointer to object we're updating
 			value := pop(f)     // the value we're placing in the field
 			ref = pop(f)        // on non-long, non-double values, this will be a
@@ -1695,50 +1969,22 @@ func runFrame(fs *list.List) error {
 				ref = pop(f).(*object.Object)
 			}
 
-			obj := *(ref.(*object.Object))
-
-			// if the value we're inserting is a reference to an
-			// array object, we have to modify it to point directly
-			// to the array of primitives, rather than to the array
-			// object
-			switch value.(type) {
-			case *object.Object:
-				if value != object.Null {
-					v := *(value.(*object.Object))
-					if obj.Fields != nil {
-						if strings.HasPrefix(v.Fields[0].Ftype, types.Array) {
-							value = v.Fields[0].Fvalue
-						}
-					}
+			if ref == nil || ref == object.Null {
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
 				}
+				f = handlerFrame
+				continue
 			}
+			obj := ref.(*object.Object)
 
-			if obj.Fields != nil {
-				// If it's a simple object w/out superclasses other than Object,
-				// the fields in the object are numbered in the same
-				// order they are declared in the constant pool. So,
-				// to get to the right field, we only need to know
-				// the slot number in CP.Fields. It will be the same
-				// index into the object's fields.
-				if strings.HasPrefix(obj.Fields[fieldEntry.Slot].Ftype, types.Static) {
-					errMsg := fmt.Sprintf("PUTFIELD: invalid attempt to update a static variable in %s.%s",
-						f.ClName, f.MethName)
-					_ = log.Log(errMsg, log.SEVERE)
-					return errors.New(errMsg)
-				} else {
-					obj.Fields[fieldEntry.Slot].Fvalue = value
+			if obj.LaidOut {
+				obj.Fields[slot].Fvalue = value
+			} else { // an object that Jacobin created without its class keeps its fields by name
+				if obj.FieldTable == nil {
+					obj.FieldTable = make(map[string]object.Field)
 				}
-			} else {
-				// otherwise, it's an object that contains superclass fields and
-				// we need to access the field via the field table using the field name
-				fullFieldEntry := f.CP.FieldRefs[fieldEntry.Slot]
-				nameAndTypeCPIndex := fullFieldEntry.NameAndType
-				nameAndTypeIndex := f.CP.CpIndex[nameAndTypeCPIndex]
-				nameAndType := f.CP.NameAndTypes[nameAndTypeIndex.Slot]
-				nameCPIndex := nameAndType.NameIndex
-				nameCPentry := f.CP.CpIndex[nameCPIndex]
-				fieldName := f.CP.Utf8Refs[nameCPentry.Slot]
-
 				objField := obj.FieldTable[fieldName]
 				objField.Fvalue = value
 				obj.FieldTable[fieldName] = objField
@@ -1779,25 +2025,54 @@ func runFrame(fs *list.List) error {
 			methodSigIndex := nAndT.DescIndex
 			methodType := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, methodSigIndex)
 
-			mtEntry := classloader.MTable[className+"."+methodName+methodType]
-			if mtEntry.Meth == nil { // if the method is not in the method table, find it
-				mtEntry, err = classloader.FetchMethodAndCP(className, methodName, methodType)
-				if err != nil || mtEntry.Meth == nil {
-					// TODO: search the classpath and retry
-					errMsg := "INVOKEVIRTUAL: Class method not found: " + className + "." + methodName
-					_ = log.Log(errMsg, log.SEVERE)
-					return errors.New(errMsg)
+			// the method is selected based on the class of the object reference, which is
+			// beneath the arguments on the stack. References that are not Java objects
+			// (such as System.out, which is implemented in golang) use the method in the
+			// class named in the CP. Arrays use the methods of java/lang/Object.
+			var mtEntry classloader.MTentry
+			argSlots := countArgSlots(methodType)
+			var objRef *object.Object
+			if f.TOS-argSlots >= 0 {
+				objRef, _ = f.OpStack[f.TOS-argSlots].(*object.Object)
+			}
+			if objRef != nil && objRef.Klass != nil && !strings.HasPrefix(*objRef.Klass, "[") {
+				mtEntry, className, err = locateVirtualMethod(*objRef.Klass, className, methodName, methodType)
+				if err != nil {
+					handlerFrame, err := throwResolutionError(fs, err)
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue
+				}
+			} else {
+				if strings.HasPrefix(className, types.Array) { // arrays have the methods of Object
+					className = "java/lang/Object"
+				}
+				mtEntry = classloader.MTableFetch(className + "." + methodName + methodType)
+				if mtEntry.Meth == nil { // if the method is not in the method table, find it
+					mtEntry, err = classloader.FetchMethodAndCP(className, methodName, methodType)
+					if err != nil || mtEntry.Meth == nil {
+						// TODO: search the classpath and retry
+						errMsg := "INVOKEVIRTUAL: Class method not found: " + className + "." + methodName
+						_ = log.Log(errMsg, log.SEVERE)
+						return errors.New(errMsg)
+					}
 				}
 			}
 
 			if mtEntry.MType == 'G' { // so we have a golang function
-				_, err = runGmethod(mtEntry, fs, className, methodName, methodType)
+				handlerFrame, thrown, err := runGmethod(mtEntry, fs, className, methodName, methodType)
 				if err != nil {
 					// any exception message will already have been displayed to the user
 					errMsg := fmt.Sprintf("INVOKEVIRTUAL: Error encountered in: %s.%s"+
 						className, methodName)
 					return errors.New(errMsg)
 				}
+				if thrown { // the go function threw an exception, which was caught
+					f = handlerFrame
+					continue // f.PC points to the handler
+				}
 				break
 			}
 
@@ -1809,6 +2084,22 @@ func runFrame(fs *list.List) error {
 					_ = log.Log(errMsg, log.SEVERE)
 					return errors.New(errMsg)
 				}
+				if receiver := f.OpStack[f.TOS-argSlots]; receiver == nil || receiver == object.Null {
+					handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue
+				}
+				if frameStackIsFull(fs) { // the method's frame would exceed the maximum depth
+					handlerFrame, err := throwNewException(fs, "java/lang/StackOverflowError", "")
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue // f.PC points to the handler
+				}
 				fram, err := createAndInitNewFrame(
 					className, methodName, methodType, &m, true, f)
 				if err != nil {
@@ -1818,31 +2109,7 @@ func runFrame(fs *list.List) error {
 				f.PC += 1                            // move to next bytecode before exiting
 				fs.PushFront(fram)                   // push the new frame
 				f = fs.Front().Value.(*frames.Frame) // point f to the new head
-				return runFrame(fs)
-				/*
-					err = runFrame(fs)                   // 2nd on stack from new crash site
-					if err != nil {
-						return err
-					}
-
-					// if the method is main(), then when we get here the
-					// frame stack will be empty to exit from here, otherwise
-					// there's still a frame on the stack, pop it off and continue.
-					if fs.Len() == 0 {
-						return nil
-					}
-					fs.Remove(fs.Front()) // pop the frame off
-
-					// the previous frame pop might have been main()
-					// if so, then we can't reset f to a non-existent frame
-					// so we test for this before resetting f.
-					if fs.Len() != 0 {
-						f = fs.Front().Value.(*frames.Frame)
-					} else {
-						return nil
-					}
-
-				*/
+				continue                             // and execute it in this loop
 			}
 		case INVOKESPECIAL: //	0xB7 invokespecial (invoke constructors, private methods, etc.)
 			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2]) // next 2 bytes point to CP entry
@@ -1864,15 +2131,27 @@ func runFrame(fs *list.List) error {
 			}
 
 			if mtEntry.MType == 'G' { // it's a golang method
-				f, err = runGmethod(mtEntry, fs, className, className+"."+methName, methSig)
+				var thrown bool
+				f, thrown, err = runGmethod(mtEntry, fs, className, methName, methSig)
 				if err != nil {
 					errMsg := "INVOKESPECIAL: Error encountered in: " + className + "." + methName
 					// any exceptions message will already have been displayed to the user
 					return errors.New(errMsg)
 				}
+				if thrown { // the go function threw an exception, which was caught
+					continue // f.PC points to the handler
+				}
 			} else if mtEntry.MType == 'J' {
 				// TODO: handle arguments to method, if any
 				m := mtEntry.Meth.(classloader.JmEntry)
+				if frameStackIsFull(fs) { // the method's frame would exceed the maximum depth
+					handlerFrame, err := throwNewException(fs, "java/lang/StackOverflowError", "")
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue // f.PC points to the handler
+				}
 				fram, err := createAndInitNewFrame(className, methName, methSig, &m, true, f)
 				if err != nil {
 					errMsg := "INVOKESPECIAL: Error creating frame in: " + className + "." + methName
@@ -1882,7 +2161,7 @@ func runFrame(fs *list.List) error {
 				f.PC += 1
 				fs.PushFront(fram)                   // push the new frame
 				f = fs.Front().Value.(*frames.Frame) // point f to the new head
-				return runFrame(fs)
+				continue                             // and execute it in this loop
 			}
 		case INVOKESTATIC: // 	0xB8 invokestatic (create new frame, invoke static function)
 			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2]) // next 2 bytes point to CP entry
@@ -1910,6 +2189,15 @@ func runFrame(fs *list.List) error {
 			methodType := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, methodSigIndex)
 
 			mtEntry, err := classloader.FetchMethodAndCP(className, methodName, methodType)
+			var errBlk *classloader.GErrBlk
+			if errors.As(err, &errBlk) { // the class could not be loaded
+				handlerFrame, err := throwResolutionError(fs, errBlk)
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
+			}
 			if err != nil || mtEntry.Meth == nil {
 				// TODO: search the classpath and retry
 				errMsg := "INVOKESTATIC: Class method not found: " + className + "." + methodName
@@ -1917,30 +2205,61 @@ func runFrame(fs *list.List) error {
 				return errors.New(errMsg)
 			}
 
-			// before we can run the method, we need to either instantiate the class and/or
-			// make sure that its static intializer block (if any) has been run. At this point,
-			// all we know the class exists and has been loaded.
-			k := classloader.MethAreaFetch(className)
-			if k.Data.ClInit == types.ClInitNotRun {
-				err = runInitializationBlock(k, nil, fs)
+			// before we can run the method, the class that declares it must be initialized.
+			// A go method that stands in for a class that's not loaded needs no initialization.
+			declaringClass := className
+			if mtEntry.MType == 'J' && mtEntry.Meth.(classloader.JmEntry).ClName != "" {
+				declaringClass = mtEntry.Meth.(classloader.JmEntry).ClName
+			}
+			if mtEntry.MType == 'J' || classloader.MethAreaFetch(declaringClass) != nil {
+				exc, err := initializeClass(declaringClass, fs)
+				var errBlk *classloader.GErrBlk
+				if errors.As(err, &errBlk) { // the class could not be loaded, e.g., a VerifyError
+					handlerFrame, err := throwResolutionError(fs, errBlk)
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue
+				}
 				if err != nil {
 					errMsg := fmt.Sprintf("INVOKESTATIC: error running initializer block in %s",
-						className)
+						declaringClass)
 					_ = log.Log(errMsg, log.SEVERE)
 					return errors.New(errMsg)
 				}
+				if exc != nil {
+					handlerFrame, err := throwException(fs, exc)
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue
+				}
 			}
 
 			if mtEntry.MType == 'G' {
-				f, err = runGmethod(mtEntry, fs, className, methodName, methodType)
+				var thrown bool
+				f, thrown, err = runGmethod(mtEntry, fs, className, methodName, methodType)
 
 				if err != nil {
 					// any exceptions message will already have been displayed to the user
 					return errors.New("INVOKESTATIC: Error encountered in: " +
 						className + "." + methodName)
 				}
+				if thrown { // the go function threw an exception, which was caught
+					continue // f.PC points to the handler
+				}
 			} else if mtEntry.MType == 'J' {
 				m := mtEntry.Meth.(classloader.JmEntry)
+				if frameStackIsFull(fs) { // the method's frame would exceed the maximum depth
+					handlerFrame, err := throwNewException(fs, "java/lang/StackOverflowError", "")
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue // f.PC points to the handler
+				}
 				fram, err := createAndInitNewFrame(
 					className, methodName, methodType, &m, false, f)
 				if err != nil {
@@ -1951,7 +2270,7 @@ func runFrame(fs *list.List) error {
 				f.PC += 1                            // point to the next bytecode before exiting
 				fs.PushFront(fram)                   // push the new frame
 				f = fs.Front().Value.(*frames.Frame) // point f to the new head
-				return runFrame(fs)
+				continue                             // and execute it in this loop
 				// err = runFrame(fs)                   // 2nd on stack from new crash site
 				// if err != nil {
 				// 	return err
@@ -1977,6 +2296,115 @@ func runFrame(fs *list.List) error {
 
 				*/
 			}
+		case INVOKEINTERFACE: // 0xB9 invokeinterface (invoke an interface method on an object)
+			// the next 2 bytes point to an interface-method CP entry. They're followed by
+			// a count of the argument slots (including the object reference) and a zero byte.
+			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2])
+			count := int(f.Meth[f.PC+3])
+			f.PC += 4
+
+			CPentry := f.CP.CpIndex[CPslot]
+			if CPentry.Type != classloader.Interface {
+				errMsg := fmt.Sprintf("INVOKEINTERFACE: Expected an interface method ref, but got %d in "+
+					"location %d in method %s of class %s\n",
+					CPentry.Type, f.PC, f.MethName, f.ClName)
+				_ = log.Log(errMsg, log.SEVERE)
+				return errors.New(errMsg)
+			}
+
+			// get the interface, method name, and signature from the interface-method ref
+			method := f.CP.InterfaceRefs[CPentry.Slot]
+			ifaceName, _ := getClassNameFromCPclassref(f.CP, method.ClassIndex)
+			nAndT := f.CP.NameAndTypes[f.CP.CpIndex[method.NameAndType].Slot]
+			methodName := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, nAndT.NameIndex)
+			methodType := classloader.FetchUTF8stringFromCPEntryNumber(f.CP, nAndT.DescIndex)
+
+			// the object reference is beneath the arguments on the stack. The method is
+			// selected based on the class of this object, rather than on the interface.
+			if count < 1 || f.TOS-(count-1) < 0 {
+				errMsg := fmt.Sprintf("INVOKEINTERFACE: Invalid argument count %d for %s.%s",
+					count, ifaceName, methodName)
+				_ = log.Log(errMsg, log.SEVERE)
+				return errors.New(errMsg)
+			}
+			objRef, ok := f.OpStack[f.TOS-(count-1)].(*object.Object)
+			if !ok || objRef == nil {
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
+			}
+
+			mtEntry, className, err := locateInterfaceMethod(*objRef.Klass, ifaceName, methodName, methodType)
+			if err != nil {
+				handlerFrame, err := throwResolutionError(fs, err)
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
+			}
+
+			if mtEntry.MType == 'G' { // so we have a golang function
+				handlerFrame, thrown, err := runGmethod(mtEntry, fs, className, methodName, methodType)
+				if err != nil {
+					// any exception message will already have been displayed to the user
+					errMsg := "INVOKEINTERFACE: Error encountered in: " + className + "." + methodName
+					return errors.New(errMsg)
+				}
+				if thrown { // the go function threw an exception, which was caught
+					f = handlerFrame
+					continue // f.PC points to the handler
+				}
+				break
+			}
+
+			if mtEntry.MType == 'J' {
+				m := mtEntry.Meth.(classloader.JmEntry)
+				if m.AccessFlags&0x0100 > 0 {
+					// Native code
+					errMsg := "INVOKEINTERFACE: Native method requested: " + className + "." + methodName
+					_ = log.Log(errMsg, log.SEVERE)
+					return errors.New(errMsg)
+				}
+				if frameStackIsFull(fs) { // the method's frame would exceed the maximum depth
+					handlerFrame, err := throwNewException(fs, "java/lang/StackOverflowError", "")
+					if err != nil {
+						return err
+					}
+					f = handlerFrame
+					continue // f.PC points to the handler
+				}
+				fram, err := createAndInitNewFrame(
+					className, methodName, methodType, &m, true, f)
+				if err != nil {
+					return errors.New("INVOKEINTERFACE: Error creating frame in: " +
+						className + "." + methodName)
+				}
+				f.PC += 1                            // move to next bytecode before exiting
+				fs.PushFront(fram)                   // push the new frame
+				f = fs.Front().Value.(*frames.Frame) // point f to the new head
+				continue                             // and execute it in this loop
+			}
+		case INVOKEDYNAMIC: // 0xBA invokedynamic (invoke a dynamically-computed call site)
+			// the next 2 bytes point to an invokedynamic CP entry. They're followed by two zero bytes.
+			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2])
+			f.PC += 4
+			if err := invokeDynamic(fs, f, CPslot); err != nil {
+				var callerErr *goCallerException
+				if !errors.As(err, &callerErr) {
+					return err
+				}
+				// a toString() called by a string concatenation threw an exception
+				handlerFrame, err := throwException(fs, callerErr.exception)
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
+			}
 		case NEW: // 0xBB 	new: create and instantiate a new object
 			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2]) // next 2 bytes point to CP entry
 			f.PC += 2
@@ -1994,6 +2422,30 @@ func runFrame(fs *list.List) error {
 				className = classloader.FetchUTF8stringFromCPEntryNumber(f.CP, utf8Index)
 			}
 
+			exc, err := initializeClass(className, fs)
+			var errBlk *classloader.GErrBlk
+			if errors.As(err, &errBlk) { // the class could not be loaded, e.g., a VerifyError
+				handlerFrame, err := throwResolutionError(fs, errBlk)
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
+			}
+			if err != nil {
+				errMsg := fmt.Sprintf("NEW: could not load class %s", className)
+				_ = log.Log(errMsg, log.SEVERE)
+				return errors.New(errMsg)
+			}
+			if exc != nil {
+				handlerFrame, err := throwException(fs, exc)
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
+			}
+
 			ref, err := instantiateClass(className, fs)
 			if err != nil {
 				errMsg := fmt.Sprintf("NEW: could not load class %s", className)
@@ -2005,9 +2457,13 @@ func runFrame(fs *list.List) error {
 		case NEWARRAY: // 0xBC create a new array of primitives
 			size := pop(f).(int64)
 			if size < 0 {
-				errMsg := "NEWARRAY: Invalid size for array"
-				exceptions.Throw(exceptions.NegativeArraySizeException, errMsg)
-				return errors.New(errMsg)
+				handlerFrame, err := throwNewException(fs, "java/lang/NegativeArraySizeException",
+					strconv.FormatInt(size, 10))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
 			arrayType := int(f.Meth[f.PC+1])
@@ -2028,29 +2484,46 @@ func runFrame(fs *list.List) error {
 		case ANEWARRAY: // 0xBD create array of references
 			size := pop(f).(int64)
 			if size < 0 {
-				errMsg := "ANEWARRAY: Invalid size for array"
-				exceptions.Throw(exceptions.NegativeArraySizeException, errMsg)
+				handlerFrame, err := throwNewException(fs, "java/lang/NegativeArraySizeException",
+					strconv.FormatInt(size, 10))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
+			}
+
+			// The bytecode is followed by a two-byte index into the CP
+			// of the class of the array's components, which might
+			// itself be an array class.
+			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2])
+			f.PC += 2
+			componentClass, _ := getClassNameFromCPclassref(f.CP, uint16(CPslot))
+			if componentClass == "" {
+				errMsg := "ANEWARRAY: Invalid classRef found"
+				_ = log.Log(errMsg, log.SEVERE)
 				return errors.New(errMsg)
 			}
 
-			arrayPtr := object.Make1DimArray(object.REF, size)
+			arrayType := types.Array + componentClass
+			if !strings.HasPrefix(componentClass, types.Array) {
+				arrayType = types.RefArray + componentClass + ";"
+			}
+			arrayPtr := object.MakeArray(arrayType, size)
 			g := globals.GetGlobalRef()
 			g.ArrayAddressList.PushFront(arrayPtr)
 			push(f, arrayPtr)
 
-			// The bytecode is followed by a two-byte index into the CP
-			// which indicates what type the reference points to. We
-			// don't presently check the type, so we skip over these
-			// two bytes.
-			f.PC += 2
-
 		case ARRAYLENGTH: // OxBE get size of array
 			// expects a pointer to an array
 			ref := pop(f)
-			if ref == nil {
-				errMsg := "ARRAYLENGTH: Invalid (null) reference to an array"
-				exceptions.Throw(exceptions.NullPointerException, errMsg)
-				return errors.New(errMsg)
+			if ref == nil || ref == object.Null {
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
 			}
 
 			var size int64
@@ -2069,24 +2542,38 @@ func runFrame(fs *list.List) error {
 				size = int64(len(array))
 			case *object.Object:
 				r := ref.(*object.Object)
-				arrayType := r.Fields[0].Ftype
-				switch arrayType {
-				case types.ByteArray:
-					arrayPtr := r.Fields[0].Fvalue.(*[]byte)
+				switch arrayPtr := r.Fields[0].Fvalue.(type) {
+				case *[]byte: // byte and boolean arrays
 					size = int64(len(*arrayPtr))
-				case types.RefArray:
-					arrayPtr := r.Fields[0].Fvalue.(*[]*object.Object)
+				case *[]*object.Object: // arrays of references, including arrays of arrays
 					size = int64(len(*arrayPtr))
-				case types.FloatArray:
-					arrayPtr := r.Fields[0].Fvalue.(*[]float64)
+				case *[]float64:
 					size = int64(len(*arrayPtr))
-				default:
-					arrayPtr := r.Fields[0].Fvalue.(*[]int64)
+				case *[]int64:
 					size = int64(len(*arrayPtr))
 				}
 			}
 			push(f, size)
 
+		case ATHROW: // 0xBF throw an exception
+			// the exception object is popped and the frame stack is searched for a handler.
+			// If one is found, execution resumes at the handler, which might be in
+			// another frame. Otherwise, the uncaught exception has been reported.
+			ref := pop(f)
+			excObj, ok := ref.(*object.Object)
+			var handlerFrame *frames.Frame
+			var err error
+			if !ok || excObj == nil {
+				handlerFrame, err = throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+			} else {
+				handlerFrame, err = throwException(fs, excObj)
+			}
+			if err != nil {
+				return err
+			}
+			f = handlerFrame
+			continue // f.PC already points to the handler, so skip the increment below
+
 		case CHECKCAST: // 0xC0 same as INSTANCEOF but throws exception on null
 			// because this uses the same logic as INSTANCEOF, any change here should
 			// be made to INSTANCEOF
@@ -2097,21 +2584,14 @@ func runFrame(fs *list.List) error {
 				continue
 			}
 
-			var obj *object.Object
-			switch ref.(type) {
-			case *object.Object:
-				if ref == object.Null { // if ref is null, just carry on
-					f.PC += 2 // move past two bytes pointing to comp object
-					f.PC += 1
-					continue
-				} else {
-					obj = (ref).(*object.Object)
-				}
-			default:
-				errMsg := "CHECKCAST: Invalid class reference"
-				exceptions.Throw(exceptions.ClassCastException, errMsg)
-				return errors.New(errMsg)
+			if ref == object.Null { // if ref is null, just carry on
+				f.PC += 2 // move past two bytes pointing to comp object
+				f.PC += 1
+				continue
 			}
+			// a value that's not an object, or an object without a class, is cast as
+			// an instance of Object, which can't be cast to any other class
+			obj, _ := ref.(*object.Object)
 
 			// at this point, we know we have a valid non-nil, non-null pointer to an object
 			CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2])
@@ -2138,40 +2618,42 @@ func runFrame(fs *list.List) error {
 					_ = log.Log(msg, log.TRACE_INST)
 				}
 
-				if strings.HasPrefix(className, "[") { // the object being checked is an array
-					if obj.Klass != nil {
-						sptr := obj.Klass
-						// for the nonce if they're both the same type of arrays, we're good
-						// TODO: if both are arrays of reference, check the leaf types
-						if *sptr == className || strings.HasPrefix(className, *sptr) {
-							break // exit this bytecode processing
-						} else {
-							errMsg := fmt.Sprintf("CHECKCAST: %s is not castable with respect to %s", className, *sptr)
-							exceptions.Throw(exceptions.ClassCastException, errMsg)
-							return errors.New(errMsg)
-						}
-					} else {
-						errMsg := fmt.Sprintf("CHECKCAST: Klass field for object is nil")
-						exceptions.Throw(exceptions.ClassCastException, errMsg)
-						return errors.New(errMsg)
+				if obj == nil || obj.Klass == nil {
+					handlerFrame, err := throwNewException(fs, "java/lang/ClassCastException",
+						classCastMessage("java/lang/Object", className))
+					if err != nil {
+						return err
 					}
-				} else { // the object being checked is a class
-					classPtr := classloader.MethAreaFetch(className)
-					if classPtr == nil { // class wasn't loaded, so load it now
-						if classloader.LoadClassFromNameOnly(className) != nil {
-							return errors.New("CHECKCAST: Could not load class: " + className)
+					f = handlerFrame
+					continue
+				}
+
+				if !strings.HasPrefix(className, types.Array) { // array classes need not be loaded
+					if classloader.MethAreaFetch(className) == nil { // class wasn't loaded, so load it now
+						if err := classloader.LoadClassFromNameOnly(className); err != nil {
+							handlerFrame, err := throwResolutionError(fs, classloader.ClassLoadingError(className, err))
+							if err != nil {
+								return err
+							}
+							f = handlerFrame
+							continue
 						}
-						classPtr = classloader.MethAreaFetch(className)
 					}
+				}
 
-					if classPtr != classloader.MethAreaFetch(*obj.Klass) {
-						errMsg := fmt.Sprintf("CHECKCAST: %s is not castable with respect to %s", className, classPtr.Data.Name)
-						exceptions.Throw(exceptions.ClassCastException, errMsg)
-						return errors.New(errMsg)
+				// arrays are covariant, so an array of a class can be cast to an array of
+				// its superclass. See isAssignableTo() for the rules.
+				if !isInstanceOf(obj, className) {
+					handlerFrame, err := throwNewException(fs, "java/lang/ClassCastException",
+						classCastMessage(*obj.Klass, className))
+					if err != nil {
+						return err
 					}
-					// note that if the classPtr == obj.Klass, which is the desired outcome,
-					// do nothing. That is, the incoming stack should remain the same.
+					f = handlerFrame
+					continue
 				}
+				// note that if the object is an instance of the class, which is the desired
+				// outcome, do nothing. That is, the incoming stack should remain the same.
 			}
 
 		case INSTANCEOF: // 0xC1 validate the type of object (if not nil or null)
@@ -2191,7 +2673,7 @@ func runFrame(fs *list.List) error {
 					f.PC += 2 // move past two bytes pointing to comp object
 					break
 				} else {
-					obj := *ref.(*object.Object)
+					obj := ref.(*object.Object)
 					CPslot := (int(f.Meth[f.PC+1]) * 256) + int(f.Meth[f.PC+2])
 					f.PC += 2
 					CPentry := f.CP.CpIndex[CPslot]
@@ -2211,15 +2693,17 @@ func runFrame(fs *list.List) error {
 							}
 						}
 						classPtr := classloader.MethAreaFetch(className)
-						if classPtr == nil { // class wasn't loaded, so load it now
-							if classloader.LoadClassFromNameOnly(className) != nil {
-								errMsg := "INSTANCEOF: Could not load class: " + className
-								_ = log.Log(errMsg, log.SEVERE)
-								return errors.New(errMsg)
+						if classPtr == nil && !strings.HasPrefix(className, types.Array) { // class wasn't loaded, so load it now
+							if err := classloader.LoadClassFromNameOnly(className); err != nil {
+								handlerFrame, err := throwResolutionError(fs, classloader.ClassLoadingError(className, err))
+								if err != nil {
+									return err
+								}
+								f = handlerFrame
+								continue
 							}
-							classPtr = classloader.MethAreaFetch(className)
 						}
-						if classPtr == classloader.MethAreaFetch(*obj.Klass) {
+						if isInstanceOf(obj, className) {
 							push(f, int64(1))
 						} else {
 							push(f, int64(0))
@@ -2228,12 +2712,38 @@ func runFrame(fs *list.List) error {
 				}
 			}
 
-		case MONITORENTER, MONITOREXIT: // OxC2 and OxC3. These  are not implemented in the JDK JVM
-			_ = pop(f) // so just pop off the reference on the stack
+		case MONITORENTER: // 0xC2 (enter the monitor of the object popped off the stack)
+			obj, _ := pop(f).(*object.Object)
+			if obj == nil || obj == object.Null {
+				handlerFrame, err := throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+				if err != nil {
+					return err
+				}
+				f = handlerFrame
+				continue
+			}
+			object.GetMonitor(obj).Enter(f.Thread) // blocks while another thread owns the monitor
+
+		case MONITOREXIT: // 0xC3 (exit the monitor of the object popped off the stack)
+			obj, _ := pop(f).(*object.Object)
+			var handlerFrame *frames.Frame
+			var err error
+			if obj == nil || obj == object.Null {
+				handlerFrame, err = throwNewException(fs, "java/lang/NullPointerException", nullPointerMessage(f))
+			} else if !object.GetMonitor(obj).Exit(f.Thread) {
+				handlerFrame, err = throwNewException(fs, "java/lang/IllegalMonitorStateException",
+					"current thread is not owner")
+			} else {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			f = handlerFrame
+			continue
 
 		case MULTIANEWARRAY: // 0xC5 create multi-dimensional array
 			var arrayDesc string
-			var arrayType uint8
 
 			// The first two chars after the bytecode point to a
 			// classref entry in the CP. In turn, it points to a
@@ -2253,36 +2763,21 @@ func runFrame(fs *list.List) error {
 				arrayDesc = classloader.FetchUTF8stringFromCPEntryNumber(f.CP, utf8Index)
 			}
 
-			var rawArrayType uint8
-			for i := 0; i < len(arrayDesc); i++ {
-				if arrayDesc[i] != '[' {
-					rawArrayType = arrayDesc[i]
-					break
-				}
-			}
-
-			switch rawArrayType {
-			case 'B', 'Z':
-				arrayType = object.BYTE
-			case 'F', 'D':
-				arrayType = object.FLOAT
-			case 'L':
-				arrayType = object.REF
-			default:
-				arrayType = object.INT
-			}
-
 			// get the number of dimensions, then pop off the operand
 			// stack an int for every dimension, giving the size of that
 			// dimension and put them into a slice that starts with
 			// the highest dimension first. So a two-dimensional array
 			// such as x[4][3], would have entries of 4 and 3 respectively
-			// in the dimsizes slice.
+			// in the dimsizes slice. The number of dimensions can be
+			// anywhere from 1 to the 255 dimensions of the array type.
+			// If it's fewer, the remaining dimensions are created only
+			// when the program assigns them, as in int[4][].
 			dimensionCount := int(f.Meth[f.PC+1])
 			f.PC += 1
 
-			if dimensionCount > 3 { // TODO: explore arrays of > 5-255 dimensions
-				errMsg := "MULTIANEWARRAY: Jacobin supports arrays only up to three dimensions"
+			if dimensionCount < 1 || dimensionCount > strings.Count(arrayDesc, types.Array) {
+				errMsg := fmt.Sprintf("MULTIANEWARRAY: Invalid number of dimensions, %d, for array type %s",
+					dimensionCount, arrayDesc)
 				_ = log.Log(errMsg, log.SEVERE)
 				return errors.New(errMsg)
 			}
@@ -2293,47 +2788,29 @@ func runFrame(fs *list.List) error {
 			// first when popped off the stack, so, they're stored here
 			// in reverse order, so that dimSizes[0] will hold the first
 			// dimenion.
+			negativeSize := -1
 			for i := dimensionCount - 1; i >= 0; i-- {
 				dimSizes[i] = pop(f).(int64)
-			}
-
-			// A dimension of zero ends the dimensions, so we check
-			// and cut off the dimensions below and includingthe 0-sized
-			// one. Because this is almost certainly an error, we also
-			// issue a warning.
-			for i := range dimSizes {
-				if dimSizes[i] == 0 {
-					dimSizes = dimSizes[i+1:] // lop off the prev dims
-					_ = log.Log("MULTIANEWARRAY: Multidimensional array with one dimension of size 0 encountered.",
-						log.WARNING)
-					break
+				if dimSizes[i] < 0 {
+					negativeSize = i
 				}
 			}
-
-			// Because of the possibility of a zero-sized dimension
-			// affecting the valid number of dimensions, dimensionCount
-			// can no longer be considered reliable. Use len(dimSizes).
-			if len(dimSizes) == 3 {
-				multiArr := object.Make1DimArray(object.REF, dimSizes[0])
-				actualArray := *multiArr.Fields[0].Fvalue.(*[]*object.Object)
-				for i := 0; i < len(actualArray); i++ {
-					actualArray[i], _ = object.Make2DimArray(dimSizes[1],
-						dimSizes[2], arrayType)
+			if negativeSize >= 0 { // the JDK checks every dimension, even those after a zero
+				handlerFrame, err := throwNewException(fs, "java/lang/NegativeArraySizeException",
+					strconv.FormatInt(dimSizes[negativeSize], 10))
+				if err != nil {
+					return err
 				}
-				push(f, multiArr)
-				break
-			} else if len(dimSizes) == 2 { // 2-dim array is a special, trivial case
-				multiArr, _ := object.Make2DimArray(dimSizes[0], dimSizes[1], arrayType)
-				push(f, multiArr)
-				break
-				// It's possible due to a zero-length dimension, that we
-				// need to create a single-dimension array.
-			} else if len(dimSizes) == 1 {
-				oneDimArr := object.Make1DimArray(arrayType, dimSizes[0])
-				push(f, oneDimArr)
-				break
+				f = handlerFrame
+				continue
 			}
 
+			// A dimension of zero leaves the dimensions after it
+			// uncreated, as an array of zero elements has no
+			// subarrays.
+			multiArr := object.MakeMultiDimArray(arrayDesc, dimSizes)
+			push(f, multiArr)
+
 		case IFNULL: // 0xC6 jump if TOS holds a null address
 			// null = 0, so we duplicate logic of IFEQ instruction
 			value := pop(f)
@@ -2409,7 +2886,27 @@ func runFrame(fs *list.List) error {
 		}
 		f.PC += 1
 	}
-	return nil
+}
+
+// exitFrame is called when the method in the frame at the head of the frame stack
+// returns. If that frame is the one that runFrame() was called to run (that is, the
+// frame stack is at baseDepth), it's left on the stack for the caller of runFrame()
+// to pop, and nil is returned. Otherwise, the frame is popped and the frame of the
+// calling method, in which execution resumes, is returned.
+//
+// If the method is synchronized, its monitor is released first. Should the thread no
+// longer own that monitor, an IllegalMonitorStateException is thrown instead, and the
+// frame of its handler is returned, or nil and an error if it's not caught.
+func exitFrame(fs *list.List, baseDepth int) (*frames.Frame, error) {
+	if !exitSynchronizedMethod(fs.Front().Value.(*frames.Frame)) {
+		return throwNewException(fs, "java/lang/IllegalMonitorStateException", "current thread is not owner")
+	}
+
+	if fs.Len() <= baseDepth {
+		return nil, nil
+	}
+	fs.Remove(fs.Front())
+	return fs.Front().Value.(*frames.Frame), nil
 }
 
 // the generation and formatting of trace data for each executed bytecode.
@@ -2425,7 +2922,7 @@ func emitTraceData(f *frames.Frame) string {
 			if f.OpStack[f.TOS].(*object.Object) == object.Null {
 				stackTop = fmt.Sprintf("null")
 			} else {
-				obj := *(f.OpStack[f.TOS].(*object.Object))
+				obj := f.OpStack[f.TOS].(*object.Object)
 				if obj.Fields != nil && len(obj.Fields) > 0 {
 					if obj.Fields != nil && obj.Fields[0].Ftype == types.ByteArray { // if it's a string, just show the string
 						if obj.Fields[0].Fvalue == nil {
@@ -2658,6 +3155,22 @@ func subtract[N frames.Number](num1, num2 N) N {
 	return num1 - num2
 }
 
+// floatToInt converts a float or double to an int or long, whose range is min to max,
+// following the rules of F2I, F2L, D2I, and D2L: the value is truncated toward zero,
+// values beyond the range become the nearest end of the range, and NaN becomes zero.
+func floatToInt(val float64, min, max int64) int64 {
+	switch {
+	case math.IsNaN(val):
+		return 0
+	case val <= float64(min):
+		return min
+	case val >= float64(max): // float64(math.MaxInt64) is 2^63, which is beyond the range
+		return max
+	default:
+		return int64(math.Trunc(val))
+	}
+}
+
 // converts an interface{} value to int8. Used for BASTORE
 func convertInterfaceToByte(val interface{}) byte {
 	switch t := val.(type) {
@@ -2716,9 +3229,15 @@ func createAndInitNewFrame(
 	}
 	fram := frames.CreateFrame(stackSize)
 	fram.ClName = className
+	if m.ClName != "" { // an inherited method runs in the class that declares it
+		fram.ClName = m.ClName
+	}
 	fram.MethName = methodName
+	fram.MethType = methodType
 	fram.CP = m.Cp                           // add its pointer to the class CP
 	fram.Meth = append(fram.Meth, m.Code...) // copy the method's bytecodes over
+	fram.ExcTable = m.Exceptions             // the method's exception handlers, if any
+	fram.LineNums = m.LineNumbers            // the method's source line numbers, if any
 
 	// pop the parameters off the present stack and put them in
 	// the new frame's locals. This is done in reverse order so
@@ -2845,10 +3364,27 @@ func createAndInitNewFrame(
 	}
 
 	fram.TOS = -1
+	fram.Thread = f.Thread
+
+	if m.AccessFlags&classloader.AccSynchronized != 0 {
+		enterSynchronizedMethod(fram, !includeObjectRef)
+	}
 
 	return fram, nil
 }
 
+// runFrameToCompletion runs the frame at the head of the frame stack, along with
+// any methods it calls, until it returns, and then pops it off the frame stack. This
+// is used when a Java method must be run to obtain a value in the middle of executing
+// an instruction.
+func runFrameToCompletion(fs *list.List) error {
+	if err := runFrame(fs); err != nil {
+		return err
+	}
+	fs.Remove(fs.Front())
+	return nil
+}
+
 // Convert a byte to an int64 by extending the sign-bit
 func byteToInt64(bite byte) int64 {
 	if (bite & 0x80) == 0x80 { // Negative bite value (left-most bit on)?
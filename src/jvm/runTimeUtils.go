@@ -132,27 +132,31 @@ func FetchCPentry(cpp *classloader.CPool, index int) cpType {
 func getClassNameFromCPclassref(CP *classloader.CPool, cpIndex uint16) (string, int) {
 	var className = ""
 	cpEntry := FetchCPentry(CP, int(cpIndex))
-	if cpEntry.retType != IS_ERROR {
-		ptr := unsafe.Pointer(cpEntry.addrVal)
-		stringPtr := (*string)(ptr)
-		className = *stringPtr
-		// classnameUTF8idx := cpEntry.entryType
-		// className = CP.Utf8Refs[classnameUTF8idx]
+	if cpEntry.entryType == classloader.ClassRef && cpEntry.retType == IS_STRING_ADDR {
+		className = *cpEntry.stringVal
 	}
 	return className, cpEntry.entryType
 }
 
+// getMethInfoFromCPmethref returns the class name, method name, and method signature
+// of the method ref or interface-method ref at cpIndex in the CP. Returns empty strings
+// if the CP entry is neither.
 func getMethInfoFromCPmethref(CP *classloader.CPool, cpIndex int) (string, string, string) {
 	if cpIndex < 1 || cpIndex >= len(CP.CpIndex) {
 		return "", "", ""
 	}
 
-	if CP.CpIndex[cpIndex].Type != classloader.MethodRef {
+	var classIndex, nameAndTypeCPindex uint16
+	switch CP.CpIndex[cpIndex].Type {
+	case classloader.MethodRef:
+		methodRef := CP.MethodRefs[CP.CpIndex[cpIndex].Slot]
+		classIndex, nameAndTypeCPindex = methodRef.ClassIndex, methodRef.NameAndType
+	case classloader.Interface: // e.g., static, private, and default methods of interfaces
+		interfaceRef := CP.InterfaceRefs[CP.CpIndex[cpIndex].Slot]
+		classIndex, nameAndTypeCPindex = interfaceRef.ClassIndex, interfaceRef.NameAndType
+	default:
 		return "", "", ""
 	}
-	methodRef := CP.CpIndex[cpIndex].Slot
-	classIndex := CP.MethodRefs[methodRef].ClassIndex
-	// nameAndTypeIndex := CP.MethodRefs[methodRef].NameAndType
 
 	classRefIdx := CP.CpIndex[classIndex].Slot
 	classIdx := CP.ClassRefs[classRefIdx]
@@ -160,7 +164,6 @@ func getMethInfoFromCPmethref(CP *classloader.CPool, cpIndex int) (string, strin
 	className := CP.Utf8Refs[classNameIdx.Slot]
 
 	// now get the method signature
-	nameAndTypeCPindex := CP.MethodRefs[methodRef].NameAndType
 	nameAndTypeIndex := CP.CpIndex[nameAndTypeCPindex].Slot
 	nameAndTypeEntry := CP.NameAndTypes[nameAndTypeIndex]
 	methNameCPindex := nameAndTypeEntry.NameIndex
@@ -174,3 +177,21 @@ func getMethInfoFromCPmethref(CP *classloader.CPool, cpIndex int) (string, strin
 
 	return className, methName, methSig
 }
+
+// getFieldInfoFromCPfieldref returns the class name, field name, and field type of the
+// field ref at cpIndex in the CP. Returns empty strings if the CP entry is not a field ref.
+func getFieldInfoFromCPfieldref(CP *classloader.CPool, cpIndex int) (string, string, string) {
+	if cpIndex < 1 || cpIndex >= len(CP.CpIndex) || CP.CpIndex[cpIndex].Type != classloader.FieldRef {
+		return "", "", ""
+	}
+
+	fieldRef := &CP.FieldRefs[CP.CpIndex[cpIndex].Slot]
+	className, _ := getClassNameFromCPclassref(CP, fieldRef.ClassIndex)
+
+	nameAndTypeIndex := CP.CpIndex[fieldRef.NameAndType].Slot
+	nameAndTypeEntry := CP.NameAndTypes[nameAndTypeIndex]
+	fieldName := classloader.FetchUTF8stringFromCPEntryNumber(CP, nameAndTypeEntry.NameIndex)
+	fieldType := classloader.FetchUTF8stringFromCPEntryNumber(CP, nameAndTypeEntry.DescIndex)
+
+	return className, fieldName, fieldType
+}
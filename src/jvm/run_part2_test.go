@@ -7,6 +7,7 @@
 package jvm
 
 import (
+	"encoding/binary"
 	"io"
 	"jacobin/classloader"
 	"jacobin/frames"
@@ -16,7 +17,9 @@ import (
 	"jacobin/thread"
 	"jacobin/types"
 	"os"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"testing"
 	"unsafe"
 )
@@ -365,6 +368,1148 @@ func TestInstanceofString(t *testing.T) {
 	}
 }
 
+// sets up a class, IndyTest, whose CP holds an INVOKEDYNAMIC call site of type methType.
+// The call site's bootstrap method is bsmClass.bsmName, whose static arguments are the
+// strings in bsmArgs. Returns a frame that executes the INVOKEDYNAMIC.
+func setupInvokedynamicTest(bsmClass, bsmName, methType string, bsmArgs ...string) *frames.Frame {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+	delete(lambdaCallSites, "IndyTest.1") // remove any call site linked by previous tests
+
+	// the CP: [1] invokedynamic -> [2] name and type, [5] method handle -> [6] method ref
+	// -> [7] class ref and [9] name and type. The bootstrap args start at [11].
+	CP := classloader.CPool{}
+	CP.CpIndex = make([]classloader.CpEntry, 11)
+	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.InvokeDynamic, Slot: 0}
+	CP.CpIndex[2] = classloader.CpEntry{Type: classloader.NameAndType, Slot: 0}
+	CP.CpIndex[3] = classloader.CpEntry{Type: classloader.UTF8, Slot: 0}
+	CP.CpIndex[4] = classloader.CpEntry{Type: classloader.UTF8, Slot: 1}
+	CP.CpIndex[5] = classloader.CpEntry{Type: classloader.MethodHandle, Slot: 0}
+	CP.CpIndex[6] = classloader.CpEntry{Type: classloader.MethodRef, Slot: 0}
+	CP.CpIndex[7] = classloader.CpEntry{Type: classloader.ClassRef, Slot: 0}
+	CP.CpIndex[8] = classloader.CpEntry{Type: classloader.UTF8, Slot: 2}
+	CP.CpIndex[9] = classloader.CpEntry{Type: classloader.NameAndType, Slot: 1}
+	CP.CpIndex[10] = classloader.CpEntry{Type: classloader.UTF8, Slot: 3}
+	CP.InvokeDynamics = append(CP.InvokeDynamics, classloader.InvokeDynamicEntry{BootstrapIndex: 0, NameAndType: 2})
+	CP.NameAndTypes = append(CP.NameAndTypes,
+		classloader.NameAndTypeEntry{NameIndex: 3, DescIndex: 4},
+		classloader.NameAndTypeEntry{NameIndex: 3, DescIndex: 10})
+	CP.MethodHandles = append(CP.MethodHandles, classloader.MethodHandleEntry{RefKind: 6, RefIndex: 6})
+	CP.MethodRefs = append(CP.MethodRefs, classloader.MethodRefEntry{ClassIndex: 7, NameAndType: 9})
+	CP.ClassRefs = append(CP.ClassRefs, 8)
+	CP.Utf8Refs = append(CP.Utf8Refs, bsmName, methType, bsmClass, "()Ljava/lang/invoke/CallSite;")
+
+	bootstrap := classloader.BootstrapMethod{MethodRef: 5}
+	for _, arg := range bsmArgs {
+		bootstrap.Args = append(bootstrap.Args, uint16(len(CP.CpIndex)))
+		CP.CpIndex = append(CP.CpIndex, classloader.CpEntry{Type: classloader.UTF8, Slot: uint16(len(CP.Utf8Refs))})
+		CP.Utf8Refs = append(CP.Utf8Refs, arg)
+	}
+
+	data := classloader.ClData{Name: "IndyTest", Superclass: "java/lang/Object", CP: CP,
+		MethodTable: map[string]*classloader.Method{}}
+	data.Bootstraps = append(data.Bootstraps, bootstrap)
+	classloader.MethAreaInsert("IndyTest", &classloader.Klass{Status: 'X', Loader: "bootstrap", Data: &data})
+
+	f := frames.CreateFrame(16)
+	f.Ftype = 'J'
+	f.ClName = "IndyTest"
+	f.CP = &data.CP
+	f.Meth = append(f.Meth, INVOKEDYNAMIC, 0x00, 0x01, 0x00, 0x00) // CP entry 1, followed by two zeros
+	return f
+}
+
+// adds the bootstrap arguments of LambdaMetafactory.metafactory() to a call site created by
+// setupInvokedynamicTest(): the SAM type, and a method handle of kind refKind that points
+// to the implementation method implClass.implName with type implType. The call site is
+// renamed to samName, the name of the functional interface's method.
+func addLambdaBootstrapArgs(f *frames.Frame, samName, samType string, refKind uint16,
+	implClass, implName, implType string) {
+	CP := f.CP
+	addEntry := func(entryType uint16, slot int) uint16 {
+		CP.CpIndex = append(CP.CpIndex, classloader.CpEntry{Type: entryType, Slot: uint16(slot)})
+		return uint16(len(CP.CpIndex) - 1)
+	}
+	addUTF8 := func(s string) uint16 {
+		CP.Utf8Refs = append(CP.Utf8Refs, s)
+		return addEntry(classloader.UTF8, len(CP.Utf8Refs)-1)
+	}
+
+	CP.NameAndTypes[0].NameIndex = addUTF8(samName)
+	CP.MethodTypes = append(CP.MethodTypes, addUTF8(samType))
+	samIndex := addEntry(classloader.MethodType, len(CP.MethodTypes)-1)
+
+	CP.ClassRefs = append(CP.ClassRefs, addUTF8(implClass))
+	classIndex := addEntry(classloader.ClassRef, len(CP.ClassRefs)-1)
+	CP.NameAndTypes = append(CP.NameAndTypes,
+		classloader.NameAndTypeEntry{NameIndex: addUTF8(implName), DescIndex: addUTF8(implType)})
+	nAndTIndex := addEntry(classloader.NameAndType, len(CP.NameAndTypes)-1)
+	CP.MethodRefs = append(CP.MethodRefs, classloader.MethodRefEntry{ClassIndex: classIndex, NameAndType: nAndTIndex})
+	methRefIndex := addEntry(classloader.MethodRef, len(CP.MethodRefs)-1)
+	CP.MethodHandles = append(CP.MethodHandles, classloader.MethodHandleEntry{RefKind: refKind, RefIndex: methRefIndex})
+	handleIndex := addEntry(classloader.MethodHandle, len(CP.MethodHandles)-1)
+
+	k := classloader.MethAreaFetch(f.ClName)
+	k.Data.Bootstraps[0].Args = []uint16{samIndex, handleIndex, samIndex}
+}
+
+// runs the method methName with type methType on the object obj, passing it args,
+// and returns the value the method returns
+func runLambdaMethod(t *testing.T, obj *object.Object, methName, methType string, args ...interface{}) interface{} {
+	mtEntry, err := classloader.FetchMethodAndCP(*obj.Klass, methName, methType)
+	if err != nil || mtEntry.Meth == nil {
+		t.Fatalf("INVOKEDYNAMIC: Did not find %s.%s%s in the lambda class: %v", *obj.Klass, methName, methType, err)
+	}
+	m := mtEntry.Meth.(classloader.JmEntry)
+
+	caller := frames.CreateFrame(6)
+	push(caller, obj)
+	for _, arg := range args {
+		push(caller, arg)
+	}
+	fram, err := createAndInitNewFrame(*obj.Klass, methName, methType, &m, true, caller)
+	if err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Error creating frame for lambda method: %s", err.Error())
+	}
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(caller)
+	fs.PushFront(fram)
+	if err = runFrameToCompletion(fs); err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Got unexpected error running lambda method: %s", err.Error())
+	}
+	return pop(caller)
+}
+
+// INVOKEDYNAMIC: a lambda that captures a value, x -> add(captured, x), where add() is static
+func TestInvokedynamicLambdaCapturing(t *testing.T) {
+	f := setupInvokedynamicTest("java/lang/invoke/LambdaMetafactory", "metafactory",
+		"(I)Ljava/util/function/IntUnaryOperator;")
+	addLambdaBootstrapArgs(f, "applyAsInt", "(I)I", refInvokeStatic, "IndyTest", "add", "(II)I")
+	delete(classloader.MTable, "IndyTest.add(II)I") // remove any method cached by previous tests
+	classloader.MethAreaFetch("IndyTest").Data.MethodTable["add(II)I"] = &classloader.Method{
+		AccessFlags: classloader.AccPublic | classloader.AccStatic,
+		CodeAttr:    classloader.CodeAttrib{MaxStack: 2, MaxLocals: 2, Code: []byte{ILOAD_0, ILOAD_1, IADD, IRETURN}}}
+
+	push(f, int64(10)) // the captured value
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Got unexpected error: %s", err.Error())
+	}
+
+	if f.TOS != 0 {
+		t.Fatalf("INVOKEDYNAMIC: Expected TOS to be 0, got: %d", f.TOS)
+	}
+	lambda := pop(f).(*object.Object)
+	if !strings.HasPrefix(*lambda.Klass, "IndyTest$$Lambda$") {
+		t.Errorf("INVOKEDYNAMIC: Expected an object of a synthetic lambda class, got: %s", *lambda.Klass)
+	}
+	if len(lambda.Fields) != 1 || lambda.Fields[0].Fvalue != int64(10) {
+		t.Errorf("INVOKEDYNAMIC: Expected the captured value 10 in the lambda's fields, got: %v", lambda.Fields)
+	}
+	if !classloader.ImplementsInterface(*lambda.Klass, "java/util/function/IntUnaryOperator") {
+		t.Errorf("INVOKEDYNAMIC: Expected the lambda class to implement IntUnaryOperator")
+	}
+
+	result := runLambdaMethod(t, lambda, "applyAsInt", "(I)I", int64(5))
+	if result != int64(15) {
+		t.Errorf("INVOKEDYNAMIC: Expected the lambda to return 15, got: %v", result)
+	}
+
+	// executing the call site again reuses the class, but captures the new value
+	f.PC = 0
+	push(f, int64(20))
+	fs.PushFront(f)
+	if err = runFrame(fs); err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Got unexpected error: %s", err.Error())
+	}
+	lambda2 := pop(f).(*object.Object)
+	if *lambda2.Klass != *lambda.Klass {
+		t.Errorf("INVOKEDYNAMIC: Expected the call site to be linked once, but got classes %s and %s",
+			*lambda.Klass, *lambda2.Klass)
+	}
+	if lambda2 == lambda || lambda2.Fields[0].Fvalue != int64(20) {
+		t.Errorf("INVOKEDYNAMIC: Expected a new lambda object capturing 20, got: %v", lambda2.Fields)
+	}
+}
+
+// INVOKEDYNAMIC: a non-capturing constructor reference, Point::new, which is always
+// the same object
+func TestInvokedynamicLambdaConstructorRef(t *testing.T) {
+	f := setupInvokedynamicTest("java/lang/invoke/LambdaMetafactory", "metafactory",
+		"()Ljava/util/function/Supplier;")
+	addLambdaBootstrapArgs(f, "get", "()Ljava/lang/Object;", refNewInvokeSpecial, "Point", "<init>", "()V")
+	delete(classloader.MTable, "Point.<init>()V") // remove any method cached by previous tests
+	data := classloader.ClData{Name: "Point", Superclass: "java/lang/Object",
+		MethodTable: map[string]*classloader.Method{"<init>()V": {AccessFlags: classloader.AccPublic,
+			CodeAttr: classloader.CodeAttrib{MaxStack: 1, MaxLocals: 1, Code: []byte{RETURN}}}}}
+	classloader.MethAreaInsert("Point", &classloader.Klass{Status: 'X', Loader: "bootstrap", Data: &data})
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Got unexpected error: %s", err.Error())
+	}
+	lambda := pop(f).(*object.Object)
+
+	f.PC = 0
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Got unexpected error: %s", err.Error())
+	}
+	if pop(f).(*object.Object) != lambda {
+		t.Errorf("INVOKEDYNAMIC: Expected a non-capturing lambda to be the same object every time")
+	}
+
+	point, ok := runLambdaMethod(t, lambda, "get", "()Ljava/lang/Object;").(*object.Object)
+	if !ok || point == nil || *point.Klass != "Point" {
+		t.Errorf("INVOKEDYNAMIC: Expected Supplier.get() to return a new Point, got: %v", point)
+	}
+}
+
+// INVOKEDYNAMIC: string concatenation with arguments of every primitive type and a constant
+func TestInvokedynamicStringConcat(t *testing.T) {
+	f := setupInvokedynamicTest("java/lang/invoke/StringConcatFactory", "makeConcatWithConstants",
+		"(BSIJFDZCLjava/lang/String;Ljava/lang/Object;)Ljava/lang/String;",
+		"\u0001|\u0001|\u0001|\u0001|\u0001|\u0001|\u0001|\u0001|\u0001|\u0001|\u0002", "const\u0001")
+	push(f, int64(-5))
+	push(f, int64(300))
+	push(f, int64(42))
+	push(f, int64(-9000000000))
+	push(f, int64(-9000000000))
+	push(f, float64(float32(1.1)))
+	push(f, 1e10)
+	push(f, 1e10)
+	push(f, types.JavaBoolTrue)
+	push(f, int64('q'))
+	s := "hello"
+	str := object.CreateCompactStringFromGoString(&s)
+	str.Klass = &object.StringClassName
+	push(f, str)
+	push(f, object.Null)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Got unexpected error: %s", err.Error())
+	}
+
+	if f.TOS != 0 {
+		t.Fatalf("INVOKEDYNAMIC: Expected TOS to be 0, got: %d", f.TOS)
+	}
+	result := pop(f).(*object.Object)
+	if !object.IsJavaString(result) {
+		t.Fatalf("INVOKEDYNAMIC: Expected a string result")
+	}
+	expected := "-5|300|42|-9000000000|1.1|1.0E10|true|q|hello|null|const\u0001"
+	if object.GetGoStringFromJavaStringPtr(result) != expected {
+		t.Errorf("INVOKEDYNAMIC: Expected %q, got: %q", expected, object.GetGoStringFromJavaStringPtr(result))
+	}
+}
+
+// INVOKEDYNAMIC: string concatenation of an object whose class overrides toString()
+func TestInvokedynamicStringConcatToString(t *testing.T) {
+	f := setupInvokedynamicTest("java/lang/invoke/StringConcatFactory", "makeConcatWithConstants",
+		"(Ljava/lang/Object;)Ljava/lang/String;", "p=\u0001")
+	delete(classloader.MTable, "Point.toString()Ljava/lang/String;") // remove any method cached by previous tests
+	delete(classloader.MTable, selectedMethodKey("Point", "toString", "()Ljava/lang/String;"))
+
+	// Point.toString() returns the string in entry [1] of its CP
+	pointCP := classloader.CPool{}
+	pointCP.CpIndex = []classloader.CpEntry{{}, {Type: classloader.UTF8, Slot: 0}}
+	pointCP.Utf8Refs = []string{"Point(1, 2)"}
+	toString := &classloader.Method{AccessFlags: classloader.AccPublic,
+		CodeAttr: classloader.CodeAttrib{MaxStack: 1, MaxLocals: 1, Code: []byte{LDC, 0x01, ARETURN}}}
+	data := classloader.ClData{Name: "Point", Superclass: "java/lang/Object", CP: pointCP,
+		MethodTable: map[string]*classloader.Method{"toString()Ljava/lang/String;": toString}}
+	classloader.MethAreaInsert("Point", &classloader.Klass{Status: 'X', Loader: "bootstrap", Data: &data})
+	classloader.MethAreaInsert("java/lang/String", &classloader.Klass{Status: 'X', Loader: "bootstrap",
+		Data: &classloader.ClData{Name: "java/lang/String"}})
+
+	className := "Point"
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	push(f, obj)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Got unexpected error: %s", err.Error())
+	}
+
+	if fs.Len() != 1 {
+		t.Errorf("INVOKEDYNAMIC: Expected toString() frame to be popped, got %d frames", fs.Len())
+	}
+	result := pop(f).(*object.Object)
+	if object.GetGoStringFromJavaStringPtr(result) != "p=Point(1, 2)" {
+		t.Errorf("INVOKEDYNAMIC: Expected \"p=Point(1, 2)\", got: %q", object.GetGoStringFromJavaStringPtr(result))
+	}
+}
+
+// INVOKEDYNAMIC: an exception thrown by toString() in a string concatenation is thrown
+// by the INVOKEDYNAMIC, so the method that does the concatenation can catch it
+func TestInvokedynamicStringConcatToStringThrows(t *testing.T) {
+	f := setupInvokedynamicTest("java/lang/invoke/StringConcatFactory", "makeConcatWithConstants",
+		"(Ljava/lang/Object;)Ljava/lang/String;", "p=\u0001")
+	delete(classloader.MTable, "Point.toString()Ljava/lang/String;") // remove any method cached by previous tests
+	delete(classloader.MTable, selectedMethodKey("Point", "toString", "()Ljava/lang/String;"))
+
+	// Point.toString() does: throw null, which throws a NullPointerException
+	toString := &classloader.Method{AccessFlags: classloader.AccPublic,
+		CodeAttr: classloader.CodeAttrib{MaxStack: 1, MaxLocals: 1, Code: []byte{ACONST_NULL, ATHROW}}}
+	data := classloader.ClData{Name: "Point", Superclass: "java/lang/Object",
+		MethodTable: map[string]*classloader.Method{"toString()Ljava/lang/String;": toString}}
+	classloader.MethAreaInsert("Point", &classloader.Klass{Status: 'X', Loader: "bootstrap", Data: &data})
+
+	// try { "p=" + point; } catch (NullPointerException e) { ... }, with the handler at 6
+	f.CP.CpIndex = append(f.CP.CpIndex,
+		classloader.CpEntry{Type: classloader.UTF8, Slot: uint16(len(f.CP.Utf8Refs))},
+		classloader.CpEntry{Type: classloader.ClassRef, Slot: uint16(len(f.CP.ClassRefs))})
+	f.CP.Utf8Refs = append(f.CP.Utf8Refs, "java/lang/NullPointerException")
+	f.CP.ClassRefs = append(f.CP.ClassRefs, uint16(len(f.CP.CpIndex)-2))
+	f.Meth = append(f.Meth, RETURN, ASTORE_0, RETURN)
+	f.Locals = []interface{}{nil}
+	f.ExcTable = append(f.ExcTable, classloader.CodeException{StartPc: 0, EndPc: 5, HandlerPc: 6,
+		CatchType: uint16(len(f.CP.CpIndex) - 1)})
+
+	className := "Point"
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	push(f, obj)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("INVOKEDYNAMIC: Expected the exception to be caught, got error: %s", err.Error())
+	}
+
+	if fs.Len() != 1 {
+		t.Errorf("INVOKEDYNAMIC: Expected only the caller's frame to be left, got %d frames", fs.Len())
+	}
+	exc, ok := f.Locals[0].(*object.Object)
+	if !ok || exc == nil || *exc.Klass != "java/lang/NullPointerException" {
+		t.Errorf("INVOKEDYNAMIC: Expected the handler to store a NullPointerException, got: %v", f.Locals[0])
+	}
+	if f.TOS != -1 {
+		t.Errorf("INVOKEDYNAMIC: Expected an empty operand stack after the handler, got TOS %d", f.TOS)
+	}
+}
+
+// INVOKEDYNAMIC: a bootstrap method that Jacobin does not handle should return an error
+func TestInvokedynamicUnsupportedBootstrap(t *testing.T) {
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	f := setupInvokedynamicTest("java/lang/runtime/ObjectMethods", "bootstrap",
+		"(LIndyTest;)Ljava/lang/String;")
+	push(f, object.Null)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if err == nil {
+		t.Fatalf("INVOKEDYNAMIC: Expected an error for an unsupported bootstrap method")
+	}
+	if !strings.Contains(err.Error(), "java/lang/runtime/ObjectMethods.bootstrap") {
+		t.Errorf("INVOKEDYNAMIC: Expected error to name the bootstrap method, got: %s", err.Error())
+	}
+}
+
+// sets up the classes used in the INVOKEINTERFACE tests: interface Iface, which declares
+// the abstract method abs() and the default method dflt(); class Impl, which implements
+// both; class NoImpl, which implements Iface but not abs(); class Hidden, which implements
+// Iface with a package-private abs(); and class Other, which does not implement Iface.
+// All methods return an int. Returns the frame of a method that
+// invokes methName on Iface and has the object reference obj on its stack.
+func setupInvokeinterfaceTest(methName string, obj *object.Object) *frames.Frame {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+	for _, key := range []string{"Impl.abs()I", "Impl.dflt()I", "Iface.dflt()I", "NoImpl.abs()I", "Other.abs()I"} {
+		delete(classloader.MTable, key) // remove methods cached by previous tests
+	}
+	for _, className := range []string{"Impl", "NoImpl", "Hidden", "Other"} {
+		for _, meth := range []string{"abs", "dflt"} {
+			delete(classloader.MTable, selectedMethodKey(className, meth, "()I"))
+		}
+	}
+
+	returnInt := func(val byte) *classloader.Method {
+		return &classloader.Method{AccessFlags: classloader.AccPublic,
+			CodeAttr: classloader.CodeAttrib{MaxStack: 1, MaxLocals: 1, Code: []byte{BIPUSH, val, IRETURN}}}
+	}
+	insertClass := func(name string, isInterface bool, meths map[string]*classloader.Method, ifaces ...string) {
+		data := classloader.ClData{Name: name, Superclass: "java/lang/Object", MethodTable: meths}
+		if name == "java/lang/Object" {
+			data.Superclass = ""
+		}
+		data.Access.ClassIsInterface = isInterface
+		for i, iface := range ifaces {
+			data.CP.Utf8Refs = append(data.CP.Utf8Refs, iface)
+			data.Interfaces = append(data.Interfaces, uint16(i))
+		}
+		classloader.MethAreaInsert(name, &classloader.Klass{Status: 'X', Loader: "bootstrap", Data: &data})
+	}
+
+	insertClass("java/lang/Object", false, map[string]*classloader.Method{})
+	insertClass("Iface", true, map[string]*classloader.Method{
+		"abs()I":  {AccessFlags: classloader.AccPublic | classloader.AccAbstract},
+		"dflt()I": returnInt(7),
+	})
+	insertClass("Impl", false, map[string]*classloader.Method{"abs()I": returnInt(5)}, "Iface")
+	insertClass("NoImpl", false, map[string]*classloader.Method{}, "Iface")
+	hiddenAbs := returnInt(6)
+	hiddenAbs.AccessFlags = 0
+	insertClass("Hidden", false, map[string]*classloader.Method{"abs()I": hiddenAbs}, "Iface")
+	insertClass("Other", false, map[string]*classloader.Method{"abs()I": returnInt(9)})
+
+	// the CP: [1] interface method ref -> [2] class ref to Iface and [4] name and type
+	CP := classloader.CPool{}
+	CP.CpIndex = make([]classloader.CpEntry, 7)
+	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.Interface, Slot: 0}
+	CP.CpIndex[2] = classloader.CpEntry{Type: classloader.ClassRef, Slot: 0}
+	CP.CpIndex[3] = classloader.CpEntry{Type: classloader.UTF8, Slot: 0}
+	CP.CpIndex[4] = classloader.CpEntry{Type: classloader.NameAndType, Slot: 0}
+	CP.CpIndex[5] = classloader.CpEntry{Type: classloader.UTF8, Slot: 1}
+	CP.CpIndex[6] = classloader.CpEntry{Type: classloader.UTF8, Slot: 2}
+	CP.InterfaceRefs = append(CP.InterfaceRefs, classloader.InterfaceRefEntry{ClassIndex: 2, NameAndType: 4})
+	CP.ClassRefs = append(CP.ClassRefs, 3)
+	CP.NameAndTypes = append(CP.NameAndTypes, classloader.NameAndTypeEntry{NameIndex: 5, DescIndex: 6})
+	CP.Utf8Refs = append(CP.Utf8Refs, "Iface", methName, "()I")
+
+	f := newFrame(INVOKEINTERFACE)
+	f.Meth = append(f.Meth, 0x00, 0x01, 0x01, 0x00) // CP entry 1, 1 arg slot (the object ref), 0
+	f.CP = &CP
+	push(&f, obj)
+	return &f
+}
+
+// INVOKEINTERFACE: invoke a method implemented in the object's class
+func TestInvokeinterfaceClassMethod(t *testing.T) {
+	className := "Impl"
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	f := setupInvokeinterfaceTest("abs", obj)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("INVOKEINTERFACE: Got unexpected error: %s", err.Error())
+	}
+
+	if fs.Len() != 1 {
+		t.Errorf("INVOKEINTERFACE: Expected the called method's frame to be popped, got %d frames", fs.Len())
+	}
+	if f.PC != 5 {
+		t.Errorf("INVOKEINTERFACE: Expected PC to be 5, got: %d", f.PC)
+	}
+	value := pop(f).(int64)
+	if value != 5 {
+		t.Errorf("INVOKEINTERFACE: Expected Impl.abs() to return 5, got: %d", value)
+	}
+
+	// the selected method should now be cached in the MTable under the object's class
+	if classloader.MTable[selectedMethodKey("Impl", "abs", "()I")].Meth == nil {
+		t.Errorf("INVOKEINTERFACE: Expected Impl.abs()I to be cached in the MTable")
+	}
+}
+
+// INVOKEINTERFACE: invoke a default method declared in the interface
+func TestInvokeinterfaceDefaultMethod(t *testing.T) {
+	className := "Impl"
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	f := setupInvokeinterfaceTest("dflt", obj)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("INVOKEINTERFACE: Got unexpected error: %s", err.Error())
+	}
+
+	value := pop(f).(int64)
+	if value != 7 {
+		t.Errorf("INVOKEINTERFACE: Expected default method Iface.dflt() to return 7, got: %d", value)
+	}
+
+	called := classloader.MTable[selectedMethodKey("Impl", "dflt", "()I")].Meth.(classloader.JmEntry)
+	if called.ClName != "Iface" {
+		t.Errorf("INVOKEINTERFACE: Expected the default method to be selected from class Iface, got: %s",
+			called.ClName)
+	}
+}
+
+// runs the frame of an invocation test, which holds only the invoking instruction, with
+// a handler that catches the named error and stores it in local 0. Returns the caught error.
+func runInvocationCaught(t *testing.T, f *frames.Frame, excClass string) *object.Object {
+	f.CP.CpIndex = append(f.CP.CpIndex,
+		classloader.CpEntry{Type: classloader.UTF8, Slot: uint16(len(f.CP.Utf8Refs))},
+		classloader.CpEntry{Type: classloader.ClassRef, Slot: uint16(len(f.CP.ClassRefs))})
+	f.CP.Utf8Refs = append(f.CP.Utf8Refs, excClass)
+	f.CP.ClassRefs = append(f.CP.ClassRefs, uint16(len(f.CP.CpIndex)-2))
+	end := len(f.Meth)
+	f.Meth = append(f.Meth, RETURN, ASTORE_0, RETURN) // the handler follows the RETURN
+	f.Locals = []interface{}{nil}
+	f.ExcTable = append(f.ExcTable, classloader.CodeException{StartPc: 0, EndPc: end, HandlerPc: end + 1,
+		CatchType: uint16(len(f.CP.CpIndex) - 1)})
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("Expected the %s to be caught, got error: %s", excClass, err.Error())
+	}
+
+	exc, ok := f.Locals[0].(*object.Object)
+	if !ok || exc == nil || *exc.Klass != excClass {
+		t.Fatalf("Expected the handler to store a %s, got: %v", excClass, f.Locals[0])
+	}
+	return exc
+}
+
+// INVOKEINTERFACE: an abstract method with no implementation throws an AbstractMethodError
+func TestInvokeinterfaceAbstractMethodError(t *testing.T) {
+	className := "NoImpl"
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	f := setupInvokeinterfaceTest("abs", obj)
+
+	exc := runInvocationCaught(t, f, "java/lang/AbstractMethodError")
+	if !strings.Contains(getThrowableMessage(exc), "does not define or inherit an implementation") {
+		t.Errorf("INVOKEINTERFACE: Got unexpected AbstractMethodError message: %q", getThrowableMessage(exc))
+	}
+}
+
+// INVOKEINTERFACE: a selected method that's not public causes an IllegalAccessError, even
+// if it has already been selected for the object's class by an INVOKEVIRTUAL
+func TestInvokeinterfaceNonPublicMethod(t *testing.T) {
+	className := "Hidden"
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	f := setupInvokeinterfaceTest("abs", obj)
+	if _, _, err := locateVirtualMethod("Hidden", "Hidden", "abs", "()I"); err != nil {
+		t.Fatalf("INVOKEINTERFACE: Got unexpected error selecting Hidden.abs(): %s", err.Error())
+	}
+
+	exc := runInvocationCaught(t, f, "java/lang/IllegalAccessError")
+	expected := "Receiver class Hidden does not have a public implementation of Iface.abs()I"
+	if msg := getThrowableMessage(exc); msg != expected {
+		t.Errorf("INVOKEINTERFACE: Expected IllegalAccessError message %q, got: %q", expected, msg)
+	}
+}
+
+// INVOKEINTERFACE: an object whose class does not implement the interface causes an
+// IncompatibleClassChangeError, even if it has a method of the same name and type, and
+// even if that method has already been selected for its class by an INVOKEVIRTUAL
+func TestInvokeinterfaceIncompatibleClass(t *testing.T) {
+	className := "Other"
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	f := setupInvokeinterfaceTest("abs", obj)
+	mtEntry, err := classloader.FetchMethodAndCP("Other", "abs", "()I")
+	if err != nil {
+		t.Fatalf("INVOKEINTERFACE: Got unexpected error fetching Other.abs(): %s", err.Error())
+	}
+	classloader.MTableInsert("Other.abs()I", mtEntry)
+
+	exc := runInvocationCaught(t, f, "java/lang/IncompatibleClassChangeError")
+	expected := "Class Other does not implement the requested interface Iface"
+	if msg := getThrowableMessage(exc); msg != expected {
+		t.Errorf("INVOKEINTERFACE: Expected the message %q, got: %q", expected, msg)
+	}
+}
+
+// INVOKEINTERFACE: a null object reference
+func TestInvokeinterfaceNullObject(t *testing.T) {
+	f := setupInvokeinterfaceTest("abs", object.Null)
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if err == nil || !strings.Contains(err.Error(), "java.lang.NullPointerException") {
+		t.Errorf("INVOKEINTERFACE: Expected an error for a null object reference, got: %v", err)
+	}
+}
+
+// sets up class Recurse, whose static method r(I)I has the given bytecode and calls
+// itself through CP entry 1. Returns the frame of a method that invokes r() with arg.
+func setupRecursionTest(code []byte, maxStack int, arg int64) *frames.Frame {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+	delete(classloader.MTable, "Recurse.r(I)I") // remove any method cached by previous tests
+
+	r := &classloader.Method{AccessFlags: classloader.AccPublic | classloader.AccStatic,
+		CodeAttr: classloader.CodeAttrib{MaxStack: maxStack, MaxLocals: 1, Code: code}}
+
+	// the CP: [1] method ref -> [2] class ref to Recurse and [4] name and type
+	CP := classloader.CPool{}
+	CP.CpIndex = make([]classloader.CpEntry, 7)
+	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.MethodRef, Slot: 0}
+	CP.CpIndex[2] = classloader.CpEntry{Type: classloader.ClassRef, Slot: 0}
+	CP.CpIndex[3] = classloader.CpEntry{Type: classloader.UTF8, Slot: 0}
+	CP.CpIndex[4] = classloader.CpEntry{Type: classloader.NameAndType, Slot: 0}
+	CP.CpIndex[5] = classloader.CpEntry{Type: classloader.UTF8, Slot: 1}
+	CP.CpIndex[6] = classloader.CpEntry{Type: classloader.UTF8, Slot: 2}
+	CP.MethodRefs = append(CP.MethodRefs, classloader.MethodRefEntry{ClassIndex: 2, NameAndType: 4})
+	CP.ClassRefs = append(CP.ClassRefs, 3)
+	CP.NameAndTypes = append(CP.NameAndTypes, classloader.NameAndTypeEntry{NameIndex: 5, DescIndex: 6})
+	CP.Utf8Refs = append(CP.Utf8Refs, "Recurse", "r", "(I)I")
+
+	data := classloader.ClData{Name: "Recurse", Superclass: "java/lang/Object", CP: CP,
+		MethodTable: map[string]*classloader.Method{"r(I)I": r}, ClInit: types.ClInitRun}
+	classloader.MethAreaInsert("Recurse", &classloader.Klass{Status: 'X', Loader: "bootstrap", Data: &data})
+
+	f := newFrame(INVOKESTATIC)
+	f.Meth = append(f.Meth, 0x00, 0x01) // CP entry 1
+	f.CP = &data.CP
+	push(&f, arg)
+	return &f
+}
+
+// INVOKESTATIC: deep recursion runs in a single interpreter loop, so it's limited by
+// the size of the frame stack rather than by the size of the golang stack
+func TestInvokestaticDeepRecursion(t *testing.T) {
+	// static int r(int n) { return n == 0 ? 0 : r(n - 1) + 1; }
+	code := []byte{
+		ILOAD_0, IFNE, 0x00, 0x05, // if n != 0, go to the recursive call
+		ICONST_0, IRETURN,
+		ILOAD_0, ICONST_1, ISUB, INVOKESTATIC, 0x00, 0x01,
+		ICONST_1, IADD, IRETURN,
+	}
+	depth := int64(100_000)
+	f := setupRecursionTest(code, 2, depth)
+
+	// a recursive interpreter would need far more than this to run 100,000 nested calls
+	prevMaxStack := debug.SetMaxStack(16 * 1024 * 1024)
+	defer debug.SetMaxStack(prevMaxStack)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("INVOKESTATIC: Got unexpected error: %s", err.Error())
+	}
+
+	if fs.Len() != 1 {
+		t.Errorf("INVOKESTATIC: Expected all the called frames to be popped, got %d frames", fs.Len())
+	}
+	value := pop(f).(int64)
+	if value != depth {
+		t.Errorf("INVOKESTATIC: Expected recursion depth of %d, got: %d", depth, value)
+	}
+}
+
+// INVOKESTATIC: a recursive Fibonacci, in which each invocation makes two recursive calls
+func TestInvokestaticRecursiveFibonacci(t *testing.T) {
+	// static int r(int n) { return n < 2 ? n : r(n - 1) + r(n - 2); }
+	code := []byte{
+		ILOAD_0, ICONST_2, IF_ICMPGE, 0x00, 0x05, // if n >= 2, go to the recursive calls
+		ILOAD_0, IRETURN,
+		ILOAD_0, ICONST_1, ISUB, INVOKESTATIC, 0x00, 0x01,
+		ILOAD_0, ICONST_2, ISUB, INVOKESTATIC, 0x00, 0x01,
+		IADD, IRETURN,
+	}
+	f := setupRecursionTest(code, 3, 20)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("INVOKESTATIC: Got unexpected error: %s", err.Error())
+	}
+
+	value := pop(f).(int64)
+	if value != 6765 {
+		t.Errorf("INVOKESTATIC: Expected fib(20) to be 6765, got: %d", value)
+	}
+}
+
+// INVOKESTATIC: runaway recursion throws a StackOverflowError when the frame stack
+// reaches its maximum depth, which the Java program can catch
+func TestInvokestaticStackOverflowCaught(t *testing.T) {
+	// static int r(int n) { return r(n + 1); }
+	code := []byte{ILOAD_0, ICONST_1, IADD, INVOKESTATIC, 0x00, 0x01, IRETURN}
+	f := setupRecursionTest(code, 2, 0)
+
+	// the calling method catches the error: try { r(0); } catch (StackOverflowError e) { return 42; }
+	f.CP.CpIndex = append(f.CP.CpIndex,
+		classloader.CpEntry{Type: classloader.UTF8, Slot: uint16(len(f.CP.Utf8Refs))},
+		classloader.CpEntry{Type: classloader.ClassRef, Slot: uint16(len(f.CP.ClassRefs))})
+	f.CP.Utf8Refs = append(f.CP.Utf8Refs, "java/lang/StackOverflowError")
+	f.CP.ClassRefs = append(f.CP.ClassRefs, uint16(len(f.CP.CpIndex)-2))
+	f.Meth = append(f.Meth, RETURN, POP, BIPUSH, 42) // the handler is at 4
+	f.ExcTable = append(f.ExcTable, classloader.CodeException{StartPc: 0, EndPc: 3, HandlerPc: 4,
+		CatchType: uint16(len(f.CP.CpIndex) - 1)})
+
+	prevMaxDepth := MainThread.MaxFrameDepth
+	MainThread.MaxFrameDepth = 100
+	defer func() { MainThread.MaxFrameDepth = prevMaxDepth }()
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("INVOKESTATIC: Got unexpected error: %s", err.Error())
+	}
+
+	if fs.Len() != 1 {
+		t.Errorf("INVOKESTATIC: Expected the recursive frames to be popped, got %d frames", fs.Len())
+	}
+	value := pop(f).(int64)
+	if value != 42 {
+		t.Errorf("INVOKESTATIC: Expected the StackOverflowError handler to push 42, got: %d", value)
+	}
+}
+
+// INVOKESTATIC: the maximum depth of the frame stack is that of the thread that runs it
+func TestInvokestaticStackOverflowPerThread(t *testing.T) {
+	// static int r(int n) { return n == 0 ? 0 : r(n - 1) + 1; }
+	code := []byte{
+		ILOAD_0, IFNE, 0x00, 0x05, // if n != 0, go to the recursive call
+		ICONST_0, IRETURN,
+		ILOAD_0, ICONST_1, ISUB, INVOKESTATIC, 0x00, 0x01,
+		ICONST_1, IADD, IRETURN,
+	}
+	f := setupRecursionTest(code, 2, 50)
+
+	prevMainThread := MainThread
+	defer func() { MainThread = prevMainThread }()
+	MainThread.MaxFrameDepth = 100
+	thread.AddThreadToTable(&MainThread, &globals.GetGlobalRef().Threads)
+	shallow := thread.CreateThread()
+	shallow.MaxFrameDepth = 20
+	thread.AddThreadToTable(&shallow, &globals.GetGlobalRef().Threads)
+
+	// on the main thread, 50 nested calls are within its maximum depth
+	f.Thread = MainThread.ID
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("INVOKESTATIC: Got unexpected error on the main thread: %s", err.Error())
+	}
+	if value := pop(f).(int64); value != 50 {
+		t.Errorf("INVOKESTATIC: Expected recursion depth of 50, got: %d", value)
+	}
+
+	// on the other thread, they're not
+	f = setupRecursionTest(code, 2, 50)
+	thread.AddThreadToTable(&MainThread, &globals.GetGlobalRef().Threads)
+	thread.AddThreadToTable(&shallow, &globals.GetGlobalRef().Threads)
+	f.Thread = shallow.ID
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	fs = frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if err == nil || !strings.Contains(err.Error(), "java.lang.StackOverflowError") {
+		t.Errorf("INVOKESTATIC: Expected a StackOverflowError on a thread with a maximum depth of 20, got: %v", err)
+	}
+}
+
+// INVOKESTATIC: an uncaught StackOverflowError ends the program with an error
+func TestInvokestaticStackOverflowUncaught(t *testing.T) {
+	code := []byte{ILOAD_0, ICONST_1, IADD, INVOKESTATIC, 0x00, 0x01, IRETURN}
+	f := setupRecursionTest(code, 2, 0)
+
+	prevMaxDepth := MainThread.MaxFrameDepth
+	MainThread.MaxFrameDepth = 50
+	defer func() { MainThread.MaxFrameDepth = prevMaxDepth }()
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if err == nil || !strings.Contains(err.Error(), "java.lang.StackOverflowError") {
+		t.Errorf("INVOKESTATIC: Expected an uncaught StackOverflowError, got: %v", err)
+	}
+	if !strings.Contains(string(out), "Exception in thread \"main\" java.lang.StackOverflowError") {
+		t.Errorf("INVOKESTATIC: Expected a report of the uncaught StackOverflowError, got: %s", string(out))
+	}
+	if fs.Len() != 50 {
+		t.Errorf("INVOKESTATIC: Expected the frame stack to be at its maximum depth of 50, got: %d", fs.Len())
+	}
+}
+
+// INVOKESTATIC: a synchronized static method holds the monitor of its class while it
+// runs. Recursive calls reenter the monitor, which is released when they all return.
+func TestInvokestaticSynchronizedMethod(t *testing.T) {
+	// static synchronized int r(int n) { return n == 0 ? 0 : r(n - 1) + 1; }
+	code := []byte{
+		ILOAD_0, IFNE, 0x00, 0x05, // if n != 0, go to the recursive call
+		ICONST_0, IRETURN,
+		ILOAD_0, ICONST_1, ISUB, INVOKESTATIC, 0x00, 0x01,
+		ICONST_1, IADD, IRETURN,
+	}
+	f := setupRecursionTest(code, 2, 10)
+	classloader.MethAreaFetch("Recurse").Data.MethodTable["r(I)I"].AccessFlags |= classloader.AccSynchronized
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("INVOKESTATIC: Got unexpected error: %s", err.Error())
+	}
+
+	value := pop(f).(int64)
+	if value != 10 {
+		t.Errorf("INVOKESTATIC: Expected recursion depth of 10, got: %d", value)
+	}
+	if object.GetMonitor(classloader.GetClassMirror("Recurse")).IsOwnedBy(f.Thread) {
+		t.Errorf("INVOKESTATIC: Expected the class's monitor to be released when the methods return")
+	}
+}
+
+// INVOKESTATIC: the monitors of synchronized methods are released when an exception
+// unwinds through them
+func TestInvokestaticSynchronizedMethodUnwound(t *testing.T) {
+	// static synchronized int r(int n) { return r(n + 1); }
+	code := []byte{ILOAD_0, ICONST_1, IADD, INVOKESTATIC, 0x00, 0x01, IRETURN}
+	f := setupRecursionTest(code, 2, 0)
+	classloader.MethAreaFetch("Recurse").Data.MethodTable["r(I)I"].AccessFlags |= classloader.AccSynchronized
+
+	// the calling method catches the StackOverflowError: catch (Throwable t) { return 42; }
+	f.Meth = append(f.Meth, RETURN, POP, BIPUSH, 42) // the handler is at 4
+	f.ExcTable = append(f.ExcTable, classloader.CodeException{StartPc: 0, EndPc: 3, HandlerPc: 4})
+
+	prevMaxDepth := MainThread.MaxFrameDepth
+	MainThread.MaxFrameDepth = 20
+	defer func() { MainThread.MaxFrameDepth = prevMaxDepth }()
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("INVOKESTATIC: Got unexpected error: %s", err.Error())
+	}
+
+	value := pop(f).(int64)
+	if value != 42 {
+		t.Errorf("INVOKESTATIC: Expected the handler to push 42, got: %d", value)
+	}
+	if object.GetMonitor(classloader.GetClassMirror("Recurse")).IsOwnedBy(f.Thread) {
+		t.Errorf("INVOKESTATIC: Expected the class's monitor to be released by the exception")
+	}
+}
+
+// a synchronized instance method holds the monitor of its object while it runs and
+// throws an IllegalMonitorStateException if it returns after exiting that monitor
+func TestSynchronizedInstanceMethod(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	// synchronized void m() { monitorexit(this); }
+	jm := classloader.JmEntry{AccessFlags: classloader.AccPublic | classloader.AccSynchronized,
+		MaxStack: 1, MaxLocals: 1, Code: []byte{ALOAD_0, MONITOREXIT, RETURN}}
+	obj := object.MakeEmptyObject()
+	caller := newFrame(INVOKEVIRTUAL)
+	push(&caller, obj)
+
+	fram, err := createAndInitNewFrame("Sync", "m", "()V", &jm, true, &caller)
+	if err != nil {
+		t.Fatalf("Got unexpected error creating the frame: %s", err.Error())
+	}
+	if fram.SyncObj != obj || !object.GetMonitor(obj).IsOwnedBy(fram.Thread) {
+		t.Fatalf("Expected the synchronized method to hold the monitor of its object")
+	}
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&caller)
+	fs.PushFront(fram)
+	err = runFrame(fs)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if err == nil || !strings.Contains(err.Error(), "java.lang.IllegalMonitorStateException") {
+		t.Errorf("Expected an uncaught IllegalMonitorStateException, got: %v", err)
+	}
+	if object.GetMonitor(obj).IsOwnedBy(fram.Thread) {
+		t.Errorf("Expected the monitor to be released")
+	}
+}
+
+// a static synchronized method holds the monitor of its class's mirror, the object that
+// LDC pushes for Foo.class, so it excludes the code that's in synchronized (Foo.class)
+func TestSynchronizedStaticMethod(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+	data := classloader.ClData{Name: "Sync", Superclass: "java/lang/Object"}
+	classloader.MethAreaInsert("Sync", &classloader.Klass{Status: 'X', Loader: "bootstrap", Data: &data})
+
+	// static synchronized Object m() { return Sync.class; }
+	cp := newSyntheticCP()
+	syncClass := cp.classRef("Sync")
+	jm := classloader.JmEntry{AccessFlags: classloader.AccPublic | classloader.AccStatic | classloader.AccSynchronized,
+		MaxStack: 1, MaxLocals: 0, Code: []byte{LDC, byte(syncClass), ARETURN}, Cp: &cp.CPool}
+	caller := newFrame(INVOKESTATIC)
+
+	fram, err := createAndInitNewFrame("Sync", "m", "()Ljava/lang/Object;", &jm, false, &caller)
+	if err != nil {
+		t.Fatalf("Got unexpected error creating the frame: %s", err.Error())
+	}
+	lockObj := fram.SyncObj
+	if lockObj == nil || !object.GetMonitor(lockObj).IsOwnedBy(fram.Thread) {
+		t.Fatalf("Expected the synchronized method to hold the monitor of its class")
+	}
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&caller)
+	fs.PushFront(fram)
+	if err = runFrame(fs); err != nil {
+		t.Fatalf("Got unexpected error: %s", err.Error())
+	}
+
+	if mirror := pop(&caller); mirror != lockObj {
+		t.Errorf("Expected the method to hold the monitor of Sync.class, but it holds another's")
+	}
+	if object.GetMonitor(lockObj).IsOwnedBy(fram.Thread) {
+		t.Errorf("Expected the monitor to be released")
+	}
+}
+
+// sets up the classes used in the INVOKEVIRTUAL tests: class Base, which declares the
+// methods m(), fin() (final), priv() (private), and abs() (abstract); class Derived, which
+// extends Base and declares its own versions of the first three; class Leaf, which
+// extends Derived and declares no methods; and class Secret, which extends Base and
+// declares a private m(). Each method returns a distinct int. Returns the frame of a method
+// that invokes methName on Base and has the object reference obj on its stack.
+func setupInvokevirtualTest(methName string, obj *object.Object) *frames.Frame {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+	for _, className := range []string{"Base", "Derived", "Leaf", "Secret"} {
+		for _, meth := range []string{"m", "fin", "priv", "abs"} {
+			delete(classloader.MTable, className+"."+meth+"()I") // remove methods cached by previous tests
+			delete(classloader.MTable, selectedMethodKey(className, meth, "()I"))
+		}
+	}
+
+	returnInt := func(access int, val byte) *classloader.Method {
+		return &classloader.Method{AccessFlags: access,
+			CodeAttr: classloader.CodeAttrib{MaxStack: 1, MaxLocals: 1, Code: []byte{BIPUSH, val, IRETURN}}}
+	}
+	insertClass := func(name, superclass string, meths map[string]*classloader.Method) {
+		data := classloader.ClData{Name: name, Superclass: superclass, MethodTable: meths}
+		classloader.MethAreaInsert(name, &classloader.Klass{Status: 'X', Loader: "bootstrap", Data: &data})
+	}
+
+	insertClass("java/lang/Object", "", map[string]*classloader.Method{})
+	insertClass("Base", "java/lang/Object", map[string]*classloader.Method{
+		"m()I":    returnInt(classloader.AccPublic, 1),
+		"fin()I":  returnInt(classloader.AccPublic|classloader.AccFinal, 3),
+		"priv()I": returnInt(classloader.AccPrivate, 4),
+		"abs()I":  {AccessFlags: classloader.AccPublic | classloader.AccAbstract},
+	})
+	insertClass("Derived", "Base", map[string]*classloader.Method{
+		"m()I":    returnInt(classloader.AccPublic, 2),
+		"fin()I":  returnInt(classloader.AccPublic, 6),
+		"priv()I": returnInt(classloader.AccPublic, 8),
+	})
+	insertClass("Leaf", "Derived", map[string]*classloader.Method{})
+	insertClass("Secret", "Base", map[string]*classloader.Method{"m()I": returnInt(classloader.AccPrivate, 9)})
+
+	// the CP: [1] method ref -> [2] class ref to Base and [4] name and type
+	CP := classloader.CPool{}
+	CP.CpIndex = make([]classloader.CpEntry, 7)
+	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.MethodRef, Slot: 0}
+	CP.CpIndex[2] = classloader.CpEntry{Type: classloader.ClassRef, Slot: 0}
+	CP.CpIndex[3] = classloader.CpEntry{Type: classloader.UTF8, Slot: 0}
+	CP.CpIndex[4] = classloader.CpEntry{Type: classloader.NameAndType, Slot: 0}
+	CP.CpIndex[5] = classloader.CpEntry{Type: classloader.UTF8, Slot: 1}
+	CP.CpIndex[6] = classloader.CpEntry{Type: classloader.UTF8, Slot: 2}
+	CP.MethodRefs = append(CP.MethodRefs, classloader.MethodRefEntry{ClassIndex: 2, NameAndType: 4})
+	CP.ClassRefs = append(CP.ClassRefs, 3)
+	CP.NameAndTypes = append(CP.NameAndTypes, classloader.NameAndTypeEntry{NameIndex: 5, DescIndex: 6})
+	CP.Utf8Refs = append(CP.Utf8Refs, "Base", methName, "()I")
+
+	f := newFrame(INVOKEVIRTUAL)
+	f.Meth = append(f.Meth, 0x00, 0x01) // CP entry 1
+	f.CP = &CP
+	push(&f, obj)
+	return &f
+}
+
+// runs the INVOKEVIRTUAL of methName on Base with an object of class className.
+// Returns the value returned by the selected method.
+func runInvokevirtualTest(t *testing.T, className, methName string) int64 {
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	f := setupInvokevirtualTest(methName, obj)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("INVOKEVIRTUAL: Got unexpected error: %s", err.Error())
+	}
+
+	if fs.Len() != 1 {
+		t.Errorf("INVOKEVIRTUAL: Expected the called method's frame to be popped, got %d frames", fs.Len())
+	}
+	return pop(f).(int64)
+}
+
+// INVOKEVIRTUAL: the method is selected from the class of the object, not the class in the CP
+func TestInvokevirtualOverride(t *testing.T) {
+	value := runInvokevirtualTest(t, "Derived", "m")
+	if value != 2 {
+		t.Errorf("INVOKEVIRTUAL: Expected Derived.m() to return 2, got: %d", value)
+	}
+
+	// the selected method should now be cached in the MTable under the object's class
+	if classloader.MTable[selectedMethodKey("Derived", "m", "()I")].Meth == nil {
+		t.Errorf("INVOKEVIRTUAL: Expected Derived.m()I to be cached in the MTable")
+	}
+
+	value = runInvokevirtualTest(t, "Base", "m")
+	if value != 1 {
+		t.Errorf("INVOKEVIRTUAL: Expected Base.m() to return 1, got: %d", value)
+	}
+}
+
+// INVOKEVIRTUAL: an inherited method is selected from the superclass that declares it
+func TestInvokevirtualInheritedMethod(t *testing.T) {
+	value := runInvokevirtualTest(t, "Leaf", "m")
+	if value != 2 {
+		t.Errorf("INVOKEVIRTUAL: Expected Derived.m() to return 2, got: %d", value)
+	}
+
+	called := classloader.MTable[selectedMethodKey("Leaf", "m", "()I")].Meth.(classloader.JmEntry)
+	if called.ClName != "Derived" {
+		t.Errorf("INVOKEVIRTUAL: Expected m() to be selected from class Derived, got: %s", called.ClName)
+	}
+}
+
+// INVOKEVIRTUAL: a private method of the object's class doesn't override the method of its
+// superclass, even once the class's own code has invoked it, which puts it in the MTable
+func TestInvokevirtualPrivateMethodNotSelected(t *testing.T) {
+	className := "Secret"
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	f := setupInvokevirtualTest("m", obj)
+	if _, err := classloader.FetchMethodAndCP("Secret", "m", "()I"); err != nil { // as INVOKESPECIAL does
+		t.Fatalf("INVOKEVIRTUAL: Got unexpected error fetching Secret.m(): %s", err.Error())
+	}
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("INVOKEVIRTUAL: Got unexpected error: %s", err.Error())
+	}
+	if value := pop(f).(int64); value != 1 {
+		t.Errorf("INVOKEVIRTUAL: Expected Base.m() to return 1, not the private Secret.m(), got: %d", value)
+	}
+}
+
+// INVOKEVIRTUAL: final and private methods are not overridden
+func TestInvokevirtualFinalAndPrivateMethods(t *testing.T) {
+	value := runInvokevirtualTest(t, "Derived", "fin")
+	if value != 3 {
+		t.Errorf("INVOKEVIRTUAL: Expected final Base.fin() to return 3, got: %d", value)
+	}
+
+	value = runInvokevirtualTest(t, "Derived", "priv")
+	if value != 4 {
+		t.Errorf("INVOKEVIRTUAL: Expected private Base.priv() to return 4, got: %d", value)
+	}
+}
+
+// INVOKEVIRTUAL: a private method is invoked as resolved even if a method of the same
+// name has been selected for the object's class and cached in the MTable under it
+func TestInvokevirtualPrivateMethodWithCachedOverride(t *testing.T) {
+	className := "Leaf"
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	f := setupInvokevirtualTest("priv", obj)
+	derivedPriv, err := classloader.FetchMethodAndCP("Derived", "priv", "()I")
+	if err != nil {
+		t.Fatalf("INVOKEVIRTUAL: Got unexpected error fetching Derived.priv(): %s", err.Error())
+	}
+	classloader.MTableInsert("Leaf.priv()I", derivedPriv) // as an INVOKEVIRTUAL of Derived.priv() does
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	if err = runFrame(fs); err != nil {
+		t.Fatalf("INVOKEVIRTUAL: Got unexpected error: %s", err.Error())
+	}
+	if value := pop(f).(int64); value != 4 {
+		t.Errorf("INVOKEVIRTUAL: Expected private Base.priv() to return 4, got: %d", value)
+	}
+}
+
+// INVOKEVIRTUAL: an abstract method that the object's class doesn't implement throws an
+// AbstractMethodError, which the invoking method can catch
+func TestInvokevirtualAbstractMethodError(t *testing.T) {
+	className := "Leaf"
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	f := setupInvokevirtualTest("abs", obj)
+
+	exc := runInvocationCaught(t, f, "java/lang/AbstractMethodError")
+	expected := "Receiver class Leaf does not define or inherit an implementation of the resolved " +
+		"method 'abstract abs()I' of abstract class Base."
+	if msg := getThrowableMessage(exc); msg != expected {
+		t.Errorf("INVOKEVIRTUAL: Expected the message %q, got: %q", expected, msg)
+	}
+}
+
+// INVOKEVIRTUAL: a class in the CP that can't be loaded throws a NoClassDefFoundError
+func TestInvokevirtualUnloadableClass(t *testing.T) {
+	className := "Derived"
+	obj := object.MakeEmptyObject()
+	obj.Klass = &className
+	f := setupInvokevirtualTest("m", obj)
+	f.CP.Utf8Refs[0] = "NoSuchClass"
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+	exc := runInvocationCaught(t, f, "java/lang/NoClassDefFoundError")
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if msg := getThrowableMessage(exc); msg != "NoSuchClass" {
+		t.Errorf("INVOKEVIRTUAL: Expected the message NoSuchClass, got: %q", msg)
+	}
+}
+
 // INVOKEVIRTUAL : invoke method -- here testing for error
 func TestInvokevirtualInvalid(t *testing.T) {
 	f := newFrame(INVOKEVIRTUAL)
@@ -444,7 +1589,7 @@ func TestIremDivideByZero(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	err := runFrame(fs)
 	errMsg := err.Error()
-	if !strings.Contains(errMsg, "divide by zero") {
+	if !strings.Contains(errMsg, "java.lang.ArithmeticException") {
 		t.Errorf("IREM: Expected divide by zero error msg, got: %s", errMsg)
 	}
 }
@@ -661,8 +1806,8 @@ func TestIushr(t *testing.T) {
 
 	value := pop(&f).(int64) // longs require two slots, so popped twice
 
-	if value != 25 { // 200 >> 3 = 25
-		t.Errorf("IUSHR: expected a result of 25, but got: %d", value)
+	if value != 536870887 { // -200 >>> 3 in Java, as zeros are shifted in
+		t.Errorf("IUSHR: expected a result of 536870887, but got: %d", value)
 	}
 	if f.TOS != -1 {
 		t.Errorf("IUSHR: Expected an empty stack, but got a tos of: %d", f.TOS)
@@ -1076,7 +2221,7 @@ func TestLdivDivideByZero(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	res := runFrame(fs)
 
-	if !strings.Contains(res.Error(), "Divide by zero") {
+	if !strings.Contains(res.Error(), "java.lang.ArithmeticException") {
 		t.Errorf("LDIV: Expected err msg re divide by zero, got %s", res.Error())
 	}
 }
@@ -1255,6 +2400,71 @@ func TestLneg(t *testing.T) {
 	}
 }
 
+// LOOKUPSWITCH: jump to the offset paired with the key on the stack. Here the
+// instruction is at PC 0, so it's followed by 3 bytes of padding.
+func TestLookupswitch(t *testing.T) {
+	keys := []int32{-1000, -5, 1000000}
+
+	// the switch is 1 + 3 (padding) + 8 + 8 bytes per pair long
+	targets, addrs := makeSwitchTargets(1+3+8+8*len(keys), []int{99, 1, 2, 3})
+
+	code := []byte{LOOKUPSWITCH, 0, 0, 0}
+	code = appendInt32(code, int32(addrs[0])) // default
+	code = appendInt32(code, int32(len(keys)))
+	for i, key := range keys {
+		code = appendInt32(code, key)
+		code = appendInt32(code, int32(addrs[i+1]))
+	}
+	code = append(code, targets...)
+
+	tests := []struct {
+		key      int64
+		expected int64
+	}{{-1000, 1}, {-5, 2}, {1000000, 3}, {0, 99}, {-2000, 99}, {2000000, 99}}
+
+	for _, test := range tests {
+		f := newFrame(NOP)
+		f.Meth = code
+		push(&f, test.key)
+
+		fs := frames.CreateFrameStack()
+		fs.PushFront(&f) // push the new frame
+		err := runFrame(fs)
+		if err != nil {
+			t.Errorf("LOOKUPSWITCH: Got unexpected error for key %d: %s", test.key, err.Error())
+			continue
+		}
+
+		value := pop(&f).(int64)
+		if value != test.expected {
+			t.Errorf("LOOKUPSWITCH: For key %d expected %d, got: %d", test.key, test.expected, value)
+		}
+	}
+}
+
+// LOOKUPSWITCH: a switch with no pairs, as generated for a switch with only a default
+func TestLookupswitchDefaultOnly(t *testing.T) {
+	targets, addrs := makeSwitchTargets(1+3+8, []int{7})
+
+	code := []byte{LOOKUPSWITCH, 0, 0, 0}
+	code = appendInt32(code, int32(addrs[0])) // default
+	code = appendInt32(code, 0)               // no pairs
+	code = append(code, targets...)
+
+	f := newFrame(NOP)
+	f.Meth = code
+	push(&f, int64(42))
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	_ = runFrame(fs)
+
+	value := pop(&f).(int64)
+	if value != 7 {
+		t.Errorf("LOOKUPSWITCH: Expected the default path to push 7, got: %d", value)
+	}
+}
+
 // LOR: Logical OR of two longs
 func TestLor(t *testing.T) {
 	f := newFrame(LOR)
@@ -1315,7 +2525,7 @@ func TestLremDivideByZero(t *testing.T) {
 	fs.PushFront(&f) // push the new frame
 	err := runFrame(fs)
 	errMsg := err.Error()
-	if !strings.Contains(errMsg, "divide by zero") {
+	if !strings.Contains(errMsg, "java.lang.ArithmeticException") {
 		t.Errorf("LREM: Expected divide by zero error msg, got: %s", errMsg)
 	}
 }
@@ -1597,32 +2807,153 @@ func TestLxor(t *testing.T) {
 	}
 }
 
-// MONITORENTER: The JDK JVM does not implement this, nor do we. So just pop the ref off stack
+// MONITORENTER: enter the monitor of the object on the stack. Monitors are reentrant.
 func TestMonitorEnter(t *testing.T) {
 	f := newFrame(MONITORENTER)
-	push(&f, &f) // push any value and make sure it gets popped off
+	f.Meth = append(f.Meth, MONITORENTER)
+	obj := object.MakeEmptyObject()
+	push(&f, obj)
+	push(&f, obj)
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("MONITORENTER: Got unexpected error: %s", err.Error())
+	}
 
 	if f.TOS != -1 {
 		t.Errorf("MONITORENTER: Expected an empty stack, but got a tos of: %d", f.TOS)
 	}
+	monitor := object.GetMonitor(obj)
+	if !monitor.IsOwnedBy(f.Thread) {
+		t.Errorf("MONITORENTER: Expected the thread to own the monitor")
+	}
+
+	// the monitor was entered twice, so it takes two exits to release it
+	if !monitor.Exit(f.Thread) || !monitor.IsOwnedBy(f.Thread) {
+		t.Errorf("MONITORENTER: Expected the monitor to still be owned after the first exit")
+	}
+	if !monitor.Exit(f.Thread) || monitor.IsOwnedBy(f.Thread) {
+		t.Errorf("MONITORENTER: Expected the monitor to be released after the second exit")
+	}
+}
+
+// each object has its own monitor, which is created only once, even when several threads
+// need it at the same time
+func TestMonitorPerObject(t *testing.T) {
+	obj := object.MakeEmptyObject()
+	monitors := make([]*object.Monitor, 8)
+	var wg sync.WaitGroup
+	for i := range monitors {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			monitors[i] = object.GetMonitor(obj)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, monitor := range monitors {
+		if monitor == nil || monitor != object.GetMonitor(obj) {
+			t.Fatalf("Expected every thread to get the same monitor for the object")
+		}
+	}
+	if object.GetMonitor(object.MakeEmptyObject()) == monitors[0] {
+		t.Errorf("Expected another object to have a different monitor")
+	}
+}
+
+// MONITORENTER: a null reference throws a NullPointerException
+func TestMonitorEnterNull(t *testing.T) {
+	f := newFrame(MONITORENTER)
+	push(&f, object.Null)
+
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+
+	if err == nil || !strings.Contains(err.Error(), "java.lang.NullPointerException") {
+		t.Errorf("MONITORENTER: Expected an uncaught NullPointerException, got: %v", err)
+	}
 }
 
-// MONITOREXIT: The JDK JVM does not implement this, nor do we. So just pop the ref off stack
+// MONITOREXIT: exit the monitor of the object on the stack
 func TestMonitorExit(t *testing.T) {
-	f := newFrame(MONITOREXIT)
-	push(&f, &f) // push any value and make sure it gets popped off
+	f := newFrame(MONITORENTER)
+	f.Meth = append(f.Meth, MONITOREXIT)
+	obj := object.MakeEmptyObject()
+	push(&f, obj)
+	push(&f, obj)
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
-	_ = runFrame(fs)
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("MONITOREXIT: Got unexpected error: %s", err.Error())
+	}
 
 	if f.TOS != -1 {
 		t.Errorf("MONITOREXIT: Expected an empty stack, but got a tos of: %d", f.TOS)
 	}
+	if object.GetMonitor(obj).IsOwnedBy(f.Thread) {
+		t.Errorf("MONITOREXIT: Expected the monitor to be released")
+	}
+}
+
+// MONITOREXIT: exiting a monitor the thread does not own throws an
+// IllegalMonitorStateException, which the method can catch
+func TestMonitorExitUnbalanced(t *testing.T) {
+	f := newFrame(MONITOREXIT)
+	push(&f, object.MakeEmptyObject())
+
+	// the handler for all exceptions at 2: catch (Throwable t) { return 42; }
+	f.Meth = append(f.Meth, RETURN, POP, BIPUSH, 42)
+	f.ExcTable = append(f.ExcTable, classloader.CodeException{StartPc: 0, EndPc: 1, HandlerPc: 2})
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("MONITOREXIT: Got unexpected error: %s", err.Error())
+	}
+
+	value := pop(&f).(int64)
+	if value != 42 {
+		t.Errorf("MONITOREXIT: Expected the handler to catch the exception and push 42, got: %d", value)
+	}
+}
+
+// MONITOREXIT: an uncaught IllegalMonitorStateException ends the program with an error
+func TestMonitorExitUnbalancedUncaught(t *testing.T) {
+	f := newFrame(MONITOREXIT)
+	push(&f, object.MakeEmptyObject())
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+
+	_ = w.Close()
+	out, _ := io.ReadAll(r)
+	os.Stderr = normalStderr
+
+	if err == nil || !strings.Contains(err.Error(), "java.lang.IllegalMonitorStateException") {
+		t.Errorf("MONITOREXIT: Expected an uncaught IllegalMonitorStateException, got: %v", err)
+	}
+	if !strings.Contains(string(out), "current thread is not owner") {
+		t.Errorf("MONITOREXIT: Expected the exception's message in the report, got: %s", string(out))
+	}
 }
 
 // NEW: Instantiate object -- here with an error
@@ -1757,37 +3088,21 @@ func TestPop2WithTrace(t *testing.T) {
 
 // PUTFIELD: Update a non-static field
 func TestPutFieldSimpleInt(t *testing.T) {
-	f := newFrame(PUTFIELD)
-	f.Meth = append(f.Meth, 0x00)
-	f.Meth = append(f.Meth, 0x01) // Go to slot 0x0001 in the CP
-
-	CP := classloader.CPool{}
-	CP.CpIndex = make([]classloader.CpEntry, 10, 10)
-	CP.CpIndex[0] = classloader.CpEntry{Type: 0, Slot: 0}
-	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.FieldRef, Slot: 0}
-	// now create the pointed-to FieldRef
-	CP.FieldRefs = make([]classloader.FieldRefEntry, 1, 1)
-	CP.FieldRefs[0] = classloader.FieldRefEntry{ClassIndex: 0, NameAndType: 0}
-	f.CP = &CP
+	f, obj := setupFieldTest(t, PUTFIELD, "x", "I")
+	obj.Fields[3].Fvalue = int64(42) // set the field = 42
+	push(f, obj)
 
-	// now create the object we're updating, with one int field
-	obj := object.MakeEmptyObject()
-	obj.Fields = make([]object.Field, 1, 1)
-	obj.Fields[0].Fvalue = int64(42) // set the field = 42
-	obj.Fields[0].Ftype = types.Int
-	push(&f, obj)
-
-	push(&f, int64(26)) // update the field to 26
+	push(f, int64(26)) // update the field to 26
 
 	fs := frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
+	fs.PushFront(f) // push the new frame
 	err := runFrame(fs)
 
 	if err != nil {
 		t.Errorf("PUTFIELD: Got unexpected error msg: %s", err.Error())
 	}
 
-	res := obj.Fields[0].Fvalue.(int64)
+	res := obj.Fields[3].Fvalue.(int64)
 	if res != 26 {
 		t.Errorf("PUTFIELD: Expected a new value of 26, got: %d", res)
 	}
@@ -1795,43 +3110,72 @@ func TestPutFieldSimpleInt(t *testing.T) {
 
 // PUTFIELD
 func TestPutFieldDouble(t *testing.T) {
-	f := newFrame(PUTFIELD)
-	f.Meth = append(f.Meth, 0x00)
-	f.Meth = append(f.Meth, 0x01) // Go to slot 0x0001 in the CP
-
-	CP := classloader.CPool{}
-	CP.CpIndex = make([]classloader.CpEntry, 10, 10)
-	CP.CpIndex[0] = classloader.CpEntry{Type: 0, Slot: 0}
-	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.FieldRef, Slot: 0}
-	// now create the pointed-to FieldRef
-	CP.FieldRefs = make([]classloader.FieldRefEntry, 1, 1)
-	CP.FieldRefs[0] = classloader.FieldRefEntry{ClassIndex: 0, NameAndType: 0}
-	f.CP = &CP
-
-	// now create the object we're updating, with one int field
-	obj := object.MakeEmptyObject()
-	obj.Fields = make([]object.Field, 1, 1)
-	obj.Fields[0].Fvalue = float64(42.0) // set the field = 42
-	obj.Fields[0].Ftype = types.Double
-	push(&f, obj)
+	f, obj := setupFieldTest(t, PUTFIELD, "d", "D")
+	obj.Fields[2].Fvalue = float64(42.0) // set the field = 42
+	push(f, obj)
 
-	push(&f, float64(26.8)) // update the field to 26.8
-	push(&f, float64(26.8)) // push a second time b/c it's a double
+	push(f, float64(26.8)) // update the field to 26.8
+	push(f, float64(26.8)) // push a second time b/c it's a double
 
 	fs := frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
+	fs.PushFront(f) // push the new frame
 	err := runFrame(fs)
 
 	if err != nil {
 		t.Errorf("PUTFIELD: Got unexpected error msg: %s", err.Error())
 	}
 
-	res := obj.Fields[0].Fvalue.(float64)
+	res := obj.Fields[2].Fvalue.(float64)
 	if res != 26.8 {
 		t.Errorf("PUTFIELD: Expected a new value of 26.8, got: %f", res)
 	}
 }
 
+// PUTFIELD and GETFIELD: a subclass's field that shadows a field of its superclass is a
+// separate field, and the superclass's field is accessed through the superclass
+func TestPutFieldAndGetFieldShadowed(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+
+	// class Base { int x; }  class Sub extends Base { int x; }
+	for _, class := range []struct{ name, superclass string }{{"Base", "java/lang/Object"}, {"Sub", "Base"}} {
+		data := classloader.ClData{Name: class.name, Superclass: class.superclass}
+		data.CP.Utf8Refs = []string{"x", types.Int}
+		data.Fields = []classloader.Field{{Name: 0, Desc: 1}}
+		classloader.MethAreaInsert(class.name, &classloader.Klass{Status: 'X', Loader: "app", Data: &data})
+	}
+	obj, err := instantiateClass("Sub", nil)
+	if err != nil {
+		t.Fatalf("Got unexpected error instantiating Sub: %s", err.Error())
+	}
+	if len(obj.Fields) != 2 {
+		t.Fatalf("Expected a Sub object to have 2 fields, got: %d", len(obj.Fields))
+	}
+
+	cp := newMessageTestCP()
+	baseX := byte(cp.fieldRef("Base", "x", types.Int))
+	subX := byte(cp.fieldRef("Sub", "x", types.Int))
+	f := newFrame(ALOAD_0)
+	f.Meth = append(f.Meth, ICONST_1, PUTFIELD, 0, baseX, ALOAD_0, ICONST_2, PUTFIELD, 0, subX,
+		ALOAD_0, GETFIELD, 0, baseX)
+	f.CP = &cp.cp
+	f.Locals = []interface{}{obj}
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("PUTFIELD: Got unexpected error: %s", err.Error())
+	}
+
+	if obj.Fields[0].Fvalue != int64(1) || obj.Fields[1].Fvalue != int64(2) {
+		t.Errorf("PUTFIELD: Expected Base.x to be 1 and Sub.x to be 2, got: %v", obj.Fields)
+	}
+	if value := pop(&f); value != int64(1) {
+		t.Errorf("GETFIELD: Expected Base.x to be 1, got: %v", value)
+	}
+}
+
 // PUTFIELD: Update a field in an object -- error doesn't point to a field
 func TestPutFieldNonFieldCPentry(t *testing.T) {
 	f := newFrame(PUTFIELD)
@@ -1856,40 +3200,30 @@ func TestPutFieldNonFieldCPentry(t *testing.T) {
 	}
 }
 
-// PUTFIELD: Error: attempt to update a non-static field
+// PUTFIELD: Error: attempt to update a static field throws an IncompatibleClassChangeError
 func TestPutFieldErrorUpdatingStatic(t *testing.T) {
-	f := newFrame(PUTFIELD)
-	f.Meth = append(f.Meth, 0x00)
-	f.Meth = append(f.Meth, 0x01) // Go to slot 0x0001 in the CP
-
-	CP := classloader.CPool{}
-	CP.CpIndex = make([]classloader.CpEntry, 10, 10)
-	CP.CpIndex[0] = classloader.CpEntry{Type: 0, Slot: 0}
-	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.FieldRef, Slot: 0}
-	// now create the pointed-to FieldRef
-	CP.FieldRefs = make([]classloader.FieldRefEntry, 1, 1)
-	CP.FieldRefs[0] = classloader.FieldRefEntry{ClassIndex: 0, NameAndType: 0}
-	f.CP = &CP
+	f, obj := setupFieldTest(t, PUTFIELD, "count", "I")
+	push(f, obj)
 
-	// now create the object we're updating, with one int field
-	obj := object.MakeEmptyObject()
-	obj.Fields = make([]object.Field, 1, 1)
-	obj.Fields[0].Fvalue = int64(42) // set the field = 42
-	obj.Fields[0].Ftype = types.Static + types.Int
-	push(&f, obj)
+	push(f, int64(26)) // update the field to 26
 
-	push(&f, int64(26)) // update the field to 26
+	normalStderr := os.Stderr
+	_, w, _ := os.Pipe()
+	os.Stderr = w
 
 	fs := frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
+	fs.PushFront(f) // push the new frame
 	err := runFrame(fs)
 
+	_ = w.Close()
+	os.Stderr = normalStderr
+
 	if err == nil {
-		t.Errorf("PUTFIELD: Expected error message but got none")
+		t.Fatalf("PUTFIELD: Expected error message but got none")
 	}
 
 	errMsg := err.Error()
-	if !strings.Contains(errMsg, "invalid attempt to update a static variable") {
+	if !strings.Contains(errMsg, "java.lang.IncompatibleClassChangeError") {
 		t.Errorf("PUTFIELD: Did not get expected error message, got %s", errMsg)
 	}
 }
@@ -2007,6 +3341,74 @@ func TestSwap(t *testing.T) {
 	}
 }
 
+// TABLESWITCH: jump to the offset indexed by the value on the stack. Here the
+// instruction is at PC 1, so it's followed by 2 bytes of padding. The range of
+// the table runs from -1 to 2 to test negative indexes.
+func TestTableswitch(t *testing.T) {
+	low, high := int32(-1), int32(2)
+
+	// the switch starts at PC 1 and is 1 + 2 (padding) + 12 + 4 bytes per entry long
+	targets, addrs := makeSwitchTargets(1+1+2+12+4*int(high-low+1), []int{99, 10, 20, 30, 40})
+
+	code := []byte{NOP, TABLESWITCH, 0, 0}
+	code = appendInt32(code, int32(addrs[0]-1)) // offsets are relative to the opcode at PC 1
+	code = appendInt32(code, low)
+	code = appendInt32(code, high)
+	for i := 1; i < len(addrs); i++ {
+		code = appendInt32(code, int32(addrs[i]-1))
+	}
+	code = append(code, targets...)
+
+	tests := []struct {
+		index    int64
+		expected int64
+	}{{-1, 10}, {0, 20}, {1, 30}, {2, 40}, {-2, 99}, {3, 99}, {-100000, 99}}
+
+	for _, test := range tests {
+		f := newFrame(NOP)
+		f.Meth = code
+		push(&f, test.index)
+
+		fs := frames.CreateFrameStack()
+		fs.PushFront(&f) // push the new frame
+		err := runFrame(fs)
+		if err != nil {
+			t.Errorf("TABLESWITCH: Got unexpected error for index %d: %s", test.index, err.Error())
+			continue
+		}
+
+		value := pop(&f).(int64)
+		if value != test.expected {
+			t.Errorf("TABLESWITCH: For index %d expected %d, got: %d", test.index, test.expected, value)
+		}
+	}
+}
+
+// TABLESWITCH: an instruction at PC 3 needs no padding
+func TestTableswitchNoPadding(t *testing.T) {
+	targets, addrs := makeSwitchTargets(3+1+12+4, []int{99, 5})
+
+	code := []byte{NOP, NOP, NOP, TABLESWITCH}
+	code = appendInt32(code, int32(addrs[0]-3)) // offsets are relative to the opcode at PC 3
+	code = appendInt32(code, 0)                 // low
+	code = appendInt32(code, 0)                 // high
+	code = appendInt32(code, int32(addrs[1]-3))
+	code = append(code, targets...)
+
+	f := newFrame(NOP)
+	f.Meth = code
+	push(&f, int64(0))
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	_ = runFrame(fs)
+
+	value := pop(&f).(int64)
+	if value != 5 {
+		t.Errorf("TABLESWITCH: Expected 5, got: %d", value)
+	}
+}
+
 func TestInvalidInstruction(t *testing.T) {
 	// set the logger to low granularity, so that logging messages are not also captured in this test
 	Global := globals.InitGlobals("test")
@@ -2072,3 +3474,26 @@ func TestConvertInterfaceToUint64(t *testing.T) {
 		t.Error("Expected TestConvertInterfaceToUint64() to !=0, got 0\n")
 	}
 }
+
+// builds the code that follows a TABLESWITCH or LOOKUPSWITCH instruction in the
+// tests: for each value, a target that pushes the value and jumps to the end of
+// the method. Returns the code and the address of each target. start is the
+// address at which the targets begin.
+func makeSwitchTargets(start int, values []int) ([]byte, []int) {
+	var code []byte
+	var addrs []int
+	end := start + len(values)*5 // each target is BIPUSH (2 bytes) + GOTO (3 bytes)
+	for _, v := range values {
+		pc := start + len(code)
+		addrs = append(addrs, pc)
+		jump := end - (pc + 2) // the GOTO follows the BIPUSH
+		code = append(code, BIPUSH, byte(v), GOTO, byte(jump>>8), byte(jump))
+	}
+	code = append(code, NOP)
+	return code, addrs
+}
+
+// appends a signed 32-bit value to the code in big-endian format
+func appendInt32(code []byte, val int32) []byte {
+	return binary.BigEndian.AppendUint32(code, uint32(val))
+}
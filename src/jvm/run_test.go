@@ -290,6 +290,158 @@ func TestAstore3(t *testing.T) {
 	}
 }
 
+// ATHROW: throw an exception that is caught by a catch-all (finally) handler in the same method
+func TestAthrowCatchAll(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+
+	f := newFrame(ATHROW)
+	f.Meth = append(f.Meth, RETURN) // skipped if the exception is caught
+	f.Meth = append(f.Meth, NOP)    // the handler
+	f.ExcTable = append(f.ExcTable,
+		classloader.CodeException{StartPc: 0, EndPc: 1, HandlerPc: 2, CatchType: 0})
+
+	excName := "java/lang/RuntimeException"
+	exc := object.MakeEmptyObject()
+	exc.Klass = &excName
+	push(&f, zero) // should be cleared by the throw
+	push(&f, exc)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+
+	if err != nil {
+		t.Errorf("ATHROW: Got unexpected error: %s", err.Error())
+	}
+	if f.PC != 3 {
+		t.Errorf("ATHROW: Expected PC to be past the handler at 3, got: %d", f.PC)
+	}
+	if f.TOS != 0 {
+		t.Errorf("ATHROW: Expected a single item (the exception) on the stack, got TOS: %d", f.TOS)
+	}
+	if peek(&f) != exc {
+		t.Errorf("ATHROW: Expected the exception object on the stack")
+	}
+}
+
+// ATHROW: an exception that is not caught in the throwing method is caught in the calling
+// method by a handler whose catch type is a superclass of the thrown exception
+func TestAthrowCaughtInCaller(t *testing.T) {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+
+	classloader.MethAreaInsert("MyException", &(classloader.Klass{Status: 'X', Loader: "bootstrap",
+		Data: &classloader.ClData{Name: "MyException", Superclass: "java/lang/Exception"}}))
+	classloader.MethAreaInsert("java/lang/Exception", &(classloader.Klass{Status: 'X', Loader: "bootstrap",
+		Data: &classloader.ClData{Name: "java/lang/Exception", Superclass: "java/lang/Throwable"}}))
+
+	// [1] is a UTF8 entry with the name of the class that's caught, [2] is a ClassRef to [1]
+	CP := classloader.CPool{}
+	CP.CpIndex = make([]classloader.CpEntry, 3)
+	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.UTF8, Slot: 0}
+	CP.CpIndex[2] = classloader.CpEntry{Type: classloader.ClassRef, Slot: 0}
+	CP.ClassRefs = append(CP.ClassRefs, 1)
+	CP.Utf8Refs = append(CP.Utf8Refs, "java/lang/Exception")
+
+	// the calling frame has executed the invocation (here a dummy NOP) and is
+	// waiting at the next bytecode. The handler covers the invocation.
+	caller := newFrame(NOP)
+	caller.Meth = append(caller.Meth, RETURN)
+	caller.Meth = append(caller.Meth, NOP) // the handler
+	caller.PC = 1
+	caller.CP = &CP
+	caller.ExcTable = append(caller.ExcTable,
+		classloader.CodeException{StartPc: 0, EndPc: 1, HandlerPc: 2, CatchType: 2})
+
+	excName := "MyException"
+	exc := object.MakeEmptyObject()
+	exc.Klass = &excName
+
+	callee := newFrame(ATHROW)
+	push(&callee, exc)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&caller)
+	fs.PushFront(&callee)
+	err := runFrame(fs)
+
+	if err != nil {
+		t.Errorf("ATHROW: Got unexpected error: %s", err.Error())
+	}
+	if fs.Len() != 1 || fs.Front().Value.(*frames.Frame) != &caller {
+		t.Errorf("ATHROW: Expected the throwing frame to be popped, but frame stack has %d frames", fs.Len())
+	}
+	if caller.PC != 3 {
+		t.Errorf("ATHROW: Expected PC in calling frame to be past the handler at 3, got: %d", caller.PC)
+	}
+	if caller.TOS != 0 || peek(&caller) != exc {
+		t.Errorf("ATHROW: Expected the exception object on the calling frame's stack")
+	}
+}
+
+// ATHROW: an exception that no handler catches is reported as the JDK does
+func TestAthrowUncaught(t *testing.T) {
+	g := globals.GetGlobalRef()
+	globals.InitGlobals("test")
+	g.JacobinName = "test" // prevents a shutdown when the exception hits.
+	log.Init()
+	classloader.InitMethodArea()
+
+	classloader.MethAreaInsert("java/lang/RuntimeException", &(classloader.Klass{Status: 'X', Loader: "bootstrap",
+		Data: &classloader.ClData{Name: "java/lang/RuntimeException", Superclass: "java/lang/Object"}}))
+
+	// redirect stderr to capture the report
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	// the handler catches only java/lang/Error, which is not a superclass of the exception
+	CP := classloader.CPool{}
+	CP.CpIndex = make([]classloader.CpEntry, 3)
+	CP.CpIndex[1] = classloader.CpEntry{Type: classloader.UTF8, Slot: 0}
+	CP.CpIndex[2] = classloader.CpEntry{Type: classloader.ClassRef, Slot: 0}
+	CP.ClassRefs = append(CP.ClassRefs, 1)
+	CP.Utf8Refs = append(CP.Utf8Refs, "java/lang/Error")
+
+	f := newFrame(ATHROW)
+	f.Meth = append(f.Meth, NOP)
+	f.ClName = "com/foo/Bar"
+	f.MethName = "baz"
+	f.CP = &CP
+	f.ExcTable = append(f.ExcTable,
+		classloader.CodeException{StartPc: 0, EndPc: 1, HandlerPc: 1, CatchType: 2})
+
+	excName := "java/lang/RuntimeException"
+	exc := object.MakeEmptyObject()
+	exc.Klass = &excName
+	exc.FieldTable = make(map[string]object.Field)
+	exc.FieldTable["detailMessage"] = object.Field{Ftype: "Ljava/lang/String;",
+		Fvalue: object.NewStringFromGoString("oops")}
+	push(&f, exc)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(&f) // push the new frame
+	err := runFrame(fs)
+
+	// restore stderr to what it was before
+	_ = w.Close()
+	os.Stderr = normalStderr
+	msg, _ := io.ReadAll(r)
+	errMsg := string(msg)
+
+	if err == nil {
+		t.Errorf("ATHROW: Expected an error from an uncaught exception, but got none")
+	}
+	if !strings.Contains(errMsg, "Exception in thread \"main\" java.lang.RuntimeException: oops") {
+		t.Errorf("ATHROW: Got unexpected report of uncaught exception: %s", errMsg)
+	}
+	if !strings.Contains(errMsg, "\tat com.foo.Bar.baz(Unknown Source)") {
+		t.Errorf("ATHROW: Got unexpected stack trace of uncaught exception: %s", errMsg)
+	}
+}
+
 // BIPUSH
 func TestBipush(t *testing.T) {
 	f := newFrame(BIPUSH)
@@ -426,37 +578,35 @@ func TestCheckcastOfNull(t *testing.T) {
 
 // CHECKCAST: Test for non-object pointer -- this should result in an exception
 func TestCheckcastOfInvalidReference(t *testing.T) {
-	g := globals.GetGlobalRef()
-	globals.InitGlobals("test")
-	g.JacobinName = "test" // prevents a shutdown when the exception hits.
-	log.Init()
+	setupAnimalClasses()
 	log.SetLogLevel(log.SEVERE)
 
 	// redirect stderr to avoid printing error message to console
 	normalStderr := os.Stderr
-	_, w, _ := os.Pipe()
+	r, w, _ := os.Pipe()
 	os.Stderr = w
 
+	c := newMessageTestCP()
 	f := newFrame(CHECKCAST)
+	f.Meth = append(f.Meth, 0, byte(c.classRef("Cat")))
+	f.CP = &c.cp
 	push(&f, float64(42.0)) // this should cause the error
 
 	fs := frames.CreateFrameStack()
 	fs.PushFront(&f) // push the new frame
 	err := runFrame(fs)
 
+	_ = w.Close()
 	os.Stderr = normalStderr // restore stderr
+	msg, _ := io.ReadAll(r)
 
 	if err == nil {
-		t.Errorf("CHECKCAST: Expected an error, but did not get one")
+		t.Fatalf("CHECKCAST: Expected an error, but did not get one")
 	}
 
-	if f.TOS != 0 {
-		t.Errorf("CHECKCAST: Expected TOS to be 0, got %d", f.TOS)
-	}
-
-	errMsg := err.Error()
-	if !strings.Contains(errMsg, "CHECKCAST: Invalid class reference") {
-		t.Errorf("CHECKCAST: Expected different error message. Got: %s", errMsg)
+	expected := "java.lang.ClassCastException: class java.lang.Object cannot be cast to class Cat"
+	if !strings.Contains(string(msg), expected) {
+		t.Errorf("CHECKCAST: Expected the report of an uncaught exception with %q, got: %s", expected, string(msg))
 	}
 }
 
@@ -1884,32 +2034,58 @@ func TestFsub(t *testing.T) {
 	}
 }
 
+// sets up the class Point, which has the instance fields name, big, d and x and the
+// static field count, in the method area. Returns a frame whose code is the bytecode op
+// followed by the index of a CP entry referring to the named field, and a new Point.
+func setupFieldTest(t *testing.T, op byte, fieldName, fieldDesc string) (*frames.Frame, *object.Object) {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+
+	data := classloader.ClData{Name: "Point", Superclass: "java/lang/Object"}
+	fields := []struct {
+		name, desc string
+		isStatic   bool
+	}{
+		{"name", "Ljava/lang/String;", false}, {"big", "J", false}, {"d", "D", false},
+		{"x", "I", false}, {"count", "I", true},
+	}
+	for _, fld := range fields {
+		data.CP.Utf8Refs = append(data.CP.Utf8Refs, fld.name, fld.desc)
+		data.Fields = append(data.Fields, classloader.Field{Name: uint16(len(data.CP.Utf8Refs) - 2),
+			Desc: uint16(len(data.CP.Utf8Refs) - 1), IsStatic: fld.isStatic})
+	}
+	classloader.MethAreaInsert("Point", &classloader.Klass{Status: 'X', Loader: "app", Data: &data})
+
+	obj, err := instantiateClass("Point", nil)
+	if err != nil {
+		t.Fatalf("Got unexpected error instantiating Point: %s", err.Error())
+	}
+
+	cp := newMessageTestCP()
+	fieldRef := cp.fieldRef("Point", fieldName, fieldDesc)
+	f := newFrame(op)
+	f.Meth = append(f.Meth, byte(fieldRef>>8), byte(fieldRef))
+	f.CP = &cp.cp
+	return &f, obj
+}
+
 // GETFIELD: Get a field from an object
 func TestGetField(t *testing.T) {
-	f := newFrame(GETFIELD)
-	f.Meth = append(f.Meth, 0x00)
-	f.Meth = append(f.Meth, 0x01) // Go to slot 0x0001 in the CP
-
-	CP := classloader.CPool{}
-	CP.CpIndex = make([]classloader.CpEntry, 10, 10)
-	CP.CpIndex[0] = classloader.CpEntry{Type: 0, Slot: 0}
-	CP.CpIndex[1] = classloader.CpEntry{Type: 9, Slot: 0} // point to a fieldRef
-	CP.FieldRefs = make([]classloader.FieldRefEntry, 1, 1)
-	CP.FieldRefs[0] = classloader.FieldRefEntry{ClassIndex: 0, NameAndType: 0}
-	f.CP = &CP
+	f, obj := setupFieldTest(t, GETFIELD, "name", "Ljava/lang/String;")
 
-	// push the string whose field[0] we'll be getting
-	str := object.NewString()
-	str.Fields[0].Fvalue = "hello"
-	push(&f, str)
+	// set the field that we'll be getting
+	str := object.NewStringFromGoString("hello")
+	obj.Fields[0].Fvalue = str
+	push(f, obj)
 
 	fs := frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
+	fs.PushFront(f) // push the new frame
 	_ = runFrame(fs)
 
 	// preceding should mean that the field value is on the stack
-	ret := pop(&f)
-	if ret != "hello" {
+	ret := pop(f)
+	if ret != str {
 		t.Errorf("GETFIELD: did not get expected pointer to a string 'hello'")
 	}
 
@@ -1920,31 +2096,16 @@ func TestGetField(t *testing.T) {
 
 // GETFIELD: Get a long field, make sure that it's value is pushed twice
 func TestGetFieldWithLong(t *testing.T) {
-	f := newFrame(GETFIELD)
-	f.Meth = append(f.Meth, 0x00)
-	f.Meth = append(f.Meth, 0x01) // Go to slot 0x0001 in the CP
-
-	CP := classloader.CPool{}
-	CP.CpIndex = make([]classloader.CpEntry, 10, 10)
-	CP.CpIndex[0] = classloader.CpEntry{Type: 0, Slot: 0}
-	CP.CpIndex[1] = classloader.CpEntry{Type: 9, Slot: 0} // point to a fieldRef
-	CP.FieldRefs = make([]classloader.FieldRefEntry, 1, 1)
-	CP.FieldRefs[0] = classloader.FieldRefEntry{ClassIndex: 0, NameAndType: 0}
-	f.CP = &CP
-
-	// push the string whose field[0] we'll be getting
-	obj := object.MakeEmptyObject()
-	obj.Fields = make([]object.Field, 1, 1)
-	obj.Fields[0].Fvalue = int64(222)
-	obj.Fields[0].Ftype = types.Long
-	push(&f, obj)
+	f, obj := setupFieldTest(t, GETFIELD, "big", "J")
+	obj.Fields[1].Fvalue = int64(222)
+	push(f, obj)
 
 	fs := frames.CreateFrameStack()
-	fs.PushFront(&f) // push the new frame
+	fs.PushFront(f) // push the new frame
 	_ = runFrame(fs)
 
 	// preceding should mean that the field value is on the stack
-	ret := pop(&f).(int64)
+	ret := pop(f).(int64)
 	if ret != 222 {
 		t.Errorf("GETFIELD: expected popped value of 222, got: %d", ret)
 	}
@@ -1954,6 +2115,38 @@ func TestGetFieldWithLong(t *testing.T) {
 	}
 }
 
+// GETFIELD and PUTFIELD: an object whose Fields are not laid out by its class, as Jacobin
+// builds some objects by hand, has its fields accessed by name rather than by slot, even
+// if it has as many Fields as its class's layout
+func TestGetFieldAndPutFieldWithoutLayout(t *testing.T) {
+	f, _ := setupFieldTest(t, GETFIELD, "x", "I")
+	pointClassName := "Point"
+	obj := object.MakeEmptyObject()
+	obj.Klass = &pointClassName
+	for i := 0; i < 4; i++ { // as many fields as Point has, but slot 3 isn't x
+		obj.Fields = append(obj.Fields, object.Field{Ftype: types.Int, Fvalue: int64(-1)})
+	}
+	obj.FieldTable = map[string]object.Field{"x": {Ftype: types.Int, Fvalue: int64(42)}}
+	push(f, obj)
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	_ = runFrame(fs)
+	if ret := pop(f); ret != int64(42) {
+		t.Errorf("GETFIELD: expected the value of x by name, 42, got: %v", ret)
+	}
+
+	f, _ = setupFieldTest(t, PUTFIELD, "x", "I")
+	push(f, obj)
+	push(f, int64(7))
+	fs = frames.CreateFrameStack()
+	fs.PushFront(f) // push the new frame
+	_ = runFrame(fs)
+	if obj.FieldTable["x"].Fvalue != int64(7) || obj.Fields[3].Fvalue != int64(-1) {
+		t.Errorf("PUTFIELD: expected x to be set by name to 7, got: %v", obj.FieldTable["x"].Fvalue)
+	}
+}
+
 // GETFIELD: Get a field from an object (here, with error that it's not a fieldref)
 func TestGetFieldInvalidFieldEntry(t *testing.T) {
 	f := newFrame(GETFIELD)
@@ -2002,6 +2195,7 @@ func TestGetStaticBoolean(t *testing.T) {
 	f.Meth = append(f.Meth, 0x00)
 	f.Meth = append(f.Meth, 0x01) // Go to slot 0x0001 in the CP
 
+	classloader.InitMethodArea()
 	classloader.StaticsPreload() // load the statics table with the String class
 
 	CP := classloader.CPool{}
@@ -2093,8 +2287,8 @@ func TestI2B(t *testing.T) {
 	}
 }
 
-// I2B: convert int to Java char (16-bit value) using a negative value
-func TestI2Bneg(t *testing.T) { // TODO: check that this matches Java result
+// I2B: convert int to Java byte using a negative value
+func TestI2Bneg(t *testing.T) {
 	f := newFrame(I2B)
 	push(&f, int64(-2100))
 
@@ -2102,8 +2296,8 @@ func TestI2Bneg(t *testing.T) { // TODO: check that this matches Java result
 	fs.PushFront(&f) // push the new frame
 	_ = runFrame(fs)
 	value := pop(&f).(int64)
-	if value != -204 { // looks like 256-52
-		t.Errorf("I2B: expected a result of -204, but got: %d", value)
+	if value != -52 { // (byte) -2100 in Java: the low byte is 0xCC
+		t.Errorf("I2B: expected a result of -52, but got: %d", value)
 	}
 	if f.TOS != -1 {
 		t.Errorf("I2B: Expected stack with 1 entry, but got a TOS of: %d", f.TOS)
@@ -2293,7 +2487,7 @@ func TestIdivDivideByZero(t *testing.T) {
 	_ = wout.Close()
 	os.Stdout = normalStdout
 
-	if !strings.Contains(errMsg, "Arithmetic Exception") {
+	if !strings.Contains(errMsg, "java.lang.ArithmeticException: / by zero") {
 		t.Errorf("IDIV: Did not get expected error msg, got: %s", errMsg)
 	}
 }
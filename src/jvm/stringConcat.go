@@ -0,0 +1,311 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/log"
+	"jacobin/object"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Since Java 9, javac compiles string concatenation ("a" + b) into an INVOKEDYNAMIC
+// whose bootstrap method is StringConcatFactory.makeConcatWithConstants(). Its first
+// static argument is a recipe: a string in which every \u0001 stands for the next
+// argument on the stack and every \u0002 for the next of the remaining static arguments,
+// which are constants. All other chars in the recipe are copied as is.
+
+const (
+	concatArgTag      = '\u0001'
+	concatConstantTag = '\u0002'
+)
+
+// concatWithConstants performs the concatenation for a makeConcatWithConstants call site
+// of type methodType. The arguments are popped off f's operand stack and the resulting
+// string is pushed in their place.
+func concatWithConstants(fs *list.List, f *frames.Frame, methodType string, bsmArgs []uint16) error {
+	if len(bsmArgs) < 1 {
+		errMsg := "INVOKEDYNAMIC: Missing recipe for string concatenation in class " + f.ClName
+		_ = log.Log(errMsg, log.SEVERE)
+		return errors.New(errMsg)
+	}
+
+	recipeEntry := FetchCPentry(f.CP, int(bsmArgs[0]))
+	if recipeEntry.retType != IS_STRING_ADDR {
+		errMsg := "INVOKEDYNAMIC: Invalid recipe for string concatenation in class " + f.ClName
+		_ = log.Log(errMsg, log.SEVERE)
+		return errors.New(errMsg)
+	}
+	recipe := *recipeEntry.stringVal
+
+	// pop the arguments, which are on the stack in reverse order. Longs and doubles
+	// take up two slots on the stack.
+	params := parseParamDescriptors(methodType)
+	args := make([]interface{}, len(params))
+	for i := len(params) - 1; i >= 0; i-- {
+		if params[i] == "J" || params[i] == "D" {
+			pop(f)
+		}
+		args[i] = pop(f)
+	}
+
+	var sb strings.Builder
+	argIndex, constIndex := 0, 1
+	for _, ch := range recipe {
+		switch ch {
+		case concatArgTag:
+			if argIndex >= len(args) {
+				errMsg := "INVOKEDYNAMIC: Too few arguments for string concatenation recipe in class " + f.ClName
+				_ = log.Log(errMsg, log.SEVERE)
+				return errors.New(errMsg)
+			}
+			s, err := formatConcatArg(fs, f, params[argIndex], args[argIndex])
+			if err != nil {
+				return err
+			}
+			sb.WriteString(s)
+			argIndex += 1
+		case concatConstantTag:
+			if constIndex >= len(bsmArgs) {
+				errMsg := "INVOKEDYNAMIC: Too few constants for string concatenation recipe in class " + f.ClName
+				_ = log.Log(errMsg, log.SEVERE)
+				return errors.New(errMsg)
+			}
+			s, err := formatConcatConstant(f.CP, bsmArgs[constIndex])
+			if err != nil {
+				return err
+			}
+			sb.WriteString(s)
+			constIndex += 1
+		default:
+			sb.WriteRune(ch)
+		}
+	}
+
+	str := sb.String()
+	result := object.CreateCompactStringFromGoString(&str)
+	result.Klass = &object.StringClassName
+	push(f, result)
+	return nil
+}
+
+// formatConcatArg converts a value popped off the operand stack into the string that
+// String.valueOf() would return for it. The field descriptor gives the value's Java type.
+func formatConcatArg(fs *list.List, f *frames.Frame, descriptor string, arg interface{}) (string, error) {
+	switch descriptor {
+	case "Z":
+		if arg.(int64) != 0 {
+			return "true", nil
+		}
+		return "false", nil
+	case "C":
+		return string(rune(arg.(int64))), nil
+	case "B", "S", "I", "J":
+		return strconv.FormatInt(arg.(int64), 10), nil
+	case "F":
+		return javaFloatToString(arg.(float64), 32), nil
+	case "D":
+		return javaFloatToString(arg.(float64), 64), nil
+	default: // a reference
+		obj, _ := arg.(*object.Object)
+		return javaObjectToString(fs, f, obj)
+	}
+}
+
+// formatConcatConstant converts a static argument of the bootstrap method, which is
+// a loadable CP entry, into a string
+func formatConcatConstant(CP *classloader.CPool, cpIndex uint16) (string, error) {
+	entry := FetchCPentry(CP, int(cpIndex))
+	switch entry.entryType {
+	case classloader.UTF8: // string constants are stored as UTF8 entries
+		return *entry.stringVal, nil
+	case classloader.IntConst, classloader.LongConst:
+		return strconv.FormatInt(entry.intVal, 10), nil
+	case classloader.FloatConst:
+		return javaFloatToString(entry.floatVal, 32), nil
+	case classloader.DoubleConst:
+		return javaFloatToString(entry.floatVal, 64), nil
+	case classloader.ClassRef:
+		return "class " + javaClassName(*entry.stringVal), nil
+	default:
+		errMsg := fmt.Sprintf("INVOKEDYNAMIC: Unsupported constant type %d in string concatenation",
+			entry.entryType)
+		_ = log.Log(errMsg, log.SEVERE)
+		return "", errors.New(errMsg)
+	}
+}
+
+// javaObjectToString returns the string that String.valueOf() returns for an object:
+// "null" for a null reference, the string itself for a string, and otherwise the
+// result of the object's toString() method. If the class does not override toString(),
+// the string is built the way Object.toString() does it: class name@hash code in hex.
+// An exception thrown by toString() is returned as a *goCallerException.
+func javaObjectToString(fs *list.List, f *frames.Frame, obj *object.Object) (string, error) {
+	if obj == nil {
+		return "null", nil
+	}
+	if object.IsJavaString(obj) {
+		return javaStringToGoString(obj), nil
+	}
+
+	className := *obj.Klass
+	declaringClass, m := classloader.FindInstanceMethodInSuperclasses(className, "toString", "()Ljava/lang/String;")
+	if m == nil || declaringClass == "java/lang/Object" {
+		return fmt.Sprintf("%s@%x", javaClassName(className), obj.Mark.Hash), nil
+	}
+
+	mtEntry, err := classloader.FetchMethodAndCP(declaringClass, "toString", "()Ljava/lang/String;")
+	if err != nil || mtEntry.Meth == nil {
+		errMsg := "INVOKEDYNAMIC: Could not fetch " + declaringClass + ".toString()"
+		_ = log.Log(errMsg, log.SEVERE)
+		return "", errors.New(errMsg)
+	}
+
+	// toString() is called from a golang frame, as it is called from String.valueOf() in
+	// the JDK. An exception it doesn't catch then stops at that frame, rather than being
+	// caught by a handler in f while this golang code is still running. It's returned as
+	// a goCallerException, which the INVOKEDYNAMIC rethrows.
+	cf := frames.CreateFrame(2)
+	cf.Ftype = 'G'
+	cf.ClName = "java/lang/String"
+	cf.MethName = "valueOf"
+	cf.Thread = f.Thread
+	push(cf, obj) // the object reference is the 'this' of toString()
+	callerElement := fs.PushFront(cf)
+	defer fs.Remove(callerElement)
+
+	if mtEntry.MType == 'G' {
+		_, _, err = runGmethod(mtEntry, fs, declaringClass, "toString", "()Ljava/lang/String;")
+	} else {
+		jm := mtEntry.Meth.(classloader.JmEntry)
+		var fram *frames.Frame
+		fram, err = createAndInitNewFrame(declaringClass, "toString", "()Ljava/lang/String;", &jm, true, cf)
+		if err != nil {
+			return "", err
+		}
+		fs.PushFront(fram)
+		err = runFrameToCompletion(fs)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	str, _ := pop(cf).(*object.Object)
+	if str == nil {
+		return "null", nil
+	}
+	return javaStringToGoString(str), nil
+}
+
+// javaStringToGoString returns the contents of a Java string, whose chars can be held
+// either as bytes (compact strings) or as a go string
+func javaStringToGoString(str *object.Object) string {
+	switch v := str.Fields[0].Fvalue.(type) {
+	case *[]byte:
+		return string(*v)
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return ""
+	}
+}
+
+// javaFloatToString formats a float (bitSize 32) or a double (bitSize 64) the way
+// Float.toString() and Double.toString() do: the shortest decimal that uniquely
+// identifies the value, but with at least two significant digits, of which the
+// second is dropped if it's a zero. Magnitudes in the range [10^-3, 10^7) are shown
+// as plain decimals with at least one digit after the decimal point, all others in
+// computerized scientific notation, such as 1.0E10 or 1.5E-5.
+func javaFloatToString(value float64, bitSize int) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "Infinity"
+	case math.IsInf(value, -1):
+		return "-Infinity"
+	case value == 0:
+		if math.Signbit(value) {
+			return "-0.0"
+		}
+		return "0.0"
+	}
+
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	// get the significant digits and the exponent. Go formats these as 1.2345E+07.
+	digits, exp := decimalDigits(value, -1, bitSize)
+	if len(digits) == 1 { // Java uses the closest two-digit decimal, such as 4.9E-324
+		digits, exp = decimalDigits(value, 1, bitSize)
+		digits = strings.TrimRight(digits, "0")
+		if digits == "" {
+			digits = "0"
+		}
+	}
+
+	if exp < -3 || exp >= 7 {
+		frac := digits[1:]
+		if frac == "" {
+			frac = "0"
+		}
+		return sign + digits[:1] + "." + frac + "E" + strconv.Itoa(exp)
+	}
+
+	if exp < 0 {
+		return sign + "0." + strings.Repeat("0", -exp-1) + digits
+	}
+	if len(digits) <= exp+1 {
+		return sign + digits + strings.Repeat("0", exp+1-len(digits)) + ".0"
+	}
+	return sign + digits[:exp+1] + "." + digits[exp+1:]
+}
+
+// decimalDigits returns the significant digits of a positive value, rounded to
+// precision digits after the first (-1 gives the shortest exact representation),
+// along with the decimal exponent of the first digit
+func decimalDigits(value float64, precision, bitSize int) (string, int) {
+	s := strconv.FormatFloat(value, 'E', precision, bitSize)
+	mantissa, exponent, _ := strings.Cut(s, "E")
+	exp, _ := strconv.Atoi(exponent)
+	return strings.Replace(mantissa, ".", "", 1), exp
+}
+
+// parseParamDescriptors returns the field descriptors of the parameters in a method
+// descriptor. For example, (IJLjava/lang/String;[[D)V yields I, J, Ljava/lang/String;
+// and [[D. Unlike util.ParseIncomingParamsFromMethTypeString(), it preserves the exact
+// type of each parameter, which is needed to format the values for string concatenation.
+func parseParamDescriptors(methodType string) []string {
+	var params []string
+	if !strings.HasPrefix(methodType, "(") {
+		return params
+	}
+
+	for i := 1; i < len(methodType) && methodType[i] != ')'; {
+		start := i
+		for methodType[i] == '[' {
+			i += 1
+		}
+		if methodType[i] == 'L' {
+			i = start + strings.IndexByte(methodType[start:], ';')
+		}
+		i += 1
+		params = append(params, methodType[start:i])
+	}
+	return params
+}
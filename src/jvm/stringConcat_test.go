@@ -0,0 +1,84 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// the expected strings are the output of Double.toString() and Float.toString()
+func TestJavaFloatToStringDouble(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{0.0, "0.0"},
+		{math.Copysign(0, -1), "-0.0"},
+		{1.0, "1.0"},
+		{-42.0, "-42.0"},
+		{0.1, "0.1"},
+		{3.14159, "3.14159"},
+		{0.001, "0.001"},
+		{0.0001, "1.0E-4"},
+		{1234567.0, "1234567.0"},
+		{9999999.0, "9999999.0"},
+		{10000000.0, "1.0E7"},
+		{1.2345e10, "1.2345E10"},
+		{-1.5e-5, "-1.5E-5"},
+		{1e100, "1.0E100"},
+		{math.MaxFloat64, "1.7976931348623157E308"},
+		{4.9e-324, "4.9E-324"},
+		{math.NaN(), "NaN"},
+		{math.Inf(1), "Infinity"},
+		{math.Inf(-1), "-Infinity"},
+	}
+
+	for _, test := range tests {
+		s := javaFloatToString(test.value, 64)
+		if s != test.expected {
+			t.Errorf("javaFloatToString(double %g): expected %s, got: %s", test.value, test.expected, s)
+		}
+	}
+}
+
+func TestJavaFloatToStringFloat(t *testing.T) {
+	tests := []struct {
+		value    float32
+		expected string
+	}{
+		{1.1, "1.1"},
+		{0.3, "0.3"},
+		{100.0, "100.0"},
+		{-2.5, "-2.5"},
+		{1e7, "1.0E7"},
+		{1.0e-4, "1.0E-4"},
+		{math.MaxFloat32, "3.4028235E38"},
+		{float32(math.Inf(1)), "Infinity"},
+	}
+
+	for _, test := range tests {
+		s := javaFloatToString(float64(test.value), 32)
+		if s != test.expected {
+			t.Errorf("javaFloatToString(float %g): expected %s, got: %s", test.value, test.expected, s)
+		}
+	}
+}
+
+func TestParseParamDescriptors(t *testing.T) {
+	params := parseParamDescriptors("(IJLjava/lang/String;[[DZ[Ljava/lang/Object;)Ljava/lang/String;")
+	expected := []string{"I", "J", "Ljava/lang/String;", "[[D", "Z", "[Ljava/lang/Object;"}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("parseParamDescriptors: expected %v, got: %v", expected, params)
+	}
+
+	params = parseParamDescriptors("()V")
+	if len(params) != 0 {
+		t.Errorf("parseParamDescriptors: expected no params, got: %v", params)
+	}
+}
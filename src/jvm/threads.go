@@ -0,0 +1,97 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/object"
+	"jacobin/thread"
+)
+
+// Every Java thread other than main is started by Thread.start(), which is implemented
+// in golang in the classloader package. Because running a thread's run() method requires
+// the interpreter, start() calls startThread() here, which runs run() in its own
+// interpreter loop on a new goroutine.
+
+func init() {
+	classloader.StartThread = startThread
+}
+
+// startThread creates a thread for the Thread object threadObj and runs its run()
+// method on a new goroutine. If the object's class does not override Thread.run(),
+// the run() method of the thread's target (a Runnable) is run instead.
+func startThread(threadObj *object.Object) error {
+	receiver := threadObj
+	className, mtEntry := classloader.FindMethodForReceiver(*threadObj.Klass, "run", "()V")
+	if mtEntry.Meth == nil || mtEntry.MType == 'G' { // Thread.run() itself, which just runs the target
+		receiver = classloader.GetThreadTarget(threadObj)
+		if receiver != nil {
+			className, mtEntry = classloader.FindMethodForReceiver(*receiver.Klass, "run", "()V")
+			if mtEntry.Meth == nil { // it could be a default method of an interface
+				var err error
+				mtEntry, className, err = locateInterfaceMethod(*receiver.Klass, "java/lang/Runnable", "run", "()V")
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	t := thread.CreateThread()
+	t.Stack = frames.CreateFrameStack()
+	t.Trace = MainThread.Trace
+	t.MaxFrameDepth = MainThread.MaxFrameDepth
+	t.Object = threadObj
+	t.Daemon = classloader.IsDaemonThread(threadObj)
+	thread.AddThreadToTable(&t, &globals.GetGlobalRef().Threads)
+	t.Started()
+
+	if receiver == nil || mtEntry.MType != 'J' { // there's nothing to run
+		t.Ended()
+		return nil
+	}
+
+	jm := mtEntry.Meth.(classloader.JmEntry)
+	go runStartedThread(&t, className, &jm, receiver)
+	return nil
+}
+
+// runStartedThread runs the run() method of a started thread and ends the thread when
+// run() returns. The method's frame is created by a launcher frame, which passes it the
+// receiver the way an invoking method would.
+func runStartedThread(t *thread.ExecThread, className string, jm *classloader.JmEntry, receiver *object.Object) {
+	defer t.Ended()
+
+	launcher := frames.CreateFrame(1)
+	launcher.Thread = t.ID
+	push(launcher, receiver)
+
+	fram, err := createAndInitNewFrame(className, "run", "()V", jm, true, launcher)
+	if err != nil {
+		_ = log.Log("Error creating the frame of "+className+".run() for thread "+
+			classloader.GetThreadName(t.Object), log.SEVERE)
+		return
+	}
+	t.Stack.PushFront(fram)
+
+	// an uncaught exception has been reported by throwException() and, having released
+	// the thread's monitors, ends only this thread. Whether the JVM exits is up to main.
+	_ = runThread(t)
+}
+
+// getThreadName returns the name of the thread with the given ID. Threads that
+// were not started by Thread.start(), such as the main thread, are named main.
+func getThreadName(threadID int) string {
+	t := thread.FindThread(threadID)
+	if t == nil || t.Object == nil {
+		return "main"
+	}
+	return classloader.GetThreadName(t.Object)
+}
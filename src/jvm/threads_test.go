@@ -0,0 +1,418 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"io"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/object"
+	"jacobin/thread"
+	"jacobin/types"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sets up the Go implementations of the Thread methods, a stand-in for class Thread,
+// and a class named className, with the int field done, whose run()V method has the
+// given code. The class's CP is cp, to which the code refers.
+func setupThreadTest(className, superclass string, cp *syntheticCP, code []byte) {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+	classloader.MTable = make(map[string]classloader.MTentry)
+	classloader.MTableLoadNatives()
+
+	threadData := classloader.ClData{Name: "java/lang/Thread", Superclass: "java/lang/Object",
+		MethodTable: map[string]*classloader.Method{}, ClInit: types.ClInitRun}
+	classloader.MethAreaInsert("java/lang/Thread",
+		&classloader.Klass{Status: 'X', Loader: "bootstrap", Data: &threadData})
+
+	run := &classloader.Method{AccessFlags: classloader.AccPublic,
+		CodeAttr: classloader.CodeAttrib{MaxStack: 2, MaxLocals: 1, Code: code}}
+	data := classloader.ClData{Name: className, Superclass: superclass, CP: cp.CPool,
+		MethodTable: map[string]*classloader.Method{"run()V": run}, ClInit: types.ClInitRun}
+	data.Fields = append(data.Fields, classloader.Field{Name: cp.utf8Slot("done"), Desc: cp.utf8Slot(types.Int)})
+	data.CP = cp.CPool
+	classloader.MethAreaInsert(className, &classloader.Klass{Status: 'X', Loader: "bootstrap", Data: &data})
+}
+
+// waits for the thread whose Thread object is obj to end
+func waitForThread(t *testing.T, obj *object.Object) {
+	caller := thread.CreateThread()
+	if other := thread.FindThreadByObject(obj); other != nil {
+		caller.Join(other, 5*time.Second)
+	}
+	if thread.FindThreadByObject(obj) != nil {
+		t.Fatalf("Thread: Expected the thread to end")
+	}
+}
+
+// Thread.start() runs the run() method of the thread's target on a new thread
+func TestThreadStartRunsTarget(t *testing.T) {
+	// class Task implements Runnable { int done; public void run() { done = 1; } }
+	cp := newSyntheticCP()
+	done := cp.fieldRef("Task", "done", "I")
+	code := []byte{ALOAD_0, ICONST_1, PUTFIELD, byte(done >> 8), byte(done), RETURN}
+	setupThreadTest("Task", "java/lang/Object", cp, code)
+
+	task := object.MakeEmptyObject()
+	taskClassName := "Task"
+	task.Klass = &taskClassName
+	task.FieldTable = map[string]object.Field{"done": {Ftype: types.Int, Fvalue: int64(0)}}
+	threadObj := classloader.NewThreadObject("worker", task)
+
+	if err := startThread(threadObj); err != nil {
+		t.Fatalf("Thread: Got unexpected error starting the thread: %s", err.Error())
+	}
+	waitForThread(t, threadObj)
+
+	if task.FieldTable["done"].Fvalue.(int64) != 1 {
+		t.Errorf("Thread: Expected the target's run() method to set done to 1, got: %d",
+			task.FieldTable["done"].Fvalue.(int64))
+	}
+}
+
+// Thread.start() runs the run() method of a subclass of Thread that overrides it
+func TestThreadStartRunsSubclass(t *testing.T) {
+	// class Worker extends Thread { int done; public void run() { done = 1; } }
+	cp := newSyntheticCP()
+	done := cp.fieldRef("Worker", "done", "I")
+	code := []byte{ALOAD_0, ICONST_1, PUTFIELD, byte(done >> 8), byte(done), RETURN}
+	setupThreadTest("Worker", "java/lang/Thread", cp, code)
+
+	// the test's Thread class declares no fields, so the Thread fields stay in the FieldTable
+	threadObj := classloader.NewThreadObject("", nil)
+	workerClassName := "Worker"
+	threadObj.Klass = &workerClassName
+	if err := classloader.LayOutFields(threadObj, workerClassName); err != nil {
+		t.Fatalf("Thread: Got unexpected error laying out the fields of Worker: %s", err.Error())
+	}
+
+	if err := startThread(threadObj); err != nil {
+		t.Fatalf("Thread: Got unexpected error starting the thread: %s", err.Error())
+	}
+	waitForThread(t, threadObj)
+
+	if threadObj.Fields[0].Fvalue.(int64) != 1 {
+		t.Errorf("Thread: Expected Worker.run() to set done to 1")
+	}
+}
+
+// when threads call start() on the same Thread object at the same time, only one of them
+// starts the thread. The others get an IllegalThreadStateException. start() is synchronized
+// on the Thread object, so the callers wait while another thread holds its monitor.
+func TestThreadStartTwiceConcurrently(t *testing.T) {
+	// class Task implements Runnable { int done; public void run() { done = 1; } }
+	cp := newSyntheticCP()
+	done := cp.fieldRef("Task", "done", "I")
+	code := []byte{ALOAD_0, ICONST_1, PUTFIELD, byte(done >> 8), byte(done), RETURN}
+	setupThreadTest("Task", "java/lang/Object", cp, code)
+
+	task := object.MakeEmptyObject()
+	taskClassName := "Task"
+	task.Klass = &taskClassName
+	task.FieldTable = map[string]object.Field{"done": {Ftype: types.Int, Fvalue: int64(0)}}
+	threadObj := classloader.NewThreadObject("worker", task)
+	start := classloader.MTableFetch("java/lang/Thread.start()V").Meth.(classloader.GmEntry).Fu
+
+	holder := thread.CreateThread()
+	thread.AddThreadToTable(&holder, &globals.GetGlobalRef().Threads)
+	monitor := object.GetMonitor(threadObj)
+	monitor.Enter(holder.ID)
+
+	const callers = 8
+	results := make(chan interface{}, callers)
+	for i := 0; i < callers; i++ {
+		caller := thread.CreateThread()
+		thread.AddThreadToTable(&caller, &globals.GetGlobalRef().Threads)
+		go func() {
+			results <- start([]interface{}{threadObj, caller.ID})
+		}()
+	}
+	select {
+	case ret := <-results:
+		t.Fatalf("Thread: Expected start() to wait for the monitor of the Thread object, but it returned: %v", ret)
+	case <-time.After(50 * time.Millisecond):
+	}
+	monitor.Exit(holder.ID)
+
+	started := 0
+	for i := 0; i < callers; i++ {
+		switch ret := (<-results).(type) {
+		case nil:
+			started++
+		case *classloader.GErrBlk:
+			if ret.ExceptionType != "java/lang/IllegalThreadStateException" {
+				t.Errorf("Thread: Expected an IllegalThreadStateException, got: %s", ret.ExceptionType)
+			}
+		default:
+			t.Errorf("Thread: Got unexpected return from start(): %v", ret)
+		}
+	}
+	waitForThread(t, threadObj)
+
+	if started != 1 {
+		t.Errorf("Thread: Expected the thread to be started once, but it was started %d times", started)
+	}
+	if task.FieldTable["done"].Fvalue.(int64) != 1 {
+		t.Errorf("Thread: Expected run() to set done to 1, got: %d", task.FieldTable["done"].Fvalue.(int64))
+	}
+}
+
+// each thread runs on its own goroutine and has its own ID
+func TestThreadsHaveUniqueIDs(t *testing.T) {
+	// class Task implements Runnable { public void run() { Thread.sleep(50); } }
+	cp := newSyntheticCP()
+	sleep := cp.methodRef("java/lang/Thread", "sleep", "(J)V")
+	code := []byte{BIPUSH, 50, I2L, INVOKESTATIC, byte(sleep >> 8), byte(sleep), RETURN}
+	setupThreadTest("Task", "java/lang/Object", cp, code)
+
+	taskClassName := "Task"
+	var threadObjs []*object.Object
+	ids := make(map[int]bool)
+	for i := 0; i < 3; i++ {
+		task := object.MakeEmptyObject()
+		task.Klass = &taskClassName
+		threadObj := classloader.NewThreadObject("", task)
+		if err := startThread(threadObj); err != nil {
+			t.Fatalf("Thread: Got unexpected error starting the thread: %s", err.Error())
+		}
+		threadObjs = append(threadObjs, threadObj)
+
+		et := thread.FindThreadByObject(threadObj)
+		if et == nil {
+			t.Fatalf("Thread: Expected the thread to be alive while it sleeps")
+		}
+		ids[et.ID] = true
+	}
+
+	if len(ids) != 3 {
+		t.Errorf("Thread: Expected 3 distinct thread IDs, got: %v", ids)
+	}
+	for _, threadObj := range threadObjs {
+		waitForThread(t, threadObj)
+	}
+}
+
+// an exception that no method catches ends only the thread that threw it. It's reported,
+// but the JVM doesn't shut down, and the other threads run to their end.
+func TestThreadUncaughtExceptionEndsOnlyItsThread(t *testing.T) {
+	// class Task implements Runnable { int done; public void run() { Thread.sleep(50); done = 1; } }
+	cp := newSyntheticCP()
+	sleep := cp.methodRef("java/lang/Thread", "sleep", "(J)V")
+	done := cp.fieldRef("Task", "done", "I")
+	code := []byte{BIPUSH, 50, I2L, INVOKESTATIC, byte(sleep >> 8), byte(sleep),
+		ALOAD_0, ICONST_1, PUTFIELD, byte(done >> 8), byte(done), RETURN}
+	setupThreadTest("Task", "java/lang/Object", cp, code)
+	log.Level = log.INFO // to see any request to shut down
+
+	// class Thrower implements Runnable { public void run() { throw null; } }
+	run := &classloader.Method{AccessFlags: classloader.AccPublic,
+		CodeAttr: classloader.CodeAttrib{MaxStack: 1, MaxLocals: 1, Code: []byte{ACONST_NULL, ATHROW}}}
+	throwerData := classloader.ClData{Name: "Thrower", Superclass: "java/lang/Object", CP: newSyntheticCP().CPool,
+		MethodTable: map[string]*classloader.Method{"run()V": run}, ClInit: types.ClInitRun}
+	classloader.MethAreaInsert("Thrower", &classloader.Klass{Status: 'X', Loader: "bootstrap", Data: &throwerData})
+
+	normalStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	task := object.MakeEmptyObject()
+	taskClassName := "Task"
+	task.Klass = &taskClassName
+	task.FieldTable = map[string]object.Field{"done": {Ftype: types.Int, Fvalue: int64(0)}}
+	taskThread := classloader.NewThreadObject("worker", task)
+
+	thrower := object.MakeEmptyObject()
+	throwerClassName := "Thrower"
+	thrower.Klass = &throwerClassName
+	throwerThread := classloader.NewThreadObject("thrower", thrower)
+
+	for _, threadObj := range []*object.Object{taskThread, throwerThread} {
+		if err := startThread(threadObj); err != nil {
+			t.Fatalf("Thread: Got unexpected error starting the thread: %s", err.Error())
+		}
+	}
+	waitForThread(t, throwerThread)
+	waitForThread(t, taskThread)
+
+	_ = w.Close()
+	os.Stderr = normalStderr
+	msg, _ := io.ReadAll(r)
+	output := string(msg)
+
+	if !strings.Contains(output, "Exception in thread \"thrower\" java.lang.NullPointerException") {
+		t.Errorf("Thread: Expected the uncaught exception to be reported, got: %s", output)
+	}
+	if strings.Contains(output, "shutdown.Exit") {
+		t.Errorf("Thread: Expected no shutdown for an exception in a thread other than main, got: %s", output)
+	}
+	if task.FieldTable["done"].Fvalue.(int64) != 1 {
+		t.Errorf("Thread: Expected the other thread to run to its end and set done to 1")
+	}
+}
+
+// a Java exception thrown by a Go function, here the InterruptedException thrown by
+// Thread.sleep() in an interrupted thread, is caught by the calling method
+func TestThreadSleepInterrupted(t *testing.T) {
+	cp := newSyntheticCP()
+	sleep := cp.methodRef("java/lang/Thread", "sleep", "(J)V")
+	setupThreadTest("Task", "java/lang/Object", cp, []byte{RETURN})
+
+	et := thread.CreateThread()
+	thread.AddThreadToTable(&et, &globals.GetGlobalRef().Threads)
+	et.Interrupt()
+
+	// try { Thread.sleep(1000); return; } catch (InterruptedException e) { return 42; }
+	f := frames.CreateFrame(4)
+	f.Thread = et.ID
+	f.CP = &cp.CPool
+	f.Meth = []byte{SIPUSH, 0x03, 0xE8, I2L, INVOKESTATIC, byte(sleep >> 8), byte(sleep), RETURN,
+		POP, BIPUSH, 42}
+	f.ExcTable = append(f.ExcTable, classloader.CodeException{StartPc: 0, EndPc: 7, HandlerPc: 8})
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	start := time.Now()
+	err := runFrame(fs)
+	if err != nil {
+		t.Fatalf("Thread: Got unexpected error: %s", err.Error())
+	}
+
+	if time.Since(start) >= time.Second {
+		t.Errorf("Thread: Expected sleep() to return immediately in an interrupted thread")
+	}
+	value := pop(f).(int64)
+	if value != 42 {
+		t.Errorf("Thread: Expected the InterruptedException handler to push 42, got: %d", value)
+	}
+	if et.IsInterrupted() {
+		t.Errorf("Thread: Expected the interrupt status to be cleared by the InterruptedException")
+	}
+}
+
+// runs code in a frame of the thread et, with obj in local 0
+func runInThread(t *testing.T, et *thread.ExecThread, cp *syntheticCP, obj *object.Object,
+	code []byte, excTable []classloader.CodeException) *frames.Frame {
+	f := frames.CreateFrame(4)
+	f.Thread = et.ID
+	f.CP = &cp.CPool
+	f.Locals = []interface{}{obj}
+	f.Meth = code
+	f.ExcTable = excTable
+
+	fs := frames.CreateFrameStack()
+	fs.PushFront(f)
+	if err := runFrame(fs); err != nil {
+		t.Fatalf("Object: Got unexpected error: %s", err.Error())
+	}
+	return f
+}
+
+// Object.wait() by a thread that does not own the object's monitor throws an
+// IllegalMonitorStateException
+func TestObjectWaitWithoutMonitor(t *testing.T) {
+	cp := newSyntheticCP()
+	wait := cp.methodRef("java/lang/Object", "wait", "()V")
+	setupThreadTest("Task", "java/lang/Object", cp, []byte{RETURN})
+
+	et := thread.CreateThread()
+	thread.AddThreadToTable(&et, &globals.GetGlobalRef().Threads)
+	task := object.MakeEmptyObject()
+	taskClassName := "Task"
+	task.Klass = &taskClassName
+
+	// try { this.wait(); return; } catch (IllegalMonitorStateException e) { return 42; }
+	code := []byte{ALOAD_0, INVOKEVIRTUAL, byte(wait >> 8), byte(wait), RETURN, POP, BIPUSH, 42}
+	f := runInThread(t, &et, cp, task, code,
+		[]classloader.CodeException{{StartPc: 0, EndPc: 4, HandlerPc: 5}})
+
+	if value := pop(f).(int64); value != 42 {
+		t.Errorf("Object: Expected the IllegalMonitorStateException handler to push 42, got: %d", value)
+	}
+}
+
+// Object.notify() wakes a thread waiting on the object, which resumes once it has
+// reacquired the monitor
+func TestObjectNotifyWakesWaitingThread(t *testing.T) {
+	// class Task implements Runnable {
+	//     int done;
+	//     public void run() { synchronized (this) { this.wait(); done = 1; } } }
+	cp := newSyntheticCP()
+	wait := cp.methodRef("java/lang/Object", "wait", "()V")
+	notify := cp.methodRef("java/lang/Object", "notify", "()V")
+	done := cp.fieldRef("Task", "done", "I")
+	code := []byte{ALOAD_0, MONITORENTER, ALOAD_0, INVOKEVIRTUAL, byte(wait >> 8), byte(wait),
+		ALOAD_0, ICONST_1, PUTFIELD, byte(done >> 8), byte(done), ALOAD_0, MONITOREXIT, RETURN}
+	setupThreadTest("Task", "java/lang/Object", cp, code)
+
+	task := object.MakeEmptyObject()
+	taskClassName := "Task"
+	task.Klass = &taskClassName
+	task.FieldTable = map[string]object.Field{"done": {Ftype: types.Int, Fvalue: int64(0)}}
+	threadObj := classloader.NewThreadObject("waiter", task)
+	if err := startThread(threadObj); err != nil {
+		t.Fatalf("Object: Got unexpected error starting the thread: %s", err.Error())
+	}
+
+	// synchronized (task) { task.notify(); }, repeated until the waiter has been
+	// notified, as it might not be waiting yet
+	notifier := thread.CreateThread()
+	thread.AddThreadToTable(&notifier, &globals.GetGlobalRef().Threads)
+	notifyCode := []byte{ALOAD_0, MONITORENTER, ALOAD_0, INVOKEVIRTUAL, byte(notify >> 8), byte(notify),
+		ALOAD_0, MONITOREXIT, RETURN}
+	deadline := time.Now().Add(5 * time.Second)
+	for thread.FindThreadByObject(threadObj) != nil && time.Now().Before(deadline) {
+		runInThread(t, &notifier, cp, task, notifyCode, nil)
+		time.Sleep(5 * time.Millisecond)
+	}
+	waitForThread(t, threadObj)
+
+	if task.FieldTable["done"].Fvalue.(int64) != 1 {
+		t.Errorf("Object: Expected the notified thread to set done to 1")
+	}
+}
+
+// interrupting a thread that is waiting on an object throws an InterruptedException
+// in that thread
+func TestObjectWaitInterrupted(t *testing.T) {
+	cp := newSyntheticCP()
+	wait := cp.methodRef("java/lang/Object", "wait", "()V")
+	setupThreadTest("Task", "java/lang/Object", cp, []byte{RETURN})
+
+	et := thread.CreateThread()
+	thread.AddThreadToTable(&et, &globals.GetGlobalRef().Threads)
+	lock := object.MakeEmptyObject()
+	lockClassName := "Task"
+	lock.Klass = &lockClassName
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		et.Interrupt()
+	}()
+
+	// synchronized (lock) { try { lock.wait(); return; } catch (InterruptedException e) { return 42; } }
+	code := []byte{ALOAD_0, MONITORENTER, ALOAD_0, INVOKEVIRTUAL, byte(wait >> 8), byte(wait), RETURN,
+		POP, BIPUSH, 42}
+	f := runInThread(t, &et, cp, lock, code,
+		[]classloader.CodeException{{StartPc: 2, EndPc: 6, HandlerPc: 7}})
+
+	if value := pop(f).(int64); value != 42 {
+		t.Errorf("Object: Expected the InterruptedException handler to push 42, got: %d", value)
+	}
+	if et.IsInterrupted() {
+		t.Errorf("Object: Expected the interrupt status to be cleared by the InterruptedException")
+	}
+	if !object.GetMonitor(lock).IsOwnedBy(et.ID) {
+		t.Errorf("Object: Expected the thread to own the monitor again after the wait")
+	}
+}
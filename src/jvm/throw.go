@@ -0,0 +1,415 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"jacobin/classloader"
+	"jacobin/frames"
+	"jacobin/globals"
+	"jacobin/log"
+	"jacobin/object"
+	"jacobin/thread"
+	"strconv"
+	"strings"
+)
+
+// The handling of thrown exceptions (the ATHROW bytecode). When an exception is
+// thrown, the exception table of the current method is searched for a handler
+// whose range of bytecodes covers the present PC and whose catch type is the
+// class of the thrown object or one of its superclasses. If no such handler is
+// found, the frame is popped, releasing the monitor of a synchronized method,
+// and the search continues in the calling method, and so on up the frame stack.
+// If no method catches the exception, a report in the format used by the JDK is
+// printed and the thread ends.
+
+// the maximum number of frames shown in the stack trace of an exception
+const maxStackTraceDepth = 1024
+
+// Throwable's fillInStackTrace() and printStackTrace() are implemented in the classloader
+// package, but they need the frame stack, so they call the functions here.
+func init() {
+	classloader.FillInStackTrace = fillInStackTrace
+	classloader.StackTraceOf = getStackTraceReport
+}
+
+// throwException walks the frame stack looking for a handler for the exception
+// object excObj. On success, the frames above the handler's frame are popped and
+// that frame is returned with its PC set to the first bytecode of the handler and
+// the exception object on its otherwise empty operand stack. The search ends at a
+// <clinit> frame, in which case the frames above it are popped and an
+// *initializerException is returned, and at the frame of a golang method that called
+// Java code, as Method.invoke() does, in which case the frames above it are popped and
+// a *goCallerException is returned. If no handler is found, the uncaught exception is
+// reported and an error is returned.
+func throwException(fs *list.List, excObj *object.Object) (*frames.Frame, error) {
+	excClass := *excObj.Klass
+	if getBacktrace(excObj) == nil { // the JVM created it, or its constructor didn't record it
+		setBacktrace(excObj, getStackTraceLines(fs.Front(), 0))
+	}
+
+	// the current frame is on the throwing bytecode. All the other frames are
+	// on the bytecode following the invocation, so we step back one byte to
+	// get a PC that is still inside the invoking instruction.
+	pcOffset := 0
+	for e := fs.Front(); e != nil; e = e.Next() {
+		f := e.Value.(*frames.Frame)
+		if f.Ftype != 'G' {
+			handlerPC, found := findExceptionHandler(f, f.PC-pcOffset, excClass)
+			if found {
+				for fs.Front() != e { // pop the frames that did not catch the exception
+					exitSynchronizedMethod(fs.Front().Value.(*frames.Frame))
+					fs.Remove(fs.Front())
+				}
+				f.TOS = -1 // the JVM clears the operand stack before jumping to the handler
+				push(f, excObj)
+				f.PC = handlerPC
+				return f, nil
+			}
+		}
+		if f.MethName == "<clinit>" { // the exception is rethrown by initializeClass()
+			for fs.Front() != e {
+				exitSynchronizedMethod(fs.Front().Value.(*frames.Frame))
+				fs.Remove(fs.Front())
+			}
+			return nil, &initializerException{exception: excObj}
+		}
+		if f.Ftype == 'G' { // the exception is returned to the golang method
+			for fs.Front() != e {
+				exitSynchronizedMethod(fs.Front().Value.(*frames.Frame))
+				fs.Remove(fs.Front())
+			}
+			return nil, &goCallerException{exception: excObj}
+		}
+		pcOffset = 1
+	}
+
+	// the frames are left on the stack for any further diagnostic output, but
+	// the monitors they hold are released, as the thread is ending
+	for e := fs.Front(); e != nil; e = e.Next() {
+		exitSynchronizedMethod(e.Value.(*frames.Frame))
+	}
+	threadName := getThreadName(fs.Front().Value.(*frames.Frame).Thread)
+	reportUncaughtException(threadName, excObj)
+	errMsg := "uncaught exception: " + javaClassName(excClass)
+	return nil, errors.New(errMsg)
+}
+
+// goCallerException is the error returned by throwException() when an exception is not
+// caught by the Java code that a golang method called. The golang method then decides
+// what to throw, as Method.invoke() wraps the exception in an InvocationTargetException.
+type goCallerException struct {
+	exception *object.Object
+}
+
+func (e *goCallerException) Error() string {
+	return "exception in Java code called by a go method: " + javaClassName(*e.exception.Klass)
+}
+
+// frameStackIsFull reports whether the frame stack fs is at the maximum depth of the
+// thread that runs it, set by -Xss or -Xmaxframes, so that invoking another method must
+// throw a StackOverflowError. The thread is identified by the frame at the top of fs.
+// A stack that belongs to no thread in the thread table has the main thread's maximum.
+func frameStackIsFull(fs *list.List) bool {
+	maxDepth := MainThread.MaxFrameDepth
+	if threadID := fs.Front().Value.(*frames.Frame).Thread; threadID != MainThread.ID {
+		if t := thread.FindThread(threadID); t != nil {
+			maxDepth = t.MaxFrameDepth
+		}
+	}
+	return maxDepth > 0 && fs.Len() >= maxDepth
+}
+
+// throwNewException creates an exception of the named class with the given detail
+// message and throws it from the current frame, as is done for the errors the JVM
+// itself detects, such as a StackOverflowError. As with throwException(), it returns
+// the frame of the handler that catches it, or an error if it's not caught.
+func throwNewException(fs *list.List, className, msg string) (*frames.Frame, error) {
+	return throwException(fs, createThrowable(className, msg))
+}
+
+// throwResolutionError throws the exception for a symbolic reference that could not be
+// resolved, such as a NoSuchFieldError. The classloader reports it as a *GErrBlk. Any
+// other error is returned as is.
+func throwResolutionError(fs *list.List, err error) (*frames.Frame, error) {
+	var errBlk *classloader.GErrBlk
+	if !errors.As(err, &errBlk) {
+		return nil, err
+	}
+	return throwNewException(fs, errBlk.ExceptionType, errBlk.ErrMsg)
+}
+
+// createThrowable creates an object of the named Throwable class with the given detail
+// message. No constructor is run, so this is used only for the errors that the JVM
+// itself throws. The fields are set as Throwable's constructor would set them. An
+// empty message leaves the detail message null.
+func createThrowable(className, msg string) *object.Object {
+	excObj := object.MakeEmptyObject()
+	excObj.Klass = &className
+	addThrowableFields(excObj, className)
+
+	if msg != "" {
+		classloader.SetObjectField(excObj, "detailMessage", object.Field{
+			Ftype: "Ljava/lang/String;", Fvalue: object.NewStringFromGoString(msg)})
+	}
+	if cause, ok := classloader.GetObjectField(excObj, "cause"); ok { // a cause of this means it's not been set
+		cause.Fvalue = excObj
+		classloader.SetObjectField(excObj, "cause", cause)
+	}
+	return excObj
+}
+
+// addThrowableFields gives the exception object the instance fields laid out by its
+// class. The class and its superclasses are loaded from the JDK's jmods as needed. If
+// they can't be, the object's fields are kept in its FieldTable, and it gets none
+// other than those set by createThrowable(), which are all the JVM itself uses.
+func addThrowableFields(excObj *object.Object, className string) {
+	for name := className; name != "" && name != "java/lang/Object"; {
+		k := classloader.MethAreaFetch(name)
+		if k == nil {
+			if classloader.JmodMapSize() == 0 || classloader.JmodMapFetch(name) == "" {
+				return
+			}
+			if classloader.LoadClassFromNameOnly(name) != nil ||
+				classloader.WaitForClassStatus(name) != nil {
+				return
+			}
+			k = classloader.MethAreaFetch(name)
+		}
+		if k == nil || k.Data == nil {
+			return
+		}
+		name = k.Data.Superclass
+	}
+
+	_ = classloader.LayOutFields(excObj, className)
+}
+
+// findExceptionHandler checks the exception table of frame f for a handler that
+// covers the bytecode at pc and that catches exceptions of class excClass. It
+// returns the PC of the handler and true if one is found. Entries are checked in
+// the order in which they appear in the table, as required by the JVMS.
+func findExceptionHandler(f *frames.Frame, pc int, excClass string) (int, bool) {
+	for _, entry := range f.ExcTable {
+		if pc < int(entry.StartPc) || pc >= int(entry.EndPc) {
+			continue
+		}
+
+		// a catch type of 0 means the handler catches all exceptions (it's
+		// used for finally blocks), otherwise it points to a class reference
+		if entry.CatchType == 0 {
+			return int(entry.HandlerPc), true
+		}
+
+		catchClass, _ := getClassNameFromCPclassref(f.CP, entry.CatchType)
+		if catchClass == "" {
+			continue
+		}
+
+		if isClassOrSubclassOf(excClass, catchClass) {
+			return int(entry.HandlerPc), true
+		}
+	}
+	return -1, false
+}
+
+// isClassOrSubclassOf reports whether className is the same class as target or one
+// of its subclasses. It ascends the superclasses of className, loading them as needed.
+func isClassOrSubclassOf(className, target string) bool {
+	for className != "" {
+		if className == target {
+			return true
+		}
+
+		if className == "java/lang/Object" {
+			return false
+		}
+
+		if loadThisClass(className) != nil {
+			return false
+		}
+
+		k := classloader.MethAreaFetch(className)
+		if k == nil || k.Data == nil {
+			return false
+		}
+		className = k.Data.Superclass
+	}
+	return false
+}
+
+// getStackTraceLines returns the lines of a JDK-style stack trace for the frames on
+// the frame stack from e down. pcOffset is subtracted from the PC of the first frame,
+// and 1 from those of the others, which are on the bytecode after the invocation. As
+// in the JDK, at most maxStackTraceDepth frames are shown, which matters after a stack
+// overflow.
+func getStackTraceLines(e *list.Element, pcOffset int) []string {
+	var lines []string
+	for ; e != nil && len(lines) < maxStackTraceDepth; e = e.Next() {
+		f := e.Value.(*frames.Frame)
+		methName, _, _ := strings.Cut(f.MethName, "(") // go methods have the type in the name
+		lines = append(lines, fmt.Sprintf("\tat %s.%s(%s)",
+			javaClassName(f.ClName), methName, getSourceLocation(f, f.PC-pcOffset)))
+		pcOffset = 1
+	}
+	return lines
+}
+
+// getSourceLocation returns the source file and line of the bytecode at pc in the
+// frame's method, in the format the JDK uses: Bar.java:42, or Bar.java if the line
+// is not known. It's "Unknown Source" if the source file is not known, and "Native
+// Method" for go methods.
+func getSourceLocation(f *frames.Frame, pc int) string {
+	if f.Ftype == 'G' {
+		return "Native Method"
+	}
+
+	k := classloader.MethAreaFetch(f.ClName)
+	if k == nil || k.Data == nil || k.Data.SourceFile == "" {
+		return "Unknown Source"
+	}
+
+	line := classloader.SourceLineOf(f.LineNums, pc)
+	if line < 0 {
+		return k.Data.SourceFile
+	}
+	return k.Data.SourceFile + ":" + strconv.Itoa(line)
+}
+
+// fillInStackTrace records the stack trace of the thread with the given ID in the
+// throwable, as Throwable.fillInStackTrace() does. As in the JDK, the trace begins
+// with the method that created the throwable, so the frames of fillInStackTrace()
+// and of the constructors of the throwable's class and superclasses are skipped.
+func fillInStackTrace(throwable *object.Object, threadID int) {
+	t := thread.FindThread(threadID)
+	if t == nil || t.Stack == nil {
+		return
+	}
+
+	e := t.Stack.Front()
+	for e != nil {
+		f := e.Value.(*frames.Frame)
+		if f.Ftype != 'G' && !strings.HasPrefix(f.MethName, "fillInStackTrace") {
+			break
+		}
+		e = e.Next()
+	}
+	for e != nil {
+		f := e.Value.(*frames.Frame)
+		if f.MethName != "<init>" || !isClassOrSubclassOf(*throwable.Klass, f.ClName) {
+			break
+		}
+		e = e.Next()
+	}
+	setBacktrace(throwable, getStackTraceLines(e, 1))
+}
+
+// setBacktrace stores the lines of the stack trace in the throwable's backtrace field,
+// which the JDK uses for the same purpose
+func setBacktrace(throwable *object.Object, lines []string) {
+	classloader.SetObjectField(throwable, "backtrace", object.Field{Ftype: "Ljava/lang/Object;", Fvalue: lines})
+}
+
+// getBacktrace returns the lines of the stack trace recorded in the throwable, if any
+func getBacktrace(throwable *object.Object) []string {
+	backtrace, _ := classloader.GetObjectField(throwable, "backtrace")
+	lines, _ := backtrace.Fvalue.([]string)
+	return lines
+}
+
+// getThrowableCause returns the cause of the throwable, or nil if it has none. As in
+// the JDK, a throwable whose cause has not been set has itself as its cause.
+func getThrowableCause(throwable *object.Object) *object.Object {
+	fld, _ := classloader.GetObjectField(throwable, "cause")
+	cause, ok := fld.Fvalue.(*object.Object)
+	if !ok || cause == throwable {
+		return nil
+	}
+	return cause
+}
+
+// getStackTraceReport returns the lines that Throwable.printStackTrace() prints: the
+// throwable and its stack trace, followed by each of its causes. As in the JDK, the
+// frames that a cause's trace has in common with the trace of the throwable it caused
+// are replaced by a line giving their number.
+func getStackTraceReport(throwable *object.Object) []string {
+	trace := getBacktrace(throwable)
+	lines := append([]string{throwableToString(throwable)}, trace...)
+
+	seen := map[*object.Object]bool{throwable: true}
+	for cause := getThrowableCause(throwable); cause != nil; cause = getThrowableCause(cause) {
+		if seen[cause] {
+			lines = append(lines, "Caused by: [CIRCULAR REFERENCE: "+throwableToString(cause)+"]")
+			break
+		}
+		seen[cause] = true
+
+		causeTrace := getBacktrace(cause)
+		inCommon := 0
+		for inCommon < len(causeTrace) && inCommon < len(trace) &&
+			causeTrace[len(causeTrace)-1-inCommon] == trace[len(trace)-1-inCommon] {
+			inCommon++
+		}
+
+		lines = append(lines, "Caused by: "+throwableToString(cause))
+		lines = append(lines, causeTrace[:len(causeTrace)-inCommon]...)
+		if inCommon > 0 {
+			lines = append(lines, fmt.Sprintf("\t... %d more", inCommon))
+		}
+		trace = causeTrace
+	}
+	return lines
+}
+
+// throwableToString returns the class name of the throwable followed by its detail
+// message, if any, as Throwable.toString() does
+func throwableToString(throwable *object.Object) string {
+	str := javaClassName(*throwable.Klass)
+	if msg := getThrowableMessage(throwable); msg != "" {
+		str += ": " + msg
+	}
+	return str
+}
+
+// getThrowableMessage returns the detail message of a Throwable object, or "" if
+// there is none.
+func getThrowableMessage(excObj *object.Object) string {
+	fld, ok := classloader.GetObjectField(excObj, "detailMessage")
+	if !ok || fld.Fvalue == nil {
+		return ""
+	}
+
+	msg, ok := fld.Fvalue.(*object.Object)
+	if !ok || msg == nil || len(msg.Fields) == 0 {
+		return ""
+	}
+
+	switch val := msg.Fields[0].Fvalue.(type) {
+	case *[]byte:
+		return string(*val)
+	case string:
+		return val
+	}
+	return ""
+}
+
+// reportUncaughtException prints the report of an exception that no method caught,
+// formatted as the JDK does it. The exception ends only the thread that threw it:
+// the JVM exits once main() has ended and the other threads that are not daemons
+// have ended, too.
+func reportUncaughtException(threadName string, excObj *object.Object) {
+	report := fmt.Sprintf("Exception in thread \"%s\" ", threadName) +
+		strings.Join(getStackTraceReport(excObj), "\n")
+	_ = log.Log(report, log.SEVERE)
+
+	// the report is all the user needs to see, so suppress the other diagnostic output
+	glob := globals.GetGlobalRef()
+	glob.JvmFrameStackShown = true
+	glob.GoStackShown = true
+}
@@ -0,0 +1,67 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/object"
+	"jacobin/types"
+	"strings"
+)
+
+// The type checks of CHECKCAST, INSTANCEOF, and AASTORE. An object's class is the name in its
+// Klass field. For arrays, that's the array type, such as [I or [Ljava/lang/String;.
+
+// Class.isInstance() and isAssignableFrom() are implemented in the classloader package,
+// but they make the same checks, so they call isAssignableTo() here.
+func init() {
+	classloader.IsAssignableTo = isAssignableTo
+}
+
+// isInstanceOf reports whether the object is an instance of the named class, interface,
+// or array class
+func isInstanceOf(obj *object.Object, className string) bool {
+	return obj.Klass != nil && isAssignableTo(*obj.Klass, className)
+}
+
+// isAssignableTo reports whether a reference to an object of class s can be assigned to
+// a reference of type t, following the rules of CHECKCAST in JVMS 6.5. Arrays of references
+// are covariant: an array of s can be assigned to an array of t if s can be assigned to t.
+// Every array is also an Object, a Cloneable, and a Serializable.
+// https://docs.oracle.com/javase/specs/jvms/se17/html/jvms-6.html#jvms-6.5.checkcast
+func isAssignableTo(s, t string) bool {
+	if s == t || t == "java/lang/Object" {
+		return true
+	}
+
+	if !strings.HasPrefix(s, types.Array) {
+		if strings.HasPrefix(t, types.Array) {
+			return false
+		}
+		return isClassOrSubclassOf(s, t) || classloader.ImplementsInterface(s, t)
+	}
+
+	// s is an array class
+	if !strings.HasPrefix(t, types.Array) {
+		return t == "java/lang/Cloneable" || t == "java/io/Serializable"
+	}
+	sComponent, tComponent := s[1:], t[1:]
+	if len(sComponent) == 1 || len(tComponent) == 1 { // arrays of different primitives
+		return false
+	}
+	return isAssignableTo(componentClassName(sComponent), componentClassName(tComponent))
+}
+
+// componentClassName returns the name of the class of an array's components, given the
+// component type from the array type: Ljava/lang/String; is java/lang/String, while an array
+// type, such as [I, is its own class name.
+func componentClassName(componentType string) string {
+	if strings.HasPrefix(componentType, types.Ref) {
+		return strings.TrimSuffix(componentType[1:], ";")
+	}
+	return componentType
+}
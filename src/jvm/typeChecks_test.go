@@ -0,0 +1,58 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"jacobin/classloader"
+	"jacobin/globals"
+	"jacobin/log"
+	"testing"
+)
+
+// puts the classes Animal, Dog extends Animal, and Cat extends Animal in a new method area
+func setupAnimalClasses() {
+	globals.InitGlobals("test")
+	log.Init()
+	classloader.InitMethodArea()
+	classes := map[string]string{"java/lang/Object": "", "Animal": "java/lang/Object",
+		"Dog": "Animal", "Cat": "Animal"}
+	for name, superclass := range classes {
+		classloader.MethAreaInsert(name, &classloader.Klass{Status: 'X', Loader: "app",
+			Data: &classloader.ClData{Name: name, Superclass: superclass}})
+	}
+}
+
+func TestIsAssignableToWithArrays(t *testing.T) {
+	setupAnimalClasses()
+
+	tests := []struct {
+		s, t       string
+		assignable bool
+	}{
+		{"[LDog;", "[LAnimal;", true},
+		{"[LAnimal;", "[LDog;", false},
+		{"[LDog;", "[LCat;", false},
+		{"[[LDog;", "[[LAnimal;", true},
+		{"[[LDog;", "[LAnimal;", false},
+		{"[LDog;", "[Ljava/lang/Object;", true},
+		{"[[I", "[Ljava/lang/Object;", true},
+		{"[I", "[Ljava/lang/Object;", false},
+		{"[C", "[I", false},
+		{"[I", "[I", true},
+		{"[I", "java/lang/Object", true},
+		{"[LDog;", "java/lang/Cloneable", true},
+		{"[LDog;", "java/io/Serializable", true},
+		{"[LDog;", "Animal", false},
+		{"Dog", "[LDog;", false},
+		{"Dog", "Animal", true},
+	}
+	for _, test := range tests {
+		if isAssignableTo(test.s, test.t) != test.assignable {
+			t.Errorf("Expected isAssignableTo(%s, %s) to be %t", test.s, test.t, test.assignable)
+		}
+	}
+}
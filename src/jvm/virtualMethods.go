@@ -0,0 +1,158 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package jvm
+
+import (
+	"errors"
+	"fmt"
+	"jacobin/classloader"
+)
+
+// locateVirtualMethod finds the method to execute for an INVOKEVIRTUAL of the method
+// methName with type methType, resolved in class className, on an object of class
+// receiverClass. Private and final methods cannot be overridden, so they're executed
+// as resolved. All others are selected per the JVMS (5.4.6): the first matching method
+// in the receiver's class or its superclasses, or else the single maximally-specific
+// default method in its superinterfaces. The selected method is cached in the MTable
+// under the receiver's class (see selectedMethodKey), so later invocations on objects
+// of the same class don't repeat the search.
+//
+// Returns the MTable entry and the name of the class that declares the method. The
+// errors that the JVMS requires are returned as a *GErrBlk, which the caller throws.
+func locateVirtualMethod(receiverClass, className, methName, methType string) (classloader.MTentry, string, error) {
+	// resolution: a private or final method in the resolved class is invoked directly.
+	// This is checked before the MTable, which can hold a method of the same name and
+	// type that's selected for the receiver's class.
+	m, err := classloader.FindNonOverridableMethod(className, methName, methType)
+	if err != nil {
+		return classloader.MTentry{}, "", err
+	}
+	if m != nil {
+		mtEntry, err := classloader.FetchMethodAndCP(className, methName, methType)
+		if err != nil || mtEntry.Meth == nil {
+			errMsg := "INVOKEVIRTUAL: Class method not found: " + className + "." + methName
+			return classloader.MTentry{}, "", errors.New(errMsg)
+		}
+		return mtEntry, className, nil
+	}
+
+	// has this method already been selected for this receiver class?
+	methKey := selectedMethodKey(receiverClass, methName, methType)
+	mtEntry := classloader.MTableFetch(methKey)
+	if mtEntry.Meth != nil {
+		return mtEntry, declaringClassOf(mtEntry, receiverClass), nil
+	}
+
+	declaringClass, mtEntry, err := selectMethod(receiverClass, methName, methType)
+	if err != nil {
+		return classloader.MTentry{}, "", err
+	}
+
+	// if the receiver's class can't be loaded (as can happen with classes whose methods
+	// are all implemented in golang), fall back on the method in the resolved class
+	if declaringClass == "" && classloader.MethAreaFetch(receiverClass) == nil {
+		mtEntry, err = classloader.FetchMethodAndCP(className, methName, methType)
+		if err != nil || mtEntry.Meth == nil {
+			errMsg := "INVOKEVIRTUAL: Class method not found: " + className + "." + methName
+			return classloader.MTentry{}, "", errors.New(errMsg)
+		}
+		return mtEntry, declaringClassOf(mtEntry, className), nil
+	}
+
+	if declaringClass == "" || isAbstractMethod(mtEntry) {
+		errMsg := fmt.Sprintf("Receiver class %s does not define or inherit an implementation of the "+
+			"resolved method 'abstract %s' of abstract class %s.",
+			javaClassName(receiverClass), methName+methType, javaClassName(className))
+		return classloader.MTentry{}, "", &classloader.GErrBlk{
+			ExceptionType: "java/lang/AbstractMethodError", ErrMsg: errMsg}
+	}
+
+	classloader.MTableInsert(methKey, mtEntry)
+	return mtEntry, declaringClass, nil
+}
+
+// selectedMethodKey returns the MTable key under which the method selected for invocations
+// of methName with type methType on objects of the receiver's class is cached. It differs
+// from the key of a method that the class declares, which FetchMethodAndCP() looks up,
+// since a private or static method that the class declares is never selected. The
+// selection is the same for INVOKEVIRTUAL and INVOKEINTERFACE, so they share the key.
+func selectedMethodKey(receiverClass, methName, methType string) string {
+	return receiverClass + ";selected." + methName + methType
+}
+
+// selectMethod performs the selection step shared by INVOKEVIRTUAL and INVOKEINTERFACE:
+// it looks for the method first in the receiver's class and its superclasses, then for
+// a single maximally-specific, non-abstract method in its superinterfaces, which is where
+// default methods are found. Returns the name of the declaring class and the method's
+// MTable entry, or "" if no method is selected. The only error is for conflicting
+// default methods, which is returned as a *GErrBlk for an IncompatibleClassChangeError.
+func selectMethod(receiverClass, methName, methType string) (string, classloader.MTentry, error) {
+	declaringClass, mtEntry := classloader.FindMethodForReceiver(receiverClass, methName, methType)
+	if declaringClass != "" {
+		return declaringClass, mtEntry, nil
+	}
+
+	var nonAbstract []string
+	for _, iface := range classloader.FindMaxSpecificInterfaceMethods(receiverClass, methName, methType) {
+		k := classloader.MethAreaFetch(iface)
+		if k.Data.MethodTable[methName+methType].AccessFlags&classloader.AccAbstract == 0 {
+			nonAbstract = append(nonAbstract, iface)
+		}
+	}
+
+	if len(nonAbstract) > 1 {
+		errMsg := fmt.Sprintf("Conflicting default methods: %s.%s %s.%s",
+			javaClassName(nonAbstract[0]), methName, javaClassName(nonAbstract[1]), methName)
+		return "", classloader.MTentry{}, &classloader.GErrBlk{
+			ExceptionType: "java/lang/IncompatibleClassChangeError", ErrMsg: errMsg}
+	}
+
+	if len(nonAbstract) == 1 {
+		// fetching the method from its declaring class picks up any golang
+		// implementation of the method, which is then used instead of the bytecode.
+		mtEntry, err := classloader.FetchMethodAndCP(nonAbstract[0], methName, methType)
+		if err == nil && mtEntry.Meth != nil {
+			return nonAbstract[0], mtEntry, nil
+		}
+	}
+	return "", classloader.MTentry{}, nil
+}
+
+// isAbstractMethod reports whether an MTable entry is an abstract Java method
+func isAbstractMethod(mtEntry classloader.MTentry) bool {
+	if mtEntry.MType != 'J' {
+		return false
+	}
+	return mtEntry.Meth.(classloader.JmEntry).AccessFlags&classloader.AccAbstract != 0
+}
+
+// declaringClassOf returns the name of the class that declares the method in an MTable
+// entry. If the entry doesn't record its class, as for methods made by hand, it's
+// defaultClass.
+func declaringClassOf(mtEntry classloader.MTentry, defaultClass string) string {
+	switch meth := mtEntry.Meth.(type) {
+	case classloader.JmEntry:
+		if meth.ClName != "" {
+			return meth.ClName
+		}
+	case classloader.GmEntry:
+		if meth.ClName != "" {
+			return meth.ClName
+		}
+	}
+	return defaultClass
+}
+
+// countArgSlots returns the number of operand stack slots taken up by the arguments
+// of a method with the given descriptor. Longs and doubles take up two slots.
+func countArgSlots(methodType string) int {
+	slots := 0
+	for _, param := range parseParamDescriptors(methodType) {
+		slots += slotsFor(param)
+	}
+	return slots
+}
@@ -99,8 +99,7 @@ func CreateCompactStringFromGoString(in *string) *Object {
 
 // convenience method to extract a Go string from a Java string
 func GetGoStringFromJavaStringPtr(strPtr *Object) string {
-	s := *strPtr
-	bytes := s.Fields[0].Fvalue.(*[]byte)
+	bytes := strPtr.Fields[0].Fvalue.(*[]byte)
 	return string(*bytes)
 }
 
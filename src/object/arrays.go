@@ -19,9 +19,15 @@ import "jacobin/types"
 	array to a function, the entire array is copied over. We
 	don't want that!
 
-    For our purposes, there are four possible array types:
-    int64 (all integral types ), float64 (all FP types), bytes
-	(for bytes and boolean/bits), and references (i.e. pointers)
+    Every primitive array keeps the exact type of its elements,
+    which is the name of its class (e.g., [C for char[]). The
+    elements themselves are stored in one of four kinds of Go
+    arrays: int64 (char, short, int, and long elements),
+    float64 (float and double elements), bytes (for bytes
+    and boolean/bits), and references (i.e. pointers). The
+    array store instructions narrow the values they store to
+    the element type, so that an element holds only values
+    its Java type can represent.
 
     The official JVM docs suggest that bit arrays (so booleans)
     can be implemented as individual byte elements or aggregated
@@ -31,11 +37,16 @@ import "jacobin/types"
 */
 
 const ( // the ArrayTypes
-	ERROR = 0
-	FLOAT = 1
-	INT   = 2
-	BYTE  = 3
-	REF   = 4 // arrays of object references
+	ERROR  = 0
+	FLOAT  = 1
+	INT    = 2
+	BYTE   = 3
+	REF    = 4 // arrays of object references
+	BOOL   = 5
+	CHAR   = 6
+	SHORT  = 7
+	LONG   = 8
+	DOUBLE = 9
 )
 
 // the primitive types as specified in the
@@ -58,12 +69,22 @@ const T_REF = 12 // used only in Jacobin
 // by Jacobin in array creation. Returns zero on error.
 func JdkArrayTypeToJacobinType(jdkType int) int {
 	switch jdkType {
-	case T_BOOLEAN, T_BYTE:
+	case T_BOOLEAN:
+		return BOOL
+	case T_BYTE:
 		return BYTE
-	case T_CHAR, T_SHORT, T_INT, T_LONG:
+	case T_CHAR:
+		return CHAR
+	case T_SHORT:
+		return SHORT
+	case T_INT:
 		return INT
-	case T_FLOAT, T_DOUBLE:
+	case T_LONG:
+		return LONG
+	case T_FLOAT:
 		return FLOAT
+	case T_DOUBLE:
+		return DOUBLE
 	case T_REF:
 		return REF // technically not one of the JDK categories
 		// but needed for our purposes.
@@ -72,58 +93,94 @@ func JdkArrayTypeToJacobinType(jdkType int) int {
 	}
 }
 
-// Make2DimArray creates a the last two dimensions of a multi-
-// dimensional array. (All the dimensions > 2 are simply arrays
-// of pointers to arrays.)
+// Make2DimArray creates a two-dimensional array of ptrArrSize arrays
+// of leafArrSize elements of the specified type.
 func Make2DimArray(ptrArrSize, leafArrSize int64, arrType uint8) (*Object, error) {
-	ptrArr := MakeEmptyObject()                      // ptrArr is the pointer to the array of pointers to the leaf arrays
-	value := make([]*Object, ptrArrSize, ptrArrSize) // the actual ptr-level array
-	ptrArr.Fields = append(ptrArr.Fields, Field{Fvalue: &(value)})
-	for i := 0; i < len(value); i++ { // for each entry in the ptr array
-		value[i] = Make1DimArray(arrType, leafArrSize)
-	}
-
-	// the type of the pointer array will be the type of the leaf
-	// array with a [ pre pended.
-	ptrArrType := "[" + value[0].Fields[0].Ftype
-	ptrArr.Fields[0].Ftype = ptrArrType
+	return MakeMultiDimArray("["+arrayTypeNames[arrType], []int64{ptrArrSize, leafArrSize}), nil
+}
 
-	ptrArr.Klass = &value[0].Fields[0].Ftype
+// the array types of the Jacobin array types
+var arrayTypeNames = map[uint8]string{
+	BOOL: types.BoolArray, BYTE: types.ByteArray, CHAR: types.CharArray, SHORT: types.ShortArray,
+	INT: types.IntArray, LONG: types.LongArray, FLOAT: types.FloatArray, DOUBLE: types.DoubleArray,
+	REF: "[Ljava/lang/Object;",
+}
 
-	return ptrArr, nil
+// the Jacobin array types of the primitive element types
+var primitiveArrayTypes = map[byte]uint8{
+	'Z': BOOL, 'B': BYTE, 'C': CHAR, 'S': SHORT, 'I': INT, 'J': LONG, 'F': FLOAT, 'D': DOUBLE,
 }
 
 // Make1DimArray creates and 1-diminensional Jacobin-style array
-// of the specified type (passed as a byte) and size.
+// of the specified type (passed as a byte) and size. An array of
+// references (REF) is an array of java/lang/Object; arrays of other
+// classes are made by MakeArray().
 func Make1DimArray(arrType uint8, size int64) *Object {
 	o := MakeEmptyObject()
 	var of Field
 
 	switch arrType {
-	// case 'B': // byte arrays
-	case BYTE:
+	// case 'B', 'Z': // byte and boolean arrays
+	case BYTE, BOOL:
 		// barArr := make([]types.JavaByte, size) // changed with JACOBIN-282
 		barArr := make([]byte, size)
-		of = Field{Ftype: types.ByteArray, Fvalue: &barArr}
+		of = Field{Ftype: arrayTypeNames[arrType], Fvalue: &barArr}
 		o.Fields = append(o.Fields, of)
 	// case 'F', 'D': // float arrays
-	case FLOAT:
+	case FLOAT, DOUBLE:
 		farArr := make([]float64, size)
-		of := Field{Ftype: types.FloatArray, Fvalue: &farArr}
+		of := Field{Ftype: arrayTypeNames[arrType], Fvalue: &farArr}
 		o.Fields = append(o.Fields, of)
 	case REF: // reference/pointer arrays
 		rarArr := make([]*Object, size)
-		of := Field{Ftype: types.RefArray, Fvalue: &rarArr}
+		of := Field{Ftype: arrayTypeNames[REF], Fvalue: &rarArr}
 		o.Fields = append(o.Fields, of)
 	default: // all the integer types
 		iarArr := make([]int64, size)
-		of := Field{Ftype: types.IntArray, Fvalue: &iarArr}
+		of := Field{Ftype: arrayTypeNames[arrType], Fvalue: &iarArr}
+		if of.Ftype == "" {
+			of.Ftype = types.IntArray
+		}
 		o.Fields = append(o.Fields, of)
 	}
 	o.Klass = &o.Fields[0].Ftype // in arrays, Klass field is a pointer to the array type string
 	return o
 }
 
+// MakeArray creates a 1-dimensional array of the given array type,
+// such as [C, [Ljava/lang/String; or [[I, with size elements. The
+// elements of an array of references are null.
+func MakeArray(arrayType string, size int64) *Object {
+	if len(arrayType) == 2 {
+		if arrType, ok := primitiveArrayTypes[arrayType[1]]; ok {
+			return Make1DimArray(arrType, size)
+		}
+	}
+
+	o := MakeEmptyObject()
+	rarArr := make([]*Object, size)
+	o.Fields = append(o.Fields, Field{Ftype: arrayType, Fvalue: &rarArr})
+	o.Klass = &o.Fields[0].Ftype
+	return o
+}
+
+// MakeMultiDimArray creates an array of the given array type, which
+// has at least len(dimSizes) dimensions, as MULTIANEWARRAY does. The
+// first len(dimSizes) dimensions are created with the given sizes,
+// the first dimension's size first. Any further dimensions are left
+// null, to be created when they're assigned. As in the JDK, a
+// dimension of size zero leaves the dimensions after it uncreated.
+func MakeMultiDimArray(arrayType string, dimSizes []int64) *Object {
+	arr := MakeArray(arrayType, dimSizes[0])
+	if len(dimSizes) > 1 {
+		subArrays := *(arr.Fields[0].Fvalue.(*[]*Object))
+		for i := range subArrays {
+			subArrays[i] = MakeMultiDimArray(arrayType[1:], dimSizes[1:])
+		}
+	}
+	return arr
+}
+
 // MakeArrayFromRawArray accepts a raw array (such as []byte) and
 // converts it into an array *object*.
 func MakeArrayFromRawArray(rawArray interface{}) *Object {
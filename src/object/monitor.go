@@ -0,0 +1,178 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package object
+
+import (
+	"sync"
+	"time"
+)
+
+// Every Java object has a monitor, which a thread enters by executing MONITORENTER on
+// the object or by invoking one of the object's synchronized methods. Monitors are
+// reentrant: the owning thread can enter the same monitor repeatedly, and the monitor
+// is released only when it has been exited as many times as it was entered.
+//
+// A thread that owns a monitor can wait on it (Object.wait()), which releases the
+// monitor and places the thread in the monitor's wait set until another thread
+// notifies it (Object.notify() or notifyAll()), the wait times out, or the waiting
+// thread is interrupted. Either way, the thread then reacquires the monitor, with
+// the same entry count it had before waiting, before it resumes.
+//
+// Few objects are ever locked, so monitors are not allocated when objects are created.
+// Rather, the first time an object's monitor is needed, a monitor is created and stored
+// in the object, where it stays for the life of the object.
+
+// Monitor is the lock of a Java object. Threads are identified by their thread IDs.
+type Monitor struct {
+	mutex   sync.Mutex      // guards owner, count, and waitSet
+	freed   *sync.Cond      // signaled when the monitor is released
+	owner   int             // the ID of the thread that owns the monitor, if count > 0
+	count   int             // the number of times the owner has entered the monitor
+	waitSet []chan struct{} // the waiting threads, in order; each channel is closed to notify its thread
+}
+
+// GetMonitor returns the monitor of an object, creating it if necessary. If two threads
+// create a monitor for the same object at once, only the first one stored is used.
+func GetMonitor(obj *Object) *Monitor {
+	if m := obj.monitor.Load(); m != nil {
+		return m
+	}
+
+	m := &Monitor{}
+	m.freed = sync.NewCond(&m.mutex)
+	if obj.monitor.CompareAndSwap(nil, m) {
+		return m
+	}
+	return obj.monitor.Load()
+}
+
+// Enter acquires the monitor for the thread threadID. If another thread owns
+// the monitor, Enter blocks until the monitor is released.
+func (m *Monitor) Enter(threadID int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for m.count > 0 && m.owner != threadID {
+		m.freed.Wait()
+	}
+	m.owner = threadID
+	m.count += 1
+}
+
+// Exit releases one entry into the monitor by the thread threadID. Returns false,
+// leaving the monitor unchanged, if that thread does not own the monitor.
+func (m *Monitor) Exit(threadID int) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.count == 0 || m.owner != threadID {
+		return false
+	}
+
+	m.count -= 1
+	if m.count == 0 {
+		m.freed.Signal()
+	}
+	return true
+}
+
+// IsOwnedBy reports whether the thread threadID owns the monitor
+func (m *Monitor) IsOwnedBy(threadID int) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.count > 0 && m.owner == threadID
+}
+
+// Wait releases the monitor, which the thread threadID must own, and waits until the
+// thread is notified, timeout has elapsed (if it's greater than zero), or a value is
+// received from interrupt. The thread then reacquires the monitor before returning.
+// Returns false if the thread does not own the monitor, in which case it does not wait.
+// Whether the wait ended because of an interrupt is returned as the second value.
+func (m *Monitor) Wait(threadID int, timeout time.Duration, interrupt <-chan struct{}) (bool, bool) {
+	m.mutex.Lock()
+	if m.count == 0 || m.owner != threadID {
+		m.mutex.Unlock()
+		return false, false
+	}
+
+	// release the monitor completely, remembering how many times it was entered
+	entries := m.count
+	m.count = 0
+	notified := make(chan struct{})
+	m.waitSet = append(m.waitSet, notified)
+	m.freed.Signal()
+	m.mutex.Unlock()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	interrupted := false
+	select {
+	case <-notified:
+	case <-expired:
+	case <-interrupt:
+		interrupted = true
+	}
+
+	// reacquire the monitor. A thread that was not notified is still in the wait set.
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.removeWaiter(notified)
+	for m.count > 0 {
+		m.freed.Wait()
+	}
+	m.owner = threadID
+	m.count = entries
+	return true, interrupted
+}
+
+// Notify wakes the thread that has waited longest on the monitor, if any. Returns
+// false if the thread threadID does not own the monitor.
+func (m *Monitor) Notify(threadID int) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.count == 0 || m.owner != threadID {
+		return false
+	}
+
+	if len(m.waitSet) > 0 {
+		close(m.waitSet[0])
+		m.waitSet = m.waitSet[1:]
+	}
+	return true
+}
+
+// NotifyAll wakes all the threads waiting on the monitor. Returns false if the
+// thread threadID does not own the monitor.
+func (m *Monitor) NotifyAll(threadID int) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.count == 0 || m.owner != threadID {
+		return false
+	}
+
+	for _, notified := range m.waitSet {
+		close(notified)
+	}
+	m.waitSet = nil
+	return true
+}
+
+// removeWaiter removes a thread, identified by its notification channel, from the
+// wait set. It's a no-op for a thread that has already been notified.
+func (m *Monitor) removeWaiter(notified chan struct{}) {
+	for i, waiter := range m.waitSet {
+		if waiter == notified {
+			m.waitSet = append(m.waitSet[:i], m.waitSet[i+1:]...)
+			return
+		}
+	}
+}
@@ -7,6 +7,7 @@
 package object
 
 import (
+	"sync/atomic"
 	"unsafe"
 )
 
@@ -19,6 +20,8 @@ type Object struct {
 	Klass      *string // the class name in the method area
 	Fields     []Field // slice containing the fields
 	FieldTable map[string]Field
+	LaidOut    bool                    // Fields follow the field layout of the class (see classloader/fieldLayout.go)
+	monitor    atomic.Pointer[Monitor] // the object's monitor, created when first needed (see monitor.go)
 }
 
 // These mark word contains values for different purposes. Here,
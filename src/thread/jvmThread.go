@@ -9,35 +9,195 @@ package thread
 import (
 	"container/list"
 	"jacobin/globals"
+	"jacobin/object"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Creates a JVM program execution thread. These threads are extremely limited.
-// They basically hold a Stack of frames. They push and popFrame frames as required.
-// They begin execution; they exit when execution ends; and they emit diagnostic
-// and performance data.
+// Creates a JVM program execution thread. Each thread holds a Stack of frames, which
+// it pushes and pops as methods are called and return. Every Java thread, including
+// the main thread, runs its own interpreter loop on a goroutine. Threads begin execution;
+// they exit when execution ends; and they emit diagnostic and performance data.
 
 type ExecThread struct {
-	ID    int        // the thread ID
-	Stack *list.List // the JVM Stack (frame stack, that is) for this thread
-	PC    int        // the program counter (the index to the instruction being executed)
-	Trace bool       // do we Trace instructions?
+	ID            int            // the thread ID
+	Stack         *list.List     // the JVM Stack (frame stack, that is) for this thread
+	PC            int            // the program counter (the index to the instruction being executed)
+	Trace         bool           // do we Trace instructions?
+	MaxFrameDepth int            // the maximum depth of Stack; 0 means no limit
+	Object        *object.Object // the java.lang.Thread object for this thread
+	Daemon        bool           // daemon threads don't keep the JVM running after main() ends
+	interrupted   int32          // 1 if the thread has been interrupted, accessed atomically
+	wakeup        chan struct{}  // signaled when the thread is interrupted
+	done          chan struct{}  // closed when the thread ends
 }
 
+// nonDaemonThreads counts the running threads that are not daemons. The JVM waits
+// for them to end before it exits.
+var nonDaemonThreads sync.WaitGroup
+
 func CreateThread() ExecThread {
 	t := ExecThread{}
 	t.ID = 0
 	t.PC = 0
 	t.Stack = nil
 	t.Trace = false
+	t.wakeup = make(chan struct{}, 1)
+	t.done = make(chan struct{})
 	return t
 }
 
+// AddThreadToTable adds a thread to the thread table and assigns it an ID, which is
+// unique and does not change for the life of the thread
 func AddThreadToTable(t *ExecThread, tbl *globals.ThreadList) int {
 	tbl.ThreadsMutex.Lock()
-
 	tbl.ThreadsList.PushBack(t)
-	t.ID = tbl.ThreadsList.Len() - 1
+	t.ID = tbl.NextID
+	tbl.NextID += 1
 	tbl.ThreadsMutex.Unlock()
-
 	return t.ID
 }
+
+// RemoveThreadFromTable removes a thread that has ended from the thread table
+func RemoveThreadFromTable(t *ExecThread, tbl *globals.ThreadList) {
+	tbl.ThreadsMutex.Lock()
+	defer tbl.ThreadsMutex.Unlock()
+	for e := tbl.ThreadsList.Front(); e != nil; e = e.Next() {
+		if e.Value.(*ExecThread) == t {
+			tbl.ThreadsList.Remove(e)
+			return
+		}
+	}
+}
+
+// FindThread returns the running thread with the given ID, or nil if there is none
+func FindThread(id int) *ExecThread {
+	return findThreadInTable(func(t *ExecThread) bool { return t.ID == id })
+}
+
+// FindThreadByObject returns the running thread whose java.lang.Thread object is obj,
+// or nil if that thread has not been started or has ended
+func FindThreadByObject(obj *object.Object) *ExecThread {
+	return findThreadInTable(func(t *ExecThread) bool { return t.Object == obj })
+}
+
+func findThreadInTable(match func(t *ExecThread) bool) *ExecThread {
+	tbl := &globals.GetGlobalRef().Threads
+	tbl.ThreadsMutex.Lock()
+	defer tbl.ThreadsMutex.Unlock()
+	for e := tbl.ThreadsList.Front(); e != nil; e = e.Next() {
+		if t := e.Value.(*ExecThread); match(t) {
+			return t
+		}
+	}
+	return nil
+}
+
+// Started is called when a thread begins running. A thread that is not a daemon
+// keeps the JVM from exiting until it ends.
+func (t *ExecThread) Started() {
+	if !t.Daemon {
+		nonDaemonThreads.Add(1)
+	}
+}
+
+// Ended is called when a thread finishes running. It removes the thread from the
+// thread table and releases any threads waiting to join it.
+func (t *ExecThread) Ended() {
+	RemoveThreadFromTable(t, &globals.GetGlobalRef().Threads)
+	close(t.done)
+	if !t.Daemon {
+		nonDaemonThreads.Done()
+	}
+}
+
+// WaitForNonDaemonThreads blocks until all the running threads that are not
+// daemons have ended. It's called when main() returns.
+func WaitForNonDaemonThreads() {
+	nonDaemonThreads.Wait()
+}
+
+// Interrupt sets the thread's interrupt status and wakes it if it's sleeping,
+// waiting, or joining another thread
+func (t *ExecThread) Interrupt() {
+	atomic.StoreInt32(&t.interrupted, 1)
+	select {
+	case t.wakeup <- struct{}{}:
+	default: // a wakeup is already pending
+	}
+}
+
+// IsInterrupted reports whether the thread's interrupt status is set
+func (t *ExecThread) IsInterrupted() bool {
+	return atomic.LoadInt32(&t.interrupted) == 1
+}
+
+// ClearInterrupt clears the thread's interrupt status and reports whether it was
+// set, as Thread.interrupted() does
+func (t *ExecThread) ClearInterrupt() bool {
+	wasInterrupted := atomic.SwapInt32(&t.interrupted, 0) == 1
+	select {
+	case <-t.wakeup: // discard any pending wakeup
+	default:
+	}
+	return wasInterrupted
+}
+
+// Sleep suspends the thread for duration d. Returns false if the thread was
+// interrupted before or while sleeping, in which case the interrupt status is cleared.
+func (t *ExecThread) Sleep(d time.Duration) bool {
+	if t.ClearInterrupt() {
+		return false
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-t.wakeup:
+		return !t.ClearInterrupt()
+	}
+}
+
+// Join blocks thread t until thread other ends or, if timeout is greater than zero,
+// until timeout has elapsed. Returns false if t was interrupted, in which case the
+// interrupt status is cleared.
+func (t *ExecThread) Join(other *ExecThread, timeout time.Duration) bool {
+	if t.ClearInterrupt() {
+		return false
+	}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case <-other.done:
+		return true
+	case <-expired:
+		return true
+	case <-t.wakeup:
+		return !t.ClearInterrupt()
+	}
+}
+
+// WaitOnMonitor makes thread t wait on monitor m, as Object.wait() does, until it's
+// notified, timeout elapses (if it's greater than zero), or t is interrupted. The
+// first value returned is false if t does not own m, in which case t does not wait.
+// The second is true if t was interrupted, in which case the interrupt status is cleared.
+func (t *ExecThread) WaitOnMonitor(m *object.Monitor, timeout time.Duration) (bool, bool) {
+	if !m.IsOwnedBy(t.ID) {
+		return false, false
+	}
+	if t.ClearInterrupt() { // a thread that's already interrupted does not wait
+		return true, true
+	}
+
+	m.Wait(t.ID, timeout, t.wakeup)
+	return true, t.ClearInterrupt()
+}
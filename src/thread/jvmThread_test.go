@@ -9,8 +9,10 @@ package thread
 import (
 	"container/list"
 	"jacobin/globals"
+	"jacobin/object"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestCreateThread(t *testing.T) {
@@ -88,3 +90,150 @@ func add100threads(tbl *globals.ThreadList, wgrp *sync.WaitGroup) {
 	}
 	wgrp.Done() // decrements the wait group by 1.
 }
+
+// IDs are not reused when threads end, so they're unique for the life of the JVM
+func TestThreadIDsNotReused(t *testing.T) {
+	tbl := globals.ThreadList{}
+	tbl.ThreadsList = list.New()
+
+	th1 := CreateThread()
+	th2 := CreateThread()
+	id1 := AddThreadToTable(&th1, &tbl)
+	RemoveThreadFromTable(&th1, &tbl)
+	id2 := AddThreadToTable(&th2, &tbl)
+
+	if id1 == id2 {
+		t.Errorf("Expected a new ID for the second thread, but both got %d", id1)
+	}
+	if tbl.ThreadsList.Len() != 1 {
+		t.Errorf("Expected thread table to have 1 element; got %d", tbl.ThreadsList.Len())
+	}
+}
+
+// an interrupt wakes a sleeping thread and is cleared when the thread wakes
+func TestSleepInterrupted(t *testing.T) {
+	th := CreateThread()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		th.Interrupt()
+	}()
+
+	start := time.Now()
+	if th.Sleep(5 * time.Second) {
+		t.Errorf("Expected Sleep() to report the interrupt")
+	}
+	if time.Since(start) >= 5*time.Second {
+		t.Errorf("Expected the interrupt to end the sleep early")
+	}
+	if th.IsInterrupted() {
+		t.Errorf("Expected the interrupt status to be cleared")
+	}
+	if !th.Sleep(time.Millisecond) {
+		t.Errorf("Expected an uninterrupted Sleep() to complete")
+	}
+}
+
+// Join() waits for the other thread to end, or for the timeout to elapse
+func TestJoin(t *testing.T) {
+	globals.InitGlobals("test")
+	caller := CreateThread()
+	other := CreateThread()
+	AddThreadToTable(&other, &globals.GetGlobalRef().Threads)
+	other.Started()
+
+	if !caller.Join(&other, 10*time.Millisecond) {
+		t.Errorf("Expected Join() to time out without an interrupt")
+	}
+	if FindThread(other.ID) != &other {
+		t.Errorf("Expected the other thread to still be running")
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		other.Ended()
+	}()
+	if !caller.Join(&other, 0) {
+		t.Errorf("Expected Join() to return when the other thread ended")
+	}
+	if FindThread(other.ID) != nil {
+		t.Errorf("Expected the ended thread to be removed from the thread table")
+	}
+	WaitForNonDaemonThreads() // returns at once, as the only non-daemon thread has ended
+}
+
+// a thread waiting on a monitor is woken by a notify, and owns the monitor again,
+// with the same entry count, when the wait ends
+func TestWaitOnMonitorNotified(t *testing.T) {
+	waiter := CreateThread()
+	waiter.ID = 1
+	m := object.GetMonitor(object.MakeEmptyObject())
+	m.Enter(waiter.ID)
+	m.Enter(waiter.ID)
+
+	go func() {
+		m.Enter(2) // blocks until the waiter releases the monitor
+		m.Notify(2)
+		m.Exit(2)
+	}()
+
+	owned, interrupted := waiter.WaitOnMonitor(m, 5*time.Second)
+	if !owned || interrupted {
+		t.Errorf("Expected a notified wait, got owned: %t, interrupted: %t", owned, interrupted)
+	}
+	if !m.Exit(waiter.ID) || !m.Exit(waiter.ID) || m.IsOwnedBy(waiter.ID) {
+		t.Errorf("Expected the waiter to own the monitor twice after the wait")
+	}
+}
+
+// a wait ends when its timeout elapses, and a thread that doesn't own the monitor
+// can't wait on it
+func TestWaitOnMonitorTimeout(t *testing.T) {
+	waiter := CreateThread()
+	waiter.ID = 1
+	m := object.GetMonitor(object.MakeEmptyObject())
+
+	if owned, _ := waiter.WaitOnMonitor(m, 10*time.Millisecond); owned {
+		t.Errorf("Expected WaitOnMonitor() to fail when the monitor is not owned")
+	}
+
+	m.Enter(waiter.ID)
+	start := time.Now()
+	owned, interrupted := waiter.WaitOnMonitor(m, 10*time.Millisecond)
+	if !owned || interrupted {
+		t.Errorf("Expected the wait to time out, got owned: %t, interrupted: %t", owned, interrupted)
+	}
+	if time.Since(start) >= 5*time.Second {
+		t.Errorf("Expected the wait to end when the timeout elapsed")
+	}
+	if !m.IsOwnedBy(waiter.ID) {
+		t.Errorf("Expected the waiter to own the monitor after the wait")
+	}
+}
+
+// an interrupt ends a wait and is cleared, and a thread that is already interrupted
+// does not wait
+func TestWaitOnMonitorInterrupted(t *testing.T) {
+	waiter := CreateThread()
+	waiter.ID = 1
+	m := object.GetMonitor(object.MakeEmptyObject())
+	m.Enter(waiter.ID)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		waiter.Interrupt()
+	}()
+	if _, interrupted := waiter.WaitOnMonitor(m, 0); !interrupted {
+		t.Errorf("Expected the interrupt to end the wait")
+	}
+	if waiter.IsInterrupted() {
+		t.Errorf("Expected the interrupt status to be cleared")
+	}
+
+	waiter.Interrupt()
+	if _, interrupted := waiter.WaitOnMonitor(m, 0); !interrupted {
+		t.Errorf("Expected an interrupted thread to return from WaitOnMonitor() at once")
+	}
+	if !m.IsOwnedBy(waiter.ID) {
+		t.Errorf("Expected the waiter to own the monitor after the wait")
+	}
+}
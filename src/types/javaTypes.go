@@ -19,10 +19,15 @@ const Ref = "L"
 const Short = "S"
 
 const Array = "["
+const BoolArray = "[Z"
 const ByteArray = "[B"
-const IntArray = "[I"
+const CharArray = "[C"
+const DoubleArray = "[D"
 const FloatArray = "[F"
+const IntArray = "[I"
+const LongArray = "[J"
 const RefArray = "[L"
+const ShortArray = "[S"
 const RuneArray = "[R" // used only in strings that are not compact
 
 // Jacobin-specific types
@@ -79,6 +84,20 @@ func UsesTwoSlots(t string) bool {
 	return false
 }
 
+// DefaultValue returns the value that a field of type t has before it's set: nil
+// (null) for references and zero for the primitive types. Integral values are kept
+// as int64 and floating-point values as float64.
+func DefaultValue(t string) any {
+	switch {
+	case IsIntegral(t):
+		return int64(0)
+	case IsFloatingPoint(t):
+		return 0.0
+	default:
+		return nil
+	}
+}
+
 // bytes in Go are uint8, whereas in Java they are int8. Hence this type alias.
 type JavaByte = int8
 
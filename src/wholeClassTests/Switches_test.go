@@ -0,0 +1,189 @@
+/*
+ * Jacobin VM - A Java virtual machine
+ * Copyright (c) 2023 by the Jacobin authors. All rights reserved.
+ * Licensed under Mozilla Public License 2.0 (MPL 2.0)
+ */
+
+package wholeClassTests
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// Test for testSwitches class, which tests the TABLESWITCH and LOOKUPSWITCH bytecodes
+// with dense, sparse, negative-key, and default-only switches. The switch operands are
+// computed differently in the methods so that the switches begin at different offsets
+// and so exercise all the possible amounts of alignment padding. Source code:
+//
+//	public class testSwitches {
+//		static int dense(int i) { // compiles to tableswitch
+//			switch (i) {
+//				case 1: return 10;
+//				case 2: return 20;
+//				case 3: return 30;
+//				case 4: return 40;
+//				default: return -1;
+//			}
+//		}
+//
+//		static int sparse(int i) { // compiles to lookupswitch
+//			switch (i * 10) {
+//				case 10: return 1;
+//				case 10000: return 2;
+//				case 10000000: return 3;
+//				default: return 0;
+//			}
+//		}
+//
+//		static int negative(int i) { // compiles to tableswitch with negative low value
+//			switch (i + 1) {
+//				case -2: return 1;
+//				case -1: return 2;
+//				case 0: return 3;
+//				default: return 0;
+//			}
+//		}
+//
+//		static int negativeSparse(int i) { // compiles to lookupswitch with negative keys
+//			switch (i) {
+//				case -1000000: return 1;
+//				case -1000: return 2;
+//				case 5: return 3;
+//				default: return 0;
+//			}
+//		}
+//
+//		static int defaultOnly(int i) { // compiles to lookupswitch with no pairs
+//			switch (i) {
+//				default: return 7;
+//			}
+//		}
+//
+//		public static void main(String[] args) {
+//			System.out.print("dense 3: "); System.out.println(dense(3));
+//			System.out.print("dense 9: "); System.out.println(dense(9));
+//			System.out.print("sparse 1000000: "); System.out.println(sparse(1000000));
+//			System.out.print("sparse 999: "); System.out.println(sparse(999));
+//			System.out.print("negative -2: "); System.out.println(negative(-2));
+//			System.out.print("negative -4: "); System.out.println(negative(-4));
+//			System.out.print("negativeSparse -1000000: "); System.out.println(negativeSparse(-1000000));
+//			System.out.print("negativeSparse -1000: "); System.out.println(negativeSparse(-1000));
+//			System.out.print("negativeSparse 4: "); System.out.println(negativeSparse(4));
+//			System.out.print("defaultOnly 5: "); System.out.println(defaultOnly(5));
+//		}
+//	}
+//
+// To run your class, enter its name in _TESTCLASS, any args in their respective variables and then run the tests.
+// This test harness expects that environmental variable JACOBIN_EXE gives the full name and path of the executable
+// we're running the tests on. The folder which contains the test class should be specified in the environmental
+// variable JACOBIN_TESTDATA (without a terminating slash).
+func initVarsTestSwitches() error {
+	if testing.Short() { // don't run if running quick tests only. (Used primarily so GitHub doesn't run and bork)
+		return fmt.Errorf("test not run due to -short")
+	}
+
+	_JACOBIN = os.Getenv("JACOBIN_EXE") // returns "" if JACOBIN_EXE has not been specified.
+	_JVM_ARGS = ""
+	_TESTCLASS = "testSwitches.class" // the class to test
+	_APP_ARGS = ""
+
+	if _JACOBIN == "" {
+		return fmt.Errorf("test failure due to missing Jacobin executable. Please specify it in JACOBIN_EXE")
+	} else if _, err := os.Stat(_JACOBIN); err != nil {
+		return fmt.Errorf("missing Jacobin executable, which was specified as %s", _JACOBIN)
+	}
+
+	if _TESTCLASS != "" {
+		testClass := os.Getenv("JACOBIN_TESTDATA") + string(os.PathSeparator) + _TESTCLASS
+		if _, err := os.Stat(testClass); err != nil {
+			return fmt.Errorf("missing class to test, which was specified as %s", testClass)
+		} else {
+			_TESTCLASS = testClass
+		}
+	}
+	return nil
+}
+
+func TestRunSwitches(t *testing.T) {
+	if testing.Short() { // don't run if running quick tests only. (Used primarily so GitHub doesn't run and bork)
+		t.Skip()
+	}
+
+	initErr := initVarsTestSwitches()
+	if initErr != nil {
+		t.Fatalf("Test failure due to: %s", initErr.Error())
+	}
+	var cmd *exec.Cmd
+
+	if testing.Short() { // don't run if running quick tests only. (Used primarily so GitHub doesn't run and bork)
+		t.Skip()
+	}
+
+	// test that executable exists
+	if _, err := os.Stat(_JACOBIN); err != nil {
+		t.Errorf("Missing Jacobin executable, which was specified as %s", _JACOBIN)
+	}
+
+	// run the various combinations of args. This is necessary b/c the empty string is viewed as
+	// an actual specified option on the command line.
+	if len(_JVM_ARGS) > 0 {
+		if len(_APP_ARGS) > 0 {
+			cmd = exec.Command(_JACOBIN, _JVM_ARGS, _TESTCLASS, _APP_ARGS)
+		} else {
+			cmd = exec.Command(_JACOBIN, _JVM_ARGS, _TESTCLASS)
+		}
+	} else {
+		if len(_APP_ARGS) > 0 {
+			cmd = exec.Command(_JACOBIN, _TESTCLASS, _APP_ARGS)
+		} else {
+			cmd = exec.Command(_JACOBIN, _TESTCLASS)
+		}
+	}
+
+	// get the stdout and stderr contents from the file execution
+	stderr, err := cmd.StderrPipe()
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// run the command
+	if err = cmd.Start(); err != nil {
+		t.Errorf("Got error running Jacobin: %s", err.Error())
+	}
+
+	// Here begin the actual tests on the output to stderr and stdout
+	slurp, _ := io.ReadAll(stderr)
+	slurpErr := string(slurp)
+	if len(slurp) != 0 {
+		t.Errorf("Got unexpected output to stderr: %s", slurpErr)
+	}
+
+	slurp, _ = io.ReadAll(stdout)
+	slurpOut := string(slurp)
+
+	expected := []string{
+		"dense 3: 30",
+		"dense 9: -1",
+		"sparse 1000000: 3",
+		"sparse 999: 0",
+		"negative -2: 2",
+		"negative -4: 0",
+		"negativeSparse -1000000: 1",
+		"negativeSparse -1000: 2",
+		"negativeSparse 4: 0",
+		"defaultOnly 5: 7",
+	}
+
+	for _, exp := range expected {
+		if !strings.Contains(slurpOut, exp) {
+			t.Errorf("Did not get expected output to stdout: %s. Got: %s", exp, slurpOut)
+		}
+	}
+}